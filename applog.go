@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The application log records what the program did on the user's behalf
+// that they didn't see it do: the variables it filled in, what the plugins
+// changed, answers it took from the offline file, chaos it inflicted, and
+// the things that failed quietly so as not to cost an answer, such as
+// writing the audit log. When something surprises, the log says why.
+
+// logLevel is how much an entry of the application log matters.
+type logLevel int
+
+const (
+	logDebug logLevel = iota
+	logInfo
+	logWarn
+	logError
+)
+
+// logLevels names the levels, in order.
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+func (l logLevel) String() string { return logLevels[l] }
+
+// parseLogLevel reads a level by its name.
+func parseLogLevel(name string) (logLevel, error) {
+	for i, n := range logLevels {
+		if strings.EqualFold(name, n) {
+			return logLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q; use %s", name, strings.Join(logLevels, ", "))
+}
+
+// logEntry is one thing the program did.
+type logEntry struct {
+	time  time.Time
+	level logLevel
+	text  string
+}
+
+// maxLogEntries is how many entries the log keeps; the oldest go first.
+const maxLogEntries = 500
+
+// eventLog is the application log, shared by every tab, and written to
+// from whichever goroutine did the thing.
+type eventLog struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+// appLog is the program's application log.
+var appLog = &eventLog{}
+
+// logf adds an entry at level to the application log.
+func logf(level logLevel, format string, args ...any) {
+	appLog.add(level, fmt.Sprintf(format, args...))
+}
+
+// add appends an entry, dropping the oldest once the log is full.
+func (l *eventLog) add(level logLevel, text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, logEntry{time.Now(), level, text})
+	if len(l.entries) > maxLogEntries {
+		l.entries = l.entries[len(l.entries)-maxLogEntries:]
+	}
+}
+
+// last returns the newest n entries at level or above, oldest first.
+func (l *eventLog) last(n int, level logLevel) []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var picked []logEntry
+	for i := len(l.entries) - 1; i >= 0 && len(picked) < n; i-- {
+		if l.entries[i].level >= level {
+			picked = append(picked, l.entries[i])
+		}
+	}
+	for i, j := 0, len(picked)-1; i < j; i, j = i+1, j-1 {
+		picked[i], picked[j] = picked[j], picked[i]
+	}
+	return picked
+}
+
+// logPaneLines is how many entries the log pane shows.
+const logPaneLines = 10
+
+// viewLog draws the log pane: the newest entries at the tab's level or above.
+func (m model) viewLog() string {
+	entries := appLog.last(logPaneLines, m.logLevel)
+	s := fmt.Sprintf("Log (%s and up; :log LEVEL to filter, L to hide)", m.logLevel)
+	if len(entries) == 0 {
+		return s + "\n  Nothing yet."
+	}
+	for _, e := range entries {
+		s += fmt.Sprintf("\n  %s %-5s %s", e.time.Format(time.TimeOnly), strings.ToUpper(e.level.String()), e.text)
+	}
+	return s
+}
+
+// runLog is the palette's `log [level]`: it shows the log pane, with only
+// the entries at level or above if a level is given, and hides it again
+// when no level is.
+func runLog(m model, args []string) (tea.Model, tea.Cmd) {
+	switch len(args) {
+	case 0:
+		m.showLog = !m.showLog
+	case 1:
+		level, err := parseLogLevel(args[0])
+		if err != nil {
+			return m.paletteError(err)
+		}
+		m.logLevel, m.showLog = level, true
+	default:
+		return m.paletteError(errors.New("usage: log [debug | info | warn | error]"))
+	}
+	return m, nil
+}
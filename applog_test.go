@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEventLog(t *testing.T) {
+	l := &eventLog{}
+	for i := range maxLogEntries + 2 {
+		l.add(logLevel(i%4), string(rune('a'+i%26)))
+	}
+	if len(l.entries) != maxLogEntries {
+		t.Errorf("kept %d entries", len(l.entries))
+	}
+	got := l.last(3, logWarn)
+	if len(got) != 3 || got[0].text != "b" || got[1].level != logWarn || got[2].text != "f" {
+		t.Errorf("last = %+v", got)
+	}
+}
+
+func TestLogRequest(t *testing.T) {
+	appLog = &eventLog{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	cfg := config{
+		method: "GET",
+		url:    srv.URL + "/?q={{q}}&x={{nope}}",
+		header: http.Header{"Authorization": {"Bearer {{api_token}}"}},
+		vars:   map[string]string{"q": "shoes", "api_token": "s3cret"},
+	}
+	send(cfg)
+	m := model{cfg: cfg}
+	next, _ := runLog(m, []string{"debug"})
+	view := next.(model).viewLog()
+	for _, want := range []string{
+		"DEBUG Filled in {{q}} as shoes, from global",
+		"DEBUG Filled in {{api_token}} as •••••• (6 characters), from global",
+		"WARN  {{nope}} is not defined",
+		"INFO  GET " + srv.URL,
+	} {
+		if !strings.Contains(view, want) {
+			t.Errorf("log lacks %q:\n%s", want, view)
+		}
+	}
+	if strings.Contains(view, "s3cret") {
+		t.Errorf("log shows the secret:\n%s", view)
+	}
+
+	next, _ = runLog(next.(model), []string{"warn"})
+	if view := next.(model).viewLog(); strings.Contains(view, "DEBUG") || strings.Contains(view, "INFO") {
+		t.Errorf("warn log shows less severe entries:\n%s", view)
+	}
+	if next, _ = runLog(next.(model), nil); next.(model).showLog {
+		t.Error("log without a level didn't hide the pane")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// minHSTSAge is the smallest HSTS max-age, in seconds, we consider adequate
+// (180 days, the usual recommendation).
+const minHSTSAge = 180 * 24 * 60 * 60
+
+// finding is the verdict on one security header.
+type finding struct {
+	header string // Header that was checked.
+	score  int    // 2 for good, 1 for present but weak, 0 for missing.
+	note   string // What is wrong, or what was found when all is well.
+}
+
+// maxAgeRe pulls the max-age directive out of a Strict-Transport-Security value.
+var maxAgeRe = regexp.MustCompile(`(?i)max-age\s*=\s*"?(\d+)"?`)
+
+// auditHeaders grades the security headers in h. https says whether the
+// response came over TLS, since HSTS is only honoured there.
+func auditHeaders(h http.Header, https bool) []finding {
+	return []finding{
+		auditHSTS(h.Get("Strict-Transport-Security"), https),
+		auditCSP(h.Get("Content-Security-Policy")),
+		auditFrameOptions(h.Get("X-Frame-Options"), h.Get("Content-Security-Policy")),
+		auditContentTypeOptions(h.Get("X-Content-Type-Options")),
+		auditReferrerPolicy(h.Get("Referrer-Policy")),
+	}
+}
+
+// auditHSTS checks Strict-Transport-Security.
+func auditHSTS(v string, https bool) finding {
+	f := finding{header: "Strict-Transport-Security"}
+	switch m := maxAgeRe.FindStringSubmatch(v); {
+	case !https:
+		f.note = "not served over HTTPS, so HSTS cannot apply"
+	case v == "":
+		f.note = "missing; browsers may be downgraded to plain HTTP"
+	case m == nil:
+		f.score, f.note = 1, "has no max-age directive"
+	default:
+		age, _ := strconv.Atoi(m[1])
+		if age < minHSTSAge {
+			f.score, f.note = 1, fmt.Sprintf("max-age=%d is under the recommended %d (180 days)", age, minHSTSAge)
+		} else {
+			f.score, f.note = 2, v
+		}
+	}
+	return f
+}
+
+// auditCSP checks Content-Security-Policy for presence and obvious escapes.
+func auditCSP(v string) finding {
+	f := finding{header: "Content-Security-Policy"}
+	lower := strings.ToLower(v)
+	switch {
+	case v == "":
+		f.note = "missing; nothing limits where scripts may load from"
+	case strings.Contains(lower, "'unsafe-inline'"), strings.Contains(lower, "'unsafe-eval'"):
+		f.score, f.note = 1, "allows 'unsafe-inline' or 'unsafe-eval', which defeats most of its XSS protection"
+	default:
+		f.score, f.note = 2, "present"
+	}
+	return f
+}
+
+// auditFrameOptions checks clickjacking protection, which either
+// X-Frame-Options or CSP frame-ancestors can provide.
+func auditFrameOptions(v, csp string) finding {
+	f := finding{header: "X-Frame-Options"}
+	switch strings.ToUpper(strings.TrimSpace(v)) {
+	case "DENY", "SAMEORIGIN":
+		f.score, f.note = 2, v
+	case "":
+		if strings.Contains(strings.ToLower(csp), "frame-ancestors") {
+			f.score, f.note = 2, "covered by CSP frame-ancestors"
+		} else {
+			f.note = "missing; the page can be framed for clickjacking"
+		}
+	default:
+		f.score, f.note = 1, fmt.Sprintf("%q is not DENY or SAMEORIGIN and is ignored by modern browsers", v)
+	}
+	return f
+}
+
+// auditContentTypeOptions checks that MIME sniffing is turned off.
+func auditContentTypeOptions(v string) finding {
+	f := finding{header: "X-Content-Type-Options"}
+	switch {
+	case strings.EqualFold(strings.TrimSpace(v), "nosniff"):
+		f.score, f.note = 2, "nosniff"
+	case v == "":
+		f.note = "missing; browsers may sniff responses into executable types"
+	default:
+		f.score, f.note = 1, fmt.Sprintf("%q is not the only valid value, nosniff", v)
+	}
+	return f
+}
+
+// auditReferrerPolicy checks that full URLs don't leak to other sites.
+func auditReferrerPolicy(v string) finding {
+	f := finding{header: "Referrer-Policy"}
+	// Several comma-separated policies may be given; the last one wins.
+	policy := v
+	if i := strings.LastIndex(v, ","); i >= 0 {
+		policy = v[i+1:]
+	}
+	switch strings.ToLower(strings.TrimSpace(policy)) {
+	case "":
+		f.note = "missing; relying on the browser default"
+	case "unsafe-url", "no-referrer-when-downgrade", "origin-when-cross-origin":
+		f.score, f.note = 1, fmt.Sprintf("%q leaks more of the URL than needed to other sites", policy)
+	default:
+		f.score, f.note = 2, strings.TrimSpace(policy)
+	}
+	return f
+}
+
+// grade turns findings into a letter grade from A to F.
+func grade(findings []finding) string {
+	score, best := 0, 0
+	for _, f := range findings {
+		score += f.score
+		best += 2
+	}
+	switch pct := score * 100 / best; {
+	case pct >= 90:
+		return "A"
+	case pct >= 70:
+		return "B"
+	case pct >= 50:
+		return "C"
+	case pct >= 30:
+		return "D"
+	default:
+		return "F"
+	}
+}
+
+// securityAudit builds the report shown for the audit action.
+func securityAudit(res response, https bool) reportMsg {
+	findings := auditHeaders(res.header, https)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Grade %s", grade(findings))
+	for _, f := range findings {
+		mark := "✓"
+		switch f.score {
+		case 0:
+			mark = "✗"
+		case 1:
+			mark = "!"
+		}
+		fmt.Fprintf(&b, "\n%s %-26s %s", mark, f.header, f.note)
+	}
+	return reportMsg{title: "Security headers", body: b.String()}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAuditScores(t *testing.T) {
+	tests := []struct {
+		name string
+		f    finding
+		want int
+	}{
+		{"hsts over http", auditHSTS("max-age=63072000", false), 0},
+		{"hsts missing", auditHSTS("", true), 0},
+		{"hsts without max-age", auditHSTS("includeSubDomains", true), 1},
+		{"hsts short", auditHSTS("max-age=3600", true), 1},
+		{"hsts long", auditHSTS(`max-age="63072000"; includeSubDomains`, true), 2},
+		{"csp missing", auditCSP(""), 0},
+		{"csp unsafe", auditCSP("script-src 'self' 'unsafe-inline'"), 1},
+		{"csp strict", auditCSP("default-src 'self'"), 2},
+		{"frame deny", auditFrameOptions("deny", ""), 2},
+		{"frame by csp", auditFrameOptions("", "frame-ancestors 'none'"), 2},
+		{"frame allow-from", auditFrameOptions("ALLOW-FROM https://a.test", ""), 1},
+		{"frame missing", auditFrameOptions("", ""), 0},
+		{"nosniff", auditContentTypeOptions(" NoSniff "), 2},
+		{"sniff other", auditContentTypeOptions("yes"), 1},
+		{"referrer strict", auditReferrerPolicy("no-referrer"), 2},
+		{"referrer last wins", auditReferrerPolicy("no-referrer, unsafe-url"), 1},
+		{"referrer missing", auditReferrerPolicy(""), 0},
+	}
+	for _, tt := range tests {
+		if tt.f.score != tt.want {
+			t.Errorf("%s: score %d (%s), want %d", tt.name, tt.f.score, tt.f.note, tt.want)
+		}
+	}
+}
+
+func TestGrade(t *testing.T) {
+	all := func(score int) []finding {
+		return []finding{{score: score}, {score: score}, {score: score}, {score: score}, {score: score}}
+	}
+	if g := grade(all(2)); g != "A" {
+		t.Errorf("all good = %s, want A", g)
+	}
+	if g := grade(all(1)); g != "C" {
+		t.Errorf("all weak = %s, want C", g)
+	}
+	if g := grade(all(0)); g != "F" {
+		t.Errorf("all missing = %s, want F", g)
+	}
+}
+
+func TestSecurityAudit(t *testing.T) {
+	h := http.Header{
+		"Strict-Transport-Security": {"max-age=63072000"},
+		"Content-Security-Policy":   {"default-src 'self'"},
+		"X-Content-Type-Options":    {"nosniff"},
+	}
+	r := securityAudit(response{header: h}, true)
+	if !strings.HasPrefix(r.body, "Grade C") {
+		t.Errorf("report starts %q, want Grade C", strings.SplitN(r.body, "\n", 2)[0])
+	}
+	if !strings.Contains(r.body, "✗ X-Frame-Options") || !strings.Contains(r.body, "✗ Referrer-Policy") {
+		t.Errorf("report =\n%s", r.body)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// auditEntry is one request sent, as kept in the audit log: who sent what
+// where, and what came of it. Unlike the history, which is the user's to
+// prune, the log is only ever appended to, and it keeps no bodies unless
+// -audit-bodies asks for the request's.
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user"`
+	Host   string    `json:"host"` // The machine it was sent from.
+	Method string    `json:"method"`
+	URL    string    `json:"url"`
+	Env    string    `json:"env,omitempty"`
+	ID     string    `json:"request_id,omitempty"`
+	Status int       `json:"status,omitempty"` // 0 when the request failed.
+	Error  string    `json:"error,omitempty"`
+	Body   string    `json:"body,omitempty"`
+}
+
+// defaultAuditFile is where the audit log is kept unless -audit-log says
+// otherwise.
+func defaultAuditFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "httpwizard", "audit.jsonl")
+}
+
+// auditUser names who is sending, as the system knows them.
+func auditUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// recordAudit appends a request, and what came of it, to the audit log, if
+// there is one. msg is what send returned for it.
+func recordAudit(cfg config, msg any) error {
+	if cfg.auditFile == "" {
+		return nil
+	}
+	host, _ := os.Hostname()
+	e := auditEntry{Time: time.Now().UTC(), User: auditUser(), Host: host, Method: cfg.method, URL: cfg.url, Env: cfg.env, ID: cfg.header.Get("X-Request-ID")}
+	switch msg := msg.(type) {
+	case responseMsg:
+		e.Status = msg.status
+	case errMsg:
+		e.Error = msg.err.Error()
+	}
+	if cfg.auditBodies {
+		e.Body = string(cfg.body)
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// What was sent where, and by whom, is only the user's to read.
+	if err := os.MkdirAll(filepath.Dir(cfg.auditFile), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(cfg.auditFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readAudit returns the entries of the audit log at path, oldest first,
+// skipping lines that don't parse.
+func readAudit(path string) ([]auditEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []auditEntry
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 16<<20)
+	for s.Scan() {
+		var e auditEntry
+		if json.Unmarshal(s.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, s.Err()
+}
+
+// runAuditExport implements `audit-export [-since DURATION] [-env NAME]
+// [-format csv|json] [-o FILE]`: it writes the audit log, or the part of it
+// asked for, for a compliance record.
+func runAuditExport(args []string) error {
+	fs := flag.NewFlagSet("audit-export", flag.ExitOnError)
+	path := fs.String("audit-log", defaultAuditFile(), "the audit log `file` to export")
+	since := fs.Duration("since", 0, "export only the requests sent in this last `duration`, e.g. 720h")
+	env := fs.String("env", "", "export only the requests sent to this `environment`")
+	format := fs.String("format", "csv", "write the log as `format` csv or json")
+	output := fs.String("o", "-", "write the export to this `file`, - for standard output")
+	fs.Parse(args)
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("unknown -format %q; use csv or json", *format)
+	}
+
+	entries, err := readAudit(*path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("there is no audit log at %s yet", *path)
+	}
+	if err != nil {
+		return err
+	}
+	var picked []auditEntry
+	for _, e := range entries {
+		if (*since == 0 || time.Since(e.Time) <= *since) && (*env == "" || e.Env == *env) {
+			picked = append(picked, e)
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "json" {
+		return writeAuditJSON(w, picked)
+	}
+	return writeAuditCSV(w, picked)
+}
+
+// writeAuditJSON writes entries as one JSON array.
+func writeAuditJSON(w io.Writer, entries []auditEntry) error {
+	if entries == nil {
+		entries = []auditEntry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+// writeAuditCSV writes entries as CSV, with a header row.
+func writeAuditCSV(w io.Writer, entries []auditEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "user", "host", "method", "url", "env", "request_id", "status", "error", "body"})
+	for _, e := range entries {
+		status := ""
+		if e.Status > 0 {
+			status = strconv.Itoa(e.Status)
+		}
+		cw.Write([]string{e.Time.Format(time.RFC3339), e.User, e.Host, e.Method, e.URL, e.Env, e.ID, status, e.Error, e.Body})
+	}
+	cw.Flush()
+	return cw.Error()
+}
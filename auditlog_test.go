@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+	file := filepath.Join(t.TempDir(), "httpwizard", "audit.jsonl")
+	send(config{method: "POST", url: srv.URL + "/orders", env: "prod", body: []byte("secret"), auditFile: file})
+	send(config{method: "GET", url: "http://127.0.0.1:1/", auditFile: file, auditBodies: true, body: []byte("kept")})
+
+	entries, err := readAudit(file)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("readAudit = %+v, %v", entries, err)
+	}
+	if e := entries[0]; e.Method != "POST" || e.URL != srv.URL+"/orders" || e.Env != "prod" || e.Status != 202 || e.Body != "" || e.User == "" {
+		t.Errorf("first entry = %+v", e)
+	}
+	if e := entries[1]; e.Status != 0 || e.Error == "" || e.Body != "kept" {
+		t.Errorf("second entry = %+v", e)
+	}
+	for path, want := range map[string]os.FileMode{file: 0o600, filepath.Dir(file): 0o700} {
+		if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != want {
+			t.Errorf("%s: mode %v, %v", path, fi.Mode(), err)
+		}
+	}
+}
+
+func TestAuditExport(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "audit.jsonl")
+	old := time.Now().Add(-48 * time.Hour).UTC().Format(time.RFC3339)
+	now := time.Now().UTC().Format(time.RFC3339)
+	os.WriteFile(log, []byte(`{"time":"`+old+`","user":"ann","method":"DELETE","url":"https://a/1","env":"prod","status":204}
+{"time":"`+now+`","user":"bob","method":"POST","url":"https://a/2","env":"prod","error":"refused"}
+{"time":"`+now+`","user":"bob","method":"GET","url":"https://b/","env":"dev","status":200}
+`), 0o644)
+
+	out := filepath.Join(dir, "out.csv")
+	if err := runAuditExport([]string{"-audit-log", log, "-since", "24h", "-env", "prod", "-o", out}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(out)
+	want := "time,user,host,method,url,env,request_id,status,error,body\n" + now + ",bob,,POST,https://a/2,prod,,,refused,\n"
+	if string(got) != want {
+		t.Errorf("CSV export:\n%s\nwant:\n%s", got, want)
+	}
+
+	out = filepath.Join(dir, "out.json")
+	if err := runAuditExport([]string{"-audit-log", log, "-format", "json", "-o", out}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(out); strings.Count(string(got), `"method"`) != 3 {
+		t.Errorf("JSON export:\n%s", got)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"  // Register GIF so image.DecodeConfig can size it.
+	_ "image/jpeg" // Register JPEG so image.DecodeConfig can size it.
+	_ "image/png"  // Register PNG so image.DecodeConfig can size it.
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// bodyKind says which renderer a body is shown with.
+type bodyKind string
+
+const (
+	kindEmpty  bodyKind = "empty"
+	kindJSON   bodyKind = "json"
+	kindHTML   bodyKind = "html"
+	kindXML    bodyKind = "xml"
+	kindText   bodyKind = "text"
+	kindImage  bodyKind = "image"
+	kindBinary bodyKind = "binary"
+)
+
+// previewLines is how many lines of a rendered body the main view shows at once.
+const previewLines = 20
+
+// hexPreview is how many bytes of a binary body are hex-dumped.
+const hexPreview = 256
+
+// kindOf maps a media type, such as "application/json", to a renderer.
+func kindOf(mediaType string) bodyKind {
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return kindJSON
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return kindHTML
+	case mediaType == "text/xml" || mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return kindXML
+	case strings.HasPrefix(mediaType, "text/"), mediaType == "application/javascript", ndjsonTypes[mediaType]:
+		return kindText
+	case strings.HasPrefix(mediaType, "image/"):
+		return kindImage
+	default:
+		return kindBinary
+	}
+}
+
+// sniff guesses the media type of body from its content alone.
+// http.DetectContentType doesn't know JSON, so that is checked first.
+func sniff(body []byte) string {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return "application/json"
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(body))
+	return mediaType
+}
+
+// contentKind picks the renderer for a body served with headers h. The
+// content wins over the Content-Type header when they disagree, in which
+// case a warning explaining the mismatch is returned as well.
+func contentKind(h http.Header, body []byte) (bodyKind, string) {
+	if len(body) == 0 {
+		return kindEmpty, ""
+	}
+
+	sniffed := sniff(body)
+	declared, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil || declared == "application/octet-stream" {
+		// Nothing (useful) was declared, so there is nothing to contradict.
+		return kindOf(sniffed), ""
+	}
+
+	want, got := kindOf(declared), kindOf(sniffed)
+	switch {
+	// Plain text is what the sniffer says for anything textual it can't
+	// place, so it never contradicts a more specific textual type.
+	case want == got, got == kindText && want != kindBinary && want != kindImage:
+		return want, ""
+	// Sniffing is unreliable for unusual binary formats; trust the server.
+	case got == kindBinary:
+		return want, ""
+	}
+	return got, fmt.Sprintf("declared %s but the body looks like %s", declared, sniffed)
+}
+
+// jsonBody returns the body as JSON for the tree and table views: the
+// JSON a protobuf, MessagePack or CBOR body was decoded to, or else the body itself.
+func (r response) jsonBody() []byte {
+	if r.asJSON != nil {
+		return r.asJSON
+	}
+	return r.body
+}
+
+// renderBody renders body with the renderer for kind.
+func renderBody(kind bodyKind, body []byte) string {
+	switch kind {
+	case kindEmpty:
+		return "(no body)"
+	case kindJSON:
+		var b bytes.Buffer
+		if json.Indent(&b, bytes.TrimSpace(body), "", "  ") == nil {
+			return b.String()
+		}
+		return string(body)
+	case kindImage:
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(body))
+		if err != nil {
+			return fmt.Sprintf("(%d bytes of image data)", len(body))
+		}
+		return fmt.Sprintf("(%s image, %d×%d, %d bytes)", strings.ToUpper(format), cfg.Width, cfg.Height, len(body))
+	case kindBinary:
+		return strings.TrimRight(hex.Dump(body[:min(len(body), hexPreview)]), "\n")
+	default:
+		return string(body)
+	}
+}
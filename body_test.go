@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	for mediaType, want := range map[string]bodyKind{
+		"application/json":         kindJSON,
+		"application/problem+json": kindJSON,
+		"text/html":                kindHTML,
+		"application/atom+xml":     kindXML,
+		"text/csv":                 kindText,
+		"application/x-ndjson":     kindText,
+		"image/webp":               kindImage,
+		"application/pdf":          kindBinary,
+	} {
+		if got := kindOf(mediaType); got != want {
+			t.Errorf("kindOf(%q) = %s, want %s", mediaType, got, want)
+		}
+	}
+}
+
+func TestContentKind(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        bodyKind
+		warn        bool
+	}{
+		{"empty", "application/json", "", kindEmpty, false},
+		{"json as json", "application/json", `{"a":1}`, kindJSON, false},
+		{"json as html", "text/html", ` [1, 2] `, kindJSON, true},
+		{"html as json", "application/json", "<!DOCTYPE html><html></html>", kindHTML, true},
+		{"no type", "", `{"a":1}`, kindJSON, false},
+		{"octet-stream", "application/octet-stream", "<html><body>x</body></html>", kindHTML, false},
+		{"csv is text", "text/csv", "a,b\n1,2\n", kindText, false},
+		{"yaml as json", "application/json", "a: 1\n", kindJSON, false},
+		{"unusual binary", "application/x-custom", "\x00\x01\x02", kindBinary, false},
+	}
+	for _, tt := range tests {
+		kind, warning := contentKind(http.Header{"Content-Type": {tt.contentType}}, []byte(tt.body))
+		if kind != tt.want || (warning != "") != tt.warn {
+			t.Errorf("%s: contentKind = %s, %q, want %s (warning %v)", tt.name, kind, warning, tt.want, tt.warn)
+		}
+	}
+}
+
+func TestRenderBody(t *testing.T) {
+	if got := renderBody(kindJSON, []byte(` {"a":[1]} `)); got != "{\n  \"a\": [\n    1\n  ]\n}" {
+		t.Errorf("json = %q", got)
+	}
+	if got := renderBody(kindJSON, []byte(`{bad`)); got != "{bad" {
+		t.Errorf("bad json = %q", got)
+	}
+	if got := renderBody(kindEmpty, nil); got != "(no body)" {
+		t.Errorf("empty = %q", got)
+	}
+
+	var img bytes.Buffer
+	png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 3, 2)))
+	if got := renderBody(kindImage, img.Bytes()); !strings.HasPrefix(got, "(PNG image, 3×2, ") {
+		t.Errorf("image = %q", got)
+	}
+
+	dump := renderBody(kindBinary, bytes.Repeat([]byte{0xff}, 1000))
+	if lines := strings.Count(dump, "\n") + 1; lines != hexPreview/16 {
+		t.Errorf("hex dump has %d lines, want %d", lines, hexPreview/16)
+	}
+}
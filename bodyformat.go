@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// bodyFormat is a binary encoding of JSON-like data. Bodies in it are shown
+// as JSON, and with -body-format, JSON request bodies are sent in it.
+type bodyFormat struct {
+	title      string   // Its name in prose.
+	mediaTypes []string // What it is served as; the first is what we send.
+	decode     func([]byte) (any, error)
+	encode     func(any) ([]byte, error)
+}
+
+// bodyFormats are the formats -body-format can name, besides json.
+var bodyFormats = map[string]bodyFormat{
+	"msgpack": {
+		title:      "MessagePack",
+		mediaTypes: []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"},
+		decode: func(b []byte) (any, error) {
+			// Maps may have keys of any type, so don't insist on strings.
+			d := msgpack.NewDecoder(bytes.NewReader(b))
+			d.SetMapDecoder(func(d *msgpack.Decoder) (any, error) { return d.DecodeUntypedMap() })
+			var v any
+			err := d.Decode(&v)
+			return v, err
+		},
+		encode: func(v any) ([]byte, error) {
+			var b bytes.Buffer
+			e := msgpack.NewEncoder(&b)
+			e.UseCompactInts(true)
+			e.UseCompactFloats(true)
+			err := e.Encode(v)
+			return b.Bytes(), err
+		},
+	},
+	"cbor": {
+		title:      "CBOR",
+		mediaTypes: []string{"application/cbor"},
+		decode: func(b []byte) (any, error) {
+			var v any
+			err := cbor.Unmarshal(b, &v)
+			return v, err
+		},
+		encode: cbor.Marshal,
+	},
+}
+
+// bodyFormatNames lists the formats -body-format accepts, for its help.
+func bodyFormatNames() string {
+	names := []string{"json"}
+	for name := range bodyFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return strings.Join(names, ", ")
+}
+
+// formatOf returns the format of a body served as contentType. A body
+// declared as nothing in particular is taken to be in the -body-format the
+// request was sent in.
+func formatOf(contentType, fallback string) (bodyFormat, bool) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, f := range bodyFormats {
+		for _, t := range f.mediaTypes {
+			if mediaType == t {
+				return f, true
+			}
+		}
+	}
+	if strings.HasSuffix(mediaType, "+cbor") {
+		return bodyFormats["cbor"], true
+	}
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		f, ok := bodyFormats[fallback]
+		return f, ok
+	}
+	return bodyFormat{}, false
+}
+
+// toJSON decodes body and re-encodes it as JSON.
+func (f bodyFormat) toJSON(body []byte) ([]byte, error) {
+	v, err := f.decode(body)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", f.title, err)
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(jsonable(v)); err != nil {
+		return nil, fmt.Errorf("%s that JSON can't hold: %w", f.title, err)
+	}
+	return bytes.TrimSpace(b.Bytes()), nil
+}
+
+// fromJSON encodes a JSON body in the format.
+func (f bodyFormat) fromJSON(body []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("the body isn't JSON, so it can't be sent as %s: %w", f.title, err)
+	}
+	return f.encode(numbers(v))
+}
+
+// jsonable returns v with what JSON can't represent turned into what it
+// can: map keys of other types become strings, and CBOR tags objects.
+func jsonable(v any) any {
+	switch v := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			s, ok := key.(string)
+			if !ok {
+				s = fmt.Sprint(key)
+			}
+			m[s] = jsonable(value)
+		}
+		return m
+	case map[string]any:
+		for key, value := range v {
+			v[key] = jsonable(value)
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = jsonable(value)
+		}
+		return v
+	case cbor.Tag:
+		return map[string]any{"tag": v.Number, "value": jsonable(v.Content)}
+	}
+	return v
+}
+
+// numbers turns the json.Numbers in v into integers where they are whole
+// numbers and floats otherwise, so they are encoded compactly.
+func numbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, value := range v {
+			v[key] = numbers(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = numbers(value)
+		}
+	}
+	return v
+}
+
+// encodeBody returns cfg with its body encoded the way the server takes
+// it: in a SOAP envelope, or from JSON to -proto-request's message type or
+// the -body-format.
+func encodeBody(cfg config) (config, error) {
+	if cfg.soapVersion != "" {
+		return wrapSOAP(cfg), nil
+	}
+	if cfg.protoRequest != "" {
+		return encodeProtoRequest(cfg)
+	}
+	f, ok := bodyFormats[cfg.bodyFormat]
+	if !ok || cfg.body == nil {
+		return cfg, nil
+	}
+	body, err := f.fromJSON(cfg.body)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.body = body
+	if cfg.header.Get("Content-Type") == "" {
+		cfg.header = cfg.header.Clone()
+		cfg.header.Set("Content-Type", f.mediaTypes[0])
+	}
+	return cfg, nil
+}
+
+// decodeBody returns a protobuf, MessagePack or CBOR body as JSON, with a
+// note on what it was decoded from, or why it couldn't be. Other bodies
+// come back as they are, with no note.
+func decodeBody(cfg config, h http.Header, body []byte) ([]byte, string) {
+	if len(body) == 0 {
+		return nil, ""
+	}
+	if name, ok := responseMessage(cfg, h); ok {
+		if name == "" {
+			return nil, "protobuf, not decoded; give its message type with -proto-response"
+		}
+		out, err := cfg.proto.toJSON(name, body)
+		if err != nil {
+			return nil, "protobuf, not decoded: " + err.Error()
+		}
+		return out, "protobuf, decoded as " + name
+	}
+	if f, ok := formatOf(h.Get("Content-Type"), cfg.bodyFormat); ok {
+		out, err := f.toJSON(body)
+		if err != nil {
+			return nil, f.title + ", not decoded: " + err.Error()
+		}
+		return out, f.title + ", shown as JSON"
+	}
+	return nil, ""
+}
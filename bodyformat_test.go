@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestBodyFormatRoundTrip(t *testing.T) {
+	in := `{"big":9007199254740993,"list":[1,2.5,"<x>",null,true],"name":"Ann"}`
+	for _, name := range []string{"msgpack", "cbor"} {
+		f := bodyFormats[name]
+		wire, err := f.fromJSON([]byte(in))
+		if err != nil {
+			t.Fatalf("%s fromJSON: %v", name, err)
+		}
+		out, err := f.toJSON(wire)
+		if err != nil {
+			t.Fatalf("%s toJSON: %v", name, err)
+		}
+		if string(out) != in {
+			t.Errorf("%s round trip = %s, want %s", name, out, in)
+		}
+	}
+}
+
+func TestBodyFormatCompactNumbers(t *testing.T) {
+	wire, err := bodyFormats["msgpack"].fromJSON([]byte(`[1, 2.0, 2.5]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fixarray of 3, fixints 1 and 2, and 2.5 as a float64.
+	if want := []byte{0x93, 0x01, 0x02, 0xcb, 0x40, 0x04, 0, 0, 0, 0, 0, 0}; string(wire) != string(want) {
+		t.Errorf("msgpack = % x, want % x", wire, want)
+	}
+}
+
+func TestBodyFormatKeysAndTags(t *testing.T) {
+	wire, _ := msgpack.Marshal(map[int]string{7: "seven"})
+	out, err := bodyFormats["msgpack"].toJSON(wire)
+	if err != nil || string(out) != `{"7":"seven"}` {
+		t.Errorf("msgpack int keys = %s, %v", out, err)
+	}
+
+	wire, _ = cbor.Marshal(cbor.Tag{Number: 32, Content: "https://a.test/"})
+	out, err = bodyFormats["cbor"].toJSON(wire)
+	if err != nil || string(out) != `{"tag":32,"value":"https://a.test/"}` {
+		t.Errorf("cbor tag = %s, %v", out, err)
+	}
+
+	if _, err := bodyFormats["cbor"].fromJSON([]byte("{oops")); err == nil || !strings.Contains(err.Error(), "isn't JSON") {
+		t.Errorf("bad JSON: err = %v", err)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		contentType, fallback, want string
+	}{
+		{"application/msgpack", "", "MessagePack"},
+		{"application/x-msgpack; charset=binary", "", "MessagePack"},
+		{"application/cbor", "msgpack", "CBOR"},
+		{"application/senml+cbor", "", "CBOR"},
+		{"application/octet-stream", "cbor", "CBOR"},
+		{"", "msgpack", "MessagePack"},
+		{"", "json", ""},
+		{"application/json", "msgpack", ""},
+	}
+	for _, tt := range tests {
+		f, ok := formatOf(tt.contentType, tt.fallback)
+		if f.title != tt.want || ok != (tt.want != "") {
+			t.Errorf("formatOf(%q, %q) = %q, %v, want %q", tt.contentType, tt.fallback, f.title, ok, tt.want)
+		}
+	}
+	if got := bodyFormatNames(); got != "json, cbor, msgpack" {
+		t.Errorf("bodyFormatNames = %q", got)
+	}
+}
+
+func TestEncodeAndDecodeBody(t *testing.T) {
+	cfg := config{bodyFormat: "cbor", header: http.Header{}, body: []byte(`{"a":1}`)}
+	sent, err := encodeBody(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.header.Get("Content-Type") != "application/cbor" || len(cfg.header) != 0 {
+		t.Errorf("Content-Type = %q, original headers %v", sent.header.Get("Content-Type"), cfg.header)
+	}
+
+	out, note := decodeBody(cfg, sent.header, sent.body)
+	if string(out) != `{"a":1}` || note != "CBOR, shown as JSON" {
+		t.Errorf("decodeBody = %s, %q", out, note)
+	}
+	if out, note := decodeBody(cfg, http.Header{"Content-Type": {"application/cbor"}}, []byte{0xff}); out != nil || !strings.HasPrefix(note, "CBOR, not decoded: ") {
+		t.Errorf("broken CBOR = %s, %q", out, note)
+	}
+	if out, note := decodeBody(config{}, http.Header{"Content-Type": {"application/x-protobuf"}}, []byte{1}); out != nil || !strings.Contains(note, "-proto-response") {
+		t.Errorf("unnamed protobuf = %s, %q", out, note)
+	}
+	if out, note := decodeBody(config{}, http.Header{"Content-Type": {"text/plain"}}, []byte("hi")); out != nil || note != "" {
+		t.Errorf("plain text = %s, %q", out, note)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// lineIndex records where each line of a rendered body starts, so that a
+// window of lines can be cut out without splitting the whole body.
+type lineIndex []int
+
+// indexLines finds the start of every line in s.
+func indexLines(s string) lineIndex {
+	ix := lineIndex{0}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			ix = append(ix, i+1)
+		}
+	}
+	return ix
+}
+
+// line returns line i of s, which ix indexes, without its newline.
+func (ix lineIndex) line(s string, i int) string {
+	end := len(s)
+	if i+1 < len(ix) {
+		end = ix[i+1] - 1
+	}
+	return s[ix[i]:end]
+}
+
+// scrollBody moves the body window by delta lines, keeping it on the body.
+func (m model) scrollBody(delta int) model {
+	last := max(len(m.res.lines)-previewLines, 0)
+	m.bodyTop = min(max(m.bodyTop+delta, 0), last)
+	return m
+}
+
+// bodyWindow renders the previewLines lines of the body starting at
+// bodyTop. Only those lines are touched: long ones are cut to the terminal
+// width here, per frame, rather than for the whole body up front.
+func (m model) bodyWindow() string {
+	r := m.res
+	end := min(m.bodyTop+previewLines, len(r.lines))
+
+	var b strings.Builder
+	for i := m.bodyTop; i < end; i++ {
+		line := strings.ReplaceAll(r.lines.line(r.rendered, i), "\t", "    ")
+		if m.width > 0 {
+			line = runewidth.Truncate(line, m.width, "…")
+		}
+		if i > m.bodyTop {
+			b.WriteByte('\n')
+		}
+		b.WriteString(line)
+	}
+	if len(r.lines) > previewLines {
+		fmt.Fprintf(&b, "\n… lines %d–%d of %d (pgup/pgdn scroll)", m.bodyTop+1, end, len(r.lines))
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLineIndex(t *testing.T) {
+	s := "one\n\nthree\nfour"
+	ix := indexLines(s)
+	var got []string
+	for i := range ix {
+		got = append(got, ix.line(s, i))
+	}
+	if strings.Join(got, "|") != "one||three|four" {
+		t.Errorf("lines = %q", got)
+	}
+	if n := len(indexLines("")); n != 1 {
+		t.Errorf("an empty body has %d lines, want 1", n)
+	}
+}
+
+// numbered returns a model whose body has n numbered lines.
+func numbered(n int) model {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i+1)
+	}
+	rendered := strings.Join(lines, "\n")
+	return model{res: response{rendered: rendered, lines: indexLines(rendered)}}
+}
+
+func TestScrollBody(t *testing.T) {
+	m := numbered(50).scrollBody(10)
+	if m.bodyTop != 10 {
+		t.Errorf("bodyTop = %d, want 10", m.bodyTop)
+	}
+	if m = m.scrollBody(100); m.bodyTop != 50-previewLines {
+		t.Errorf("bodyTop = %d, want it to stop at %d", m.bodyTop, 50-previewLines)
+	}
+	if m = m.scrollBody(-100); m.bodyTop != 0 {
+		t.Errorf("bodyTop = %d, want 0", m.bodyTop)
+	}
+	if m = numbered(5).scrollBody(3); m.bodyTop != 0 {
+		t.Errorf("short body scrolled to %d", m.bodyTop)
+	}
+}
+
+func TestBodyWindow(t *testing.T) {
+	m := numbered(50).scrollBody(5)
+	got := strings.Split(m.bodyWindow(), "\n")
+	if len(got) != previewLines+1 || got[0] != "line 6" || got[previewLines-1] != "line 25" {
+		t.Errorf("window = %q", got)
+	}
+	if got[previewLines] != "… lines 6–25 of 50 (pgup/pgdn scroll)" {
+		t.Errorf("footer = %q", got[previewLines])
+	}
+
+	m = model{width: 8, res: response{rendered: "\tabcdefghij"}}
+	m.res.lines = indexLines(m.res.rendered)
+	if got := m.bodyWindow(); got != "    abc…" {
+		t.Errorf("truncated window = %q", got)
+	}
+}
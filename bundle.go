@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// A bundle carries a request from someone who can't send it to someone who
+// can: the on-call engineer with production access, say. It is signed, so
+// that what is sent is what its author wrote, and it leaves out the
+// author's credentials; whoever sends it gives their own with -H.
+
+// secretHeaders are the request headers a bundle never carries.
+var secretHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key", "X-Auth-Token", "X-Csrf-Token"}
+
+// secretWords mark the names of headers and query parameters that hold
+// credentials, e.g. X-Vendor-Token or api_key.
+var secretWords = []string{"token", "secret", "password", "passwd", "apikey", "api_key", "api-key", "signature", "session"}
+
+// isSecret reports whether a header or query parameter named name holds a
+// credential.
+func isSecret(name string) bool {
+	if slices.Contains(secretHeaders, http.CanonicalHeaderKey(name)) {
+		return true
+	}
+	lower := strings.ToLower(name)
+	return slices.ContainsFunc(secretWords, func(w string) bool { return strings.Contains(lower, w) })
+}
+
+// bundledRequest is the request a bundle carries.
+type bundledRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// bundle is a request signed by its author.
+type bundle struct {
+	Request  bundledRequest `json:"request"`
+	Author   string         `json:"author"`
+	Created  time.Time      `json:"created"`
+	Withheld []string       `json:"withheld,omitempty"` // The secret headers and parameters left out.
+
+	PublicKey ed25519.PublicKey `json:"public_key"`
+	Signature []byte            `json:"signature"`
+}
+
+// signed is the part of b its signature covers.
+func (b bundle) signed() []byte {
+	out, _ := json.Marshal(struct {
+		Request  bundledRequest `json:"request"`
+		Author   string         `json:"author"`
+		Created  time.Time      `json:"created"`
+		Withheld []string       `json:"withheld"`
+	}{b.Request, b.Author, b.Created, b.Withheld})
+	return out
+}
+
+// fingerprint identifies the key a bundle was signed with, for the sender
+// to check with its author, e.g. SHA256:f3Vt….
+func fingerprint(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// defaultBundleKey is the key bundles are signed with.
+func defaultBundleKey() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "httpwizard", "bundle_ed25519.pem")
+}
+
+// bundleKey reads the signing key at path, making one the first time.
+func bundleKey(path string) (ed25519.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, err
+		}
+		return key, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s holds no PEM key", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an Ed25519 key", path)
+	}
+	return key, nil
+}
+
+// makeBundle bundles the request described by cfg, without its secrets,
+// signed with key.
+func makeBundle(cfg config, key ed25519.PrivateKey) (bundle, error) {
+	u, err := url.Parse(cfg.url)
+	if err != nil {
+		return bundle{}, err
+	}
+	b := bundle{Author: shareUser(), Created: time.Now().UTC().Truncate(time.Second)}
+	if u.User != nil {
+		u.User = nil
+		b.Withheld = append(b.Withheld, "userinfo")
+	}
+	q := u.Query()
+	for name := range q {
+		if isSecret(name) {
+			q.Del(name)
+			b.Withheld = append(b.Withheld, "?"+name)
+		}
+	}
+	u.RawQuery = q.Encode()
+	b.Request = bundledRequest{Method: cfg.method, URL: u.String(), Headers: http.Header{}, Body: string(cfg.body)}
+	for name, values := range cfg.header {
+		switch {
+		case name == "X-Request-Id": // A request ID is the sender's to make.
+		case isSecret(name):
+			b.Withheld = append(b.Withheld, name)
+		default:
+			b.Request.Headers[name] = values
+		}
+	}
+	slices.Sort(b.Withheld)
+	b.PublicKey = key.Public().(ed25519.PublicKey)
+	b.Signature = ed25519.Sign(key, b.signed())
+	return b, nil
+}
+
+// readBundle reads the bundle at path, and checks that it is signed by the
+// key it carries.
+func readBundle(path string) (bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return bundle{}, err
+	}
+	var b bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return bundle{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(b.PublicKey) != ed25519.PublicKeySize || !ed25519.Verify(b.PublicKey, b.signed(), b.Signature) {
+		return bundle{}, fmt.Errorf("%s: the signature doesn't match; the bundle was changed after it was signed", path)
+	}
+	return b, nil
+}
+
+// apply makes cfg the bundled request. The headers cfg already has, given
+// with -H, go on top, to supply the credentials the bundle withheld.
+func (b bundle) apply(cfg config) (config, error) {
+	target, err := normalizeURL(b.Request.URL)
+	if err != nil {
+		return cfg, err
+	}
+	own := cfg.header
+	cfg.method, cfg.url, cfg.path = b.Request.Method, target, target
+	cfg.header = b.Request.Headers.Clone()
+	if cfg.header == nil {
+		cfg.header = http.Header{}
+	}
+	for name, values := range own {
+		cfg.header[name] = values
+	}
+	cfg.body = nil
+	if b.Request.Body != "" {
+		cfg.body = []byte(b.Request.Body)
+	}
+	cfg.bundle = &b
+	return cfg, nil
+}
+
+// describe sums a bundle up for review before it is sent.
+func (b bundle) describe(cfg config) string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "Bundle from %s, made %s\n", b.Author, b.Created.Local().Format(time.DateTime))
+	fmt.Fprintf(&s, "Signed with %s ✓ (check this with %s)\n", fingerprint(b.PublicKey), b.Author)
+	fmt.Fprintf(&s, "\n%s %s\n", b.Request.Method, b.Request.URL)
+	for _, name := range slices.Sorted(maps.Keys(b.Request.Headers)) {
+		fmt.Fprintf(&s, "%s: %s\n", name, strings.Join(b.Request.Headers[name], ", "))
+	}
+	if b.Request.Body != "" {
+		s.WriteString("\n" + b.Request.Body + "\n")
+	}
+	for _, w := range b.Withheld {
+		mark := "✗ not given; add your own with -H"
+		switch {
+		case strings.HasPrefix(w, "?"):
+			mark = "left out of the URL"
+		case w == "userinfo":
+			mark = "the user and password were left out of the URL"
+		case cfg.header.Get(w) != "":
+			mark = "✓ your own"
+		}
+		fmt.Fprintf(&s, "\nWithheld %s: %s", w, mark)
+	}
+	return strings.TrimRight(s.String(), "\n")
+}
+
+// runBundle is the palette's `bundle [file]`: it writes the request as a
+// signed bundle, without its credentials, for someone else to review and
+// send with theirs.
+func runBundle(m model, args []string) (tea.Model, tea.Cmd) {
+	path := ""
+	if len(args) > 0 {
+		path = args[0]
+	} else if u, err := url.Parse(m.cfg.url); err == nil {
+		path = strings.ToLower(m.cfg.method) + "-" + u.Hostname() + ".bundle.json"
+	}
+	key, err := bundleKey(defaultBundleKey())
+	if err != nil {
+		return m.paletteError(fmt.Errorf("the signing key: %w", err))
+	}
+	b, err := makeBundle(m.cfg, key)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	out, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return m.paletteError(err)
+	}
+	if err := os.WriteFile(path, append(out, '\n'), 0o644); err != nil {
+		return m.paletteError(err)
+	}
+	body := fmt.Sprintf("Wrote %s, signed with %s.\nSend it with: httpwizard -bundle %s -H \"Authorization: …\"", path, fingerprint(b.PublicKey), path)
+	if len(b.Withheld) > 0 {
+		body += "\nWithheld: " + strings.Join(b.Withheld, ", ")
+	}
+	m.report, m.cursor = &reportMsg{title: "Bundle", body: body}, 0
+	return m, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	dir := t.TempDir()
+	key, err := bundleKey(filepath.Join(dir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := bundleKey(filepath.Join(dir, "key.pem")); err != nil || !again.Equal(key) {
+		t.Fatalf("the key read back differs: %v", err)
+	}
+
+	cfg := config{
+		method: "DELETE",
+		url:    "https://ann:pw@api.example.com/users/7?force=1&access_token=abc",
+		header: http.Header{"Authorization": {"Bearer mine"}, "X-Vendor-Token": {"t"}, "Accept": {"application/json"}, "X-Request-Id": {"r1"}},
+		body:   []byte(`{"reason": "gdpr"}`),
+	}
+	b, err := makeBundle(cfg, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Request.URL != "https://api.example.com/users/7?force=1" || len(b.Request.Headers) != 1 ||
+		strings.Join(b.Withheld, ",") != "?access_token,Authorization,X-Vendor-Token,userinfo" {
+		t.Fatalf("bundle = %+v", b)
+	}
+	path := filepath.Join(dir, "req.bundle.json")
+	out, _ := json.Marshal(b)
+	os.WriteFile(path, out, 0o644)
+
+	read, err := readBundle(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := read.apply(config{header: http.Header{"Authorization": {"Bearer theirs"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender.method != "DELETE" || sender.url != b.Request.URL || sender.header.Get("Authorization") != "Bearer theirs" ||
+		sender.header.Get("Accept") != "application/json" || string(sender.body) != `{"reason": "gdpr"}` || sender.bundle == nil {
+		t.Errorf("sender's request = %+v", sender)
+	}
+	d := read.describe(sender)
+	for _, want := range []string{"Signed with " + fingerprint(b.PublicKey), "Withheld Authorization: ✓ your own", "Withheld X-Vendor-Token: ✗ not given", "Withheld ?access_token: left out of the URL", "Withheld userinfo: the user and password"} {
+		if !strings.Contains(d, want) {
+			t.Errorf("describe lacks %q:\n%s", want, d)
+		}
+	}
+
+	// Changing a signed bundle breaks its signature.
+	b.Request.Method = "POST"
+	out, _ = json.Marshal(b)
+	os.WriteFile(path, out, 0o644)
+	if _, err := readBundle(path); err == nil || !strings.Contains(err.Error(), "signature doesn't match") {
+		t.Errorf("a tampered bundle read as %v", err)
+	}
+}
+
+func TestIsSecret(t *testing.T) {
+	for name, want := range map[string]bool{"authorization": true, "X-API-KEY": true, "api_key": true, "X-Session-Id": true, "Accept": false, "page": false} {
+		if isSecret(name) != want {
+			t.Errorf("isSecret(%q) = %t", name, !want)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The cache headers of an answer say where it may be kept and for how
+// long, but in a dialect spread over Cache-Control, Expires, Age, ETag,
+// Last-Modified and Vary, whose directives may quietly overrule each
+// other. The cache explainer reads them the way RFC 9111 has caches do,
+// and says in plain words what they add up to.
+
+// cacheableStatuses are the statuses a cache may keep without being told
+// to, with a lifetime it works out itself; RFC 9110 §15.1.
+var cacheableStatuses = []int{200, 203, 204, 206, 300, 301, 308, 404, 405, 410, 414, 501}
+
+// cacheDirectives is a parsed Cache-Control header: each directive, by
+// lower-case name, with its value, "" for one without.
+type cacheDirectives map[string]string
+
+// parseCacheControl parses every Cache-Control line of h, and lists what
+// it finds wrong with them: repeated directives and values that aren't
+// numbers.
+func parseCacheControl(h http.Header) (cacheDirectives, []string) {
+	d := cacheDirectives{}
+	var problems []string
+	for _, line := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(line, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+			if old, ok := d[name]; ok && old != value {
+				problems = append(problems, fmt.Sprintf("%s is given twice, as %q and %q; caches may take either", name, old, value))
+			}
+			d[name] = value
+		}
+	}
+	for _, name := range []string{"max-age", "s-maxage", "stale-while-revalidate", "stale-if-error"} {
+		if v, ok := d[name]; ok {
+			if n, err := strconv.ParseInt(v, 10, 64); err != nil || n < 0 {
+				problems = append(problems, fmt.Sprintf("%s=%s isn't a number of seconds, so caches ignore it", name, v))
+				delete(d, name)
+			}
+		}
+	}
+	return d, problems
+}
+
+// seconds returns the value of the directive name, and whether it is set.
+func (d cacheDirectives) seconds(name string) (int64, bool) {
+	v, ok := d[name]
+	if !ok {
+		return 0, false
+	}
+	n, _ := strconv.ParseInt(v, 10, 64)
+	return n, true
+}
+
+// has reports whether the directive name is set.
+func (d cacheDirectives) has(name string) bool {
+	_, ok := d[name]
+	return ok
+}
+
+// runCache is the palette's `cache`: it explains how the answer may be
+// cached.
+func runCache(m model, _ []string) (tea.Model, tea.Cmd) {
+	if m.res.status == 0 {
+		return m.paletteError(errors.New("there is no answer to explain yet"))
+	}
+	authorized := m.cfg.header.Get("Authorization") != ""
+	m.report, m.cursor = &reportMsg{title: "Caching: " + m.cfg.method + " " + m.cfg.path, body: explainCaching(m.res.status, m.res.header, authorized, time.Now())}, 0
+	return m, nil
+}
+
+// explainCaching says where and how long an answer with status and h may
+// be cached, as of now, and what in its headers contradicts itself.
+// authorized says the request carried credentials, which keep an answer
+// out of shared caches unless it says otherwise.
+func explainCaching(status int, h http.Header, authorized bool, now time.Time) string {
+	d, problems := parseCacheControl(h)
+	var b strings.Builder
+	line := func(label, format string, args ...any) {
+		fmt.Fprintf(&b, "%-11s %s\n", label+":", fmt.Sprintf(format, args...))
+	}
+
+	// Where.
+	shared := !d.has("private") && (!authorized || d.has("public") || d.has("s-maxage") || d.has("must-revalidate"))
+	switch {
+	case d.has("no-store"):
+		line("Where", "nowhere: no cache may keep it, not even the browser")
+	case shared:
+		line("Where", "in the browser, and in shared caches such as CDNs and proxies")
+	case d.has("private"):
+		line("Where", "in the browser only; private keeps it out of CDNs and proxies")
+	default:
+		line("Where", "in the browser only; the request carried credentials, and nothing makes it public")
+	}
+
+	// How long.
+	age := int64(0)
+	if v := h.Get("Age"); v != "" {
+		age, _ = strconv.ParseInt(v, 10, 64)
+	}
+	date, dateErr := http.ParseTime(h.Get("Date"))
+	if dateErr != nil {
+		date = now
+	}
+	lifetime, from := int64(-1), ""
+	if maxAge, ok := d.seconds("max-age"); ok {
+		lifetime, from = maxAge, "max-age"
+	}
+	if v := h.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		switch {
+		case err != nil:
+			if lifetime < 0 {
+				lifetime, from = 0, "Expires"
+			}
+			problems = append(problems, fmt.Sprintf("Expires: %s isn't a date; caches take that as expired, unless max-age says otherwise", v))
+		case lifetime >= 0:
+			if gap := expires.Sub(date) - time.Duration(lifetime)*time.Second; gap > time.Minute || gap < -time.Minute {
+				problems = append(problems, fmt.Sprintf("Expires says %s but max-age %s; max-age wins, but HTTP/1.0 caches go by Expires", forSeconds(int64(expires.Sub(date).Seconds())), forSeconds(lifetime)))
+			}
+		default:
+			lifetime, from = max(int64(expires.Sub(date).Seconds()), 0), "Expires"
+		}
+	}
+	switch {
+	case d.has("no-store"):
+	case d.has("no-cache"):
+		line("How long", "it may be kept, but has to be checked with the server before every use (no-cache)")
+	case lifetime >= 0:
+		if s, ok := d.seconds("s-maxage"); ok && shared {
+			line("How long", "%s in the browser (%s), %s in shared caches (s-maxage)", forSeconds(lifetime), from, forSeconds(s))
+		} else {
+			line("How long", "%s (%s)", forSeconds(lifetime), from)
+		}
+	default:
+		if s, ok := d.seconds("s-maxage"); ok && shared {
+			line("How long", "%s in shared caches (s-maxage); the browser works out its own", forSeconds(s))
+			break
+		}
+		modified, err := http.ParseTime(h.Get("Last-Modified"))
+		switch {
+		case !slices.Contains(cacheableStatuses, status) && !d.has("public"):
+			line("How long", "not at all: caches don't keep a %d without being told how long", status)
+		case err == nil && modified.Before(date):
+			// RFC 9111 §4.2.2 suggests a tenth of the time since it last changed.
+			lifetime, from = int64(date.Sub(modified).Seconds())/10, "a guess"
+			line("How long", "about %s: with no lifetime given, caches guess a tenth of the time since Last-Modified", forSeconds(lifetime))
+		default:
+			line("How long", "as long as each cache sees fit: it gives no lifetime, nor a Last-Modified to guess one from")
+		}
+	}
+	if age > 0 {
+		left := "it is stale already"
+		if lifetime > age && !d.has("no-cache") {
+			left = forSeconds(lifetime-age) + " of freshness left"
+		}
+		line("Age", "a cache has held it for %s; %s", forSeconds(age), left)
+	}
+
+	// Once stale.
+	var stale []string
+	if d.has("must-revalidate") {
+		stale = append(stale, "it must not be used again until the server says it is still good (must-revalidate)")
+	} else if d.has("proxy-revalidate") {
+		stale = append(stale, "shared caches must check with the server before using it (proxy-revalidate)")
+	}
+	if s, ok := d.seconds("stale-while-revalidate"); ok {
+		stale = append(stale, fmt.Sprintf("it may be served for %s more while a fresh copy is fetched", forSeconds(s)))
+	}
+	if s, ok := d.seconds("stale-if-error"); ok {
+		stale = append(stale, fmt.Sprintf("it may stand in for %s if the server fails", forSeconds(s)))
+	}
+	if d.has("immutable") {
+		stale = append(stale, "while fresh, it isn't rechecked even on reload (immutable)")
+	}
+	if len(stale) > 0 {
+		line("Then", "%s", strings.Join(stale, "; "))
+	}
+
+	// Revalidating.
+	etag, modified := h.Get("ETag"), h.Get("Last-Modified")
+	switch {
+	case etag != "" && strings.HasPrefix(etag, "W/"):
+		line("Checking", "with If-None-Match: %s, a weak ETag; the copy is equivalent, not byte for byte the same", etag)
+	case etag != "":
+		line("Checking", "with If-None-Match: %s", etag)
+	case modified != "":
+		line("Checking", "with If-Modified-Since: %s, to the second", modified)
+	case !d.has("no-store"):
+		line("Checking", "it can't be: with no ETag or Last-Modified, a stale copy is downloaded again whole")
+	}
+
+	// Keyed by.
+	if vary := headerList(h.Values("Vary")); len(vary) > 0 {
+		switch {
+		case slices.Contains(vary, "*"):
+			line("Kept per", "every request: Vary: * means no stored copy ever matches another request")
+		default:
+			line("Kept per", "value of %s", strings.Join(vary, ", "))
+			for _, v := range vary {
+				if strings.EqualFold(v, "Cookie") || strings.EqualFold(v, "User-Agent") || strings.EqualFold(v, "Authorization") {
+					problems = append(problems, fmt.Sprintf("Vary: %s keeps a copy per %s, which few requests share; most caches will hardly ever hit", v, strings.ToLower(v)))
+				}
+			}
+		}
+	}
+
+	// What contradicts what.
+	if d.has("no-store") {
+		for _, other := range []string{"max-age", "s-maxage", "public", "immutable", "stale-while-revalidate", "stale-if-error"} {
+			if d.has(other) {
+				problems = append(problems, "no-store forbids keeping it at all, so "+other+" means nothing")
+			}
+		}
+	}
+	if d.has("public") && d.has("private") {
+		problems = append(problems, "public and private both; private wins, and shared caches won't keep it")
+	}
+	if d.has("no-cache") && d.has("immutable") {
+		problems = append(problems, "no-cache checks every use, so immutable means nothing")
+	}
+	if strings.Contains(strings.ToLower(h.Get("Pragma")), "no-cache") && lifetime > 0 && !d.has("no-cache") {
+		problems = append(problems, "Pragma: no-cache says not to cache it, but Cache-Control gives it a lifetime; HTTP/1.1 caches go by Cache-Control")
+	}
+	if h.Get("Set-Cookie") != "" && shared && !d.has("no-store") && !d.has("no-cache") {
+		problems = append(problems, "it sets a cookie but shared caches may keep it, and hand the same cookie to every visitor")
+	}
+
+	if len(problems) == 0 {
+		return b.String() + "\n✓ The headers agree with each other"
+	}
+	b.WriteString("\n")
+	for _, p := range problems {
+		b.WriteString("⚠ " + p + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// headerList splits the comma-separated values of a list header.
+func headerList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+// forSeconds writes n seconds the short way, e.g. "1h30m", "0s" or, from
+// two days on, "365 days".
+func forSeconds(n int64) string {
+	d := time.Duration(n) * time.Second
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%d days", n/(24*60*60))
+	}
+	s := d.String()
+	// Duration writes 1h0m0s; drop the zero minutes and seconds.
+	for _, unit := range []string{"m0s", "h0m"} {
+		if strings.HasSuffix(s, unit) {
+			s = s[:len(s)-2]
+		}
+	}
+	return s
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplainCaching(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	date := now.Format(http.TimeFormat)
+	for _, tc := range []struct {
+		name       string
+		status     int
+		header     http.Header
+		authorized bool
+		want       []string
+	}{
+		{
+			name:   "static asset",
+			status: 200,
+			header: http.Header{"Cache-Control": {"public, max-age=31536000, immutable"}, "Etag": {`"abc"`}, "Date": {date}, "Age": {"3600"}},
+			want: []string{
+				"Where:      in the browser, and in shared caches",
+				"How long:   365 days (max-age)",
+				"Age:        a cache has held it for 1h; 364 days of freshness left",
+				"Then:       while fresh, it isn't rechecked even on reload (immutable)",
+				`Checking:   with If-None-Match: "abc"`,
+				"✓ The headers agree",
+			},
+		},
+		{
+			name:   "contradictions",
+			status: 200,
+			header: http.Header{"Cache-Control": {"no-store, max-age=60", "public, private"}, "Pragma": {"no-cache"}},
+			want: []string{
+				"Where:      nowhere",
+				"⚠ no-store forbids keeping it at all, so max-age means nothing",
+				"⚠ public and private both; private wins",
+			},
+		},
+		{
+			name:       "credentials",
+			status:     200,
+			header:     http.Header{"Cache-Control": {"max-age=60, s-maxage=600, stale-while-revalidate=30"}, "Vary": {"Accept-Encoding, Cookie"}},
+			authorized: true,
+			want: []string{
+				"How long:   1m in the browser (max-age), 10m in shared caches (s-maxage)",
+				"it may be served for 30s more while a fresh copy is fetched",
+				"Checking:   it can't be",
+				"Kept per:   value of Accept-Encoding, Cookie",
+				"⚠ Vary: Cookie keeps a copy per cookie",
+			},
+		},
+		{
+			name:       "private by credentials",
+			status:     200,
+			header:     http.Header{"Cache-Control": {"max-age=abc"}, "Expires": {now.Add(2 * time.Hour).Format(http.TimeFormat)}, "Date": {date}},
+			authorized: true,
+			want: []string{
+				"Where:      in the browser only; the request carried credentials",
+				"How long:   2h (Expires)",
+				"⚠ max-age=abc isn't a number of seconds",
+			},
+		},
+		{
+			name:   "heuristic",
+			status: 200,
+			header: http.Header{"Last-Modified": {now.Add(-100 * time.Hour).Format(http.TimeFormat)}, "Date": {date}},
+			want:   []string{"How long:   about 10h: with no lifetime given", "Checking:   with If-Modified-Since"},
+		},
+		{
+			name:   "uncacheable status",
+			status: 500,
+			header: http.Header{},
+			want:   []string{"How long:   not at all: caches don't keep a 500"},
+		},
+		{
+			name:   "expires and max-age disagree",
+			status: 200,
+			header: http.Header{"Cache-Control": {"max-age=60"}, "Expires": {now.Add(time.Hour).Format(http.TimeFormat)}, "Date": {date}, "Set-Cookie": {"a=1"}},
+			want: []string{
+				"⚠ Expires says 1h but max-age 1m",
+				"⚠ it sets a cookie but shared caches may keep it",
+			},
+		},
+	} {
+		out := explainCaching(tc.status, tc.header, tc.authorized, now)
+		for _, want := range tc.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: report lacks %q:\n%s", tc.name, want, out)
+			}
+		}
+	}
+}
+
+func TestForSeconds(t *testing.T) {
+	for n, want := range map[int64]string{0: "0s", 90: "1m30s", 3600: "1h", 5400: "1h30m", 172800: "2 days"} {
+		if got := forSeconds(n); got != want {
+			t.Errorf("forSeconds(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
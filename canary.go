@@ -0,0 +1,209 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A canary is judged on many samples, not one: compare with a number of
+// samples sends the request that many times to each side, in pairs at the
+// same instant so both see the same network, and weighs the latencies and
+// error rates with a significance test, to tell a real regression from
+// noise.
+
+// maxCanarySamples caps how many samples compare sends to each side.
+const maxCanarySamples = 1000
+
+// significance is the p-value below which a difference counts as real.
+const significance = 0.05
+
+// canarySide is what one side of a canary comparison answered.
+type canarySide struct {
+	env     string
+	samples []sample
+}
+
+// runCanary sends the requests described by a and b n times each, a pair
+// at a time, reporting how far it has got.
+func runCanary(a, b config, n int, report func(string)) (canarySide, canarySide) {
+	sides := []canarySide{{env: a.env}, {env: b.env}}
+	for i := range n {
+		report(fmt.Sprintf("Sampling %s and %s: %d of %d", a.env, b.env, i+1, n))
+		var wg sync.WaitGroup
+		for j, cfg := range []config{a, b} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				start := time.Now()
+				msg := checkServer(cfg)()
+				sides[j].samples = append(sides[j].samples, toSample(msg, start, time.Since(start)))
+			}()
+		}
+		wg.Wait()
+	}
+	return sides[0], sides[1]
+}
+
+// failed reports whether a sample counts against the side: it got no
+// answer, or a server error.
+func (s sample) failed() bool {
+	return s.err != nil || s.status >= 500
+}
+
+// latencies returns the latencies of the side's answered samples, sorted.
+func (c canarySide) latencies() []time.Duration {
+	var out []time.Duration
+	for _, s := range c.samples {
+		if s.err == nil {
+			out = append(out, s.elapsed)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// failures counts the samples that failed.
+func (c canarySide) failures() int {
+	n := 0
+	for _, s := range c.samples {
+		if s.failed() {
+			n++
+		}
+	}
+	return n
+}
+
+// describeCanary lays out the two sides' latencies and error rates, and
+// judges whether the second is worse than the first.
+func describeCanary(a, b canarySide) string {
+	la, lb := a.latencies(), b.latencies()
+	var s strings.Builder
+	fmt.Fprintf(&s, "%-8s  %12s  %12s\n", "", a.env, b.env)
+	for _, p := range []float64{50, 90, 99} {
+		fmt.Fprintf(&s, "%-8s  %12s  %12s\n", fmt.Sprintf("p%.0f", p), percentileOf(la, p), percentileOf(lb, p))
+	}
+	fmt.Fprintf(&s, "%-8s  %12s  %12s\n", "errors", errorRate(a), errorRate(b))
+	fmt.Fprintf(&s, "%-8s  %12s  %12s\n", "answers", sampleStatuses(a.samples), sampleStatuses(b.samples))
+
+	var worse []string
+	s.WriteString("\n")
+	if len(la) < 2 || len(lb) < 2 {
+		s.WriteString("Latency: too few answers to compare\n")
+	} else {
+		z := mannWhitneyZ(la, lb)
+		p := pValue(z)
+		diff := percentile(lb, 50) - percentile(la, 50)
+		switch {
+		case p >= significance:
+			fmt.Fprintf(&s, "Latency: p50 %+dms, no significant difference (%s)\n", diff.Milliseconds(), formatP(p))
+		case z > 0:
+			fmt.Fprintf(&s, "Latency: %s is slower, p50 %+dms, significant (%s)\n", b.env, diff.Milliseconds(), formatP(p))
+			worse = append(worse, "is slower")
+		default:
+			fmt.Fprintf(&s, "Latency: %s is faster, p50 %+dms, significant (%s)\n", b.env, diff.Milliseconds(), formatP(p))
+		}
+	}
+	fa, fb := a.failures(), b.failures()
+	z := proportionZ(fa, len(a.samples), fb, len(b.samples))
+	switch p := pValue(z); {
+	case fa == fb || p >= significance:
+		fmt.Fprintf(&s, "Errors: no significant difference (%s)\n", formatP(p))
+	case z > 0:
+		fmt.Fprintf(&s, "Errors: %s fails more often, significant (%s)\n", b.env, formatP(p))
+		worse = append(worse, "fails more often")
+	default:
+		fmt.Fprintf(&s, "Errors: %s fails less often, significant (%s)\n", b.env, formatP(p))
+	}
+
+	if len(worse) > 0 {
+		fmt.Fprintf(&s, "\n✗ %s looks unhealthy: it %s than %s", b.env, strings.Join(worse, " and "), a.env)
+	} else {
+		fmt.Fprintf(&s, "\n✓ %s looks as healthy as %s over %d samples", b.env, a.env, len(b.samples))
+	}
+	return s.String()
+}
+
+// percentileOf is the p-th percentile of sorted, or - if it is empty.
+func percentileOf(sorted []time.Duration, p float64) string {
+	if len(sorted) == 0 {
+		return "-"
+	}
+	return percentile(sorted, p).Round(100 * time.Microsecond).String()
+}
+
+// errorRate is the share of the side's samples that failed, e.g. "2.0% (1)".
+func errorRate(c canarySide) string {
+	n := c.failures()
+	return fmt.Sprintf("%.1f%% (%d)", 100*float64(n)/float64(max(len(c.samples), 1)), n)
+}
+
+// mannWhitneyZ compares two sets of latencies without assuming how they
+// are spread, with the Mann-Whitney U test: a positive z says b tends to be
+// slower than a.
+func mannWhitneyZ(a, b []time.Duration) float64 {
+	type ranked struct {
+		d   time.Duration
+		inB bool
+	}
+	all := make([]ranked, 0, len(a)+len(b))
+	for _, d := range a {
+		all = append(all, ranked{d, false})
+	}
+	for _, d := range b {
+		all = append(all, ranked{d, true})
+	}
+	slices.SortFunc(all, func(x, y ranked) int { return cmp.Compare(x.d, y.d) })
+
+	// Tied latencies share the mean of their ranks.
+	var rankB float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].d == all[i].d {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].inB {
+				rankB += rank
+			}
+		}
+		i = j
+	}
+	n1, n2 := float64(len(a)), float64(len(b))
+	u := rankB - n2*(n2+1)/2
+	sigma := math.Sqrt(n1 * n2 * (n1 + n2 + 1) / 12)
+	return (u - n1*n2/2) / sigma
+}
+
+// proportionZ compares the failure rates fa/na and fb/nb: a positive z says
+// b fails more often.
+func proportionZ(fa, na, fb, nb int) float64 {
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	pa, pb := float64(fa)/float64(na), float64(fb)/float64(nb)
+	pooled := float64(fa+fb) / float64(na+nb)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(na) + 1/float64(nb)))
+	if se == 0 {
+		return 0
+	}
+	return (pb - pa) / se
+}
+
+// formatP writes a p-value for the report, e.g. "p≈0.03" or "p<0.001".
+func formatP(p float64) string {
+	if p < 0.001 {
+		return "p<0.001"
+	}
+	return fmt.Sprintf("p≈%.3f", p)
+}
+
+// pValue is the two-sided p-value of a standard normal z.
+func pValue(z float64) float64 {
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
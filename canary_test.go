@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCanary(t *testing.T) {
+	stable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer stable.Close()
+	var n int
+	canary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(15 * time.Millisecond)
+		if n++; n%2 == 0 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer canary.Close()
+
+	m := model{cfg: config{
+		method: "GET", url: stable.URL + "/", path: "/", header: http.Header{},
+		env:  "stable",
+		envs: map[string]environment{"stable": {Base: stable.URL}, "canary": {Base: canary.URL}},
+	}}
+	_, cmd := runCompare(m, []string{"canary", "20"})
+	if cmd == nil {
+		t.Fatal("compare with samples didn't run")
+	}
+	msg := cmd()
+	for p, ok := msg.(progressMsg); ok; p, ok = msg.(progressMsg) {
+		msg = p.next()
+	}
+	report := msg.(reportMsg)
+	for _, want := range []string{
+		"Canary: GET /, 20 samples each",
+		"errors        0.0% (0)    50.0% (10)",
+		"Latency: canary is slower",
+		"Errors: canary fails more often, significant (p<0.001)",
+		"✗ canary looks unhealthy: it is slower and fails more often than stable",
+	} {
+		if !strings.Contains(report.title+"\n"+report.body, want) {
+			t.Errorf("canary report lacks %q:\n%s", want, report.body)
+		}
+	}
+
+	if next, cmd := runCompare(m, []string{"canary", "1"}); cmd != nil || next.(model).report == nil {
+		t.Error("one sample wasn't refused")
+	}
+}
+
+func TestCanaryStatistics(t *testing.T) {
+	ms := func(vs ...int) []time.Duration {
+		out := make([]time.Duration, len(vs))
+		for i, v := range vs {
+			out[i] = time.Duration(v) * time.Millisecond
+		}
+		return out
+	}
+	same := ms(10, 11, 12, 13, 14)
+	if z := mannWhitneyZ(same, same); z != 0 {
+		t.Errorf("z of identical samples = %v", z)
+	}
+	slower := ms(20, 21, 22, 23, 24)
+	if z := mannWhitneyZ(same, slower); z <= 0 || pValue(z) >= significance {
+		t.Errorf("z of a slower side = %v, p %v", z, pValue(z))
+	}
+	if z := mannWhitneyZ(slower, same); z >= 0 {
+		t.Errorf("z of a faster side = %v", z)
+	}
+	if p := pValue(0); p != 1 {
+		t.Errorf("pValue(0) = %v", p)
+	}
+	if z := proportionZ(0, 100, 10, 100); math.Abs(z-3.244) > 0.01 {
+		t.Errorf("proportionZ = %v", z)
+	}
+	if z := proportionZ(0, 10, 0, 10); z != 0 {
+		t.Errorf("proportionZ without failures = %v", z)
+	}
+}
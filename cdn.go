@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// An answer that came through a CDN carries the CDN's own headers: whether
+// the edge had it cached, which point of presence (POP) served it, how long
+// the origin took. Each CDN names and packs them its own way; the edge
+// summary unpacks those of the common ones into the same few lines.
+
+// popCities names the airports CDNs name their POPs after, for the ones
+// seen most.
+var popCities = map[string]string{
+	"AMS": "Amsterdam", "ARN": "Stockholm", "ATL": "Atlanta", "BOM": "Mumbai", "BOS": "Boston",
+	"CDG": "Paris", "DEL": "Delhi", "DEN": "Denver", "DFW": "Dallas", "DUB": "Dublin",
+	"EWR": "Newark", "FRA": "Frankfurt", "GRU": "São Paulo", "HKG": "Hong Kong", "HND": "Tokyo",
+	"IAD": "Ashburn", "ICN": "Seoul", "JFK": "New York", "JNB": "Johannesburg", "LAX": "Los Angeles",
+	"LHR": "London", "MAD": "Madrid", "MIA": "Miami", "MXP": "Milan", "NRT": "Tokyo",
+	"ORD": "Chicago", "SEA": "Seattle", "SFO": "San Francisco", "SIN": "Singapore", "SJC": "San Jose",
+	"SYD": "Sydney", "WAW": "Warsaw", "YUL": "Montreal", "YVR": "Vancouver", "YYZ": "Toronto",
+}
+
+// fastlyNode matches a Fastly cache node, e.g. cache-lhr7350-LHR, for its POP.
+var fastlyNode = regexp.MustCompile(`cache-[a-z0-9-]+-([A-Z]{3})$`)
+
+// cloudFrontPOP matches a CloudFront POP, e.g. LHR62-C1, for its airport.
+var cloudFrontPOP = regexp.MustCompile(`^([A-Z]{3})\d`)
+
+// edgeSummary is what the CDN headers of an answer say.
+type edgeSummary struct {
+	cdn       string   // Who served it, e.g. Cloudflare; "" if no CDN we know.
+	hops      []string // Each cache on the way, nearest the origin first, with its result.
+	pop       string   // The POP that answered, e.g. "LHR (London)".
+	age       string   // How long the answer has been cached.
+	timing    []string // What the CDN says the edge and the origin took.
+	requestID string   // The CDN's ID for the request, to quote to its support.
+}
+
+// runEdge is the palette's `edge`: it sums up what the CDN headers of the
+// answer say.
+func runEdge(m model, _ []string) (tea.Model, tea.Cmd) {
+	if m.res.status == 0 {
+		return m.paletteError(errors.New("there is no answer to read yet"))
+	}
+	m.report, m.cursor = &reportMsg{title: "Edge: " + m.cfg.method + " " + m.cfg.path, body: decodeEdge(m.res.header).describe()}, 0
+	return m, nil
+}
+
+// decodeEdge reads the CDN headers of h.
+func decodeEdge(h http.Header) edgeSummary {
+	var e edgeSummary
+	xCache := headerList(h.Values("X-Cache"))
+	switch {
+	case h.Get("CF-Ray") != "":
+		e.cdn, e.requestID = "Cloudflare", h.Get("CF-Ray")
+		if _, pop, ok := strings.Cut(e.requestID, "-"); ok {
+			e.pop = popName(pop)
+		}
+		if s := h.Get("CF-Cache-Status"); s != "" {
+			e.hops = []string{cacheResult(s)}
+		}
+	case h.Get("X-Amz-Cf-Pop") != "" || h.Get("X-Amz-Cf-Id") != "":
+		e.cdn, e.requestID = "CloudFront", h.Get("X-Amz-Cf-Id")
+		if m := cloudFrontPOP.FindStringSubmatch(h.Get("X-Amz-Cf-Pop")); m != nil {
+			e.pop = popName(m[1]) + ", " + h.Get("X-Amz-Cf-Pop")
+		}
+		for _, s := range xCache {
+			e.hops = append(e.hops, cacheResult(strings.TrimSuffix(strings.ToLower(s), " from cloudfront")))
+		}
+	case h.Get("X-Served-By") != "" && strings.Contains(h.Get("X-Served-By"), "cache-"):
+		// Fastly lists every node on the way, the shield nearest the
+		// origin first, and X-Cache their results in the same order.
+		e.cdn, e.requestID = "Fastly", h.Get("X-Served-By")
+		nodes, hits := headerList(h.Values("X-Served-By")), headerList(h.Values("X-Cache-Hits"))
+		for i, node := range nodes {
+			hop := node
+			if m := fastlyNode.FindStringSubmatch(node); m != nil {
+				hop = popName(m[1])
+				e.pop = hop
+			}
+			if i < len(xCache) {
+				hop += ": " + cacheResult(xCache[i])
+			}
+			if i < len(hits) && hits[i] != "0" {
+				hop += fmt.Sprintf(", %s hits", hits[i])
+			}
+			e.hops = append(e.hops, hop)
+		}
+		if ve, ok := fastlyElapsed(h.Get("X-Timer")); ok {
+			e.timing = append(e.timing, fmt.Sprintf("%dms in Fastly, fetching from the origin included", ve))
+		}
+	case h.Get("X-Akamai-Request-ID") != "" || h.Get("Akamai-Cache-Status") != "" || strings.Contains(strings.Join(xCache, " "), "akamai"):
+		e.cdn, e.requestID = "Akamai", h.Get("X-Akamai-Request-ID")
+		if s := h.Get("Akamai-Cache-Status"); s != "" {
+			e.hops = []string{cacheResult(s)}
+		} else {
+			for _, s := range xCache {
+				// e.g. TCP_MEM_HIT from a23-1-2-3.deploy.akamaitechnologies.com (AkamaiGHost/…)
+				result, _, _ := strings.Cut(s, " ")
+				e.hops = append(e.hops, cacheResult(result))
+			}
+		}
+	case h.Get("X-Varnish") != "" || strings.Contains(strings.ToLower(h.Get("Via")), "varnish"):
+		e.cdn = "Varnish"
+		// A hit carries the ID of the request that filled the cache as well.
+		if ids := strings.Fields(h.Get("X-Varnish")); len(ids) == 2 {
+			e.hops = []string{"hit"}
+		} else if len(ids) == 1 {
+			e.hops = []string{"miss"}
+		}
+	default:
+		for _, s := range xCache {
+			e.hops = append(e.hops, cacheResult(s))
+		}
+	}
+	if v := h.Get("Age"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			e.age = forSeconds(n)
+		}
+	}
+	e.timing = append(e.timing, serverTimings(h)...)
+	return e
+}
+
+// popName writes an airport code with its city, if we know it.
+func popName(code string) string {
+	code = strings.ToUpper(code)
+	if city, ok := popCities[code]; ok {
+		return code + " (" + city + ")"
+	}
+	return code
+}
+
+// cacheResult says in a word or two what a cache status means, whatever
+// the CDN's spelling of it.
+func cacheResult(status string) string {
+	s := strings.ToUpper(strings.TrimSpace(status))
+	switch {
+	case strings.Contains(s, "REFRESH_HIT"), strings.Contains(s, "REFRESHHIT"), strings.Contains(s, "REVALIDATED"):
+		return "revalidated: the cached copy was stale, and the origin said it still holds"
+	case strings.Contains(s, "STALE"), strings.Contains(s, "UPDATING"):
+		return "stale: the cached copy was served while a fresh one is fetched"
+	case strings.Contains(s, "EXPIRED"):
+		return "expired: the cached copy had expired, so it came from the origin"
+	case strings.Contains(s, "HIT"):
+		return "hit"
+	case strings.Contains(s, "MISS"):
+		return "miss: it came from the origin"
+	case strings.Contains(s, "DYNAMIC"):
+		return "not cached: the CDN took it for dynamic content"
+	case strings.Contains(s, "BYPASS"), strings.Contains(s, "PASS"):
+		return "bypassed: a rule or the request kept the cache out of it"
+	}
+	return strings.ToLower(strings.TrimSpace(status))
+}
+
+// fastlyElapsed reads how long Fastly took, in milliseconds, from the VE
+// field of X-Timer, e.g. S1700000000.123,VS0,VE12.
+func fastlyElapsed(timer string) (int, bool) {
+	for _, f := range strings.Split(timer, ",") {
+		if v, ok := strings.CutPrefix(f, "VE"); ok {
+			n, err := strconv.Atoi(v)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+// serverTimings lists the durations of Server-Timing, e.g. "origin 40ms",
+// where CDNs such as Akamai and Cloudflare put their timings.
+func serverTimings(h http.Header) []string {
+	var out []string
+	for _, metric := range headerList(h.Values("Server-Timing")) {
+		fields := strings.Split(metric, ";")
+		name, dur, desc := strings.TrimSpace(fields[0]), "", ""
+		for _, p := range fields[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			switch strings.ToLower(k) {
+			case "dur":
+				dur = v
+			case "desc":
+				desc = strings.Trim(v, `"`)
+			}
+		}
+		switch {
+		case dur != "":
+			out = append(out, fmt.Sprintf("%s %sms", name, dur))
+		case desc != "":
+			out = append(out, fmt.Sprintf("%s %s", name, desc))
+		}
+	}
+	return out
+}
+
+// describe lays the summary out, a line for each thing the headers told.
+func (e edgeSummary) describe() string {
+	if e.cdn == "" && len(e.hops) == 0 && e.age == "" && len(e.timing) == 0 {
+		return "No CDN or cache headers: the answer seems to have come straight from the origin."
+	}
+	var b strings.Builder
+	line := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%-11s %s\n", label+":", value)
+		}
+	}
+	cdn := e.cdn
+	if cdn == "" {
+		cdn = "unknown; only generic cache headers"
+	}
+	line("CDN", cdn)
+	switch len(e.hops) {
+	case 0:
+	case 1:
+		line("Cache", e.hops[0])
+	default:
+		line("Cache", strings.Join(e.hops, " → "))
+	}
+	line("POP", e.pop)
+	if e.age != "" {
+		line("Age", e.age+" in the cache")
+	}
+	line("Timing", strings.Join(e.timing, ", "))
+	line("Request ID", e.requestID)
+	return strings.TrimRight(b.String(), "\n")
+}
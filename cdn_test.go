@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDecodeEdge(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		want   []string
+	}{
+		{
+			name:   "cloudflare",
+			header: http.Header{"Cf-Ray": {"8a1b2c3d4e5f-LHR"}, "Cf-Cache-Status": {"HIT"}, "Age": {"120"}, "Server-Timing": {"cfL4;desc=\"?proto=TCP\", origin;dur=40"}},
+			want: []string{
+				"CDN:        Cloudflare",
+				"Cache:      hit",
+				"POP:        LHR (London)",
+				"Age:        2m in the cache",
+				"Timing:     cfL4 ?proto=TCP, origin 40ms",
+				"Request ID: 8a1b2c3d4e5f-LHR",
+			},
+		},
+		{
+			name: "fastly",
+			header: http.Header{
+				"X-Served-By":  {"cache-iad-kjyo7100123-IAD, cache-lhr7350-LHR"},
+				"X-Cache":      {"MISS, HIT"},
+				"X-Cache-Hits": {"0, 3"},
+				"X-Timer":      {"S1700000000.123456,VS0,VE12"},
+			},
+			want: []string{
+				"CDN:        Fastly",
+				"Cache:      IAD (Ashburn): miss: it came from the origin → LHR (London): hit, 3 hits",
+				"POP:        LHR (London)",
+				"Timing:     12ms in Fastly",
+			},
+		},
+		{
+			name:   "cloudfront",
+			header: http.Header{"X-Cache": {"RefreshHit from cloudfront"}, "X-Amz-Cf-Pop": {"FRA56-P1"}, "X-Amz-Cf-Id": {"abc=="}},
+			want:   []string{"CDN:        CloudFront", "Cache:      revalidated", "POP:        FRA (Frankfurt), FRA56-P1", "Request ID: abc=="},
+		},
+		{
+			name:   "akamai",
+			header: http.Header{"X-Cache": {"TCP_MISS from a23-1-2-3.deploy.akamaitechnologies.com (AkamaiGHost/10.0)"}},
+			want:   []string{"CDN:        Akamai", "Cache:      miss"},
+		},
+		{
+			name:   "varnish",
+			header: http.Header{"X-Varnish": {"32770 3"}},
+			want:   []string{"CDN:        Varnish", "Cache:      hit"},
+		},
+		{
+			name:   "generic",
+			header: http.Header{"X-Cache": {"BYPASS"}},
+			want:   []string{"CDN:        unknown", "Cache:      bypassed"},
+		},
+		{
+			name:   "none",
+			header: http.Header{"Content-Type": {"text/plain"}},
+			want:   []string{"No CDN or cache headers"},
+		},
+	} {
+		out := decodeEdge(tc.header).describe()
+		for _, want := range tc.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: summary lacks %q:\n%s", tc.name, want, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// chaosTimeout is how long a simulated timeout hangs when the request has
+// no deadline of its own to run into, as downloads to disk don't.
+const chaosTimeout = 10 * time.Second
+
+// chaosOptions make the network worse on purpose, to see how retries, and
+// this program, cope. The zero value leaves requests alone.
+type chaosOptions struct {
+	latency  time.Duration // Added before every request is sent.
+	jitter   time.Duration // Up to this much more, at random.
+	timeouts int           // Percent of requests that hang until they time out.
+	resets   int           // Percent of requests whose connection is reset.
+}
+
+// errChaosTimeout is what a simulated timeout fails with. Like the errors
+// of real timeouts, it says it is one.
+var errChaosTimeout error = chaosTimeoutError{}
+
+// chaosTimeoutError is the type of errChaosTimeout.
+type chaosTimeoutError struct{}
+
+func (chaosTimeoutError) Error() string   { return "chaos: timed out (simulated)" }
+func (chaosTimeoutError) Timeout() bool   { return true }
+func (chaosTimeoutError) Temporary() bool { return true }
+
+// enabled reports whether any chaos was asked for.
+func (c chaosOptions) enabled() bool {
+	return c != chaosOptions{}
+}
+
+// validate checks that the percentages make sense together.
+func (c chaosOptions) validate() error {
+	switch {
+	case c.latency < 0 || c.jitter < 0:
+		return errors.New("-chaos-latency and -chaos-jitter can't be negative")
+	case c.timeouts < 0 || c.resets < 0 || c.timeouts+c.resets > 100:
+		return errors.New("-chaos-timeouts and -chaos-resets are percentages, and together at most 100")
+	}
+	return nil
+}
+
+// String describes the chaos, e.g. "200ms + up to 50ms delay, 10% timeouts".
+func (c chaosOptions) String() string {
+	var parts []string
+	if c.latency > 0 || c.jitter > 0 {
+		d := c.latency.String()
+		if c.jitter > 0 {
+			d += " + up to " + c.jitter.String()
+		}
+		parts = append(parts, d+" delay")
+	}
+	if c.timeouts > 0 {
+		parts = append(parts, fmt.Sprintf("%d%% timeouts", c.timeouts))
+	}
+	if c.resets > 0 {
+		parts = append(parts, fmt.Sprintf("%d%% connection resets", c.resets))
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// inflict holds req up by the latency asked for, then maybe fails it the
+// way a bad network would. roll is a number from 0 to 99, picked at random
+// for every request, that decides which requests fail. A nil error means
+// the request goes ahead.
+func (c chaosOptions) inflict(req *http.Request, roll int) error {
+	ctx := req.Context()
+	delay := c.latency
+	if c.jitter > 0 {
+		delay += rand.N(c.jitter)
+	}
+	if delay > 0 {
+		logf(logDebug, "Chaos: held %s %s back %s", req.Method, req.URL, delay)
+	}
+	if err := sleepCtx(ctx, delay); err != nil {
+		return err
+	}
+	switch {
+	case roll < c.timeouts:
+		logf(logInfo, "Chaos: timing %s %s out", req.Method, req.URL)
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, chaosTimeout)
+			defer cancel()
+		}
+		<-ctx.Done()
+		return errChaosTimeout
+	case roll < c.timeouts+c.resets:
+		logf(logInfo, "Chaos: resetting the connection of %s %s", req.Method, req.URL)
+		return fmt.Errorf("chaos (simulated): %w", syscall.ECONNRESET)
+	}
+	return nil
+}
+
+// sleepCtx waits for d, or until ctx is done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
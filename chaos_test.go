@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestChaosInflict(t *testing.T) {
+	c := chaosOptions{latency: 20 * time.Millisecond, timeouts: 10, resets: 20}
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+
+	start := time.Now()
+	if err := c.inflict(req, 50); err != nil || time.Since(start) < 20*time.Millisecond {
+		t.Errorf("roll 50: err = %v after %s", err, time.Since(start))
+	}
+	if err := c.inflict(req, 15); !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("roll 15: err = %v, want a reset", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	var ne net.Error
+	if err := c.inflict(req.WithContext(ctx), 5); !errors.As(err, &ne) || !ne.Timeout() {
+		t.Errorf("roll 5: err = %v, want a timeout", err)
+	}
+}
+
+func TestChaosOptions(t *testing.T) {
+	if (chaosOptions{}).enabled() || (chaosOptions{}).String() != "none" {
+		t.Error("the zero options are chaos")
+	}
+	c := chaosOptions{latency: 200 * time.Millisecond, jitter: 50 * time.Millisecond, timeouts: 10, resets: 5}
+	if got, want := c.String(), "200ms + up to 50ms delay, 10% timeouts, 5% connection resets"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if c.validate() != nil || (chaosOptions{timeouts: 60, resets: 50}).validate() == nil || (chaosOptions{jitter: -1}).validate() == nil {
+		t.Error("validate let bad options through, or stopped good ones")
+	}
+}
+
+func TestChaosTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	c := newClient(config{transport: transportOptions{chaos: chaosOptions{resets: 100}}})
+	if _, err := c.Get(srv.URL); !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("err = %v, want a reset", err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// textCharset describes how a textual body was decoded for display.
+type textCharset struct {
+	name    string // Original charset, e.g. "shift_jis" or "windows-1252".
+	guessed bool   // Not declared by a BOM or header, only inferred.
+}
+
+// summary describes the charset for the status area, e.g.
+// "shift_jis, shown as UTF-8".
+func (c *textCharset) summary() string {
+	s := c.name + ", shown as UTF-8"
+	if c.guessed {
+		s += " (inferred, not declared by the server)"
+	}
+	return s
+}
+
+// decodeText converts a textual body to UTF-8 for display. The charset comes
+// from a byte order mark, the Content-Type header, or an HTML <meta> tag, in
+// that order; failing all of those, valid UTF-8 is taken as is and anything
+// else is read as windows-1252, the web's usual legacy default. A nil
+// textCharset means the body was UTF-8 already.
+func decodeText(contentType string, body []byte) ([]byte, *textCharset) {
+	enc, name, certain := charset.DetermineEncoding(body, contentType)
+	if name == "utf-8" || (!certain && utf8.Valid(body)) {
+		return body, nil
+	}
+
+	text, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		// Leave undecodable bodies alone rather than showing half of them.
+		return body, nil
+	}
+	return text, &textCharset{name: name, guessed: !certain}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+		charset     string
+		guessed     bool
+	}{
+		{"utf-8", "text/plain; charset=utf-8", "héllo", "héllo", "", false},
+		{"undeclared utf-8", "text/plain", "héllo", "héllo", "", false},
+		{"declared latin-1", "text/plain; charset=iso-8859-1", "caf\xe9", "café", "windows-1252", false},
+		{"shift_jis", "text/plain; charset=shift_jis", "\x93\xfa\x96\x7b", "日本", "shift_jis", false},
+		{"meta tag", "text/html", `<meta charset="windows-1251"><p>` + "\xcf\xf0\xe8", `<meta charset="windows-1251"><p>При`, "windows-1251", true},
+		{"guessed", "text/plain", "na\xefve", "naïve", "windows-1252", true},
+	}
+	for _, tt := range tests {
+		got, cs := decodeText(tt.contentType, []byte(tt.body))
+		if string(got) != tt.want {
+			t.Errorf("%s: text = %q, want %q", tt.name, got, tt.want)
+		}
+		switch {
+		case tt.charset == "" && cs != nil:
+			t.Errorf("%s: charset = %+v, want none", tt.name, cs)
+		case tt.charset != "" && (cs == nil || cs.name != tt.charset || cs.guessed != tt.guessed):
+			t.Errorf("%s: charset = %+v, want %s (guessed %v)", tt.name, cs, tt.charset, tt.guessed)
+		}
+	}
+}
+
+func TestCharsetSummary(t *testing.T) {
+	if got := (&textCharset{name: "shift_jis"}).summary(); got != "shift_jis, shown as UTF-8" {
+		t.Errorf("summary = %q", got)
+	}
+	if got := (&textCharset{name: "windows-1252", guessed: true}).summary(); got != "windows-1252, shown as UTF-8 (inferred, not declared by the server)" {
+		t.Errorf("guessed summary = %q", got)
+	}
+}
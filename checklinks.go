@@ -0,0 +1,143 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// linkColumns names the columns of the broken-link table, in sort order.
+var linkColumns = []string{"status", "url", "referrer"}
+
+// linksModel is the Bubble Tea model behind the check-links subcommand. It
+// crawls a site and shows every link that didn't answer 2xx in a table.
+type linksModel struct {
+	cfg    config        // Site to crawl; crawlDepth says how far.
+	broken []crawlResult // Non-2xx results, in the current sort order.
+	total  int           // Number of URLs checked, once the crawl is done.
+	done   bool          // The crawl has finished.
+	sortBy int           // Index into linkColumns.
+	offset int           // First table row on screen.
+	status string        // How far the crawl has got, while it runs.
+	err    error         // Why the crawl could not start, if it couldn't.
+}
+
+// crawlDoneMsg carries the results of a finished crawl.
+type crawlDoneMsg []crawlResult
+
+// runCheckLinks implements `check-links [-depth N] URL`.
+func runCheckLinks(args []string) error {
+	fs := flag.NewFlagSet("check-links", flag.ExitOnError)
+	depth := fs.Int("depth", 2, "how many links `deep` to follow the site")
+	fs.Parse(args)
+
+	cfg := config{url: defaultURL, crawlDepth: *depth}
+	if fs.NArg() > 0 {
+		cfg.url = fs.Arg(0)
+	}
+
+	_, err := tea.NewProgram(linksModel{cfg: cfg}).Run()
+	return err
+}
+
+// Init starts the crawl. Links to other hosts are checked but not followed.
+func (m linksModel) Init() tea.Cmd {
+	return runJob(func(report func(string)) tea.Msg {
+		results, err := crawl(newClient(m.cfg), m.cfg.url, m.cfg.crawlDepth, true, func(checked, queued int) {
+			report(fmt.Sprintf("%d links checked, %d queued", checked, queued))
+		})
+		if err != nil {
+			return errMsg{err}
+		}
+		return crawlDoneMsg(results)
+	})
+}
+
+// Update handles the crawl result and the table's keys.
+func (m linksModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case crawlDoneMsg:
+		m.done, m.total = true, len(msg)
+		for _, r := range msg {
+			if r.err != nil || r.status < 200 || r.status > 299 {
+				m.broken = append(m.broken, r)
+			}
+		}
+		m.sort()
+
+	case progressMsg:
+		m.status = msg.status
+		return m, msg.next
+
+	case errMsg:
+		m.err = msg.err
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+
+		// Cycle through the sort columns.
+		case "s":
+			m.sortBy = (m.sortBy + 1) % len(linkColumns)
+			m.sort()
+
+		// Scroll the table.
+		case "up", "k":
+			m.offset = max(m.offset-1, 0)
+		case "down", "j":
+			m.offset = min(m.offset+1, max(len(m.broken)-listHeight, 0))
+		}
+	}
+	return m, nil
+}
+
+// sort orders the broken links by the selected column, breaking ties by URL.
+func (m *linksModel) sort() {
+	slices.SortStableFunc(m.broken, func(a, b crawlResult) int {
+		var c int
+		switch linkColumns[m.sortBy] {
+		case "status":
+			c = cmp.Compare(a.status, b.status)
+		case "referrer":
+			c = cmp.Compare(a.referrer, b.referrer)
+		}
+		return cmp.Or(c, cmp.Compare(a.url, b.url))
+	})
+}
+
+// View renders the crawl progress or the table of broken links.
+func (m linksModel) View() string {
+	if m.err != nil {
+		return fmt.Sprintf("\nWe had some trouble: %v\n\n", m.err)
+	}
+	if !m.done {
+		s := fmt.Sprintf("\nChecking links on %s, %d deep ...\n", m.cfg.url, m.cfg.crawlDepth)
+		if m.status != "" {
+			s += m.status + "\n"
+		}
+		return s + "\n"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nChecked %d links from %s: %d broken, sorted by %s\n\n",
+		m.total, m.cfg.url, len(m.broken), linkColumns[m.sortBy])
+	if len(m.broken) == 0 {
+		b.WriteString("Every link answered 2xx.\n")
+	} else {
+		fmt.Fprintf(&b, "%-6s %-50s %s\n", "STATUS", "URL", "FOUND ON")
+	}
+	for _, r := range m.broken[m.offset:min(m.offset+listHeight, len(m.broken))] {
+		status := fmt.Sprint(r.status)
+		if r.err != nil {
+			status = "ERR"
+		}
+		fmt.Fprintf(&b, "%-6s %-50s %s\n", status, r.url, r.referrer)
+	}
+	b.WriteString("\n↑/↓ scroll • s sort • q quit\n")
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestLinksModel(t *testing.T) {
+	results := crawlDoneMsg{
+		{url: "https://a.test/", status: 200},
+		{url: "https://a.test/z", referrer: "https://a.test/", status: 404},
+		{url: "https://a.test/b", referrer: "https://a.test/z", status: 500},
+		{url: "https://b.test/", referrer: "https://a.test/", err: errors.New("refused")},
+		{url: "https://a.test/moved", referrer: "https://a.test/", status: 301},
+	}
+	next, _ := linksModel{}.Update(results)
+	m := next.(linksModel)
+	if !m.done || m.total != 5 || len(m.broken) != 4 {
+		t.Fatalf("done = %v, total = %d, broken = %d", m.done, m.total, len(m.broken))
+	}
+	urls := func() string {
+		var s []string
+		for _, r := range m.broken {
+			s = append(s, strings.TrimPrefix(r.url, "https://"))
+		}
+		return strings.Join(s, " ")
+	}
+	// By status first; a failed request has status 0.
+	if got := urls(); got != "b.test/ a.test/moved a.test/z a.test/b" {
+		t.Errorf("by status = %s", got)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	m = next.(linksModel)
+	if got := urls(); got != "a.test/b a.test/moved a.test/z b.test/" {
+		t.Errorf("by url = %s", got)
+	}
+	if view := m.View(); !strings.Contains(view, "4 broken, sorted by url") || !strings.Contains(view, "ERR") {
+		t.Errorf("view =\n%s", view)
+	}
+}
+
+func TestLinksModelNothingBroken(t *testing.T) {
+	next, _ := linksModel{}.Update(crawlDoneMsg{{url: "https://a.test/", status: 204}})
+	if view := next.(linksModel).View(); !strings.Contains(view, "Every link answered 2xx.") {
+		t.Errorf("view =\n%s", view)
+	}
+}
+
+func TestLinksModelProgress(t *testing.T) {
+	m := linksModel{cfg: config{url: "https://a.test/", crawlDepth: 2}}
+	next, cmd := m.Update(progressMsg{status: "7 links checked, 3 queued"})
+	if view := next.(linksModel).View(); !strings.Contains(view, "7 links checked, 3 queued") || cmd != nil {
+		t.Errorf("view =\n%s", view)
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoints behind Cloud Run, IAP or Azure API Management want a token
+// from the cloud's identity service, which lasts an hour. -cloud-token gets
+// one and sends it as the Authorization: Bearer of each request, asking
+// again when it is about to run out or is refused:
+//
+//	gcp               a Google access token
+//	gcp-id[=AUDIENCE] a Google identity token, for Cloud Run and IAP; the
+//	                  audience is the request's origin unless given
+//	azure[=RESOURCE]  an Azure access token for RESOURCE, by default
+//	                  https://management.azure.com/
+//
+// The token comes from the cloud's command line, gcloud or az, as whoever
+// is signed in to it; where there is none, as on a VM or in a container
+// running in the cloud, from the metadata server, as its service account.
+
+// cloudTokenKinds are what -cloud-token can ask for.
+var cloudTokenKinds = []string{"gcp", "gcp-id", "azure"}
+
+// defaultAzureResource is what an Azure token is for unless -cloud-token
+// says otherwise.
+const defaultAzureResource = "https://management.azure.com/"
+
+// cloudTokenTimeout bounds how long getting a token may take.
+const cloudTokenTimeout = 30 * time.Second
+
+// cloudTokenMargin is how long before it runs out a token is replaced.
+const cloudTokenMargin = time.Minute
+
+// The metadata servers, as seen from inside each cloud. GCE_METADATA_HOST
+// moves Google's, as for its own client libraries.
+var (
+	gcpMetadataHost = "metadata.google.internal"
+	azureIMDS       = "http://169.254.169.254/metadata/identity/oauth2/token"
+)
+
+// cloudTokenSpec is what -cloud-token asked for.
+type cloudTokenSpec struct {
+	kind     string // One of cloudTokenKinds.
+	audience string // The audience or resource; "" for the default.
+}
+
+// parseCloudToken reads -cloud-token: a kind, and maybe =audience.
+func parseCloudToken(v string) (cloudTokenSpec, error) {
+	kind, audience, _ := strings.Cut(v, "=")
+	s := cloudTokenSpec{kind: strings.ToLower(strings.TrimSpace(kind)), audience: strings.TrimSpace(audience)}
+	switch {
+	case s.kind != "gcp" && s.kind != "gcp-id" && s.kind != "azure":
+		return s, fmt.Errorf("-cloud-token %q: use one of %s", v, strings.Join(cloudTokenKinds, ", "))
+	case s.kind == "gcp" && s.audience != "":
+		return s, errors.New("-cloud-token gcp takes no audience; an identity token is gcp-id=AUDIENCE")
+	}
+	return s, nil
+}
+
+// cloudToken is a token and when it runs out.
+type cloudToken struct {
+	value   string
+	expires time.Time
+}
+
+// cloudTokens keeps the tokens got so far, by kind and audience, for as
+// long as they last.
+var cloudTokens = struct {
+	sync.Mutex
+	byKey map[cloudTokenSpec]cloudToken
+}{byKey: map[cloudTokenSpec]cloudToken{}}
+
+// cloudAuth is the middleware that sends a cloud token with each request.
+type cloudAuth struct {
+	spec cloudTokenSpec
+	used cloudTokenSpec // What the latest request was sent with, to forget it if refused.
+}
+
+func (c *cloudAuth) name() string { return "cloud-token" }
+
+// forRequest is what the token for req is: the audience of an identity
+// token defaults to where req goes.
+func (c *cloudAuth) forRequest(req *http.Request) cloudTokenSpec {
+	s := c.spec
+	if s.kind == "gcp-id" && s.audience == "" {
+		s.audience = req.URL.Scheme + "://" + req.URL.Host
+	}
+	return s
+}
+
+// An Authorization given with -H wins over the cloud's.
+func (c *cloudAuth) onRequest(req *http.Request, body []byte) ([]byte, error) {
+	if req.Header.Get("Authorization") != "" {
+		return body, nil
+	}
+	s := c.forRequest(req)
+	token, err := getCloudToken(s, time.Now())
+	if err != nil {
+		return body, err
+	}
+	c.used = s
+	req.Header.Set("Authorization", "Bearer "+token)
+	return body, nil
+}
+
+func (c *cloudAuth) onResponse(res *http.Response, _ []byte) ([]string, error) {
+	if res.StatusCode != http.StatusUnauthorized || c.used.kind == "" {
+		return nil, nil
+	}
+	// Revoked, or the wrong account: the next request asks afresh.
+	cloudTokens.Lock()
+	delete(cloudTokens.byKey, c.used)
+	cloudTokens.Unlock()
+	return []string{fmt.Sprintf("the %s token was refused; the next request gets a new one", c.used.kind)}, nil
+}
+
+// getCloudToken returns a token as s says, as of now: the one got last
+// time, unless it is about to run out.
+func getCloudToken(s cloudTokenSpec, now time.Time) (string, error) {
+	cloudTokens.Lock()
+	t, ok := cloudTokens.byKey[s]
+	cloudTokens.Unlock()
+	if ok && now.Add(cloudTokenMargin).Before(t.expires) {
+		return t.value, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cloudTokenTimeout)
+	defer cancel()
+	t, err := fetchCloudToken(ctx, s, now)
+	if err != nil {
+		return "", fmt.Errorf("getting a %s token: %w", s.kind, err)
+	}
+	cloudTokens.Lock()
+	cloudTokens.byKey[s] = t
+	cloudTokens.Unlock()
+	return t.value, nil
+}
+
+// fetchCloudToken gets a new token as s says, from the command line if it
+// is installed and the metadata server if not.
+func fetchCloudToken(ctx context.Context, s cloudTokenSpec, now time.Time) (cloudToken, error) {
+	switch s.kind {
+	case "gcp", "gcp-id":
+		if _, err := exec.LookPath("gcloud"); err == nil {
+			args := []string{"auth", "print-access-token"}
+			if s.kind == "gcp-id" {
+				// Only a service account can choose the audience; a user's
+				// token is for gcloud, which Cloud Run takes too.
+				args = []string{"auth", "print-identity-token"}
+				if account, _ := runCLI(ctx, "gcloud", "config", "get-value", "account"); strings.HasSuffix(account, ".gserviceaccount.com") {
+					args = append(args, "--audiences="+s.audience)
+				}
+			}
+			value, err := runCLI(ctx, "gcloud", args...)
+			if err != nil {
+				return cloudToken{}, err
+			}
+			return cloudToken{value, tokenExpiry(value, now)}, nil
+		}
+		host := gcpMetadataHost
+		if h := os.Getenv("GCE_METADATA_HOST"); h != "" {
+			host = h
+		}
+		base := "http://" + host + "/computeMetadata/v1/instance/service-accounts/default/"
+		if s.kind == "gcp-id" {
+			b, err := askMetadata(ctx, base+"identity?format=full&audience="+url.QueryEscape(s.audience), "Metadata-Flavor", "Google")
+			if err != nil {
+				return cloudToken{}, err
+			}
+			value := strings.TrimSpace(string(b))
+			return cloudToken{value, tokenExpiry(value, now)}, nil
+		}
+		b, err := askMetadata(ctx, base+"token", "Metadata-Flavor", "Google")
+		if err != nil {
+			return cloudToken{}, err
+		}
+		return oauthToken(b, now)
+	default:
+		resource := s.audience
+		if resource == "" {
+			resource = defaultAzureResource
+		}
+		if _, err := exec.LookPath("az"); err == nil {
+			out, err := runCLI(ctx, "az", "account", "get-access-token", "--resource", resource, "--output", "json")
+			if err != nil {
+				return cloudToken{}, err
+			}
+			var t struct {
+				AccessToken string `json:"accessToken"`
+				ExpiresOn   int64  `json:"expires_on"`
+			}
+			if err := json.Unmarshal([]byte(out), &t); err != nil || t.AccessToken == "" {
+				return cloudToken{}, errors.New("az gave no access token")
+			}
+			expires := tokenExpiry(t.AccessToken, now)
+			if t.ExpiresOn > 0 {
+				expires = time.Unix(t.ExpiresOn, 0)
+			}
+			return cloudToken{t.AccessToken, expires}, nil
+		}
+		b, err := askMetadata(ctx, azureIMDS+"?api-version=2018-02-01&resource="+url.QueryEscape(resource), "Metadata", "true")
+		if err != nil {
+			return cloudToken{}, err
+		}
+		return oauthToken(b, now)
+	}
+}
+
+// runCLI runs a cloud's command line and returns what it printed.
+func runCLI(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// askMetadata gets target from a metadata server, which wants the header
+// key: value to be sure the request isn't forged by a page.
+func askMetadata(ctx context.Context, target, key, value string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(key, value)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("no command line, and no metadata server: %w", err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the metadata server answered %s: %s", res.Status, strings.TrimSpace(string(b)))
+	}
+	return b, nil
+}
+
+// oauthToken reads a metadata server's token, whose expires_in is a number
+// of seconds, or for Azure's a string of one.
+func oauthToken(b []byte, now time.Time) (cloudToken, error) {
+	var t struct {
+		AccessToken string          `json:"access_token"`
+		ExpiresIn   json.RawMessage `json:"expires_in"`
+	}
+	if err := json.Unmarshal(b, &t); err != nil || t.AccessToken == "" {
+		return cloudToken{}, errors.New("the metadata server gave no access token")
+	}
+	secs, err := strconv.Atoi(strings.Trim(string(t.ExpiresIn), `"`))
+	if err != nil {
+		return cloudToken{t.AccessToken, tokenExpiry(t.AccessToken, now)}, nil
+	}
+	return cloudToken{t.AccessToken, now.Add(time.Duration(secs) * time.Second)}, nil
+}
+
+// tokenExpiry is when token runs out: its exp, if it is a JWT, and
+// otherwise in the hour Google's and Azure's tokens last, less a margin.
+func tokenExpiry(token string, now time.Time) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) == 3 {
+		if b, err := base64.RawURLEncoding.DecodeString(parts[1]); err == nil {
+			var claims struct {
+				Exp int64 `json:"exp"`
+			}
+			if json.Unmarshal(b, &claims) == nil && claims.Exp > 0 {
+				return time.Unix(claims.Exp, 0)
+			}
+		}
+	}
+	return now.Add(50 * time.Minute)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCloudToken(t *testing.T) {
+	for v, want := range map[string]cloudTokenSpec{
+		"gcp":                               {kind: "gcp"},
+		"gcp-id=https://svc-x.a.run.app":    {kind: "gcp-id", audience: "https://svc-x.a.run.app"},
+		"Azure=api://11111111-2222-3333-44": {kind: "azure", audience: "api://11111111-2222-3333-44"},
+	} {
+		if got, err := parseCloudToken(v); err != nil || got != want {
+			t.Errorf("%s: %+v, %v", v, got, err)
+		}
+	}
+	for _, v := range []string{"aws", "gcp=x"} {
+		if _, err := parseCloudToken(v); err == nil {
+			t.Errorf("%s was taken", v)
+		}
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	jwt := "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(`{"exp":1700003600}`)) + ".sig"
+	if got := tokenExpiry(jwt, now); !got.Equal(time.Unix(1_700_003_600, 0)) {
+		t.Errorf("JWT: %v", got)
+	}
+	if got := tokenExpiry("ya29.opaque", now); !got.Equal(now.Add(50 * time.Minute)) {
+		t.Errorf("opaque: %v", got)
+	}
+}
+
+func TestCloudTokenFromMetadata(t *testing.T) {
+	t.Setenv("PATH", t.TempDir()) // Neither gcloud nor az.
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch {
+		case r.Header.Get("Metadata-Flavor") == "Google" && strings.HasSuffix(r.URL.Path, "/default/token"):
+			w.Write([]byte(`{"access_token": "ya29.meta", "expires_in": 3599, "token_type": "Bearer"}`))
+		case r.Header.Get("Metadata-Flavor") == "Google" && strings.HasSuffix(r.URL.Path, "/default/identity"):
+			w.Write([]byte("id-for-" + r.URL.Query().Get("audience")))
+		case r.Header.Get("Metadata") == "true" && r.URL.Query().Get("resource") == defaultAzureResource:
+			w.Write([]byte(`{"access_token": "az.meta", "expires_in": "3599"}`))
+		default:
+			http.Error(w, "forged", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(srv.URL, "http://"))
+	saved := azureIMDS
+	azureIMDS = srv.URL + "/metadata/identity/oauth2/token"
+	defer func() { azureIMDS = saved }()
+
+	mw := &cloudAuth{spec: cloudTokenSpec{kind: "gcp-id"}}
+	req := httptest.NewRequest("GET", "https://svc-meta.a.run.app/items", nil)
+	if _, err := mw.onRequest(req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer id-for-https://svc-meta.a.run.app" {
+		t.Errorf("gcp-id: %q", got)
+	}
+	for spec, want := range map[cloudTokenSpec]string{{kind: "gcp"}: "ya29.meta", {kind: "azure"}: "az.meta"} {
+		if got, err := getCloudToken(spec, time.Now()); err != nil || got != want {
+			t.Errorf("%s: %q, %v", spec.kind, got, err)
+		}
+	}
+
+	// Until it is about to run out, or is refused, the token is kept.
+	before := calls
+	getCloudToken(cloudTokenSpec{kind: "gcp"}, time.Now())
+	if calls != before {
+		t.Error("a fresh token was asked for again")
+	}
+	getCloudToken(cloudTokenSpec{kind: "gcp"}, time.Now().Add(59*time.Minute))
+	if calls != before+1 {
+		t.Error("a token about to run out was kept")
+	}
+	notes, _ := mw.onResponse(&http.Response{StatusCode: http.StatusUnauthorized}, nil)
+	if len(notes) != 1 {
+		t.Errorf("notes = %v", notes)
+	}
+	if _, ok := cloudTokens.byKey[mw.used]; ok {
+		t.Error("a refused token was kept")
+	}
+
+	// One given with -H wins.
+	req = httptest.NewRequest("GET", "https://svc-meta.a.run.app/items", nil)
+	req.Header.Set("Authorization", "Bearer mine")
+	mw.onRequest(req, nil)
+	if got := req.Header.Get("Authorization"); got != "Bearer mine" {
+		t.Errorf("with -H: %q", got)
+	}
+}
+
+func TestCloudTokenFromCLI(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	os.WriteFile(filepath.Join(dir, "gcloud"), []byte(`#!/bin/sh
+case "$*" in
+"config get-value account") echo ci@proj.iam.gserviceaccount.com ;;
+"auth print-identity-token --audiences=https://svc-cli.a.run.app") echo id.cli ;;
+*) echo "unexpected: $*" >&2; exit 1 ;;
+esac
+`), 0o755)
+	os.WriteFile(filepath.Join(dir, "az"), []byte(`#!/bin/sh
+echo '{"accessToken": "az.cli", "expires_on": 4102444800}'
+`), 0o755)
+	if got, err := getCloudToken(cloudTokenSpec{kind: "gcp-id", audience: "https://svc-cli.a.run.app"}, time.Now()); err != nil || got != "id.cli" {
+		t.Errorf("gcloud: %q, %v", got, err)
+	}
+	if got, err := getCloudToken(cloudTokenSpec{kind: "azure", audience: "api://cli"}, time.Now()); err != nil || got != "az.cli" {
+		t.Errorf("az: %q, %v", got, err)
+	}
+	if _, err := getCloudToken(cloudTokenSpec{kind: "gcp"}, time.Now().Add(24*time.Hour)); err == nil || !strings.Contains(err.Error(), "unexpected: auth print-access-token") {
+		t.Errorf("a failing gcloud: %v", err)
+	}
+}
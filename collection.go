@@ -0,0 +1,488 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// collection is a file of saved requests that the run subcommand sends one
+// after another, checking each answer, without the interactive screen:
+//
+//	{
+//	  "name": "Pet store",
+//	  "requests": [
+//	    {"name": "List pets", "url": "/pets", "headers": {"Accept": "application/json"}},
+//	    {"name": "Add a pet", "method": "POST", "url": "/pets", "body": "{\"name\": \"Rex\"}",
+//	     "expect": {"status": 201, "body_contains": "Rex"}}
+//	  ],
+//	  "folders": [
+//	    {"name": "Orders", "requests": [
+//	      {"name": "Order a pet", "method": "POST", "url": "/orders", "body": "{}", "depends_on": ["Add a pet"]}
+//	    ]}
+//	  ]
+//	}
+//
+// URLs may be relative to the base URL of the environment chosen with -env.
+// The requests of a folder, and those outside any, go in order; with
+// -parallel, folders run alongside each other. A request that depends_on
+// others, by id or name, waits for them wherever they are, and is skipped
+// if one of them fails.
+//
+// The collection and each folder may have before and after hooks, to set
+// things up and clean up after themselves:
+//
+//	"before": [{"request": "Log in"}, {"run": "./seed-db.sh"}],
+//	"after": [{"request": "Delete test pets"}]
+//
+// A hook is a request of the collection, by id or name, which then runs only
+// as the hook, or a command to run. Before hooks go first, and if one fails,
+// the requests they come before are skipped; after hooks go last, whatever
+// became of the rest.
+type collection struct {
+	Name     string              `json:"name"`
+	Requests []collectionRequest `json:"requests"`
+	Folders  []folder            `json:"folders"`
+	Before   []hook              `json:"before"`
+	After    []hook              `json:"after"`
+
+	SnapshotIgnore []string `json:"snapshot_ignore"` // Fields no request's snapshot compares.
+
+	// OpenAPI is the spec, in JSON, that the API is described by, relative
+	// to the collection; every answer is checked against it.
+	OpenAPI string `json:"openapi"`
+}
+
+// folder is a group of requests of a collection that go in order.
+type folder struct {
+	Name     string              `json:"name"`
+	Requests []collectionRequest `json:"requests"`
+	Before   []hook              `json:"before"`
+	After    []hook              `json:"after"`
+}
+
+// hook is a setup or teardown step of a collection or folder: a request
+// of the collection or a command line.
+type hook struct {
+	Request string `json:"request"`
+	Run     string `json:"run"`
+}
+
+// collectionRequest is one saved request of a collection.
+type collectionRequest struct {
+	ID        string            `json:"id"` // What depends_on may call it instead of its name.
+	Name      string            `json:"name"`
+	Method    string            `json:"method"` // GET if left out, or POST with a body.
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers"`
+	Body      string            `json:"body"`
+	Expect    expectation       `json:"expect"`
+	DependsOn []string          `json:"depends_on"`
+
+	SnapshotIgnore []string `json:"snapshot_ignore"` // Fields its snapshot doesn't compare.
+}
+
+// method is the method the request is sent with.
+func (r collectionRequest) method() string {
+	switch {
+	case r.Method != "":
+		return strings.ToUpper(r.Method)
+	case r.Body != "":
+		return http.MethodPost
+	}
+	return http.MethodGet
+}
+
+// label is what the request is called in the output: its name, or failing
+// that its method and URL.
+func (r collectionRequest) label() string {
+	return cmp.Or(r.Name, r.method()+" "+r.URL)
+}
+
+// expectation is what a request's answer has to look like to pass. Its
+// body may have to keep to a JSON Schema, written out in full or named as
+// a file, or a schema in one, relative to the collection:
+//
+//	"expect": {"schema": "openapi.json#/components/schemas/Pet"}
+type expectation struct {
+	Status       int             `json:"status"` // 0 for any 2xx.
+	BodyContains string          `json:"body_contains"`
+	Schema       json.RawMessage `json:"schema"`
+
+	schema *jsonSchema // Schema, loaded.
+}
+
+// loadSchema loads the schema of e, if it has one, looking for files in dir.
+func (e *expectation) loadSchema(dir string) error {
+	var ref string
+	var err error
+	switch {
+	case len(e.Schema) == 0:
+		return nil
+	case json.Unmarshal(e.Schema, &ref) == nil:
+		e.schema, err = loadSchema(ref, dir)
+	default:
+		e.schema, err = parseSchema(e.Schema)
+	}
+	return err
+}
+
+// runResult is how one request of a collection run went.
+type runResult struct {
+	name      string
+	folder    string // "" for a request outside any folder.
+	method    string
+	url       string
+	status    int // 0 when there was no answer.
+	elapsed   time.Duration
+	requestID string
+	body      []byte
+	failure   string   // Why it failed, "" if it passed.
+	warnings  []string // What was amiss, without failing it.
+
+	contentType string
+}
+
+// passed reports whether the request passed.
+func (r runResult) passed() bool { return r.failure == "" }
+
+// collectionRun is a finished run of a collection.
+type collectionRun struct {
+	name    string
+	started time.Time
+	elapsed time.Duration
+	results []runResult
+}
+
+// failed counts the requests that didn't pass.
+func (r collectionRun) failed() int {
+	n := 0
+	for _, res := range r.results {
+		if !res.passed() {
+			n++
+		}
+	}
+	return n
+}
+
+// warnings counts the warnings of all requests.
+func (r collectionRun) warnings() int {
+	n := 0
+	for _, res := range r.results {
+		n += len(res.warnings)
+	}
+	return n
+}
+
+// loadCollection reads a collection file.
+func loadCollection(path string) (*collection, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c collection
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	n := len(c.Requests)
+	for i, r := range c.Requests {
+		if r.URL == "" {
+			return nil, fmt.Errorf("%s: request %d has no url", path, i+1)
+		}
+		if err := c.Requests[i].Expect.loadSchema(dir); err != nil {
+			return nil, fmt.Errorf("%s: the schema of %s: %w", path, r.label(), err)
+		}
+	}
+	for _, f := range c.Folders {
+		n += len(f.Requests)
+		for i, r := range f.Requests {
+			if r.URL == "" {
+				return nil, fmt.Errorf("%s: request %d of folder %s has no url", path, i+1, f.Name)
+			}
+			if err := f.Requests[i].Expect.loadSchema(dir); err != nil {
+				return nil, fmt.Errorf("%s: the schema of %s: %w", path, r.label(), err)
+			}
+		}
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("%s has no requests", path)
+	}
+	return &c, nil
+}
+
+// gates are the limits a CI pipeline sets on a run: beyond a request's own
+// expectation, it fails on any status in failOn, when it takes longer than
+// maxLatency, when its answer differs from its snapshot or breaks the API's
+// contract, and the run as a whole fails when more than maxFailures of its
+// requests do.
+type gates struct {
+	failOn      []statusRange
+	maxLatency  time.Duration // 0 for no limit.
+	maxFailures int
+	snapshots   *snapshots   // nil to compare no snapshots.
+	contract    *openAPISpec // nil to check against no spec.
+}
+
+// statusRange is a range of status codes, such as 500-599 for "5xx".
+type statusRange struct{ from, to int }
+
+// parseStatusRanges reads -fail-on-status: codes and classes separated by
+// commas, e.g. "5xx,429".
+func parseStatusRanges(v string) ([]statusRange, error) {
+	var ranges []statusRange
+	for _, f := range strings.Split(v, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" {
+			continue
+		}
+		if class, ok := strings.CutSuffix(f, "xx"); ok && len(class) == 1 && class[0] >= '1' && class[0] <= '5' {
+			from := int(class[0]-'0') * 100
+			ranges = append(ranges, statusRange{from, from + 99})
+			continue
+		}
+		code, err := strconv.Atoi(f)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("-fail-on-status: %q is neither a status code nor a class such as 5xx", f)
+		}
+		ranges = append(ranges, statusRange{code, code})
+	}
+	return ranges, nil
+}
+
+// check fails r if it breaks a gate its expectation let through.
+func (g gates) check(r *runResult) {
+	if !r.passed() {
+		return
+	}
+	for _, s := range g.failOn {
+		if r.status >= s.from && r.status <= s.to {
+			r.failure = fmt.Sprintf("status %d is one -fail-on-status fails on", r.status)
+			return
+		}
+	}
+	if g.maxLatency > 0 && r.elapsed > g.maxLatency {
+		r.failure = fmt.Sprintf("took %s, more than -max-latency %s", r.elapsed.Round(time.Millisecond), g.maxLatency)
+	}
+}
+
+// runCollectionCmd implements `run [-env NAME] [-report FILE] COLLECTION`.
+func runCollectionCmd(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	envFile := fs.String("env-file", defaultEnvFile(), "JSON `file` of named environments and their base URLs")
+	env := fs.String("env", "", "resolve relative URLs against this `environment`'s base URL")
+	report := fs.String("report", "", "also write the results to this `file`, - for standard output, for CI to read")
+	format := fs.String("report-format", "", "write the -report as `format` junit, tap or json (default from the file's extension)")
+	history := fs.String("history", defaultHistoryFile(), "record every request in this `file`, a database if it ends in .db and JSON lines otherwise; \"\" to keep no history")
+	failOn := fs.String("fail-on-status", "", "fail requests answered with these `statuses`, e.g. 5xx,429, whatever they expect")
+	maxLatency := fs.Duration("max-latency", 0, "fail requests that take longer than this `duration`, e.g. 800ms")
+	maxFailures := fs.Int("max-failures", 0, "let the run pass with up to this `many` failed requests")
+	parallel := fs.Int("parallel", 1, "send up to this `many` requests at once, from different folders")
+	snapshotFile := fs.String("snapshots", "", "compare every answer with its snapshot in this JSON `file`, taking those it lacks")
+	update := fs.Bool("update-snapshots", false, "take every request's snapshot afresh instead of comparing")
+	spec := fs.String("openapi", "", "check every answer against this OpenAPI `spec`, in JSON (default the collection's openapi)")
+	audit := fs.String("audit-log", defaultAuditFile(), "append every request sent to this audit log `file`; \"\" to keep none")
+	var secretProviders secretProviderFlag
+	fs.Var(&secretProviders, "secret-provider", "read variables that are references to secrets of a scheme with a command, as `scheme=command` (repeatable)")
+	cloudToken := fs.String("cloud-token", "", "send each request with a Bearer token of this `kind` from gcloud, az or the metadata server: gcp, gcp-id[=AUDIENCE] or azure[=RESOURCE]")
+	keyLog := fs.String("key-log", os.Getenv("SSLKEYLOGFILE"), "append the TLS session keys to this `file`, for Wireshark to decrypt a capture with (default $SSLKEYLOGFILE)")
+	readOnly := fs.Bool("read-only", false, "send only the GET and HEAD requests, run no hook commands and save no snapshots; the rest fail")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: run [-env NAME] [-report FILE] COLLECTION.json")
+	}
+	g := gates{maxLatency: *maxLatency, maxFailures: *maxFailures}
+	var err error
+	if g.failOn, err = parseStatusRanges(*failOn); err != nil {
+		return err
+	}
+
+	write, err := reportWriter(*report, *format)
+	if err != nil {
+		return err
+	}
+	if *parallel < 1 {
+		return errors.New("-parallel has to be at least 1")
+	}
+	c, err := loadCollection(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	steps, err := planRun(c)
+	if err != nil {
+		return err
+	}
+	if *update && *snapshotFile == "" {
+		return errors.New("-update-snapshots needs -snapshots")
+	}
+	if *update && *readOnly {
+		return fmt.Errorf("-update-snapshots: %w", errReadOnly)
+	}
+	if *snapshotFile != "" {
+		if g.snapshots, err = loadSnapshots(*snapshotFile, *update, c.SnapshotIgnore); err != nil {
+			return err
+		}
+	}
+	if *spec == "" && c.OpenAPI != "" {
+		*spec = filepath.Join(filepath.Dir(fs.Arg(0)), c.OpenAPI)
+	}
+	if *spec != "" {
+		if g.contract, err = loadOpenAPI(*spec); err != nil {
+			return err
+		}
+	}
+	base := config{envFile: *envFile, env: *env, bodyFormat: "json", requestID: true, historyFile: *history, auditFile: *audit, readOnly: *readOnly, secretProviders: secretProviders}
+	base.transport.keyLog = *keyLog
+	if *cloudToken != "" {
+		if base.cloudToken, err = parseCloudToken(*cloudToken); err != nil {
+			return err
+		}
+	}
+	if base.envs, err = loadEnvironments(base.envFile); err != nil {
+		return err
+	}
+	if _, ok := base.envs[base.env]; base.env != "" && !ok {
+		return fmt.Errorf("no environment %q in %s", base.env, base.envFile)
+	}
+
+	// A report on standard output leaves the progress to standard error.
+	progress := os.Stdout
+	if *report == "-" {
+		progress = os.Stderr
+	}
+	run := collectionRun{name: c.Name, started: time.Now()}
+	if isTerminal(progress) {
+		run.results, err = runLive(base, steps, g, *parallel, progress)
+		if err != nil {
+			return err
+		}
+	} else {
+		run.results = runSteps(base, steps, g, *parallel, printProgress(progress))
+	}
+	run.elapsed = time.Since(run.started)
+	fmt.Fprintf(progress, "\n%d passed, %d failed in %s", len(run.results)-run.failed(), run.failed(), run.elapsed.Round(time.Millisecond))
+	if n := run.warnings(); n > 0 {
+		fmt.Fprintf(progress, ", with %d warnings", n)
+	}
+	fmt.Fprintln(progress)
+	switch s := g.snapshots; {
+	case s == nil || s.taken == 0:
+	case *readOnly:
+		fmt.Fprintf(progress, "Took %d snapshots, not saved in read-only mode\n", s.taken)
+	default:
+		if err := s.save(); err != nil {
+			return fmt.Errorf("saving the snapshots: %w", err)
+		}
+		fmt.Fprintf(progress, "Took %d snapshots, saved in %s\n", s.taken, s.path)
+	}
+	if write != nil {
+		if err := write(run); err != nil {
+			return fmt.Errorf("writing the report: %w", err)
+		}
+	}
+	if n := run.failed(); n > g.maxFailures {
+		return fmt.Errorf("%d of %d requests failed", n, len(run.results))
+	}
+	return nil
+}
+
+// sendSaved sends one saved request and checks its answer. Like any other
+// request, it goes into the history.
+func sendSaved(base config, req collectionRequest) runResult {
+	r := runResult{name: req.label(), method: req.method(), url: req.URL}
+
+	cfg := base
+	cfg.method, cfg.path = r.method, req.URL
+	target, err := resolveURL(base.envs[base.env], req.URL)
+	if err == nil {
+		target, err = normalizeURL(target)
+	}
+	if err != nil {
+		r.failure = err.Error()
+		return r
+	}
+	cfg.url, r.url = target, target
+	cfg.header = http.Header{}
+	for k, v := range req.Headers {
+		cfg.header.Set(k, v)
+	}
+	if req.Body != "" {
+		cfg.body = []byte(req.Body)
+	}
+
+	cfg = withRequestID(cfg)
+	r.requestID = cfg.header.Get("X-Request-ID")
+	start := time.Now()
+	msg := send(cfg)
+	r.elapsed = time.Since(start)
+	_ = recordHistory(cfg, msg, r.elapsed)
+
+	switch msg := msg.(type) {
+	case responseMsg:
+		if msg.stopStream != nil {
+			msg.stopStream()
+		}
+		r.status, r.body = msg.status, msg.body
+		r.contentType = msg.header.Get("Content-Type")
+		r.failure = req.Expect.check(response(msg))
+	case errMsg:
+		r.failure = msg.err.Error()
+	default:
+		r.failure = "only HTTP requests can be run from a collection"
+	}
+	return r
+}
+
+// runScript runs the command of a hook, in the current directory, with the
+// environment's base URL and the folder's name in HTTPWIZARD_BASE_URL and
+// HTTPWIZARD_FOLDER. It fails if the command exits with an error.
+func runScript(base config, s runStep) runResult {
+	r := runResult{url: strings.Join(s.script, " ")}
+	if base.readOnly {
+		r.failure = "read-only mode: commands are not run"
+		return r
+	}
+	cmd := exec.Command(s.script[0], s.script[1:]...)
+	cmd.Env = append(os.Environ(),
+		"HTTPWIZARD_BASE_URL="+base.envs[base.env].Base,
+		"HTTPWIZARD_FOLDER="+s.folder,
+	)
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	r.elapsed = time.Since(start)
+	if err != nil {
+		r.failure = err.Error()
+		// The last thing the command said is usually why.
+		lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+		if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+			r.failure += ": " + last
+		}
+	}
+	return r
+}
+
+// check says how res falls short of e, or "" if it doesn't.
+func (e expectation) check(res response) string {
+	switch {
+	case e.Status != 0 && res.status != e.Status:
+		return fmt.Sprintf("expected status %d, got %d", e.Status, res.status)
+	case e.Status == 0 && (res.status < 200 || res.status > 299):
+		return fmt.Sprintf("expected a 2xx status, got %d", res.status)
+	case e.BodyContains != "" && !strings.Contains(string(res.body), e.BodyContains):
+		return fmt.Sprintf("the body doesn't contain %q", e.BodyContains)
+	case e.schema != nil:
+		if violations := e.schema.validateBody(res.body); len(violations) > 0 {
+			return "the body breaks its schema: " + describeViolations(violations, "; ")
+		}
+	}
+	return ""
+}
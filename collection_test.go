@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// petStore answers the requests of testCollection.
+func petStore() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/pets":
+			w.Write([]byte(`[{"name": "Rex"}]`))
+		case r.Method == http.MethodPost && r.URL.Path == "/pets":
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+const testCollection = `{
+  "name": "Pet store",
+  "requests": [
+    {"name": "List pets", "url": "/pets", "expect": {"body_contains": "Rex"}},
+    {"name": "Add a pet", "url": "/pets", "body": "{}", "expect": {"status": 201}},
+    {"url": "/cats"}
+  ]
+}`
+
+func TestRunCollection(t *testing.T) {
+	srv := petStore()
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "pets.json")
+	os.WriteFile(path, []byte(testCollection), 0o644)
+	c, err := loadCollection(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps, err := planRun(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := config{envs: map[string]environment{"test": {Base: srv.URL}}, env: "test", bodyFormat: "json"}
+	var out strings.Builder
+	run := collectionRun{results: runSteps(base, steps, gates{}, 1, printProgress(&out))}
+	if len(run.results) != 3 || run.failed() != 1 {
+		t.Fatalf("results = %+v", run.results)
+	}
+	if r := run.results[1]; r.method != http.MethodPost || r.status != 201 || !r.passed() {
+		t.Errorf("a body should make it a POST: %+v", r)
+	}
+	if r := run.results[2]; r.name != "GET /cats" || r.failure != "expected a 2xx status, got 404" {
+		t.Errorf("unnamed request = %+v", r)
+	}
+	for _, want := range []string{"✓ List pets  GET " + srv.URL + "/pets → 200", "✗ GET /cats"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestExpectation(t *testing.T) {
+	res := response{status: 200, body: []byte("hello")}
+	for _, c := range []struct {
+		e    expectation
+		want string
+	}{
+		{expectation{}, ""},
+		{expectation{Status: 200, BodyContains: "ell"}, ""},
+		{expectation{Status: 204}, "expected status 204, got 200"},
+		{expectation{BodyContains: "bye"}, `the body doesn't contain "bye"`},
+	} {
+		if got := c.e.check(res); got != c.want {
+			t.Errorf("%+v: %q, want %q", c.e, got, c.want)
+		}
+	}
+}
+
+func TestLoadCollectionErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"empty.json": `{"requests": []}`,
+		"nourl.json": `{"requests": [{"name": "x"}]}`,
+		"bad.json":   `{`,
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(body), 0o644)
+		if _, err := loadCollection(path); err == nil {
+			t.Errorf("%s was taken", name)
+		}
+	}
+}
+
+func TestGates(t *testing.T) {
+	ranges, err := parseStatusRanges("5xx, 429")
+	if err != nil || len(ranges) != 2 || ranges[0] != (statusRange{500, 599}) || ranges[1] != (statusRange{429, 429}) {
+		t.Fatalf("parseStatusRanges = %v, %v", ranges, err)
+	}
+	for _, bad := range []string{"6xx", "abc", "99"} {
+		if _, err := parseStatusRanges(bad); err == nil {
+			t.Errorf("%q was taken", bad)
+		}
+	}
+
+	g := gates{failOn: ranges, maxLatency: 800 * time.Millisecond}
+	for _, c := range []struct {
+		r    runResult
+		want string
+	}{
+		{runResult{status: 200, elapsed: time.Second}, "took 1s, more than -max-latency 800ms"},
+		{runResult{status: 503}, "status 503 is one -fail-on-status fails on"},
+		{runResult{status: 200, elapsed: time.Millisecond}, ""},
+		{runResult{failure: "refused"}, "refused"}, // Already failed, for its own reason.
+	} {
+		g.check(&c.r)
+		if c.r.failure != c.want {
+			t.Errorf("failure = %q, want %q", c.r.failure, c.want)
+		}
+	}
+}
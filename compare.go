@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxCompareChanges caps how many body differences a comparison lists.
+const maxCompareChanges = 20
+
+// envAnswer is how one environment answered the request.
+type envAnswer struct {
+	env     string
+	url     string
+	res     response
+	err     error
+	elapsed time.Duration
+}
+
+// runCompare is the palette's `compare [env-a] env-b [samples]`: it sends
+// the request to two environments at once, the active one if only one
+// other is named, and shows their answers side by side, with how their
+// bodies differ. Given a number of samples, it sends that many to each and
+// compares them as a canary; see canary.go.
+func runCompare(m model, args []string) (tea.Model, tea.Cmd) {
+	samples := 1
+	if len(args) > 1 {
+		if n, err := strconv.Atoi(args[len(args)-1]); err == nil {
+			if n < 2 || n > maxCanarySamples {
+				return m.paletteError(fmt.Errorf("%d is not a number of samples from 2 to %d", n, maxCanarySamples))
+			}
+			samples, args = n, args[:len(args)-1]
+		}
+	}
+	var envs []string
+	switch len(args) {
+	case 1:
+		if m.cfg.env == "" {
+			return m.paletteError(errors.New("no environment is active; name both: compare staging production"))
+		}
+		envs = []string{m.cfg.env, args[0]}
+	case 2:
+		envs = args
+	default:
+		return m.paletteError(errors.New("usage: compare [env-a] env-b [samples], e.g. compare staging production, or compare stable canary 50"))
+	}
+	if isFileTransfer(m.cfg.url) {
+		return m.paletteError(errors.New("only HTTP requests can be compared"))
+	}
+	cfgs := make([]config, len(envs))
+	for i, env := range envs {
+		cfg, err := inEnv(withoutConditions(m.cfg), env)
+		if err != nil {
+			return m.paletteError(err)
+		}
+		cfgs[i] = cfg
+	}
+	start := func(m model) (tea.Model, tea.Cmd) {
+		if samples > 1 {
+			m.job = fmt.Sprintf("Sampling %s and %s", envs[0], envs[1])
+			return m, runJob(func(report func(string)) tea.Msg {
+				a, b := runCanary(cfgs[0], cfgs[1], samples, report)
+				return reportMsg{title: fmt.Sprintf("Canary: %s %s, %d samples each", m.cfg.method, m.cfg.path, samples), body: describeCanary(a, b)}
+			})
+		}
+		m.job = fmt.Sprintf("Sending to %s and %s", envs[0], envs[1])
+		return m, runJob(func(func(string)) tea.Msg {
+			a, b := compareEnvs(cfgs[0], cfgs[1])
+			return reportMsg{title: fmt.Sprintf("%s %s in %s and %s", m.cfg.method, m.cfg.path, a.env, b.env), body: describeComparison(a, b)}
+		})
+	}
+	// Each environment that is protected is confirmed in turn, its host
+	// once, before anything is sent to either.
+	run := start
+	asked := map[string]bool{}
+	for i := len(cfgs) - 1; i >= 0; i-- {
+		host, ok := protectedHost(cfgs[i])
+		if !ok || asked[host] {
+			continue
+		}
+		asked[host] = true
+		next, what := run, fmt.Sprintf("compare of %s %s in %s", cfgs[i].method, cfgs[i].url, envs[i])
+		if samples > 1 {
+			what += fmt.Sprintf(", %d times", samples)
+		}
+		c := cfgs[i]
+		run = func(m model) (tea.Model, tea.Cmd) { return m.guarded(c, what, next) }
+	}
+	return run(m)
+}
+
+// compareEnvs sends the requests described by a and b at the same time.
+func compareEnvs(a, b config) (envAnswer, envAnswer) {
+	answers := make([]envAnswer, 2)
+	var wg sync.WaitGroup
+	for i, cfg := range []config{a, b} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			msg := checkServer(cfg)()
+			answers[i] = envAnswer{env: cfg.env, url: cfg.url, elapsed: time.Since(start)}
+			switch msg := msg.(type) {
+			case responseMsg:
+				answers[i].res = response(msg)
+			case errMsg:
+				answers[i].err = msg.err
+			}
+		}()
+	}
+	wg.Wait()
+	return answers[0], answers[1]
+}
+
+// describeComparison lays two answers out side by side, marking with ≠ what
+// differs, then lists how the second's body differs from the first's.
+func describeComparison(a, b envAnswer) string {
+	rows := [][3]string{
+		{"", a.env, b.env},
+		{"URL", a.url, b.url},
+		{"Status", a.status(), b.status()},
+		{"Time", a.elapsed.Round(time.Millisecond).String(), fmt.Sprintf("%s (%+dms)", b.elapsed.Round(time.Millisecond), (b.elapsed - a.elapsed).Milliseconds())},
+	}
+	if a.err == nil && b.err == nil {
+		rows = append(rows,
+			[3]string{"Size", formatSize(int64(len(a.res.body))), formatSize(int64(len(b.res.body)))},
+			[3]string{"Content-Type", a.res.header.Get("Content-Type"), b.res.header.Get("Content-Type")},
+		)
+	}
+	width := [2]int{}
+	for _, r := range rows {
+		width[0], width[1] = max(width[0], len(r[0])), max(width[1], len([]rune(r[1])))
+	}
+	var s strings.Builder
+	for i, r := range rows {
+		mark := ""
+		// The URLs and times always differ; only the answers are marked.
+		if i > 1 && r[0] != "Time" && r[1] != r[2] {
+			mark = "  ≠"
+		}
+		fmt.Fprintf(&s, "%-*s  %-*s  %s%s\n", width[0], r[0], width[1], r[1], r[2], mark)
+	}
+	if a.err != nil || b.err != nil {
+		return strings.TrimRight(s.String(), "\n")
+	}
+
+	// The statuses are compared above; only the bodies are left.
+	changes := diffJSON(nil, "$", takeSnapshot(0, a.res.jsonBody(), nil).Body, takeSnapshot(0, b.res.jsonBody(), nil).Body)
+	if len(changes) == 0 {
+		return s.String() + "\n✓ The bodies are the same"
+	}
+	fmt.Fprintf(&s, "\n%d body differences, %s → %s:", len(changes), a.env, b.env)
+	for _, c := range changes[:min(len(changes), maxCompareChanges)] {
+		s.WriteString("\n  " + c)
+	}
+	if len(changes) > maxCompareChanges {
+		fmt.Fprintf(&s, "\n  … and %d more", len(changes)-maxCompareChanges)
+	}
+	return s.String()
+}
+
+// status sums up how the environment answered, or why it didn't.
+func (e envAnswer) status() string {
+	if e.err != nil {
+		return "✗ " + e.err.Error()
+	}
+	return fmt.Sprintf("%d %s", e.res.status, http.StatusText(e.res.status))
+}
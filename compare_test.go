@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompareEnvs(t *testing.T) {
+	staging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version": "1.3", "items": [1, 2], "region": "eu"}`))
+	}))
+	defer staging.Close()
+	production := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"version": "1.2", "items": [1], "region": "eu"}`))
+	}))
+	defer production.Close()
+
+	m := model{cfg: config{
+		method: "GET", url: staging.URL + "/status", path: "/status", header: http.Header{},
+		env:  "staging",
+		envs: map[string]environment{"staging": {Base: staging.URL}, "production": {Base: production.URL}},
+	}}
+	next, cmd := runCompare(m, []string{"production"})
+	if cmd == nil {
+		t.Fatalf("compare: %+v", next.(model).report)
+	}
+	report, ok := cmd().(reportMsg)
+	if !ok {
+		t.Fatal("compare didn't report")
+	}
+	for _, want := range []string{
+		"URL           " + staging.URL + "/status",
+		"Status        200 OK",
+		"Content-Type  application/json",
+		"2 body differences, staging → production:",
+		`$.items[1] removed`,
+		`$.version: "1.3" → "1.2"`,
+	} {
+		if !strings.Contains(report.body, want) {
+			t.Errorf("comparison lacks %q:\n%s", want, report.body)
+		}
+	}
+	if strings.Contains(report.body, "Status        200 OK                       200 OK  ≠") {
+		t.Errorf("equal statuses marked as different:\n%s", report.body)
+	}
+
+	if next, cmd := runCompare(m, []string{"nowhere"}); cmd != nil || !strings.Contains(next.(model).report.body, `no environment "nowhere"`) {
+		t.Error("comparing with an unknown environment")
+	}
+}
+
+func TestDescribeComparisonError(t *testing.T) {
+	a := envAnswer{env: "a", url: "http://a/", res: response{status: 200}}
+	b := envAnswer{env: "b", url: "http://b/", err: errors.New("boom")}
+	got := describeComparison(a, b)
+	if !strings.Contains(got, "✗ boom  ≠") || strings.Contains(got, "body differences") {
+		t.Errorf("comparison with a failure:\n%s", got)
+	}
+}
+
+func TestCompareConfirmsProtected(t *testing.T) {
+	sent := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { sent++ }))
+	defer srv.Close()
+	m := model{cfg: config{
+		method: "DELETE", url: srv.URL + "/users/7", path: "/users/7", header: http.Header{},
+		envs: map[string]environment{"dev": {Base: srv.URL}, "prod": {Base: "http://localhost:1", Protected: true}},
+	}}
+	next, cmd := runCompare(m, []string{"dev", "prod", "50"})
+	c := next.(model).confirm
+	if cmd != nil || c == nil || c.host != "localhost" || !strings.Contains(c.what, "in prod, 50 times") {
+		t.Fatalf("compare into prod wasn't held back: confirm %+v", c)
+	}
+	if sent != 0 {
+		t.Errorf("%d requests went before confirming", sent)
+	}
+}
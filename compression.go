@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The transport asks for gzip on its own and takes the encoding off before
+// we see the body, so a response never says how big it was on the wire.
+// The compression check asks for the encodings itself instead, which makes
+// the transport hand the body over as it came, and decodes it here.
+
+// compressionEncodings is what the compression check offers: the encodings
+// it can decode itself.
+const compressionEncodings = "gzip, deflate"
+
+// minCompressible is the size below which a body left uncompressed is no
+// loss: the encoding's own framing eats most of what it would save.
+const minCompressible = 1024
+
+// compressionCheck is how big a response was as sent and as decoded.
+type compressionCheck struct {
+	status      int
+	contentType string
+	encoding    string // Content-Encoding, "" for none.
+	declared    int64  // Content-Length, -1 if the server didn't send one.
+	wire        int64  // Bytes of body received.
+	decoded     int64  // Bytes after decoding, -1 for an encoding we can't decode.
+	gzipped     int64  // What gzip makes of an uncompressed body, -1 when not tried.
+}
+
+// runCompression is the palette's `compression`: it sends the request
+// again, offering gzip and deflate, and reports the body's size on the wire
+// and decoded, the compression ratio, and whether a body that would have
+// compressed well was sent as it is.
+func runCompression(m model, _ []string) (tea.Model, tea.Cmd) {
+	if isFileTransfer(m.cfg.url) {
+		return m.paletteError(errors.New("only HTTP answers are compressed"))
+	}
+	if m.cfg.method == http.MethodHead {
+		return m.paletteError(errors.New("a HEAD answer has no body to weigh"))
+	}
+	cfg := withoutConditions(m.cfg)
+	m.job = "Weighing the body of " + cfg.method + " " + cfg.path
+	return m, runJob(func(func(string)) tea.Msg {
+		c, err := checkCompression(cfg)
+		if err != nil {
+			return reportMsg{title: "Compression", body: err.Error()}
+		}
+		return reportMsg{title: "Compression: " + cfg.method + " " + cfg.path, body: c.describe()}
+	})
+}
+
+// checkCompression sends the request described by cfg and weighs the body
+// of the answer.
+func checkCompression(cfg config) (*compressionCheck, error) {
+	if err := readOnlyBlocks(cfg); err != nil {
+		return nil, err
+	}
+	cfg, err := expandRequest(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg, err = encodeBody(cfg); err != nil {
+		return nil, err
+	}
+	req, _, err := newRequest(cfg)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", compressionEncodings)
+	res, err := newClient(cfg).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(res.Body, maxBody))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &compressionCheck{
+		status:      res.StatusCode,
+		contentType: res.Header.Get("Content-Type"),
+		encoding:    strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding"))),
+		declared:    res.ContentLength,
+		wire:        int64(len(raw)),
+		gzipped:     -1,
+	}
+	c.decoded = decodedSize(c.encoding, raw)
+	if c.encoding == "" || c.encoding == "identity" {
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		w.Write(raw)
+		w.Close()
+		c.gzipped = int64(b.Len())
+	}
+	return c, nil
+}
+
+// decodedSize counts the bytes body decodes to under encoding, or returns
+// -1 if it is an encoding we don't decode, or doesn't decode.
+func decodedSize(encoding string, body []byte) int64 {
+	var r io.Reader
+	switch encoding {
+	case "", "identity":
+		return int64(len(body))
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return -1
+		}
+		r = zr
+	case "deflate":
+		// HTTP's deflate is zlib-wrapped, but some servers send it raw.
+		if zr, err := zlib.NewReader(bytes.NewReader(body)); err == nil {
+			r = zr
+		} else {
+			r = flate.NewReader(bytes.NewReader(body))
+		}
+	default:
+		return -1
+	}
+	n, err := io.Copy(io.Discard, io.LimitReader(r, maxBody))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// compressible reports whether a body of contentType is text that gzip
+// shrinks, as opposed to images, archives and the like that come
+// compressed already.
+func compressible(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(t, "text/"), strings.HasSuffix(t, "+json"), strings.HasSuffix(t, "+xml"):
+		return true
+	}
+	switch t {
+	case "application/json", "application/xml", "application/javascript", "application/x-ndjson", "application/graphql-response+json":
+		return true
+	}
+	return false
+}
+
+// describe lays out the sizes, the ratio, and any warnings.
+func (c *compressionCheck) describe() string {
+	var b strings.Builder
+	row := func(label, value string) { fmt.Fprintf(&b, "%-17s %s\n", label+":", value) }
+	row("Status", fmt.Sprintf("%d %s", c.status, http.StatusText(c.status)))
+	row("Content-Type", orNone(c.contentType))
+	encoding := c.encoding
+	if encoding == "" {
+		encoding = "none"
+	}
+	row("Content-Encoding", encoding)
+	declared := "(not sent)"
+	if c.declared >= 0 {
+		declared = sizeOf(c.declared)
+	}
+	row("Content-Length", declared)
+	row("On the wire", sizeOf(c.wire))
+	if c.decoded < 0 {
+		row("Decoded", "can't tell; "+encoding+" isn't decoded here")
+	} else {
+		row("Decoded", sizeOf(c.decoded))
+	}
+	if c.decoded > 0 && c.wire > 0 && c.decoded != c.wire {
+		row("Ratio", fmt.Sprintf("%.1f:1, %.0f%% saved", float64(c.decoded)/float64(c.wire), 100*(1-float64(c.wire)/float64(c.decoded))))
+	}
+
+	var warnings []string
+	if c.declared >= 0 && c.declared != c.wire {
+		warnings = append(warnings, fmt.Sprintf("Content-Length says %s, but %s arrived", sizeOf(c.declared), sizeOf(c.wire)))
+	}
+	if c.gzipped >= 0 && compressible(c.contentType) && c.wire >= minCompressible {
+		warnings = append(warnings, fmt.Sprintf("The body was sent uncompressed, though %s was offered; gzip makes it %s, %.0f%% smaller",
+			compressionEncodings, sizeOf(c.gzipped), 100*(1-float64(c.gzipped)/float64(c.wire))))
+	}
+	if c.gzipped < 0 && c.decoded >= 0 && c.decoded < c.wire {
+		warnings = append(warnings, "Compressing made the body bigger; one this small is better sent as it is")
+	}
+	if c.gzipped < 0 && !compressible(c.contentType) && c.contentType != "" {
+		warnings = append(warnings, c.contentType+" is usually compressed already; encoding it again costs CPU for little gain")
+	}
+	if len(warnings) == 0 {
+		return b.String() + "\n✓ Nothing to improve"
+	}
+	b.WriteString("\n")
+	for _, w := range warnings {
+		b.WriteString("⚠ " + w + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// sizeOf writes a size both ways, e.g. "1.2 kB (1234 B)", a plain count
+// being what Content-Length is compared with.
+func sizeOf(n int64) string {
+	if n < 1024 {
+		return formatSize(n)
+	}
+	return fmt.Sprintf("%s (%d B)", formatSize(n), n)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	page := strings.Repeat(`{"name": "widget", "price": 10},`, 200)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/gzip":
+			if r.Header.Get("Accept-Encoding") != compressionEncodings {
+				t.Errorf("Accept-Encoding = %q", r.Header.Get("Accept-Encoding"))
+			}
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			zw.Write([]byte(page))
+			zw.Close()
+		case "/plain":
+			w.Header().Set("Content-Length", strconv.Itoa(len(page)))
+			w.Write([]byte(page))
+		case "/small":
+			w.Write([]byte(`{"ok": true}`))
+		}
+	}))
+	defer srv.Close()
+
+	c, err := checkCompression(config{method: "GET", url: srv.URL + "/gzip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.encoding != "gzip" || c.decoded != int64(len(page)) || c.wire >= c.decoded || c.gzipped != -1 {
+		t.Errorf("gzip: %+v", c)
+	}
+	out := c.describe()
+	for _, want := range []string{"Content-Encoding: gzip", "Decoded:          6.2 kB (6400 B)", "Ratio:            ", "✓ Nothing to improve"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("gzip report lacks %q:\n%s", want, out)
+		}
+	}
+
+	c, err = checkCompression(config{method: "GET", url: srv.URL + "/plain"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = c.describe()
+	for _, want := range []string{"Content-Encoding: none", "Content-Length:   6.2 kB (6400 B)", "⚠ The body was sent uncompressed, though gzip, deflate was offered"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plain report lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Ratio") {
+		t.Errorf("plain report has a ratio:\n%s", out)
+	}
+
+	// A small body isn't worth compressing.
+	c, err = checkCompression(config{method: "GET", url: srv.URL + "/small"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := c.describe(); !strings.Contains(out, "✓ Nothing to improve") {
+		t.Errorf("small report:\n%s", out)
+	}
+}
+
+func TestDecodedSize(t *testing.T) {
+	if n := decodedSize("br", []byte("x")); n != -1 {
+		t.Errorf("br = %d", n)
+	}
+	if n := decodedSize("gzip", []byte("not gzip")); n != -1 {
+		t.Errorf("bad gzip = %d", n)
+	}
+	for _, tc := range []struct {
+		contentType string
+		want        bool
+	}{
+		{"application/json; charset=utf-8", true},
+		{"application/problem+json", true},
+		{"text/html", true},
+		{"image/png", false},
+		{"", false},
+	} {
+		if got := compressible(tc.contentType); got != tc.want {
+			t.Errorf("compressible(%q) = %v", tc.contentType, got)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// conditional describes one of the conditional request headers we can fill
+// in from a validator (ETag or Last-Modified) on the previous response.
+type conditional struct {
+	key       string // Key that resends the request with this condition.
+	header    string // Request header to set, e.g. If-None-Match.
+	validator string // Response header whose value it carries, e.g. ETag.
+}
+
+// conditionals lists the conditions on offer, in the order they are shown.
+var conditionals = []conditional{
+	{key: "n", header: "If-None-Match", validator: "ETag"},
+	{key: "m", header: "If-Match", validator: "ETag"},
+	{key: "s", header: "If-Modified-Since", validator: "Last-Modified"},
+}
+
+// conditionalHeaders are stripped before a new condition is applied, so
+// pressing one key after another never stacks conflicting preconditions.
+var conditionalHeaders = []string{
+	"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "If-Range",
+}
+
+// available returns the conditions the previous response has validators for.
+func available(prev http.Header) []conditional {
+	var out []conditional
+	for _, c := range conditionals {
+		if prev.Get(c.validator) != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// withConditional returns a copy of cfg whose headers carry condition c,
+// built from the validators in prev. The original cfg is left untouched.
+func withConditional(cfg config, prev http.Header, c conditional) config {
+	next := withoutConditions(cfg)
+	next.header.Set(c.header, prev.Get(c.validator))
+	return next
+}
+
+// withoutConditions returns a copy of cfg with every conditional header
+// removed, for when the request moves to a different resource.
+func withoutConditions(cfg config) config {
+	cfg.header = cfg.header.Clone()
+	for _, h := range conditionalHeaders {
+		cfg.header.Del(h)
+	}
+	return cfg
+}
+
+// explain interprets the status code returned for a request sent with
+// condition c, in the vocabulary of caching and optimistic concurrency.
+func (c conditional) explain(status int) string {
+	switch {
+	case status == http.StatusNotModified:
+		return "unchanged, the cached copy is still good"
+	case status == http.StatusPreconditionFailed:
+		return "precondition failed, the resource has changed"
+	case status >= 200 && status < 300 && c.header == "If-Match":
+		return "still matches, the request was applied"
+	case status >= 200 && status < 300:
+		return "changed, the server sent a fresh copy"
+	default:
+		return fmt.Sprintf("server answered %d without evaluating the condition", status)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAvailable(t *testing.T) {
+	prev := http.Header{"Etag": {`"v1"`}}
+	var keys string
+	for _, c := range available(prev) {
+		keys += c.key
+	}
+	if keys != "nm" {
+		t.Errorf("with an ETag, keys = %q, want nm", keys)
+	}
+	prev.Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	if n := len(available(prev)); n != 3 {
+		t.Errorf("with both validators, %d conditions, want 3", n)
+	}
+	if n := len(available(http.Header{})); n != 0 {
+		t.Errorf("without validators, %d conditions, want 0", n)
+	}
+}
+
+func TestWithConditional(t *testing.T) {
+	prev := http.Header{"Etag": {`"v2"`}, "Last-Modified": {"Mon, 02 Jan 2006 15:04:05 GMT"}}
+	cfg := config{header: http.Header{"If-Match": {`"old"`}, "Accept": {"*/*"}}}
+
+	next := withConditional(cfg, prev, conditionals[0])
+	if got := next.header.Get("If-None-Match"); got != `"v2"` {
+		t.Errorf("If-None-Match = %q", got)
+	}
+	if next.header.Get("If-Match") != "" || next.header.Get("Accept") != "*/*" {
+		t.Errorf("headers = %v, want only the new condition added", next.header)
+	}
+	if cfg.header.Get("If-Match") != `"old"` || cfg.header.Get("If-None-Match") != "" {
+		t.Errorf("original headers changed to %v", cfg.header)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	ifMatch, ifNoneMatch := conditionals[1], conditionals[0]
+	tests := []struct {
+		c      conditional
+		status int
+		want   string
+	}{
+		{ifNoneMatch, 304, "unchanged, the cached copy is still good"},
+		{ifMatch, 412, "precondition failed, the resource has changed"},
+		{ifMatch, 204, "still matches, the request was applied"},
+		{ifNoneMatch, 200, "changed, the server sent a fresh copy"},
+		{ifNoneMatch, 500, "server answered 500 without evaluating the condition"},
+	}
+	for _, tt := range tests {
+		if got := tt.c.explain(tt.status); got != tt.want {
+			t.Errorf("%s explain(%d) = %q, want %q", tt.c.header, tt.status, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// defaultURL is the remote address we check when none is given on the command line.
+const defaultURL = "https://charm.sh/"
+
+// config holds everything the user asked for on the command line: which URL
+// to hit, how to build the request, and which optional behaviours to switch on.
+type config struct {
+	url    string      // The remote address we want to check.
+	path   string      // The URL as given, possibly relative to the environment's base.
+	method string      // HTTP method to send, e.g. GET or POST.
+	header http.Header // Extra request headers given with -H.
+	body   []byte      // Request body given with -d, if any.
+
+	uaProfile string // The client whose headers the request goes with, one of uaProfiles; "" for Go's.
+
+	// expectContinue asks the server for permission before uploading bodies
+	// of at least expectContinueMin bytes, so a rejected upload costs nothing.
+	expectContinue    bool
+	expectContinueMin int64
+
+	byteRange string // Byte ranges to ask for, e.g. "0-1023" or "0-99,-100".
+	output    string // File to write the response body to, if any.
+	resume    bool   // Continue a partial download of output instead of starting over.
+
+	// origin turns on the CORS simulator: the request is checked as if a
+	// page on this origin made it, with credentials if asked to.
+	origin      string
+	credentials bool
+
+	crawlDepth int    // How many links deep the crawl action follows the site.
+	dnsServer  string // DNS server the lookup pane asks, host[:port]; empty for the system's.
+
+	transport transportOptions // Connection pool settings.
+	plugins   []string         // Middlewares given with -plugin, built-in names or command lines.
+	requestID bool             // Send every request with a new X-Request-ID.
+	madeID    bool             // The header's X-Request-ID is withRequestID's, not one given with -H.
+
+	// cloudToken is the kind of token from a cloud's identity service that
+	// -cloud-token sends each request with; its kind is "" for none.
+	cloudToken cloudTokenSpec
+
+	// secretProviders are the commands given with -secret-provider, by the
+	// scheme of the secret references they read.
+	secretProviders secretProviderFlag
+
+	// otlpEndpoint is the OTLP/HTTP collector the traceparent middleware
+	// exports spans to, and traceLink the tracing UI's URL of a trace,
+	// with {trace} for its ID. Either turns the middleware on.
+	otlpEndpoint string
+	traceLink    string
+
+	ext       *extensions  // What the Starlark extensions file registered; nil for none.
+	renderers []renderRule // Renderers given with -render, by content type.
+
+	// proto holds the message types from -proto. Request bodies are written
+	// as JSON and sent as protoRequest; protobuf responses are shown as
+	// JSON, decoded as protoResponse unless their Content-Type names a type.
+	proto         *protoTypes
+	protoRequest  string
+	protoResponse string
+
+	// bodyFormat is what JSON request bodies are encoded as before they
+	// are sent: json for as written, or one of bodyFormats.
+	bodyFormat string
+
+	// soapVersion turns on SOAP mode: the body is wrapped in an envelope of
+	// that version, with soapHeader in its Header, and sent with soapAction.
+	soapVersion string
+	soapAction  string
+	soapHeader  []byte
+
+	envFile string                 // Where the environments were loaded from.
+	envs    map[string]environment // Every environment in envFile.
+	env     string                 // Name of the active environment, if any.
+
+	historyFile      string // Where every request is recorded, in a database if it ends in .db, one JSON line each otherwise; "" for nowhere.
+	historyRetention historyRetention
+	sloFile          string // Where the requests' SLOs are kept.
+	favoritesFile    string // Where the starred requests are kept; "" for nowhere.
+	trashFile        string // Where unstarred requests and deleted sessions go; "" to delete them outright.
+
+	// metrics counts the requests of watch and load mode, served on
+	// metricsAddr or written to a file; nil when neither was asked for.
+	metrics     *metrics
+	metricsAddr string
+
+	schema *jsonSchema // What JSON bodies are checked against, given with -schema; nil for nothing.
+
+	// offline answers requests from the answers kept in offlineFile instead
+	// of the network. Live answers are saved to it only once keepOffline is
+	// set, by giving -offline-file or -offline, or using the palette's
+	// offline.
+	offline     bool
+	offlineFile string
+	keepOffline bool
+
+	protect []string // Host patterns, e.g. *.prod.example.com, that changes to need confirming.
+
+	// readOnly sends nothing but GET and HEAD requests, and saves no SLOs
+	// or snapshots, for demos and for those who should only look.
+	readOnly bool
+
+	// auditFile is the audit log every request sent is appended to, "" for
+	// none, with the request's body if auditBodies is set.
+	auditFile   string
+	auditBodies bool
+
+	// shareURL is the shared server the environments are kept in step
+	// with, and share the connection to it; nil when not sharing.
+	shareURL string
+	share    *shareClient
+
+	bundle *bundle // The signed bundle the request came from, given with -bundle; nil for none.
+
+	// vars are the variables given with -var, and captured those taken
+	// from answers since; see variables.go.
+	vars     map[string]string
+	captured map[string]string
+
+	// sessionFile keeps the open tabs from one run to the next, "" for
+	// not at all; fresh starts without the last run's.
+	sessionFile string
+	fresh       bool
+}
+
+// headerFlag collects repeated -H "Key: value" flags into an http.Header.
+type headerFlag http.Header
+
+// String implements flag.Value. It renders the headers one per line.
+func (h headerFlag) String() string {
+	var b strings.Builder
+	for key, values := range h {
+		for _, v := range values {
+			fmt.Fprintf(&b, "%s: %s\n", key, v)
+		}
+	}
+	return b.String()
+}
+
+// Set implements flag.Value. It parses a single "Key: value" pair.
+func (h headerFlag) Set(v string) error {
+	key, value, ok := strings.Cut(v, ":")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("header %q is not in \"Key: value\" form", v)
+	}
+	http.Header(h).Add(strings.TrimSpace(key), strings.TrimSpace(value))
+	return nil
+}
+
+// listFlag collects the values of a flag that may be given more than once.
+type listFlag []string
+
+// String implements flag.Value.
+func (l *listFlag) String() string { return strings.Join(*l, ", ") }
+
+// Set implements flag.Value. It appends one value.
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// parseFlags reads the command line into a config. The URL is the first
+// positional argument and falls back to defaultURL.
+func parseFlags() (config, error) {
+	cfg := config{header: http.Header{}, vars: map[string]string{}}
+
+	flag.StringVar(&cfg.method, "X", http.MethodGet, "HTTP `method` to send")
+	flag.Var(headerFlag(cfg.header), "H", "extra request `header` as \"Key: value\" (repeatable)")
+	flag.StringVar(&cfg.uaProfile, "ua", "", "send the headers a browser or crawler sends, as `profile` "+uaProfileNames())
+	data := flag.String("d", "", "request `body`; use @file to read it from a file")
+	flag.BoolVar(&cfg.expectContinue, "expect-continue", false, "send Expect: 100-continue before uploading large bodies")
+	flag.Int64Var(&cfg.expectContinueMin, "expect-continue-min", 1<<20, "smallest body, in `bytes`, that -expect-continue applies to")
+	flag.StringVar(&cfg.byteRange, "range", "", "request only these byte `ranges`, e.g. 0-1023 or 500-")
+	flag.StringVar(&cfg.output, "o", "", "write the response body to `file`")
+	flag.BoolVar(&cfg.resume, "resume", false, "continue a partial -o download from where it stopped")
+	flag.StringVar(&cfg.origin, "cors-origin", "", "simulate a browser's CORS checks for a page on this `origin`")
+	flag.BoolVar(&cfg.credentials, "cors-credentials", false, "simulate a credentialed CORS request (cookies, auth)")
+	flag.IntVar(&cfg.crawlDepth, "depth", 1, "how many links `deep` the crawl action follows the same host")
+	flag.StringVar(&cfg.dnsServer, "dns-server", "", "DNS `server` the lookup pane asks, as host or host:port (default the system's)")
+	flag.StringVar(&cfg.envFile, "env-file", defaultEnvFile(), "JSON `file` of named environments and their base URLs")
+	flag.StringVar(&cfg.env, "env", "", "resolve relative URLs against this `environment`'s base URL")
+	flag.BoolVar(&cfg.readOnly, "read-only", false, "send only GET and HEAD requests, and save no SLOs, for demos and for looking without touching")
+	flag.Var((*listFlag)(&cfg.protect), "protect", "ask to type the host before sending POST, PUT, PATCH or DELETE to hosts matching this `pattern`, e.g. *.prod.example.com (repeatable)")
+	flag.StringVar(&cfg.historyFile, "history", defaultHistoryFile(), "record every request in this `file`, a database if it ends in .db and JSON lines otherwise; \"\" to keep no history")
+	flag.DurationVar(&cfg.historyRetention.keep, "history-keep", 0, "drop requests from the history once they are this `old`, e.g. 2160h; 0 to keep them however old")
+	flag.IntVar(&cfg.historyRetention.max, "history-max", defaultHistoryMax, "keep at most this `many` requests in a .db history, dropping the oldest")
+	flag.BoolVar(&cfg.offline, "offline", false, "answer requests from the answers kept in -offline-file instead of the network")
+	flag.StringVar(&cfg.offlineFile, "offline-file", defaultOfflineFile(), "keep the latest answer to every request in this JSON `file`, for -offline; answers are kept once this or -offline is given")
+	flag.StringVar(&cfg.auditFile, "audit-log", defaultAuditFile(), "append who sent every request, when, where and with what outcome to this JSON lines `file`; \"\" to keep none")
+	flag.BoolVar(&cfg.auditBodies, "audit-bodies", false, "keep request bodies in the -audit-log too")
+	flag.StringVar(&cfg.shareURL, "share", "", "keep the environments in step with the team's through the share-server at this `URL`, e.g. ws://team-box:7070/sync?token=SECRET")
+	bundleFile := flag.String("bundle", "", "review and send the request in this signed bundle `file`, with your own credentials given with -H")
+	flag.StringVar(&cfg.sessionFile, "session-file", defaultSessionFile(), "keep the open tabs in this JSON `file` on the way out, and open them again on the next start; \"\" to keep none")
+	flag.BoolVar(&cfg.fresh, "fresh", false, "start with just the request on the command line, not the tabs open last time")
+	flag.Var(varFlag(cfg.vars), "var", "set a variable the request uses as {{name}}, as `name=value` (repeatable)")
+	flag.StringVar(&cfg.sloFile, "slo-file", defaultSLOFile(), "JSON `file` of the requests' latency and availability objectives")
+	flag.StringVar(&cfg.favoritesFile, "favorites-file", defaultFavoritesFile(), "JSON `file` of the starred requests ctrl+r offers; \"\" to star none")
+	flag.StringVar(&cfg.trashFile, "trash-file", defaultTrashFile(), "keep unstarred requests and deleted sessions in this JSON `file` until purged; \"\" to delete them outright")
+	flag.IntVar(&cfg.transport.maxIdlePerHost, "max-idle-per-host", http.DefaultMaxIdleConnsPerHost, "idle `connections` to keep open per host")
+	flag.DurationVar(&cfg.transport.idleTimeout, "idle-timeout", defaultIdleTimeout, "how long to keep an idle connection open")
+	flag.BoolVar(&cfg.transport.noCompression, "no-compression", false, "don't ask for gzip; receive bodies as the server sends them")
+	ipv4Only := flag.Bool("4", false, "connect over IPv4 only")
+	ipv6Only := flag.Bool("6", false, "connect over IPv6 only")
+	flag.BoolVar(&cfg.transport.portForward, "port-forward", false, "send requests to NAME.NAMESPACE.svc and NAME.NAMESPACE.pod through kubectl port-forward, opened as they need it")
+	flag.StringVar(&cfg.transport.kubeContext, "kube-context", "", "port-forward into the cluster of this kubectl `context` (default the current one)")
+	flag.StringVar(&cfg.transport.keyLog, "key-log", os.Getenv("SSLKEYLOGFILE"), "append the TLS session keys to this `file`, for Wireshark to decrypt a capture with (default $SSLKEYLOGFILE)")
+	flag.BoolVar(&cfg.transport.noHTTP2, "no-http2", false, "use HTTP/1.1 even when the server offers HTTP/2")
+	flag.DurationVar(&cfg.transport.chaos.latency, "chaos-latency", 0, "hold every request up this long before sending it, to simulate a slow network")
+	flag.DurationVar(&cfg.transport.chaos.jitter, "chaos-jitter", 0, "hold every request up by as much as this more, at random")
+	flag.IntVar(&cfg.transport.chaos.timeouts, "chaos-timeouts", 0, "make this `percent` of requests hang until they time out")
+	flag.IntVar(&cfg.transport.chaos.resets, "chaos-resets", 0, "make this `percent` of requests fail as if the connection were reset")
+	throttleSpeed := flag.String("throttle", "", "cap the connection's speed to a `network`'s, one of "+throttlePresetNames()+", or kbit/s as DOWN or DOWN/UP")
+	flag.BoolVar(&cfg.requestID, "request-id", true, "send every request with a new random X-Request-ID, shown with the response and kept in the history")
+	flag.Var((*listFlag)(&cfg.plugins), "plugin", "run requests through this `middleware`: request-id, traceparent, or a command speaking the plugin protocol (repeatable)")
+	cloudToken := flag.String("cloud-token", "", "send each request with a Bearer token of this `kind` from gcloud, az or the metadata server: gcp, gcp-id[=AUDIENCE] or azure[=RESOURCE]")
+	flag.Var(&cfg.secretProviders, "secret-provider", "read variables that are references to secrets of a scheme with a command, as `scheme=command`, given the reference as its last argument; op, vault and aws-sm are built in (repeatable)")
+	flag.StringVar(&cfg.otlpEndpoint, "otlp-endpoint", "", "export a client span of each request to this OTLP/HTTP collector `URL`, e.g. http://localhost:4318")
+	flag.StringVar(&cfg.traceLink, "trace-link", "", "show a link to each request's trace, this `URL` with {trace} for its ID, e.g. http://localhost:16686/trace/{trace}")
+	flag.Var((*renderFlag)(&cfg.renderers), "render", "show bodies of a content type with a built-in renderer ("+builtinRendererNames()+") or a command, as `type=renderer`, e.g. text/csv=\"column -ts,\" (repeatable)")
+	var protoFiles, protoPaths listFlag
+	flag.Var(&protoFiles, "proto", "register the message types in this .proto `file` or compiled descriptor set (repeatable)")
+	flag.Var(&protoPaths, "proto-path", "look for imported .proto files in this `directory` (repeatable)")
+	flag.StringVar(&cfg.protoRequest, "proto-request", "", "send the JSON body as this protobuf `message` type, e.g. acme.v1.CreateUser")
+	flag.StringVar(&cfg.protoResponse, "proto-response", "", "decode protobuf responses as this `message` type when they don't name one")
+	flag.StringVar(&cfg.bodyFormat, "body-format", "json", "send the JSON body encoded as `format`: "+bodyFormatNames())
+	flag.StringVar(&cfg.soapVersion, "soap", "", "send the body in a SOAP envelope of this `version`, 1.1 or 1.2")
+	flag.StringVar(&cfg.soapAction, "soap-action", "", "SOAPAction `URI` of the operation to call")
+	soapHeader := flag.String("soap-header", "", "`XML` for the envelope's Header; use @file to read it from a file")
+	wsdl := flag.String("wsdl", "", "WSDL `URL or file` to take the endpoint, action and a skeleton body from; see the wsdl subcommand")
+	operation := flag.String("soap-operation", "", "`operation` of the -wsdl to call")
+	flag.StringVar(&cfg.metricsAddr, "metrics-addr", "", "serve Prometheus metrics of watch and load mode on this `address`, e.g. :9464, at /metrics")
+	metricsFile := flag.String("metrics-file", "", "write Prometheus metrics of watch and load mode to this `file`, e.g. for the node exporter's textfile collector")
+	schema := flag.String("schema", "", "check JSON bodies against this JSON Schema `file`, or the schema at file#/pointer in one, e.g. openapi.json#/components/schemas/Pet")
+	extFile := flag.String("extensions", defaultExtensionsFile(), "Starlark `file` of extension commands, functions and renderers")
+	flag.Usage = usage
+	flag.Parse()
+	cfg.keepOffline = cfg.offline || isFlagSet("offline-file")
+
+	var err error
+	if cfg.envs, err = loadEnvironments(cfg.envFile); err != nil {
+		return cfg, err
+	}
+	if cfg.ext, err = loadExtensions(*extFile); err != nil {
+		return cfg, err
+	}
+	if cfg.proto, err = loadProtoTypes(protoFiles, protoPaths); err != nil {
+		return cfg, fmt.Errorf("loading -proto: %w", err)
+	}
+	if _, ok := cfg.envs[cfg.env]; cfg.env != "" && !ok {
+		return cfg, fmt.Errorf("no environment %q in %s", cfg.env, cfg.envFile)
+	}
+	if *schema != "" {
+		if cfg.schema, err = loadSchema(*schema, ""); err != nil {
+			return cfg, fmt.Errorf("-schema: %w", err)
+		}
+	}
+
+	// A WSDL says where to send an operation, and how.
+	var op *soapOperation
+	if *wsdl != "" {
+		if *operation == "" {
+			return cfg, errors.New("-wsdl needs an operation to call, given with -soap-operation")
+		}
+		ops, err := loadWSDL(*wsdl)
+		if err != nil {
+			return cfg, err
+		}
+		found, err := findOperation(ops, *operation, cfg.soapVersion)
+		if err != nil {
+			return cfg, err
+		}
+		op = &found
+		cfg.soapVersion = cmp.Or(cfg.soapVersion, op.version)
+		cfg.soapAction = cmp.Or(cfg.soapAction, op.action)
+	}
+	if cfg.soapVersion != "" && cfg.soapVersion != "1.1" && cfg.soapVersion != "1.2" {
+		return cfg, fmt.Errorf("unknown -soap version %q; use 1.1 or 1.2", cfg.soapVersion)
+	}
+
+	// The URL may be relative to the active environment's base; without an
+	// environment or a URL, we check the default address.
+	cfg.path = flag.Arg(0)
+	if cfg.path == "" && op != nil {
+		cfg.path = op.endpoint
+	}
+	if cfg.path == "" && cfg.env == "" {
+		cfg.path = defaultURL
+	}
+	target, err := resolveURL(cfg.envs[cfg.env], cfg.path)
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.url, err = normalizeURL(target); err != nil {
+		return cfg, err
+	}
+	if u, _ := url.Parse(cfg.url); !u.IsAbs() {
+		return cfg, fmt.Errorf("%q is relative; pick an environment with -env to resolve it", cfg.path)
+	}
+	cfg.method = strings.ToUpper(cfg.method)
+
+	if cfg.body, err = readArg(*data); err != nil {
+		return cfg, fmt.Errorf("reading body: %w", err)
+	}
+	if cfg.soapHeader, err = readArg(*soapHeader); err != nil {
+		return cfg, fmt.Errorf("reading -soap-header: %w", err)
+	}
+	if cfg.body == nil && op != nil {
+		cfg.body = []byte(op.body)
+	}
+
+	if _, ok := bodyFormats[cfg.bodyFormat]; !ok && cfg.bodyFormat != "json" {
+		return cfg, fmt.Errorf("unknown -body-format %q; use one of %s", cfg.bodyFormat, bodyFormatNames())
+	}
+	if cfg.protoRequest != "" && cfg.bodyFormat != "json" {
+		return cfg, fmt.Errorf("-proto-request and -body-format both say how to encode the body; give one")
+	}
+	if cfg.soapVersion != "" && (cfg.protoRequest != "" || cfg.bodyFormat != "json") {
+		return cfg, fmt.Errorf("-soap bodies are XML; they can't be sent with -proto-request or -body-format")
+	}
+
+	switch {
+	case *ipv4Only && *ipv6Only:
+		return cfg, errors.New("-4 and -6 can't both be given")
+	case *ipv4Only:
+		cfg.transport.family = "4"
+	case *ipv6Only:
+		cfg.transport.family = "6"
+	}
+
+	for _, p := range cfg.protect {
+		if _, err := path.Match(p, ""); err != nil {
+			return cfg, fmt.Errorf("-protect %q: %w", p, err)
+		}
+	}
+	if err := cfg.transport.chaos.validate(); err != nil {
+		return cfg, err
+	}
+	if err := checkUAProfile(cfg.uaProfile); err != nil {
+		return cfg, err
+	}
+	if cfg.transport.throttle, err = parseThrottle(*throttleSpeed); err != nil {
+		return cfg, err
+	}
+	if *cloudToken != "" {
+		if cfg.cloudToken, err = parseCloudToken(*cloudToken); err != nil {
+			return cfg, err
+		}
+	}
+
+	if cfg.metricsAddr != "" || *metricsFile != "" {
+		cfg.metrics = newMetrics(*metricsFile)
+	}
+
+	if cfg.resume && cfg.output == "" {
+		return cfg, fmt.Errorf("-resume needs a file to resume, given with -o")
+	}
+
+	// Like curl, sending a body without naming a method means POST. SOAP
+	// always posts, even an empty Body.
+	if (cfg.body != nil || cfg.soapVersion != "") && !isFlagSet("X") {
+		cfg.method = http.MethodPost
+	}
+
+	// A bundle says what to send; the command line only adds credentials.
+	if *bundleFile != "" {
+		b, err := readBundle(*bundleFile)
+		if err != nil {
+			return cfg, err
+		}
+		if cfg, err = b.apply(cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// readArg returns the value of a flag that takes either text or, with a
+// leading @ as in curl, the name of a file to read it from. An empty
+// value gives nil.
+func readArg(v string) ([]byte, error) {
+	if name, ok := strings.CutPrefix(v, "@"); ok {
+		return os.ReadFile(name)
+	}
+	if v == "" {
+		return nil, nil
+	}
+	return []byte(v), nil
+}
+
+// usage prints the help text for the main mode and lists the subcommands.
+func usage() {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "Usage: %s [flags] [URL]\n", os.Args[0])
+	fmt.Fprintf(w, "       %s <subcommand> [flags] [URL]\n\n", os.Args[0])
+	fmt.Fprintf(w, "Subcommands: %s\n\nFlags:\n", strings.Join(names, ", "))
+	flag.PrintDefaults()
+}
+
+// isFlagSet reports whether the named flag was given on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// destructiveMethods are the methods that change things on the server, and
+// so want confirming before they reach a protected host.
+var destructiveMethods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// confirmation holds a request back until the user types its host, to
+// confirm that it really is meant for production.
+type confirmation struct {
+	host   string
+	what   string // What is about to happen, e.g. "DELETE https://api.example.com/users/7".
+	detail string // More to review before confirming, if anything.
+	input  textinput.Model
+
+	// run sends the request, or runs the command that sends it, once
+	// confirmed.
+	run func(m model) (tea.Model, tea.Cmd)
+}
+
+// protectedHost returns the host of the request, and whether sending it
+// needs confirming: whether it changes things, and goes to a host matching
+// one of the -protect patterns, or through an environment marked protected.
+func protectedHost(cfg config) (string, bool) {
+	if !slices.Contains(destructiveMethods, cfg.method) {
+		return "", false
+	}
+	u, err := url.Parse(cfg.url)
+	if err != nil {
+		return "", false
+	}
+	host := u.Hostname()
+	if cfg.env != "" && cfg.envs[cfg.env].Protected {
+		return host, true
+	}
+	for _, pattern := range cfg.protect {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(host)); ok {
+			return host, true
+		}
+	}
+	return host, false
+}
+
+// guarded runs run, which sends the request described by cfg somehow,
+// straight away, or once the user confirms it if its host is protected.
+// what says what run is about to do.
+func (m model) guarded(cfg config, what string, run func(m model) (tea.Model, tea.Cmd)) (tea.Model, tea.Cmd) {
+	if c := newConfirmation(cfg, what, run); c != nil {
+		m.confirm = c
+		return m, nil
+	}
+	return run(m)
+}
+
+// newConfirmation returns the confirmation sending cfg needs, or nil if it
+// needs none.
+func newConfirmation(cfg config, what string, run func(m model) (tea.Model, tea.Cmd)) *confirmation {
+	host, ok := protectedHost(cfg)
+	if !ok {
+		return nil
+	}
+	return holdFor(host, what, run)
+}
+
+// holdFor returns a confirmation that holds run back until host is typed.
+func holdFor(host, what string, run func(m model) (tea.Model, tea.Cmd)) *confirmation {
+	in := textinput.New()
+	in.Prompt = "Type the host to confirm: "
+	in.Placeholder = host
+	in.Cursor.SetMode(cursor.CursorStatic)
+	in.Focus()
+	return &confirmation{host: host, what: what, input: in, run: run}
+}
+
+// updateConfirm sends the request held back once its host has been typed,
+// and drops it on esc.
+func (m model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.report, m.cursor = &reportMsg{title: "Not sent", body: m.confirm.what + " was not sent."}, 0
+		m.confirm = nil
+		return m, nil
+	case "enter":
+		if !strings.EqualFold(strings.TrimSpace(m.confirm.input.Value()), m.confirm.host) {
+			m.confirm.input.SetValue("")
+			return m, nil
+		}
+		run := m.confirm.run
+		m.confirm = nil
+		return run(m)
+	}
+	c := *m.confirm
+	c.input, _ = c.input.Update(msg)
+	m.confirm = &c
+	return m, nil
+}
+
+// viewConfirm draws the confirmation over the rest of the screen.
+func (m model) viewConfirm() string {
+	s := m.viewMain() + "\n"
+	if m.confirm.detail != "" {
+		s += m.confirm.detail + "\n\n"
+		s += fmt.Sprintf("⚠ %s goes to %s.\n", m.confirm.what, m.confirm.host)
+	} else {
+		s += fmt.Sprintf("⚠ %s goes to %s, a protected host.\n", m.confirm.what, m.confirm.host)
+	}
+	return s + m.confirm.input.View() + "\n\nenter send • esc don't send\n"
+}
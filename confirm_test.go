@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestProtectedHost(t *testing.T) {
+	envs := map[string]environment{"prod": {Base: "https://api.example.com/", Protected: true}, "dev": {Base: "http://localhost/"}}
+	for _, tt := range []struct {
+		method, url, env string
+		want             bool
+	}{
+		{"DELETE", "https://users.prod.example.com/7", "", true},
+		{"PATCH", "https://USERS.PROD.example.com/7", "", true},
+		{"GET", "https://users.prod.example.com/7", "", false},
+		{"DELETE", "https://staging.example.com/7", "", false},
+		{"PUT", "https://api.example.com/7", "prod", true},
+		{"PUT", "http://localhost/7", "dev", false},
+	} {
+		cfg := config{method: tt.method, url: tt.url, env: tt.env, envs: envs, protect: []string{"*.prod.example.com"}}
+		if _, got := protectedHost(cfg); got != tt.want {
+			t.Errorf("protectedHost(%s %s, env %q) = %t, want %t", tt.method, tt.url, tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	m := model{cfg: config{method: "GET", url: "https://api.prod.example.com/"}}
+	cfg := config{method: "DELETE", url: "https://api.prod.example.com/users/7", protect: []string{"*.prod.example.com"}}
+	next, cmd := m.resend(cfg)
+	m = next.(model)
+	if m.confirm == nil || cmd != nil || m.cfg.method != "GET" {
+		t.Fatalf("the DELETE wasn't held back: confirm = %+v", m.confirm)
+	}
+
+	// The wrong host sends nothing and clears the input.
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("api.example.com")})
+	next, cmd = next.(model).Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if m.confirm == nil || cmd != nil || m.confirm.input.Value() != "" {
+		t.Fatalf("a wrong host went through")
+	}
+
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("api.prod.example.com")})
+	next, cmd = next.(model).Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if m.confirm != nil || cmd == nil || m.cfg.method != "DELETE" {
+		t.Errorf("the confirmed DELETE wasn't sent")
+	}
+
+	// Esc drops the request and says so.
+	next, _ = m.resend(cfg)
+	next, _ = next.(model).Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m = next.(model); m.confirm != nil || m.report == nil || m.report.title != "Not sent" {
+		t.Errorf("esc left confirm = %+v, report = %+v", m.confirm, m.report)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+)
+
+// continueInfo records how an Expect: 100-continue exchange played out.
+// The fields are written from the transport's goroutines, hence the atomics.
+type continueInfo struct {
+	bodySize int64        // Size of the body we offered to upload.
+	sent     atomic.Int64 // Body bytes the transport actually read from us.
+	got100   atomic.Bool  // The server answered "100 Continue".
+}
+
+// countingReader wraps the request body and counts how much of it the
+// transport has consumed, which tells us whether the upload really happened.
+type countingReader struct {
+	r    io.Reader
+	info *continueInfo
+}
+
+// Read implements io.Reader.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.info.sent.Add(int64(n))
+	return n, err
+}
+
+// withExpectContinue switches req over to the Expect: 100-continue handshake.
+// It replaces the body with a counting reader and installs a client trace so
+// we can tell later whether the server let the body through.
+func withExpectContinue(req *http.Request, body io.Reader, size int64) (*http.Request, *continueInfo) {
+	info := &continueInfo{bodySize: size}
+
+	req.Header.Set("Expect", "100-continue")
+	req.Body = io.NopCloser(&countingReader{r: body, info: info})
+	req.ContentLength = size
+
+	// The default transport waits up to a second for the server's verdict
+	// before giving up and sending the body anyway.
+	trace := &httptrace.ClientTrace{
+		Got100Continue: func() { info.got100.Store(true) },
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), info
+}
+
+// summary describes the handshake for the status line, e.g.
+// "server said 100 Continue, sent 4096 of 4096 bytes".
+func (c *continueInfo) summary(status int) string {
+	sent := c.sent.Load()
+	switch {
+	case c.got100.Load():
+		return fmt.Sprintf("server said 100 Continue, sent %d of %d bytes", sent, c.bodySize)
+	case sent == 0:
+		return fmt.Sprintf("server answered %d before the upload, saved %d bytes", status, c.bodySize)
+	default:
+		return fmt.Sprintf("no 100 Continue in time, sent %d of %d bytes anyway", sent, c.bodySize)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// upload sends body with Expect: 100-continue to a server running handle.
+func upload(t *testing.T, body string, handle http.HandlerFunc) (*http.Response, *continueInfo) {
+	t.Helper()
+	srv := httptest.NewServer(handle)
+	t.Cleanup(srv.Close)
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, info := withExpectContinue(req, strings.NewReader(body), int64(len(body)))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res, info
+}
+
+func TestExpectContinueAccepted(t *testing.T) {
+	var got string
+	res, info := upload(t, "0123456789", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Expect") != "100-continue" {
+			t.Errorf("Expect = %q", r.Header.Get("Expect"))
+		}
+		b, _ := io.ReadAll(r.Body)
+		got = string(b)
+	})
+	if got != "0123456789" {
+		t.Errorf("server read %q", got)
+	}
+	if want := "server said 100 Continue, sent 10 of 10 bytes"; info.summary(res.StatusCode) != want {
+		t.Errorf("summary = %q, want %q", info.summary(res.StatusCode), want)
+	}
+}
+
+func TestExpectContinueRejected(t *testing.T) {
+	res, info := upload(t, strings.Repeat("x", 1<<20), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	})
+	if res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d", res.StatusCode)
+	}
+	if info.got100.Load() || info.sent.Load() != 0 {
+		t.Errorf("got100 = %v, sent = %d, want the body held back", info.got100.Load(), info.sent.Load())
+	}
+	if want := "server answered 413 before the upload, saved 1048576 bytes"; info.summary(res.StatusCode) != want {
+		t.Errorf("summary = %q, want %q", info.summary(res.StatusCode), want)
+	}
+}
+
+func TestContinueSummaryWithout100(t *testing.T) {
+	info := &continueInfo{bodySize: 8}
+	info.sent.Store(8)
+	if want := "no 100 Continue in time, sent 8 of 8 bytes anyway"; info.summary(200) != want {
+		t.Errorf("summary = %q, want %q", info.summary(200), want)
+	}
+}
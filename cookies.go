@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// A Set-Cookie line packs a cookie and the rules for keeping it into one
+// string, and a browser that dislikes any part of it drops the cookie
+// without a word. The cookie breakdown takes the lines apart, and says what
+// a browser would make of each.
+
+// maxCookieAge is the longest browsers keep a cookie, whatever it asks
+// for: 400 days.
+const maxCookieAge = 400 * 24 * time.Hour
+
+// sessionCookieWords are parts of names that say a cookie holds a session
+// or credentials, which script on the page has no business reading.
+var sessionCookieWords = []string{"sess", "sid", "token", "auth", "jwt", "login"}
+
+// runCookies is the palette's `cookies`: it breaks down each Set-Cookie
+// of the answer.
+func runCookies(m model, _ []string) (tea.Model, tea.Cmd) {
+	if m.res.status == 0 {
+		return m.paletteError(errors.New("there is no answer to read yet"))
+	}
+	lines := m.res.header.Values("Set-Cookie")
+	if len(lines) == 0 {
+		return m.paletteError(errors.New("the answer sets no cookies"))
+	}
+	from := m.res.final
+	if from == nil {
+		var err error
+		if from, err = url.Parse(m.cfg.url); err != nil {
+			return m.paletteError(err)
+		}
+	}
+	m.report, m.cursor = &reportMsg{title: fmt.Sprintf("%d cookies from %s", len(lines), from.Hostname()), body: describeCookies(lines, from, time.Now())}, 0
+	return m, nil
+}
+
+// describeCookies breaks down each of lines, Set-Cookie headers of an
+// answer from u, as of now.
+func describeCookies(lines []string, u *url.URL, now time.Time) string {
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		c, err := http.ParseSetCookie(line)
+		if err != nil {
+			fmt.Fprintf(&b, "✗ %s\n  Browsers ignore it: %v\n", line, err)
+			continue
+		}
+		b.WriteString(describeCookie(c, u, now))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// describeCookie says what c is, what each of its attributes does, and
+// what is wrong with it, for a cookie set by an answer from u.
+func describeCookie(c *http.Cookie, u *url.URL, now time.Time) string {
+	var b strings.Builder
+	value := c.Value
+	if len(value) > 40 {
+		value = value[:37] + "…"
+	}
+	fmt.Fprintf(&b, "%s = %s\n", c.Name, value)
+	attr := func(name, about string) { fmt.Fprintf(&b, "  %-10s %s\n", name, about) }
+	host := strings.ToLower(u.Hostname())
+
+	// Where it is sent.
+	domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+	if domain == "" {
+		attr("Domain", "(none) only to "+host+", not its subdomains")
+	} else {
+		attr("Domain", domain+" and every subdomain of it")
+	}
+	path := c.Path
+	if path == "" {
+		path = "(none) under the path of the URL that set it"
+	} else {
+		path += " and everything under it"
+	}
+	attr("Path", path)
+
+	// How long it lasts.
+	switch {
+	case c.MaxAge < 0:
+		attr("Max-Age", "0 or less: it deletes the cookie now")
+	case c.MaxAge > 0:
+		attr("Max-Age", fmt.Sprintf("kept for %s", forSeconds(int64(c.MaxAge))))
+	case !c.Expires.IsZero() && c.Expires.Before(now):
+		attr("Expires", c.Expires.UTC().Format(http.TimeFormat)+", in the past: it deletes the cookie")
+	case !c.Expires.IsZero():
+		attr("Expires", fmt.Sprintf("%s, in %s", c.Expires.UTC().Format(http.TimeFormat), forSeconds(int64(c.Expires.Sub(now).Seconds()))))
+	default:
+		attr("Lifetime", "a session cookie: gone when the browser closes")
+	}
+
+	// Who sees it.
+	if c.Secure {
+		attr("Secure", "only sent over HTTPS")
+	} else {
+		attr("Secure", "no: sent over plain HTTP too")
+	}
+	if c.HttpOnly {
+		attr("HttpOnly", "hidden from scripts on the page")
+	} else {
+		attr("HttpOnly", "no: scripts on the page can read it")
+	}
+	switch c.SameSite {
+	case http.SameSiteStrictMode:
+		attr("SameSite", "Strict: never sent with requests from other sites")
+	case http.SameSiteLaxMode:
+		attr("SameSite", "Lax: sent from other sites only when following a link")
+	case http.SameSiteNoneMode:
+		attr("SameSite", "None: sent with requests from any site")
+	default:
+		attr("SameSite", "(none) browsers treat it as Lax")
+	}
+	if c.Partitioned {
+		attr("Partitioned", "kept apart for each site it is embedded in (CHIPS)")
+	}
+
+	var problems []string
+	if c.SameSite == http.SameSiteNoneMode && !c.Secure {
+		problems = append(problems, "SameSite=None without Secure: browsers reject the cookie")
+	}
+	if c.Partitioned && !c.Secure {
+		problems = append(problems, "Partitioned without Secure: browsers reject the cookie")
+	}
+	if c.Secure && u.Scheme == "http" {
+		problems = append(problems, "Secure set over plain HTTP: browsers don't let an http:// page set it")
+	}
+	if domain != "" {
+		switch {
+		case !strings.Contains(domain, "."):
+			problems = append(problems, "Domain="+domain+" is a top-level domain: browsers reject the cookie")
+		case host != domain && !strings.HasSuffix(host, "."+domain):
+			problems = append(problems, "Domain="+domain+" doesn't cover "+host+": browsers reject the cookie")
+		case host != domain:
+			problems = append(problems, "Domain="+domain+" sends it to every subdomain of "+domain+", not just "+host+"; leave Domain out to keep it to this host")
+		}
+	}
+	if strings.HasPrefix(c.Name, "__Secure-") && !c.Secure {
+		problems = append(problems, "the __Secure- prefix needs Secure: browsers reject the cookie")
+	}
+	if strings.HasPrefix(c.Name, "__Host-") && (!c.Secure || c.Path != "/" || c.Domain != "") {
+		problems = append(problems, "the __Host- prefix needs Secure, Path=/ and no Domain: browsers reject the cookie")
+	}
+	if !c.HttpOnly && looksLikeSession(c.Name) {
+		problems = append(problems, "it looks like a session or token, but scripts can read it; set HttpOnly")
+	}
+	if c.MaxAge > 0 && !c.Expires.IsZero() {
+		problems = append(problems, "Max-Age and Expires both; Max-Age wins")
+	}
+	if time.Duration(c.MaxAge)*time.Second > maxCookieAge || (c.MaxAge == 0 && c.Expires.Sub(now) > maxCookieAge) {
+		problems = append(problems, "it asks to be kept longer than 400 days; browsers keep it for 400 at most")
+	}
+	if len(c.Unparsed) > 0 {
+		problems = append(problems, "browsers don't know "+strings.Join(c.Unparsed, "; ")+", and ignore it")
+	}
+	for _, p := range problems {
+		b.WriteString("  ⚠ " + p + "\n")
+	}
+	return b.String()
+}
+
+// looksLikeSession reports whether a cookie called name seems to hold a
+// session or credentials.
+func looksLikeSession(name string) bool {
+	name = strings.ToLower(name)
+	for _, w := range sessionCookieWords {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribeCookies(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	u, _ := url.Parse("https://api.example.com/login")
+	out := describeCookies([]string{
+		"session_id=abc123; Domain=.example.com; Path=/; Max-Age=3600; SameSite=None",
+		"__Host-prefs=dark; Secure; HttpOnly; Path=/app; SameSite=Strict",
+		"theme=light; Expires=Thu, 01 Jan 1970 00:00:00 GMT",
+		"track=1; Domain=other.org; Max-Age=63072000; Secure; HttpOnly; Priority=High",
+		"=nameless",
+	}, u, now)
+	for _, want := range []string{
+		"session_id = abc123\n  Domain     example.com and every subdomain of it\n  Path       / and everything under it\n  Max-Age    kept for 1h\n",
+		"  ⚠ SameSite=None without Secure: browsers reject the cookie",
+		"  ⚠ Domain=example.com sends it to every subdomain of example.com, not just api.example.com",
+		"  ⚠ it looks like a session or token, but scripts can read it; set HttpOnly",
+		"  SameSite   Strict: never sent with requests from other sites",
+		"  ⚠ the __Host- prefix needs Secure, Path=/ and no Domain",
+		"  Expires    Thu, 01 Jan 1970 00:00:00 GMT, in the past: it deletes the cookie",
+		"  Lifetime   a session cookie",
+		"  ⚠ Domain=other.org doesn't cover api.example.com",
+		"  ⚠ it asks to be kept longer than 400 days",
+		"  ⚠ browsers don't know Priority=High",
+		"✗ =nameless\n  Browsers ignore it",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("breakdown lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "theme = light\n  Domain     (none) only to api.example.com, not its subdomains\n  Path       (none) under the path of the URL that set it\n  Expires    Thu, 01 Jan 1970 00:00:00 GMT, in the past: it deletes the cookie\n  Secure     no: sent over plain HTTP too\n  HttpOnly   no: scripts on the page can read it\n  SameSite   (none) browsers treat it as Lax\n  ⚠") {
+		t.Errorf("a plain cookie has problems:\n%s", out)
+	}
+
+	u, _ = url.Parse("http://example.com/")
+	if out := describeCookies([]string{"a=1; Secure"}, u, now); !strings.Contains(out, "⚠ Secure set over plain HTTP") {
+		t.Errorf("Secure over HTTP:\n%s", out)
+	}
+}
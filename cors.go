@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// corsCheck is the outcome of simulating the CORS checks a browser would run
+// for our request if it were made by a page served from cfg.origin.
+type corsCheck struct {
+	preflight int      // Status of the OPTIONS preflight, 0 if none was needed.
+	problems  []string // Why the browser would block the request; empty if allowed.
+}
+
+// safelistedMethods never need a preflight on their own.
+var safelistedMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+// safelistedContentTypes are the only Content-Type values a page may send
+// without a preflight.
+var safelistedContentTypes = []string{
+	"application/x-www-form-urlencoded", "multipart/form-data", "text/plain",
+}
+
+// unsafeHeaders returns, lowercased and sorted, the request headers that are
+// not CORS-safelisted and so must be announced in a preflight.
+func unsafeHeaders(h http.Header) []string {
+	var out []string
+	for key := range h {
+		switch k := strings.ToLower(key); k {
+		case "accept", "accept-language", "content-language", "range":
+		case "content-type":
+			mediaType, _, _ := strings.Cut(h.Get(key), ";")
+			if !slices.Contains(safelistedContentTypes, strings.ToLower(strings.TrimSpace(mediaType))) {
+				out = append(out, k)
+			}
+		default:
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// pageHeaders returns the headers the page itself would send with the
+// request described by cfg: the X-Request-ID this program adds is left out,
+// so that it doesn't make every request look like it needs a preflight.
+func pageHeaders(cfg config) http.Header {
+	if !cfg.madeID {
+		return cfg.header
+	}
+	h := cfg.header.Clone()
+	h.Del("X-Request-ID")
+	return h
+}
+
+// needsPreflight reports whether a browser would send an OPTIONS preflight
+// before the request described by cfg.
+func needsPreflight(cfg config) bool {
+	return !slices.Contains(safelistedMethods, cfg.method) || len(unsafeHeaders(pageHeaders(cfg))) > 0
+}
+
+// sendPreflight sends the OPTIONS request a browser would send ahead of the
+// request described by cfg, and checks that its answer lets the request through.
+func sendPreflight(c *http.Client, cfg config) (*corsCheck, error) {
+	req, err := http.NewRequest(http.MethodOptions, cfg.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	headers := unsafeHeaders(pageHeaders(cfg))
+	req.Header.Set("Origin", cfg.origin)
+	req.Header.Set("Access-Control-Request-Method", cfg.method)
+	if len(headers) > 0 {
+		req.Header.Set("Access-Control-Request-Headers", strings.Join(headers, ","))
+	}
+
+	// Browsers don't follow redirects on a preflight, so neither do we.
+	noRedirects := *c
+	noRedirects.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	res, err := noRedirects.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+
+	check := &corsCheck{preflight: res.StatusCode}
+	switch {
+	case res.StatusCode >= 300 && res.StatusCode <= 399:
+		check.problems = append(check.problems,
+			fmt.Sprintf("preflight: answered %d, a redirect, which browsers refuse to follow for a preflight", res.StatusCode))
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		check.problems = append(check.problems,
+			fmt.Sprintf("preflight: answered %d, but it must succeed with a 2xx status", res.StatusCode))
+	}
+	check.problems = append(check.problems, checkAllowOrigin(res.Header, cfg, "preflight")...)
+
+	allow := tokens(res.Header.Get("Access-Control-Allow-Methods"))
+	if !slices.Contains(safelistedMethods, cfg.method) && !slices.Contains(allow, strings.ToLower(cfg.method)) &&
+		!(slices.Contains(allow, "*") && !cfg.credentials) {
+		check.problems = append(check.problems,
+			fmt.Sprintf("preflight: Access-Control-Allow-Methods does not list %s", cfg.method))
+	}
+
+	allowed := tokens(res.Header.Get("Access-Control-Allow-Headers"))
+	for _, h := range headers {
+		if !slices.Contains(allowed, h) && !(slices.Contains(allowed, "*") && !cfg.credentials) {
+			check.problems = append(check.problems,
+				fmt.Sprintf("preflight: Access-Control-Allow-Headers does not list %s", h))
+		}
+	}
+	return check, nil
+}
+
+// checkAllowOrigin checks the Access-Control-Allow-Origin and, for
+// credentialed requests, Access-Control-Allow-Credentials headers of h.
+// where names the response being checked, "preflight" or "response".
+func checkAllowOrigin(h http.Header, cfg config, where string) []string {
+	var problems []string
+	switch allow := h.Get("Access-Control-Allow-Origin"); {
+	case allow == "":
+		problems = append(problems, where+": Access-Control-Allow-Origin is missing")
+	case allow == "*" && cfg.credentials:
+		problems = append(problems, where+": Access-Control-Allow-Origin is * but credentialed requests need the exact origin")
+	case allow != "*" && allow != cfg.origin:
+		problems = append(problems, fmt.Sprintf("%s: Access-Control-Allow-Origin is %s, not %s", where, allow, cfg.origin))
+	}
+	if cfg.credentials && h.Get("Access-Control-Allow-Credentials") != "true" {
+		problems = append(problems, where+": Access-Control-Allow-Credentials must be true for credentialed requests")
+	}
+	return problems
+}
+
+// tokens splits a comma-separated header value into lowercased, trimmed items.
+func tokens(v string) []string {
+	var out []string
+	for _, t := range strings.Split(v, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// summary gives the browser's verdict, followed by one line per problem.
+func (c *corsCheck) summary() string {
+	s := "allowed"
+	if len(c.problems) > 0 {
+		s = "blocked"
+	}
+	if c.preflight > 0 {
+		s += fmt.Sprintf(" (preflight answered %d)", c.preflight)
+	} else {
+		s += " (simple request, no preflight)"
+	}
+	for _, p := range c.problems {
+		s += "\n  - " + p
+	}
+	return s
+}
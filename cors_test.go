@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// preflightServer answers OPTIONS requests with handle.
+func preflightServer(t *testing.T, handle http.HandlerFunc) config {
+	t.Helper()
+	srv := httptest.NewServer(handle)
+	t.Cleanup(srv.Close)
+	return config{
+		method: http.MethodPut,
+		url:    srv.URL + "/api",
+		origin: "https://app.test",
+		header: http.Header{"X-Token": {"1"}},
+	}
+}
+
+func TestPreflightAllowed(t *testing.T) {
+	cfg := preflightServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") != "PUT" ||
+			r.Header.Get("Access-Control-Request-Headers") != "x-token" {
+			t.Errorf("preflight = %s %v", r.Method, r.Header)
+		}
+		w.Header().Set("Access-Control-Allow-Origin", "https://app.test")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, PUT")
+		w.Header().Set("Access-Control-Allow-Headers", "X-Token")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	check, err := sendPreflight(http.DefaultClient, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.preflight != http.StatusNoContent || len(check.problems) != 0 {
+		t.Errorf("check = %+v, want an allowed 204", check)
+	}
+}
+
+func TestPreflightRedirect(t *testing.T) {
+	followed := false
+	cfg := preflightServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			followed = true
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			return
+		}
+		http.Redirect(w, r, "/moved", http.StatusTemporaryRedirect)
+	})
+	check, err := sendPreflight(http.DefaultClient, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if followed {
+		t.Error("the preflight followed a redirect")
+	}
+	if check.preflight != http.StatusTemporaryRedirect || len(check.problems) == 0 ||
+		!strings.Contains(check.problems[0], "redirect") {
+		t.Errorf("check = %+v, want the redirect reported", check)
+	}
+}
+
+func TestPreflightMissingHeaders(t *testing.T) {
+	cfg := preflightServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	})
+	cfg.credentials = true
+	check, err := sendPreflight(http.DefaultClient, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"exact origin", "Allow-Methods does not list PUT", "Allow-Headers does not list x-token"}
+	for _, w := range want {
+		found := false
+		for _, p := range check.problems {
+			found = found || strings.Contains(p, w)
+		}
+		if !found {
+			t.Errorf("problems = %q, missing %q", check.problems, w)
+		}
+	}
+}
+
+func TestNeedsPreflight(t *testing.T) {
+	simple := config{method: http.MethodPost, header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}}}
+	if needsPreflight(simple) {
+		t.Error("a simple POST needs no preflight")
+	}
+	if !needsPreflight(config{method: http.MethodPost, header: http.Header{"Content-Type": {"application/json"}}}) {
+		t.Error("a JSON POST needs a preflight")
+	}
+	if !needsPreflight(config{method: http.MethodDelete, header: http.Header{}}) {
+		t.Error("DELETE needs a preflight")
+	}
+	// The X-Request-ID added here isn't the page's, but one given with -H is.
+	simple.requestID = true
+	if needsPreflight(withRequestID(simple)) {
+		t.Error("the added X-Request-ID asked for a preflight")
+	}
+	simple.header = http.Header{"X-Request-Id": {"mine"}}
+	if !needsPreflight(withRequestID(simple)) {
+		t.Error("a given X-Request-ID needs a preflight")
+	}
+}
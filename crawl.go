@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// crawlWorkers bounds how many requests a crawl has in flight at once.
+const crawlWorkers = 8
+
+// maxCrawlPages stops a crawl from running away on a large site.
+const maxCrawlPages = 500
+
+// crawlResult is what the crawler learned about one URL.
+type crawlResult struct {
+	url      string   // The URL that was checked.
+	referrer string   // Page the link was found on, empty for the start page.
+	depth    int      // Number of links followed to get here.
+	status   int      // HTTP status code, 0 if the request failed.
+	err      error    // Why the request failed, if it did.
+	links    []string // Links found on the page, when it was followed.
+}
+
+// label renders r as a list entry, e.g. "404 https://example.com/gone".
+func (r crawlResult) label() string {
+	if r.err != nil {
+		return "ERR " + r.url
+	}
+	return fmt.Sprintf("%3d %s", r.status, r.url)
+}
+
+// crawl visits start and, breadth first, the pages it links to on the same
+// host, up to depth links away. When external is set, links to other hosts
+// are checked as well, but never followed. progress, if not nil, is called
+// after every page with the number checked and the number still queued.
+func crawl(c *http.Client, start string, depth int, external bool, progress func(checked, queued int)) ([]crawlResult, error) {
+	startURL, err := url.Parse(start)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []crawlResult
+	var checked atomic.Int64
+	seen := map[string]bool{start: true}
+	level := []crawlResult{{url: start}}
+
+	for d := 0; len(level) > 0; d++ {
+		// Check the whole level concurrently; each worker owns one slot.
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, crawlWorkers)
+		for i := range level {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(r *crawlResult) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				follow := d < depth && sameHost(r.url, startURL)
+				visit(c, r, follow)
+				if n := checked.Add(1); progress != nil {
+					progress(int(n), len(out)+len(level)-int(n))
+				}
+			}(&level[i])
+		}
+		wg.Wait()
+		out = append(out, level...)
+
+		// Queue up everything this level linked to that we haven't seen.
+		var next []crawlResult
+		for _, r := range level {
+			for _, link := range r.links {
+				if seen[link] || len(seen) >= maxCrawlPages {
+					continue
+				}
+				if !external && !sameHost(link, startURL) {
+					continue
+				}
+				seen[link] = true
+				next = append(next, crawlResult{url: link, referrer: r.url, depth: d + 1})
+			}
+		}
+		level = next
+	}
+	return out, nil
+}
+
+// visit requests r.url and records its status. When follow is set and the
+// answer is an HTML page, the links on it are recorded too.
+func visit(c *http.Client, r *crawlResult, follow bool) {
+	res, err := c.Get(r.url)
+	if err != nil {
+		r.err = err
+		return
+	}
+	defer res.Body.Close()
+	r.status = res.StatusCode
+
+	if !follow || !isHTML(res.Header) {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxBody))
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.links = extractLinks(body, res.Request.URL)
+}
+
+// sameHost reports whether link points at the same host as start.
+func sameHost(link string, start *url.URL) bool {
+	u, err := url.Parse(link)
+	return err == nil && strings.EqualFold(u.Host, start.Host)
+}
+
+// statusCounts summarises results as "200×41, 404×2, ERR×1".
+func statusCounts(results []crawlResult) string {
+	counts := map[string]int{}
+	for _, r := range results {
+		key := "ERR"
+		if r.err == nil {
+			key = fmt.Sprint(r.status)
+		}
+		counts[key]++
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s×%d", k, counts[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// crawlSite returns a command that crawls cfg.url to cfg.crawlDepth and lists
+// every page it reached along with its status. It reports its progress as
+// it goes.
+func crawlSite(cfg config) tea.Cmd {
+	return runJob(func(report func(string)) tea.Msg {
+		results, err := crawl(newClient(cfg), cfg.url, cfg.crawlDepth, false, func(checked, queued int) {
+			report(fmt.Sprintf("Crawling: %d pages checked, %d queued", checked, queued))
+		})
+		if err != nil {
+			return errMsg{err}
+		}
+
+		r := reportMsg{
+			title: "Crawl",
+			body:  fmt.Sprintf("%d pages within %d links of the start: %s", len(results), cfg.crawlDepth, statusCounts(results)),
+		}
+		for _, res := range results {
+			r.links = append(r.links, res.url)
+			r.labels = append(r.labels, res.label())
+		}
+		return r
+	})
+}
+
+// pageLinks builds the report listing the hyperlinks on the current page.
+func pageLinks(res response) reportMsg {
+	if !isHTML(res.header) {
+		return reportMsg{title: "Links", body: "The response is not an HTML page."}
+	}
+	links := extractLinks(res.body, res.final)
+	return reportMsg{title: "Links", body: fmt.Sprintf("%d links on the page", len(links)), links: links}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+func TestCrawl(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/a">a</a><a href="/gone">gone</a><a href="https://other.invalid/">out</a>`)
+		case "/a":
+			fmt.Fprint(w, `<a href="/">home</a><a href="/deep">deep</a>`)
+		case "/deep":
+			fmt.Fprint(w, `<a href="/deeper">deeper</a>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	results, err := crawl(srv.Client(), srv.URL+"/", 1, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.label())
+	}
+	sort.Strings(got)
+	want := []string{
+		"200 " + srv.URL + "/",
+		"200 " + srv.URL + "/a",
+		"404 " + srv.URL + "/gone",
+	}
+	sort.Strings(want)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("crawl = %q, want %q", got, want)
+	}
+}
+
+func TestStatusCounts(t *testing.T) {
+	results := []crawlResult{{status: 200}, {status: 404}, {status: 200}, {err: errors.New("refused")}}
+	if got := statusCounts(results); got != "200×2, 404×1, ERR×1" {
+		t.Errorf("statusCounts = %q", got)
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	r := pageLinks(response{header: http.Header{"Content-Type": {"application/json"}}})
+	if r.body != "The response is not an HTML page." || r.links != nil {
+		t.Errorf("JSON page = %+v", r)
+	}
+}
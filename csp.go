@@ -0,0 +1,273 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// A Content-Security-Policy is a line of directives, each with a list of
+// the sources it allows, and a long one is unreadable at a glance. The CSP
+// view sets it out a directive and a source to a line, says what each
+// source lets in, and points out the patterns that undo the policy.
+
+// cspFetchDirectives are the directives that fall back to default-src.
+var cspFetchDirectives = []string{
+	"child-src", "connect-src", "font-src", "frame-src", "img-src", "manifest-src", "media-src",
+	"object-src", "prefetch-src", "script-src", "script-src-attr", "script-src-elem",
+	"style-src", "style-src-attr", "style-src-elem", "worker-src",
+}
+
+// cspOtherDirectives are the rest of the directives browsers know.
+var cspOtherDirectives = []string{
+	"default-src", "base-uri", "form-action", "frame-ancestors", "navigate-to", "report-to", "report-uri",
+	"require-trusted-types-for", "sandbox", "trusted-types", "upgrade-insecure-requests",
+	"block-all-mixed-content", "plugin-types",
+}
+
+// cspDirective is a directive and its sources, as the policy gives them.
+type cspDirective struct {
+	name    string
+	sources []string
+}
+
+// cspPolicy is one policy: a Content-Security-Policy header, or a
+// Content-Security-Policy-Report-Only one, which only reports what it would
+// block.
+type cspPolicy struct {
+	reportOnly bool
+	directives []cspDirective
+}
+
+// parseCSP parses a policy header's value. Browsers take the first of a
+// repeated directive and ignore the rest.
+func parseCSP(v string, reportOnly bool) cspPolicy {
+	p := cspPolicy{reportOnly: reportOnly}
+	for _, part := range strings.Split(v, ";") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.ToLower(fields[0])
+		if p.directive(name) != nil {
+			continue
+		}
+		p.directives = append(p.directives, cspDirective{name: name, sources: fields[1:]})
+	}
+	return p
+}
+
+// directive returns the policy's directive called name, or nil.
+func (p cspPolicy) directive(name string) *cspDirective {
+	for i := range p.directives {
+		if p.directives[i].name == name {
+			return &p.directives[i]
+		}
+	}
+	return nil
+}
+
+// scripts returns the directive that governs scripts: script-src, or
+// default-src, which it falls back to.
+func (p cspPolicy) scripts() *cspDirective {
+	if d := p.directive("script-src"); d != nil {
+		return d
+	}
+	return p.directive("default-src")
+}
+
+// runCSP is the palette's `csp`: it sets out the answer's
+// Content-Security-Policy.
+func runCSP(m model, _ []string) (tea.Model, tea.Cmd) {
+	if m.res.status == 0 {
+		return m.paletteError(errors.New("there is no answer to read yet"))
+	}
+	var policies []cspPolicy
+	for _, v := range m.res.header.Values("Content-Security-Policy") {
+		policies = append(policies, parseCSP(v, false))
+	}
+	for _, v := range m.res.header.Values("Content-Security-Policy-Report-Only") {
+		policies = append(policies, parseCSP(v, true))
+	}
+	if len(policies) == 0 {
+		return m.paletteError(errors.New("the answer has no Content-Security-Policy"))
+	}
+	m.report, m.cursor = &reportMsg{title: "Content-Security-Policy: " + m.cfg.path, body: describeCSP(policies, m.res.header)}, 0
+	return m, nil
+}
+
+// describeCSP sets out each of policies, from an answer with headers h,
+// and what weakens them.
+func describeCSP(policies []cspPolicy, h http.Header) string {
+	var b strings.Builder
+	for i, p := range policies {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		mode := "enforced"
+		if p.reportOnly {
+			mode = "report only: nothing is blocked, only reported"
+		}
+		if len(policies) > 1 {
+			fmt.Fprintf(&b, "Policy %d, %s:\n", i+1, mode)
+		} else {
+			fmt.Fprintf(&b, "Policy, %s:\n", mode)
+		}
+		width := 0
+		for _, d := range p.directives {
+			width = max(width, len(d.name))
+		}
+		for _, d := range p.directives {
+			if len(d.sources) == 0 {
+				fmt.Fprintf(&b, "  %-*s  %s\n", width, d.name, cspDirectiveNote(d))
+				continue
+			}
+			for j, s := range d.sources {
+				name := d.name
+				if j > 0 {
+					name = ""
+				}
+				row := fmt.Sprintf("  %-*s  %-28s %s", width, name, s, cspSourceNote(d, s))
+				b.WriteString(strings.TrimRight(row, " ") + "\n")
+			}
+		}
+		problems := p.problems(h)
+		if len(problems) > 0 {
+			b.WriteString("\n")
+		}
+		for _, problem := range problems {
+			b.WriteString("  ⚠ " + problem + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// cspDirectiveNote says what a directive with no sources does.
+func cspDirectiveNote(d cspDirective) string {
+	switch d.name {
+	case "upgrade-insecure-requests":
+		return "the page's http:// URLs are fetched over https://"
+	case "block-all-mixed-content":
+		return "nothing is fetched over plain HTTP (obsolete; upgrade-insecure-requests does better)"
+	case "sandbox":
+		return "the page runs sandboxed, as in an iframe with no allowances"
+	}
+	if slices.Contains(cspFetchDirectives, d.name) || d.name == "default-src" {
+		return "no sources: nothing is allowed, as with 'none'"
+	}
+	return ""
+}
+
+// cspSourceNote says what the source s lets in, within the directive d.
+func cspSourceNote(d cspDirective, s string) string {
+	script := strings.HasPrefix(d.name, "script-src") || d.name == "default-src"
+	lower := strings.ToLower(s)
+	switch {
+	case lower == "'self'":
+		return "the page's own origin"
+	case lower == "'none'":
+		if len(d.sources) > 1 {
+			return "⚠ 'none' with other sources is ignored"
+		}
+		return "nothing at all"
+	case lower == "'unsafe-inline'":
+		if d.hasNonceOrHash() {
+			return "ignored by browsers, since a nonce or hash is given"
+		}
+		if script || strings.HasPrefix(d.name, "style-src") {
+			return "⚠ any inline code runs, which is just what an injection needs"
+		}
+		return "inline code runs"
+	case lower == "'unsafe-eval'":
+		return "⚠ eval() and new Function() run strings as code"
+	case lower == "'wasm-unsafe-eval'":
+		return "WebAssembly may be compiled, but not eval()"
+	case lower == "'unsafe-hashes'":
+		return "event handlers such as onclick= run, if their hash is listed"
+	case lower == "'strict-dynamic'":
+		return "scripts a trusted script loads are trusted too; host sources are ignored"
+	case lower == "'report-sample'":
+		return "reports quote the start of what was blocked"
+	case strings.HasPrefix(lower, "'nonce-"):
+		return "scripts or styles carrying this nonce"
+	case strings.HasPrefix(lower, "'sha256-"), strings.HasPrefix(lower, "'sha384-"), strings.HasPrefix(lower, "'sha512-"):
+		return "inline code with this hash"
+	case s == "*":
+		return "⚠ any host, over any scheme but data:, blob: and filesystem:"
+	case lower == "https:":
+		if script {
+			return "⚠ any host over HTTPS, which anyone can put a script on"
+		}
+		return "any host over HTTPS"
+	case lower == "http:":
+		return "⚠ any host, even over plain HTTP"
+	case lower == "data:":
+		if script {
+			return "⚠ data: URLs, which carry whatever code the URL spells out"
+		}
+		return "data: URLs"
+	case lower == "blob:":
+		return "blob: URLs the page makes"
+	case strings.HasPrefix(lower, "http://"):
+		return "⚠ over plain HTTP, where the network can swap it"
+	case strings.Contains(s, "*."):
+		return "any subdomain of " + s[strings.Index(s, "*.")+2:]
+	}
+	if d.name == "report-uri" || d.name == "report-to" {
+		return "where violations are reported"
+	}
+	return ""
+}
+
+// hasNonceOrHash reports whether d lists a nonce or a hash, which make
+// browsers ignore its 'unsafe-inline'.
+func (d cspDirective) hasNonceOrHash() bool {
+	for _, s := range d.sources {
+		s = strings.ToLower(s)
+		if strings.HasPrefix(s, "'nonce-") || strings.HasPrefix(s, "'sha") {
+			return true
+		}
+	}
+	return false
+}
+
+// problems lists what weakens p, an answer with headers h's policy.
+func (p cspPolicy) problems(h http.Header) []string {
+	var out []string
+	for _, d := range p.directives {
+		if !slices.Contains(cspFetchDirectives, d.name) && !slices.Contains(cspOtherDirectives, d.name) {
+			out = append(out, fmt.Sprintf("browsers don't know %s, and ignore it", d.name))
+		}
+	}
+	if s := p.scripts(); s == nil {
+		out = append(out, "no script-src or default-src: scripts may come from anywhere")
+	} else if !slices.Contains(s.sources, "'strict-dynamic'") {
+		for _, src := range s.sources {
+			if src == "*" || src == "https:" || src == "http:" || src == "data:" {
+				out = append(out, fmt.Sprintf("%s allows scripts from %s, which leaves little to block", s.name, src))
+			}
+		}
+		if slices.Contains(s.sources, "'unsafe-inline'") && !s.hasNonceOrHash() {
+			out = append(out, s.name+" allows 'unsafe-inline', so an injected <script> runs; use nonces or hashes")
+		}
+	}
+	if p.directive("object-src") == nil {
+		if d := p.directive("default-src"); d == nil || !slices.Equal(d.sources, []string{"'none'"}) {
+			out = append(out, "no object-src: plugins fall back to default-src; object-src 'none' shuts them out")
+		}
+	}
+	if p.directive("base-uri") == nil {
+		out = append(out, "no base-uri: an injected <base> can point relative script URLs elsewhere; base-uri 'self' or 'none' stops it")
+	}
+	if p.directive("frame-ancestors") == nil && h.Get("X-Frame-Options") == "" {
+		out = append(out, "no frame-ancestors, nor X-Frame-Options: any site may frame the page, for clickjacking")
+	}
+	if p.reportOnly && p.directive("report-uri") == nil && p.directive("report-to") == nil {
+		out = append(out, "a report-only policy with no report-uri or report-to reports to no one")
+	}
+	return out
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDescribeCSP(t *testing.T) {
+	p := parseCSP("default-src 'self'; script-src 'self' 'unsafe-inline' https: *.cdn.example.com; img-src * data:; upgrade-insecure-requests; script-src 'none'", false)
+	if len(p.directives) != 4 || len(p.scripts().sources) != 4 {
+		t.Fatalf("parsed %+v", p)
+	}
+	out := describeCSP([]cspPolicy{p}, http.Header{})
+	for _, want := range []string{
+		"Policy, enforced:\n  default-src                'self'                       the page's own origin\n",
+		"  script-src                 'self'                       the page's own origin\n                             'unsafe-inline'              ⚠ any inline code runs",
+		"*.cdn.example.com            any subdomain of cdn.example.com",
+		"  img-src                    *                            ⚠ any host",
+		"data:                        data: URLs\n",
+		"  upgrade-insecure-requests  the page's http:// URLs are fetched over https://",
+		"⚠ script-src allows scripts from https:",
+		"⚠ script-src allows 'unsafe-inline', so an injected <script> runs",
+		"⚠ no object-src",
+		"⚠ no base-uri",
+		"⚠ no frame-ancestors, nor X-Frame-Options",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("view lacks %q:\n%s", want, out)
+		}
+	}
+
+	// A nonce makes 'unsafe-inline' a fallback for old browsers, not a hole.
+	strict := parseCSP("script-src 'nonce-abc' 'unsafe-inline' 'strict-dynamic'; object-src 'none'; base-uri 'none'; frame-ancestors 'self'; bogus-src x", true)
+	out = describeCSP([]cspPolicy{p, strict}, http.Header{})
+	for _, want := range []string{
+		"Policy 2, report only",
+		"ignored by browsers, since a nonce or hash is given",
+		"⚠ browsers don't know bogus-src",
+		"⚠ a report-only policy with no report-uri",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("view lacks %q:\n%s", want, out)
+		}
+	}
+	if problems := strict.problems(http.Header{}); len(problems) != 2 {
+		t.Errorf("strict policy problems = %q", problems)
+	}
+}
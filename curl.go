@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// looksLikeCurl reports whether s is a pasted curl command line rather than a URL.
+func looksLikeCurl(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "curl ") || strings.HasPrefix(s, "curl.exe ")
+}
+
+// shellSplit splits a command line into words the way a POSIX shell would
+// for the commands people paste: single and double quotes, backslash
+// escapes, $'...' strings and backslash-newline continuations.
+func shellSplit(s string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	inWord := false
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			// A backslash before a line break continues the line. Pasting
+			// into a single-line input turns those breaks into spaces, so a
+			// backslash and a space between words counts too.
+			if s[i] != '\n' && s[i] != '\r' && (inWord || s[i] != ' ') {
+				cur.WriteByte(s[i])
+				inWord = true
+			}
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			// $'...' understands \n, \t and friends, which Chrome uses when
+			// it copies request bodies.
+			j := i + 2
+			for ; j < len(s) && s[j] != '\''; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+					switch s[j] {
+					case 'n':
+						cur.WriteByte('\n')
+					case 't':
+						cur.WriteByte('\t')
+					case 'r':
+						cur.WriteByte('\r')
+					default:
+						cur.WriteByte(s[j])
+					}
+					continue
+				}
+				cur.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, errors.New("unterminated $' quote")
+			}
+			inWord = true
+			i = j
+		case c == '\'':
+			// '...' is taken literally.
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			cur.WriteString(s[i+1 : i+1+end])
+			inWord = true
+			i += end + 1
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) && strings.IndexByte("\"\\$`\n", s[j+1]) >= 0 {
+					j++
+					if s[j] == '\n' {
+						continue
+					}
+				}
+				cur.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+			i = j
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+// curlSwitches are the curl options without a value that only change how
+// curl itself behaves, so they are skipped.
+var curlSwitches = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true,
+	"-v": true, "--verbose": true, "-L": true, "--location": true,
+	"-k": true, "--insecure": true, "-i": true, "--include": true,
+	"-f": true, "--fail": true, "--fail-with-body": true,
+	"-N": true, "--no-buffer": true, "-g": true, "--globoff": true,
+	"-#": true, "--progress-bar": true, "-O": true, "--remote-name": true,
+	"--compressed": true, "--http1.1": true, "--http2": true, "--http2-prior-knowledge": true,
+}
+
+// curlValued are the short curl options that take a value, which can also
+// be attached to the option, as in -XPOST.
+const curlValued = "XHduAebowmxF"
+
+// readCurlData returns the text of a -d option. Like curl, @name reads the
+// file name instead, dropping its line breaks unless binary is set.
+func readCurlData(v string, binary bool) (string, error) {
+	if v == "@-" {
+		return "", errors.New("-d @- reads standard input, which a pasted command doesn't have")
+	}
+	if !strings.HasPrefix(v, "@") {
+		return v, nil
+	}
+	b, err := readArg(v)
+	if err != nil {
+		return "", err
+	}
+	if binary {
+		return string(b), nil
+	}
+	return strings.NewReplacer("\r", "", "\n", "").Replace(string(b)), nil
+}
+
+// fromCurl returns a copy of cfg with the method, URL, headers and body of
+// the curl command line cmd. Options that only change how curl itself
+// behaves (-s, -v, -L, --compressed, ...) are ignored; any other option we
+// don't know is an error, since guessing whether it takes a value could
+// turn that value into the URL.
+func fromCurl(cfg config, cmd string) (config, error) {
+	words, err := shellSplit(cmd)
+	if err != nil {
+		return cfg, err
+	}
+	if len(words) == 0 || !strings.HasPrefix(words[0], "curl") {
+		return cfg, errors.New("not a curl command")
+	}
+
+	var data []string
+	method, target, getData := "", "", false
+	cfg.header = http.Header{}
+
+	for i := 1; i < len(words); i++ {
+		w := words[i]
+		name, attached, hasAttached := strings.Cut(w, "=")
+		switch {
+		case !strings.HasPrefix(w, "-") || w == "-":
+			target = w
+			continue
+		case !strings.HasPrefix(w, "--"):
+			// Short options: -XPOST carries its value, -sSL bundles switches.
+			name, attached, hasAttached = w, "", false
+			if len(w) > 2 && strings.ContainsRune(curlValued, rune(w[1])) {
+				name, attached, hasAttached = w[:2], w[2:], true
+			} else if len(w) > 2 {
+				for _, c := range w[1:] {
+					switch {
+					case c == 'I':
+						method = http.MethodHead
+					case c == 'G':
+						getData = true
+					case !curlSwitches["-"+string(c)]:
+						return cfg, fmt.Errorf("curl option -%c (in %s) is not supported", c, w)
+					}
+				}
+				continue
+			}
+		}
+		// arg returns the value of the option, whether it was given as
+		// "-H value", "-Hvalue", "--header value" or "--header=value".
+		arg := func() (string, error) {
+			if hasAttached {
+				return attached, nil
+			}
+			if i+1 >= len(words) {
+				return "", fmt.Errorf("%s needs a value", name)
+			}
+			i++
+			return words[i], nil
+		}
+
+		var v string
+		switch name {
+		case "-X", "--request":
+			if v, err = arg(); err == nil {
+				method = strings.ToUpper(v)
+			}
+		case "-H", "--header":
+			if v, err = arg(); err == nil {
+				if key, value, ok := strings.Cut(v, ":"); ok {
+					cfg.header.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+				}
+			}
+		case "-d", "--data", "--data-ascii", "--data-binary":
+			if v, err = arg(); err == nil {
+				if v, err = readCurlData(v, name == "--data-binary"); err == nil {
+					data = append(data, v)
+				}
+			}
+		case "--data-raw":
+			if v, err = arg(); err == nil {
+				data = append(data, v)
+			}
+		case "--data-urlencode":
+			if v, err = arg(); err == nil {
+				key, value, ok := strings.Cut(v, "=")
+				if ok {
+					v = key + "=" + url.QueryEscape(value)
+				} else {
+					v = url.QueryEscape(v)
+				}
+				data = append(data, v)
+			}
+		case "-F", "--form", "--form-string":
+			err = errors.New("-F sends a multipart form, which can't be imported; use -d")
+		case "-u", "--user":
+			if v, err = arg(); err == nil {
+				cfg.header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(v)))
+			}
+		case "-A", "--user-agent":
+			if v, err = arg(); err == nil {
+				cfg.header.Set("User-Agent", v)
+			}
+		case "-e", "--referer":
+			if v, err = arg(); err == nil {
+				cfg.header.Set("Referer", v)
+			}
+		case "-b", "--cookie":
+			if v, err = arg(); err == nil {
+				cfg.header.Set("Cookie", v)
+			}
+		case "--url":
+			target, err = arg()
+		case "-I", "--head":
+			method = http.MethodHead
+		case "-G", "--get":
+			getData = true
+		case "-o", "--output", "-w", "--write-out", "-m", "--max-time", "--connect-timeout", "-x", "--proxy":
+			// Options with a value we don't use; skip the value too.
+			_, err = arg()
+		default:
+			if !curlSwitches[name] {
+				err = fmt.Errorf("curl option %s is not supported", name)
+			}
+		}
+		if err != nil {
+			return cfg, err
+		}
+	}
+	if target == "" {
+		return cfg, errors.New("the curl command has no URL")
+	}
+
+	// Like curl: data makes it a POST, unless -G moves it into the query.
+	body := strings.Join(data, "&")
+	switch {
+	case getData && body != "":
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + body
+		body = ""
+	case body != "" && cfg.header.Get("Content-Type") == "":
+		cfg.header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if method == "" {
+		method = http.MethodGet
+		if body != "" {
+			method = http.MethodPost
+		}
+	}
+
+	// curl guesses http:// for a URL without a scheme, and so do we.
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	if target, err = normalizeURL(target); err != nil {
+		return cfg, err
+	}
+	cfg.method, cfg.url, cfg.path = method, target, target
+	cfg.body = nil
+	if body != "" {
+		cfg.body = []byte(body)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShellSplit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{`curl -H 'A: b c' "x y"`, []string{"curl", "-H", "A: b c", "x y"}},
+		{"curl \\\n  -d a\\ b", []string{"curl", "-d", "a b"}},
+		{`curl $'line\none' x`, []string{"curl", "line\none", "x"}},
+		{`curl "say \"hi\""`, []string{"curl", `say "hi"`}},
+	}
+	for _, tt := range tests {
+		got, err := shellSplit(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shellSplit(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := shellSplit(`curl 'open`); err == nil {
+		t.Error("an unterminated quote was accepted")
+	}
+}
+
+// curlConfig imports cmd into an empty config, failing the test on an error.
+func curlConfig(t *testing.T, cmd string) config {
+	t.Helper()
+	cfg, err := fromCurl(config{}, cmd)
+	if err != nil {
+		t.Fatalf("fromCurl(%q): %v", cmd, err)
+	}
+	return cfg
+}
+
+func TestFromCurl(t *testing.T) {
+	cfg := curlConfig(t, `curl -sSL -XPOST -HAccept:text/plain 'https://example.com/api' -H 'X-Id: 7' --data-raw '@me' --compressed`)
+	if cfg.method != http.MethodPost || cfg.url != "https://example.com/api" {
+		t.Errorf("got %s %s", cfg.method, cfg.url)
+	}
+	if cfg.header.Get("Accept") != "text/plain" || cfg.header.Get("X-Id") != "7" {
+		t.Errorf("headers = %v", cfg.header)
+	}
+	if string(cfg.body) != "@me" {
+		t.Errorf("body = %q, want the literal @me", cfg.body)
+	}
+
+	cfg = curlConfig(t, `curl -G example.com/s?x=1 -d q=a --data-urlencode 'n=b c'`)
+	if cfg.method != http.MethodGet || cfg.url != "http://example.com/s?x=1&q=a&n=b+c" || cfg.body != nil {
+		t.Errorf("-G: got %s %s %q", cfg.method, cfg.url, cfg.body)
+	}
+
+	cfg = curlConfig(t, `curl -I --url=https://example.com/`)
+	if cfg.method != http.MethodHead || cfg.url != "https://example.com/" {
+		t.Errorf("-I: got %s %s", cfg.method, cfg.url)
+	}
+}
+
+func TestFromCurlDataFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "body.txt")
+	if err := os.WriteFile(name, []byte("a=1\n&b=2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := curlConfig(t, "curl https://example.com/ -d @"+name)
+	if string(cfg.body) != "a=1&b=2" {
+		t.Errorf("-d @file body = %q", cfg.body)
+	}
+	cfg = curlConfig(t, "curl https://example.com/ --data-binary @"+name)
+	if string(cfg.body) != "a=1\n&b=2\n" {
+		t.Errorf("--data-binary @file body = %q", cfg.body)
+	}
+}
+
+func TestFromCurlErrors(t *testing.T) {
+	for cmd, want := range map[string]string{
+		"curl https://example.com/ --cacert ca.pem": "--cacert is not supported",
+		"curl -c jar https://example.com/":          "-c is not supported",
+		"curl --retry 3 https://example.com/":       "--retry is not supported",
+		"curl -T up.txt https://example.com/":       "-T is not supported",
+		"curl -F a=b https://example.com/":          "multipart",
+		"curl -sZ https://example.com/":             "-Z",
+		"curl -d @- https://example.com/":           "standard input",
+		"curl -H":                                   "needs a value",
+		"curl -s":                                   "no URL",
+		"wget https://example.com/":                 "not a curl command",
+	} {
+		_, err := fromCurl(config{}, cmd)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("fromCurl(%q) error = %v, want %q", cmd, err, want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultDiscoverRate is how many paths a second discovery probes unless
+// told otherwise, gentle enough not to trip most rate limits.
+const defaultDiscoverRate = 10
+
+// maxDiscoverRate caps the rate discovery may be asked for.
+const maxDiscoverRate = 200
+
+// commonPaths are the paths discovery probes without a wordlist: the
+// admin pages, API docs, health checks and leftovers sites most often have.
+var commonPaths = []string{
+	".env", ".git/HEAD", ".git/config", ".gitignore", ".htaccess", ".svn/entries", ".well-known/security.txt",
+	".DS_Store", "actuator", "actuator/env", "actuator/health", "admin", "admin/login", "administrator",
+	"api", "api-docs", "api/docs", "api/health", "api/v1", "api/v2", "backup", "backup.zip", "config",
+	"config.json", "console", "dashboard", "debug", "debug/pprof", "docs", "dump.sql", "env", "graphql",
+	"graphiql", "health", "healthz", "info", "internal", "login", "manage", "metrics", "openapi.json",
+	"openapi.yaml", "phpinfo.php", "phpmyadmin", "ping", "readyz", "robots.txt", "server-status",
+	"sitemap.xml", "status", "swagger", "swagger-ui.html", "swagger.json", "test", "v1", "v2", "version",
+	"web.config", "wp-admin", "wp-login.php",
+}
+
+// discovered is what one probed path answered.
+type discovered struct {
+	path     string
+	url      string
+	status   int
+	size     int64  // Bytes of body, -1 if unknown.
+	location string // Where a redirect points.
+	err      error
+}
+
+// runDiscover is the palette's `discover [wordlist] [rate]`: it probes the
+// paths of a wordlist file, one per line, or commonPaths, under the URL,
+// at most rate a second, and lists those that exist. When the server
+// answers every path, real or not, alike, paths that answer the same way
+// are left out.
+func runDiscover(m model, args []string) (tea.Model, tea.Cmd) {
+	words, rate := commonPaths, defaultDiscoverRate
+	for _, arg := range args {
+		if n, err := strconv.Atoi(arg); err == nil {
+			if n < 1 || n > maxDiscoverRate {
+				return m.paletteError(fmt.Errorf("a rate of %d is not from 1 to %d a second", n, maxDiscoverRate))
+			}
+			rate = n
+			continue
+		}
+		var err error
+		if words, err = readWordlist(arg); err != nil {
+			return m.paletteError(err)
+		}
+	}
+	if isFileTransfer(m.cfg.url) {
+		return m.paletteError(errors.New("only HTTP servers can be probed"))
+	}
+	base, err := url.Parse(m.cfg.url)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	base.RawQuery, base.Fragment = "", ""
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	cfg := m.cfg
+	m.job = fmt.Sprintf("Probing %d paths under %s", len(words), base)
+	return m, runJob(func(report func(string)) tea.Msg {
+		return discover(cfg, base, words, rate, report)
+	})
+}
+
+// readWordlist reads a file of paths, one per line; blank lines and lines
+// starting with # are skipped.
+func readWordlist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var words []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		w := strings.TrimSpace(sc.Text())
+		if w != "" && !strings.HasPrefix(w, "#") {
+			words = append(words, strings.TrimPrefix(w, "/"))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("%s lists no paths", path)
+	}
+	return words, nil
+}
+
+// discover probes words under base and reports the paths that exist.
+func discover(cfg config, base *url.URL, words []string, rate int, report func(string)) tea.Msg {
+	c := newClient(cfg)
+	// A redirect is worth knowing about in itself, so it isn't followed.
+	c.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	probe := func(word string) discovered {
+		target := base.ResolveReference(&url.URL{Path: word})
+		d := discovered{path: "/" + strings.TrimPrefix(target.Path, base.Path), url: target.String(), size: -1}
+		req, err := http.NewRequest(http.MethodGet, d.url, nil)
+		if err != nil {
+			d.err = err
+			return d
+		}
+		for k, v := range cfg.header {
+			req.Header[k] = v
+		}
+		res, err := c.Do(req)
+		if err != nil {
+			d.err = err
+			return d
+		}
+		defer res.Body.Close()
+		d.status, d.location = res.StatusCode, res.Header.Get("Location")
+		d.size, _ = io.Copy(io.Discard, io.LimitReader(res.Body, maxBody))
+		return d
+	}
+
+	// A path that can't exist shows how the server answers for those that
+	// don't; many answer 200 with a page of their own.
+	missing := probe(randomHex(8))
+
+	tick := time.NewTicker(time.Second / time.Duration(rate))
+	defer tick.Stop()
+	results := make([]discovered, len(words))
+	var wg sync.WaitGroup
+	for i, w := range words {
+		<-tick.C
+		report(fmt.Sprintf("Probing: %d of %d paths", i+1, len(words)))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = probe(w)
+		}()
+	}
+	wg.Wait()
+	return describeDiscovery(base, missing, results)
+}
+
+// notFound reports whether d answered the way a path that doesn't exist
+// does, going by missing.
+func notFound(d, missing discovered) bool {
+	if d.err != nil || d.status == http.StatusNotFound || d.status == http.StatusGone {
+		return true
+	}
+	return missing.err == nil && missing.status != http.StatusNotFound && d.status == missing.status &&
+		(d.status/100 == 3 && d.location == missing.location || d.status/100 != 3 && d.size == missing.size)
+}
+
+// describeDiscovery lists what discovery found as a table of links.
+func describeDiscovery(base *url.URL, missing discovered, results []discovered) reportMsg {
+	r := reportMsg{title: "Discovery " + base.String()}
+	var found []discovered
+	errs := 0
+	for _, d := range results {
+		if d.err != nil {
+			errs++
+		}
+		if !notFound(d, missing) {
+			found = append(found, d)
+		}
+	}
+	slices.SortStableFunc(found, func(a, b discovered) int { return a.status - b.status })
+
+	r.body = fmt.Sprintf("%d of %d paths exist", len(found), len(results))
+	if missing.err == nil && missing.status != http.StatusNotFound {
+		r.body += fmt.Sprintf("; the server answers %d to paths that don't, so those answering the same were left out", missing.status)
+	}
+	if errs > 0 {
+		r.body += fmt.Sprintf("; %d got no answer", errs)
+	}
+	width := 0
+	for _, d := range found {
+		width = max(width, len(d.path))
+	}
+	for _, d := range found {
+		label := fmt.Sprintf("%d  %-*s  %9s", d.status, width, d.path, formatSize(d.size))
+		if d.location != "" {
+			label += "  → " + d.location
+		}
+		r.links = append(r.links, d.url)
+		r.labels = append(r.labels, label)
+	}
+	return r
+}
+
+// formatSize writes a body size for the table.
+func formatSize(n int64) string {
+	switch {
+	case n < 0:
+		return "?"
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f kB", float64(n)/1024)
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+}
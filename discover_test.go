@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/admin":
+			http.Redirect(w, r, "/app/admin/login", http.StatusFound)
+		case "/app/health":
+			w.Write([]byte("ok"))
+		case "/app/secret":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	base, _ := url.Parse(srv.URL + "/app/")
+	r := discover(config{}, base, []string{"admin", "health", "secret", "nothing"}, maxDiscoverRate, func(string) {}).(reportMsg)
+	if r.body != "3 of 4 paths exist" {
+		t.Errorf("body = %q", r.body)
+	}
+	if len(r.labels) != 3 || !strings.HasPrefix(r.labels[0], "200  /health") || !strings.Contains(r.labels[1], "→ /app/admin/login") || !strings.HasPrefix(r.labels[2], "403  /secret") {
+		t.Errorf("labels = %q", r.labels)
+	}
+	if r.links[0] != srv.URL+"/app/health" {
+		t.Errorf("links = %q", r.links)
+	}
+}
+
+func TestDiscoverSoft404(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/real" {
+			w.Write([]byte("a real page"))
+			return
+		}
+		w.Write([]byte("page not found"))
+	}))
+	defer srv.Close()
+	base, _ := url.Parse(srv.URL + "/")
+	r := discover(config{}, base, []string{"real", "fake", "other"}, maxDiscoverRate, func(string) {}).(reportMsg)
+	if !strings.HasPrefix(r.body, "1 of 3 paths exist; the server answers 200 to paths that don't") || !slices.Equal(r.links, []string{srv.URL + "/real"}) {
+		t.Errorf("report = %+v", r)
+	}
+}
+
+func TestReadWordlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	os.WriteFile(path, []byte("# common\n/admin\n\n  api/v1  \n"), 0o644)
+	if words, err := readWordlist(path); err != nil || !slices.Equal(words, []string{"admin", "api/v1"}) {
+		t.Errorf("words = %q, %v", words, err)
+	}
+	os.WriteFile(path, []byte("# nothing\n"), 0o644)
+	if _, err := readWordlist(path); err == nil {
+		t.Error("an empty wordlist was read")
+	}
+	if got := formatSize(1536); got != "1.5 kB" {
+		t.Errorf("formatSize = %q", got)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// resolvConf is where the system's DNS servers are listed.
+const resolvConf = "/etc/resolv.conf"
+
+// dnsTimeout is how long we wait for a DNS server to answer one query.
+const dnsTimeout = 5 * time.Second
+
+// dnsTypes are the record types the lookup pane asks for, in display order.
+var dnsTypes = []dnsmessage.Type{
+	dnsmessage.TypeA, dnsmessage.TypeAAAA, dnsmessage.TypeCNAME, dnsmessage.TypeMX, dnsmessage.TypeTXT,
+}
+
+// dnsRecord is one answer to a query, as shown in the pane.
+type dnsRecord struct {
+	name  string // Owner name, e.g. "www.example.com.".
+	typ   dnsmessage.Type
+	value string
+	ttl   uint32
+}
+
+// lookupDNS returns a command that asks a DNS server about the host of
+// cfg.url and reports its records with their TTLs. The server is the one
+// given with -dns-server, or the system's first.
+func lookupDNS(cfg config) tea.Cmd {
+	return func() tea.Msg {
+		u, err := url.Parse(cfg.url)
+		if err != nil {
+			return errMsg{err}
+		}
+		host := u.Hostname()
+		if net.ParseIP(host) != nil {
+			return reportMsg{title: "DNS", body: host + " is an IP address; there is no name to look up."}
+		}
+
+		server, source := cfg.dnsServer, "-dns-server"
+		if server == "" {
+			if server, err = systemResolver(resolvConf); err != nil {
+				return reportMsg{title: "DNS", body: "Couldn't find a DNS server: " + err.Error()}
+			}
+			source = resolvConf
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+
+		// Ask about every type at once; they don't depend on each other.
+		answers := make([][]dnsRecord, len(dnsTypes))
+		errs := make([]error, len(dnsTypes))
+		var wg sync.WaitGroup
+		for i, t := range dnsTypes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				answers[i], errs[i] = dnsQuery(server, host, t)
+			}()
+		}
+		wg.Wait()
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "Resolver: %s (from %s)\n", server, source)
+		for i, t := range dnsTypes {
+			name := strings.TrimPrefix(t.String(), "Type")
+			switch {
+			case errs[i] != nil:
+				fmt.Fprintf(&b, "\n%-6s %v", name, errs[i])
+			case len(answers[i]) == 0:
+				fmt.Fprintf(&b, "\n%-6s none", name)
+			}
+			for _, r := range answers[i] {
+				fmt.Fprintf(&b, "\n%-6s %s  TTL %s", strings.TrimPrefix(r.typ.String(), "Type"), r.value, time.Duration(r.ttl)*time.Second)
+				if !strings.EqualFold(r.name, host+".") {
+					fmt.Fprintf(&b, "  (for %s)", r.name)
+				}
+			}
+		}
+		return reportMsg{title: "DNS " + host, body: b.String()}
+	}
+}
+
+// systemResolver returns the first nameserver listed in a resolv.conf.
+func systemResolver(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if fields := strings.Fields(s.Text()); len(fields) > 1 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53"), nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s lists no nameserver", path)
+}
+
+// dnsQuery asks server for the records of type t for name. It asks over
+// UDP, and again over TCP if the answer didn't fit in a datagram. Answers
+// of other types, such as the CNAME an A query is led through, are left
+// out; the CNAME query shows those.
+func dnsQuery(server, name string, t dnsmessage.Type) ([]dnsRecord, error) {
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return nil, err
+	}
+	id := uint16(rand.Uint32())
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: t, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	answer, err := dnsExchange("udp", server, query)
+	if err != nil {
+		return nil, err
+	}
+	var msg dnsmessage.Message
+	if err := msg.Unpack(answer); err != nil {
+		return nil, fmt.Errorf("reading the answer: %w", err)
+	}
+	if msg.Truncated {
+		if answer, err = dnsExchange("tcp", server, query); err != nil {
+			return nil, err
+		}
+		if err := msg.Unpack(answer); err != nil {
+			return nil, fmt.Errorf("reading the answer: %w", err)
+		}
+	}
+	if msg.ID != id {
+		return nil, errors.New("the answer is to another query")
+	}
+	switch msg.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, errors.New("no such name")
+	default:
+		return nil, fmt.Errorf("the server answered %s", strings.TrimPrefix(msg.RCode.String(), "RCode"))
+	}
+
+	var records []dnsRecord
+	for _, a := range msg.Answers {
+		if a.Header.Type != t {
+			continue
+		}
+		r := dnsRecord{name: a.Header.Name.String(), typ: a.Header.Type, ttl: a.Header.TTL}
+		switch body := a.Body.(type) {
+		case *dnsmessage.AResource:
+			r.value = net.IP(body.A[:]).String()
+		case *dnsmessage.AAAAResource:
+			r.value = net.IP(body.AAAA[:]).String()
+		case *dnsmessage.CNAMEResource:
+			r.value = body.CNAME.String()
+		case *dnsmessage.MXResource:
+			r.value = fmt.Sprintf("%d %s", body.Pref, body.MX)
+		case *dnsmessage.TXTResource:
+			r.value = fmt.Sprintf("%q", strings.Join(body.TXT, ""))
+		default:
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// dnsExchange sends a packed query to server over network, udp or tcp, and
+// returns the packed answer. Over TCP each message is prefixed with its length.
+func dnsExchange(network, server string, query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout(network, server, dnsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(dnsTimeout))
+
+	if network == "udp" {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 65535)
+		n, err := conn.Read(buf)
+		return buf[:n], err
+	}
+
+	framed := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(framed, query...)); err != nil {
+		return nil, err
+	}
+	var size [2]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+	_, err = io.ReadFull(conn, buf)
+	return buf, err
+}
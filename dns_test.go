@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeDNS answers queries on a local UDP and TCP port from records, keyed
+// by type. With truncate set, UDP answers only say they were cut short.
+func fakeDNS(t *testing.T, records map[dnsmessage.Type][]dnsmessage.Resource, truncate bool) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Skipf("can't listen on the same TCP port: %v", err)
+	}
+	t.Cleanup(func() { pc.Close(); ln.Close() })
+
+	answer := func(query []byte, udp bool) []byte {
+		var q dnsmessage.Message
+		if err := q.Unpack(query); err != nil {
+			return nil
+		}
+		res := dnsmessage.Message{Header: dnsmessage.Header{ID: q.ID, Response: true}, Questions: q.Questions}
+		switch {
+		case strings.HasPrefix(q.Questions[0].Name.String(), "missing."):
+			res.RCode = dnsmessage.RCodeNameError
+		case udp && truncate:
+			res.Truncated = true
+		default:
+			res.Answers = records[q.Questions[0].Type]
+		}
+		b, _ := res.Pack()
+		return b
+	}
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(answer(buf[:n], true), addr)
+		}
+	}()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			var size [2]byte
+			io.ReadFull(c, size[:])
+			query := make([]byte, binary.BigEndian.Uint16(size[:]))
+			io.ReadFull(c, query)
+			b := answer(query, false)
+			c.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(b))), b...))
+			c.Close()
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+// testRecords are the records of www.example.com, a CNAME to edge.example.net.
+func testRecords() map[dnsmessage.Type][]dnsmessage.Resource {
+	www := dnsmessage.MustNewName("www.example.com.")
+	edge := dnsmessage.MustNewName("edge.example.net.")
+	cname := dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: www, Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET, TTL: 300},
+		Body:   &dnsmessage.CNAMEResource{CNAME: edge},
+	}
+	return map[dnsmessage.Type][]dnsmessage.Resource{
+		dnsmessage.TypeA: {cname, {
+			Header: dnsmessage.ResourceHeader{Name: edge, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+		}},
+		dnsmessage.TypeCNAME: {cname},
+		dnsmessage.TypeTXT: {{
+			Header: dnsmessage.ResourceHeader{Name: www, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET, TTL: 3600},
+			Body:   &dnsmessage.TXTResource{TXT: []string{"v=spf1 ", "-all"}},
+		}},
+	}
+}
+
+func TestDNSQuery(t *testing.T) {
+	for _, truncate := range []bool{false, true} {
+		server := fakeDNS(t, testRecords(), truncate)
+		records, err := dnsQuery(server, "www.example.com", dnsmessage.TypeA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The CNAME the answer goes through is left to the CNAME query.
+		if len(records) != 1 || records[0].value != "192.0.2.1" || records[0].ttl != 60 || records[0].name != "edge.example.net." {
+			t.Errorf("truncate=%v: A records = %+v", truncate, records)
+		}
+	}
+
+	server := fakeDNS(t, testRecords(), false)
+	if _, err := dnsQuery(server, "missing.example.com", dnsmessage.TypeA); err == nil || err.Error() != "no such name" {
+		t.Errorf("NXDOMAIN error = %v", err)
+	}
+}
+
+func TestLookupDNS(t *testing.T) {
+	server := fakeDNS(t, testRecords(), false)
+	msg := lookupDNS(config{url: "https://www.example.com/x", dnsServer: server})()
+	r, ok := msg.(reportMsg)
+	if !ok {
+		t.Fatalf("lookupDNS = %#v", msg)
+	}
+	for _, want := range []string{
+		"Resolver: " + server + " (from -dns-server)",
+		"A      192.0.2.1  TTL 1m0s  (for edge.example.net.)",
+		"AAAA   none",
+		"CNAME  edge.example.net.  TTL 5m0s",
+		"MX     none",
+		`TXT    "v=spf1 -all"  TTL 1h0m0s`,
+	} {
+		if !strings.Contains(r.body, want) {
+			t.Errorf("report lacks %q:\n%s", want, r.body)
+		}
+	}
+
+	msg = lookupDNS(config{url: "http://127.0.0.1:8080/"})()
+	if r, ok := msg.(reportMsg); !ok || !strings.Contains(r.body, "is an IP address") {
+		t.Errorf("lookupDNS of an IP = %#v", msg)
+	}
+}
+
+func TestSystemResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	os.WriteFile(path, []byte("# comment\nsearch lan\nnameserver fe80::1\nnameserver 10.0.0.1\n"), 0o644)
+	if got, err := systemResolver(path); err != nil || got != "[fe80::1]:53" {
+		t.Errorf("systemResolver = %q, %v", got, err)
+	}
+	os.WriteFile(path, []byte("search lan\n"), 0o644)
+	if _, err := systemResolver(path); err == nil {
+		t.Error("systemResolver found a server in a file without one")
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Compose maps each container's port to one of its choosing on the host,
+// and which it was is the first thing to look up before any request.
+// The palette's `docker` asks the Docker daemon which containers are
+// running and where their ports are published, to pick one; it goes into
+// the resend prompt with the path of the request on screen.
+
+// dockerTitle is what the picker of containers is titled.
+const dockerTitle = "Docker containers"
+
+// dockerTimeout bounds how long the daemon may take to list containers.
+const dockerTimeout = 5 * time.Second
+
+// dockerEndpoint is one published port of a running container.
+type dockerEndpoint struct {
+	container string // Its name, e.g. shop-web-1.
+	service   string // Its compose service and project, e.g. "web, shop"; "" if it has none.
+	image     string
+	host      string // Where on the host the port is, e.g. localhost:8080.
+	port      string // The container's own port and protocol, e.g. 80/tcp.
+	scheme    string // What a URL to it starts with, guessed from the port.
+}
+
+// url is where a request to path and query goes to reach e.
+func (e dockerEndpoint) url(current string) string {
+	target := e.scheme + "://" + e.host
+	if u, err := url.Parse(current); err == nil && u.Host != "" {
+		return target + u.RequestURI()
+	}
+	return target + "/"
+}
+
+// dockerClient returns an HTTP client that talks to the daemon DOCKER_HOST
+// names, on its Unix socket by default, and the URL the API is under.
+func dockerClient() (*http.Client, string, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = "unix:///var/run/docker.sock"
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, "", fmt.Errorf("DOCKER_HOST %q: %w", host, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		tr := &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", u.Path)
+		}}
+		return &http.Client{Transport: tr, Timeout: dockerTimeout}, "http://docker", nil
+	case "tcp", "http":
+		return &http.Client{Timeout: dockerTimeout}, "http://" + u.Host, nil
+	}
+	return nil, "", fmt.Errorf("DOCKER_HOST %q: only unix:// and tcp:// daemons are supported", host)
+}
+
+// dockerPublishHost is the host a port published on ip is reached at: the
+// daemon's, if it is elsewhere, and otherwise this one.
+func dockerPublishHost(ip string) string {
+	if u, err := url.Parse(os.Getenv("DOCKER_HOST")); err == nil && u.Scheme == "tcp" {
+		return u.Hostname()
+	}
+	switch ip {
+	case "", "0.0.0.0", "::":
+		return "localhost"
+	}
+	return ip
+}
+
+// findDockerEndpoints lists the published TCP ports of the running
+// containers, by container name and port.
+func findDockerEndpoints() tea.Msg {
+	fail := func(err error) tea.Msg { return endpointsMsg{title: dockerTitle, err: err} }
+	client, base, err := dockerClient()
+	if err != nil {
+		return fail(err)
+	}
+	res, err := client.Get(base + "/containers/json")
+	if err != nil {
+		return fail(fmt.Errorf("asking the Docker daemon: %w", err))
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<10))
+		return fail(fmt.Errorf("the Docker daemon answered %s: %s", res.Status, strings.TrimSpace(string(b))))
+	}
+	var containers []struct {
+		Names  []string
+		Image  string
+		Labels map[string]string
+		Ports  []struct {
+			IP          string
+			PrivatePort int
+			PublicPort  int
+			Type        string
+		}
+	}
+	if err := json.NewDecoder(res.Body).Decode(&containers); err != nil {
+		return fail(fmt.Errorf("reading the containers: %w", err))
+	}
+
+	var out []dockerEndpoint
+	seen := map[string]bool{}
+	for _, c := range containers {
+		name := ""
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		service := c.Labels["com.docker.compose.service"]
+		if p := c.Labels["com.docker.compose.project"]; service != "" && p != "" {
+			service += ", " + p
+		}
+		for _, p := range c.Ports {
+			if p.PublicPort == 0 || p.Type != "tcp" {
+				continue
+			}
+			e := dockerEndpoint{
+				container: name, service: service, image: c.Image,
+				host:   net.JoinHostPort(dockerPublishHost(p.IP), strconv.Itoa(p.PublicPort)),
+				port:   fmt.Sprintf("%d/%s", p.PrivatePort, p.Type),
+				scheme: "http",
+			}
+			if p.PrivatePort == 443 || p.PrivatePort == 8443 {
+				e.scheme = "https"
+			}
+			// A port is published on IPv4 and IPv6 alike.
+			if key := name + " " + e.host; !seen[key] {
+				seen[key] = true
+				out = append(out, e)
+			}
+		}
+	}
+	slices.SortStableFunc(out, func(a, b dockerEndpoint) int { return strings.Compare(a.container, b.container) })
+	msg := endpointsMsg{title: dockerTitle}
+	for _, e := range out {
+		msg.endpoints = append(msg.endpoints, endpoint{label: e.label(), url: e.url})
+	}
+	return msg
+}
+
+// dockerPicker is the picker of the ports the running containers publish.
+var dockerPicker = endpointPicker{
+	title:  dockerTitle,
+	asking: "Asking the Docker daemon",
+	none:   "No running container publishes a TCP port",
+	find:   findDockerEndpoints,
+}
+
+// runDocker is the palette's `docker [words]`: it lists the ports the
+// running containers publish, of those matching words if any, to pick one.
+func runDocker(m model, args []string) (tea.Model, tea.Cmd) {
+	return m.openPicker(dockerPicker, args)
+}
+
+// label is how the picker lists e.
+func (e dockerEndpoint) label() string {
+	name := e.container
+	if e.service != "" {
+		name += " (" + e.service + ")"
+	}
+	return fmt.Sprintf("%s  %s → %s  %s", name, e.host, e.port, e.image)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fakeDocker serves the Docker API's container list on a Unix socket, and
+// points DOCKER_HOST at it.
+func fakeDocker(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skip("no Unix sockets:", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`[
+  {"Names": ["/shop-web-1"], "Image": "nginx:1.27", "Labels": {"com.docker.compose.service": "web", "com.docker.compose.project": "shop"},
+   "Ports": [{"IP": "0.0.0.0", "PrivatePort": 80, "PublicPort": 32768, "Type": "tcp"}, {"IP": "::", "PrivatePort": 80, "PublicPort": 32768, "Type": "tcp"},
+             {"PrivatePort": 9000, "Type": "tcp"}, {"IP": "0.0.0.0", "PrivatePort": 53, "PublicPort": 5353, "Type": "udp"}]},
+  {"Names": ["/api"], "Image": "api:dev", "Ports": [{"IP": "127.0.0.1", "PrivatePort": 8443, "PublicPort": 8443, "Type": "tcp"}]}
+]`))
+	})}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	t.Setenv("DOCKER_HOST", "unix://"+sock)
+}
+
+func TestDockerEndpoints(t *testing.T) {
+	fakeDocker(t)
+	msg := findDockerEndpoints().(endpointsMsg)
+	if msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	var got []string
+	for _, e := range msg.endpoints {
+		got = append(got, e.label)
+	}
+	want := []string{"api  127.0.0.1:8443 → 8443/tcp  api:dev", "shop-web-1 (web, shop)  localhost:32768 → 80/tcp  nginx:1.27"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDockerPicker(t *testing.T) {
+	fakeDocker(t)
+	m := model{cfg: config{method: "GET", url: "http://localhost:3000/api/users?page=2", header: http.Header{}}}
+	next, cmd := runDocker(m, []string{"web"})
+	next, _ = next.(model).Update(cmd())
+	if v := next.(model).View(); !strings.Contains(v, "> shop-web-1 (web, shop)") || strings.Contains(v, "api:dev") {
+		t.Errorf("view:\n%s", v)
+	}
+	next, _ = next.(model).Update(tea.KeyMsg{Type: tea.KeyEnter})
+	p := next.(model).prompt
+	if next.(model).picker != nil || p == nil || p.Value() != "GET http://localhost:32768/api/users?page=2" {
+		t.Errorf("prompt = %v", p)
+	}
+}
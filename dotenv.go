@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Scripts in a terminal keep their settings in .env files and the shell's
+// environment; the program keeps them in an environment's variables.
+// env-import reads a .env file into an environment, and env-export writes
+// an environment out as export lines for a shell to eval, so that both use
+// the same values:
+//
+//	httpwizard env-import dev .env
+//	eval "$(httpwizard env-export dev)"
+
+// parseDotenv reads the NAME=value lines of a .env file. A line may start
+// with export; a # starts a comment, outside quotes. A value in single
+// quotes is taken as it is, and one in double quotes may have \n, \t, \"
+// and \\ in it; either may go on over several lines.
+func parseDotenv(text string) (map[string]string, error) {
+	vars := map[string]string{}
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		n := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		name, value, ok := strings.Cut(line, "=")
+		if name = strings.TrimSpace(name); !ok || !variableName.MatchString(name) {
+			return nil, fmt.Errorf("line %d: not NAME=value", n)
+		}
+		value = strings.TrimLeft(value, " \t")
+		if value == "" || (value[0] != '"' && value[0] != '\'') {
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = value[:j]
+			}
+			vars[name] = strings.TrimSpace(value)
+			continue
+		}
+
+		quote := value[0]
+		value = value[1:]
+		var b strings.Builder
+		for closed := false; !closed; {
+			j := 0
+			for ; j < len(value); j++ {
+				c := value[j]
+				if c == quote {
+					closed = true
+					break
+				}
+				if c == '\\' && quote == '"' && j+1 < len(value) {
+					j++
+					switch value[j] {
+					case 'n':
+						c = '\n'
+					case 't':
+						c = '\t'
+					case '"', '\\', '$':
+						c = value[j]
+					default:
+						b.WriteByte('\\')
+						c = value[j]
+					}
+				}
+				b.WriteByte(c)
+			}
+			if closed {
+				if rest := strings.TrimSpace(value[j+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+					return nil, fmt.Errorf("line %d: %q after the closing quote", i+1, rest)
+				}
+				break
+			}
+			if i++; i == len(lines) {
+				return nil, fmt.Errorf("line %d: the quote is never closed", n)
+			}
+			b.WriteByte('\n')
+			value = lines[i]
+		}
+		vars[name] = b.String()
+	}
+	return vars, nil
+}
+
+// shellQuote quotes s for a POSIX shell, in single quotes.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// saveEnvironments writes envs to the environments file at path, keeping
+// its permissions; a new one only the user can read, as its variables may
+// be credentials.
+func saveEnvironments(path string, envs map[string]environment) error {
+	b, err := json.MarshalIndent(envs, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if _, err := os.Stat(path); err == nil {
+		return writeKeepingMode(path, b)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+// runEnvImport implements `env-import [-env-file FILE] [-keep] ENV FILE.env`.
+func runEnvImport(args []string) error {
+	fs := flag.NewFlagSet("env-import", flag.ExitOnError)
+	envFile := fs.String("env-file", defaultEnvFile(), "JSON `file` of named environments to import into")
+	keep := fs.Bool("keep", false, "keep the values the environment has already, importing only new variables")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		return errors.New("usage: env-import [-env-file FILE] [-keep] ENV FILE.env")
+	}
+	b, err := os.ReadFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	vars, err := parseDotenv(string(b))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(1), err)
+	}
+	return importDotenv(os.Stdout, *envFile, fs.Arg(0), vars, *keep)
+}
+
+// importDotenv puts vars into the environment name of the environments file
+// at envFile, adding the environment if there is none, and says on w what
+// changed. With keep, the variables it has already keep their values.
+func importDotenv(w io.Writer, envFile, name string, vars map[string]string, keep bool) error {
+	envs, err := loadEnvironments(envFile)
+	if err != nil {
+		return err
+	}
+	env, existed := envs[name]
+	if env.Variables == nil {
+		env.Variables = map[string]string{}
+	}
+	var added, changed, kept int
+	for _, k := range slices.Sorted(maps.Keys(vars)) {
+		old, ok := env.Variables[k]
+		switch {
+		case !ok:
+			added++
+		case old == vars[k]:
+			continue
+		case keep:
+			kept++
+			continue
+		default:
+			changed++
+		}
+		env.Variables[k] = vars[k]
+	}
+	if added+changed == 0 && existed {
+		fmt.Fprintf(w, "%s has all %d variables already; nothing changed\n", name, len(vars))
+		return nil
+	}
+	envs[name] = env
+	if err := saveEnvironments(envFile, envs); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Imported into %s: %d new, %d changed", name, added, changed)
+	if kept > 0 {
+		fmt.Fprintf(w, ", %d kept as they were", kept)
+	}
+	fmt.Fprintln(w)
+	if !existed {
+		fmt.Fprintf(w, "%s is a new environment; give it a base URL in %s\n", name, envFile)
+	}
+	return nil
+}
+
+// runEnvExport implements `env-export [-env-file FILE] ENV`.
+func runEnvExport(args []string) error {
+	fs := flag.NewFlagSet("env-export", flag.ExitOnError)
+	envFile := fs.String("env-file", defaultEnvFile(), "JSON `file` of named environments to export from")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: env-export [-env-file FILE] ENV")
+	}
+	envs, err := loadEnvironments(*envFile)
+	if err != nil {
+		return err
+	}
+	return exportEnvironment(os.Stdout, envs, fs.Arg(0))
+}
+
+// exportEnvironment writes the variables of the environment name in envs on
+// w as shell export lines, in order of name. A value that uses another
+// variable has it filled in, as a request would.
+func exportEnvironment(w io.Writer, envs map[string]environment, name string) error {
+	env, ok := envs[name]
+	if !ok {
+		return fmt.Errorf("there is no environment %q; there are %s", name, strings.Join(envNames(envs), ", "))
+	}
+	cfg := config{envs: envs, env: name}
+	for _, k := range slices.Sorted(maps.Keys(env.Variables)) {
+		fmt.Fprintf(w, "export %s=%s\n", k, shellQuote(expandVariables(cfg, env.Variables[k])))
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	vars, err := parseDotenv(`# settings
+export API_HOST=api.example.com
+TOKEN = abc123 # for dev
+EMPTY=
+GREETING="Hello, \"you\"\n"
+LITERAL='no \n here # or here'
+KEY="-----BEGIN KEY-----
+line
+-----END KEY-----"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"API_HOST": "api.example.com",
+		"TOKEN":    "abc123",
+		"EMPTY":    "",
+		"GREETING": "Hello, \"you\"\n",
+		"LITERAL":  `no \n here # or here`,
+		"KEY":      "-----BEGIN KEY-----\nline\n-----END KEY-----",
+	}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("got  %q\nwant %q", vars, want)
+	}
+
+	for _, bad := range []string{"just words", "1X=y", `A="open`, `A="x" y`} {
+		if _, err := parseDotenv(bad); err == nil {
+			t.Errorf("%q was read", bad)
+		}
+	}
+}
+
+func TestImportExportDotenv(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "httpwizard", "environments.json")
+	var out strings.Builder
+	if err := importDotenv(&out, envFile, "dev", map[string]string{"HOST": "localhost", "URL": "http://{{HOST}}/", "QUOTE": "it's"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "Imported into dev: 3 new, 0 changed\ndev is a new environment") {
+		t.Errorf("import: %s", out.String())
+	}
+	if info, _ := os.Stat(envFile); info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v", info.Mode())
+	}
+
+	out.Reset()
+	importDotenv(&out, envFile, "dev", map[string]string{"HOST": "127.0.0.1", "PORT": "8080"}, true)
+	if out.String() != "Imported into dev: 1 new, 0 changed, 1 kept as they were\n" {
+		t.Errorf("import -keep: %s", out.String())
+	}
+
+	envs, err := loadEnvironments(envFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := exportEnvironment(&out, envs, "dev"); err != nil {
+		t.Fatal(err)
+	}
+	want := "export HOST='localhost'\nexport PORT='8080'\nexport QUOTE='it'\\''s'\nexport URL='http://localhost/'\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+	if err := exportEnvironment(&out, envs, "prod"); err == nil {
+		t.Error("a missing environment was exported")
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// environment is one named target, such as dev or prod, from the
+// environments file. Relative request URLs are resolved against its base.
+type environment struct {
+	Base      string `json:"base"`      // e.g. "https://api.example.com/v1/"
+	Protected bool   `json:"protected"` // Changes sent through it need confirming, as for production.
+
+	Variables map[string]string `json:"variables,omitempty"` // What {{name}} stands for in its requests.
+
+	SSH      string    `json:"ssh,omitempty"`      // A jump host its requests go through, [user@]host[:port].
+	Registry *registry `json:"registry,omitempty"` // Where its NAME.service hosts are looked up.
+}
+
+// defaultEnvFile is where environments live unless -env-file says otherwise,
+// or "" for none when there is no config directory to keep them in.
+func defaultEnvFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "httpwizard", "environments.json")
+}
+
+// loadEnvironments reads the environments file at path, a JSON object
+// mapping names to environments:
+//
+//	{"dev": {"base": "http://localhost:8080/"}, "prod": {"base": "https://api.example.com/", "protected": true}}
+//
+// A missing file simply means there are no environments.
+func loadEnvironments(path string) (map[string]environment, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]environment{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var envs map[string]environment
+	if err := json.Unmarshal(b, &envs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return envs, nil
+}
+
+// envNames returns the names of envs in alphabetical order.
+func envNames(envs map[string]environment) []string {
+	names := make([]string, 0, len(envs))
+	for name := range envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveURL puts ref after the base URL of env. A ref with a leading slash
+// still goes under the base's path, so /users on https://api.test/v1 gives
+// https://api.test/v1/users rather than dropping the /v1 as RFC 3986 would.
+// Absolute refs are returned unchanged, so a full URL always wins over the
+// environment.
+func resolveURL(env environment, ref string) (string, error) {
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	if r.IsAbs() || env.Base == "" {
+		return ref, nil
+	}
+	base, err := url.Parse(env.Base)
+	if err != nil {
+		return "", fmt.Errorf("base URL %q: %w", env.Base, err)
+	}
+	if r.Host != "" {
+		// A //host/path ref only borrows the base's scheme.
+		r.Scheme = base.Scheme
+		return r.String(), nil
+	}
+	base.RawQuery, base.Fragment, base.RawFragment = "", "", ""
+	root := strings.TrimSuffix(base.String(), "/")
+	if r.Path == "" {
+		// Only a query or fragment; keep the base's path as it is.
+		return base.String() + ref, nil
+	}
+	return root + "/" + strings.TrimPrefix(ref, "/"), nil
+}
+
+// relativeTo returns ref as a path under the base URL of env, for a ref that
+// was already resolved to a full URL.
+func relativeTo(env environment, ref string) (string, bool) {
+	r, err := url.Parse(ref)
+	if err != nil || !r.IsAbs() {
+		return ref, true
+	}
+	root := strings.TrimSuffix(env.Base, "/")
+	if root == "" {
+		return "", false
+	}
+	if ref == root {
+		return "/", true
+	}
+	rest, ok := strings.CutPrefix(ref, root)
+	if !ok || !strings.ContainsAny(rest[:1], "/?#") {
+		return "", false
+	}
+	return rest, true
+}
+
+// switchEnv returns a copy of cfg pointed at the environment after the
+// current one, in alphabetical order, with its path re-resolved. A path
+// that has become a full URL is first taken back under the current
+// environment's base; one outside it can't follow the switch.
+func switchEnv(cfg config) (config, error) {
+	names := envNames(cfg.envs)
+	if len(names) == 0 {
+		return cfg, errors.New("no environments defined in " + cfg.envFile)
+	}
+	next := names[0]
+	for i, name := range names {
+		if name == cfg.env {
+			next = names[(i+1)%len(names)]
+		}
+	}
+	return inEnv(cfg, next)
+}
+
+// inEnv returns cfg with its URL moved from the active environment's base
+// to the base of the environment next.
+func inEnv(cfg config, next string) (config, error) {
+	if _, ok := cfg.envs[next]; !ok {
+		return cfg, fmt.Errorf("no environment %q in %s; there are %s", next, cfg.envFile, strings.Join(envNames(cfg.envs), ", "))
+	}
+	path, ok := relativeTo(cfg.envs[cfg.env], cfg.path)
+	if !ok {
+		return cfg, fmt.Errorf("%s is not under the base URL of %q, so switching environments wouldn't change it", cfg.path, cfg.env)
+	}
+	target, err := resolveURL(cfg.envs[next], path)
+	if err != nil {
+		return cfg, err
+	}
+	if target, err = normalizeURL(target); err != nil {
+		return cfg, err
+	}
+	cfg.env, cfg.url, cfg.path = next, target, path
+	return cfg, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveURL(t *testing.T) {
+	tests := []struct {
+		base, ref, want string
+	}{
+		{"https://api.test/v1", "/users", "https://api.test/v1/users"},
+		{"https://api.test/v1/", "/users", "https://api.test/v1/users"},
+		{"https://api.test/v1/", "users?x=1#f", "https://api.test/v1/users?x=1#f"},
+		{"https://api.test/v1?key=1", "/users", "https://api.test/v1/users"},
+		{"https://api.test/v1/", "?page=2", "https://api.test/v1/?page=2"},
+		{"https://api.test/v1", "", "https://api.test/v1"},
+		{"https://api.test/v1", "//cdn.test/a", "https://cdn.test/a"},
+		{"https://api.test/v1", "http://other.test/", "http://other.test/"},
+		{"", "/users", "/users"},
+	}
+	for _, tt := range tests {
+		got, err := resolveURL(environment{Base: tt.base}, tt.ref)
+		if err != nil || got != tt.want {
+			t.Errorf("resolveURL(%q, %q) = %q, %v, want %q", tt.base, tt.ref, got, err, tt.want)
+		}
+	}
+}
+
+func TestSwitchEnv(t *testing.T) {
+	cfg := config{
+		env:  "dev",
+		path: "/users?x=1",
+		envs: map[string]environment{
+			"dev":  {Base: "http://localhost:8080/v1/"},
+			"prod": {Base: "https://api.test/v1"},
+		},
+	}
+	cfg, err := switchEnv(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.env != "prod" || cfg.url != "https://api.test/v1/users?x=1" {
+		t.Errorf("switched to %s %s", cfg.env, cfg.url)
+	}
+
+	// A path edited into a full URL is taken back under the base.
+	cfg.path = cfg.url
+	cfg, err = switchEnv(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.env != "dev" || cfg.url != "http://localhost:8080/v1/users?x=1" || cfg.path != "/users?x=1" {
+		t.Errorf("switched to %s %s (path %s)", cfg.env, cfg.url, cfg.path)
+	}
+
+	// One outside the base can't follow the switch.
+	cfg.path = "https://elsewhere.test/users"
+	if _, err := switchEnv(cfg); err == nil || !strings.Contains(err.Error(), "not under the base URL") {
+		t.Errorf("switchEnv error = %v", err)
+	}
+	cfg.path = "http://localhost:8080/v10/users"
+	if _, err := switchEnv(cfg); err == nil {
+		t.Error("a URL sharing only a prefix of the base was taken as under it")
+	}
+
+	if _, err := switchEnv(config{envFile: "envs.json"}); err == nil {
+		t.Error("switching without environments succeeded")
+	}
+}
+
+func TestLoadEnvironments(t *testing.T) {
+	dir := t.TempDir()
+	envs, err := loadEnvironments(filepath.Join(dir, "missing.json"))
+	if err != nil || len(envs) != 0 {
+		t.Errorf("missing file = %v, %v, want no environments", envs, err)
+	}
+
+	name := filepath.Join(dir, "envs.json")
+	os.WriteFile(name, []byte(`{"b": {"base": "http://b/"}, "a": {"base": "http://a/"}}`), 0o644)
+	envs, err = loadEnvironments(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(envNames(envs), ","); got != "a,b" || envs["b"].Base != "http://b/" {
+		t.Errorf("environments = %v", envs)
+	}
+
+	os.WriteFile(name, []byte(`{`), 0o644)
+	if _, err := loadEnvironments(name); err == nil {
+		t.Error("broken JSON was accepted")
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The history and the samples of watch and load runs are numbers to chart
+// and sum up, which a spreadsheet or a notebook does better than we can.
+// history-export writes the history out, and x and X in watch and load
+// mode the run, as CSV or JSON, with the columns asked for in the order
+// asked for.
+
+// historyColumns are the columns history-export can write, in the order
+// historyRow gives their values.
+var historyColumns = []string{"time", "method", "url", "env", "request_id", "status", "ms", "error"}
+
+// historyRow is e as a row of every column of historyColumns, the values
+// as JSON has them; CSV writes them as text.
+func historyRow(e historyEntry) []any {
+	return []any{e.Time.Format(time.RFC3339Nano), e.Method, e.URL, e.Env, e.ID, e.Status, e.Millis, e.Error}
+}
+
+// sampleColumns are the columns of a watch or load run's export, in the
+// order sampleRow gives their values.
+var sampleColumns = []string{"time", "ms", "status", "error"}
+
+// sampleRow is s as a row of every column of sampleColumns.
+func sampleRow(s sample) []any {
+	problem := ""
+	if s.err != nil {
+		problem = s.err.Error()
+	}
+	return []any{s.at.UTC().Format(time.RFC3339Nano), float64(s.elapsed.Microseconds()) / 1000, s.status, problem}
+}
+
+// pickColumns returns where the columns named in list, comma-separated,
+// are among all, in the list's order; an empty list picks them all.
+func pickColumns(all []string, list string) ([]int, error) {
+	if strings.TrimSpace(list) == "" {
+		picked := make([]int, len(all))
+		for i := range all {
+			picked[i] = i
+		}
+		return picked, nil
+	}
+	var picked []int
+	for _, name := range strings.Split(list, ",") {
+		i := slices.Index(all, strings.TrimSpace(name))
+		if i < 0 {
+			return nil, fmt.Errorf("no column %q; there are %s", strings.TrimSpace(name), strings.Join(all, ", "))
+		}
+		picked = append(picked, i)
+	}
+	return picked, nil
+}
+
+// writeExport writes rows, each with a value for every one of columns, to
+// w as format, csv or json, in the picked columns only.
+func writeExport(w io.Writer, format string, columns []string, picked []int, rows [][]any) error {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		header := make([]string, len(picked))
+		for i, c := range picked {
+			header[i] = columns[c]
+		}
+		cw.Write(header)
+		for _, r := range rows {
+			record := make([]string, len(picked))
+			for i, c := range picked {
+				record[i] = csvValue(r[c])
+			}
+			cw.Write(record)
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		// Each row is an object with its keys in the columns' order,
+		// which encoding a map wouldn't keep.
+		var b bytes.Buffer
+		b.WriteString("[")
+		for i, r := range rows {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n  {")
+			for j, c := range picked {
+				if j > 0 {
+					b.WriteString(", ")
+				}
+				key, _ := json.Marshal(columns[c])
+				value, err := json.Marshal(r[c])
+				if err != nil {
+					return err
+				}
+				b.Write(key)
+				b.WriteString(": ")
+				b.Write(value)
+			}
+			b.WriteString("}")
+		}
+		if len(rows) > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("]\n")
+		_, err := w.Write(b.Bytes())
+		return err
+	}
+	return fmt.Errorf("unknown format %q; use csv or json", format)
+}
+
+// csvValue writes a column's value as CSV text. A status of 0, meaning no
+// answer, is left empty, as is done elsewhere.
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int:
+		if v == 0 {
+			return ""
+		}
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// runHistoryExport implements `history-export [-since DURATION] [-env NAME]
+// [-match TEXT] [-columns LIST] [-format csv|json] [-o FILE]`.
+func runHistoryExport(args []string) error {
+	fs := flag.NewFlagSet("history-export", flag.ExitOnError)
+	path := fs.String("history", defaultHistoryFile(), "the history `file` to export")
+	since := fs.Duration("since", 0, "export only the requests sent in this last `duration`, e.g. 24h")
+	env := fs.String("env", "", "export only the requests sent to this `environment`")
+	match := fs.String("match", "", "export only the requests whose URL contains this `text`")
+	columns := fs.String("columns", "", "write these `columns`, comma-separated, e.g. time,status,ms (default all of time, method, url, env, request_id, status, ms, error)")
+	format := fs.String("format", "csv", "write the history as `format` csv or json")
+	output := fs.String("o", "-", "write the export to this `file`, - for standard output")
+	fs.Parse(args)
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("unknown -format %q; use csv or json", *format)
+	}
+	picked, err := pickColumns(historyColumns, *columns)
+	if err != nil {
+		return err
+	}
+
+	entries, err := readHistory(*path)
+	if err != nil {
+		return err
+	}
+	if entries == nil {
+		return fmt.Errorf("there is no history at %s yet", *path)
+	}
+	var rows [][]any
+	for _, e := range entries {
+		if (*since == 0 || time.Since(e.Time) <= *since) && (*env == "" || e.Env == *env) && strings.Contains(e.URL, *match) {
+			rows = append(rows, historyRow(e))
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return writeExport(w, *format, historyColumns, picked, rows)
+}
+
+// exportRun writes the samples of run to a file in dir, "" for the working
+// directory, named for when it was written, as format, and returns its name.
+func exportRun(run *latencyRun, dir, format string, now time.Time) (string, error) {
+	if len(run.samples) == 0 {
+		return "", errors.New("there are no answers to export yet")
+	}
+	name := filepath.Join(dir, "latency-"+now.Format("20060102-150405")+"."+format)
+	rows := make([][]any, len(run.samples))
+	for i, s := range run.samples {
+		rows[i] = sampleRow(s)
+	}
+	picked, _ := pickColumns(sampleColumns, "")
+	var b bytes.Buffer
+	if err := writeExport(&b, format, sampleColumns, picked, rows); err != nil {
+		return "", err
+	}
+	return name, os.WriteFile(name, b.Bytes(), 0o644)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteExport(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	rows := [][]any{
+		historyRow(historyEntry{Time: at, Method: "GET", URL: "https://a.test/x", Status: 200, Millis: 12.5}),
+		historyRow(historyEntry{Time: at, Method: "POST", URL: "https://a.test/y", Millis: 3, Error: "refused, badly"}),
+	}
+	picked, err := pickColumns(historyColumns, "status, url,ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := writeExport(&b, "csv", historyColumns, picked, rows); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != "status,url,ms\n200,https://a.test/x,12.5\n,https://a.test/y,3\n" {
+		t.Errorf("CSV = %q", got)
+	}
+
+	b.Reset()
+	picked, _ = pickColumns(historyColumns, "method,status,error")
+	if err := writeExport(&b, "json", historyColumns, picked, rows); err != nil {
+		t.Fatal(err)
+	}
+	want := `[
+  {"method": "GET", "status": 200, "error": ""},
+  {"method": "POST", "status": 0, "error": "refused, badly"}
+]
+`
+	if got := b.String(); got != want {
+		t.Errorf("JSON =\n%s\nwant\n%s", got, want)
+	}
+
+	b.Reset()
+	if err := writeExport(&b, "json", historyColumns, picked, nil); err != nil || b.String() != "[]\n" {
+		t.Errorf("empty JSON = %q, %v", b.String(), err)
+	}
+	if _, err := pickColumns(historyColumns, "status,latency"); err == nil || !strings.Contains(err.Error(), `no column "latency"`) {
+		t.Errorf("unknown column: err = %v", err)
+	}
+	if err := writeExport(&b, "xml", historyColumns, picked, rows); err == nil {
+		t.Error("xml was accepted")
+	}
+}
+
+func TestExportRun(t *testing.T) {
+	dir := t.TempDir()
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	run := &latencyRun{samples: []sample{
+		{at: at, elapsed: 1500 * time.Microsecond, status: 200},
+		{at: at.Add(time.Second), elapsed: 2 * time.Second, err: errors.New("timeout")},
+	}}
+	name, err := exportRun(run, dir, "csv", at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != filepath.Join(dir, "latency-20240501-120000.csv") {
+		t.Errorf("name = %s", name)
+	}
+	out, _ := os.ReadFile(name)
+	if got := string(out); got != "time,ms,status,error\n2024-05-01T12:00:00Z,1.5,200,\n2024-05-01T12:00:01Z,2000,,timeout\n" {
+		t.Errorf("CSV = %q", got)
+	}
+	if _, err := exportRun(&latencyRun{}, dir, "csv", at); err == nil {
+		t.Error("an empty run was exported")
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"go.starlark.net/lib/json"
+	"go.starlark.net/lib/math"
+	sltime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+)
+
+// extensionSteps and extensionTimeout stop a runaway extension function.
+const (
+	extensionSteps   = 10_000_000
+	extensionTimeout = 5 * time.Second
+)
+
+// extensions holds what the user's Starlark file registered. Starlark has
+// no access to files, the network or the environment, so an extension can
+// only compute with what it is given.
+//
+// An extensions file calls these built-ins at the top level:
+//
+//	command(key, name, fn)         # fn(response) runs when key is pressed
+//	function(name, fn)             # {{name arg ...}} in a request calls fn(arg, ...)
+//	renderer(content_type, fn)     # fn(body) renders bodies of that type, e.g. "text/csv" or "text/*"
+//
+// A response is a dict with status, url, headers (one string per name) and
+// body. A command's fn returns None, a string to show as a report, or a
+// dict with any of method, url, headers and body to send as a new request.
+// A command can't take a key the program already uses. The json, math and
+// time modules are available.
+type extensions struct {
+	file      string
+	commands  []extCommand
+	functions map[string]starlark.Callable
+	renderers map[string]starlark.Callable // By media type, or "type/*".
+}
+
+// extCommand is a key bound by an extension.
+type extCommand struct {
+	key, name string
+	fn        starlark.Callable
+}
+
+// defaultExtensionsFile is where extensions live unless -extensions says
+// otherwise: next to the environments file.
+func defaultExtensionsFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "httpwizard", "extensions.star")
+}
+
+// loadExtensions runs the extensions file. Having no file is fine; it just
+// means there are no extensions.
+func loadExtensions(file string) (*extensions, error) {
+	ext := &extensions{file: file, functions: map[string]starlark.Callable{}, renderers: map[string]starlark.Callable{}}
+	if file == "" {
+		return ext, nil
+	}
+	src, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return ext, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	predeclared := starlark.StringDict{
+		"json": json.Module,
+		"math": math.Module,
+		"time": sltime.Module,
+		"command": starlark.NewBuiltin("command", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var c extCommand
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "key", &c.key, "name", &c.name, "fn", &c.fn); err != nil {
+				return nil, err
+			}
+			if reservedKey(c.key) {
+				return nil, fmt.Errorf("%s: key %q is already taken by the program", b.Name(), c.key)
+			}
+			ext.commands = append(ext.commands, c)
+			return starlark.None, nil
+		}),
+		"function": starlark.NewBuiltin("function", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var name string
+			var fn starlark.Callable
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "name", &name, "fn", &fn); err != nil {
+				return nil, err
+			}
+			ext.functions[name] = fn
+			return starlark.None, nil
+		}),
+		"renderer": starlark.NewBuiltin("renderer", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var mediaType string
+			var fn starlark.Callable
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "content_type", &mediaType, "fn", &fn); err != nil {
+				return nil, err
+			}
+			ext.renderers[strings.ToLower(mediaType)] = fn
+			return starlark.None, nil
+		}),
+	}
+	thread, stop := newThread()
+	defer stop()
+	if _, err := starlark.ExecFile(thread, file, src, predeclared); err != nil {
+		return nil, fmt.Errorf("loading extensions: %w", err)
+	}
+	return ext, nil
+}
+
+// builtinKeys are the keys the response screen and the tab bar already
+// handle, which would shadow an extension command bound to them.
+var builtinKeys = []string{
+	"q", "ctrl+c", "p", "l", "c", "b", "pgdown", "ctrl+d", "pgup", "ctrl+u",
+	"t", "T", "u", "e", "h", "Q", "R", "]", "[", "v", "i", "r", "x",
+	"up", "k", "down", "j", "enter", "N", ":", "d", "a", "W", "E", "L",
+	"D", "w", "tab", "shift+tab",
+}
+
+// reservedKey reports whether key is a built-in key or resends a
+// conditional request.
+func reservedKey(key string) bool {
+	if slices.Contains(builtinKeys, key) {
+		return true
+	}
+	for _, c := range conditionals {
+		if c.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// newThread returns a Starlark thread that may run for a bounded time
+// and can't load other files. Call stop once it is done.
+func newThread() (thread *starlark.Thread, stop func()) {
+	thread = &starlark.Thread{Name: "extension"}
+	thread.SetMaxExecutionSteps(extensionSteps)
+	timer := time.AfterFunc(extensionTimeout, func() { thread.Cancel("took too long") })
+	return thread, func() { timer.Stop() }
+}
+
+// call runs an extension function on a thread of its own.
+func call(fn starlark.Callable, args ...starlark.Value) (starlark.Value, error) {
+	thread, stop := newThread()
+	defer stop()
+	return starlark.Call(thread, fn, starlark.Tuple(args), nil)
+}
+
+// command returns the extension command bound to key, if any. A nil
+// extensions has none.
+func (ext *extensions) command(key string) (extCommand, bool) {
+	if ext == nil {
+		return extCommand{}, false
+	}
+	for _, c := range ext.commands {
+		if c.key == key {
+			return c, true
+		}
+	}
+	return extCommand{}, false
+}
+
+// help lists the extension commands, for the help line.
+func (ext *extensions) help() string {
+	if ext == nil {
+		return ""
+	}
+	var s string
+	for _, c := range ext.commands {
+		s += c.key + " " + c.name + " • "
+	}
+	return s
+}
+
+// renderer returns the extension renderer for bodies served as contentType.
+func (ext *extensions) renderer(contentType string) (starlark.Callable, bool) {
+	if ext == nil {
+		return nil, false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	if fn, ok := ext.renderers[mediaType]; ok {
+		return fn, true
+	}
+	main, _, _ := strings.Cut(mediaType, "/")
+	fn, ok := ext.renderers[main+"/*"]
+	return fn, ok
+}
+
+// render runs the extension renderer for contentType on body, if there is
+// one. A failing renderer says why in place of the body.
+func (ext *extensions) render(contentType string, body []byte) (string, bool) {
+	fn, ok := ext.renderer(contentType)
+	if !ok {
+		return "", false
+	}
+	v, err := call(fn, starlark.String(body))
+	if err != nil {
+		return fmt.Sprintf("(extension renderer failed: %v)", err), true
+	}
+	if s, ok := starlark.AsString(v); ok {
+		return s, true
+	}
+	return v.String(), true
+}
+
+// templateCall matches {{name}} or {{name arg ...}} in a request.
+var templateCall = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)((?:\s+[^\s}]+)*)\s*\}\}`)
+
+// expand replaces every {{name arg ...}} in s with what the extension
+// function name returns for those arguments.
+func (ext *extensions) expand(s string) (string, error) {
+	if ext == nil || len(ext.functions) == 0 || !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	var failed error
+	out := templateCall.ReplaceAllStringFunc(s, func(match string) string {
+		parts := templateCall.FindStringSubmatch(match)
+		fn, ok := ext.functions[parts[1]]
+		if !ok || failed != nil {
+			return match
+		}
+		var args []starlark.Value
+		for _, a := range strings.Fields(parts[2]) {
+			args = append(args, starlark.String(a))
+		}
+		v, err := call(fn, args...)
+		if err != nil {
+			failed = fmt.Errorf("{{%s}}: %w", parts[1], err)
+			return match
+		}
+		if s, ok := starlark.AsString(v); ok {
+			return s
+		}
+		return v.String()
+	})
+	return out, failed
+}
+
+// escapedCall matches a {{...}} call in a URL after normalizeURL has
+// percent-encoded its spaces, and in the path its braces too.
+var escapedCall = regexp.MustCompile(`(?i)(?:%7B%7B|\{\{)(.*?)(?:%7D%7D|\}\})`)
+
+// expandRequest returns cfg with the variables, secrets among them, and
+// extension functions in its URL, header values and body filled in.
+func expandRequest(cfg config) (config, error) {
+	cfg, err := resolveSecrets(cfg)
+	if err != nil {
+		return cfg, err
+	}
+	ext := cfg.ext
+	if (ext == nil || len(ext.functions) == 0) && !hasVariables(cfg) {
+		return cfg, nil
+	}
+	expand := func(s string) (string, error) { return ext.expand(expandVariables(cfg, s)) }
+
+	// Undo the escaping of calls in the URL, fill them in, and escape
+	// whatever they returned.
+	target := escapedCall.ReplaceAllStringFunc(cfg.url, func(match string) string {
+		inner, err := url.PathUnescape(escapedCall.FindStringSubmatch(match)[1])
+		if err != nil {
+			return match
+		}
+		return "{{" + inner + "}}"
+	})
+	target, err = expand(target)
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.url, err = normalizeURL(target); err != nil {
+		return cfg, err
+	}
+	header := http.Header{}
+	for key, values := range cfg.header {
+		for _, v := range values {
+			if v, err = expand(v); err != nil {
+				return cfg, err
+			}
+			header.Add(key, v)
+		}
+	}
+	cfg.header = header
+	if cfg.body != nil {
+		body, err := expand(string(cfg.body))
+		if err != nil {
+			return cfg, err
+		}
+		cfg.body = []byte(body)
+	}
+	return cfg, nil
+}
+
+// extRequestMsg asks the tab to send the request an extension command built.
+type extRequestMsg struct{ cfg config }
+
+// runCommand returns a command that runs extension command c on res and
+// turns what it returns into a report or a new request.
+func runCommand(c extCommand, cfg config, res response) tea.Cmd {
+	return func() tea.Msg {
+		headers := starlark.NewDict(len(res.header))
+		keys := make([]string, 0, len(res.header))
+		for key := range res.header {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			headers.SetKey(starlark.String(key), starlark.String(strings.Join(res.header[key], ", ")))
+		}
+		arg := starlark.NewDict(4)
+		arg.SetKey(starlark.String("status"), starlark.MakeInt(res.status))
+		arg.SetKey(starlark.String("url"), starlark.String(cfg.url))
+		arg.SetKey(starlark.String("headers"), headers)
+		arg.SetKey(starlark.String("body"), starlark.String(res.body))
+
+		v, err := call(c.fn, arg)
+		if err != nil {
+			return errMsg{fmt.Errorf("%s: %w", c.name, err)}
+		}
+		switch v := v.(type) {
+		case starlark.NoneType:
+			return reportMsg{title: c.name, body: "Done."}
+		case starlark.String:
+			return reportMsg{title: c.name, body: string(v)}
+		case *starlark.Dict:
+			next, err := requestFromDict(cfg, v)
+			if err != nil {
+				return errMsg{fmt.Errorf("%s: %w", c.name, err)}
+			}
+			return extRequestMsg{next}
+		default:
+			return reportMsg{title: c.name, body: v.String()}
+		}
+	}
+}
+
+// requestFromDict returns cfg changed by the method, url, headers and body
+// in d, as returned by an extension command.
+func requestFromDict(cfg config, d *starlark.Dict) (config, error) {
+	str := func(key string) (string, bool, error) {
+		v, found, err := d.Get(starlark.String(key))
+		if err != nil || !found {
+			return "", false, err
+		}
+		s, ok := starlark.AsString(v)
+		if !ok {
+			return "", false, fmt.Errorf("%s must be a string, not %s", key, v.Type())
+		}
+		return s, true, nil
+	}
+
+	cfg = withoutConditions(cfg)
+	if method, ok, err := str("method"); err != nil {
+		return cfg, err
+	} else if ok {
+		cfg.method = strings.ToUpper(method)
+	}
+	if target, ok, err := str("url"); err != nil {
+		return cfg, err
+	} else if ok {
+		if target, err = normalizeURL(target); err != nil {
+			return cfg, err
+		}
+		cfg.url, cfg.path = target, target
+	}
+	if body, ok, err := str("body"); err != nil {
+		return cfg, err
+	} else if ok {
+		cfg.body = []byte(body)
+	}
+
+	if v, found, _ := d.Get(starlark.String("headers")); found {
+		h, ok := v.(*starlark.Dict)
+		if !ok {
+			return cfg, fmt.Errorf("headers must be a dict, not %s", v.Type())
+		}
+		for _, item := range h.Items() {
+			key, ok1 := starlark.AsString(item[0])
+			value, ok2 := starlark.AsString(item[1])
+			if !ok1 || !ok2 {
+				return cfg, errors.New("headers must map strings to strings")
+			}
+			cfg.header.Set(key, value)
+		}
+	}
+	return cfg, nil
+}
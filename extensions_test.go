@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeExtensions writes src to an extensions file and loads it.
+func writeExtensions(t *testing.T, src string) (*extensions, error) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "extensions.star")
+	if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return loadExtensions(file)
+}
+
+func TestCommandKeys(t *testing.T) {
+	ext, err := writeExtensions(t, `command("z", "Zap", lambda res: "zapped")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := ext.command("z"); !ok || c.name != "Zap" {
+		t.Errorf("command(z) = %+v, %v", c, ok)
+	}
+
+	for _, key := range []string{"q", "t", "n", "s", "D", "w", "tab"} {
+		_, err := writeExtensions(t, `command("`+key+`", "Mine", lambda res: None)`)
+		if err == nil || !strings.Contains(err.Error(), "already taken") {
+			t.Errorf("binding %q: err = %v, want it refused", key, err)
+		}
+	}
+}
+
+func TestNoExtensions(t *testing.T) {
+	ext, err := loadExtensions(filepath.Join(t.TempDir(), "missing.star"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ext.command("z"); ok {
+		t.Error("a missing file bound a command")
+	}
+	var none *extensions
+	if _, ok := none.command("z"); ok {
+		t.Error("nil extensions bound a command")
+	}
+}
+
+const sampleExtensions = `
+def shout(res):
+    return res["body"].upper() + " " + str(res["status"])
+
+def retry(res):
+    return {"method": "post", "url": "https://a.test/again", "headers": {"X-Try": "2"}, "body": "b"}
+
+command("z", "Shout", shout)
+command("y", "Retry", retry)
+function("double", lambda s: s + s)
+renderer("text/*", lambda body: "[" + body + "]")
+renderer("text/csv", lambda body: "csv!")
+`
+
+func TestRunCommand(t *testing.T) {
+	ext, err := writeExtensions(t, sampleExtensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config{method: http.MethodGet, url: "https://a.test/", header: http.Header{}, ext: ext}
+	res := response{status: 418, body: []byte("tea")}
+
+	c, _ := ext.command("z")
+	if msg, ok := runCommand(c, cfg, res)().(reportMsg); !ok || msg.body != "TEA 418" {
+		t.Errorf("Shout = %#v", msg)
+	}
+	c, _ = ext.command("y")
+	msg, ok := runCommand(c, cfg, res)().(extRequestMsg)
+	if !ok {
+		t.Fatalf("Retry = %#v, want a request", msg)
+	}
+	if got := msg.cfg; got.method != http.MethodPost || got.url != "https://a.test/again" ||
+		got.header.Get("X-Try") != "2" || string(got.body) != "b" {
+		t.Errorf("Retry request = %s %s %v %q", got.method, got.url, got.header, got.body)
+	}
+	if got := ext.help(); got != "z Shout • y Retry • " {
+		t.Errorf("help = %q", got)
+	}
+}
+
+func TestExpandRequest(t *testing.T) {
+	ext, err := writeExtensions(t, sampleExtensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, _ := normalizeURL("https://a.test/{{double ab}}?q={{ double x }}")
+	cfg := config{url: target, header: http.Header{"X-A": {"{{double 1}}"}}, body: []byte("{{double z}} {{nope}}"), ext: ext}
+	cfg, err = expandRequest(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.url != "https://a.test/abab?q=xx" || cfg.header.Get("X-A") != "11" || string(cfg.body) != "zz {{nope}}" {
+		t.Errorf("expanded = %s %v %q", cfg.url, cfg.header, cfg.body)
+	}
+}
+
+func TestRender(t *testing.T) {
+	ext, err := writeExtensions(t, sampleExtensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ct, want := range map[string]string{"text/plain; charset=utf-8": "[hi]", "text/csv": "csv!"} {
+		if got, ok := ext.render(ct, []byte("hi")); !ok || got != want {
+			t.Errorf("render(%q) = %q, %v, want %q", ct, got, ok, want)
+		}
+	}
+	if _, ok := ext.render("application/json", []byte("{}")); ok {
+		t.Error("a JSON body had a renderer")
+	}
+}
+
+func TestExtensionLimits(t *testing.T) {
+	ext, err := writeExtensions(t, "def spin(res):\n    for i in range(1000000000):\n        pass\n\ncommand(\"z\", \"Spin\", spin)\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := ext.command("z")
+	if msg, ok := runCommand(c, config{}, response{})().(errMsg); !ok || !strings.Contains(msg.err.Error(), "Spin") {
+		t.Errorf("Spin = %#v, want it stopped", msg)
+	}
+	if _, err := writeExtensions(t, `load("other.star", "x")`); err == nil {
+		t.Error("an extension loaded another file")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// familyDialer returns a DialContext that only connects over IPv4 or IPv6,
+// for family "4" or "6", with the timeouts of net/http's default transport.
+func familyDialer(family string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, network+family, addr)
+	}
+}
+
+// ipFamily names the address family of ip.
+func ipFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return "IPv4"
+	}
+	return "IPv6"
+}
+
+// connInfo says which address a request's connection went to, and what
+// else was on offer: the addresses the name resolved to, and the ones
+// Happy Eyeballs tried first without success.
+type connInfo struct {
+	mu       sync.Mutex
+	remote   string   // Address connected to, e.g. "[2001:db8::1]:443".
+	reused   bool     // The connection was already open.
+	resolved []net.IP // What DNS returned for the host.
+	failed   []string // "address: error" for each connection attempt that failed.
+}
+
+// trace returns the hooks that fill in c. Only the last connection counts,
+// which after redirects is the one the final response came over.
+func (c *connInfo) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.remote, c.reused, c.resolved, c.failed = "", false, nil, nil
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			for _, a := range info.Addrs {
+				c.resolved = append(c.resolved, a.IP)
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				return
+			}
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.failed = append(c.failed, fmt.Sprintf("%s: %v", addr, err))
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.remote, c.reused = remoteAddr(info.Conn), info.Reused
+		},
+	}
+}
+
+// remoteAddr is the address at the other end of conn, under TLS if need be.
+func remoteAddr(conn net.Conn) string {
+	if tc, ok := conn.(*tls.Conn); ok {
+		conn = tc.NetConn()
+	}
+	if conn == nil || conn.RemoteAddr() == nil {
+		return ""
+	}
+	return conn.RemoteAddr().String()
+}
+
+// summary describes the connection in a line, e.g. "93.184.216.34:443 over
+// IPv4; the host has IPv6 addresses too". family is the address family the
+// request was limited to, "4" or "6", or "" for either.
+func (c *connInfo) summary(family string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	host, _, err := net.SplitHostPort(c.remote)
+	ip := net.ParseIP(host)
+	if err != nil || ip == nil {
+		return ""
+	}
+	fam := ipFamily(ip)
+	s := c.remote + " over " + fam
+	if family != "" {
+		s += ", as -" + family + " asked"
+	}
+	if c.reused {
+		s += " (reused connection)"
+	}
+
+	for _, r := range c.resolved {
+		if other := ipFamily(r); other != fam {
+			s += "; the host has " + other + " addresses too"
+			break
+		}
+	}
+	if len(c.failed) > 0 {
+		s += "; tried first: " + strings.Join(c.failed, "; ")
+	}
+	return s
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConnSummary(t *testing.T) {
+	c := &connInfo{
+		remote:   "[2001:db8::1]:443",
+		resolved: []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("192.0.2.1")},
+		failed:   []string{"192.0.2.1:443: connect: connection refused"},
+	}
+	want := "[2001:db8::1]:443 over IPv6; the host has IPv4 addresses too; tried first: 192.0.2.1:443: connect: connection refused"
+	if got := c.summary(""); got != want {
+		t.Errorf("summary = %q, want %q", got, want)
+	}
+
+	c = &connInfo{remote: "192.0.2.1:80", reused: true}
+	if got := c.summary("4"); got != "192.0.2.1:80 over IPv4, as -4 asked (reused connection)" {
+		t.Errorf("summary = %q", got)
+	}
+	if got := (&connInfo{}).summary(""); got != "" {
+		t.Errorf("summary without a connection = %q", got)
+	}
+}
+
+func TestRequestFamily(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	cfg := config{url: srv.URL, method: http.MethodGet, header: http.Header{}, transport: transportOptions{family: "4"}}
+	res, ok := checkServer(cfg)().(responseMsg)
+	if !ok {
+		t.Fatal("IPv4 request failed")
+	}
+	if got := res.conn.summary("4"); !strings.HasPrefix(got, srv.Listener.Addr().String()+" over IPv4, as -4 asked") {
+		t.Errorf("connection = %q", got)
+	}
+
+	// An IPv4 server can't be reached over IPv6 alone.
+	cfg.transport.family = "6"
+	if msg, ok := checkServer(cfg)().(errMsg); !ok || !strings.Contains(msg.err.Error(), "127.0.0.1") {
+		t.Errorf("IPv6-only request to an IPv4 address = %#v", msg)
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+)
+
+// Some requests get sent over and over, and finding them again shouldn't
+// mean retyping them. * stars the request on screen, keeping it, headers
+// and body with it, in the favorites file; ctrl+r opens a quick switcher of
+// the favorites and the requests sent most recently, narrowed down by
+// fuzzy search as you type.
+
+// maxRecentRequests is how many of the latest different requests the
+// quick switcher offers, besides the favorites.
+const maxRecentRequests = 50
+
+// defaultFavoritesFile is where the favorites are kept unless
+// -favorites-file says otherwise, or "" for none without a config directory.
+func defaultFavoritesFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "httpwizard", "favorites.json")
+}
+
+// loadFavorites reads the favorites file, a JSON list of requests as the
+// session file keeps tabs. A missing file means nothing is starred yet.
+func loadFavorites(path string) ([]savedTab, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var favs []savedTab
+	if err := json.Unmarshal(b, &favs); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return favs, nil
+}
+
+// saveFavorites writes favs to the favorites file. Their headers may carry
+// credentials, so only the user can read it.
+func saveFavorites(path string, favs []savedTab) error {
+	b, err := json.MarshalIndent(favs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(b, '\n'))
+}
+
+// favoriteRequest is the request cfg describes, as a favorite keeps it.
+func favoriteRequest(cfg config) savedTab {
+	return savedTab{Method: cfg.method, URL: cfg.url, Header: cfg.header, Body: cfg.body, Env: cfg.env}
+}
+
+// toggleStar stars the request on screen, or unstars it if it is starred
+// already, and says which it did.
+func (m model) toggleStar() (tea.Model, tea.Cmd) {
+	if m.cfg.favoritesFile == "" {
+		return m.paletteError(errors.New("there is no favorites file to keep stars in"))
+	}
+	favs, err := loadFavorites(m.cfg.favoritesFile)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	key := historyKey(m.cfg.method, m.cfg.url)
+	i := slices.IndexFunc(favs, func(t savedTab) bool { return historyKey(t.Method, t.URL) == key })
+	if i < 0 {
+		favs = append(favs, favoriteRequest(withoutConditions(m.cfg)))
+		if err := saveFavorites(m.cfg.favoritesFile, favs); err != nil {
+			return m.paletteError(err)
+		}
+		m.report, m.cursor = &reportMsg{title: "Starred " + key, body: fmt.Sprintf("%d starred. ctrl+r switches between them and the latest requests.", len(favs))}, 0
+		return m, nil
+	}
+
+	// Unstarring keeps the request, headers and body, in the trash.
+	if err := moveToTrash(m.cfg.trashFile, trashItem{Kind: trashFavorite, Name: key, Deleted: time.Now().UTC(), Favorite: &favs[i]}); err != nil {
+		return m.paletteError(err)
+	}
+	favs = slices.Delete(favs, i, i+1)
+	if err := saveFavorites(m.cfg.favoritesFile, favs); err != nil {
+		return m.paletteError(err)
+	}
+	body := fmt.Sprintf("%d starred.", len(favs))
+	if m.cfg.trashFile != "" {
+		body += " It is in the trash; trash restore puts it back."
+	}
+	m.report, m.cursor = &reportMsg{title: "Unstarred " + key, body: body}, 0
+	return m, nil
+}
+
+// switchItem is a request the quick switcher offers.
+type switchItem struct {
+	request savedTab
+	starred bool
+	sent    time.Time // When it was last sent, if the history says.
+}
+
+// label is how the switcher lists the item, and what fuzzy search matches.
+func (it switchItem) label() string {
+	s := historyKey(it.request.Method, it.request.URL)
+	if it.request.Env != "" {
+		s += "  [" + it.request.Env + "]"
+	}
+	return s
+}
+
+// quickSwitcher is the ctrl+r switcher's state.
+type quickSwitcher struct {
+	input   textinput.Model
+	items   []switchItem // Favorites, then the latest requests; nil until loaded.
+	shown   []int        // The items matching the input, best first.
+	cursor  int          // Index into shown.
+	offset  int          // First visible row.
+	loading bool
+	err     error
+}
+
+// switchItemsMsg carries the switcher's items once they are read.
+type switchItemsMsg struct {
+	items []switchItem
+	err   error
+}
+
+// openSwitcher opens the quick switcher and starts reading its items.
+func (m model) openSwitcher() (tea.Model, tea.Cmd) {
+	in := textinput.New()
+	in.Prompt = "› "
+	in.Placeholder = "type to search the favorites and the latest requests"
+	in.Cursor.SetMode(cursor.CursorStatic)
+	in.Focus()
+	m.switcher = &quickSwitcher{input: in, loading: true}
+	return m, findSwitchItems(m.cfg.favoritesFile, m.cfg.historyFile)
+}
+
+// findSwitchItems returns a command that reads the favorites, and the
+// latest different requests from the history, that aren't among them.
+func findSwitchItems(favoritesFile, historyFile string) tea.Cmd {
+	return func() tea.Msg {
+		var items []switchItem
+		seen := map[string]bool{}
+		if favoritesFile != "" {
+			favs, err := loadFavorites(favoritesFile)
+			if err != nil {
+				return switchItemsMsg{err: err}
+			}
+			for _, t := range favs {
+				seen[historyKey(t.Method, t.URL)] = true
+				items = append(items, switchItem{request: t, starred: true})
+			}
+		}
+		if historyFile == "" {
+			return switchItemsMsg{items: items}
+		}
+		latest := func(e historyEntry) bool {
+			key := historyKey(e.Method, e.URL)
+			if seen[key] {
+				return false
+			}
+			seen[key] = true
+			return true
+		}
+		recent, err := searchHistory(historyFile, "", latest, maxRecentRequests)
+		for _, e := range recent {
+			items = append(items, switchItem{request: savedTab{Method: e.Method, URL: e.URL, Env: e.Env}, sent: e.Time})
+		}
+		return switchItemsMsg{items: items, err: err}
+	}
+}
+
+// fuzzyScore reports whether the letters of pattern appear in s in order,
+// ignoring case, and how well: runs of them, and those that start a word,
+// score higher.
+func fuzzyScore(pattern, s string) (int, bool) {
+	p := []rune(strings.ToLower(strings.ReplaceAll(pattern, " ", "")))
+	if len(p) == 0 {
+		return 0, true
+	}
+	score, j, run := 0, 0, 0
+	prev := ' '
+	for _, r := range strings.ToLower(s) {
+		if j < len(p) && r == p[j] {
+			j++
+			run++
+			score += run
+			if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+				score += 3
+			}
+		} else {
+			run = 0
+		}
+		prev = r
+	}
+	return score, j == len(p)
+}
+
+// filter lists the items matching the input, best first; ties keep the
+// favorites first and the rest the latest first.
+func (q *quickSwitcher) filter() {
+	q.shown = q.shown[:0]
+	scores := map[int]int{}
+	for i, it := range q.items {
+		if score, ok := fuzzyScore(q.input.Value(), it.label()); ok {
+			q.shown = append(q.shown, i)
+			scores[i] = score
+		}
+	}
+	slices.SortStableFunc(q.shown, func(a, b int) int { return scores[b] - scores[a] })
+	q.cursor, q.offset = 0, 0
+}
+
+// showSwitchItems takes in the switcher's items.
+func (m model) showSwitchItems(msg switchItemsMsg) (tea.Model, tea.Cmd) {
+	if m.switcher == nil {
+		return m, nil
+	}
+	q := *m.switcher
+	q.items, q.err, q.loading = msg.items, msg.err, false
+	q.shown = nil
+	q.filter()
+	m.switcher = &q
+	return m, nil
+}
+
+// updateSwitcher handles keys while the quick switcher is open.
+func (m model) updateSwitcher(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	copied := *m.switcher
+	q := &copied
+	q.shown = slices.Clone(q.shown)
+	m.switcher = q
+	last := max(len(q.shown)-1, 0)
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "ctrl+r":
+		m.switcher = nil
+		return m, nil
+	case "up", "ctrl+p":
+		q.cursor = max(q.cursor-1, 0)
+	case "down", "ctrl+n":
+		q.cursor = min(q.cursor+1, last)
+	case "enter":
+		if len(q.shown) == 0 {
+			return m, nil
+		}
+		m.switcher = nil
+		return m.switchTo(q.items[q.shown[q.cursor]].request)
+	default:
+		var cmd tea.Cmd
+		before := q.input.Value()
+		q.input, cmd = q.input.Update(msg)
+		if q.input.Value() != before {
+			q.filter()
+		}
+		return m, cmd
+	}
+	if q.cursor < q.offset {
+		q.offset = q.cursor
+	}
+	if q.cursor >= q.offset+tableHeight {
+		q.offset = q.cursor - tableHeight + 1
+	}
+	return m, nil
+}
+
+// switchTo sends the request t describes in place of the one on screen.
+// Requests from the history come without headers or a body: they keep
+// the current headers, and drop its body unless it is the same request.
+func (m model) switchTo(t savedTab) (tea.Model, tea.Cmd) {
+	cfg := withoutConditions(m.cfg)
+	if t.Header != nil {
+		cfg.header = t.Header.Clone()
+	}
+	if t.Body != nil || historyKey(t.Method, t.URL) != historyKey(cfg.method, cfg.url) {
+		cfg.body = t.Body
+	}
+	if t.Env != "" {
+		cfg.env = t.Env
+	}
+	if cfg.header == nil {
+		cfg.header = http.Header{}
+	}
+	cfg.method, cfg.url, cfg.path = t.Method, t.URL, t.URL
+	m.cond = nil
+	return m.resend(cfg)
+}
+
+// viewSwitcher renders the search line and the matching requests.
+func (m model) viewSwitcher() string {
+	q := m.switcher
+	var b strings.Builder
+	b.WriteString("\nSwitch to a request\n\n" + q.input.View() + "\n\n")
+	switch {
+	case q.loading:
+		b.WriteString("Reading the favorites and the history…\n")
+	case q.err != nil:
+		b.WriteString(q.err.Error() + "\n")
+	case len(q.items) == 0:
+		b.WriteString("Nothing starred or sent yet; * stars the request on screen.\n")
+	case len(q.shown) == 0:
+		b.WriteString("No request matches.\n")
+	}
+	for i := q.offset; i < min(q.offset+tableHeight, len(q.shown)); i++ {
+		it := q.items[q.shown[i]]
+		mark := "  "
+		if i == q.cursor {
+			mark = "> "
+		}
+		star := "  "
+		if it.starred {
+			star = "★ "
+		}
+		line := star + it.label()
+		if !it.sent.IsZero() {
+			line += "  · " + it.sent.Local().Format("Mon 02 Jan 15:04")
+		}
+		if m.width > 0 {
+			line = runewidth.Truncate(line, m.width-2, "…")
+		}
+		b.WriteString(mark + line + "\n")
+	}
+	b.WriteString("\n↑/↓ select • enter switch • esc close\n")
+	return b.String()
+}
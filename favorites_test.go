@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestToggleStar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", "favorites.json")
+	m := model{cfg: config{method: "POST", url: "https://api.example.com/users", header: http.Header{"If-Match": {`"v1"`}, "Authorization": {"Bearer x"}}, body: []byte(`{"name":"Ada"}`), favoritesFile: path}}
+
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("*")})
+	if r := next.(model).report; r == nil || r.title != "Starred POST https://api.example.com/users" {
+		t.Fatalf("report = %+v", r)
+	}
+	favs, err := loadFavorites(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(favs) != 1 || string(favs[0].Body) != `{"name":"Ada"}` || favs[0].Header.Get("Authorization") != "Bearer x" || favs[0].Header.Get("If-Match") != "" {
+		t.Fatalf("favorites = %+v", favs)
+	}
+
+	next, _ = next.(model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("*")})
+	if r := next.(model).report; r.title != "Unstarred POST https://api.example.com/users" {
+		t.Errorf("report = %+v", r)
+	}
+	if favs, _ := loadFavorites(path); len(favs) != 0 {
+		t.Errorf("favorites = %+v", favs)
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	if _, ok := fuzzyScore("gusr", "GET https://api.example.com/users"); !ok {
+		t.Error("gusr doesn't match")
+	}
+	if _, ok := fuzzyScore("users get", "GET https://api.example.com/users"); ok {
+		t.Error("out of order matched")
+	}
+	run, _ := fuzzyScore("users", "GET https://api.example.com/users")
+	spread, _ := fuzzyScore("users", "GET https://xuxsxexrxs.example.com/")
+	if run <= spread {
+		t.Errorf("a run scored %d, letters spread out %d", run, spread)
+	}
+}
+
+func TestQuickSwitcher(t *testing.T) {
+	dir := t.TempDir()
+	favs := filepath.Join(dir, "favorites.json")
+	history := filepath.Join(dir, "history.db")
+	if err := saveFavorites(favs, []savedTab{{Method: "POST", URL: "https://api.example.com/login", Header: http.Header{"Content-Type": {"application/json"}}, Body: []byte(`{"user":"ada"}`)}}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Hour)
+	for i, u := range []string{"https://api.example.com/orders", "https://api.example.com/login", "https://api.example.com/users", "https://api.example.com/orders"} {
+		if err := appendHistoryDB(history, historyEntry{Time: start.Add(time.Duration(i) * time.Minute), Method: "GET", URL: u, Status: 200}, historyRetention{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := model{cfg: config{method: "GET", url: "https://api.example.com/", header: http.Header{}, favoritesFile: favs, historyFile: history}}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	next, _ = next.(model).Update(cmd())
+	q := next.(model).switcher
+	var labels []string
+	for _, i := range q.shown {
+		labels = append(labels, q.items[i].label())
+	}
+	want := "POST https://api.example.com/login,GET https://api.example.com/orders,GET https://api.example.com/users,GET https://api.example.com/login"
+	if strings.Join(labels, ",") != want {
+		t.Fatalf("items = %v", labels)
+	}
+	if view := next.(model).View(); !strings.Contains(view, "★ POST https://api.example.com/login") {
+		t.Errorf("view:\n%s", view)
+	}
+
+	next, _ = next.(model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("plogin")})
+	if q := next.(model).switcher; len(q.shown) != 2 || !q.items[q.shown[0]].starred {
+		t.Fatalf("plogin shows %v, not the favorite first", q.shown)
+	}
+	next, cmd = next.(model).Update(tea.KeyMsg{Type: tea.KeyEnter})
+	got := next.(model)
+	if got.switcher != nil || cmd == nil || got.cfg.method != "POST" || got.cfg.url != "https://api.example.com/login" || string(got.cfg.body) != `{"user":"ada"}` || got.cfg.header.Get("Content-Type") != "application/json" {
+		t.Errorf("switched to %s %s %q %v", got.cfg.method, got.cfg.url, got.cfg.body, got.cfg.header)
+	}
+}
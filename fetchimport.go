@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// fetchCall matches the start of a DevTools "Copy as fetch" or "Copy as
+// Node.js fetch" snippet, which Firefox prefixes with await.
+var fetchCall = regexp.MustCompile(`^(await\s+)?fetch\s*\(`)
+
+// looksLikeFetch reports whether s is a pasted fetch() call.
+func looksLikeFetch(s string) bool {
+	return fetchCall.MatchString(strings.TrimSpace(s))
+}
+
+// fetchOptions is the part of fetch's second argument that describes the
+// request. Browser-only settings like mode and credentials don't apply here.
+type fetchOptions struct {
+	Method   string            `json:"method"`
+	Headers  map[string]string `json:"headers"`
+	Body     *string           `json:"body"`
+	Referrer string            `json:"referrer"`
+}
+
+// fromFetch returns a copy of cfg with the method, URL, headers and body of
+// the fetch() call in snippet. Browsers write both arguments as JSON, so
+// they are read with a JSON decoder rather than a JavaScript parser.
+func fromFetch(cfg config, snippet string) (config, error) {
+	snippet = strings.TrimSpace(snippet)
+	loc := fetchCall.FindStringIndex(snippet)
+	if loc == nil {
+		return cfg, errors.New("not a fetch() call")
+	}
+	dec := json.NewDecoder(strings.NewReader(snippet[loc[1]:]))
+
+	var target string
+	if err := dec.Decode(&target); err != nil {
+		return cfg, fmt.Errorf("reading the fetch() URL: %w", err)
+	}
+
+	// The options are optional; after the URL comes either "," or ")".
+	var opts fetchOptions
+	rest := strings.TrimSpace(snippet[loc[1]+int(dec.InputOffset()):])
+	if strings.HasPrefix(rest, ",") {
+		if err := json.NewDecoder(strings.NewReader(rest[1:])).Decode(&opts); err != nil {
+			return cfg, fmt.Errorf("reading the fetch() options: %w", err)
+		}
+	}
+
+	cfg.header = http.Header{}
+	for key, value := range opts.Headers {
+		cfg.header.Set(key, value)
+	}
+	// The browser sends the referrer as a header of its own accord; keep
+	// it, since some servers check it. "about:client" means the default.
+	if opts.Referrer != "" && opts.Referrer != "about:client" && cfg.header.Get("Referer") == "" {
+		cfg.header.Set("Referer", opts.Referrer)
+	}
+
+	cfg.method = strings.ToUpper(opts.Method)
+	if cfg.method == "" {
+		cfg.method = http.MethodGet
+	}
+	cfg.body = nil
+	if opts.Body != nil {
+		cfg.body = []byte(*opts.Body)
+	}
+
+	target, err := normalizeURL(target)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.url, cfg.path = target, target
+	return cfg, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFromFetch(t *testing.T) {
+	snippet := `await fetch("https://a.test/api?q=1", {
+  "credentials": "include",
+  "headers": {
+    "accept": "application/json",
+    "content-type": "application/json"
+  },
+  "referrer": "https://a.test/page",
+  "body": "{\"name\":\"x\"}",
+  "method": "post",
+  "mode": "cors"
+});`
+	cfg, err := fromFetch(config{}, snippet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.method != http.MethodPost || cfg.url != "https://a.test/api?q=1" || cfg.path != cfg.url {
+		t.Errorf("request = %s %s (path %s)", cfg.method, cfg.url, cfg.path)
+	}
+	if cfg.header.Get("Content-Type") != "application/json" || cfg.header.Get("Referer") != "https://a.test/page" {
+		t.Errorf("headers = %v", cfg.header)
+	}
+	if string(cfg.body) != `{"name":"x"}` {
+		t.Errorf("body = %q", cfg.body)
+	}
+}
+
+func TestFromFetchURLOnly(t *testing.T) {
+	cfg, err := fromFetch(config{body: []byte("old")}, `fetch("https://a.test/");`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.method != http.MethodGet || cfg.body != nil || len(cfg.header) != 0 {
+		t.Errorf("request = %s %q %v", cfg.method, cfg.body, cfg.header)
+	}
+}
+
+func TestFromFetchDefaultReferrer(t *testing.T) {
+	cfg, err := fromFetch(config{}, `fetch("https://a.test/", {"referrer": "about:client"})`)
+	if err != nil || cfg.header.Get("Referer") != "" {
+		t.Errorf("Referer = %q, %v, want none", cfg.header.Get("Referer"), err)
+	}
+}
+
+func TestFromFetchErrors(t *testing.T) {
+	for _, snippet := range []string{
+		`fetch(url)`,
+		`fetch("https://a.test/", {method: "POST"})`,
+		`window.fetch("https://a.test/")`,
+	} {
+		if _, err := fromFetch(config{}, snippet); err == nil {
+			t.Errorf("fromFetch(%q) succeeded", snippet)
+		}
+	}
+	if !looksLikeFetch("  await fetch (\"x\")") || looksLikeFetch("curl x") {
+		t.Error("looksLikeFetch misjudged a snippet")
+	}
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jlaffaye/ftp"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// dialTimeout bounds how long connecting to an FTP or SFTP server may
+// take. The transfer itself gets no deadline, as with downloads to disk.
+const dialTimeout = 10 * time.Second
+
+// fileStore is what a transfer needs of an FTP or SFTP server.
+type fileStore interface {
+	size(name string) (int64, error)                       // -1 if the server won't say.
+	open(name string, offset int64) (io.ReadCloser, error) // Read from offset on.
+	store(name string, r io.Reader) error
+	list(dir string) ([]os.FileInfo, error)
+	close() error
+}
+
+// isFileTransfer reports whether target is an ftp:// or sftp:// URL,
+// which are fetched and stored as files rather than sent as HTTP requests.
+func isFileTransfer(target string) bool {
+	u, err := url.Parse(target)
+	return err == nil && (u.Scheme == "ftp" || u.Scheme == "sftp")
+}
+
+// transferFile GETs or PUTs the file at an ftp:// or sftp:// URL, reporting
+// how many bytes have gone either way. A URL ending in / lists the
+// directory instead. Neither protocol has HTTP statuses, so success is
+// shown as 200, or 201 for an upload, with the details in transfer.
+func transferFile(cfg config, report func(string)) tea.Msg {
+	u, err := url.Parse(cfg.url)
+	if err != nil {
+		return errMsg{err}
+	}
+	if cfg.method != http.MethodGet && cfg.method != http.MethodPut {
+		return errMsg{fmt.Errorf("%s:// URLs can only GET and PUT files, not %s", u.Scheme, cfg.method)}
+	}
+
+	report("Connecting to " + u.Host)
+	var fs fileStore
+	if u.Scheme == "sftp" {
+		fs, err = dialSFTP(u)
+	} else {
+		fs, err = dialFTP(u)
+	}
+	if err != nil {
+		return errMsg{err}
+	}
+	defer fs.close()
+	return transfer(fs, u, cfg, report)
+}
+
+// transfer does what cfg asks of the file at u on fs.
+func transfer(fs fileStore, u *url.URL, cfg config, report func(string)) tea.Msg {
+	start := time.Now()
+	name := u.Path
+	switch {
+	case cfg.method == http.MethodPut:
+		body := &progressReader{r: bytes.NewReader(cfg.body), report: func(n int64) {
+			report(fmt.Sprintf("Uploading %s: %s", path.Base(name), progress(n, int64(len(cfg.body)))))
+		}}
+		if err := fs.store(name, body); err != nil {
+			return errMsg{fmt.Errorf("uploading %s: %w", name, err)}
+		}
+		r := describeBody(cfg, http.Header{}, nil)
+		r.status, r.final = http.StatusCreated, u
+		r.transfer = fmt.Sprintf("%s: uploaded %d bytes in %s", u.Scheme, body.n, since(start))
+		return responseMsg(r)
+
+	case name == "" || strings.HasSuffix(name, "/"):
+		entries, err := fs.list(name)
+		if err != nil {
+			return errMsg{fmt.Errorf("listing %s: %w", dirName(name), err)}
+		}
+		h := http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+		r := describeBody(cfg, h, listing(entries))
+		r.status, r.final = http.StatusOK, u
+		unit := "entries"
+		if len(entries) == 1 {
+			unit = "entry"
+		}
+		r.transfer = fmt.Sprintf("%s: %d %s in %s", u.Scheme, len(entries), unit, dirName(name))
+		return responseMsg(r)
+	}
+
+	// Resume a download to disk from where the file on disk ends.
+	total, _ := fs.size(name)
+	offset := resumeOffset(cfg)
+	h := http.Header{}
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		h.Set("Content-Type", ct)
+	}
+	if total >= 0 {
+		h.Set("Content-Length", strconv.FormatInt(total, 10))
+	}
+	if offset > 0 && total >= 0 && offset >= total {
+		r := describeBody(cfg, h, nil)
+		r.status, r.final = http.StatusOK, u
+		r.saved = &download{path: cfg.output, done: true}
+		return responseMsg(r)
+	}
+
+	rc, err := fs.open(name, offset)
+	if err != nil {
+		return errMsg{fmt.Errorf("downloading %s: %w", name, err)}
+	}
+	defer rc.Close()
+	in := &progressReader{r: rc, report: func(n int64) {
+		left := int64(-1)
+		if total >= 0 {
+			left = total - offset
+		}
+		report(fmt.Sprintf("Downloading %s: %s", path.Base(name), progress(n, left)))
+	}}
+
+	var saved *download
+	var body []byte
+	if cfg.output != "" {
+		saved, err = saveFile(in, cfg.output, offset)
+	} else {
+		body, err = io.ReadAll(io.LimitReader(in, maxBody))
+	}
+	if err != nil {
+		return errMsg{fmt.Errorf("downloading %s: %w", name, err)}
+	}
+	r := describeBody(cfg, h, body)
+	r.status, r.final, r.saved = http.StatusOK, u, saved
+	r.transfer = fmt.Sprintf("%s: downloaded %d bytes in %s", u.Scheme, in.n, since(start))
+	return responseMsg(r)
+}
+
+// saveFile writes r to file, appending when resuming from offset.
+func saveFile(r io.Reader, file string, offset int64) (*download, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(file, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d := &download{path: file, offset: offset}
+	d.written, err = io.Copy(f, r)
+	return d, err
+}
+
+// progressReader counts the bytes read through it and reports the count.
+type progressReader struct {
+	r      io.Reader
+	n      int64
+	report func(n int64)
+}
+
+// Read implements io.Reader.
+func (c *progressReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	c.report(c.n)
+	return n, err
+}
+
+// progress describes n bytes of total, which is -1 when unknown.
+func progress(n, total int64) string {
+	if total <= 0 {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	return fmt.Sprintf("%d of %d bytes (%d%%)", n, total, n*100/total)
+}
+
+// since returns how long ago start was, to the millisecond.
+func since(start time.Time) time.Duration {
+	return time.Since(start).Round(time.Millisecond)
+}
+
+// dirName names the directory at a URL path, the login directory when it's empty.
+func dirName(dir string) string {
+	if dir == "" {
+		return "the login directory"
+	}
+	return dir
+}
+
+// listing renders directory entries one per line with their size and
+// time, directories first and marked with a trailing /.
+func listing(entries []os.FileInfo) []byte {
+	var b bytes.Buffer
+	for _, dirs := range []bool{true, false} {
+		for _, e := range entries {
+			if e.IsDir() != dirs || e.Name() == "." || e.Name() == ".." {
+				continue
+			}
+			name := e.Name()
+			if dirs {
+				name += "/"
+			}
+			fmt.Fprintf(&b, "%12d  %s  %s\n", e.Size(), e.ModTime().Format("2006-01-02 15:04"), name)
+		}
+	}
+	if b.Len() == 0 {
+		return []byte("(empty directory)")
+	}
+	return b.Bytes()
+}
+
+// ftpStore is a logged-in FTP connection. Logins default to anonymous.
+type ftpStore struct{ c *ftp.ServerConn }
+
+func dialFTP(u *url.URL) (fileStore, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "21")
+	}
+	c, err := ftp.Dial(host, ftp.DialWithTimeout(dialTimeout))
+	if err != nil {
+		return nil, err
+	}
+	user, pass := "anonymous", "anonymous"
+	if u.User != nil {
+		user = u.User.Username()
+		pass, _ = u.User.Password()
+	}
+	if err := c.Login(user, pass); err != nil {
+		c.Quit()
+		return nil, fmt.Errorf("logging in as %s: %w", user, err)
+	}
+	return ftpStore{c}, nil
+}
+
+func (s ftpStore) size(name string) (int64, error) {
+	n, err := s.c.FileSize(name)
+	if err != nil {
+		return -1, err
+	}
+	return n, nil
+}
+
+func (s ftpStore) open(name string, offset int64) (io.ReadCloser, error) {
+	return s.c.RetrFrom(name, uint64(offset))
+}
+
+func (s ftpStore) store(name string, r io.Reader) error { return s.c.Stor(name, r) }
+
+func (s ftpStore) list(dir string) ([]os.FileInfo, error) {
+	entries, err := s.c.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, len(entries))
+	for i, e := range entries {
+		infos[i] = ftpEntry{e}
+	}
+	return infos, nil
+}
+
+func (s ftpStore) close() error { return s.c.Quit() }
+
+// ftpEntry adapts an FTP directory entry to os.FileInfo.
+type ftpEntry struct{ e *ftp.Entry }
+
+func (f ftpEntry) Name() string       { return f.e.Name }
+func (f ftpEntry) Size() int64        { return int64(f.e.Size) }
+func (f ftpEntry) Mode() os.FileMode  { return 0 }
+func (f ftpEntry) ModTime() time.Time { return f.e.Time }
+func (f ftpEntry) IsDir() bool        { return f.e.Type == ftp.EntryTypeFolder }
+func (f ftpEntry) Sys() any           { return f.e }
+
+// sftpStore is an SFTP session over an SSH connection.
+type sftpStore struct {
+	conn   *ssh.Client
+	client *sftp.Client
+}
+
+// dialSFTP starts an SFTP session with the server u names.
+func dialSFTP(u *url.URL) (fileStore, error) {
+	conn, err := dialSSH(u)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return sftpStore{conn, client}, nil
+}
+
+// dialSSH connects to the server u names the way ssh would: as the URL's
+// user, or the local one, with the URL's password, the keys in ssh-agent
+// or the default key files. The server's host key has to be in
+// ~/.ssh/known_hosts.
+func dialSSH(u *url.URL) (*ssh.Client, error) {
+	user := os.Getenv("USER")
+	var auth []ssh.AuthMethod
+	if u.User != nil {
+		user = u.User.Username()
+		if pass, ok := u.User.Password(); ok {
+			auth = append(auth, ssh.Password(pass))
+		}
+	}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			defer conn.Close()
+			auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	if signers := keyFiles(); len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+
+	home, _ := os.UserHomeDir()
+	known, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("reading known hosts: %w", err)
+	}
+	hostKey := func(host string, remote net.Addr, key ssh.PublicKey) error {
+		err := known(host, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return fmt.Errorf("%s is not in ~/.ssh/known_hosts; add it, e.g. with ssh-keyscan, after checking its key", host)
+		}
+		if errors.As(err, &keyErr) {
+			return fmt.Errorf("the host key of %s has changed since it went into ~/.ssh/known_hosts", host)
+		}
+		return err
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "22")
+	}
+	return ssh.Dial("tcp", host, &ssh.ClientConfig{User: user, Auth: auth, HostKeyCallback: hostKey, Timeout: dialTimeout})
+}
+
+// keyFiles loads the default private keys that need no passphrase.
+func keyFiles() []ssh.Signer {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	var signers []ssh.Signer
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		pem, err := os.ReadFile(filepath.Join(home, ".ssh", name))
+		if err != nil {
+			continue
+		}
+		if s, err := ssh.ParsePrivateKey(pem); err == nil {
+			signers = append(signers, s)
+		}
+	}
+	return signers
+}
+
+func (s sftpStore) size(name string) (int64, error) {
+	fi, err := s.client.Stat(name)
+	if err != nil {
+		return -1, err
+	}
+	return fi.Size(), nil
+}
+
+func (s sftpStore) open(name string, offset int64) (io.ReadCloser, error) {
+	f, err := s.client.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s sftpStore) store(name string, r io.Reader) error {
+	f, err := s.client.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := f.ReadFrom(r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s sftpStore) list(dir string) ([]os.FileInfo, error) {
+	if dir == "" {
+		dir = "."
+	}
+	return s.client.ReadDir(dir)
+}
+
+func (s sftpStore) close() error {
+	s.client.Close()
+	return s.conn.Close()
+}
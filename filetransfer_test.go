@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memStore is a fileStore holding its files in memory.
+type memStore struct {
+	files map[string][]byte
+}
+
+func (s *memStore) size(name string) (int64, error) {
+	b, ok := s.files[name]
+	if !ok {
+		return -1, os.ErrNotExist
+	}
+	return int64(len(b)), nil
+}
+
+func (s *memStore) open(name string, offset int64) (io.ReadCloser, error) {
+	b, ok := s.files[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(b[offset:])), nil
+}
+
+func (s *memStore) store(name string, r io.Reader) error {
+	b, err := io.ReadAll(r)
+	s.files[name] = b
+	return err
+}
+
+func (s *memStore) list(dir string) ([]os.FileInfo, error) {
+	var infos []os.FileInfo
+	for name, b := range s.files {
+		if strings.HasPrefix(name, dir) {
+			infos = append(infos, memInfo{strings.TrimPrefix(name, dir), int64(len(b))})
+		}
+	}
+	return infos, nil
+}
+
+func (s *memStore) close() error { return nil }
+
+// memInfo describes a file of a memStore.
+type memInfo struct {
+	name string
+	size int64
+}
+
+func (f memInfo) Name() string       { return f.name }
+func (f memInfo) Size() int64        { return f.size }
+func (f memInfo) Mode() os.FileMode  { return 0 }
+func (f memInfo) ModTime() time.Time { return time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC) }
+func (f memInfo) IsDir() bool        { return false }
+func (f memInfo) Sys() any           { return nil }
+
+// runTransfer transfers with fs and returns the response, or fails the
+// test on an error.
+func runTransfer(t *testing.T, fs fileStore, cfg config) response {
+	t.Helper()
+	u, err := url.Parse(cfg.url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reports []string
+	msg := transfer(fs, u, cfg, func(s string) { reports = append(reports, s) })
+	res, ok := msg.(responseMsg)
+	if !ok {
+		t.Fatalf("transfer returned %#v, want a response", msg)
+	}
+	return response(res)
+}
+
+func TestTransferUploadAndDownload(t *testing.T) {
+	fs := &memStore{files: map[string][]byte{}}
+	cfg := config{header: http.Header{}, method: http.MethodPut, url: "sftp://host/data/a.txt", body: []byte("hello")}
+
+	res := runTransfer(t, fs, cfg)
+	if res.status != http.StatusCreated {
+		t.Errorf("upload status = %d, want 201", res.status)
+	}
+	if !strings.HasPrefix(res.transfer, "sftp: uploaded 5 bytes in ") {
+		t.Errorf("upload transfer = %q", res.transfer)
+	}
+	if got := string(fs.files["/data/a.txt"]); got != "hello" {
+		t.Errorf("stored %q, want hello", got)
+	}
+
+	cfg.method, cfg.body = http.MethodGet, nil
+	res = runTransfer(t, fs, cfg)
+	if res.status != http.StatusOK || string(res.body) != "hello" {
+		t.Errorf("download = %d %q, want 200 hello", res.status, res.body)
+	}
+	if res.kind != kindText {
+		t.Errorf("download kind = %s, want text", res.kind)
+	}
+	if !strings.HasPrefix(res.transfer, "sftp: downloaded 5 bytes in ") {
+		t.Errorf("download transfer = %q", res.transfer)
+	}
+}
+
+func TestTransferResume(t *testing.T) {
+	fs := &memStore{files: map[string][]byte{"/f.bin": []byte("0123456789")}}
+	out := filepath.Join(t.TempDir(), "f.bin")
+	if err := os.WriteFile(out, []byte("0123"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config{header: http.Header{}, method: http.MethodGet, url: "ftp://host/f.bin", output: out, resume: true}
+
+	res := runTransfer(t, fs, cfg)
+	if res.status != http.StatusOK {
+		t.Errorf("status = %d, want 200", res.status)
+	}
+	if res.saved == nil || res.saved.offset != 4 || res.saved.written != 6 {
+		t.Fatalf("saved = %+v, want 6 bytes written from offset 4", res.saved)
+	}
+	if !strings.HasPrefix(res.transfer, "ftp: downloaded 6 bytes in ") {
+		t.Errorf("transfer = %q", res.transfer)
+	}
+	got, _ := os.ReadFile(out)
+	if string(got) != "0123456789" {
+		t.Errorf("resumed file = %q, want 0123456789", got)
+	}
+
+	// Once the file is complete, there is nothing left to fetch.
+	res = runTransfer(t, fs, cfg)
+	if res.saved == nil || !res.saved.done {
+		t.Errorf("saved = %+v, want done", res.saved)
+	}
+}
+
+func TestTransferListing(t *testing.T) {
+	fs := &memStore{files: map[string][]byte{"/pub/readme": []byte("abc")}}
+	cfg := config{header: http.Header{}, method: http.MethodGet, url: "ftp://host/pub/"}
+
+	res := runTransfer(t, fs, cfg)
+	if want := "           3  2024-01-02 03:04  readme\n"; string(res.body) != want {
+		t.Errorf("listing = %q, want %q", res.body, want)
+	}
+	if res.transfer != "ftp: 1 entry in /pub/" {
+		t.Errorf("transfer = %q", res.transfer)
+	}
+}
+
+func TestTransferRejectsOtherMethods(t *testing.T) {
+	cfg := config{header: http.Header{}, method: http.MethodPost, url: "ftp://host/f"}
+	msg := transferFile(cfg, func(string) {})
+	err, ok := msg.(errMsg)
+	if !ok || !strings.Contains(err.err.Error(), "can only GET and PUT") {
+		t.Errorf("transferFile = %#v, want a GET/PUT error", msg)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	tests := []struct {
+		n, total int64
+		want     string
+	}{
+		{10, -1, "10 bytes"},
+		{25, 100, "25 of 100 bytes (25%)"},
+		{0, 0, "0 bytes"},
+	}
+	for _, tt := range tests {
+		if got := progress(tt.n, tt.total); got != tt.want {
+			t.Errorf("progress(%d, %d) = %q, want %q", tt.n, tt.total, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fuzzWorkers is how many fuzzed requests are in flight at once.
+const fuzzWorkers = 10
+
+// fuzzBaseline is how many times the request is sent as it is, to learn
+// how long it normally takes.
+const fuzzBaseline = 3
+
+// A fuzzed request is slow when it takes slowFactor times as long as the
+// request itself, and slowMargin more at that.
+const (
+	slowFactor = 5
+	slowMargin = 250 * time.Millisecond
+)
+
+// fuzzPayload is one value fuzzing puts in a field.
+type fuzzPayload struct {
+	name  string // What it tries, e.g. "SQL injection".
+	value any    // Sent as it is in a JSON body, as text in a query parameter.
+}
+
+// fuzzPayloads are what fuzzing tries in every field: boundary values,
+// long and odd strings, injection attempts and values of the wrong type.
+var fuzzPayloads = []fuzzPayload{
+	{"empty string", ""},
+	{"zero", json.Number("0")},
+	{"negative", json.Number("-1")},
+	{"past int32", json.Number("2147483648")},
+	{"past int64", json.Number("9223372036854775808")},
+	{"huge float", json.Number("1e308")},
+	{"long string", strings.Repeat("A", 10000)},
+	{"unicode", "𝕳é‮ʟʟ​o 😀"},
+	{"NUL byte", "a\x00b"},
+	{"SQL injection", "' OR '1'='1' --"},
+	{"script injection", "<script>alert(1)</script>"},
+	{"template injection", "{{7*7}}${7*7}"},
+	{"path traversal", "../../../../etc/passwd"},
+	{"JNDI lookup", "${jndi:ldap://127.0.0.1/a}"},
+	{"format string", "%s%s%s%n"},
+	{"null", nil},
+	{"boolean", true},
+	{"array", []any{}},
+	{"object", map[string]any{}},
+}
+
+// fuzzCase is one fuzzed request: a payload in a field.
+type fuzzCase struct {
+	field   string
+	payload fuzzPayload
+	sample  sample
+}
+
+// runFuzz is the palette's `fuzz [field…]`: it sends the request again and
+// again with each of fuzzPayloads in each field, and reports the answers
+// that were server errors or unusually slow. A field is a query parameter
+// or a field of a JSON body, given by name or as a path like $.user.name;
+// without any, every query parameter and top-level body field is fuzzed.
+// Fuzzed requests stay out of the history.
+func runFuzz(m model, args []string) (tea.Model, tea.Cmd) {
+	if isFileTransfer(m.cfg.url) {
+		return m.paletteError(errors.New("only HTTP requests can be fuzzed"))
+	}
+	fields := args
+	if len(fields) == 0 {
+		fields = fuzzFields(m.cfg)
+	}
+	if len(fields) == 0 {
+		return m.paletteError(errors.New("the request has no query parameters or JSON body fields to fuzz"))
+	}
+	var cases []fuzzCase
+	for _, f := range fields {
+		if _, err := fuzzed(m.cfg, f, fuzzPayloads[0]); err != nil {
+			return m.paletteError(err)
+		}
+		for _, p := range fuzzPayloads {
+			cases = append(cases, fuzzCase{field: f, payload: p})
+		}
+	}
+	cfg := m.cfg
+	m.job = fmt.Sprintf("Fuzzing %d fields", len(fields))
+	return m, runJob(func(report func(string)) tea.Msg {
+		baseline := fuzz(cfg, cases, report)
+		return reportMsg{title: fmt.Sprintf("Fuzz %s %s", cfg.method, cfg.url), body: describeFuzz(fields, cases, baseline)}
+	})
+}
+
+// fuzzFields lists what fuzz tries without being told: the request's query
+// parameters and the top-level fields of its JSON object body.
+func fuzzFields(cfg config) []string {
+	var fields []string
+	if u, err := url.Parse(cfg.url); err == nil {
+		fields = slices.Sorted(maps.Keys(u.Query()))
+	}
+	if body, err := decodeJSON(cfg.body); err == nil {
+		if obj, ok := body.(map[string]any); ok {
+			for _, k := range slices.Sorted(maps.Keys(obj)) {
+				if identifier.MatchString(k) {
+					fields = append(fields, "$."+k)
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// fuzzed returns cfg with p in field: a query parameter of the URL, or with
+// a $. path, a field of the JSON body. A bare name that isn't a query
+// parameter is taken as a top-level body field.
+func fuzzed(cfg config, field string, p fuzzPayload) (config, error) {
+	u, err := url.Parse(cfg.url)
+	if err != nil {
+		return cfg, err
+	}
+	if q := u.Query(); !strings.HasPrefix(field, "$") && (q.Has(field) || cfg.body == nil) {
+		text, ok := p.value.(string)
+		if !ok {
+			b, _ := json.Marshal(p.value)
+			text = string(b)
+		}
+		q.Set(field, text)
+		u.RawQuery = q.Encode()
+		cfg.url = u.String()
+		return cfg, nil
+	}
+
+	body, err := decodeJSON(cfg.body)
+	if err != nil {
+		return cfg, fmt.Errorf("%s is not a query parameter, and the body isn't JSON", field)
+	}
+	keys := strings.Split(strings.TrimPrefix(strings.TrimPrefix(field, "$"), "."), ".")
+	obj, ok := body.(map[string]any)
+	for _, k := range keys[:len(keys)-1] {
+		if !ok {
+			break
+		}
+		obj, ok = obj[k].(map[string]any)
+	}
+	if !ok || keys[len(keys)-1] == "" {
+		return cfg, fmt.Errorf("the body has no object to put %s in", field)
+	}
+	// The field is copied into a fresh body each time, so the payloads of
+	// one case never leak into another's.
+	obj[keys[len(keys)-1]] = p.value
+	if cfg.body, err = json.Marshal(body); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// fuzz sends the request fuzzBaseline times as it is, then every case,
+// fuzzWorkers at a time, filling in their samples. It returns how long
+// the request takes as it is.
+func fuzz(cfg config, cases []fuzzCase, report func(string)) time.Duration {
+	var times []time.Duration
+	for range fuzzBaseline {
+		start := time.Now()
+		s := toSample(send(withRequestID(cfg)), start, time.Since(start))
+		times = append(times, s.elapsed)
+	}
+	slices.Sort(times)
+	baseline := times[len(times)/2]
+
+	var next, done atomic.Int64
+	var wg sync.WaitGroup
+	for range min(fuzzWorkers, len(cases)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < len(cases); i = int(next.Add(1) - 1) {
+				c := &cases[i]
+				fc, _ := fuzzed(cfg, c.field, c.payload)
+				start := time.Now()
+				c.sample = toSample(send(withRequestID(fc)), start, time.Since(start))
+				report(fmt.Sprintf("%d of %d sent", done.Add(1), len(cases)))
+			}
+		}()
+	}
+	wg.Wait()
+	return baseline
+}
+
+// slow reports whether a fuzzed request took unusually long next to the
+// request itself.
+func slow(d, baseline time.Duration) bool {
+	return d > slowFactor*baseline && d > baseline+slowMargin
+}
+
+// describeFuzz writes up a fuzz run: the statuses, then the cases that
+// were server errors, slow or unanswered.
+func describeFuzz(fields []string, cases []fuzzCase, baseline time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Sent %d requests, fuzzing %s; as it is, the request takes %s.\n", len(cases), strings.Join(fields, ", "), baseline.Round(time.Millisecond))
+	samples := make([]sample, len(cases))
+	for i, c := range cases {
+		samples[i] = c.sample
+	}
+	b.WriteString("Statuses: " + sampleStatuses(samples) + "\n")
+
+	var errs, slowOnes, failed []string
+	for _, c := range cases {
+		line := fmt.Sprintf("  %s = %s → ", c.field, c.payload.name)
+		switch s := c.sample; {
+		case s.err != nil:
+			failed = append(failed, line+s.err.Error())
+		case s.status >= 500:
+			errs = append(errs, line+fmt.Sprintf("%d in %s", s.status, s.elapsed.Round(time.Millisecond)))
+		case slow(s.elapsed, baseline):
+			slowOnes = append(slowOnes, line+fmt.Sprintf("%d in %s", s.status, s.elapsed.Round(time.Millisecond)))
+		}
+	}
+	if len(errs)+len(slowOnes)+len(failed) == 0 {
+		b.WriteString("\nNo server errors, slow answers or dropped connections.\n")
+		return b.String()
+	}
+	for _, group := range []struct {
+		title string
+		lines []string
+	}{
+		{"Server errors", errs},
+		{fmt.Sprintf("Slow answers, %d× the usual and more", slowFactor), slowOnes},
+		{"No answer", failed},
+	} {
+		if len(group.lines) > 0 {
+			fmt.Fprintf(&b, "\n%s (%d):\n%s\n", group.title, len(group.lines), strings.Join(group.lines, "\n"))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFuzzFields(t *testing.T) {
+	cfg := config{url: "https://example.com/search?q=a&page=1", body: []byte(`{"user": {"name": "x"}, "odd key": 1, "n": 2}`)}
+	if got := fuzzFields(cfg); !slices.Equal(got, []string{"page", "q", "$.n", "$.user"}) {
+		t.Errorf("fields = %q", got)
+	}
+
+	got, err := fuzzed(cfg, "q", fuzzPayload{"null", nil})
+	if err != nil || got.url != "https://example.com/search?page=1&q=null" {
+		t.Errorf("query: %q, %v", got.url, err)
+	}
+	got, err = fuzzed(cfg, "$.user.name", fuzzPayload{"array", []any{}})
+	if err != nil || string(got.body) != `{"n":2,"odd key":1,"user":{"name":[]}}` {
+		t.Errorf("body: %s, %v", got.body, err)
+	}
+	if got, err = fuzzed(cfg, "n", fuzzPayload{"boolean", true}); err != nil || !strings.Contains(string(got.body), `"n":true`) {
+		t.Errorf("a bare body field: %s, %v", got.body, err)
+	}
+	if _, err := fuzzed(cfg, "$.n.deeper", fuzzPayload{"zero", 0}); err == nil {
+		t.Error("fuzzing inside a number")
+	}
+	if _, err := fuzzed(config{url: "https://example.com/", body: []byte("plain")}, "x", fuzzPayloads[0]); err == nil {
+		t.Error("fuzzing a body that isn't JSON")
+	}
+}
+
+func TestFuzz(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch q := r.URL.Query().Get("id"); {
+		case strings.Contains(q, "'"):
+			w.WriteHeader(http.StatusInternalServerError)
+		case len(q) > 1000:
+			time.Sleep(300 * time.Millisecond)
+		case q == "":
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := config{method: "GET", url: srv.URL + "/?id=1", bodyFormat: "json"}
+	var cases []fuzzCase
+	for _, p := range fuzzPayloads {
+		cases = append(cases, fuzzCase{field: "id", payload: p})
+	}
+	baseline := fuzz(cfg, cases, func(string) {})
+	out := describeFuzz([]string{"id"}, cases, baseline)
+	for _, want := range []string{
+		"Sent 19 requests, fuzzing id",
+		"Statuses: 200×17, 400×1, 500×1",
+		"Server errors (1):\n  id = SQL injection → 500",
+		"Slow answers, 5× the usual and more (1):\n  id = long string → 200",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q:\n%s", want, out)
+		}
+	}
+	if !slow(time.Second, 10*time.Millisecond) || slow(100*time.Millisecond, 10*time.Millisecond) || slow(4*time.Second, time.Second) {
+		t.Error("slow is wrong")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// historyEntry is one request the program sent, as kept in the history
+// file, one JSON object per line.
+type historyEntry struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	URL    string    `json:"url"`
+	Env    string    `json:"env,omitempty"`
+	ID     string    `json:"request_id,omitempty"` // The X-Request-ID it was sent with.
+	Status int       `json:"status,omitempty"`     // 0 when the request failed.
+	Millis float64   `json:"ms"`                   // How long until the whole answer was in.
+	Error  string    `json:"error,omitempty"`
+
+	// Body is the start of the answer, if it was text, to search; only a
+	// history database keeps it. See historydb.go.
+	Body string `json:"body,omitempty"`
+}
+
+// defaultHistoryFile is where the history is kept unless -history says otherwise.
+func defaultHistoryFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "httpwizard", "history.db")
+}
+
+// historyKey identifies a request across runs: its method and URL as given,
+// before any {{function}} calls in it are filled in.
+func historyKey(method, url string) string {
+	return method + " " + url
+}
+
+// elapsed is how long the entry's request took.
+func (e historyEntry) elapsed() time.Duration {
+	return time.Duration(e.Millis * float64(time.Millisecond))
+}
+
+// ok reports whether the request got an answer that wasn't a server error,
+// which is what counts towards availability.
+func (e historyEntry) ok() bool {
+	return e.Error == "" && e.Status > 0 && e.Status < 500
+}
+
+// recordHistory appends what came of a request to the history file, if
+// there is one. msg is what the request's command returned.
+func recordHistory(cfg config, msg any, elapsed time.Duration) error {
+	if cfg.historyFile == "" {
+		return nil
+	}
+	e := historyEntry{Time: time.Now().UTC(), Method: cfg.method, URL: cfg.url, Env: cfg.env, ID: cfg.header.Get("X-Request-ID"), Millis: float64(elapsed.Microseconds()) / 1000}
+	switch msg := msg.(type) {
+	case responseMsg:
+		e.Status = msg.status
+		if isHistoryDB(cfg.historyFile) {
+			e.Body = searchableBody(msg.body)
+		}
+	case errMsg:
+		e.Error = msg.err.Error()
+	default:
+		return nil // Still under way, like a file transfer; it isn't an HTTP check anyway.
+	}
+	if isHistoryDB(cfg.historyFile) {
+		return appendHistoryDB(cfg.historyFile, e, cfg.historyRetention)
+	}
+	return appendHistory(cfg.historyFile, e)
+}
+
+// appendHistory adds e to the end of the history file at path.
+func appendHistory(path string, e historyEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readHistory returns every entry in the history file at path, oldest
+// first. A missing file is an empty history; lines that don't parse, say
+// the half-written last one of a crash, are skipped.
+func readHistory(path string) ([]historyEntry, error) {
+	if isHistoryDB(path) {
+		return readHistoryDB(path)
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []historyEntry
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 1<<20)
+	for s.Scan() {
+		var e historyEntry
+		if json.Unmarshal(s.Bytes(), &e) != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, s.Err()
+}
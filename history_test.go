@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", "history.jsonl")
+	if entries, err := readHistory(path); err != nil || entries != nil {
+		t.Fatalf("missing history = %v, %v; want empty", entries, err)
+	}
+
+	cfg := config{method: "GET", url: "https://example.com/health", env: "prod", historyFile: path}
+	if err := recordHistory(cfg, responseMsg{status: 204}, 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordHistory(cfg, errMsg{errors.New("connection refused")}, 3*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordHistory(cfg, reportMsg{}, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash can leave half a line behind; it shouldn't cost the rest.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2026-`)
+	f.Close()
+
+	entries, err := readHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2: %+v", len(entries), entries)
+	}
+	if e := entries[0]; e.Status != 204 || e.Env != "prod" || e.elapsed() != 120*time.Millisecond || !e.ok() {
+		t.Errorf("first entry = %+v", e)
+	}
+	if e := entries[1]; e.Error != "connection refused" || e.ok() {
+		t.Errorf("second entry = %+v", e)
+	}
+	if historyKey(entries[0].Method, entries[0].URL) != "GET https://example.com/health" {
+		t.Errorf("key = %q", historyKey(entries[0].Method, entries[0].URL))
+	}
+}
+
+func TestHistoryDisabled(t *testing.T) {
+	if err := recordHistory(config{method: "GET", url: "http://x"}, responseMsg{status: 200}, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHistoryEntryOK(t *testing.T) {
+	for status, want := range map[int]bool{200: true, 404: true, 499: true, 500: false, 503: false, 0: false} {
+		if got := (historyEntry{Status: status}).ok(); got != want {
+			t.Errorf("ok() for %d = %v, want %v", status, got, want)
+		}
+	}
+}
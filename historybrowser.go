@@ -0,0 +1,376 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+)
+
+// The history browser finds a request sent before, like that 500 from
+// Tuesday, by narrowing the history down as a filter is typed: words to
+// search for, and facets such as status:5xx or on:tuesday. The matches can
+// be grouped by host or by day, and enter sends the selected one again.
+
+// historyBrowseLimit caps how many matches the browser lists, newest first.
+const historyBrowseLimit = 1000
+
+// historyGroupings are the ways the browser can group its matches, in the
+// order tab cycles through them; "" lists them as they come.
+var historyGroupings = []string{"", "host", "day"}
+
+// historyFilter is what a filter line asks for. Zero fields match anything.
+type historyFilter struct {
+	text   string    // Words to search for.
+	method string    // In upper case.
+	status int       // An exact status…
+	class  int       // …or a class of them: 5 for 5xx.
+	failed bool      // Only requests that got no answer.
+	host   string    // Part of the host, in lower case.
+	from   time.Time // Sent at or after this…
+	to     time.Time // …and before this.
+}
+
+// historyBrowser is the history browser's state.
+type historyBrowser struct {
+	input    textinput.Model // The filter line.
+	query    string          // The filter line the matches are for.
+	matches  []historyEntry  // Newest first, or in groups.
+	grouping int             // Index into historyGroupings.
+	cursor   int             // Selected match.
+	offset   int             // First visible match.
+	err      error           // Why the filter or the search failed.
+}
+
+// historyFoundMsg carries the matches of a filter line.
+type historyFoundMsg struct {
+	query   string
+	matches []historyEntry
+	err     error
+}
+
+// parseHistoryFilter reads a filter line such as "status:500 on:tue users",
+// as of now. Facets are method:, status: (404, 5xx or err), host:, and
+// since:, until: and on:, which take a date, a weekday, today, yesterday,
+// or, for since:, a duration like 90m or 7d.
+func parseHistoryFilter(line string, now time.Time) (historyFilter, error) {
+	var f historyFilter
+	var words []string
+	for _, field := range strings.Fields(line) {
+		name, value, ok := strings.Cut(field, ":")
+		// A word that merely has a colon in it, like a URL, is searched for.
+		if !ok || value == "" || !slices.Contains([]string{"method", "status", "host", "since", "until", "on"}, strings.ToLower(name)) {
+			words = append(words, field)
+			continue
+		}
+		switch strings.ToLower(name) {
+		case "method":
+			f.method = strings.ToUpper(value)
+		case "status":
+			lower := strings.ToLower(value)
+			switch {
+			case lower == "err" || lower == "error":
+				f.failed = true
+			case len(lower) == 3 && strings.HasSuffix(lower, "xx") && lower[0] >= '1' && lower[0] <= '5':
+				f.class = int(lower[0] - '0')
+			default:
+				n, err := strconv.Atoi(value)
+				if err != nil || n < 100 || n > 599 {
+					return historyFilter{}, fmt.Errorf("status:%s is not a status; try 404, 5xx or err", value)
+				}
+				f.status = n
+			}
+		case "host":
+			f.host = strings.ToLower(value)
+		case "since":
+			if d, err := parseAge(value); err == nil {
+				f.from = now.Add(-d)
+				continue
+			}
+			day, err := parseDay(value, now)
+			if err != nil {
+				return historyFilter{}, err
+			}
+			f.from = day
+		case "until":
+			day, err := parseDay(value, now)
+			if err != nil {
+				return historyFilter{}, err
+			}
+			f.to = day.AddDate(0, 0, 1)
+		case "on":
+			day, err := parseDay(value, now)
+			if err != nil {
+				return historyFilter{}, err
+			}
+			f.from, f.to = day, day.AddDate(0, 0, 1)
+		}
+	}
+	f.text = strings.Join(words, " ")
+	return f, nil
+}
+
+// parseAge reads a duration as time.ParseDuration does, or a number of
+// days such as 7d.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a number of days", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// parseDay returns the start of the day s names, in now's time zone: a
+// date such as 2026-10-13, today, yesterday, or a weekday, mon or monday,
+// which is the latest such day up to today.
+func parseDay(s string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	lower := strings.ToLower(s)
+	switch lower {
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	if day, err := time.ParseInLocation(time.DateOnly, s, now.Location()); err == nil {
+		return day, nil
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if lower == name || (len(lower) >= 3 && strings.HasPrefix(name, lower)) {
+			return today.AddDate(0, 0, -((int(now.Weekday()) - int(d) + 7) % 7)), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a day; try 2026-10-13, today, yesterday or a weekday", s)
+}
+
+// keep reports whether e has the facets f asks for; the words are left to
+// the search.
+func (f historyFilter) keep(e historyEntry) bool {
+	switch {
+	case f.method != "" && e.Method != f.method:
+		return false
+	case f.status != 0 && e.Status != f.status:
+		return false
+	case f.class != 0 && e.Status/100 != f.class:
+		return false
+	case f.failed && e.Error == "":
+		return false
+	case f.host != "" && !strings.Contains(strings.ToLower(entryHost(e)), f.host):
+		return false
+	case !f.from.IsZero() && e.Time.Before(f.from):
+		return false
+	case !f.to.IsZero() && !e.Time.Before(f.to):
+		return false
+	}
+	return true
+}
+
+// entryHost is the host e's request went to, or its URL if that doesn't
+// parse.
+func entryHost(e historyEntry) string {
+	if u, err := url.Parse(e.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return e.URL
+}
+
+// runHistory is the palette's `history [filter]`: it opens the history
+// browser, with the filter typed in if one is given.
+func runHistory(m model, args []string) (tea.Model, tea.Cmd) {
+	if m.cfg.historyFile == "" {
+		return m.paletteError(errors.New("the history is switched off, so there is nothing to browse"))
+	}
+	in := textinput.New()
+	in.Prompt = "filter: "
+	in.Placeholder = "words, method:GET, status:5xx, host:api, since:7d, on:tuesday"
+	in.Cursor.SetMode(cursor.CursorStatic)
+	in.SetValue(strings.Join(args, " "))
+	in.Focus()
+	b := &historyBrowser{input: in, query: in.Value()}
+	m.history = b
+	return m, findHistory(m.cfg.historyFile, b.query)
+}
+
+// findHistory returns a command that searches the history at path for
+// what the filter line query asks for.
+func findHistory(path, query string) tea.Cmd {
+	return func() tea.Msg {
+		f, err := parseHistoryFilter(query, time.Now())
+		if err != nil {
+			return historyFoundMsg{query: query, err: err}
+		}
+		matches, err := searchHistory(path, f.text, f.keep, historyBrowseLimit)
+		return historyFoundMsg{query: query, matches: matches, err: err}
+	}
+}
+
+// showHistory takes in the matches of a filter line, unless it has been
+// typed over since.
+func (m model) showHistory(msg historyFoundMsg) (tea.Model, tea.Cmd) {
+	if m.history == nil || msg.query != m.history.query {
+		return m, nil
+	}
+	b := *m.history
+	b.matches, b.err = msg.matches, msg.err
+	b.cursor, b.offset = 0, 0
+	b.group()
+	m.history = &b
+	return m, nil
+}
+
+// groupOf is the group the browser puts e in, as grouped now.
+func (b *historyBrowser) groupOf(e historyEntry) string {
+	switch historyGroupings[b.grouping] {
+	case "host":
+		return entryHost(e)
+	case "day":
+		return e.Time.Local().Format("Monday 2 January 2006")
+	}
+	return ""
+}
+
+// group orders the matches into their groups, the group of the newest
+// match first, keeping them newest first within each.
+func (b *historyBrowser) group() {
+	slices.SortStableFunc(b.matches, func(x, y historyEntry) int { return y.Time.Compare(x.Time) })
+	rank := map[string]int{}
+	for _, e := range b.matches {
+		if _, ok := rank[b.groupOf(e)]; !ok {
+			rank[b.groupOf(e)] = len(rank)
+		}
+	}
+	slices.SortStableFunc(b.matches, func(x, y historyEntry) int { return cmp.Compare(rank[b.groupOf(x)], rank[b.groupOf(y)]) })
+}
+
+// updateHistory handles keys while the history browser is open: arrows
+// select, tab groups, enter sends the selected request again, and the rest
+// edit the filter.
+func (m model) updateHistory(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	copied := *m.history
+	b := &copied
+	m.history = b
+	last := max(len(b.matches)-1, 0)
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.history = nil
+		return m, nil
+	case "up":
+		b.cursor = max(b.cursor-1, 0)
+	case "down":
+		b.cursor = min(b.cursor+1, last)
+	case "pgup":
+		b.cursor = max(b.cursor-tableHeight, 0)
+	case "pgdown":
+		b.cursor = min(b.cursor+tableHeight, last)
+	case "tab":
+		b.grouping = (b.grouping + 1) % len(historyGroupings)
+		b.group()
+		b.cursor, b.offset = 0, 0
+	case "enter":
+		if len(b.matches) == 0 {
+			return m, nil
+		}
+		e := b.matches[b.cursor]
+		m.history = nil
+		return m.switchTo(savedTab{Method: e.Method, URL: e.URL})
+	default:
+		var cmd tea.Cmd
+		b.input, cmd = b.input.Update(msg)
+		if b.input.Value() != b.query {
+			b.query = b.input.Value()
+			return m, tea.Batch(cmd, findHistory(m.cfg.historyFile, b.query))
+		}
+		return m, cmd
+	}
+	b.scroll()
+	return m, nil
+}
+
+// scroll keeps the cursor in view.
+func (b *historyBrowser) scroll() {
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	}
+	if b.cursor >= b.offset+tableHeight {
+		b.offset = b.cursor - tableHeight + 1
+	}
+}
+
+// historyLine is a match as the browser lists it: when, the method, the
+// status or ERR, how long it took, and the URL. Grouped by day, the day
+// goes without saying.
+func historyLine(e historyEntry, byDay bool) string {
+	when := e.Time.Local().Format("Mon 02 Jan 15:04")
+	if byDay {
+		when = e.Time.Local().Format("15:04:05")
+	}
+	status := strconv.Itoa(e.Status)
+	if e.Error != "" || e.Status == 0 {
+		status = "ERR"
+	}
+	return fmt.Sprintf("%s  %-6s %-4s %8s  %s", when, e.Method, status, fmt.Sprintf("%.0fms", e.Millis), e.URL)
+}
+
+// viewHistory renders the filter line, then the visible matches under the
+// headings of their groups.
+func (m model) viewHistory() string {
+	b := m.history
+	var s strings.Builder
+	s.WriteString("\nHistory\n\n" + b.input.View() + "\n\n")
+	if b.err != nil {
+		s.WriteString(b.err.Error() + "\n")
+	} else if len(b.matches) == 0 {
+		s.WriteString("Nothing in the history matches.\n")
+	}
+
+	counts := map[string]int{}
+	for _, e := range b.matches {
+		counts[b.groupOf(e)]++
+	}
+	grouped := historyGroupings[b.grouping] != ""
+	for i := b.offset; i < min(b.offset+tableHeight, len(b.matches)); i++ {
+		e := b.matches[i]
+		if group := b.groupOf(e); grouped && (i == b.offset || group != b.groupOf(b.matches[i-1])) {
+			fmt.Fprintf(&s, "── %s (%d)\n", group, counts[group])
+		}
+		mark := "  "
+		if i == b.cursor {
+			mark = "> "
+		}
+		line := historyLine(e, historyGroupings[b.grouping] == "day")
+		if m.width > 0 {
+			line = runewidth.Truncate(line, m.width-2, "…")
+		}
+		s.WriteString(mark + line + "\n")
+	}
+
+	if len(b.matches) > 0 {
+		more := ""
+		if len(b.matches) == historyBrowseLimit {
+			more = ", the newest only"
+		}
+		fmt.Fprintf(&s, "\nmatch %d of %d%s\n", b.cursor+1, len(b.matches), more)
+	}
+	grouping := "none"
+	if grouped {
+		grouping = "by " + historyGroupings[b.grouping]
+	}
+	fmt.Fprintf(&s, "\n↑/↓ select • enter send again • tab grouping (%s) • esc close\n", grouping)
+	return s.String()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestParseHistoryFilter(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2026, 10, 14, 15, 30, 0, 0, time.UTC)
+	tuesday := time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC)
+
+	f, err := parseHistoryFilter("status:500 on:Tuesday users https://x.test/a method:post", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.status != 500 || f.method != "POST" || !f.from.Equal(tuesday) || !f.to.Equal(tuesday.AddDate(0, 0, 1)) || f.text != "users https://x.test/a" {
+		t.Errorf("filter = %+v", f)
+	}
+
+	f, _ = parseHistoryFilter("status:5xx since:7d host:API", now)
+	if f.class != 5 || !f.from.Equal(now.Add(-7*24*time.Hour)) || f.host != "api" {
+		t.Errorf("filter = %+v", f)
+	}
+	f, _ = parseHistoryFilter("status:err since:yesterday until:today", now)
+	if !f.failed || !f.from.Equal(tuesday) || !f.to.Equal(tuesday.AddDate(0, 0, 2)) {
+		t.Errorf("filter = %+v", f)
+	}
+	// Today's weekday is today, not a week ago.
+	if f, _ := parseHistoryFilter("on:wed", now); !f.from.Equal(tuesday.AddDate(0, 0, 1)) {
+		t.Errorf("on:wed from %v", f.from)
+	}
+	if f, _ := parseHistoryFilter("on:2026-10-01", now); f.from.Day() != 1 {
+		t.Errorf("on:2026-10-01 from %v", f.from)
+	}
+
+	for _, bad := range []string{"status:600", "status:abc", "on:someday", "until:7d"} {
+		if _, err := parseHistoryFilter(bad, now); err == nil {
+			t.Errorf("%s parsed", bad)
+		}
+	}
+}
+
+func TestHistoryFilterKeep(t *testing.T) {
+	e := historyEntry{Time: time.Date(2026, 10, 13, 9, 0, 0, 0, time.UTC), Method: "GET", URL: "https://api.example.com/users", Status: 503}
+	now := time.Date(2026, 10, 14, 15, 30, 0, 0, time.UTC)
+	for line, want := range map[string]bool{
+		"":                       true,
+		"status:5xx on:tue":      true,
+		"status:503 host:api":    true,
+		"status:500":             false,
+		"status:4xx":             false,
+		"status:err":             false,
+		"method:post":            false,
+		"host:other.example.com": false,
+		"since:1h":               false,
+		"until:monday":           false,
+	} {
+		f, err := parseHistoryFilter(line, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.keep(e); got != want {
+			t.Errorf("%q keeps = %v, want %v", line, got, want)
+		}
+	}
+}
+
+func TestHistoryBrowser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day()-2, 12, 0, 0, 0, time.Local)
+	for i, e := range []historyEntry{
+		{Method: "GET", URL: "https://a.example.com/users", Status: 200},
+		{Method: "GET", URL: "https://b.example.com/orders", Status: 500},
+		{Method: "POST", URL: "https://a.example.com/users", Status: 201},
+		{Method: "GET", URL: "https://b.example.com/orders", Error: "connection refused"},
+	} {
+		e.Time = day.Add(time.Duration(i) * time.Minute)
+		if err := appendHistoryDB(path, e, historyRetention{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := model{cfg: config{method: "GET", url: "https://a.example.com/users", body: []byte("{}"), historyFile: path}}
+	next, cmd := runHistory(m, []string{"orders"})
+	next, _ = next.(model).Update(cmd())
+	b := next.(model).history
+	if len(b.matches) != 2 || b.matches[0].Error == "" || b.matches[1].Status != 500 {
+		t.Fatalf("matches = %+v", b.matches)
+	}
+
+	// Typing narrows the matches down.
+	next, cmd = next.(model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(" status:5xx")})
+	next, _ = next.(model).Update(foundMsg(cmd))
+	if b := next.(model).history; len(b.matches) != 1 || b.matches[0].Status != 500 {
+		t.Fatalf("matches = %+v", b.matches)
+	}
+	// Matches for what has since been typed over are dropped.
+	stale, _ := next.(model).Update(historyFoundMsg{query: "orders"})
+	if b := stale.(model).history; len(b.matches) != 1 {
+		t.Errorf("stale matches were taken: %+v", b.matches)
+	}
+
+	// Grouped by host, the group of the newest match leads.
+	m.history = nil
+	next, cmd = runHistory(m, nil)
+	next, _ = next.(model).Update(cmd())
+	next, _ = next.(model).Update(tea.KeyMsg{Type: tea.KeyTab})
+	view := next.(model).View()
+	if !strings.Contains(view, "── b.example.com (2)") || strings.Index(view, "b.example.com (2)") > strings.Index(view, "a.example.com (2)") {
+		t.Errorf("grouped by host:\n%s", view)
+	}
+	next, _ = next.(model).Update(tea.KeyMsg{Type: tea.KeyTab})
+	if view := next.(model).View(); !strings.Contains(view, "── "+day.Local().Format("Monday 2 January 2006")+" (4)") {
+		t.Errorf("grouped by day:\n%s", view)
+	}
+
+	// Enter sends the selected request again, without another's body.
+	next, _ = next.(model).Update(tea.KeyMsg{Type: tea.KeyDown})
+	next, cmd = next.(model).Update(tea.KeyMsg{Type: tea.KeyEnter})
+	got := next.(model)
+	if got.history != nil || cmd == nil || got.cfg.method != "POST" || got.cfg.url != "https://a.example.com/users" || got.cfg.body != nil {
+		t.Errorf("after enter: history %v, cfg %s %s %q", got.history, got.cfg.method, got.cfg.url, got.cfg.body)
+	}
+}
+
+// foundMsg runs cmd, and the commands it batches, for the matches they find.
+func foundMsg(cmd tea.Cmd) tea.Msg {
+	msg := cmd()
+	if batch, ok := msg.(tea.BatchMsg); ok {
+		for _, c := range batch {
+			if c != nil {
+				if found, ok := c().(historyFoundMsg); ok {
+					return found
+				}
+			}
+		}
+	}
+	return msg
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// A history file of JSON lines has to be read whole to answer anything,
+// which stops being quick after a few thousand requests. So the history is
+// kept in an embedded bbolt database instead, whenever its file ends in
+// .db, as it does by default: the entries in the order they were sent,
+// with an index of them by request and a word index over their URLs and
+// the text of their answers for searching. Old entries make way for new
+// ones past -history-keep and -history-max.
+
+// Buckets of the history database.
+var (
+	historyEntries = []byte("entries") // Sequence number → JSON entry.
+	historyByKey   = []byte("by_key")  // historyKey, 0, sequence number → nothing.
+	historyWords   = []byte("words")   // Word, 0, sequence number → nothing.
+	historyMeta    = []byte("meta")    // What is known of the entries as a whole.
+)
+
+// historyCountKey is where the meta bucket keeps how many entries there are.
+var historyCountKey = []byte("count")
+
+// defaultHistoryMax is how many entries the history keeps unless
+// -history-max says otherwise.
+const defaultHistoryMax = 100000
+
+// maxHistoryBody caps how much of an answer's body is kept for searching.
+const maxHistoryBody = 16 << 10
+
+// maxEntryWords caps how many different words of an entry are indexed.
+const maxEntryWords = 500
+
+// historyLock keeps the program's own writers from waiting on each other's
+// file lock; another program running holds it only a moment at a time.
+var historyLock sync.Mutex
+
+// historyRetention says how long, and how many, entries the history keeps.
+type historyRetention struct {
+	keep time.Duration // Entries older than this are dropped; 0 to keep them however old.
+	max  int           // Past this many, the oldest are dropped; 0 for defaultHistoryMax.
+}
+
+// isHistoryDB reports whether the history at path is a database rather
+// than JSON lines.
+func isHistoryDB(path string) bool {
+	return strings.HasSuffix(path, ".db")
+}
+
+// openHistory opens the history database at path, creating it and its
+// buckets if need be, and importing the JSON lines history beside it if
+// there is one from before. The caller holds historyLock.
+func openHistory(path string) (*bolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	_, statErr := os.Stat(path)
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening the history: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{historyEntries, historyByKey, historyWords, historyMeta} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil && errors.Is(statErr, os.ErrNotExist) {
+		err = importHistory(db, strings.TrimSuffix(path, ".db")+".jsonl")
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// importHistory moves the JSON lines history at old, if there is one,
+// into db, and renames it so it isn't imported twice.
+func importHistory(db *bolt.DB, old string) error {
+	entries, err := readHistory(old)
+	if err != nil || entries == nil {
+		return err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, e := range entries {
+			if err := putEntry(tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("importing %s: %w", old, err)
+	}
+	return os.Rename(old, old+".imported")
+}
+
+// historySeq writes a sequence number as a key that sorts in order.
+func historySeq(n uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, n)
+}
+
+// indexKey is the key of an index entry: what is indexed, then a 0 byte,
+// then the entry's sequence number.
+func indexKey(term string, seq []byte) []byte {
+	return append(append([]byte(term), 0), seq...)
+}
+
+// putEntry adds e to the history, and to its indexes.
+func putEntry(tx *bolt.Tx, e historyEntry) error {
+	entries := tx.Bucket(historyEntries)
+	n, err := entries.NextSequence()
+	if err != nil {
+		return err
+	}
+	seq := historySeq(n)
+	value, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := entries.Put(seq, value); err != nil {
+		return err
+	}
+	if err := addToCount(tx, 1); err != nil {
+		return err
+	}
+	if err := tx.Bucket(historyByKey).Put(indexKey(historyKey(e.Method, e.URL), seq), nil); err != nil {
+		return err
+	}
+	for _, w := range entryWords(e) {
+		if err := tx.Bucket(historyWords).Put(indexKey(w, seq), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteEntry removes the entry at seq, e, from the history and its
+// indexes.
+func deleteEntry(tx *bolt.Tx, seq []byte, e historyEntry) error {
+	if err := tx.Bucket(historyEntries).Delete(seq); err != nil {
+		return err
+	}
+	if err := addToCount(tx, -1); err != nil {
+		return err
+	}
+	if err := tx.Bucket(historyByKey).Delete(indexKey(historyKey(e.Method, e.URL), seq)); err != nil {
+		return err
+	}
+	for _, w := range entryWords(e) {
+		if err := tx.Bucket(historyWords).Delete(indexKey(w, seq)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryWords lists the different words of e's URL, error and body, in
+// lower case, as the word index keeps them.
+func entryWords(e historyEntry) []string {
+	return words(e.Method+" "+e.URL+" "+e.Error+" "+e.Body, maxEntryWords)
+}
+
+// words splits s into its different words of letters and digits, in lower
+// case, up to n of them. Words of one letter, and those too long to be
+// anything but IDs and hashes, are left out.
+func words(s string, n int) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if len(w) < 2 || len(w) > 40 || seen[w] {
+			continue
+		}
+		seen[w] = true
+		out = append(out, w)
+		if len(out) == n {
+			break
+		}
+	}
+	return out
+}
+
+// appendHistoryDB adds e to the history database at path, then drops what
+// r says is no longer kept.
+func appendHistoryDB(path string, e historyEntry, r historyRetention) error {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+	db, err := openHistory(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		if err := putEntry(tx, e); err != nil {
+			return err
+		}
+		return prune(tx, r, e.Time)
+	})
+}
+
+// prune drops the oldest entries while there are more than r allows, or
+// they are older than it keeps them, as of now.
+func prune(tx *bolt.Tx, r historyRetention, now time.Time) error {
+	most := r.max
+	if most <= 0 {
+		most = defaultHistoryMax
+	}
+	meta := tx.Bucket(historyMeta)
+	count := historyCount(meta)
+	var seqs [][]byte
+	var doomed []historyEntry
+	c := tx.Bucket(historyEntries).Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var e historyEntry
+		if err := json.Unmarshal(v, &e); err != nil {
+			return err
+		}
+		if count-len(doomed) <= most && (r.keep == 0 || now.Sub(e.Time) <= r.keep) {
+			break
+		}
+		seqs, doomed = append(seqs, slices.Clone(k)), append(doomed, e)
+	}
+	for i, e := range doomed {
+		if err := deleteEntry(tx, seqs[i], e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// historyCount is how many entries the history holds, as its meta bucket
+// keeps count; counting the entries would mean reading them all.
+func historyCount(meta *bolt.Bucket) int {
+	if v := meta.Get(historyCountKey); len(v) == 8 {
+		return int(binary.BigEndian.Uint64(v))
+	}
+	return 0
+}
+
+// addToCount adds delta to the count of entries.
+func addToCount(tx *bolt.Tx, delta int) error {
+	meta := tx.Bucket(historyMeta)
+	return meta.Put(historyCountKey, historySeq(uint64(historyCount(meta)+delta)))
+}
+
+// readHistoryDB returns every entry of the history database at path,
+// oldest first.
+func readHistoryDB(path string) ([]historyEntry, error) {
+	var entries []historyEntry
+	err := viewHistory(path, func(tx *bolt.Tx) error {
+		return tx.Bucket(historyEntries).ForEach(func(_, v []byte) error {
+			var e historyEntry
+			if json.Unmarshal(v, &e) == nil {
+				entries = append(entries, e)
+			}
+			return nil
+		})
+	})
+	return entries, err
+}
+
+// viewHistory runs fn on the history database at path, if there is one.
+func viewHistory(path string, fn func(tx *bolt.Tx) error) error {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(strings.TrimSuffix(path, ".db") + ".jsonl"); err != nil {
+			return nil
+		}
+	}
+	historyLock.Lock()
+	defer historyLock.Unlock()
+	db, err := openHistory(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+// historyOf returns the entries of the history at path of the request
+// key, oldest first, by the index where there is one.
+func historyOf(path, key string) ([]historyEntry, error) {
+	if !isHistoryDB(path) {
+		entries, err := readHistory(path)
+		return slices.DeleteFunc(entries, func(e historyEntry) bool { return historyKey(e.Method, e.URL) != key }), err
+	}
+	var entries []historyEntry
+	err := viewHistory(path, func(tx *bolt.Tx) error {
+		all := tx.Bucket(historyEntries)
+		prefix := indexKey(key, nil)
+		c := tx.Bucket(historyByKey).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && len(k) == len(prefix)+8 && strings.HasPrefix(string(k), string(prefix)); k, _ = c.Next() {
+			var e historyEntry
+			if json.Unmarshal(all.Get(k[len(prefix):]), &e) == nil {
+				entries = append(entries, e)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// searchHistory returns the entries of the history at path with every
+// word of text, or words starting with them, in their method, URL, error
+// or body, and that keep, if not nil, keeps; newest first, up to limit of
+// them. Text with no words matches every entry.
+func searchHistory(path, text string, keep func(historyEntry) bool, limit int) ([]historyEntry, error) {
+	terms := words(text, maxEntryWords)
+	kept := func(e historyEntry) bool { return keep == nil || keep(e) }
+	if !isHistoryDB(path) {
+		entries, err := readHistory(path)
+		var found []historyEntry
+		for _, e := range slices.Backward(entries) {
+			if len(found) < limit && hasWords(entryWords(e), terms) && kept(e) {
+				found = append(found, e)
+			}
+		}
+		return found, err
+	}
+	var found []historyEntry
+	err := viewHistory(path, func(tx *bolt.Tx) error {
+		all := tx.Bucket(historyEntries)
+		add := func(v []byte) {
+			var e historyEntry
+			if json.Unmarshal(v, &e) == nil && kept(e) {
+				found = append(found, e)
+			}
+		}
+		if len(terms) == 0 {
+			c := all.Cursor()
+			for k, v := c.Last(); k != nil && len(found) < limit; k, v = c.Prev() {
+				add(v)
+			}
+			return nil
+		}
+		for _, seq := range matching(tx.Bucket(historyWords), terms) {
+			if len(found) == limit {
+				break
+			}
+			add(all.Get(seq))
+		}
+		return nil
+	})
+	return found, err
+}
+
+// matching returns the sequence numbers, newest first, of the entries the
+// word index has words starting with each of terms for.
+func matching(index *bolt.Bucket, terms []string) [][]byte {
+	var common map[string]bool
+	for _, term := range terms {
+		these := map[string]bool{}
+		c := index.Cursor()
+		for k, _ := c.Seek([]byte(term)); k != nil && strings.HasPrefix(string(k), term); k, _ = c.Next() {
+			if len(k) > 8 && k[len(k)-9] == 0 {
+				if seq := string(k[len(k)-8:]); common == nil || common[seq] {
+					these[seq] = true
+				}
+			}
+		}
+		common = these
+	}
+	seqs := make([][]byte, 0, len(common))
+	for seq := range common {
+		seqs = append(seqs, []byte(seq))
+	}
+	slices.SortFunc(seqs, func(a, b []byte) int { return strings.Compare(string(b), string(a)) })
+	return seqs
+}
+
+// hasWords reports whether every one of terms starts one of have.
+func hasWords(have, terms []string) bool {
+	for _, t := range terms {
+		if !slices.ContainsFunc(have, func(w string) bool { return strings.HasPrefix(w, t) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// searchableBody returns what of body is kept in the history for
+// searching: its start, if it is text.
+func searchableBody(body []byte) string {
+	cut := body[:min(len(body), maxHistoryBody)]
+	// Cutting may have split a character; drop what is left of it.
+	for i := 0; i < utf8.UTFMax && len(cut) < len(body) && !utf8.Valid(cut); i++ {
+		cut = cut[:len(cut)-1]
+	}
+	if !utf8.Valid(cut) {
+		return ""
+	}
+	return string(cut)
+}
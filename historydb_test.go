@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestHistoryDBRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", "history.db")
+	if entries, err := readHistory(path); err != nil || entries != nil {
+		t.Fatalf("missing history = %v, %v; want empty", entries, err)
+	}
+
+	health := config{method: "GET", url: "https://example.com/health", env: "prod", historyFile: path}
+	users := config{method: "POST", url: "https://example.com/users", historyFile: path}
+	if err := recordHistory(health, responseMsg{status: 200, body: []byte(`{"status":"healthy"}`)}, 120*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordHistory(users, errMsg{errors.New("connection refused")}, 3*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordHistory(health, responseMsg{status: 503, body: []byte("Service Unavailable")}, 40*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := readHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].Status != 200 || entries[1].Error != "connection refused" || entries[2].Status != 503 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	if entries[0].Body != `{"status":"healthy"}` || entries[0].Env != "prod" {
+		t.Errorf("first entry = %+v", entries[0])
+	}
+
+	runs, err := historyOf(path, "GET https://example.com/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].Status != 200 || runs[1].Status != 503 {
+		t.Errorf("historyOf = %+v", runs)
+	}
+}
+
+func TestSearchHistory(t *testing.T) {
+	for _, name := range []string{"history.db", "history.jsonl"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			for _, c := range []struct {
+				url  string
+				body string
+			}{
+				{"https://api.example.com/users/1", `{"name":"Ada"}`},
+				{"https://api.example.com/orders", `{"total":12}`},
+				{"https://api.example.com/users/2", `{"name":"Grace"}`},
+			} {
+				cfg := config{method: "GET", url: c.url, historyFile: path}
+				if err := recordHistory(cfg, responseMsg{status: 200, body: []byte(c.body)}, time.Millisecond); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			search := func(text string, limit int) []string {
+				t.Helper()
+				found, err := searchHistory(path, text, nil, limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var urls []string
+				for _, e := range found {
+					urls = append(urls, e.URL)
+				}
+				return urls
+			}
+			if got := search("user", 10); len(got) != 2 || got[0] != "https://api.example.com/users/2" {
+				t.Errorf("user = %v", got)
+			}
+			if got := search("API ORD", 10); len(got) != 1 || got[0] != "https://api.example.com/orders" {
+				t.Errorf("api ord = %v", got)
+			}
+			if got := search("orders ada", 10); len(got) != 0 {
+				t.Errorf("orders ada = %v", got)
+			}
+			if got := search("", 2); len(got) != 2 || got[0] != "https://api.example.com/users/2" {
+				t.Errorf("everything = %v", got)
+			}
+			// Only the database keeps the body.
+			if got := search("grace", 10); name == "history.db" && len(got) != 1 {
+				t.Errorf("grace = %v", got)
+			}
+		})
+	}
+}
+
+func TestHistoryDBRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	r := historyRetention{keep: 48 * time.Hour, max: 3}
+	names := []string{"alpha", "bravo", "charlie", "delta", "echo"}
+	for i, age := range []time.Duration{72 * time.Hour, 5 * time.Hour, 4 * time.Hour, 3 * time.Hour, 0} {
+		e := historyEntry{Time: now.Add(-age), Method: "GET", URL: "https://example.com/" + names[i], Status: 200}
+		if err := appendHistoryDB(path, e, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := readHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].URL != "https://example.com/charlie" || entries[2].URL != "https://example.com/echo" {
+		t.Fatalf("entries = %+v", entries)
+	}
+	// What was dropped is gone from the indexes too.
+	if found, _ := searchHistory(path, "alpha", nil, 10); len(found) != 0 {
+		t.Errorf("search found dropped entries: %+v", found)
+	}
+	if runs, _ := historyOf(path, "GET https://example.com/bravo"); len(runs) != 0 {
+		t.Errorf("historyOf found dropped entries: %+v", runs)
+	}
+}
+
+func TestHistoryDBImport(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "history.jsonl")
+	cfg := config{method: "GET", url: "https://example.com/legacy", historyFile: old}
+	for range 2 {
+		if err := recordHistory(cfg, responseMsg{status: 200}, time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg.historyFile = filepath.Join(dir, "history.db")
+	if err := recordHistory(cfg, responseMsg{status: 201}, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := readHistory(cfg.historyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[2].Status != 201 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	if _, err := os.Stat(old); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the old history is still at %s", old)
+	}
+	if _, err := os.Stat(old + ".imported"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSearchableBody(t *testing.T) {
+	if got := searchableBody([]byte{0xff, 0xfe, 0x00}); got != "" {
+		t.Errorf("binary body = %q", got)
+	}
+	// Two-byte characters after one byte cut one in half at the cap.
+	long := []byte("a" + strings.Repeat("é", maxHistoryBody))
+	if got := searchableBody(long); len(got) != maxHistoryBody-1 || !utf8.ValidString(got) {
+		t.Errorf("long body kept %d bytes", len(got))
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/publicsuffix"
+)
+
+// preloadMinAge is the smallest max-age, in seconds, the HSTS preload list
+// takes: one year.
+const preloadMinAge = 365 * 24 * 60 * 60
+
+// maxPolicyHops is how many redirects the HTTPS policy check follows.
+const maxPolicyHops = 10
+
+// policyHop is one answer on the way from plain HTTP to wherever it leads.
+type policyHop struct {
+	url      string
+	status   int
+	location string // Where it redirects to, "" if it doesn't.
+	hsts     string // Its Strict-Transport-Security header.
+}
+
+// httpsPolicy is what the check found out about how a host moves plain
+// HTTP visitors to HTTPS and keeps them there.
+type httpsPolicy struct {
+	host    string
+	hops    []policyHop // From the http:// URL on.
+	rootURL string      // https://host/.
+	root    *policyHop  // What rootURL answered, without following redirects.
+	tlsOK   error       // Why rootURL couldn't be fetched, nil if it could.
+}
+
+// hstsPolicy is the parsed value of a Strict-Transport-Security header.
+type hstsPolicy struct {
+	maxAge            int // -1 when there is none.
+	includeSubDomains bool
+	preload           bool
+}
+
+// parseHSTS reads a Strict-Transport-Security value.
+func parseHSTS(v string) hstsPolicy {
+	p := hstsPolicy{maxAge: -1}
+	if m := maxAgeRe.FindStringSubmatch(v); m != nil {
+		p.maxAge, _ = strconv.Atoi(m[1])
+	}
+	for _, d := range strings.Split(v, ";") {
+		switch strings.ToLower(strings.TrimSpace(d)) {
+		case "includesubdomains":
+			p.includeSubDomains = true
+		case "preload":
+			p.preload = true
+		}
+	}
+	return p
+}
+
+// runHTTPSPolicy is the palette's `hsts`.
+func runHTTPSPolicy(m model, _ []string) (tea.Model, tea.Cmd) {
+	host, _, err := hostPort(m.cfg.url)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	m.job = "Checking how " + host + " moves visitors to HTTPS"
+	plain, root, err := policyURLs(m.cfg.url)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	c := newClient(m.cfg)
+	return m, runJob(func(func(string)) tea.Msg {
+		p, err := checkHTTPSPolicy(c, plain, root)
+		if err != nil {
+			return reportMsg{title: "HTTPS policy " + host, body: err.Error()}
+		}
+		return reportMsg{title: "HTTPS policy " + host, body: p.describe()}
+	})
+}
+
+// policyURLs returns the plain-HTTP form of target, and the root of its
+// host over HTTPS: https://host/, which is where the preload list looks
+// for the host's HSTS policy.
+func policyURLs(target string) (plain, root string, err error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", "", err
+	}
+	p := *u
+	p.Scheme = "http"
+	if u.Scheme != "http" {
+		p.Host = u.Hostname() // Another scheme's port means nothing to HTTP.
+		if strings.Contains(p.Host, ":") {
+			p.Host = "[" + p.Host + "]"
+		}
+	}
+	return p.String(), (&url.URL{Scheme: "https", Host: p.Host, Path: "/"}).String(), nil
+}
+
+// checkHTTPSPolicy requests plain, a plain-HTTP URL, and follows where it
+// leads one redirect at a time, then asks root, the host's root over
+// HTTPS, for its HSTS policy without following any redirect.
+func checkHTTPSPolicy(c *http.Client, plain, root string) (*httpsPolicy, error) {
+	u, err := url.Parse(plain)
+	if err != nil {
+		return nil, err
+	}
+	p := &httpsPolicy{host: u.Hostname()}
+	noRedirects := *c
+	noRedirects.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	get := func(target string) (*policyHop, error) {
+		res, err := noRedirects.Get(target)
+		if err != nil {
+			return nil, err
+		}
+		res.Body.Close()
+		hop := &policyHop{url: target, status: res.StatusCode, hsts: res.Header.Get("Strict-Transport-Security")}
+		if loc, err := res.Location(); err == nil && res.StatusCode >= 300 && res.StatusCode <= 399 {
+			hop.location = loc.String()
+		}
+		return hop, nil
+	}
+
+	next := plain
+	for len(p.hops) < maxPolicyHops && next != "" {
+		hop, err := get(next)
+		if err != nil {
+			if len(p.hops) == 0 {
+				return nil, fmt.Errorf("plain HTTP: %w", err)
+			}
+			return nil, fmt.Errorf("following the redirect to %s: %w", next, err)
+		}
+		p.hops = append(p.hops, *hop)
+		next = hop.location
+	}
+
+	p.rootURL = root
+	p.root, p.tlsOK = get(root)
+	return p, nil
+}
+
+// redirectsToHTTPS reports whether plain HTTP ends up on HTTPS, and
+// whether its first redirect already goes to HTTPS on the same host, as
+// the preload list requires.
+func (p *httpsPolicy) redirectsToHTTPS() (ends, sameHostFirst bool) {
+	if len(p.hops) == 0 {
+		return false, false
+	}
+	if first, err := url.Parse(p.hops[0].location); err == nil {
+		sameHostFirst = first.Scheme == "https" && strings.EqualFold(first.Hostname(), p.host)
+	}
+	last, err := url.Parse(p.hops[len(p.hops)-1].url)
+	return err == nil && last.Scheme == "https" && p.hops[len(p.hops)-1].location == "", sameHostFirst
+}
+
+// preloadProblems lists what keeps the host off the HSTS preload list, by
+// the rules of hstspreload.org that can be checked from outside.
+func (p *httpsPolicy) preloadProblems() []string {
+	var problems []string
+	if net.ParseIP(p.host) != nil {
+		problems = append(problems, "only domain names are preloaded, not IP addresses")
+	} else if domain, err := publicsuffix.EffectiveTLDPlusOne(p.host); err == nil && !strings.EqualFold(domain, p.host) {
+		problems = append(problems, fmt.Sprintf("only registrable domains are preloaded; submit %s, not %s", domain, p.host))
+	}
+	if p.tlsOK != nil {
+		return append(problems, p.rootURL+" doesn't answer with a valid certificate: "+p.tlsOK.Error())
+	}
+	if _, sameHost := p.redirectsToHTTPS(); !sameHost {
+		problems = append(problems, "plain HTTP has to redirect to HTTPS on the same host first")
+	}
+	h := parseHSTS(p.root.hsts)
+	switch {
+	case p.root.hsts == "":
+		problems = append(problems, p.rootURL+" sends no Strict-Transport-Security header")
+	case h.maxAge < preloadMinAge:
+		problems = append(problems, fmt.Sprintf("max-age has to be at least %d (a year), not %d", preloadMinAge, max(h.maxAge, 0)))
+	}
+	if p.root.hsts != "" && !h.includeSubDomains {
+		problems = append(problems, "the header needs includeSubDomains")
+	}
+	if p.root.hsts != "" && !h.preload {
+		problems = append(problems, "the header needs the preload directive")
+	}
+	return problems
+}
+
+// describe writes the check up: the redirect chain, the HSTS policy and
+// whether the host could go on the preload list.
+func (p *httpsPolicy) describe() string {
+	var b strings.Builder
+	for _, hop := range p.hops {
+		fmt.Fprintf(&b, "%s → %d", hop.url, hop.status)
+		if hop.location != "" {
+			b.WriteString(" → " + hop.location)
+		}
+		if hop.hsts != "" && strings.HasPrefix(hop.url, "http:") {
+			b.WriteString(" (its HSTS header is ignored over plain HTTP)")
+		}
+		b.WriteString("\n")
+	}
+	if len(p.hops) == maxPolicyHops && p.hops[len(p.hops)-1].location != "" {
+		fmt.Fprintf(&b, "... stopped after %d redirects\n", maxPolicyHops)
+	}
+
+	ends, sameHost := p.redirectsToHTTPS()
+	switch {
+	case sameHost:
+		b.WriteString("\nRedirect: ✓ plain HTTP goes straight to HTTPS on the same host")
+	case ends:
+		b.WriteString("\nRedirect: ~ plain HTTP ends up on HTTPS, but not by way of the same host first")
+	default:
+		b.WriteString("\nRedirect: ✗ plain HTTP doesn't lead to HTTPS; visitors who type the bare name stay unencrypted")
+	}
+
+	switch {
+	case p.tlsOK != nil:
+		fmt.Fprintf(&b, "\nHSTS:     ✗ %s failed: %v", p.rootURL, p.tlsOK)
+	case p.root.hsts == "":
+		fmt.Fprintf(&b, "\nHSTS:     ✗ %s sends no Strict-Transport-Security header", p.rootURL)
+	default:
+		h := parseHSTS(p.root.hsts)
+		mark := "✓"
+		if h.maxAge < minHSTSAge {
+			mark = "~"
+		}
+		fmt.Fprintf(&b, "\nHSTS:     %s %s", mark, p.root.hsts)
+		if h.maxAge >= 0 {
+			fmt.Fprintf(&b, " (max-age of %d days)", h.maxAge/(24*60*60))
+		}
+	}
+
+	if problems := p.preloadProblems(); len(problems) > 0 {
+		b.WriteString("\nPreload:  ✗ not eligible")
+		for _, problem := range problems {
+			b.WriteString("\n  - " + problem)
+		}
+	} else {
+		b.WriteString("\nPreload:  ✓ eligible; submit it at https://hstspreload.org/")
+	}
+	return b.String()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseHSTS(t *testing.T) {
+	p := parseHSTS("max-age=31536000; includeSubDomains; Preload")
+	if p.maxAge != 31536000 || !p.includeSubDomains || !p.preload {
+		t.Errorf("parseHSTS = %+v", p)
+	}
+	if p := parseHSTS("includeSubDomains"); p.maxAge != -1 || p.preload {
+		t.Errorf("parseHSTS without max-age = %+v", p)
+	}
+}
+
+func TestPolicyURLs(t *testing.T) {
+	tests := []struct{ in, plain, root string }{
+		{"https://example.com:8443/a?b=1", "http://example.com/a?b=1", "https://example.com/"},
+		{"http://example.com:8080/a", "http://example.com:8080/a", "https://example.com:8080/"},
+		{"https://[::1]/", "http://[::1]/", "https://[::1]/"},
+	}
+	for _, tt := range tests {
+		plain, root, err := policyURLs(tt.in)
+		if err != nil || plain != tt.plain || root != tt.root {
+			t.Errorf("policyURLs(%q) = %q, %q, %v, want %q, %q", tt.in, plain, root, err, tt.plain, tt.root)
+		}
+	}
+}
+
+// policyServers starts an HTTPS server sending hsts, and a plain one that
+// redirects to it if redirect is set.
+func policyServers(t *testing.T, hsts string, redirect bool) (plain, tls *httptest.Server) {
+	t.Helper()
+	tls = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hsts != "" {
+			w.Header().Set("Strict-Transport-Security", hsts)
+		}
+	}))
+	plain = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", "max-age=1")
+		if redirect {
+			http.Redirect(w, r, tls.URL+r.URL.Path, http.StatusMovedPermanently)
+		}
+	}))
+	t.Cleanup(func() { plain.Close(); tls.Close() })
+	return plain, tls
+}
+
+func TestHTTPSPolicyEligible(t *testing.T) {
+	plain, tls := policyServers(t, "max-age=63072000; includeSubDomains; preload", true)
+	p, err := checkHTTPSPolicy(tls.Client(), plain.URL+"/x", tls.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.hops) != 2 || p.hops[0].status != http.StatusMovedPermanently || p.hops[1].url != tls.URL+"/x" {
+		t.Errorf("hops = %+v", p.hops)
+	}
+	out := p.describe()
+	for _, want := range []string{
+		"(its HSTS header is ignored over plain HTTP)",
+		"Redirect: ✓ plain HTTP goes straight to HTTPS on the same host",
+		"HSTS:     ✓ max-age=63072000; includeSubDomains; preload (max-age of 730 days)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("describe lacks %q:\n%s", want, out)
+		}
+	}
+	// Everything but the test server's address qualifies.
+	if !strings.HasSuffix(out, "Preload:  ✗ not eligible\n  - only domain names are preloaded, not IP addresses") {
+		t.Errorf("describe:\n%s", out)
+	}
+
+	p = &httpsPolicy{
+		host:    "example.com",
+		hops:    []policyHop{{url: "http://example.com/", status: 308, location: "https://example.com/"}, {url: "https://example.com/", status: 200}},
+		rootURL: "https://example.com/",
+		root:    &policyHop{url: "https://example.com/", status: 200, hsts: "max-age=31536000; includeSubDomains; preload"},
+	}
+	if out := p.describe(); !strings.Contains(out, "Preload:  ✓ eligible") {
+		t.Errorf("describe:\n%s", out)
+	}
+}
+
+func TestHTTPSPolicyProblems(t *testing.T) {
+	plain, tls := policyServers(t, "max-age=86400", false)
+	p, err := checkHTTPSPolicy(tls.Client(), plain.URL+"/", tls.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := p.describe()
+	for _, want := range []string{
+		"Redirect: ✗ plain HTTP doesn't lead to HTTPS",
+		"HSTS:     ~ max-age=86400 (max-age of 1 days)",
+		"Preload:  ✗ not eligible",
+		"redirect to HTTPS on the same host first",
+		"at least 31536000 (a year), not 86400",
+		"needs includeSubDomains",
+		"needs the preload directive",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("describe lacks %q:\n%s", want, out)
+		}
+	}
+
+	// Subdomains can't be preloaded on their own.
+	p.host = "www.example.com"
+	if problems := p.preloadProblems(); !strings.Contains(problems[0], "submit example.com, not www.example.com") {
+		t.Errorf("preloadProblems = %q", problems)
+	}
+}
+
+func TestHTTPSPolicyBadCertificate(t *testing.T) {
+	plain, tls := policyServers(t, "", true)
+	// The default client doesn't trust httptest's certificate.
+	p, err := checkHTTPSPolicy(&http.Client{}, plain.URL+"/", tls.URL+"/")
+	if err == nil {
+		t.Fatalf("following the redirect to an untrusted certificate succeeded: %+v", p)
+	}
+	if !strings.Contains(err.Error(), "following the redirect") {
+		t.Errorf("err = %v", err)
+	}
+}
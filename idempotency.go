@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultIdempotencySends is how many times each round of the idempotency
+// check sends the request unless told otherwise.
+const defaultIdempotencySends = 3
+
+// maxIdempotencySends caps the sends of each round.
+const maxIdempotencySends = 20
+
+// idempotentMethods are the methods RFC 9110 defines as idempotent.
+var idempotentMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete}
+
+// replayHeaders are the response headers servers mark a replayed answer
+// with, such as Stripe's Idempotent-Replayed.
+var replayHeaders = []string{"Idempotent-Replayed", "Idempotency-Replayed", "X-Idempotent-Replayed"}
+
+// idempotencyRound is what came of sending the request a few times over.
+type idempotencyRound struct {
+	key      string // The Idempotency-Key sent, "" for none.
+	answers  []snapshot
+	replayed int      // How many answers were marked as replays.
+	errs     []string // Why sends got no answer.
+}
+
+// runIdempotency is the palette's `idempotency [sends]`: it sends the
+// request that many times without an Idempotency-Key and as many again
+// with one, and compares the answers of each round, to show whether the
+// API takes a repeated request as one. Every send goes into the history,
+// since each may have changed something.
+func runIdempotency(m model, args []string) (tea.Model, tea.Cmd) {
+	n := defaultIdempotencySends
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v < 2 || v > maxIdempotencySends {
+			return m.paletteError(fmt.Errorf("%q is not a number of sends from 2 to %d", args[0], maxIdempotencySends))
+		}
+		n = v
+	}
+	if isFileTransfer(m.cfg.url) {
+		return m.paletteError(errors.New("only HTTP requests can be checked for idempotency"))
+	}
+	cfg := m.cfg
+	m.job = fmt.Sprintf("Sending the request %d times", 2*n)
+	return m, runJob(func(report func(string)) tea.Msg {
+		without := sendRound(cfg, "", n)
+		report("Sending with an Idempotency-Key")
+		with := sendRound(cfg, idempotencyKey(cfg), n)
+		return reportMsg{title: fmt.Sprintf("Idempotency of %s %s", cfg.method, cfg.url), body: describeIdempotency(cfg.method, without, with)}
+	})
+}
+
+// idempotencyKey is the Idempotency-Key given with -H, or a new random one.
+func idempotencyKey(cfg config) string {
+	if k := cfg.header.Get("Idempotency-Key"); k != "" {
+		return k
+	}
+	return randomHex(16)
+}
+
+// sendRound sends the request n times, with key as its Idempotency-Key,
+// or none if key is "".
+func sendRound(cfg config, key string, n int) idempotencyRound {
+	round := idempotencyRound{key: key}
+	cfg.header = cfg.header.Clone()
+	if cfg.header == nil {
+		cfg.header = http.Header{}
+	}
+	cfg.header.Del("Idempotency-Key")
+	if key != "" {
+		cfg.header.Set("Idempotency-Key", key)
+	}
+	for range n {
+		msg := checkServer(cfg)()
+		res, ok := msg.(responseMsg)
+		if !ok {
+			if e, ok := msg.(errMsg); ok {
+				round.errs = append(round.errs, e.err.Error())
+			}
+			continue
+		}
+		if res.stopStream != nil {
+			res.stopStream()
+		}
+		round.answers = append(round.answers, takeSnapshot(res.status, response(res).jsonBody(), nil))
+		if slices.ContainsFunc(replayHeaders, func(h string) bool { return strings.EqualFold(res.header.Get(h), "true") }) {
+			round.replayed++
+		}
+	}
+	return round
+}
+
+// differences lists how the later answers of a round differ from its
+// first, or is empty when they are all alike.
+func (r idempotencyRound) differences() []string {
+	var changes []string
+	for i := 1; i < len(r.answers); i++ {
+		for _, c := range diffSnapshots(r.answers[0], r.answers[i]) {
+			changes = append(changes, fmt.Sprintf("send %d: %s", i+1, c))
+		}
+	}
+	return changes
+}
+
+// alike reports whether every send of the round was answered, the same way.
+func (r idempotencyRound) alike() bool {
+	return len(r.errs) == 0 && len(r.differences()) == 0
+}
+
+// describe writes up a round: its statuses, and how its answers differ.
+func (r idempotencyRound) describe(title string) string {
+	var b strings.Builder
+	b.WriteString(title + "\n")
+	statuses := make([]string, len(r.answers))
+	for i, a := range r.answers {
+		statuses[i] = strconv.Itoa(a.Status)
+	}
+	fmt.Fprintf(&b, "  Statuses: %s\n", strings.Join(statuses, ", "))
+	if r.replayed > 0 {
+		fmt.Fprintf(&b, "  %d answers were marked as replays\n", r.replayed)
+	}
+	for _, e := range r.errs {
+		b.WriteString("  No answer: " + e + "\n")
+	}
+	switch diffs := r.differences(); {
+	case len(r.answers) < 2:
+	case len(diffs) == 0:
+		b.WriteString("  Every answer was the same\n")
+	default:
+		if len(diffs) > maxSnapshotChanges {
+			diffs = append(diffs[:maxSnapshotChanges], fmt.Sprintf("and %d more", len(diffs)-maxSnapshotChanges))
+		}
+		b.WriteString("  The answers differ:\n    " + strings.Join(diffs, "\n    ") + "\n")
+	}
+	return b.String()
+}
+
+// describeIdempotency writes up both rounds, and what they say of the API.
+func describeIdempotency(method string, without, with idempotencyRound) string {
+	var b strings.Builder
+	b.WriteString(without.describe(fmt.Sprintf("Without an Idempotency-Key, %d sends:", len(without.answers)+len(without.errs))))
+	b.WriteString("\n")
+	b.WriteString(with.describe(fmt.Sprintf("With Idempotency-Key %s, %d sends:", with.key, len(with.answers)+len(with.errs))))
+	b.WriteString("\n")
+
+	switch {
+	case without.alike() && with.alike():
+		b.WriteString("✓ The request is idempotent: repeating it changes nothing, with or without a key.")
+	case with.alike():
+		b.WriteString("✓ The server honours the Idempotency-Key: repeats with the key were answered as the first was, while repeats without one were not.")
+	case without.alike():
+		b.WriteString("✗ Repeats with a key were answered differently from repeats without: the server handles the key, but not as a replay of the first answer.")
+	default:
+		b.WriteString("✗ The request is not idempotent, and the Idempotency-Key doesn't make it so: each repeat was answered anew.")
+	}
+	if slices.Contains(idempotentMethods, method) && !without.alike() {
+		fmt.Fprintf(&b, "\n%s is meant to be idempotent (RFC 9110), yet repeating it changed the answer.", method)
+	}
+	return b.String()
+}
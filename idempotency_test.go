@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// paymentServer creates a payment per POST, except that a repeated
+// Idempotency-Key gets the first answer again, if honour is set.
+func paymentServer(honour bool) *httptest.Server {
+	var mu sync.Mutex
+	next := 0
+	seen := map[string]string{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		key := r.Header.Get("Idempotency-Key")
+		if body, ok := seen[key]; ok && honour && key != "" {
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(body))
+			return
+		}
+		next++
+		body := fmt.Sprintf(`{"id": %d, "amount": 100}`, next)
+		seen[key] = body
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestIdempotency(t *testing.T) {
+	srv := paymentServer(true)
+	defer srv.Close()
+	cfg := config{method: "POST", url: srv.URL, body: []byte(`{"amount": 100}`), bodyFormat: "json"}
+	without, with := sendRound(cfg, "", 3), sendRound(cfg, "k1", 3)
+	if without.alike() || !with.alike() || with.replayed != 2 {
+		t.Errorf("without = %+v, with = %+v", without, with)
+	}
+	out := describeIdempotency("POST", without, with)
+	for _, want := range []string{
+		"Without an Idempotency-Key, 3 sends:\n  Statuses: 201, 201, 201\n  The answers differ:\n    send 2: $.id: 1 → 2\n    send 3: $.id: 1 → 3",
+		"With Idempotency-Key k1, 3 sends:\n  Statuses: 201, 201, 201\n  2 answers were marked as replays\n  Every answer was the same",
+		"✓ The server honours the Idempotency-Key",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q:\n%s", want, out)
+		}
+	}
+
+	srv = paymentServer(false)
+	defer srv.Close()
+	cfg.url = srv.URL
+	cfg.method = "PUT"
+	out = describeIdempotency("PUT", sendRound(cfg, "", 2), sendRound(cfg, "k2", 2))
+	if !strings.Contains(out, "✗ The request is not idempotent") || !strings.Contains(out, "PUT is meant to be idempotent") {
+		t.Errorf("report:\n%s", out)
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// treeHeight is how many rows of the JSON tree are visible at once.
+const treeHeight = 20
+
+// arrayPage is how many elements of an array are shown when it is opened,
+// and how many more each "show more" adds, so huge arrays stay cheap to draw.
+const arrayPage = 100
+
+// jsonNode is one value in a parsed JSON document. Objects keep their keys
+// in document order, which encoding/json's maps would lose.
+type jsonNode struct {
+	key      string      // Object key or array index within the parent; empty for the root.
+	path     string      // Where the node sits in the document, e.g. $.items[3].name.
+	array    bool        // The node is an array.
+	object   bool        // The node is an object.
+	value    string      // A scalar's JSON text, e.g. "abc", 42 or null.
+	children []*jsonNode // An array's elements or an object's members.
+}
+
+// identifier matches object keys that can be written as .key in a path.
+var identifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// childPath is the path of the member key of an object at parent.
+func childPath(parent, key string) string {
+	if identifier.MatchString(key) {
+		return parent + "." + key
+	}
+	return parent + "[" + strconv.Quote(key) + "]"
+}
+
+// parseJSONTree reads body into a tree of nodes.
+func parseJSONTree(body []byte) (*jsonNode, error) {
+	dec := json.NewDecoder(strings.NewReader(string(body)))
+	dec.UseNumber()
+	root, err := readNode(dec, "", "$")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return root, nil
+}
+
+// readNode reads the next value from dec, and everything inside it.
+func readNode(dec *json.Decoder, key, path string) (*jsonNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	n := &jsonNode{key: key, path: path}
+
+	switch tok {
+	case json.Delim('['):
+		n.array = true
+		for i := 0; dec.More(); i++ {
+			index := strconv.Itoa(i)
+			child, err := readNode(dec, index, path+"["+index+"]")
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, child)
+		}
+	case json.Delim('{'):
+		n.object = true
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			name := tok.(string) // Object keys are always strings.
+			child, err := readNode(dec, name, childPath(path, name))
+			if err != nil {
+				return nil, err
+			}
+			n.children = append(n.children, child)
+		}
+	default:
+		// Scalars are shown as they would be written in JSON, but without
+		// the \u003c escapes encoding/json uses for HTML characters.
+		var b strings.Builder
+		enc := json.NewEncoder(&b)
+		enc.SetEscapeHTML(false)
+		enc.Encode(tok)
+		n.value = strings.TrimSuffix(b.String(), "\n")
+		return n, nil
+	}
+
+	// Consume the closing ] or }.
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// size says how much a container holds, e.g. "3 items" or "1 key".
+func (n *jsonNode) size() string {
+	unit := "key"
+	if n.array {
+		unit = "item"
+	}
+	if len(n.children) != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", len(n.children), unit)
+}
+
+// treeRow is a node as it appears on screen in the flattened tree.
+type treeRow struct {
+	node   *jsonNode
+	depth  int
+	parent int // Row of the enclosing node, -1 for the root.
+	more   int // For the "show more" row of an array: how many elements are hidden.
+}
+
+// jsonTree is the collapsible JSON view of a response body.
+type jsonTree struct {
+	root     *jsonNode
+	expanded map[string]bool // Paths of the containers that are open.
+	shown    map[string]int  // How many elements of each array are shown, if not arrayPage.
+	cursor   int             // Selected row.
+	offset   int             // First visible row.
+	status   string          // Outcome of the last copy, if any.
+}
+
+// newJSONTree opens the tree on body with just the top level expanded.
+func newJSONTree(body []byte) (*jsonTree, error) {
+	root, err := parseJSONTree(body)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonTree{root: root, expanded: map[string]bool{root.path: true}}, nil
+}
+
+// rows flattens the expanded part of the tree into display order.
+func (t *jsonTree) rows() []treeRow {
+	var rows []treeRow
+	var walk func(n *jsonNode, depth, parent int)
+	walk = func(n *jsonNode, depth, parent int) {
+		rows = append(rows, treeRow{node: n, depth: depth, parent: parent})
+		if !t.expanded[n.path] {
+			return
+		}
+		self := len(rows) - 1
+		children := n.children
+		if n.array {
+			children = children[:min(len(children), t.limit(n))]
+		}
+		for _, c := range children {
+			walk(c, depth+1, self)
+		}
+		if hidden := len(n.children) - len(children); hidden > 0 {
+			rows = append(rows, treeRow{node: n, depth: depth + 1, parent: self, more: hidden})
+		}
+	}
+	walk(t.root, 0, -1)
+	return rows
+}
+
+// limit is how many elements of array n are shown.
+func (t *jsonTree) limit(n *jsonNode) int {
+	if shown, ok := t.shown[n.path]; ok {
+		return shown
+	}
+	return arrayPage
+}
+
+// showMore reveals the next page of elements of array n.
+func (t *jsonTree) showMore(n *jsonNode) {
+	// Copy the counts so that earlier models keep their own view.
+	shown := maps.Clone(t.shown)
+	if shown == nil {
+		shown = map[string]int{}
+	}
+	shown[n.path] = t.limit(n) + arrayPage
+	t.shown = shown
+}
+
+// setExpanded opens or closes the container at path.
+func (t *jsonTree) setExpanded(path string, open bool) {
+	// Copy the set so that earlier models keep their own view.
+	t.expanded = maps.Clone(t.expanded)
+	if open {
+		t.expanded[path] = true
+	} else {
+		delete(t.expanded, path)
+	}
+}
+
+// updateTree handles keys while the JSON tree is open.
+func (m model) updateTree(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// Shallow-copy the tree so the previous model keeps its own state.
+	copied := *m.tree
+	t := &copied
+	m.tree = t
+	t.status = ""
+
+	rows := t.rows()
+	row := rows[t.cursor]
+	n := row.node
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "t", "q":
+		m.tree = nil
+		return m, nil
+	case "up", "k":
+		t.cursor = max(t.cursor-1, 0)
+	case "down", "j":
+		t.cursor = min(t.cursor+1, len(rows)-1)
+	case "g", "home":
+		t.cursor = 0
+	case "G", "end":
+		t.cursor = len(rows) - 1
+
+	// Open and close containers. On the "show more" row of a long array,
+	// opening reveals the next page of elements instead.
+	case "enter", " ":
+		switch {
+		case row.more > 0:
+			t.showMore(n)
+		case len(n.children) > 0:
+			t.setExpanded(n.path, !t.expanded[n.path])
+		}
+	case "right", "l":
+		switch {
+		case row.more > 0:
+			t.showMore(n)
+		case len(n.children) > 0:
+			t.setExpanded(n.path, true)
+		}
+	case "left", "h":
+		// Close the node, or if it is already closed, go up to its parent.
+		if t.expanded[n.path] && row.more == 0 {
+			t.setExpanded(n.path, false)
+		} else if row.parent >= 0 {
+			t.cursor = row.parent
+		}
+
+	// Copy the path of the selected node, for use in jq or code.
+	case "y":
+		if err := clipboard.WriteAll(n.path); err != nil {
+			t.status = fmt.Sprintf("Couldn't copy %s: %v", n.path, err)
+		} else {
+			t.status = "Copied " + n.path
+		}
+	}
+
+	// Scroll so the cursor stays in view.
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+treeHeight {
+		t.offset = t.cursor - treeHeight + 1
+	}
+	return m, nil
+}
+
+// viewTree renders the visible window of the JSON tree.
+func (m model) viewTree() string {
+	t := m.tree
+	rows := t.rows()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nJSON body of %s\n\n", m.cfg.url)
+	for i := t.offset; i < min(t.offset+treeHeight, len(rows)); i++ {
+		r := rows[i]
+		n := r.node
+
+		mark := "  "
+		if i == t.cursor {
+			mark = "> "
+		}
+		indent := strings.Repeat("  ", r.depth)
+		if r.more > 0 {
+			fmt.Fprintf(&b, "%s%s… %d more items (enter shows %d more)\n", mark, indent, r.more, min(r.more, arrayPage))
+			continue
+		}
+		label := ""
+		if r.parent >= 0 {
+			if rows[r.parent].node.array {
+				label = "[" + n.key + "] "
+			} else {
+				label = strconv.Quote(n.key) + ": "
+			}
+		}
+		var value string
+		switch {
+		case !n.array && !n.object:
+			value = n.value
+			if len([]rune(value)) > 80 {
+				value = string([]rune(value)[:79]) + "…"
+			}
+		case len(n.children) == 0 && n.array:
+			value = "[]"
+		case len(n.children) == 0:
+			value = "{}"
+		case t.expanded[n.path]:
+			value = "▾ " + n.size()
+		case n.array:
+			value = "▸ […] " + n.size()
+		default:
+			value = "▸ {…} " + n.size()
+		}
+		b.WriteString(mark + indent + label + value + "\n")
+	}
+
+	fmt.Fprintf(&b, "\n%s  (%d of %d rows)\n", rows[t.cursor].node.path, t.cursor+1, len(rows))
+	if t.status != "" {
+		b.WriteString(t.status + "\n")
+	}
+	b.WriteString("\n↑/↓ select • enter toggle • →/← expand/collapse • g/G top/bottom • y copy path • esc close\n")
+	return b.String()
+}
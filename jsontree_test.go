@@ -0,0 +1,146 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// runKey presses k on m and feeds the background job it starts back into
+// the model until the job is over.
+func runKey(t *testing.T, m model, k string) model {
+	t.Helper()
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+	m = next.(model)
+	if cmd == nil {
+		t.Fatalf("%s started no job", k)
+	}
+	for cmd != nil {
+		msg := cmd()
+		next, cmd = m.Update(msg)
+		m = next.(model)
+		if _, ok := msg.(progressMsg); !ok {
+			break
+		}
+	}
+	return m
+}
+
+func TestJSONTree(t *testing.T) {
+	m := runKey(t, model{res: response{status: 200, kind: kindJSON, body: []byte(`{"a":[1,2],"b":"x"}`)}}, "t")
+	if m.tree == nil || m.job != "" {
+		t.Fatalf("tree = %v, job = %q", m.tree, m.job)
+	}
+	if !m.tree.expanded[m.tree.root.path] {
+		t.Error("the root is not expanded")
+	}
+}
+
+func TestTreeFailureKeepsResponse(t *testing.T) {
+	m := runKey(t, model{res: response{status: 200, kind: kindJSON, body: []byte(`{"a":`)}}, "t")
+	if m.err != nil || m.tree != nil {
+		t.Fatalf("err = %v, tree = %v, want neither", m.err, m.tree)
+	}
+	if m.report == nil || !strings.Contains(m.report.body, "Couldn't build the tree") {
+		t.Errorf("report = %+v", m.report)
+	}
+	if m.res.status != 200 || m.job != "" {
+		t.Errorf("status = %d, job = %q", m.res.status, m.job)
+	}
+}
+
+func TestParseJSONTree(t *testing.T) {
+	root, err := parseJSONTree([]byte(`{"z":1,"a":{"my key":[true,null,"<b>"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	var walk func(n *jsonNode)
+	walk = func(n *jsonNode) {
+		paths = append(paths, n.path+"="+n.value)
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(root)
+	want := `$= $.z=1 $.a= $.a["my key"]= $.a["my key"][0]=true $.a["my key"][1]=null $.a["my key"][2]="<b>"`
+	if got := strings.Join(paths, " "); got != want {
+		t.Errorf("paths = %s\nwant    %s", got, want)
+	}
+	if root.size() != "2 keys" || root.children[1].children[0].size() != "3 items" {
+		t.Errorf("sizes = %s, %s", root.size(), root.children[1].children[0].size())
+	}
+
+	for _, bad := range []string{`{"a":`, `[1] [2]`, ``} {
+		if _, err := parseJSONTree([]byte(bad)); err == nil {
+			t.Errorf("parseJSONTree(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestTreeExpand(t *testing.T) {
+	tree, err := newJSONTree([]byte(`{"a":{"b":1},"c":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := model{tree: tree}
+	key := func(k string) {
+		next, _ := m.updateTree(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		m = next.(model)
+	}
+	if n := len(m.tree.rows()); n != 3 {
+		t.Fatalf("%d rows with the root open, want 3", n)
+	}
+	key("j")
+	key("l")
+	if n := len(m.tree.rows()); n != 4 {
+		t.Errorf("%d rows with $.a open, want 4", n)
+	}
+	if len(tree.rows()) != 3 {
+		t.Error("opening $.a changed the earlier tree")
+	}
+	key("j")
+	key("h") // A scalar can't close, so this goes up to $.a.
+	if m.tree.cursor != 1 {
+		t.Errorf("cursor = %d, want 1", m.tree.cursor)
+	}
+	key("h")
+	if n := len(m.tree.rows()); n != 3 {
+		t.Errorf("%d rows with $.a closed, want 3", n)
+	}
+}
+
+func TestTreeArrayPages(t *testing.T) {
+	tree, err := newJSONTree([]byte("[" + strings.Repeat("0,", 2*arrayPage+9) + "0]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := tree.rows()
+	if len(rows) != arrayPage+2 {
+		t.Fatalf("%d rows, want the root, %d elements and a more row", len(rows), arrayPage)
+	}
+	last := rows[len(rows)-1]
+	if last.more != arrayPage+10 || last.parent != 0 {
+		t.Errorf("more row = %+v, want %d hidden under the root", last, arrayPage+10)
+	}
+
+	m := model{tree: tree}
+	m.tree.cursor = len(rows) - 1
+	next, _ := m.updateTree(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if n := len(m.tree.rows()); n != 2*arrayPage+2 {
+		t.Errorf("%d rows after one more page, want %d", n, 2*arrayPage+2)
+	}
+	if len(tree.rows()) != arrayPage+2 {
+		t.Error("showing more changed the earlier tree")
+	}
+
+	m.tree.cursor = len(m.tree.rows()) - 1
+	next, _ = m.updateTree(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("l")})
+	m = next.(model)
+	rows = m.tree.rows()
+	if len(rows) != 2*arrayPage+11 || rows[len(rows)-1].more != 0 {
+		t.Errorf("%d rows after the last page, want every element and no more row", len(rows))
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// A capture of HTTPS traffic is no use to Wireshark without the keys of
+// its TLS sessions. With -key-log, or SSLKEYLOGFILE set as for curl and
+// the browsers, the keys of every connection are appended to a file in
+// the NSS key log format, which Wireshark decrypts a capture with. The
+// palette's `keylog` turns it on or off as it goes, and tells how to
+// capture the request's traffic to go with it; capturing takes tcpdump
+// and the privileges it needs.
+//
+// Anyone with the file can read what the sessions carried, so it is only
+// readable by its owner.
+
+// keyLogFile is a key log, opened on the first key written to it.
+type keyLogFile struct {
+	mu       sync.Mutex
+	path     string
+	f        *os.File
+	sessions int // TLS sessions whose keys were written.
+	err      error
+}
+
+// keyLogs holds the key logs written to, by path, so two transports
+// logging to the same file share it.
+var keyLogs = struct {
+	sync.Mutex
+	byPath map[string]*keyLogFile
+}{byPath: map[string]*keyLogFile{}}
+
+// keyLogWriter returns the key log at path.
+func keyLogWriter(path string) *keyLogFile {
+	keyLogs.Lock()
+	defer keyLogs.Unlock()
+	k, ok := keyLogs.byPath[path]
+	if !ok {
+		k = &keyLogFile{path: path}
+		keyLogs.byPath[path] = k
+	}
+	return k
+}
+
+// Write appends a line of keys, as crypto/tls gives it. A log that can't
+// be written is warned about, once, rather than failing the request.
+func (k *keyLogFile) Write(line []byte) (int, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.f == nil && k.err == nil {
+		if dir := filepath.Dir(k.path); dir != "" {
+			os.MkdirAll(dir, 0o700)
+		}
+		if k.f, k.err = os.OpenFile(k.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600); k.err != nil {
+			logf(logWarn, "Couldn't open the TLS key log: %v", k.err)
+		}
+	}
+	if k.err != nil {
+		return len(line), nil
+	}
+	// TLS 1.2 logs a session as one CLIENT_RANDOM, and 1.3 as several
+	// secrets, of which one CLIENT_TRAFFIC_SECRET_0.
+	if bytes.HasPrefix(line, []byte("CLIENT_RANDOM ")) || bytes.HasPrefix(line, []byte("CLIENT_TRAFFIC_SECRET_0 ")) {
+		k.sessions++
+	}
+	return k.f.Write(line)
+}
+
+// logged is how many sessions' keys were written so far.
+func (k *keyLogFile) logged() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.sessions
+}
+
+// defaultKeyLog is where the palette's keylog writes unless told where: in
+// the user's own cache directory, not the temporary one everyone shares,
+// where someone else could have made the file first. It is "" when there
+// is no cache directory.
+func defaultKeyLog() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "httpwizard", "tls-keys.log")
+}
+
+// runKeyLog is the palette's `keylog [file | off]`: it logs the TLS keys of
+// the connections opened from now on to file, or one in the cache, or stops,
+// and says how to capture traffic for them.
+func runKeyLog(m model, args []string) (tea.Model, tea.Cmd) {
+	switch {
+	case len(args) > 0 && args[0] == "off":
+		m.cfg.transport.keyLog = ""
+		m.report, m.cursor = &reportMsg{title: "TLS key log", body: "The keys of new connections are no longer logged."}, 0
+		return m, nil
+	case len(args) > 0:
+		path, err := filepath.Abs(args[0])
+		if err != nil {
+			return m.paletteError(err)
+		}
+		m.cfg.transport.keyLog = path
+	case m.cfg.transport.keyLog == "":
+		if m.cfg.transport.keyLog = defaultKeyLog(); m.cfg.transport.keyLog == "" {
+			return m.paletteError(errors.New("there is no cache directory to keep the keys in; name a file, keylog FILE"))
+		}
+	}
+	m.report, m.cursor = &reportMsg{title: "TLS key log", body: keyLogGuide(m.cfg)}, 0
+	return m, nil
+}
+
+// keyLogGuide says where cfg's keys are logged, and how to capture and
+// decrypt the traffic of its request with them.
+func keyLogGuide(cfg config) string {
+	path := cfg.transport.keyLog
+	var b strings.Builder
+	fmt.Fprintf(&b, "The TLS keys of the connections opened from now on go to %s", path)
+	fmt.Fprintf(&b, "; %d sessions so far.\n", keyLogWriter(path).logged())
+	u, err := url.Parse(cfg.url)
+	if err != nil || u.Hostname() == "" {
+		return b.String()
+	}
+	if u.Scheme != "https" {
+		fmt.Fprintf(&b, "\nThe request is to %s, over %s, so a capture of it needs no keys.\n", u.Host, u.Scheme)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	if jump := cfg.envs[cfg.env].SSH; jump != "" {
+		fmt.Fprintf(&b, "\nRequests go through the jump host %s; capture there, for the host can't be seen from here.\n", jump)
+	}
+	fmt.Fprintf(&b, "\nCapture the traffic while sending the request, with privileges to:\n\n")
+	fmt.Fprintf(&b, "  sudo tcpdump -i any -w request.pcap host %s and tcp port %s\n", u.Hostname(), port)
+	fmt.Fprintf(&b, "\nand open the capture with the keys:\n\n")
+	fmt.Fprintf(&b, "  wireshark -o tls.keylog_file:%s request.pcap\n", shellQuote(path))
+	fmt.Fprintf(&b, "\nor in the terminal:\n\n")
+	fmt.Fprintf(&b, "  tshark -o tls.keylog_file:%s -r request.pcap -Y http\n", shellQuote(path))
+	b.WriteString("\nConnections already open stay as they were; d pool diagnostics shows which log is in use.\n")
+	return b.String()
+}
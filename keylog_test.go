@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestKeyLog(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "keys", "tls.log")
+	tr := transportFor(transportOptions{keyLog: path})
+	// The test server's certificate is its own.
+	tr.TLSClientConfig.InsecureSkipVerify = true
+	for range 2 {
+		res, err := (&http.Client{Transport: tr}).Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "CLIENT_TRAFFIC_SECRET_0 ") && !strings.Contains(string(b), "CLIENT_RANDOM ") {
+		t.Errorf("no session keys in the log:\n%s", b)
+	}
+	// The second request went on the first's connection.
+	if n := keyLogWriter(path).logged(); n != 1 {
+		t.Errorf("logged %d sessions, want 1", n)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("the key log's mode is %v, %v", fi.Mode(), err)
+	}
+}
+
+func TestRunKeyLog(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	t.Setenv("HOME", cache)
+	if want := filepath.Join(cache, "httpwizard", "tls-keys.log"); defaultKeyLog() != want && runtime.GOOS == "linux" {
+		t.Errorf("defaultKeyLog = %q, want %q", defaultKeyLog(), want)
+	}
+	m := model{cfg: config{method: "GET", url: "https://api.example.com/users", header: http.Header{}}}
+	next, _ := runKeyLog(m, nil)
+	got := next.(model)
+	if got.cfg.transport.keyLog != defaultKeyLog() {
+		t.Errorf("keyLog = %q", got.cfg.transport.keyLog)
+	}
+	for _, want := range []string{
+		"sudo tcpdump -i any -w request.pcap host api.example.com and tcp port 443\n",
+		"wireshark -o tls.keylog_file:" + shellQuote(defaultKeyLog()) + " request.pcap\n",
+	} {
+		if !strings.Contains(got.report.body, want) {
+			t.Errorf("report lacks %q:\n%s", want, got.report.body)
+		}
+	}
+	next, _ = runKeyLog(got, []string{"off"})
+	if next.(model).cfg.transport.keyLog != "" {
+		t.Error("keylog off left it on")
+	}
+}
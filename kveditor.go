@@ -0,0 +1,278 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// kvKind says what a key/value editor is editing.
+type kvKind int
+
+const (
+	kvHeaders kvKind = iota // Request headers, written "Key: value".
+	kvParams                // Query parameters, written "key=value".
+)
+
+// kvEditor edits a list of key/value pairs either as a table, one row at a
+// time, or as a raw block of text that can be pasted in one go.
+type kvEditor struct {
+	kind    kvKind
+	pairs   [][2]string     // The pairs being edited, in order.
+	cursor  int             // Selected table row.
+	editing bool            // The selected row is being edited in input.
+	input   textinput.Model // Line editor for a single row.
+	raw     bool            // Showing the raw text block instead of the table.
+	area    textarea.Model  // The raw text block.
+	err     error           // Why the raw text could not be parsed.
+}
+
+// newKVEditor opens an editor of the given kind on pairs.
+func newKVEditor(kind kvKind, pairs [][2]string) *kvEditor {
+	in := textinput.New()
+	in.Cursor.SetMode(cursor.CursorStatic)
+
+	area := textarea.New()
+	area.CharLimit = 0
+	area.ShowLineNumbers = false
+	area.SetWidth(100)
+	area.SetHeight(12)
+	area.Cursor.SetMode(cursor.CursorStatic)
+
+	return &kvEditor{kind: kind, pairs: pairs, input: in, area: area}
+}
+
+// title names what is being edited.
+func (e *kvEditor) title() string {
+	if e.kind == kvHeaders {
+		return "Request headers"
+	}
+	return "Query parameters"
+}
+
+// format writes one pair the way it appears in the raw text block.
+func (e *kvEditor) format(kv [2]string) string {
+	if e.kind == kvHeaders {
+		return kv[0] + ": " + kv[1]
+	}
+	return kv[0] + "=" + kv[1]
+}
+
+// parseLine reads one line of raw text. Headers must be "Key: value";
+// parameters may be "key=value" or "key: value", as DevTools shows them.
+func (e *kvEditor) parseLine(line string) ([2]string, error) {
+	sep := ":"
+	if e.kind == kvParams && strings.Contains(line, "=") {
+		sep = "="
+	}
+	key, value, ok := strings.Cut(line, sep)
+	if !ok || strings.TrimSpace(key) == "" {
+		return [2]string{}, fmt.Errorf("%q is not in %q form", line, e.format([2]string{"key", "value"}))
+	}
+	return [2]string{strings.TrimSpace(key), strings.TrimSpace(value)}, nil
+}
+
+// parseRaw turns the raw text block back into pairs. Blank lines are
+// skipped, and so are HTTP/2 pseudo-headers like ":authority" that DevTools
+// includes when copying request headers.
+func (e *kvEditor) parseRaw(text string) ([][2]string, error) {
+	var pairs [][2]string
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || (e.kind == kvHeaders && strings.HasPrefix(line, ":")) {
+			continue
+		}
+		kv, err := e.parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		pairs = append(pairs, kv)
+	}
+	return pairs, nil
+}
+
+// headerPairs lists h as pairs, sorted by key so the table is stable.
+func headerPairs(h http.Header) [][2]string {
+	var pairs [][2]string
+	for key, values := range h {
+		for _, v := range values {
+			pairs = append(pairs, [2]string{key, v})
+		}
+	}
+	sortPairs(pairs)
+	return pairs
+}
+
+// sortPairs orders pairs by key, then value.
+func sortPairs(pairs [][2]string) {
+	slices.SortFunc(pairs, func(a, b [2]string) int {
+		return cmp.Or(cmp.Compare(a[0], b[0]), cmp.Compare(a[1], b[1]))
+	})
+}
+
+// apply stores the edited pairs in cfg: as its headers, or as the query of its URL.
+func (e *kvEditor) apply(cfg config) (config, error) {
+	if e.kind == kvHeaders {
+		cfg.header = http.Header{}
+		for _, kv := range e.pairs {
+			cfg.header.Add(kv[0], kv[1])
+		}
+		return cfg, nil
+	}
+	parts, err := splitURL(cfg.url)
+	if err != nil {
+		return cfg, err
+	}
+	parts.params = e.pairs
+	cfg.url = parts.String()
+	cfg.path = cfg.url
+	return cfg, nil
+}
+
+// updateKVEditor handles keys while a key/value editor is open.
+func (m model) updateKVEditor(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// Shallow-copy the editor so the previous model keeps its own state.
+	copied := *m.kvEditor
+	e := &copied
+	m.kvEditor = e
+
+	switch {
+	case msg.String() == "ctrl+c":
+		return m, tea.Quit
+
+	// Raw mode: everything but esc and ctrl+s is typing.
+	case e.raw:
+		switch msg.String() {
+		case "esc", "ctrl+s":
+			pairs, err := e.parseRaw(e.area.Value())
+			if err != nil {
+				e.err = err
+				return m, nil
+			}
+			e.pairs, e.raw, e.err = pairs, false, nil
+			if msg.String() == "ctrl+s" {
+				return m.applyKVEditor()
+			}
+			return m, nil
+		}
+		var cmd tea.Cmd
+		e.area, cmd = e.area.Update(msg)
+		return m, cmd
+
+	// Editing a single row of the table.
+	case e.editing:
+		switch msg.String() {
+		case "enter":
+			kv, err := e.parseLine(e.input.Value())
+			if err != nil {
+				e.err = err
+				return m, nil
+			}
+			e.pairs = append([][2]string(nil), e.pairs...)
+			e.pairs[e.cursor] = kv
+			e.editing, e.err = false, nil
+		case "esc":
+			e.editing, e.err = false, nil
+		default:
+			var cmd tea.Cmd
+			e.input, cmd = e.input.Update(msg)
+			return m, cmd
+		}
+		return m, nil
+	}
+
+	// The table itself.
+	switch msg.String() {
+	case "esc":
+		m.kvEditor = nil
+	case "up", "k":
+		e.cursor = max(e.cursor-1, 0)
+	case "down", "j":
+		e.cursor = min(e.cursor+1, max(len(e.pairs)-1, 0))
+	case "enter":
+		if len(e.pairs) > 0 {
+			e.editing = true
+			e.input.SetValue(e.format(e.pairs[e.cursor]))
+			e.input.CursorEnd()
+			e.input.Focus()
+		}
+	case "+":
+		e.pairs = append(append([][2]string(nil), e.pairs...), [2]string{"key", "value"})
+		e.cursor = len(e.pairs) - 1
+	case "-":
+		if len(e.pairs) > 0 {
+			e.pairs = append(append([][2]string(nil), e.pairs[:e.cursor]...), e.pairs[e.cursor+1:]...)
+			e.cursor = min(e.cursor, max(len(e.pairs)-1, 0))
+		}
+	case "t":
+		// Switch to the raw text block, one pair per line.
+		lines := make([]string, len(e.pairs))
+		for i, kv := range e.pairs {
+			lines[i] = e.format(kv)
+		}
+		e.raw = true
+		e.area.SetValue(strings.Join(lines, "\n"))
+		e.area.Focus()
+	case "s", "ctrl+s":
+		return m.applyKVEditor()
+	}
+	return m, nil
+}
+
+// applyKVEditor stores the edited pairs in the request, closes the editor
+// and sends the request again.
+func (m model) applyKVEditor() (tea.Model, tea.Cmd) {
+	cfg, err := m.kvEditor.apply(m.cfg)
+	m.kvEditor = nil
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	m.cond = nil
+	return m.resend(cfg)
+}
+
+// viewKVEditor renders the key/value editor.
+func (m model) viewKVEditor() string {
+	e := m.kvEditor
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n%s\n\n", e.title())
+
+	if e.raw {
+		b.WriteString(e.area.View())
+	} else {
+		if len(e.pairs) == 0 {
+			b.WriteString("  (none)\n")
+		}
+		for i, kv := range e.pairs {
+			mark, row := "  ", e.format(kv)
+			if i == e.cursor {
+				mark = "> "
+				if e.editing {
+					row = e.input.View()
+				}
+			}
+			b.WriteString(mark + row + "\n")
+		}
+	}
+
+	if e.err != nil {
+		fmt.Fprintf(&b, "\n%v\n", e.err)
+	}
+	switch {
+	case e.raw:
+		b.WriteString("\n\none per line • esc back to table • ctrl+s send\n")
+	case e.editing:
+		b.WriteString("\nenter apply • esc cancel\n")
+	default:
+		b.WriteString("\n↑/↓ select • enter edit • + add • - remove • t raw text • s send • esc close\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRawHeaders(t *testing.T) {
+	e := newKVEditor(kvHeaders, nil)
+	pairs, err := e.parseRaw(":authority: a.test\n\nAccept: text/html\n  X-Time : 10:30 \n")
+	want := [][2]string{{"Accept", "text/html"}, {"X-Time", "10:30"}}
+	if err != nil || !reflect.DeepEqual(pairs, want) {
+		t.Errorf("parseRaw = %q, %v, want %q", pairs, err, want)
+	}
+	if _, err := e.parseRaw("Accept text/html"); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("a line without a colon: err = %v", err)
+	}
+}
+
+func TestParseRawParams(t *testing.T) {
+	e := newKVEditor(kvParams, nil)
+	pairs, err := e.parseRaw("q=a:b\npage: 2\n")
+	want := [][2]string{{"q", "a:b"}, {"page", "2"}}
+	if err != nil || !reflect.DeepEqual(pairs, want) {
+		t.Errorf("parseRaw = %q, %v, want %q", pairs, err, want)
+	}
+}
+
+func TestHeaderPairs(t *testing.T) {
+	h := http.Header{"B": {"2"}, "A": {"z", "y"}}
+	want := [][2]string{{"A", "y"}, {"A", "z"}, {"B", "2"}}
+	if got := headerPairs(h); !reflect.DeepEqual(got, want) {
+		t.Errorf("headerPairs = %q, want %q", got, want)
+	}
+}
+
+func TestApplyHeaders(t *testing.T) {
+	e := newKVEditor(kvHeaders, [][2]string{{"Accept", "a"}, {"Accept", "b"}, {"X-Id", "1"}})
+	cfg, err := e.apply(config{header: http.Header{"Old": {"gone"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := http.Header{"Accept": {"a", "b"}, "X-Id": {"1"}}
+	if !reflect.DeepEqual(cfg.header, want) {
+		t.Errorf("headers = %v, want %v", cfg.header, want)
+	}
+}
+
+func TestKVEditorApplyKeepsURL(t *testing.T) {
+	raw := "http://[::1]:8080/api/?flag&q=1#frag"
+	cfg := config{url: raw}
+	parts, _ := splitURL(raw)
+	e := newKVEditor(kvParams, parts.params)
+
+	got, err := e.apply(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.url != raw {
+		t.Errorf("apply = %q, want %q unchanged", got.url, raw)
+	}
+
+	e.pairs = append(e.pairs, [2]string{"new", ""})
+	if got, _ := e.apply(cfg); got.url != "http://[::1]:8080/api/?flag&q=1&new=#frag" {
+		t.Errorf("apply with a new param = %q", got.url)
+	}
+}
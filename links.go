@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// isHTML reports whether a response with headers h carries an HTML page.
+func isHTML(h http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// extractLinks returns the absolute URLs of every hyperlink in an HTML page
+// fetched from base, in document order and without duplicates. Links that
+// can't be requested over HTTP (mailto:, javascript:, ...) are left out, and
+// fragments are dropped since they name the same resource.
+func extractLinks(body []byte, base *url.URL) []string {
+	var links []string
+	seen := map[string]bool{}
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return links
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		name, hasAttr := z.TagName()
+		tag := string(name)
+		if !hasAttr || (tag != "a" && tag != "area" && tag != "base") {
+			continue
+		}
+		for {
+			key, val, more := z.TagAttr()
+			if string(key) == "href" {
+				ref, err := base.Parse(strings.TrimSpace(string(val)))
+				if err != nil {
+					break
+				}
+				// <base href> changes what later relative links resolve against.
+				if tag == "base" {
+					base = ref
+					break
+				}
+				if ref.Scheme != "http" && ref.Scheme != "https" {
+					break
+				}
+				ref.Fragment, ref.RawFragment = "", ""
+				if s := ref.String(); !seen[s] {
+					seen[s] = true
+					links = append(links, s)
+				}
+				break
+			}
+			if !more {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestExtractLinks(t *testing.T) {
+	base, _ := url.Parse("https://a.test/docs/page.html")
+	body := []byte(`<html><body>
+<a href="intro.html">Intro</a>
+<a href="/top#section">Top</a>
+<a href=" /top ">Top again</a>
+<a href="mailto:me@a.test">Mail</a>
+<a href="javascript:void(0)">JS</a>
+<a>No href</a>
+<map><area href="https://b.test/x" /></map>
+<base href="https://c.test/root/">
+<a href="later">Later</a>
+</body></html>`)
+
+	want := []string{
+		"https://a.test/docs/intro.html",
+		"https://a.test/top",
+		"https://b.test/x",
+		"https://c.test/root/later",
+	}
+	if got := extractLinks(body, base); !reflect.DeepEqual(got, want) {
+		t.Errorf("extractLinks = %q, want %q", got, want)
+	}
+}
+
+func TestIsHTML(t *testing.T) {
+	for ct, want := range map[string]bool{
+		"text/html; charset=utf-8": true,
+		"application/xhtml+xml":    true,
+		"text/plain":               false,
+		"":                         false,
+	} {
+		if got := isHTML(http.Header{"Content-Type": {ct}}); got != want {
+			t.Errorf("isHTML(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// A collection shared by a team drifts: a variable is renamed in the
+// environments but not the requests, a token pasted in to try something
+// stays, a request is copied and keeps its name. lint reads collections
+// without sending anything and lists what looks wrong, so a CI job can
+// catch it before it is merged.
+
+// Kinds of problems lint finds, which -skip can leave out.
+const (
+	lintUndefined = "undefined" // A {{name}} no environment defines.
+	lintUnused    = "unused"    // A variable no request uses.
+	lintSecret    = "secret"    // A credential written out in a request.
+	lintDuplicate = "duplicate" // Two requests with the same name or id.
+	lintUnchecked = "unchecked" // A request that expects nothing of its answer.
+)
+
+var lintKinds = []string{lintUndefined, lintUnused, lintSecret, lintDuplicate, lintUnchecked}
+
+// tokenPatterns match credentials by their look, wherever they are: the
+// formats that issuers make recognizable for exactly this purpose.
+var tokenPatterns = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"a JWT", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]+`)},
+	{"an AWS access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"a GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{"a Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{"a Stripe key", regexp.MustCompile(`\b[sr]k_live_[A-Za-z0-9]{16,}`)},
+	{"a Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+	{"a private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+}
+
+// lintProblem is one thing lint found.
+type lintProblem struct {
+	file    string
+	where   string // The request, as its folder and label, or "" for the file as a whole.
+	kind    string
+	message string
+}
+
+func (p lintProblem) String() string {
+	if p.where == "" {
+		return fmt.Sprintf("%s: %s [%s]", p.file, p.message, p.kind)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", p.file, p.where, p.message, p.kind)
+}
+
+// lintRequest is a request of a collection, with where it is.
+type lintRequest struct {
+	where string
+	req   collectionRequest
+}
+
+// lintRequests lists the requests of c, those outside any folder first.
+func lintRequests(c *collection) []lintRequest {
+	var out []lintRequest
+	for _, req := range c.Requests {
+		out = append(out, lintRequest{req.label(), req})
+	}
+	for _, f := range c.Folders {
+		for _, req := range f.Requests {
+			out = append(out, lintRequest{f.Name + " / " + req.label(), req})
+		}
+	}
+	return out
+}
+
+// requestText is the parts of req that are sent, where variables are filled
+// in.
+func requestText(req collectionRequest) []string {
+	text := []string{req.URL, req.Body}
+	for _, name := range slices.Sorted(maps.Keys(req.Headers)) {
+		text = append(text, req.Headers[name])
+	}
+	return text
+}
+
+// usedVariables lists the variables text uses, in order of first use.
+func usedVariables(text ...string) []string {
+	var names []string
+	for _, s := range text {
+		for _, match := range variableRef.FindAllStringSubmatch(escapedCall.ReplaceAllString(s, "{{$1}}"), -1) {
+			if !slices.Contains(names, match[1]) {
+				names = append(names, match[1])
+			}
+		}
+	}
+	return names
+}
+
+// maskToken shows enough of a credential to find it again, and no more.
+func maskToken(s string) string {
+	if r := []rune(s); len(r) > 8 {
+		return string(r[:4]) + "…"
+	}
+	return "…"
+}
+
+// findSecrets says where in req a credential is written out rather than
+// filled in from a variable: in a header or query parameter whose name says
+// it holds one, or anywhere, by its look.
+func findSecrets(req collectionRequest) []string {
+	var found []string
+	written := func(value string) string {
+		if scheme, rest, ok := strings.Cut(strings.TrimSpace(value), " "); ok && slices.Contains([]string{"bearer", "basic", "token"}, strings.ToLower(scheme)) {
+			value = rest
+		}
+		return strings.TrimSpace(variableRef.ReplaceAllString(escapedCall.ReplaceAllString(value, ""), ""))
+	}
+	for _, name := range slices.Sorted(maps.Keys(req.Headers)) {
+		if v := written(req.Headers[name]); isSecret(name) && v != "" {
+			found = append(found, fmt.Sprintf("the %s header is written out (%s); fill it in from a variable", name, maskToken(v)))
+		}
+	}
+	if u, err := url.Parse(req.URL); err == nil {
+		q := u.Query()
+		for _, name := range slices.Sorted(maps.Keys(q)) {
+			if v := written(q.Get(name)); isSecret(name) && v != "" {
+				found = append(found, fmt.Sprintf("the query parameter %s is written out (%s); fill it in from a variable", name, maskToken(v)))
+			}
+		}
+	}
+	for _, t := range tokenPatterns {
+		for _, s := range requestText(req) {
+			if match := t.pattern.FindString(s); match != "" {
+				found = append(found, fmt.Sprintf("%s is written out (%s)", t.name, maskToken(match)))
+				break
+			}
+		}
+	}
+	return found
+}
+
+// lintCollection lists the problems of the collection c, read from file,
+// with the environments envs; nil envs checks no variables.
+func lintCollection(file string, c *collection, envs map[string]environment) []lintProblem {
+	var problems []lintProblem
+	add := func(where, kind, format string, args ...any) {
+		problems = append(problems, lintProblem{file, where, kind, fmt.Sprintf(format, args...)})
+	}
+	requests := lintRequests(c)
+
+	if envs != nil {
+		names := envNames(envs)
+		for _, r := range requests {
+			for _, v := range usedVariables(requestText(r.req)...) {
+				var missing []string
+				for _, name := range names {
+					if _, ok := envs[name].Variables[v]; !ok {
+						missing = append(missing, name)
+					}
+				}
+				switch {
+				case len(missing) == len(names):
+					add(r.where, lintUndefined, "{{%s}} is defined in no environment", v)
+				case len(missing) > 0:
+					add(r.where, lintUndefined, "{{%s}} is not defined in %s", v, strings.Join(missing, ", "))
+				}
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, r := range requests {
+		for _, key := range []struct{ what, value string }{{"name", r.req.Name}, {"id", r.req.ID}} {
+			k := key.what + "\x00" + key.value
+			if key.value != "" && seen[k] {
+				add(r.where, lintDuplicate, "a request before it has the %s %q too; depends_on and hooks can't tell them apart", key.what, key.value)
+			}
+			seen[k] = true
+		}
+	}
+
+	for _, r := range requests {
+		for _, s := range findSecrets(r.req) {
+			add(r.where, lintSecret, "%s", s)
+		}
+		if e := r.req.Expect; e.Status == 0 && e.BodyContains == "" && len(e.Schema) == 0 && c.OpenAPI == "" {
+			add(r.where, lintUnchecked, "expects nothing of its answer but a 2xx; give it an expect")
+		}
+	}
+	return problems
+}
+
+// runLint implements `lint [-env-file FILE] [-skip KINDS] COLLECTION.json...`.
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	envFile := fs.String("env-file", defaultEnvFile(), "JSON `file` of named environments whose variables the requests use; \"\" to check no variables")
+	skip := fs.String("skip", "", "leave out these `kinds` of problems, comma-separated: "+strings.Join(lintKinds, ", "))
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("usage: lint [-env-file FILE] [-skip KINDS] COLLECTION.json...")
+	}
+	var skipped []string
+	if *skip != "" {
+		for _, k := range strings.Split(*skip, ",") {
+			if k = strings.TrimSpace(k); !slices.Contains(lintKinds, k) {
+				return fmt.Errorf("there is no kind of problem %q; there are %s", k, strings.Join(lintKinds, ", "))
+			}
+			skipped = append(skipped, k)
+		}
+	}
+	return lint(os.Stdout, fs.Args(), *envFile, skipped)
+}
+
+// lint lists on w the problems of the collections at files, and of the
+// environments file at envFile, if any, but those of the skipped kinds. It
+// fails if there are any, for CI.
+func lint(w io.Writer, files []string, envFile string, skipped []string) error {
+	var envs map[string]environment
+	if envFile != "" {
+		var err error
+		if envs, err = loadEnvironments(envFile); err != nil {
+			return err
+		}
+	}
+	var problems []lintProblem
+	var used []string
+	for _, file := range files {
+		c, err := loadCollection(file)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, lintCollection(file, c, envs)...)
+		for _, r := range lintRequests(c) {
+			used = append(used, usedVariables(requestText(r.req)...)...)
+		}
+		for _, h := range slices.Concat(c.Before, c.After) {
+			used = append(used, usedVariables(h.Run)...)
+		}
+	}
+
+	// A variable is used if a request of these collections uses it, or an
+	// environment does, in its base URL or another variable.
+	if envs != nil {
+		for _, name := range envNames(envs) {
+			env := envs[name]
+			used = append(used, usedVariables(env.Base)...)
+			for _, v := range env.Variables {
+				used = append(used, usedVariables(v)...)
+			}
+		}
+		for _, name := range envNames(envs) {
+			for _, v := range slices.Sorted(maps.Keys(envs[name].Variables)) {
+				if !slices.Contains(used, v) {
+					problems = append(problems, lintProblem{envFile, name, lintUnused, fmt.Sprintf("{{%s}} is used by none of the collections", v)})
+				}
+			}
+		}
+	}
+
+	problems = slices.DeleteFunc(problems, func(p lintProblem) bool { return slices.Contains(skipped, p.kind) })
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "No problems in %d files.\n", len(files))
+		return nil
+	}
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	return fmt.Errorf("%d problems", len(problems))
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// listHeight is how many entries of a link list are visible at once.
+const listHeight = 10
+
+// renderList draws the visible window of items with the selected one
+// marked, plus a position indicator when not everything fits.
+func renderList(items []string, cursor int) string {
+	// Scroll so that the cursor always stays inside the window.
+	start := 0
+	if cursor >= listHeight {
+		start = cursor - listHeight + 1
+	}
+	end := min(start+listHeight, len(items))
+
+	var b strings.Builder
+	for i := start; i < end; i++ {
+		mark := "  "
+		if i == cursor {
+			mark = "> "
+		}
+		b.WriteString("\n" + mark + items[i])
+	}
+	if len(items) > listHeight {
+		fmt.Fprintf(&b, "\n  (%d of %d)", cursor+1, len(items))
+	}
+	return b.String()
+}
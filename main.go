@@ -1,53 +1,110 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"time"
 
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-// url is the remote address we want to check.
-const url = "https://charm.sh/"
-
 // model represents the state of our application. It includes
-// the HTTP status code (if any) and an error variable.
+// the request we were asked to send, the response (if any) and an error variable.
 type model struct {
-	status int   // HTTP status code returned from the server.
-	err    error // Any error encountered during the HTTP request.
+	id   int          // Identifies the tab this model is shown in.
+	cfg  config       // What to request, as given on the command line.
+	res  response     // What came back from the server.
+	err  error        // Any error encountered during the HTTP request.
+	cond *conditional // Condition the last request was sent with, if any.
+
+	timeouts int // How many requests in a row have timed out.
+
+	// job says how a background job, such as a crawl, is getting on; it is
+	// empty when none is running. spin turns while anything is in flight.
+	job  string
+	spin spinner.Model
+
+	// report is the outcome of the last follow-up action, such as a probe.
+	// cursor selects one of its links, if it has any.
+	report *reportMsg
+	cursor int
+
+	showBody  bool // Show a preview of the response body.
+	bodyTop   int  // First line of the body on screen.
+	resumeTop int  // Where a restored tab was scrolled to, for its first response.
+	width     int  // Terminal width, 0 until the terminal has told us.
+	decoded   bool // Show the URL decoded for reading rather than as sent.
+
+	// showLog shows the application log pane, with the entries at logLevel
+	// or above.
+	showLog  bool
+	logLevel logLevel
+
+	// showRef opens the status code reference, with refCursor on the
+	// selected code.
+	showRef   bool
+	refCursor int
+
+	urlPanel *urlPanel        // The URL inspector, nil while it is closed.
+	prompt   *textinput.Model // The "send and modify" prompt, nil while it is closed.
+	palette  *textinput.Model // The command palette, nil while it is closed.
+	kvEditor *kvEditor        // The header or parameter editor, nil while it is closed.
+	tree     *jsonTree        // The JSON tree view of the body, nil while it is closed.
+	table    *tableView       // The table view of the body, nil while it is closed.
+	records  *recordsView     // The NDJSON records view, nil while it is closed.
+	history  *historyBrowser  // The history browser, nil while it is closed.
+	switcher *quickSwitcher   // The ctrl+r quick switcher, nil while it is closed.
+	picker   *endpointPicker  // The docker or mdns picker, nil while it is closed.
+	latency  *latencyRun      // Watch or load mode, nil while neither is running.
+	confirm  *confirmation    // A request held back for confirmation, nil while none is.
 }
 
-// statusMsg is a custom message type used to wrap an HTTP status code.
-type statusMsg int
+// responseMsg is a custom message type used to wrap a finished response.
+type responseMsg response
+
+// reportMsg is a custom message type carrying the titled, multi-line outcome of
+// a follow-up action run against the current URL. Any links it carries are
+// shown as a list the user can pick a new URL from; labels, when present,
+// replace the bare URLs in that list.
+type reportMsg struct {
+	title, body string
+	links       []string
+	labels      []string
+}
 
 // errMsg is a custom message type used to wrap an error encountered during the HTTP request.
 type errMsg struct{ err error }
 
-// checkServer performs an HTTP GET request to the URL and returns a tea.Msg,
-// which is either a statusMsg (with the HTTP status code) or an errMsg (on error).
-func checkServer() tea.Msg {
-	// Create an HTTP client with a timeout of 10 seconds.
-	c := &http.Client{Timeout: 10 * time.Second}
-
-	// Perform an HTTP GET request.
-	res, err := c.Get(url)
-	if err != nil {
-		// If an error occurs, wrap and return it as an errMsg.
-		return errMsg{err}
-	}
-	// It is best practice to close the response body to avoid resource leaks.
-	res.Body.Close()
+// treeMsg carries a JSON tree built in the background.
+type treeMsg struct{ tree *jsonTree }
 
-	// Return the HTTP status code wrapped as a statusMsg.
-	return statusMsg(res.StatusCode)
-}
+// tableMsg carries a table view built in the background.
+type tableMsg struct{ table *tableView }
 
 // Init is the initialization function required by the Bubble Tea framework.
-// It returns an initial command (tea.Cmd) to be executed, which in this case is the checkServer command.
+// It returns the initial commands to be executed: those of start, and
+// listening to the shared server, if there is one.
 func (m model) Init() tea.Cmd {
-	return checkServer
+	var share tea.Cmd
+	if m.cfg.share != nil {
+		share = listenShare(m.cfg.share)
+	}
+	return tea.Batch(m.start(), share)
+}
+
+// start returns the commands that get a tab going: the checkServer command,
+// and the spinner's first tick. A request held back for confirmation
+// waits for it.
+func (m model) start() tea.Cmd {
+	if m.confirm != nil {
+		return m.spin.Tick
+	}
+	return tea.Batch(checkServer(m.cfg), m.spin.Tick)
 }
 
 // Update handles incoming messages (tea.Msg) and updates the model accordingly.
@@ -56,23 +113,394 @@ func (m model) Init() tea.Cmd {
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
-	// When we receive a statusMsg, update the model with the HTTP status.
-	case statusMsg:
-		m.status = int(msg) // Cast our custom statusMsg to an int.
-		// We have the desired information, so signal Bubble Tea to quit.
-		return m, tea.Quit
+	// When we receive a responseMsg, update the model with the response.
+	case responseMsg:
+		m.res = response(msg) // Cast our custom responseMsg back to a response.
+		m.timeouts = 0
+		m.bodyTop = 0
+		if m.resumeTop > 0 {
+			m, m.resumeTop = m.scrollBody(m.resumeTop), 0
+		}
+		m.job = "" // An FTP or SFTP transfer reports its progress as a job.
+		// Stay open so the user can follow up on the response, and start
+		// reading the records of a streamed body.
+		stream := m.res.stream
+		m.res.stream = nil
+		// Let the team see what this tab is looking at.
+		if c := m.cfg.share; c != nil {
+			viewing := m.cfg.method + " " + m.cfg.url
+			return m, tea.Batch(stream, func() tea.Msg { _ = c.view(viewing); return nil })
+		}
+		return m, stream
+
+	// More records of a streamed body arrived.
+	case recordsMsg:
+		// The response they belong to has been replaced; stop reading.
+		if msg.stream != m.res.streamID {
+			msg.stop()
+			return m, nil
+		}
+		m.res.records = append(m.res.records, msg.records...)
+		if m.records != nil && m.records.follow {
+			v := *m.records
+			v.cursor = max(len(m.res.records)-1, 0)
+			v.scroll()
+			m.records = &v
+		}
+		if msg.done {
+			m.res.streaming, m.res.streamErr = false, msg.err
+			m.res = m.res.finishRecords()
+		}
+		return m, msg.next
 
 	// When we receive an errMsg, update the model with the error.
 	case errMsg:
 		m.err = msg.err // Correctly assign the underlying error, not the whole struct.
-		// Signal to quit the program.
-		return m, tea.Quit
+		m.job = ""
+		// Count timeouts in a row, which suggest looking at the network path.
+		if isTimeout(msg.err) {
+			m.timeouts++
+		} else {
+			m.timeouts = 0
+		}
+		return m, nil
+
+	// When a follow-up action finishes, keep its report for display.
+	case reportMsg:
+		m.report = &msg
+		m.cursor = 0
+		m.job = ""
+		return m, nil
+
+	// A background job says how far it has got; wait for its next word.
+	case progressMsg:
+		m.job = msg.status
+		return m, msg.next
+
+	// The JSON tree is ready to browse.
+	case treeMsg:
+		m.tree = msg.tree
+		m.job = ""
+		return m, nil
+
+	// An extension command built a request; send it.
+	case extRequestMsg:
+		m.cond, m.job = nil, ""
+		return m.resend(msg.cfg)
+
+	// The table is ready to browse.
+	case tableMsg:
+		m.table = msg.table
+		m.job = ""
+		return m, nil
+
+	// The history browser's filter found its matches.
+	case historyFoundMsg:
+		return m.showHistory(msg)
+
+	// The quick switcher's favorites and latest requests were read.
+	case switchItemsMsg:
+		return m.showSwitchItems(msg)
+
+	// The picker's services were found.
+	case endpointsMsg:
+		return m.showEndpoints(msg)
+
+	// Watch or load mode got another answer, or all of them.
+	case sampleMsg:
+		return m.addSample(msg)
+	case loadDoneMsg:
+		if m.latency != nil && m.latency.id == msg.run {
+			run := *m.latency
+			run.done = true
+			m.latency = &run
+		}
+		return m, nil
+
+	// Keep the spinner turning.
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spin, cmd = m.spin.Update(msg)
+		return m, cmd
+
+	// Remember the width so that long body lines can be cut to fit.
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		return m, nil
 
 	// Handle key press messages.
 	case tea.KeyMsg:
-		// Allow the user to exit the program by pressing Ctrl+C.
-		if msg.Type == tea.KeyCtrlC {
+		// The status code reference and the URL inspector have their own
+		// keys while they are open.
+		if m.showRef {
+			return m.updateRef(msg)
+		}
+		if m.confirm != nil {
+			return m.updateConfirm(msg)
+		}
+		if m.urlPanel != nil {
+			return m.updateURLPanel(msg)
+		}
+		if m.prompt != nil {
+			return m.updatePrompt(msg)
+		}
+		if m.palette != nil {
+			return m.updatePalette(msg)
+		}
+		if m.kvEditor != nil {
+			return m.updateKVEditor(msg)
+		}
+		if m.tree != nil {
+			return m.updateTree(msg)
+		}
+		if m.table != nil {
+			return m.updateTable(msg)
+		}
+		if m.records != nil {
+			return m.updateRecords(msg)
+		}
+		if m.history != nil {
+			return m.updateHistory(msg)
+		}
+		if m.switcher != nil {
+			return m.updateSwitcher(msg)
+		}
+		if m.picker != nil {
+			return m.updatePicker(msg)
+		}
+		if m.latency != nil {
+			return m.updateLatency(msg)
+		}
+
+		switch msg.String() {
+		// Allow the user to exit the program by pressing q or Ctrl+C.
+		case "q", "ctrl+c":
 			return m, tea.Quit
+
+		// Find out what the endpoint supports.
+		case "p":
+			return m, probe(m.cfg)
+
+		// List the links on the page, or follow them a few levels deep.
+		case "l":
+			if m.res.status > 0 {
+				r := pageLinks(m.res)
+				m.report, m.cursor = &r, 0
+			}
+			return m, nil
+		case "c":
+			if m.job != "" {
+				return m, nil
+			}
+			m.job = "Crawling"
+			return m, crawlSite(m.cfg)
+
+		// Show or hide the response body.
+		case "b":
+			m.showBody = !m.showBody
+			return m, nil
+
+		// Scroll through the body a screenful at a time.
+		case "pgdown", "ctrl+d", "pgup", "ctrl+u":
+			if m.showBody {
+				delta := previewLines
+				if msg.String() == "pgup" || msg.String() == "ctrl+u" {
+					delta = -delta
+				}
+				m = m.scrollBody(delta)
+			}
+			return m, nil
+
+		// Browse a JSON body as a collapsible tree, or NDJSON record by record.
+		case "t":
+			if m.res.records != nil || m.res.streaming {
+				m.records = &recordsView{follow: m.res.streaming}
+				if m.res.streaming {
+					m.records.cursor = max(len(m.res.records)-1, 0)
+					m.records.scroll()
+				}
+				return m, nil
+			}
+			// Parsing a large body takes a while, so do it in the background.
+			if m.res.kind == kindJSON && m.job == "" {
+				body := m.res.jsonBody()
+				m.job = "Building the JSON tree"
+				return m, runJob(func(func(string)) tea.Msg {
+					tree, err := newJSONTree(body)
+					if err != nil {
+						// Keep the response on screen; only the tree failed.
+						return reportMsg{title: "JSON tree", body: "Couldn't build the tree: " + err.Error()}
+					}
+					return treeMsg{tree}
+				})
+			}
+			return m, nil
+
+		// Browse CSV, TSV or a JSON array of objects as a table.
+		case "T":
+			if tabular(m.res) && m.job == "" {
+				res := m.res
+				m.job = "Building the table"
+				return m, runJob(func(func(string)) tea.Msg {
+					table, err := newTableView(res.header.Get("Content-Type"), res.kind, res.jsonBody())
+					if err != nil {
+						// Keep the response on screen; only the table failed.
+						return reportMsg{title: "Table", body: "Couldn't build the table: " + err.Error()}
+					}
+					return tableMsg{table}
+				})
+			}
+			return m, nil
+
+		// Switch between the URL as sent and as read.
+		case "u":
+			m.decoded = !m.decoded
+			return m, nil
+
+		// Take the URL apart and edit it piece by piece.
+		case "e":
+			panel, err := newURLPanel(m.cfg.url)
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.urlPanel = panel
+			return m, nil
+
+		// Edit the headers or query parameters, as a table or as raw text.
+		case "h":
+			m.kvEditor = newKVEditor(kvHeaders, headerPairs(m.cfg.header))
+			return m, nil
+		case "Q":
+			parts, err := splitURL(m.cfg.url)
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.kvEditor = newKVEditor(kvParams, parts.params)
+			return m, nil
+
+		// Resend with one thing changed: the whole line, or just the ID.
+		case "R":
+			m.prompt = newPrompt(m.cfg)
+			return m, nil
+		case "]", "[":
+			delta := 1
+			if msg.String() == "[" {
+				delta = -1
+			}
+			if target, ok := bumpID(m.cfg.url, delta); ok {
+				m.cond = nil
+				cfg := withoutConditions(m.cfg)
+				cfg.url, cfg.path = target, target
+				return m.resend(cfg)
+			}
+			return m, nil
+
+		// Point the same request at the next environment.
+		case "v":
+			cfg, err := switchEnv(withoutConditions(m.cfg))
+			if err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.cond = nil
+			return m.resend(cfg)
+
+		// Look up what the current status code means.
+		case "i":
+			m.showRef, m.refCursor = true, refIndex(m.res.status)
+			return m, nil
+
+		// Show the host's robots.txt and sitemap.
+		case "r":
+			return m, fetchRobots(m.cfg)
+		case "x":
+			return m, fetchSitemap(m.cfg)
+
+		// Move through the links of the current report, and open one.
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+			return m, nil
+		case "down", "j":
+			if m.report != nil && m.cursor < len(m.report.links)-1 {
+				m.cursor++
+			}
+			return m, nil
+		case "enter":
+			if m.report != nil && len(m.report.links) > 0 {
+				return m.open(m.report.links[m.cursor])
+			}
+			return m, nil
+
+		// Star the request, or switch to a starred or recent one.
+		case "*":
+			return m.toggleStar()
+		case "ctrl+r":
+			return m.openSwitcher()
+
+		// Look the host up in DNS, record by record.
+		case "N":
+			m.job = "Looking up the host"
+			return m, lookupDNS(m.cfg)
+
+		// Open the command palette for the utilities without a key.
+		case ":":
+			m.palette = newPalette()
+			return m, nil
+
+		// Show the connection pool's settings and how it has been used.
+		case "d":
+			r := diagnostics(m.cfg)
+			m.report = &r
+			return m, nil
+
+		// Show the request and answer as they went over the connection.
+		case "W":
+			if m.res.wire != nil {
+				r := m.res.wire.report()
+				m.report, m.cursor = &r, 0
+			}
+			return m, nil
+
+		// Show or hide the application log.
+		case "L":
+			m.showLog = !m.showLog
+			return m, nil
+
+		// Tell what the transport did, step by step.
+		case "E":
+			if m.res.timeline != nil {
+				r := m.res.timeline.report()
+				m.report, m.cursor = &r, 0
+			}
+			return m, nil
+
+		// Grade the security headers of the response we already have.
+		case "a":
+			if m.res.status > 0 {
+				r := securityAudit(m.res, m.res.final.Scheme == "https")
+				m.report = &r
+			}
+			return m, nil
+		}
+
+		// Resend with a precondition built from the last response's validators.
+		if m.res.status > 0 {
+			for _, c := range available(m.res.header) {
+				if msg.String() == c.key {
+					m.cond = &c
+					return m.resend(withConditional(m.cfg, m.res.header, c))
+				}
+			}
+		}
+
+		// Run a command bound by an extension.
+		if c, ok := m.cfg.ext.command(msg.String()); ok && m.res.status > 0 {
+			m.job = c.name
+			return m, runCommand(c, m.cfg, m.res)
 		}
 	}
 
@@ -80,35 +508,409 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// modal reports whether a panel or prompt that takes over the keyboard is open.
+func (m model) modal() bool {
+	return m.showRef || m.confirm != nil || m.urlPanel != nil || m.prompt != nil || m.palette != nil || m.kvEditor != nil || m.tree != nil || m.table != nil || m.records != nil || m.history != nil || m.switcher != nil || m.picker != nil || m.latency != nil
+}
+
+// resend forgets the previous outcome and sends the request described by
+// cfg, once confirmed if it goes to a protected host.
+func (m model) resend(cfg config) (tea.Model, tea.Cmd) {
+	return m.guarded(cfg, cfg.method+" "+cfg.url, func(m model) (tea.Model, tea.Cmd) {
+		m.cfg = cfg
+		m.res = response{}
+		m.err = nil
+		m.report = nil
+		return m, checkServer(cfg)
+	})
+}
+
+// open points the program at a URL picked from a report's links and checks
+// it, keeping the list on screen so the user can carry on browsing.
+func (m model) open(target string) (tea.Model, tea.Cmd) {
+	target, err := normalizeURL(target)
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	// Preconditions belong to the old URL's validators.
+	cfg := withoutConditions(m.cfg)
+	cfg.url, cfg.path = target, target
+	return m.guarded(cfg, cfg.method+" "+cfg.url, func(m model) (tea.Model, tea.Cmd) {
+		m.cfg = cfg
+		m.res = response{}
+		m.err = nil
+		m.cond = nil
+		return m, checkServer(cfg)
+	})
+}
+
 // View renders the output based on the current state of the model.
 // It returns a string that is displayed in the terminal.
 func (m model) View() string {
+	if m.showRef {
+		return m.viewRef()
+	}
+	if m.confirm != nil {
+		return m.viewConfirm()
+	}
+	if m.urlPanel != nil {
+		return m.viewURLPanel()
+	}
+	if m.kvEditor != nil {
+		return m.viewKVEditor()
+	}
+	if m.tree != nil {
+		return m.viewTree()
+	}
+	if m.table != nil {
+		return m.viewTable()
+	}
+	if m.records != nil {
+		return m.viewRecords()
+	}
+	if m.history != nil {
+		return m.viewHistory()
+	}
+	if m.switcher != nil {
+		return m.viewSwitcher()
+	}
+	if m.picker != nil {
+		return m.viewPicker()
+	}
+	if m.latency != nil {
+		return m.viewLatency()
+	}
+
+	// The prompt sits on top of whatever else is on screen.
+	if m.prompt != nil {
+		view := m.viewMain() + "\n" + m.prompt.View() + "\n"
+		if hint := promptHint(m.prompt.Value()); hint != "" {
+			view += "\n" + hint
+		}
+		return view + "\n\nenter send • esc cancel\n"
+	}
+	if m.palette != nil {
+		return m.viewMain() + "\n" + m.palette.View() + "\n" + paletteHint(m.palette.Value()) + "\n\nenter run • esc cancel\n"
+	}
+	return m.viewMain()
+}
+
+// viewMain renders the request, its response and the follow-up reports.
+func (m model) viewMain() string {
 	// If there was an error during the HTTP request, display the error.
+	// The palette's utilities still work, to help find out why.
 	if m.err != nil {
-		return fmt.Sprintf("\nWe had some trouble: %v\n\n", m.err)
+		s := fmt.Sprintf("\nWe had some trouble: %v\n", m.err)
+		if hint := traceHint(m.timeouts); hint != "" {
+			s += "\n" + hint + "\n"
+		}
+		if m.job != "" {
+			s += "\n" + m.spin.View() + " " + m.job + "\n"
+		}
+		if m.report != nil {
+			s += "\n" + m.report.title + "\n" + m.report.body + "\n"
+		}
+		if m.showLog {
+			s += "\n" + m.viewLog() + "\n"
+		}
+		return s + "\nPress : for commands such as ping, L for the log, or q to quit.\n"
 	}
 
 	// Otherwise, build a string indicating that the program is checking the URL.
-	s := fmt.Sprintf("Checking %s ... ", url)
+	shown := m.cfg.url
+	if m.decoded {
+		shown = displayURL(m.cfg.url)
+	}
+	s := fmt.Sprintf("Checking %s %s ... ", m.cfg.method, shown)
+	if m.res.status == 0 {
+		s += m.spin.View()
+		// A transfer that takes a while says how far it has got.
+		if m.job != "" {
+			s += " " + m.job
+		}
+	}
 
 	// If a status code is present, display it along with its standard text representation.
-	if m.status > 0 {
-		s += fmt.Sprintf("%d %s!", m.status, http.StatusText(m.status))
+	if m.res.status > 0 {
+		s += fmt.Sprintf("%d %s!", m.res.status, http.StatusText(m.res.status))
+		if meaning := statusMeaning(m.res.status); meaning != "" {
+			s += "\n" + meaning + " (i for more)"
+		}
+
+		// Give the ID to look the request up by in the server's logs.
+		if m.res.requestID != "" {
+			s += "\nRequest ID: " + m.res.requestID
+			if m.res.echoed {
+				s += " (echoed by the server)"
+			}
+		}
+
+		if m.cfg.share != nil {
+			s += "\nShared with: " + m.cfg.share.others()
+		}
+
+		// Say which environment a relative URL was resolved against.
+		if m.cfg.env != "" {
+			s += fmt.Sprintf("\nEnvironment: %s (%s)", m.cfg.env, m.cfg.envs[m.cfg.env].Base)
+		}
+
+		if m.cfg.readOnly {
+			s += "\nREAD-ONLY: only GET and HEAD requests are sent"
+		}
+
+		// An answer from the offline file is not what the server says now.
+		switch {
+		case m.res.offline == nil:
+		case m.res.offline.IsZero():
+			s += "\nOFFLINE: an example answer from " + m.cfg.offlineFile
+		default:
+			s += "\nOFFLINE: the answer kept at " + m.res.offline.Local().Format(time.DateTime)
+		}
+
+		// Don't let an answer made worse on purpose pass for the real thing.
+		if m.cfg.transport.chaos.enabled() {
+			s += "\nChaos: " + m.cfg.transport.chaos.String()
+		}
+		if m.cfg.transport.throttle != (throttle{}) {
+			s += "\nThrottled: " + m.cfg.transport.throttle.String()
+		}
+
+		// Say which address, and so which IP version, answered.
+		if m.res.conn != nil {
+			if c := m.res.conn.summary(m.cfg.transport.family); c != "" {
+				s += "\nConnection: " + c
+			}
+		}
+
+		// Internationalized hosts travel as punycode; show both forms.
+		if uni, ascii, ok := idnHost(m.cfg.url); ok {
+			s += fmt.Sprintf("\nHost: %s is sent as %s", uni, ascii)
+		}
+
+		// Explain whether the server let a large upload through.
+		if m.res.cont != nil {
+			s += "\nExpect: 100-continue: " + m.res.cont.summary(m.res.status)
+		}
+
+		// Spell out which part of the resource a partial response covers.
+		if cr := m.res.header.Get("Content-Range"); cr != "" {
+			if parsed, err := parseContentRange(cr); err == nil {
+				s += "\nContent-Range: " + parsed.String()
+			} else {
+				s += "\nContent-Range: " + err.Error()
+			}
+		}
+
+		// Report where a downloaded body ended up.
+		if m.res.saved != nil {
+			s += "\nDownload: " + m.res.saved.summary()
+		}
+
+		// Give the simulated browser's CORS verdict.
+		if m.res.cors != nil {
+			s += "\nCORS from " + m.cfg.origin + ": " + m.res.cors.summary()
+		}
+
+		// Say whether the body keeps to its schema.
+		if m.cfg.schema != nil && m.res.saved == nil && !m.res.streaming {
+			if len(m.res.violations) == 0 {
+				s += "\nSchema: ✓ the body matches"
+			} else {
+				s += fmt.Sprintf("\nSchema: ✗ %d violations\n  %s", len(m.res.violations), describeViolations(m.res.violations, "\n  "))
+			}
+		}
+
+		// Pass on what the middlewares noticed.
+		for _, note := range m.res.notes {
+			s += "\nPlugin " + note
+		}
+
+		// Say what the precondition we sent told us.
+		if m.cond != nil {
+			s += fmt.Sprintf("\n%s: %s: %s", m.cond.header, m.cfg.header.Get(m.cond.header), m.cond.explain(m.res.status))
+		}
+
+		// Warn when the server mislabels its content.
+		if m.res.mismatch != "" {
+			s += "\nContent-Type: " + m.res.mismatch + ", showing it as " + string(m.res.kind)
+		}
+
+		// Say which legacy charset the text was transcoded from.
+		if m.res.charset != nil {
+			s += "\nCharset: " + m.res.charset.summary()
+		}
+
+		// Say what an FTP or SFTP transfer did.
+		if m.res.transfer != "" {
+			s += "\nTransfer: " + m.res.transfer
+		}
+
+		// Spell out a SOAP fault; its status alone says little.
+		if m.res.fault != "" {
+			s += "\nSOAP fault: " + m.res.fault
+		}
+
+		// Say what a binary body was decoded from for display.
+		if m.res.format != "" {
+			s += "\nBody: " + m.res.format
+		}
+
+		// Count the records of a body that is still streaming in.
+		if m.res.streaming {
+			s += fmt.Sprintf("\n%s Streaming records: %d so far (t to view)", m.spin.View(), len(m.res.records))
+		}
+
+		// Preview the body with the renderer that suits its content.
+		if m.showBody && m.res.saved == nil && !m.res.streaming {
+			s += fmt.Sprintf("\n\nBody (%s, %d bytes)\n%s", m.res.kind, len(m.res.body), m.bodyWindow())
+		}
+
+		// Say what is still running in the background.
+		if m.job != "" {
+			s += "\n\n" + m.spin.View() + " " + m.job
+		}
+
+		// Show the outcome of the last follow-up action.
+		if m.report != nil {
+			s += "\n\n" + m.report.title + "\n" + m.report.body
+			if m.report.labels != nil {
+				s += renderList(m.report.labels, m.cursor)
+			} else {
+				s += renderList(m.report.links, m.cursor)
+			}
+		}
+
+		if m.showLog {
+			s += "\n\n" + m.viewLog()
+		}
+
+		s += "\n\n" + m.help()
 	}
 
 	// Add some line breaks for nice formatting.
 	return "\n" + s + "\n\n"
 }
 
+// help lists the keys that do something with the current response.
+func (m model) help() string {
+	s := ""
+	for _, c := range available(m.res.header) {
+		s += fmt.Sprintf("%s %s • ", c.key, c.header)
+	}
+	if m.report != nil && len(m.report.links) > 0 {
+		s += "↑/↓ select • enter open • "
+	}
+	switch {
+	case m.res.records != nil || m.res.streaming:
+		s += "t records • "
+	case m.res.kind == kindJSON:
+		s += "t JSON tree • "
+	}
+	if tabular(m.res) {
+		s += "T table • "
+	}
+	s += m.cfg.ext.help()
+	return s + "b body • e edit URL • h headers • W wire • E timeline • L log • Q params • R resend modified • * star • ctrl+r switch request • [/] bump ID • D duplicate • v next env • u decode URL • i status info • p probe • N DNS lookup • : commands • d pool diagnostics • a audit • l links • c crawl • r robots.txt • x sitemap • q quit"
+}
+
+// subcommands maps a first argument to an alternative mode of the program,
+// each taking the remaining arguments.
+var subcommands = map[string]func(args []string) error{
+	"audit-export":   runAuditExport,
+	"check-links":    runCheckLinks,
+	"check-sitemap":  runCheckSitemap,
+	"env-export":     runEnvExport,
+	"env-import":     runEnvImport,
+	"history-export": runHistoryExport,
+	"share-files":    runShareFiles,
+	"share-server":   runShareServer,
+	"lint":           runLint,
+	"listen":         runListen,
+	"netcat":         runNetcat,
+	"rename-var":     runRenameVar,
+	"replace":        runReplace,
+	"run":            runCollectionCmd,
+	"wsdl":           runWSDL,
+}
+
 // main is the entry point of the program.
-// It creates a new Bubble Tea program using the model, runs it, and handles any errors.
+// It reads the command line, creates a new Bubble Tea program using the model, runs it,
+// and handles any errors.
 func main() {
-	// Create a new Bubble Tea program with an empty model.
-	p := tea.NewProgram(model{})
+	// Hand over to a subcommand if one was named.
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			err := run(os.Args[2:])
+			closePortForwards()
+			if err != nil {
+				fmt.Printf("Uh oh, there was an error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	cfg, err := parseFlags()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	if cfg.shareURL != "" {
+		if cfg.share, err = dialShare(cfg.shareURL, shareUser()); err == nil {
+			err = cfg.share.push(envDoc, cfg.envFile)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+	if cfg.metricsAddr != "" {
+		if _, err := serveMetrics(cfg.metricsAddr, cfg.metrics); err != nil {
+			fmt.Println(err)
+			os.Exit(2)
+		}
+	}
+
+	// Create a new Bubble Tea program with a model that knows what to request.
+	// The first request waits, like any other, if it needs confirming.
+	first := model{cfg: cfg, spin: newSpinner()}
+	sendFirst := func(m model) (tea.Model, tea.Cmd) { return m, checkServer(m.cfg) }
+	first.confirm = newConfirmation(cfg, cfg.method+" "+cfg.url, sendFirst)
+	// Someone else's bundle is reviewed before it is sent.
+	if cfg.bundle != nil {
+		u, _ := url.Parse(cfg.url)
+		first.confirm = holdFor(u.Hostname(), "The bundled "+cfg.method+" "+cfg.url, sendFirst)
+		first.confirm.detail = cfg.bundle.describe(cfg)
+	}
+	// Open the tabs that were open last time, unless asked not to.
+	a := newApp(first)
+	if !cfg.fresh && cfg.sessionFile != "" {
+		s, recovered, err := loadLastSession(cfg.sessionFile)
+		if err != nil {
+			fmt.Printf("Couldn't restore the last session, so starting afresh: %v\n", err)
+		} else if len(s.Tabs) > 0 {
+			a = restoreSession(first, s, flag.NArg() > 0 || cfg.bundle != nil, recovered)
+		}
+	}
+	p := tea.NewProgram(a)
 
 	// Run the program. If there is an error during runtime, print it and exit.
-	if _, err := p.Run(); err != nil {
+	final, err := p.Run()
+	closePortForwards()
+	if err != nil {
 		fmt.Printf("Uh oh, there was an error: %v\n", err)
 		os.Exit(1)
 	}
+	if a, ok := final.(app); ok && cfg.sessionFile != "" {
+		// The session has the tabs now; the recovery file is only for
+		// runs that don't get this far.
+		err := saveSession(cfg.sessionFile, a.session())
+		if err == nil {
+			err = removeRecovery(cfg.sessionFile)
+		}
+		if err != nil {
+			fmt.Printf("Couldn't keep the session: %v\n", err)
+		}
+	}
 }
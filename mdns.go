@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Printers, cameras, routers and the dev box under the desk announce their
+// web servers with multicast DNS, as Bonjour and Avahi do. The palette's
+// `mdns` asks the LAN which services of a type there are, _http._tcp and
+// _https._tcp by default, and lists them to pick one; it goes into the
+// resend prompt with the path its TXT record gives, or the one on screen.
+//
+// The question goes out from a port of our own rather than 5353, which
+// responders answer directly, so no other program's socket is in the way.
+
+// mdnsTitle is what the picker of services is titled.
+const mdnsTitle = "Services on the LAN"
+
+// mdnsGroup is where multicast DNS questions go.
+var mdnsGroup = "224.0.0.251:5353"
+
+// mdnsWait is how long the answers are collected for.
+var mdnsWait = 2 * time.Second
+
+// mdnsServiceTypes are the types asked about unless others are given.
+var mdnsServiceTypes = []string{"_http._tcp", "_https._tcp"}
+
+// mdnsService is one service instance that answered.
+type mdnsService struct {
+	instance string // Its name, e.g. "Office Printer".
+	kind     string // Its type, e.g. _http._tcp.
+	host     string // The host it says it is on, e.g. printer.local.
+	addr     string // Where to reach it, e.g. 192.168.1.20:80.
+	path     string // The path its TXT record gives; "" if none.
+}
+
+// url is where a request goes to reach s, with the path and query of
+// current unless s gives a path of its own.
+func (s mdnsService) url(current string) string {
+	scheme := "http"
+	if s.kind == "_https._tcp" {
+		scheme = "https"
+	}
+	target := scheme + "://" + s.addr
+	if s.path != "" {
+		return target + "/" + strings.TrimPrefix(s.path, "/")
+	}
+	if u, err := url.Parse(current); err == nil && u.Host != "" {
+		return target + u.RequestURI()
+	}
+	return target + "/"
+}
+
+// label is how the picker lists s.
+func (s mdnsService) label() string {
+	return fmt.Sprintf("%s  %s  %s → %s%s", s.instance, s.kind, s.host, s.addr, s.path)
+}
+
+// runMDNS is the palette's `mdns [_type._tcp…] [words]`: it lists the
+// services of those types on the LAN, of those matching words if any.
+func runMDNS(m model, args []string) (tea.Model, tea.Cmd) {
+	var kinds, words []string
+	for _, a := range args {
+		if strings.HasPrefix(a, "_") {
+			kinds = append(kinds, strings.TrimSuffix(strings.TrimSuffix(a, "."), ".local"))
+		} else {
+			words = append(words, a)
+		}
+	}
+	if len(kinds) == 0 {
+		kinds = mdnsServiceTypes
+	}
+	return m.openPicker(endpointPicker{
+		title:  mdnsTitle,
+		asking: "Asking the LAN for " + strings.Join(kinds, ", "),
+		none:   "Nothing on the LAN answered for " + strings.Join(kinds, ", "),
+		find:   findMDNSEndpoints(kinds),
+	}, words)
+}
+
+// findMDNSEndpoints returns a command that browses for services of kinds
+// and lists them by instance name.
+func findMDNSEndpoints(kinds []string) tea.Cmd {
+	return func() tea.Msg {
+		services, err := browseMDNS(kinds, mdnsWait)
+		msg := endpointsMsg{title: mdnsTitle, err: err}
+		for _, s := range services {
+			msg.endpoints = append(msg.endpoints, endpoint{label: s.label(), url: s.url})
+		}
+		return msg
+	}
+}
+
+// browseMDNS asks for the services of kinds and collects the answers for
+// wait.
+func browseMDNS(kinds []string, wait time.Duration) ([]mdnsService, error) {
+	msg := dnsmessage.Message{Header: dnsmessage.Header{ID: uint16(time.Now().UnixNano())}}
+	for _, k := range kinds {
+		name, err := dnsmessage.NewName(k + ".local.")
+		if err != nil {
+			return nil, fmt.Errorf("the service type %q: %w", k, err)
+		}
+		msg.Questions = append(msg.Questions, dnsmessage.Question{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET})
+	}
+	query, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+	group, err := net.ResolveUDPAddr("udp4", mdnsGroup)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.WriteToUDP(query, group); err != nil {
+		return nil, fmt.Errorf("asking the LAN: %w", err)
+	}
+
+	var answers []dnsmessage.Resource
+	conn.SetReadDeadline(time.Now().Add(wait))
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if isTimeout(err) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var answer dnsmessage.Message
+		if answer.Unpack(buf[:n]) != nil || !answer.Response {
+			continue
+		}
+		// What a responder knows of the instance, the SRV, TXT and
+		// addresses, comes with the PTR if it is any good.
+		answers = append(answers, answer.Answers...)
+		answers = append(answers, answer.Additionals...)
+	}
+	return mdnsServices(kinds, answers), nil
+}
+
+// mdnsServices puts together the services of kinds out of the records
+// answered: a PTR names an instance, its SRV says which host and port it
+// is on, its TXT may give a path, and the host's A or AAAA its address.
+func mdnsServices(kinds []string, records []dnsmessage.Resource) []mdnsService {
+	type srv struct {
+		host string
+		port uint16
+	}
+	instances := map[string]string{} // Instance's full name to its type.
+	srvs := map[string]srv{}
+	paths := map[string]string{}
+	addrs := map[string][]net.IP{}
+	for _, r := range records {
+		name := strings.ToLower(r.Header.Name.String())
+		switch body := r.Body.(type) {
+		case *dnsmessage.PTRResource:
+			for _, k := range kinds {
+				if name == strings.ToLower(k)+".local." {
+					instances[body.PTR.String()] = k
+				}
+			}
+		case *dnsmessage.SRVResource:
+			srvs[name] = srv{body.Target.String(), body.Port}
+		case *dnsmessage.TXTResource:
+			for _, kv := range body.TXT {
+				if v, ok := strings.CutPrefix(kv, "path="); ok {
+					paths[name] = v
+				}
+			}
+		case *dnsmessage.AResource:
+			addrs[name] = append(addrs[name], net.IP(body.A[:]))
+		case *dnsmessage.AAAAResource:
+			// A link-local address is no use without its interface.
+			if ip := net.IP(body.AAAA[:]); !ip.IsLinkLocalUnicast() {
+				addrs[name] = append(addrs[name], ip)
+			}
+		}
+	}
+
+	var services []mdnsService
+	for full, kind := range instances {
+		s, ok := srvs[strings.ToLower(full)]
+		if !ok {
+			continue
+		}
+		host := strings.TrimSuffix(s.host, ".")
+		svc := mdnsService{
+			instance: strings.TrimSuffix(full, "."+kind+".local."),
+			kind:     kind,
+			host:     host,
+			addr:     net.JoinHostPort(host, strconv.Itoa(int(s.port))),
+			path:     paths[strings.ToLower(full)],
+		}
+		// An IPv4 address is the likelier to be reachable; without any, the
+		// name is left for the system to resolve.
+		ips := addrs[strings.ToLower(s.host)]
+		if i := slices.IndexFunc(ips, func(ip net.IP) bool { return ip.To4() != nil }); i >= 0 {
+			svc.addr = net.JoinHostPort(ips[i].String(), strconv.Itoa(int(s.port)))
+		} else if len(ips) > 0 {
+			svc.addr = net.JoinHostPort(ips[0].String(), strconv.Itoa(int(s.port)))
+		}
+		services = append(services, svc)
+	}
+	slices.SortFunc(services, func(a, b mdnsService) int {
+		if c := strings.Compare(strings.ToLower(a.instance), strings.ToLower(b.instance)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.kind, b.kind)
+	})
+	return services
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeResponder answers multicast DNS questions for _http._tcp on a local
+// UDP port, and points mdnsGroup at it.
+func fakeResponder(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skip("no UDP:", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	old, oldWait := mdnsGroup, mdnsWait
+	mdnsGroup, mdnsWait = conn.LocalAddr().String(), 200*time.Millisecond
+	t.Cleanup(func() { mdnsGroup, mdnsWait = old, oldWait })
+
+	name := dnsmessage.MustNewName
+	hdr := func(n string, typ dnsmessage.Type) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: name(n), Type: typ, Class: dnsmessage.ClassINET, TTL: 120}
+	}
+	answer, err := (&dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true, Authoritative: true},
+		Answers: []dnsmessage.Resource{
+			{Header: hdr("_http._tcp.local.", dnsmessage.TypePTR), Body: &dnsmessage.PTRResource{PTR: name("Office Printer._http._tcp.local.")}},
+			{Header: hdr("_http._tcp.local.", dnsmessage.TypePTR), Body: &dnsmessage.PTRResource{PTR: name("devbox._http._tcp.local.")}},
+			{Header: hdr("_http._tcp.local.", dnsmessage.TypePTR), Body: &dnsmessage.PTRResource{PTR: name("gone._http._tcp.local.")}},
+		},
+		Additionals: []dnsmessage.Resource{
+			{Header: hdr("Office Printer._http._tcp.local.", dnsmessage.TypeSRV), Body: &dnsmessage.SRVResource{Target: name("printer.local."), Port: 80}},
+			{Header: hdr("Office Printer._http._tcp.local.", dnsmessage.TypeTXT), Body: &dnsmessage.TXTResource{TXT: []string{"txtvers=1", "path=/admin"}}},
+			{Header: hdr("printer.local.", dnsmessage.TypeAAAA), Body: &dnsmessage.AAAAResource{AAAA: [16]byte{0xfe, 0x80, 15: 1}}},
+			{Header: hdr("printer.local.", dnsmessage.TypeA), Body: &dnsmessage.AResource{A: [4]byte{192, 168, 1, 20}}},
+			{Header: hdr("devbox._http._tcp.local.", dnsmessage.TypeSRV), Body: &dnsmessage.SRVResource{Target: name("devbox.local."), Port: 3000}},
+		},
+	}).Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			var q dnsmessage.Message
+			if q.Unpack(buf[:n]) != nil || len(q.Questions) == 0 || q.Questions[0].Type != dnsmessage.TypePTR {
+				continue
+			}
+			if q.Questions[0].Name.String() == "_http._tcp.local." {
+				conn.WriteToUDP(answer, from)
+			}
+		}
+	}()
+}
+
+func TestBrowseMDNS(t *testing.T) {
+	fakeResponder(t)
+	services, err := browseMDNS([]string{"_http._tcp"}, mdnsWait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range services {
+		got = append(got, s.label()+" "+s.url("http://localhost:8080/api/users?page=2"))
+	}
+	// gone has no SRV, and devbox no address.
+	want := []string{
+		"devbox  _http._tcp  devbox.local → devbox.local:3000 http://devbox.local:3000/api/users?page=2",
+		"Office Printer  _http._tcp  printer.local → 192.168.1.20:80/admin http://192.168.1.20:80/admin",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMDNSServiceURL(t *testing.T) {
+	s := mdnsService{kind: "_https._tcp", addr: "10.0.0.5:8443"}
+	if got := s.url(""); got != "https://10.0.0.5:8443/" {
+		t.Errorf("url = %q", got)
+	}
+}
+
+func TestMDNSPicker(t *testing.T) {
+	fakeResponder(t)
+	m := model{cfg: config{method: "GET", url: "http://localhost:3000/", header: http.Header{}}}
+	next, cmd := runMDNS(m, []string{"_http._tcp", "printer"})
+	next, _ = next.(model).Update(cmd())
+	if v := next.(model).View(); !strings.Contains(v, "> Office Printer") || strings.Contains(v, "devbox") {
+		t.Errorf("view:\n%s", v)
+	}
+	next, _ = next.(model).Update(tea.KeyMsg{Type: tea.KeyEnter})
+	p := next.(model).prompt
+	if next.(model).picker != nil || p == nil || p.Value() != "GET http://192.168.1.20:80/admin" {
+		t.Errorf("prompt = %v", p)
+	}
+}
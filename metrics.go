@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metrics counts the requests of watch and load mode in the Prometheus text
+// format, for a scraper to fetch from -metrics-addr or the node exporter's
+// textfile collector to pick up from -metrics-file.
+type metrics struct {
+	mu     sync.Mutex
+	file   string // Rewritten after every request; "" for none.
+	series map[seriesKey]*series
+}
+
+// seriesKey is what a request's series are labelled with.
+type seriesKey struct{ method, url string }
+
+// series are the numbers kept for one request.
+type series struct {
+	codes   map[int]int // Answers by status code.
+	errors  int         // Requests that got no answer.
+	buckets []int       // Answers by histBounds bucket, the last for slower ones.
+	sum     time.Duration
+}
+
+// newMetrics starts counting, writing the counts to file if it isn't "".
+func newMetrics(file string) *metrics {
+	return &metrics{file: file, series: map[seriesKey]*series{}}
+}
+
+// observe counts a request's sample. On a nil *metrics it does nothing, so
+// that callers needn't check whether metrics were asked for.
+func (m *metrics) observe(method, url string, s sample) error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := seriesKey{method, url}
+	ser := m.series[k]
+	if ser == nil {
+		ser = &series{codes: map[int]int{}, buckets: make([]int, len(histBounds)+1)}
+		m.series[k] = ser
+	}
+	if s.err != nil {
+		ser.errors++
+	} else {
+		ser.codes[s.status]++
+		i, _ := slices.BinarySearch(histBounds, s.elapsed)
+		ser.buckets[i]++
+		ser.sum += s.elapsed
+	}
+	if m.file == "" {
+		return nil
+	}
+	var b bytes.Buffer
+	m.write(&b)
+	return writeFileAtomic(m.file, b.Bytes())
+}
+
+// writeFileAtomic replaces the file at path with data, by way of a
+// temporary file, so that a reader never sees half of it. The file is
+// readable by its owner only.
+func writeFileAtomic(path string, data []byte) error {
+	return writeFileAtomicPerm(path, data, 0o600)
+}
+
+// writeFileAtomicPerm is writeFileAtomic for a file with the permissions perm.
+func writeFileAtomicPerm(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ServeHTTP answers a scrape with the current counts.
+func (m *metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// write writes the counts in the Prometheus text format. The caller holds m.mu.
+func (m *metrics) write(w io.Writer) {
+	keys := make([]seriesKey, 0, len(m.series))
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b seriesKey) int {
+		return cmp.Or(strings.Compare(a.url, b.url), strings.Compare(a.method, b.method))
+	})
+	labels := func(k seriesKey) string {
+		return fmt.Sprintf(`method="%s",url="%s"`, escapeLabel(k.method), escapeLabel(k.url))
+	}
+
+	fmt.Fprintln(w, "# HELP httpwizard_requests_total Requests answered, by status code.")
+	fmt.Fprintln(w, "# TYPE httpwizard_requests_total counter")
+	for _, k := range keys {
+		ser := m.series[k]
+		for _, code := range slices.Sorted(maps.Keys(ser.codes)) {
+			fmt.Fprintf(w, "httpwizard_requests_total{%s,code=\"%d\"} %d\n", labels(k), code, ser.codes[code])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP httpwizard_request_errors_total Requests that got no answer.")
+	fmt.Fprintln(w, "# TYPE httpwizard_request_errors_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "httpwizard_request_errors_total{%s} %d\n", labels(k), m.series[k].errors)
+	}
+
+	fmt.Fprintln(w, "# HELP httpwizard_request_duration_seconds How long answered requests took.")
+	fmt.Fprintln(w, "# TYPE httpwizard_request_duration_seconds histogram")
+	for _, k := range keys {
+		ser := m.series[k]
+		count := 0
+		for i, n := range ser.buckets {
+			count += n
+			le := "+Inf"
+			if i < len(histBounds) {
+				le = strconv.FormatFloat(histBounds[i].Seconds(), 'g', -1, 64)
+			}
+			fmt.Fprintf(w, "httpwizard_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels(k), le, count)
+		}
+		fmt.Fprintf(w, "httpwizard_request_duration_seconds_sum{%s} %g\n", labels(k), ser.sum.Seconds())
+		fmt.Fprintf(w, "httpwizard_request_duration_seconds_count{%s} %d\n", labels(k), count)
+	}
+}
+
+// escapeLabel escapes a label value the way the text format wants it.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// serveMetrics serves m on addr, at /metrics, in the background. It
+// returns once it is listening, or why it can't.
+func serveMetrics(addr string, m *metrics) (net.Addr, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("-metrics-addr: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", m)
+	go http.Serve(l, mux)
+	return l.Addr(), nil
+}
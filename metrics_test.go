@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsText(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "httpwizard.prom")
+	m := newMetrics(file)
+	url := `https://example.com/a"b`
+	for _, s := range []sample{
+		{status: 200, elapsed: 20 * time.Millisecond},
+		{status: 200, elapsed: 300 * time.Millisecond},
+		{status: 503, elapsed: 8 * time.Millisecond},
+		{err: errors.New("refused")},
+	} {
+		if err := m.observe("GET", url, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(b)
+	labels := `method="GET",url="https://example.com/a\"b"`
+	for _, want := range []string{
+		"# TYPE httpwizard_requests_total counter\n",
+		"httpwizard_requests_total{" + labels + `,code="200"} 2` + "\n",
+		"httpwizard_requests_total{" + labels + `,code="503"} 1` + "\n",
+		"httpwizard_request_errors_total{" + labels + "} 1\n",
+		"httpwizard_request_duration_seconds_bucket{" + labels + `,le="0.01"} 1` + "\n",
+		"httpwizard_request_duration_seconds_bucket{" + labels + `,le="0.25"} 2` + "\n",
+		"httpwizard_request_duration_seconds_bucket{" + labels + `,le="0.5"} 3` + "\n",
+		"httpwizard_request_duration_seconds_bucket{" + labels + `,le="+Inf"} 3` + "\n",
+		"httpwizard_request_duration_seconds_sum{" + labels + "} 0.328\n",
+		"httpwizard_request_duration_seconds_count{" + labels + "} 3\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("metrics lack %q:\n%s", want, text)
+		}
+	}
+
+	// Nothing is counted, and nothing breaks, when metrics are off.
+	var off *metrics
+	if err := off.observe("GET", url, sample{status: 200}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	m := newMetrics("")
+	m.observe("GET", "http://x", sample{status: 204, elapsed: time.Millisecond})
+	addr, err := serveMetrics("127.0.0.1:0", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := http.Get("http://" + addr.String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/plain; version=0.0.4") || !strings.Contains(string(body), `code="204"} 1`) {
+		t.Errorf("scrape got %s:\n%s", res.Header.Get("Content-Type"), body)
+	}
+	if _, err := serveMetrics(addr.String(), m); err == nil {
+		t.Error("a second server took the same address")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// lastNumber finds the last run of digits in a URL path, which is usually
+// the ID of the resource, e.g. the 42 in /users/42/orders.
+var lastNumber = regexp.MustCompile(`\d+(\D*)$`)
+
+// bumpID returns raw with the last number in its path increased by delta,
+// or ok=false when the path has no number or it would drop below zero.
+func bumpID(raw string, delta int) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	loc := lastNumber.FindStringSubmatchIndex(u.Path)
+	if loc == nil {
+		return "", false
+	}
+	digits := u.Path[loc[0]:loc[2]]
+	n, err := strconv.Atoi(digits)
+	if err != nil || n+delta < 0 {
+		return "", false
+	}
+	// Keep zero padding, so /item/007 becomes /item/008.
+	bumped := fmt.Sprintf("%0*d", len(digits), n+delta)
+	u.Path = u.Path[:loc[0]] + bumped + u.Path[loc[2]:]
+	u.RawPath = ""
+	return u.String(), true
+}
+
+// newPrompt opens the "send and modify" prompt, pre-filled with the current
+// method and URL so that a single edit is all it takes.
+func newPrompt(cfg config) *textinput.Model {
+	in := textinput.New()
+	in.Prompt = "Resend as: "
+	in.Cursor.SetMode(cursor.CursorStatic)
+	in.SetValue(cfg.method + " " + cfg.url)
+	in.CursorEnd()
+	in.Focus()
+	return &in
+}
+
+// importer reads a request copied out of another tool and pasted into the prompt.
+type importer struct {
+	name  string                               // What the pasted text is, for the hint.
+	match func(string) bool                    // Whether a line is one of these.
+	parse func(config, string) (config, error) // Turns it into a request.
+}
+
+// importers lists the pasted formats the prompt understands.
+var importers = []importer{
+	{name: "curl command", match: looksLikeCurl, parse: fromCurl},
+	{name: "fetch() call", match: looksLikeFetch, parse: fromFetch},
+}
+
+// importerFor returns the importer for a pasted line, if any.
+func importerFor(line string) (importer, bool) {
+	for _, im := range importers {
+		if im.match(line) {
+			return im, true
+		}
+	}
+	return importer{}, false
+}
+
+// parsePrompt turns the prompt's line into the request it describes: a
+// pasted command, "METHOD URL", or just a URL to keep the method.
+func (m model) parsePrompt(line string) (config, error) {
+	line = strings.TrimSpace(line)
+	if im, ok := importerFor(line); ok {
+		return im.parse(withoutConditions(m.cfg), line)
+	}
+	method, target, ok := strings.Cut(line, " ")
+	if !ok {
+		method, target = m.cfg.method, line
+	}
+	target, err := normalizeURL(strings.TrimSpace(target))
+	if err != nil {
+		return m.cfg, err
+	}
+	cfg := m.cfg
+	if target != m.cfg.url {
+		cfg = withoutConditions(cfg)
+		cfg.url, cfg.path = target, target
+	}
+	cfg.method = strings.ToUpper(method)
+	return cfg, nil
+}
+
+// promptHint offers what tab can do with a pasted line, if anything.
+func promptHint(line string) string {
+	line = strings.TrimSpace(line)
+	if im, ok := importerFor(line); ok {
+		return "Looks like a " + im.name + ": enter imports its method, URL, headers and body • tab review headers first"
+	}
+	if _, target, ok := strings.Cut(line, " "); ok {
+		line = target
+	}
+	if parts, err := splitURL(line); err == nil && len(parts.params) > 0 {
+		return fmt.Sprintf("URL has %d query parameters • tab edit them in the params table", len(parts.params))
+	}
+	return ""
+}
+
+// updatePrompt handles keys while the "send and modify" prompt is open.
+func (m model) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.prompt = nil
+		return m, nil
+	case "enter", "tab":
+		line := m.prompt.Value()
+		if msg.String() == "tab" && promptHint(line) == "" {
+			// Nothing to break out into a table.
+			return m, nil
+		}
+		m.prompt = nil
+		cfg, err := m.parsePrompt(line)
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+		// A new URL or an imported command drops the precondition.
+		if m.cond != nil && cfg.header.Get(m.cond.header) == "" {
+			m.cond = nil
+		}
+		if msg.String() == "enter" {
+			return m.resend(cfg)
+		}
+
+		// Take the request over without sending it, and open the table
+		// its pasted headers or query parameters belong in.
+		m.cfg = cfg
+		if _, ok := importerFor(line); ok {
+			m.kvEditor = newKVEditor(kvHeaders, headerPairs(cfg.header))
+			return m, nil
+		}
+		parts, err := splitURL(cfg.url)
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.kvEditor = newKVEditor(kvParams, parts.params)
+		return m, nil
+	}
+
+	in, cmd := m.prompt.Update(msg)
+	m.prompt = &in
+	return m, cmd
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBumpID(t *testing.T) {
+	tests := []struct {
+		in    string
+		delta int
+		want  string
+		ok    bool
+	}{
+		{"https://a.test/users/42/orders", 1, "https://a.test/users/43/orders", true},
+		{"https://a.test/item/007", 1, "https://a.test/item/008", true},
+		{"https://a.test/item/10?page=3", -1, "https://a.test/item/09?page=3", true},
+		{"https://a.test/item/0", -1, "", false},
+		{"https://a.test/items?id=4", 1, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := bumpID(tt.in, tt.delta)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("bumpID(%q, %d) = %q, %v, want %q, %v", tt.in, tt.delta, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParsePrompt(t *testing.T) {
+	m := model{cfg: config{
+		method: http.MethodGet,
+		url:    "https://a.test/x",
+		header: http.Header{"If-None-Match": {`"v1"`}, "Accept": {"*/*"}},
+	}}
+
+	cfg, err := m.parsePrompt("delete https://a.test/x")
+	if err != nil || cfg.method != http.MethodDelete || cfg.header.Get("If-None-Match") == "" {
+		t.Errorf("same URL = %s %s %v, %v, want the condition kept", cfg.method, cfg.url, cfg.header, err)
+	}
+
+	cfg, err = m.parsePrompt("  https://a.test/y  ")
+	if err != nil || cfg.method != http.MethodGet || cfg.url != "https://a.test/y" || cfg.path != cfg.url {
+		t.Errorf("new URL = %s %s (path %s), %v", cfg.method, cfg.url, cfg.path, err)
+	}
+	if cfg.header.Get("If-None-Match") != "" || cfg.header.Get("Accept") != "*/*" {
+		t.Errorf("new URL headers = %v, want only the condition dropped", cfg.header)
+	}
+
+	cfg, err = m.parsePrompt(`curl -X PUT https://a.test/z -d x=1`)
+	if err != nil || cfg.method != http.MethodPut || string(cfg.body) != "x=1" {
+		t.Errorf("curl = %s %s %q, %v", cfg.method, cfg.url, cfg.body, err)
+	}
+}
+
+func TestPromptHint(t *testing.T) {
+	if got := promptHint("curl https://a.test/"); !strings.HasPrefix(got, "Looks like a curl command") {
+		t.Errorf("curl hint = %q", got)
+	}
+	if got := promptHint("GET https://a.test/?a=1&b"); got != "URL has 2 query parameters • tab edit them in the params table" {
+		t.Errorf("params hint = %q", got)
+	}
+	if got := promptHint("GET https://a.test/"); got != "" {
+		t.Errorf("plain URL hint = %q", got)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+)
+
+// ndjsonTypes are the media types of newline-delimited JSON, one record per line.
+var ndjsonTypes = map[string]bool{
+	"application/x-ndjson":      true,
+	"application/ndjson":        true,
+	"application/jsonl":         true,
+	"application/x-jsonlines":   true,
+	"application/json-seq":      true,
+	"application/stream+json":   true,
+	"application/x-json-stream": true,
+}
+
+// isNDJSON reports whether a response with headers h is newline-delimited JSON.
+func isNDJSON(h http.Header) bool {
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	return ndjsonTypes[mediaType]
+}
+
+// recordBatch and recordEvery bound how records are delivered while they
+// stream in: up to recordBatch at a time, at most every recordEvery.
+const (
+	recordBatch = 200
+	recordEvery = 50 * time.Millisecond
+)
+
+// recordsMsg delivers records that arrived on a streamed body. When done
+// is false, next waits for the following batch; stop abandons the stream,
+// for when its response has been replaced by another one.
+type recordsMsg struct {
+	stream  int64 // Which stream the records belong to.
+	records []string
+	done    bool
+	err     error
+	next    tea.Cmd
+	stop    func()
+}
+
+// lastStream numbers the streams, so records can't end up on the wrong response.
+var lastStream atomic.Int64
+
+// streamRecords returns a command that reads res's body one record per line
+// and delivers the records in batches as they arrive, along with the
+// stream's number and a function that abandons it. release is called once
+// the body is finished with. Like any other body, the records stop at
+// maxBody bytes.
+func streamRecords(res *http.Response, release func()) (tea.Cmd, int64, func()) {
+	id := lastStream.Add(1)
+	batches := make(chan recordsMsg)
+	abandoned := make(chan struct{})
+	release = sync.OnceFunc(release)
+	var once sync.Once
+	stop := func() { once.Do(func() { close(abandoned); release() }) }
+
+	go func() {
+		defer stop()
+		defer res.Body.Close()
+
+		// Lines are read in the background and batched here, so a fast
+		// stream doesn't turn into one message per record.
+		lines := make(chan string)
+		var readErr error
+		go func() {
+			defer close(lines)
+			sc := bufio.NewScanner(res.Body)
+			sc.Buffer(nil, maxBody)
+			total := 0
+			for sc.Scan() {
+				// json-seq puts a record separator before every record.
+				line := strings.TrimSpace(strings.TrimPrefix(sc.Text(), "\x1e"))
+				if line == "" {
+					continue
+				}
+				if total += len(line); total > maxBody {
+					readErr = fmt.Errorf("stopped reading after %d bytes of records", maxBody)
+					return
+				}
+				select {
+				case lines <- line:
+				case <-abandoned:
+					return
+				}
+			}
+			readErr = sc.Err()
+		}()
+
+		// send hands a batch over, unless nobody wants it any more.
+		send := func(msg recordsMsg) bool {
+			msg.stream, msg.stop = id, stop
+			select {
+			case batches <- msg:
+				return true
+			case <-abandoned:
+				return false
+			}
+		}
+
+		var batch []string
+		tick := time.NewTicker(recordEvery)
+		defer tick.Stop()
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					// Let go of the body before saying so, so whoever gets
+					// the last batch finds it released.
+					res.Body.Close()
+					release()
+					send(recordsMsg{records: batch, done: true, err: readErr})
+					return
+				}
+				batch = append(batch, line)
+				if len(batch) < recordBatch {
+					continue
+				}
+			case <-tick.C:
+				if len(batch) == 0 {
+					continue
+				}
+			case <-abandoned:
+				return
+			}
+			if !send(recordsMsg{records: batch}) {
+				return
+			}
+			batch = nil
+		}
+	}()
+	return waitForRecords(batches, abandoned), id, stop
+}
+
+// waitForRecords returns a command that delivers the next batch of records.
+func waitForRecords(batches chan recordsMsg, abandoned chan struct{}) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case msg := <-batches:
+			if !msg.done {
+				msg.next = waitForRecords(batches, abandoned)
+			}
+			return msg
+		case <-abandoned:
+			return nil
+		}
+	}
+}
+
+// finishRecords fills in the body of a streamed response from its records,
+// so that the rest of the program sees it as it would any other body.
+func (r response) finishRecords() response {
+	r.body = []byte(strings.Join(r.records, "\n"))
+	r.rendered = renderBody(kindText, r.body)
+	r.lines = indexLines(r.rendered)
+	return r
+}
+
+// recordsView lists the records of an NDJSON response, one per line, and
+// pretty-prints the selected one. It reads the records from the response,
+// so it keeps up while they are still streaming in.
+type recordsView struct {
+	cursor int  // Selected record.
+	offset int  // First visible record.
+	open   bool // Pretty-print the selected record.
+	follow bool // Keep the newest record selected as more arrive.
+}
+
+// updateRecords handles keys while the records view is open.
+func (m model) updateRecords(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	copied := *m.records
+	v := &copied
+	m.records = v
+	last := max(len(m.res.records)-1, 0)
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "t", "q":
+		m.records = nil
+		return m, nil
+	case "up", "k":
+		v.cursor, v.follow = max(v.cursor-1, 0), false
+	case "down", "j":
+		v.cursor = min(v.cursor+1, last)
+		v.follow = v.cursor == last
+	case "pgup":
+		v.cursor, v.follow = max(v.cursor-tableHeight, 0), false
+	case "pgdown":
+		v.cursor = min(v.cursor+tableHeight, last)
+		v.follow = v.cursor == last
+	case "g", "home":
+		v.cursor, v.follow = 0, false
+	case "G", "end", "f":
+		v.cursor, v.follow = last, true
+	case "enter", " ":
+		v.open = !v.open
+	}
+	v.scroll()
+	return m, nil
+}
+
+// scroll keeps the cursor in view.
+func (v *recordsView) scroll() {
+	if v.cursor < v.offset {
+		v.offset = v.cursor
+	}
+	if v.cursor >= v.offset+tableHeight {
+		v.offset = v.cursor - tableHeight + 1
+	}
+}
+
+// viewRecords renders the visible records, with the selected one
+// pretty-printed underneath when asked.
+func (m model) viewRecords() string {
+	v := m.records
+	records := m.res.records
+
+	var b strings.Builder
+	state := "streaming…"
+	if !m.res.streaming {
+		state = "complete"
+	}
+	fmt.Fprintf(&b, "\nRecords of %s: %d, %s\n\n", m.cfg.url, len(records), state)
+	for i := v.offset; i < min(v.offset+tableHeight, len(records)); i++ {
+		mark := "  "
+		if i == v.cursor {
+			mark = "> "
+		}
+		line := records[i]
+		if m.width > 0 {
+			line = runewidth.Truncate(line, m.width-2, "…")
+		}
+		b.WriteString(mark + line + "\n")
+
+		if i == v.cursor && v.open {
+			var pretty bytes.Buffer
+			if err := json.Indent(&pretty, []byte(records[i]), "    ", "  "); err != nil {
+				fmt.Fprintf(&b, "    (not valid JSON: %v)\n", err)
+			} else {
+				b.WriteString("    " + pretty.String() + "\n")
+			}
+		}
+	}
+	if m.res.streamErr != nil {
+		fmt.Fprintf(&b, "\nThe stream broke off: %v\n", m.res.streamErr)
+	}
+
+	follow := ""
+	if v.follow {
+		follow = " (following)"
+	}
+	fmt.Fprintf(&b, "\nrecord %d of %d%s\n", min(v.cursor+1, len(records)), len(records), follow)
+	b.WriteString("\n↑/↓ select • enter pretty-print • G/f follow newest • esc close\n")
+	return b.String()
+}
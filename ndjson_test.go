@@ -0,0 +1,87 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// readStream runs a stream to its end and returns its records and error.
+func readStream(t *testing.T, next func() tea.Msg) ([]string, error) {
+	t.Helper()
+	var records []string
+	for next != nil {
+		msg, ok := next().(recordsMsg)
+		if !ok {
+			t.Fatal("stream ended without a done batch")
+		}
+		records = append(records, msg.records...)
+		if msg.done {
+			return records, msg.err
+		}
+		next = msg.next
+	}
+	return records, nil
+}
+
+func TestStreamRecords(t *testing.T) {
+	body := "{\"a\":1}\n\n\x1e{\"a\":2}\n  {\"a\":3}  \n"
+	released := false
+	res := &http.Response{Body: io.NopCloser(strings.NewReader(body))}
+	cmd, id, _ := streamRecords(res, func() { released = true })
+
+	records, err := readStream(t, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`{"a":1}`, `{"a":2}`, `{"a":3}`}
+	if strings.Join(records, "|") != strings.Join(want, "|") {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+	if id <= 0 {
+		t.Errorf("stream id = %d", id)
+	}
+	if !released {
+		t.Error("release was not called once the body was read")
+	}
+}
+
+func TestStreamRecordsStopsAtMaxBody(t *testing.T) {
+	line := strings.Repeat("x", 1<<20) + "\n"
+	res := &http.Response{Body: io.NopCloser(strings.NewReader(strings.Repeat(line, 12)))}
+	cmd, _, _ := streamRecords(res, func() {})
+
+	records, err := readStream(t, cmd)
+	if err == nil || !strings.Contains(err.Error(), "stopped reading") {
+		t.Errorf("err = %v, want the size cap", err)
+	}
+	if len(records) != 10 {
+		t.Errorf("kept %d records, want 10", len(records))
+	}
+}
+
+func TestStreamRecordsStop(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	released := make(chan struct{})
+	cmd, _, stop := streamRecords(&http.Response{Body: r}, func() { close(released) })
+
+	stop()
+	<-released
+	if msg := cmd(); msg != nil {
+		t.Errorf("abandoned stream delivered %#v", msg)
+	}
+}
+
+func TestFinishRecords(t *testing.T) {
+	r := response{records: []string{`{"a":1}`, `{"b":2}`}}.finishRecords()
+	if string(r.body) != "{\"a\":1}\n{\"b\":2}" {
+		t.Errorf("body = %q", r.body)
+	}
+	if len(r.lines) == 0 {
+		t.Error("lines were not indexed")
+	}
+}
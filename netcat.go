@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// netcatLines is how many lines of the conversation are on screen.
+const netcatLines = 20
+
+// netcatModel is the Bubble Tea model behind the netcat subcommand: a raw
+// TCP or TLS connection, what was said on it, and a line to type into.
+type netcatModel struct {
+	addr    string
+	conn    net.Conn
+	eol     string // Appended to every line sent: "\r\n", or "\n" with -lf.
+	input   textinput.Model
+	lines   []string // The conversation so far; "> " lines were sent, "< " ones received.
+	partial bool     // The last line received hasn't ended yet.
+	open    bool     // The server hasn't closed its side yet.
+	closed  bool     // We have closed ours, with ctrl+d.
+	err     error
+	data    chan netData
+}
+
+// netData is what one read from the connection returned.
+type netData struct {
+	bytes []byte
+	err   error
+}
+
+// netDataMsg delivers the next chunk the server sent.
+type netDataMsg netData
+
+// runNetcat implements `netcat [-tls] [-insecure] [-lf] host:port`.
+func runNetcat(args []string) error {
+	fs := flag.NewFlagSet("netcat", flag.ExitOnError)
+	useTLS := fs.Bool("tls", false, "speak TLS on the connection")
+	insecure := fs.Bool("insecure", false, "with -tls, accept any certificate")
+	lf := fs.Bool("lf", false, "end lines with \\n rather than \\r\\n")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: netcat [-tls] [-insecure] [-lf] host:port")
+	}
+
+	conn, err := dialRaw(fs.Arg(0), *useTLS, *insecure)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	eol := "\r\n"
+	if *lf {
+		eol = "\n"
+	}
+	_, err = tea.NewProgram(newNetcatModel(fs.Arg(0), conn, eol)).Run()
+	return err
+}
+
+// dialRaw opens a TCP connection to addr, with TLS on top if asked to.
+func dialRaw(addr string, useTLS, insecure bool) (net.Conn, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("%q is not host:port: %w", addr, err)
+	}
+	d := &net.Dialer{Timeout: 10 * time.Second}
+	if !useTLS {
+		return d.Dial("tcp", addr)
+	}
+	return tls.DialWithDialer(d, "tcp", addr, &tls.Config{InsecureSkipVerify: insecure})
+}
+
+// newNetcatModel starts reading conn in the background.
+func newNetcatModel(addr string, conn net.Conn, eol string) netcatModel {
+	in := textinput.New()
+	in.Prompt = "> "
+	in.Cursor.SetMode(cursor.CursorStatic)
+	in.Focus()
+
+	m := netcatModel{addr: addr, conn: conn, eol: eol, input: in, open: true, data: make(chan netData)}
+	go func() {
+		for {
+			buf := make([]byte, 4096)
+			n, err := conn.Read(buf)
+			m.data <- netData{buf[:n], err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return m
+}
+
+// next waits for what the server sends next.
+func (m netcatModel) next() tea.Msg {
+	return netDataMsg(<-m.data)
+}
+
+// Init waits for the server to say something.
+func (m netcatModel) Init() tea.Cmd {
+	return m.next
+}
+
+// Update sends typed lines and shows what comes back.
+func (m netcatModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case netDataMsg:
+		if len(msg.bytes) > 0 {
+			m.lines, m.partial = appendReceived(m.lines, m.partial, msg.bytes)
+		}
+		if msg.err != nil {
+			m.open = false
+			if !errors.Is(msg.err, io.EOF) && !errors.Is(msg.err, net.ErrClosed) {
+				m.err = msg.err
+			}
+			return m, nil
+		}
+		return m, m.next
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			return m, tea.Quit
+
+		// Send the line, with escapes such as \x00 turned into bytes.
+		case "enter":
+			if m.closed {
+				return m, nil
+			}
+			line := unescapeLine(m.input.Value()) + m.eol
+			if _, err := io.WriteString(m.conn, line); err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.lines, m.partial = append(m.lines, "> "+escapeBytes([]byte(line))), false
+			m.input.SetValue("")
+			return m, nil
+
+		// Say we are done sending, like closing netcat's standard input,
+		// and keep listening for the rest of the answer.
+		case "ctrl+d":
+			if cw, ok := m.conn.(interface{ CloseWrite() error }); ok && !m.closed {
+				if err := cw.CloseWrite(); err != nil {
+					m.err = err
+				}
+				m.closed = true
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// View shows the end of the conversation and the line being typed.
+func (m netcatModel) View() string {
+	var b strings.Builder
+	state := "connected"
+	switch {
+	case !m.open:
+		state = "closed by the server"
+	case m.closed:
+		state = "sending closed, still listening"
+	}
+	fmt.Fprintf(&b, "\n%s (%s)\n\n", m.addr, state)
+	for _, line := range m.lines[max(len(m.lines)-netcatLines, 0):] {
+		b.WriteString(line + "\n")
+	}
+	if m.err != nil {
+		fmt.Fprintf(&b, "\nWe had some trouble: %v\n", m.err)
+	}
+	if !m.closed {
+		b.WriteString("\n" + m.input.View() + "\n")
+	}
+	b.WriteString("\nenter send • ctrl+d stop sending • \\r \\n \\t \\xNN escapes • esc quit\n")
+	return b.String()
+}
+
+// appendReceived adds data to the conversation as "< " lines, one per
+// line the server sent. partial says whether the last line is still open,
+// to be carried on by data; the returned one says the same afterwards.
+func appendReceived(lines []string, partial bool, data []byte) ([]string, bool) {
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		chunk := data
+		if end >= 0 {
+			chunk = data[:end+1]
+		}
+		data = data[len(chunk):]
+		if partial {
+			lines[len(lines)-1] += escapeBytes(chunk)
+		} else {
+			lines = append(lines, "< "+escapeBytes(chunk))
+		}
+		partial = end < 0
+	}
+	return lines, partial
+}
+
+// escapeBytes shows data as text: printable characters as they are, and
+// everything else, line ends included, as Go escapes, so that a stray \r
+// or NUL is plain to see.
+func escapeBytes(data []byte) string {
+	var b strings.Builder
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, `\x%02x`, data[0])
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\t' || strconv.IsPrint(r):
+			b.WriteRune(r)
+		default:
+			b.WriteString(strings.Trim(strconv.QuoteRune(r), "'"))
+		}
+		data = data[size:]
+	}
+	return b.String()
+}
+
+// unescapeLine turns the escapes \r, \n, \t, \\ and \xNN in a typed line
+// into the bytes they stand for. Anything else is sent as typed.
+func unescapeLine(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case 'r':
+			b.WriteByte('\r')
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case '\\':
+			b.WriteByte('\\')
+		case 'x':
+			if v, err := strconv.ParseUint(s[i+2:min(i+4, len(s))], 16, 8); err == nil && i+4 <= len(s) {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+			b.WriteByte('\\')
+			continue
+		default:
+			b.WriteByte('\\')
+			continue
+		}
+		i++
+	}
+	return b.String()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestEscapeBytes(t *testing.T) {
+	got := escapeBytes([]byte("OK\r\n\x00\tä\\\xff"))
+	if want := `OK\r\n\x00` + "\tä" + `\\\xff`; got != want {
+		t.Errorf("escapeBytes = %q, want %q", got, want)
+	}
+}
+
+func TestUnescapeLine(t *testing.T) {
+	tests := map[string]string{
+		`PING`:           "PING",
+		`a\r\nb\tc`:      "a\r\nb\tc",
+		`\x00\x7f\\`:     "\x00\x7f\\",
+		`\q \x \xZZ \x4`: `\q \x \xZZ \x4`,
+		`trailing\`:      `trailing\`,
+	}
+	for in, want := range tests {
+		if got := unescapeLine(in); got != want {
+			t.Errorf("unescapeLine(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestAppendReceived(t *testing.T) {
+	lines, partial := appendReceived([]string{"> PING\\r\\n"}, false, []byte("+PO"))
+	lines, partial = appendReceived(lines, partial, []byte("NG\r\n:1\r\n:2"))
+	want := []string{`> PING\r\n`, `< +PONG\r\n`, `< :1\r\n`, `< :2`}
+	if !reflect.DeepEqual(lines, want) || !partial {
+		t.Errorf("lines = %q, partial = %v, want %q, true", lines, partial, want)
+	}
+}
+
+func TestNetcatConversation(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		line, _ := bufio.NewReader(c).ReadString('\n')
+		c.Write([]byte("you said " + line))
+	}()
+
+	conn, err := dialRaw(ln.Addr().String(), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var m tea.Model = newNetcatModel(ln.Addr().String(), conn, "\r\n")
+	for _, r := range "hi\\x21" {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	// Read until the server hangs up.
+	next := m.Init()
+	for next != nil {
+		m, next = m.Update(next())
+	}
+	nc := m.(netcatModel)
+	want := []string{`> hi!\r\n`, `< you said hi!\r\n`}
+	if !reflect.DeepEqual(nc.lines, want) {
+		t.Errorf("lines = %q, want %q", nc.lines, want)
+	}
+	if nc.open || nc.err != nil {
+		t.Errorf("open = %v, err = %v, want closed cleanly", nc.open, nc.err)
+	}
+	if !strings.Contains(nc.View(), "closed by the server") {
+		t.Errorf("view:\n%s", nc.View())
+	}
+}
+
+func TestDialRawNeedsPort(t *testing.T) {
+	if _, err := dialRaw("example.com", false, false); err == nil {
+		t.Error("dialRaw accepted an address without a port")
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxCannedBody caps the bodies kept for offline mode, so the file stays
+// small enough to load at every request.
+const maxCannedBody = 1 << 20
+
+// cannedResponse is an answer kept for offline mode, one per request in the
+// offline file. It is written to be edited by hand too, to give a request
+// an example answer it never got.
+type cannedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+	Base64 bool        `json:"base64,omitempty"` // Body is base64, as it isn't text.
+	Saved  time.Time   `json:"saved"`            // Zero for an answer written by hand.
+}
+
+// cannedMu keeps saves to the offline file from overwriting one another.
+var cannedMu sync.Mutex
+
+// defaultOfflineFile is where answers are kept for offline mode unless
+// -offline-file says otherwise.
+func defaultOfflineFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "httpwizard", "offline.json")
+}
+
+// loadCanned reads the offline file at path. A file that isn't there yet
+// holds no answers.
+func loadCanned(path string) (map[string]cannedResponse, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]cannedResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	canned := map[string]cannedResponse{}
+	if err := json.Unmarshal(b, &canned); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return canned, nil
+}
+
+// keepCanned saves the answer to a request in the offline file, in place of
+// the one it had. Bodies past maxCannedBody aren't kept, and neither are
+// the cookies and credentials the server set.
+func keepCanned(path, method, target string, status int, header http.Header, body []byte) error {
+	if len(body) > maxCannedBody {
+		return nil
+	}
+	kept := http.Header{}
+	for name, values := range header {
+		if !isSecret(name) && !strings.HasPrefix(http.CanonicalHeaderKey(name), "Set-Cookie") {
+			kept[name] = values
+		}
+	}
+	c := cannedResponse{Status: status, Header: kept, Body: string(body), Saved: time.Now().UTC()}
+	if !utf8.Valid(body) {
+		c.Body, c.Base64 = base64.StdEncoding.EncodeToString(body), true
+	}
+
+	cannedMu.Lock()
+	defer cannedMu.Unlock()
+	canned, err := loadCanned(path)
+	if err != nil {
+		return err
+	}
+	canned[historyKey(method, target)] = c
+	b, err := json.MarshalIndent(canned, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(b, '\n'))
+}
+
+// answerOffline answers the request from the offline file instead of the
+// network, the way send would have shown the answer it was saved from.
+// The answer is kept under target, the URL as it was asked for.
+func answerOffline(cfg config, target string) tea.Msg {
+	if cfg.offlineFile == "" {
+		return errMsg{errors.New("offline: there is no -offline-file to answer from")}
+	}
+	canned, err := loadCanned(cfg.offlineFile)
+	if err != nil {
+		return errMsg{fmt.Errorf("offline: %w", err)}
+	}
+	c, ok := canned[historyKey(cfg.method, target)]
+	if !ok {
+		return errMsg{fmt.Errorf("offline: no answer kept for %s %s; send it once online, or add one to %s", cfg.method, target, cfg.offlineFile)}
+	}
+	body := []byte(c.Body)
+	if c.Base64 {
+		if body, err = base64.StdEncoding.DecodeString(c.Body); err != nil {
+			return errMsg{fmt.Errorf("offline: the body kept for %s %s: %w", cfg.method, target, err)}
+		}
+	}
+	if c.Header == nil {
+		c.Header = http.Header{}
+	}
+
+	r := describeBody(cfg, c.Header, body)
+	r.status, r.offline = c.Status, &c.Saved
+	r.final, _ = url.Parse(cfg.url)
+	r.requestID = cfg.header.Get("X-Request-ID")
+	if cfg.schema != nil {
+		checked := body
+		if r.asJSON != nil {
+			checked = r.asJSON
+		}
+		r.violations = cfg.schema.validateBody(checked)
+	}
+	return responseMsg(r)
+}
+
+// runOffline is the palette's `offline`: it switches offline mode on or
+// off, and sends the request again the new way. From then on, answers are
+// kept for it.
+func runOffline(m model, _ []string) (tea.Model, tea.Cmd) {
+	m.cfg.keepOffline = true
+	cfg := m.cfg
+	cfg.offline = !cfg.offline
+	return m.resend(cfg)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOffline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Header().Set("X-Auth-Token", "t0ken")
+		w.Write([]byte(`{"name": "Rex"}`))
+	}))
+	file := filepath.Join(t.TempDir(), "offline.json")
+	cfg := config{method: "GET", url: srv.URL + "/pets/1", offlineFile: file}
+	send(cfg)
+	if _, err := os.Stat(file); err == nil {
+		t.Fatal("an answer was kept without asking")
+	}
+	cfg.keepOffline = true
+	if res, ok := send(cfg).(responseMsg); !ok || res.offline != nil {
+		t.Fatalf("online send = %+v", res)
+	}
+	srv.Close()
+	if b, _ := os.ReadFile(file); strings.Contains(string(b), "abc") || strings.Contains(string(b), "t0ken") {
+		t.Errorf("credentials were kept:\n%s", b)
+	}
+
+	cfg.offline = true
+	res, ok := send(cfg).(responseMsg)
+	if !ok || res.status != 200 || string(res.body) != `{"name": "Rex"}` || res.kind != kindJSON || res.offline == nil || res.offline.IsZero() {
+		t.Fatalf("offline send = %+v", res)
+	}
+
+	cfg.url = srv.URL + "/pets/2"
+	if e, ok := send(cfg).(errMsg); !ok || !strings.Contains(e.err.Error(), "no answer kept for GET "+cfg.url) {
+		t.Errorf("unkept request = %+v", e)
+	}
+}
+
+func TestOfflineByHand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "offline.json")
+	os.WriteFile(file, []byte(`{"POST https://api.example.com/orders": {"status": 201, "body": "created"}}`), 0o644)
+	res, ok := answerOffline(config{method: "POST", url: "https://api.example.com/orders", offlineFile: file}, "https://api.example.com/orders").(responseMsg)
+	if !ok || res.status != 201 || string(res.body) != "created" || res.offline == nil || !res.offline.IsZero() {
+		t.Errorf("answer = %+v", res)
+	}
+}
+
+func TestKeepCannedBinary(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "offline.json")
+	if err := keepCanned(file, "GET", "https://example.com/a.bin", 200, nil, []byte{0xff, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	canned, err := loadCanned(file)
+	if c := canned["GET https://example.com/a.bin"]; err != nil || !c.Base64 || c.Body != "/wA=" {
+		t.Errorf("kept %+v, %v", c, err)
+	}
+}
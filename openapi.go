@@ -0,0 +1,284 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"mime"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// openAPISpec is an OpenAPI 3 or Swagger 2 description of an API, in JSON,
+// that a collection run checks live answers against. An answer whose body
+// breaks the schema documented for its status fails; a status the
+// operation doesn't document, a request that matches no operation and
+// fields the schema doesn't mention are warnings, since the spec may simply
+// be behind.
+type openAPISpec struct {
+	doc        any
+	basePath   string // Path of the server URL, e.g. /v1, which paths are under.
+	operations []apiOperation
+}
+
+// apiOperation is one method of one path of a spec.
+type apiOperation struct {
+	method    string
+	path      string         // As the spec writes it, e.g. /pets/{id}.
+	match     *regexp.Regexp // Matches request paths, with any value for a {parameter}.
+	responses map[string]any
+}
+
+// pathParam matches a {parameter} in a path template.
+var pathParam = regexp.MustCompile(`\\\{[^/]+?\\\}`)
+
+// loadOpenAPI reads the spec at path.
+func loadOpenAPI(path string) (*openAPISpec, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w (YAML has to be converted to JSON first)", path, err)
+	}
+	root, ok := doc.(map[string]any)
+	if !ok || (root["openapi"] == nil && root["swagger"] == nil) {
+		return nil, fmt.Errorf("%s is not an OpenAPI or Swagger spec", path)
+	}
+	spec := &openAPISpec{doc: doc}
+
+	// Swagger 2 gives a basePath; OpenAPI 3 puts it in the server URL.
+	if base, ok := root["basePath"].(string); ok {
+		spec.basePath = base
+	}
+	if servers, ok := root["servers"].([]any); ok && len(servers) > 0 {
+		if server, ok := servers[0].(map[string]any); ok {
+			if s, ok := server["url"].(string); ok {
+				if u, err := url.Parse(s); err == nil {
+					spec.basePath = u.Path
+				}
+			}
+		}
+	}
+	spec.basePath = strings.TrimSuffix(spec.basePath, "/")
+
+	paths, _ := root["paths"].(map[string]any)
+	for _, p := range slices.Sorted(maps.Keys(paths)) {
+		item, _ := paths[p].(map[string]any)
+		expr := pathParam.ReplaceAllString(regexp.QuoteMeta(p), `[^/]+`)
+		re, err := regexp.Compile("^" + expr + "/?$")
+		if err != nil {
+			return nil, fmt.Errorf("%s: path %s: %w", path, p, err)
+		}
+		for _, method := range slices.Sorted(maps.Keys(item)) {
+			op, ok := item[method].(map[string]any)
+			if !ok || method == "parameters" {
+				continue
+			}
+			responses, _ := op["responses"].(map[string]any)
+			spec.operations = append(spec.operations, apiOperation{method: strings.ToUpper(method), path: p, match: re, responses: responses})
+		}
+	}
+	return spec, nil
+}
+
+// operation finds the operation a request is for. Paths without
+// parameters win over those with, as OpenAPI has it.
+func (s *openAPISpec) operation(method, target string) (apiOperation, bool) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return apiOperation{}, false
+	}
+	p, ok := strings.CutPrefix(u.Path, s.basePath)
+	if !ok {
+		return apiOperation{}, false
+	}
+	var found []apiOperation
+	for _, op := range s.operations {
+		if op.method == method && op.match.MatchString(cmp.Or(p, "/")) {
+			found = append(found, op)
+		}
+	}
+	if len(found) == 0 {
+		return apiOperation{}, false
+	}
+	slices.SortStableFunc(found, func(a, b apiOperation) int {
+		return strings.Count(a.path, "{") - strings.Count(b.path, "{")
+	})
+	return found[0], true
+}
+
+// check checks the answer r got against the spec, failing it if the body
+// breaks the documented schema and warning of what the spec doesn't
+// document. Requests that failed already, or got no answer, are left
+// alone. On a nil *openAPISpec it does nothing, so that callers needn't
+// check whether a spec was given.
+func (s *openAPISpec) check(r *runResult) {
+	if s == nil || !r.passed() || r.status == 0 {
+		return
+	}
+	op, ok := s.operation(r.method, r.url)
+	if !ok {
+		r.warnings = append(r.warnings, fmt.Sprintf("%s %s matches no operation of the spec", r.method, r.url))
+		return
+	}
+	name := op.method + " " + op.path
+	res, ok := op.responses[strconv.Itoa(r.status)]
+	if !ok {
+		res, ok = op.responses[strconv.Itoa(r.status/100)+"XX"]
+	}
+	if !ok {
+		res, ok = op.responses["default"]
+		if ok {
+			r.warnings = append(r.warnings, fmt.Sprintf("status %d is only covered by the default response of %s", r.status, name))
+		}
+	}
+	if !ok {
+		r.warnings = append(r.warnings, fmt.Sprintf("status %d is not documented for %s", r.status, name))
+		return
+	}
+
+	schema := s.responseSchema(res, r.contentType)
+	if schema == nil || len(r.body) == 0 {
+		return
+	}
+	sch := &jsonSchema{doc: s.doc, schema: schema}
+	if violations := sch.validateBody(r.body); len(violations) > 0 {
+		r.failure = fmt.Sprintf("the body breaks the schema of %s for %d: %s", name, r.status, describeViolations(violations, "; "))
+		return
+	}
+	if v, err := decodeJSON(r.body); err == nil {
+		var extra []string
+		s.undocumented(&extra, "#", schema, v, 0)
+		for _, p := range extra {
+			r.warnings = append(r.warnings, p+" is not in the spec")
+		}
+	}
+}
+
+// responseSchema is the schema a documented response gives its body in
+// contentType: a Swagger 2 response has one, an OpenAPI 3 one has one per
+// media type, of which the closest to contentType is taken.
+func (s *openAPISpec) responseSchema(res any, contentType string) any {
+	res = s.deref(res, 0)
+	resp, ok := res.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if schema, ok := resp["schema"]; ok {
+		return schema
+	}
+	content, ok := resp["content"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	mt, _, _ := mime.ParseMediaType(contentType)
+	major, _, _ := strings.Cut(mt, "/")
+	for _, key := range []string{mt, major + "/*", "*/*"} {
+		if media, ok := content[key].(map[string]any); ok {
+			return media["schema"]
+		}
+	}
+	// A server that leaves out or gets the content type wrong is still
+	// held to the JSON schema, if that is what the spec documents.
+	for _, key := range slices.Sorted(maps.Keys(content)) {
+		if strings.Contains(key, "json") {
+			if media, ok := content[key].(map[string]any); ok {
+				return media["schema"]
+			}
+		}
+	}
+	return nil
+}
+
+// deref follows a $ref within the spec, if v is one.
+func (s *openAPISpec) deref(v any, depth int) any {
+	m, ok := v.(map[string]any)
+	if !ok || depth >= maxRefDepth {
+		return v
+	}
+	ref, ok := m["$ref"].(string)
+	if !ok {
+		return v
+	}
+	pointer, _ := strings.CutPrefix(ref, "#")
+	target, err := resolvePointer(s.doc, pointer)
+	if err != nil {
+		return v
+	}
+	return s.deref(target, depth+1)
+}
+
+// undocumented adds to out the pointers of the fields of v that schema
+// neither lists nor allows with additionalProperties.
+func (s *openAPISpec) undocumented(out *[]string, pointer string, schema, v any, depth int) {
+	if depth >= maxRefDepth {
+		return
+	}
+	sch, ok := s.deref(schema, 0).(map[string]any)
+	if !ok {
+		return
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		props, open := s.properties(sch, 0)
+		for _, name := range slices.Sorted(maps.Keys(v)) {
+			child := pointer + "/" + pointerToken(name)
+			prop, ok := props[name]
+			switch {
+			case ok:
+				s.undocumented(out, child, prop, v[name], depth+1)
+			case !open:
+				*out = append(*out, child)
+			}
+		}
+	case []any:
+		if items, ok := sch["items"]; ok {
+			for i, el := range v {
+				s.undocumented(out, pointer+"/"+strconv.Itoa(i), items, el, depth+1)
+			}
+		}
+	}
+}
+
+// properties gathers the properties an object schema documents, through
+// allOf, and says whether it is open to others: it allows additional
+// properties, or combines schemas in a way this can't follow.
+func (s *openAPISpec) properties(sch map[string]any, depth int) (map[string]any, bool) {
+	props := map[string]any{}
+	open := false
+	if _, ok := sch["additionalProperties"]; ok && sch["additionalProperties"] != false {
+		open = true
+	}
+	if _, ok := sch["anyOf"]; ok {
+		open = true
+	}
+	if _, ok := sch["oneOf"]; ok {
+		open = true
+	}
+	if p, ok := sch["properties"].(map[string]any); ok {
+		maps.Copy(props, p)
+	}
+	if all, ok := sch["allOf"].([]any); ok && depth < maxRefDepth {
+		for _, sub := range all {
+			sub, ok := s.deref(sub, 0).(map[string]any)
+			if !ok {
+				continue
+			}
+			p, o := s.properties(sub, depth+1)
+			maps.Copy(props, p)
+			open = open || o
+		}
+	}
+	// A schema that says nothing of its properties documents none of them
+	// one way or the other.
+	if _, ok := sch["properties"]; !ok && sch["allOf"] == nil {
+		open = true
+	}
+	return props, open
+}
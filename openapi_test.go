@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const petstoreSpec = `{
+  "openapi": "3.0.3",
+  "servers": [{"url": "https://api.example.com/v1"}],
+  "paths": {
+    "/pets": {
+      "get": {"responses": {"200": {"content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Pet"}}}}}}}
+    },
+    "/pets/{id}": {
+      "get": {"responses": {
+        "200": {"$ref": "#/components/responses/Pet"},
+        "default": {"description": "an error"}
+      }}
+    },
+    "/pets/mine": {
+      "get": {"responses": {"204": {"description": "no pets"}}}
+    }
+  },
+  "components": {
+    "responses": {"Pet": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}}}},
+    "schemas": {
+      "Pet": {"allOf": [
+        {"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}},
+        {"properties": {"tags": {"type": "object", "additionalProperties": true}}}
+      ]}
+    }
+  }
+}`
+
+func TestOpenAPIOperation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openapi.json")
+	os.WriteFile(path, []byte(petstoreSpec), 0o644)
+	spec, err := loadOpenAPI(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for target, want := range map[string]string{
+		"https://api.example.com/v1/pets":      "/pets",
+		"https://api.example.com/v1/pets/7":    "/pets/{id}",
+		"https://api.example.com/v1/pets/mine": "/pets/mine",
+		"https://api.example.com/v1/pets/7/x":  "",
+		"https://api.example.com/pets":         "",
+	} {
+		op, ok := spec.operation("GET", target)
+		if ok != (want != "") || op.path != want {
+			t.Errorf("%s matched %q, want %q", target, op.path, want)
+		}
+	}
+	if _, err := loadOpenAPI("openapi_test.go"); err == nil {
+		t.Error("Go source loaded as a spec")
+	}
+}
+
+func TestOpenAPICheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/pets":
+			w.Write([]byte(`[{"name": "Rex", "age": 3, "tags": {"any": 1}}, {"name": 7}]`))
+		case "/v1/pets/1":
+			w.Write([]byte(`{"name": "Rex", "owner": "me"}`))
+		case "/v1/pets/2":
+			w.WriteHeader(http.StatusNotFound)
+		case "/v1/pets/mine":
+			w.WriteHeader(http.StatusTeapot)
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "openapi.json"), []byte(petstoreSpec), 0o644)
+	spec, err := loadOpenAPI(filepath.Join(dir, "openapi.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(path string) runResult {
+		r := sendSaved(config{bodyFormat: "json"}, collectionRequest{URL: srv.URL + path, Expect: expectation{Status: 0}})
+		r.failure = ""
+		spec.check(&r)
+		return r
+	}
+	if r := run("/v1/pets"); !strings.HasPrefix(r.failure, "the body breaks the schema of GET /pets for 200: #/1/name: expected a string") {
+		t.Errorf("failure = %q", r.failure)
+	}
+	if r := run("/v1/pets/1"); r.failure != "" || !slices.Equal(r.warnings, []string{"#/owner is not in the spec"}) {
+		t.Errorf("an undocumented field: %+v", r)
+	}
+	if r := run("/v1/pets/2"); !slices.Equal(r.warnings, []string{"status 404 is only covered by the default response of GET /pets/{id}"}) {
+		t.Errorf("a default response: %q", r.warnings)
+	}
+	if r := run("/v1/pets/mine"); !slices.Equal(r.warnings, []string{"status 418 is not documented for GET /pets/mine"}) {
+		t.Errorf("an undocumented status: %q", r.warnings)
+	}
+	if r := run("/v1/cats"); len(r.warnings) != 1 || !strings.Contains(r.warnings[0], "matches no operation") {
+		t.Errorf("an unknown path: %q", r.warnings)
+	}
+	if got := describeResult(runResult{name: "x", method: "GET", url: "/a", status: 200, warnings: []string{"w"}}); got != "✓ x  GET /a → 200\n    ⚠ w" {
+		t.Errorf("describeResult = %q", got)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// paletteCommand is a utility that is run by typing its name into the
+// command palette rather than by a key of its own.
+type paletteCommand struct {
+	name  string // What to type, e.g. "ping".
+	usage string // Its arguments, e.g. "[count]".
+	about string // One line on what it does.
+	run   func(m model, args []string) (tea.Model, tea.Cmd)
+	sends bool // It sends the request itself, so it may need confirming.
+}
+
+// paletteCommands lists the palette's commands, in the order they are offered.
+var paletteCommands = []paletteCommand{
+	{name: "dns", about: "look the host up in DNS", run: func(m model, _ []string) (tea.Model, tea.Cmd) {
+		m.job = "Looking up the host"
+		return m, lookupDNS(m.cfg)
+	}},
+	{name: "ping", usage: "[count]", about: "ICMP ping the host, where ping sockets are permitted", run: runPing},
+	{name: "port", usage: "[port] [count]", about: "time TCP connections to a port of the host, the URL's by default", run: runPortCheck},
+	{name: "hsts", about: "check that plain HTTP redirects to HTTPS, the HSTS policy, and preload eligibility", run: runHTTPSPolicy},
+	{name: "tls", usage: "[port]", about: "grade the server's TLS: protocols, cipher suites, certificate, OCSP", run: runTLSScan},
+	{name: "history", usage: "[filter]", about: "browse the history, filtered by words and by method:, status:, host:, since:, until: and on:, grouped by host or day", run: runHistory},
+	{name: "slo", usage: "[[latency] percent]", about: "set the request's SLO, e.g. 300ms 99.5, and chart how its history meets it", run: runSLO},
+	{name: "watch", usage: "[interval]", about: "send the request every few seconds, with a live latency histogram", run: runWatch, sends: true},
+	{name: "load", usage: "[requests] [concurrency]", about: "send the request many times at once, with a live latency histogram", run: runLoad, sends: true},
+	{name: "compare", usage: "[env-a] env-b [samples]", about: "send the request to two environments at once, and compare their answers side by side; with samples, their latency and errors as a canary", run: runCompare},
+	{name: "cache", about: "explain where and how long the answer may be cached, and what in its cache headers contradicts itself", run: runCache},
+	{name: "edge", about: "decode the CDN headers of the answer: cache hit or miss, the POP that served it, and origin timings", run: runEdge},
+	{name: "cookies", about: "break down each Set-Cookie of the answer, and flag what browsers would reject or regret", run: runCookies},
+	{name: "unfurl", usage: "[url]", about: "fetch a page, the request's by default, and show the card a link to it unfurls into: title, description, image", run: runUnfurl},
+	{name: "csp", about: "set out the answer's Content-Security-Policy a directive and a source to a line, and flag what weakens it", run: runCSP},
+	{name: "compression", about: "send the request offering gzip, and report the body's size on the wire and decoded, and the ratio", run: runCompression, sends: true},
+	{name: "discover", usage: "[wordlist] [rate]", about: "probe common paths, or a wordlist's, under the URL, a few a second, and list those that exist", run: runDiscover},
+	{name: "sweep", usage: "[field=]values [path]", about: "send the request once per value, e.g. 1..20 or a,b,c, in a field or the path's ID, and tabulate the answers", run: runSweep, sends: true},
+	{name: "idempotency", usage: "[sends]", about: "send the request a few times without an Idempotency-Key and with one, and compare the answers", run: runIdempotency, sends: true},
+	{name: "vars", about: "list the variables in scope, where each comes from, and the request as it will be sent", run: runVariables},
+	{name: "capture", usage: "name [$.path | header]", about: "keep a value of the answer as a variable, e.g. capture token $.access_token", run: runCapture},
+	{name: "bundle", usage: "[file]", about: "write the request as a signed bundle, without credentials, for someone else to send with theirs", run: runBundle},
+	{name: "offline", about: "switch offline mode on or off: answer from the answers kept earlier, not the network", run: runOffline},
+	{name: "race", usage: "[requests]", about: "send many copies of the request at the same instant, and count how they were answered", run: runRace, sends: true},
+	{name: "fuzz", usage: "[field…]", about: "send the request with odd values in its parameters and body fields, and list server errors and slow answers", run: runFuzz, sends: true},
+	{name: "session", usage: "[save NAME | open NAME | delete NAME]", about: "save the open tabs as a named session, switch to one, delete one, or list them", run: runSession},
+	{name: "docker", usage: "[words]", about: "pick a port a running Docker container publishes, of those matching words, and put it in the resend prompt", run: runDocker},
+	{name: "mdns", usage: "[_type._tcp…] [words]", about: "pick a service on the LAN, of _http._tcp and _https._tcp or the types given, matching words, and put it in the resend prompt", run: runMDNS},
+	{name: "instances", usage: "[service] [words]", about: "pick the healthy instance of the request's service, or the one named, that the environment's registry sends it to", run: runInstances},
+	{name: "trash", usage: "[restore [N] | purge [age]]", about: "list what was unstarred or deleted, put the latest or the Nth back, or empty the trash", run: runTrash},
+	{name: "log", usage: "[level]", about: "show or hide the log of what the program did, e.g. log warn for warnings and errors only", run: runLog},
+	{name: "ua", usage: "[profile | off]", about: "send the request again with the headers of Chrome, Safari, Googlebot, a phone and so on, or list them", run: runUA},
+	{name: "keylog", usage: "[file | off]", about: "log the TLS keys of new connections to a file, a temporary one by default, and say how to capture and decrypt the request's traffic", run: runKeyLog},
+	{name: "trace", usage: "[max-hops]", about: "show the routers on the way to the host, with a raw socket", run: runTrace},
+}
+
+// newPalette opens the command palette, empty.
+func newPalette() *textinput.Model {
+	in := textinput.New()
+	in.Prompt = ": "
+	in.Cursor.SetMode(cursor.CursorStatic)
+	in.Focus()
+	return &in
+}
+
+// findCommand returns the command a palette line runs, and its arguments.
+// A unique prefix of a command's name is enough.
+func findCommand(line string) (paletteCommand, []string, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return paletteCommand{}, nil, fmt.Errorf("type a command: %s", paletteNames())
+	}
+	matches := matchCommands(fields[0])
+	for _, c := range matches {
+		if c.name == fields[0] {
+			return c, fields[1:], nil
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return paletteCommand{}, nil, fmt.Errorf("no command %q; there are %s", fields[0], paletteNames())
+	case 1:
+		return matches[0], fields[1:], nil
+	}
+	return paletteCommand{}, nil, fmt.Errorf("%q could be any of %s", fields[0], commandNames(matches))
+}
+
+// matchCommands returns the commands whose name starts with prefix.
+func matchCommands(prefix string) []paletteCommand {
+	var found []paletteCommand
+	for _, c := range paletteCommands {
+		if strings.HasPrefix(c.name, prefix) {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// paletteNames lists the names of every palette command.
+func paletteNames() string {
+	return commandNames(paletteCommands)
+}
+
+// commandNames joins the names of cmds.
+func commandNames(cmds []paletteCommand) string {
+	names := make([]string, len(cmds))
+	for i, c := range cmds {
+		names[i] = c.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// paletteHint lists the commands a half-typed line could be, with their usage.
+func paletteHint(line string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+	var b strings.Builder
+	for _, c := range matchCommands(name) {
+		fmt.Fprintf(&b, "\n  %-22s %s", strings.TrimSpace(c.name+" "+c.usage), c.about)
+	}
+	return b.String()
+}
+
+// updatePalette handles keys while the command palette is open.
+func (m model) updatePalette(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.palette = nil
+		return m, nil
+	case "enter":
+		line := m.palette.Value()
+		m.palette = nil
+		c, args, err := findCommand(line)
+		if err != nil {
+			// A typo shouldn't wipe the response off the screen.
+			m.report, m.cursor = &reportMsg{title: "Command", body: err.Error()}, 0
+			return m, nil
+		}
+		if c.sends {
+			return m.guarded(m.cfg, fmt.Sprintf("%s of %s %s", c.name, m.cfg.method, m.cfg.url), func(m model) (tea.Model, tea.Cmd) {
+				return c.run(m, args)
+			})
+		}
+		return c.run(m, args)
+	}
+
+	in, cmd := m.palette.Update(msg)
+	m.palette = &in
+	return m, cmd
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestFindCommand(t *testing.T) {
+	c, args, err := findCommand("  port 8080 3 ")
+	if err != nil || c.name != "port" || strings.Join(args, " ") != "8080 3" {
+		t.Errorf("findCommand = %s %q, %v", c.name, args, err)
+	}
+	if c, _, err := findCommand("pi"); err != nil || c.name != "ping" {
+		t.Errorf("a unique prefix found %s, %v", c.name, err)
+	}
+	if _, _, err := findCommand("p"); err == nil || !strings.Contains(err.Error(), "ping, port") {
+		t.Errorf("an ambiguous prefix: %v", err)
+	}
+	if _, _, err := findCommand("frobnicate"); err == nil || !strings.Contains(err.Error(), "no command") {
+		t.Errorf("an unknown command: %v", err)
+	}
+	if _, _, err := findCommand(""); err == nil {
+		t.Error("an empty line found a command")
+	}
+}
+
+func TestPaletteHint(t *testing.T) {
+	hint := paletteHint("po")
+	if !strings.Contains(hint, "port [port] [count]") || strings.Contains(hint, "ping") {
+		t.Errorf("hint for po:%s", hint)
+	}
+	if hint := paletteHint(""); strings.Count(hint, "\n") != len(paletteCommands) {
+		t.Errorf("an empty line should offer every command:%s", hint)
+	}
+}
+
+func TestPaletteKeys(t *testing.T) {
+	m := model{res: response{status: 200}}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(":")})
+	m = next.(model)
+	if m.palette == nil || !m.modal() {
+		t.Fatal(": didn't open the palette")
+	}
+	for _, r := range "nope" {
+		next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = next.(model)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if m.palette != nil {
+		t.Error("enter left the palette open")
+	}
+	if m.err != nil || m.report == nil || !strings.Contains(m.report.body, `no command "nope"`) {
+		t.Errorf("err = %v, report = %+v", m.err, m.report)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+)
+
+// The palette's docker and mdns find the services running nearby and list
+// them in the same picker, where enter puts the one picked into the resend
+// prompt, for a last look before it is sent.
+
+// endpoint is a service the picker lists.
+type endpoint struct {
+	label  string
+	url    func(current string) string // Where a request goes, given the one on screen.
+	picked func()                      // What else picking it does; nil for nothing.
+}
+
+// endpointPicker is the picker's state.
+type endpointPicker struct {
+	title     string  // What is listed, e.g. "Docker containers".
+	asking    string  // What is shown while the endpoints are found.
+	none      string  // What is shown when there are none.
+	find      tea.Cmd // Finds the endpoints, again with r.
+	filter    string  // Words the endpoints listed have to match.
+	endpoints []endpoint
+	cursor    int
+	offset    int
+	loading   bool
+	err       error
+}
+
+// endpointsMsg carries the endpoints the picker titled title found.
+type endpointsMsg struct {
+	title     string
+	endpoints []endpoint
+	err       error
+}
+
+// openPicker opens p and starts finding its endpoints.
+func (m model) openPicker(p endpointPicker, words []string) (tea.Model, tea.Cmd) {
+	p.filter, p.loading = strings.ToLower(strings.Join(words, " ")), true
+	m.picker = &p
+	return m, p.find
+}
+
+// showEndpoints takes in the endpoints found, those that match.
+func (m model) showEndpoints(msg endpointsMsg) (tea.Model, tea.Cmd) {
+	if m.picker == nil || m.picker.title != msg.title {
+		return m, nil
+	}
+	p := *m.picker
+	p.loading, p.err, p.endpoints = false, msg.err, nil
+	p.cursor, p.offset = 0, 0
+	for _, e := range msg.endpoints {
+		if strings.Contains(strings.ToLower(e.label), p.filter) {
+			p.endpoints = append(p.endpoints, e)
+		}
+	}
+	m.picker = &p
+	return m, nil
+}
+
+// updatePicker handles keys while the picker is open.
+func (m model) updatePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := *m.picker
+	m.picker = &p
+	last := max(len(p.endpoints)-1, 0)
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q":
+		m.picker = nil
+		return m, nil
+	case "up", "k":
+		p.cursor = max(p.cursor-1, 0)
+	case "down", "j":
+		p.cursor = min(p.cursor+1, last)
+	case "r":
+		p.loading = true
+		return m, p.find
+	case "enter":
+		if p.loading || len(p.endpoints) == 0 {
+			return m, nil
+		}
+		m.picker = nil
+		e := p.endpoints[p.cursor]
+		if e.picked != nil {
+			e.picked()
+		}
+		cfg := m.cfg
+		cfg.url = e.url(m.cfg.url)
+		m.prompt = newPrompt(cfg)
+		return m, nil
+	}
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+tableHeight {
+		p.offset = p.cursor - tableHeight + 1
+	}
+	return m, nil
+}
+
+// viewPicker renders the endpoints to pick from.
+func (m model) viewPicker() string {
+	p := m.picker
+	var b strings.Builder
+	b.WriteString("\n" + p.title + "\n\n")
+	switch {
+	case p.loading:
+		b.WriteString(p.asking + "…\n")
+	case p.err != nil:
+		b.WriteString(p.err.Error() + "\n")
+	case len(p.endpoints) == 0 && p.filter != "":
+		fmt.Fprintf(&b, "%s matching %q.\n", p.none, p.filter)
+	case len(p.endpoints) == 0:
+		b.WriteString(p.none + ".\n")
+	}
+	for i := p.offset; i < min(p.offset+tableHeight, len(p.endpoints)); i++ {
+		mark := "  "
+		if i == p.cursor {
+			mark = "> "
+		}
+		line := p.endpoints[i].label
+		if m.width > 0 {
+			line = runewidth.Truncate(line, m.width-2, "…")
+		}
+		b.WriteString(mark + line + "\n")
+	}
+	b.WriteString("\n↑/↓ select • enter request it • r look again • esc close\n")
+	return b.String()
+}
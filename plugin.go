@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// middleware sees every request before it is sent and every response
+// after it arrives. It may change the request, for example to sign it, and
+// may annotate the response with notes that are shown under the status.
+type middleware interface {
+	// name identifies the middleware in notes and errors.
+	name() string
+	// onRequest may change req, and returns the body to send in place of body.
+	onRequest(req *http.Request, body []byte) ([]byte, error)
+	// onResponse returns notes about res, whose body has been read into body.
+	onResponse(res *http.Response, body []byte) ([]string, error)
+}
+
+// builtinMiddleware are the middlewares that -plugin can name directly.
+var builtinMiddleware = map[string]func(cfg config) middleware{
+	"request-id":  func(config) middleware { return requestID{} },
+	"traceparent": newTracing,
+}
+
+// pluginTimeout bounds how long an external plugin may take per call.
+const pluginTimeout = 10 * time.Second
+
+// middlewares turns the -plugin specs in cfg into middlewares. A spec is
+// either the name of a built-in middleware or a command line to run.
+// -otlp-endpoint and -trace-link need traceparent, so they bring it along,
+// and -cloud-token brings the middleware that sends its token.
+func middlewares(cfg config) ([]middleware, error) {
+	plugins := cfg.plugins
+	if (cfg.otlpEndpoint != "" || cfg.traceLink != "") && !slices.Contains(plugins, "traceparent") {
+		plugins = append(slices.Clip(plugins), "traceparent")
+	}
+	var out []middleware
+	for _, spec := range plugins {
+		if mk, ok := builtinMiddleware[spec]; ok {
+			out = append(out, mk(cfg))
+			continue
+		}
+		args, err := shellSplit(spec)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %q: %w", spec, err)
+		}
+		if len(args) == 0 {
+			return nil, errors.New("empty -plugin")
+		}
+		out = append(out, externalPlugin{args: args})
+	}
+	if cfg.cloudToken.kind != "" {
+		out = append(out, &cloudAuth{spec: cfg.cloudToken})
+	}
+	return out, nil
+}
+
+// applyRequestMiddleware runs req through every middleware in turn.
+// A middleware that changes the body replaces the request's body reader.
+func applyRequestMiddleware(mws []middleware, req *http.Request, body []byte) (changed bool, err error) {
+	for _, mw := range mws {
+		next, err := mw.onRequest(req, body)
+		if err != nil {
+			return changed, fmt.Errorf("plugin %s: %w", mw.name(), err)
+		}
+		if !bytes.Equal(next, body) {
+			body, changed = next, true
+		}
+	}
+	if changed {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	}
+	return changed, nil
+}
+
+// applyResponseMiddleware collects every middleware's notes on res.
+// A failing middleware is noted rather than failing the whole response.
+func applyResponseMiddleware(mws []middleware, res *http.Response, body []byte) []string {
+	var notes []string
+	for _, mw := range mws {
+		got, err := mw.onResponse(res, body)
+		if err != nil {
+			got = []string{"error: " + err.Error()}
+		}
+		for _, n := range got {
+			notes = append(notes, mw.name()+": "+n)
+		}
+	}
+	return notes
+}
+
+// withRequestID gives the request described by cfg a new random
+// X-Request-ID, so it can be found in the server's logs, unless -request-id
+// is off or one was given with -H.
+func withRequestID(cfg config) config {
+	if !cfg.requestID || cfg.header.Get("X-Request-ID") != "" {
+		return cfg
+	}
+	h := cfg.header.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	h.Set("X-Request-ID", randomHex(8))
+	cfg.header, cfg.madeID = h, true
+	return cfg
+}
+
+// echoesRequestID reports whether the server sent the request's
+// X-Request-ID back.
+func echoesRequestID(res *http.Response) bool {
+	sent := res.Request.Header.Get("X-Request-ID")
+	return sent != "" && res.Header.Get("X-Request-ID") == sent
+}
+
+// requestID tags each request with a random X-Request-ID, like -request-id
+// but for requests whose own doesn't apply, e.g. with -request-id=false,
+// and says whether the server echoed it back.
+type requestID struct{}
+
+func (requestID) name() string { return "request-id" }
+
+func (requestID) onRequest(req *http.Request, body []byte) ([]byte, error) {
+	if req.Header.Get("X-Request-ID") == "" {
+		req.Header.Set("X-Request-ID", randomHex(8))
+	}
+	return body, nil
+}
+
+func (requestID) onResponse(res *http.Response, body []byte) ([]string, error) {
+	sent := res.Request.Header.Get("X-Request-ID")
+	if echoesRequestID(res) {
+		return []string{"sent " + sent + ", echoed by the server"}, nil
+	}
+	return []string{"sent " + sent}, nil
+}
+
+// externalPlugin is a middleware implemented by another program. For every
+// request and every response, the program is started with one JSON object
+// on its standard input, and answers with one JSON object on its standard
+// output. Bodies are base64-encoded, as encoding/json does for bytes.
+//
+// Before a request is sent, it gets
+//
+//	{"phase": "request", "method": "POST", "url": "...", "headers": {"Key": ["value"]}, "body": "..."}
+//
+// and may answer with any of method, url, headers and body; fields it
+// leaves out stay as they were, and headers, if given, replace them all.
+//
+// After the response arrives, it gets
+//
+//	{"phase": "response", "method": "...", "url": "...", "status": 200, "headers": {...}, "body": "..."}
+//
+// and may answer {"notes": ["..."]} to have them shown with the response.
+// Anything the program writes to standard error is shown if it fails.
+type externalPlugin struct {
+	args []string // The command line to run.
+}
+
+// pluginMessage is what goes to and comes back from an external plugin.
+type pluginMessage struct {
+	Phase   string      `json:"phase,omitempty"`
+	Method  string      `json:"method,omitempty"`
+	URL     string      `json:"url,omitempty"`
+	Status  int         `json:"status,omitempty"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    []byte      `json:"body,omitempty"`
+	Notes   []string    `json:"notes,omitempty"`
+}
+
+func (p externalPlugin) name() string { return filepath.Base(p.args[0]) }
+
+// call runs the plugin on in and decodes its answer.
+func (p externalPlugin) call(in pluginMessage) (pluginMessage, error) {
+	var out pluginMessage
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return out, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.args[0], p.args[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	answer, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, fmt.Errorf("%w: %s", err, msg)
+		}
+		return out, err
+	}
+
+	// A plugin with nothing to say may print nothing at all.
+	if len(bytes.TrimSpace(answer)) == 0 {
+		return out, nil
+	}
+	if err := json.Unmarshal(answer, &out); err != nil {
+		return out, fmt.Errorf("reading its answer: %w", err)
+	}
+	return out, nil
+}
+
+func (p externalPlugin) onRequest(req *http.Request, body []byte) ([]byte, error) {
+	out, err := p.call(pluginMessage{
+		Phase:   "request",
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: req.Header,
+		Body:    body,
+	})
+	if err != nil {
+		return body, err
+	}
+
+	if out.Method != "" {
+		req.Method = strings.ToUpper(out.Method)
+	}
+	if out.URL != "" {
+		u, err := url.Parse(out.URL)
+		if err != nil {
+			return body, err
+		}
+		req.URL, req.Host = u, u.Host
+	}
+	if out.Headers != nil {
+		req.Header = out.Headers
+	}
+	if out.Body != nil {
+		body = out.Body
+	}
+	return body, nil
+}
+
+func (p externalPlugin) onResponse(res *http.Response, body []byte) ([]string, error) {
+	out, err := p.call(pluginMessage{
+		Phase:   "response",
+		Method:  res.Request.Method,
+		URL:     res.Request.URL.String(),
+		Status:  res.StatusCode,
+		Headers: res.Header,
+		Body:    body,
+	})
+	return out.Notes, err
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewares(t *testing.T) {
+	mws, err := middlewares(config{plugins: []string{"request-id", `sign --key 'a b'`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mws) != 2 || mws[0].name() != "request-id" || mws[1].name() != "sign" {
+		t.Fatalf("middlewares = %v", mws)
+	}
+	if ext := mws[1].(externalPlugin); strings.Join(ext.args, "|") != "sign|--key|a b" {
+		t.Errorf("args = %q", ext.args)
+	}
+	if _, err := middlewares(config{plugins: []string{" "}}); err == nil {
+		t.Error("an empty -plugin was accepted")
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://a.test/", nil)
+	if _, err := applyRequestMiddleware([]middleware{requestID{}}, req, nil); err != nil {
+		t.Fatal(err)
+	}
+	id := req.Header.Get("X-Request-ID")
+	if len(id) != 16 {
+		t.Fatalf("X-Request-ID = %q", id)
+	}
+	res := &http.Response{Request: req, Header: http.Header{"X-Request-Id": {id}}}
+	notes := applyResponseMiddleware([]middleware{requestID{}}, res, nil)
+	if len(notes) != 1 || notes[0] != "request-id: sent "+id+", echoed by the server" {
+		t.Errorf("notes = %q", notes)
+	}
+}
+
+func TestExternalPlugin(t *testing.T) {
+	// The plugin swaps the method and body, whatever it is sent.
+	p := externalPlugin{args: []string{"sh", "-c", `cat >/dev/null; echo '{"method":"put","body":"aGk=","headers":{"X-Signed":["yes"]}}'`}}
+	req, _ := http.NewRequest(http.MethodPost, "https://a.test/", strings.NewReader("old"))
+	changed, err := applyRequestMiddleware([]middleware{p}, req, []byte("old"))
+	if err != nil || !changed {
+		t.Fatalf("changed = %v, err = %v", changed, err)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if req.Method != http.MethodPut || string(body) != "hi" || req.ContentLength != 2 || req.Header.Get("X-Signed") != "yes" {
+		t.Errorf("request = %s %q (%d) %v", req.Method, body, req.ContentLength, req.Header)
+	}
+}
+
+func TestExternalPluginNotes(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://a.test/", nil)
+	res := &http.Response{Request: req, StatusCode: 200, Header: http.Header{}}
+
+	ok := externalPlugin{args: []string{"sh", "-c", `grep -q '"phase":"response"' && echo '{"notes":["looks fine"]}'`}}
+	quiet := externalPlugin{args: []string{"sh", "-c", "cat >/dev/null"}}
+	broken := externalPlugin{args: []string{"sh", "-c", "echo boom >&2; exit 3"}}
+	notes := applyResponseMiddleware([]middleware{ok, quiet, broken}, res, []byte("body"))
+	if len(notes) != 2 || notes[0] != "sh: looks fine" || !strings.Contains(notes[1], "sh: error: exit status 3: boom") {
+		t.Errorf("notes = %q", notes)
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", r.Header.Get("X-Request-ID"))
+	}))
+	defer srv.Close()
+
+	history := filepath.Join(t.TempDir(), "history.jsonl")
+	cfg := config{method: http.MethodGet, url: srv.URL, requestID: true, historyFile: history}
+	res, ok := checkServer(cfg)().(responseMsg)
+	if !ok || len(res.requestID) != 16 || !res.echoed {
+		t.Fatalf("response = %+v", res)
+	}
+	if cfg.header != nil {
+		t.Error("the ID leaked into the config it was given")
+	}
+	entries, err := readHistory(history)
+	if err != nil || len(entries) != 1 || entries[0].ID != res.requestID {
+		t.Errorf("history = %+v, %v; want ID %s", entries, err, res.requestID)
+	}
+
+	// One given with -H is kept, and -request-id=false sends none.
+	given := withRequestID(config{requestID: true, header: http.Header{"X-Request-Id": {"mine"}}})
+	if got := given.header.Get("X-Request-ID"); got != "mine" {
+		t.Errorf("X-Request-ID = %q", got)
+	}
+	if off := withRequestID(config{}); off.header != nil {
+		t.Errorf("-request-id=false sent %v", off.header)
+	}
+
+	// A failed request names its ID, for the server's logs.
+	srv.Close()
+	if msg, ok := checkServer(config{method: http.MethodGet, url: srv.URL, requestID: true})().(errMsg); !ok || !strings.Contains(msg.err.Error(), "(X-Request-ID ") {
+		t.Errorf("error = %v", msg.err)
+	}
+}
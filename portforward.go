@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A service inside a Kubernetes cluster has a name only the cluster's DNS
+// knows, such as orders.shop.svc. With -port-forward, a request to one is
+// sent through kubectl port-forward, started for it on the way and stopped
+// once nothing has used it for a while, so testing the service takes no
+// more than its URL:
+//
+//	http://NAME.NAMESPACE.svc[.cluster.local]:PORT/...  the service NAME
+//	http://NAME.NAMESPACE.pod:PORT/...                  the pod NAME
+//
+// The request goes out with its Host as written, as it would inside the
+// cluster. -kube-context picks the cluster; kubectl's current context is
+// the default.
+
+// kubectl is the command line that port-forwards.
+var kubectl = "kubectl"
+
+// portForwardStart bounds how long kubectl may take to open a forward, and
+// portForwardIdle how long one stays open with no connection through it.
+var (
+	portForwardStart = 30 * time.Second
+	portForwardIdle  = time.Minute
+)
+
+// clusterHost matches the names of services and pods that -port-forward
+// reaches: the name, the namespace and which of the two it is.
+var clusterHost = regexp.MustCompile(`^([a-z0-9]([-a-z0-9]*[a-z0-9])?)\.([a-z0-9]([-a-z0-9]*[a-z0-9])?)\.(svc|pod)(\.cluster\.local)?\.?$`)
+
+// forwardedLine is what kubectl says once a forward is open.
+var forwardedLine = regexp.MustCompile(`^Forwarding from (127\.0\.0\.1:\d+) ->`)
+
+// forwardTarget is what is forwarded to: svc/NAME or pod/NAME, in a
+// namespace of a context, and its port.
+type forwardTarget struct {
+	context   string
+	namespace string
+	resource  string
+	port      string
+}
+
+// clusterTarget returns what addr, a host and port, is in the cluster
+// kubeContext names, if it is a service's or pod's.
+func clusterTarget(kubeContext, addr string) (forwardTarget, bool) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return forwardTarget{}, false
+	}
+	m := clusterHost.FindStringSubmatch(strings.ToLower(host))
+	if m == nil {
+		return forwardTarget{}, false
+	}
+	return forwardTarget{context: kubeContext, namespace: m[3], resource: m[5] + "/" + m[1], port: port}, true
+}
+
+// portForward is one kubectl port-forward, and the connections through it.
+type portForward struct {
+	cmd   *exec.Cmd
+	local string // The local address it listens on.
+	conns int
+	idle  *time.Timer // Stops it once it has had no connections for portForwardIdle.
+	done  chan struct{}
+}
+
+// portForwards holds the forwards open, by target.
+var portForwards = struct {
+	sync.Mutex
+	byTarget map[forwardTarget]*portForward
+}{byTarget: map[forwardTarget]*portForward{}}
+
+// startPortForward runs kubectl port-forward for t, on a free local port,
+// and waits until it says it is forwarding.
+func startPortForward(ctx context.Context, t forwardTarget) (*portForward, error) {
+	args := []string{"port-forward", "--address", "127.0.0.1", "-n", t.namespace, t.resource, ":" + t.port}
+	if t.context != "" {
+		args = append([]string{"--context", t.context}, args...)
+	}
+	cmd := exec.Command(kubectl, args...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("port-forwarding to %s: %w", t.resource, err)
+	}
+	f := &portForward{cmd: cmd, done: make(chan struct{})}
+
+	ready := make(chan string, 1)
+	go func() {
+		lines := bufio.NewScanner(out)
+		for lines.Scan() {
+			if m := forwardedLine.FindStringSubmatch(lines.Text()); m != nil {
+				ready <- m[1]
+				break
+			}
+		}
+		// kubectl goes on writing a line per connection, which nobody reads.
+		io.Copy(io.Discard, out)
+		cmd.Wait()
+		close(ready)
+		close(f.done)
+	}()
+	fail := func(err error) (*portForward, error) {
+		cmd.Process.Kill()
+		<-f.done
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("port-forwarding to %s in %s: %w", t.resource, t.namespace, err)
+	}
+	select {
+	case local, ok := <-ready:
+		if !ok {
+			return fail(errors.New("kubectl stopped"))
+		}
+		f.local = local
+		return f, nil
+	case <-time.After(portForwardStart):
+		return fail(fmt.Errorf("kubectl didn't start forwarding within %s", portForwardStart))
+	case <-ctx.Done():
+		return fail(ctx.Err())
+	}
+}
+
+// acquirePortForward returns the open forward to t, starting one if there
+// is none, and counts a connection through it.
+func acquirePortForward(ctx context.Context, t forwardTarget) (*portForward, error) {
+	portForwards.Lock()
+	defer portForwards.Unlock()
+	if f, ok := portForwards.byTarget[t]; ok {
+		select {
+		case <-f.done:
+		default:
+			if f.idle != nil {
+				f.idle.Stop()
+			}
+			f.conns++
+			return f, nil
+		}
+	}
+	f, err := startPortForward(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	f.conns = 1
+	portForwards.byTarget[t] = f
+	return f, nil
+}
+
+// release counts a connection through f gone, and stops f once it has
+// been idle for portForwardIdle.
+func (f *portForward) release(t forwardTarget) {
+	portForwards.Lock()
+	defer portForwards.Unlock()
+	if f.conns--; f.conns > 0 {
+		return
+	}
+	f.idle = time.AfterFunc(portForwardIdle, func() {
+		portForwards.Lock()
+		defer portForwards.Unlock()
+		if f.conns == 0 && portForwards.byTarget[t] == f {
+			delete(portForwards.byTarget, t)
+			f.cmd.Process.Kill()
+		}
+	})
+}
+
+// closePortForwards stops every forward, for when the program is done.
+func closePortForwards() {
+	portForwards.Lock()
+	defer portForwards.Unlock()
+	for t, f := range portForwards.byTarget {
+		f.cmd.Process.Kill()
+		<-f.done
+		delete(portForwards.byTarget, t)
+	}
+}
+
+// forwardedConn is a connection through a forward, which lets it go when
+// closed.
+type forwardedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *forwardedConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
+
+// portForwardDialer dials services and pods of the cluster kubeContext
+// names through kubectl port-forward, on this host, and everything else
+// with dial.
+func portForwardDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error), kubeContext string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		t, ok := clusterTarget(kubeContext, addr)
+		if !ok {
+			return dial(ctx, network, addr)
+		}
+		f, err := acquirePortForward(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		var d net.Dialer
+		c, err := d.DialContext(ctx, "tcp", f.local)
+		if err != nil {
+			f.release(t)
+			return nil, err
+		}
+		return &forwardedConn{Conn: c, release: func() { f.release(t) }}, nil
+	}
+}
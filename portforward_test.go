@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClusterTarget(t *testing.T) {
+	for addr, want := range map[string]forwardTarget{
+		"orders.shop.svc:8080":              {namespace: "shop", resource: "svc/orders", port: "8080"},
+		"Orders.Shop.svc.cluster.local:443": {namespace: "shop", resource: "svc/orders", port: "443"},
+		"web-0.default.pod:80":              {namespace: "default", resource: "pod/web-0", port: "80"},
+	} {
+		if got, ok := clusterTarget("", addr); !ok || got != want {
+			t.Errorf("%s: %+v", addr, got)
+		}
+	}
+	for _, addr := range []string{"api.example.com:443", "orders.svc:80", "a.b.c.svc:80", "127.0.0.1:80"} {
+		if got, ok := clusterTarget("", addr); ok {
+			t.Errorf("%s was taken for %+v", addr, got)
+		}
+	}
+}
+
+func TestPortForward(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello from "+r.Host)
+	}))
+	defer srv.Close()
+
+	// A kubectl that says it forwards to the test server, and notes how it
+	// was asked.
+	dir := t.TempDir()
+	script := filepath.Join(dir, "kubectl")
+	os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" >> "+filepath.Join(dir, "args")+"\necho 'Forwarding from "+srv.Listener.Addr().String()+" -> 8080'\nexec sleep 30\n"), 0o755)
+	savedKubectl, savedIdle := kubectl, portForwardIdle
+	kubectl, portForwardIdle = script, 50*time.Millisecond
+	defer func() { kubectl, portForwardIdle = savedKubectl, savedIdle }()
+
+	client := &http.Client{Transport: transportFor(transportOptions{portForward: true, kubeContext: "staging"})}
+	for range 2 {
+		res, err := client.Get("http://orders.shop.svc:8080/health")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if string(b) != "hello from orders.shop.svc:8080" {
+			t.Errorf("got %q", b)
+		}
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if string(args) != "--context staging port-forward --address 127.0.0.1 -n shop svc/orders :8080\n" {
+		t.Errorf("kubectl was run as %q", args)
+	}
+
+	// Once the connections are gone and it has been idle, it stops.
+	client.CloseIdleConnections()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		portForwards.Lock()
+		n := len(portForwards.byTarget)
+		portForwards.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the forward is still open")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPortForwardFails(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "kubectl")
+	os.WriteFile(script, []byte("#!/bin/sh\necho 'error: services \"nope\" not found' >&2\nexit 1\n"), 0o755)
+	saved := kubectl
+	kubectl = script
+	defer func() { kubectl = saved }()
+	client := &http.Client{Transport: transportFor(transportOptions{portForward: true, kubeContext: "fails"})}
+	_, err := client.Get("http://nope.shop.svc/")
+	if err == nil || !strings.Contains(err.Error(), `services "nope" not found`) {
+		t.Errorf("err = %v", err)
+	}
+	closePortForwards()
+}
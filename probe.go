@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// probeOrigin is the origin we pretend to come from when probing, so that
+// servers which only emit CORS headers for cross-origin requests show them.
+const probeOrigin = "https://example.com"
+
+// probeEncodings is what we offer in Accept-Encoding to learn which
+// compressions the server supports.
+const probeEncodings = "gzip, deflate, br, zstd"
+
+// probe returns a command that sends OPTIONS and HEAD to cfg.url and
+// summarises what the endpoint is willing to do: methods, CORS policy,
+// encodings and the software behind it.
+func probe(cfg config) tea.Cmd {
+	return func() tea.Msg {
+		c := newClient(cfg)
+
+		// OPTIONS tells us about methods and, with an Origin, CORS.
+		opts, err := http.NewRequest(http.MethodOptions, cfg.url, nil)
+		if err != nil {
+			return errMsg{err}
+		}
+		opts.Header.Set("Origin", probeOrigin)
+		opts.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		optRes, err := c.Do(opts)
+		if err != nil {
+			return errMsg{err}
+		}
+		optRes.Body.Close()
+
+		// HEAD tells us about encodings, ranges and the server itself
+		// without downloading the body.
+		head, err := http.NewRequest(http.MethodHead, cfg.url, nil)
+		if err != nil {
+			return errMsg{err}
+		}
+		head.Header.Set("Accept-Encoding", probeEncodings)
+		headRes, err := c.Do(head)
+		if err != nil {
+			return errMsg{err}
+		}
+		headRes.Body.Close()
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "OPTIONS %d, HEAD %d\n", optRes.StatusCode, headRes.StatusCode)
+		fmt.Fprintf(&b, "Methods:   %s\n", orNone(firstOf(optRes.Header, headRes.Header, "Allow")))
+		fmt.Fprintf(&b, "CORS:      %s\n", corsPolicy(optRes.Header))
+		fmt.Fprintf(&b, "Encoding:  %s\n", orNone(headRes.Header.Get("Content-Encoding")))
+		fmt.Fprintf(&b, "Ranges:    %s\n", orNone(headRes.Header.Get("Accept-Ranges")))
+		fmt.Fprintf(&b, "Type:      %s\n", orNone(headRes.Header.Get("Content-Type")))
+		fmt.Fprintf(&b, "Server:    %s", orNone(strings.TrimSpace(
+			firstOf(headRes.Header, optRes.Header, "Server")+" "+headRes.Header.Get("X-Powered-By"))))
+
+		return reportMsg{title: "Probe", body: b.String()}
+	}
+}
+
+// corsPolicy condenses the Access-Control-* headers of a preflight answer.
+func corsPolicy(h http.Header) string {
+	origin := h.Get("Access-Control-Allow-Origin")
+	if origin == "" {
+		return "no CORS headers for a cross-origin caller"
+	}
+	s := "origin " + origin
+	if v := h.Get("Access-Control-Allow-Methods"); v != "" {
+		s += ", methods " + v
+	}
+	if v := h.Get("Access-Control-Allow-Headers"); v != "" {
+		s += ", headers " + v
+	}
+	if h.Get("Access-Control-Allow-Credentials") == "true" {
+		s += ", with credentials"
+	}
+	if v := h.Get("Access-Control-Max-Age"); v != "" {
+		s += ", cached " + v + "s"
+	}
+	return s
+}
+
+// firstOf returns the value of key from a, or from b when a lacks it.
+func firstOf(a, b http.Header, key string) string {
+	if v := a.Get(key); v != "" {
+		return v
+	}
+	return b.Get(key)
+}
+
+// orNone stands in for an empty value in reports.
+func orNone(s string) string {
+	if s == "" {
+		return "(not advertised)"
+	}
+	return s
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProbe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodOptions:
+			w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+			if r.Header.Get("Origin") == probeOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+				w.Header().Set("Access-Control-Max-Age", "600")
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodHead:
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Server", "nginx")
+			w.Header().Set("X-Powered-By", "PHP")
+		}
+	}))
+	defer srv.Close()
+
+	msg := probe(config{url: srv.URL, header: http.Header{}})()
+	r, ok := msg.(reportMsg)
+	if !ok {
+		t.Fatalf("probe = %#v, want a report", msg)
+	}
+	for _, want := range []string{
+		"OPTIONS 204, HEAD 200",
+		"Methods:   GET, HEAD, OPTIONS",
+		"CORS:      origin *, cached 600s",
+		"Encoding:  (not advertised)",
+		"Ranges:    bytes",
+		"Type:      text/html",
+		"Server:    nginx PHP",
+	} {
+		if !strings.Contains(r.body, want) {
+			t.Errorf("report lacks %q:\n%s", want, r.body)
+		}
+	}
+}
+
+func TestCORSPolicy(t *testing.T) {
+	if got := corsPolicy(http.Header{}); got != "no CORS headers for a cross-origin caller" {
+		t.Errorf("no headers = %q", got)
+	}
+	h := http.Header{
+		"Access-Control-Allow-Origin":      {"https://a.test"},
+		"Access-Control-Allow-Methods":     {"GET, PUT"},
+		"Access-Control-Allow-Headers":     {"X-Token"},
+		"Access-Control-Allow-Credentials": {"true"},
+	}
+	want := "origin https://a.test, methods GET, PUT, headers X-Token, with credentials"
+	if got := corsPolicy(h); got != want {
+		t.Errorf("corsPolicy = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// protobufTypes are the media types protobuf bodies are served as.
+var protobufTypes = map[string]bool{
+	"application/x-protobuf":          true,
+	"application/protobuf":            true,
+	"application/x-protobuffer":       true,
+	"application/vnd.google.protobuf": true,
+}
+
+// protoSource finds the message types of one -proto file or descriptor set.
+type protoSource interface {
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
+}
+
+// protoTypes are the message types the user registered with -proto,
+// searched in the order they were given. It is also what protojson uses to
+// resolve google.protobuf.Any fields and extensions.
+type protoTypes struct {
+	sources []protoSource
+}
+
+// loadProtoTypes reads the .proto files and compiled descriptor sets in
+// files. The .proto files are compiled together, with their imports looked
+// up in importPaths and then next to the file; anything else is read as a
+// FileDescriptorSet, as written by protoc --descriptor_set_out with
+// --include_imports.
+func loadProtoTypes(files, importPaths []string) (*protoTypes, error) {
+	types := &protoTypes{}
+	var sources []string
+	paths := importPaths
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".proto") {
+			src, err := loadDescriptorSet(file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			types.sources = append(types.sources, src)
+			continue
+		}
+		name, dir := protoName(file, importPaths)
+		if dir != "" {
+			paths = append(paths, dir)
+		}
+		sources = append(sources, name)
+	}
+	if len(sources) == 0 {
+		return types, nil
+	}
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: paths}),
+	}
+	compiled, err := compiler.Compile(context.Background(), sources...)
+	if err != nil {
+		return nil, err
+	}
+	types.sources = append(types.sources, compiled.AsResolver())
+	return types, nil
+}
+
+// protoName returns the name file is compiled under: relative to the import
+// path it is in, or else its base name with its directory as an extra
+// import path.
+func protoName(file string, importPaths []string) (name, dir string) {
+	for _, p := range importPaths {
+		rel, err := filepath.Rel(p, file)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel), ""
+		}
+	}
+	return filepath.Base(file), filepath.Dir(file)
+}
+
+// loadDescriptorSet reads a compiled FileDescriptorSet.
+func loadDescriptorSet(file string) (protoSource, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("not a descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, err
+	}
+	return dynamicpb.NewTypes(files), nil
+}
+
+// FindMessageByName implements protoregistry.MessageTypeResolver.
+func (t *protoTypes) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	for _, src := range t.sources {
+		if mt, err := src.FindMessageByName(name); err == nil {
+			return mt, nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
+
+// FindMessageByURL implements protoregistry.MessageTypeResolver.
+func (t *protoTypes) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	for _, src := range t.sources {
+		if mt, err := src.FindMessageByURL(url); err == nil {
+			return mt, nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
+
+// FindExtensionByName implements protoregistry.ExtensionTypeResolver.
+func (t *protoTypes) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	for _, src := range t.sources {
+		if xt, err := src.FindExtensionByName(field); err == nil {
+			return xt, nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
+
+// FindExtensionByNumber implements protoregistry.ExtensionTypeResolver.
+func (t *protoTypes) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	for _, src := range t.sources {
+		if xt, err := src.FindExtensionByNumber(message, field); err == nil {
+			return xt, nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
+
+// message returns a new, empty message of the named type.
+func (t *protoTypes) message(name string) (protoreflect.Message, error) {
+	if t == nil || len(t.sources) == 0 {
+		return nil, errors.New("no message types registered; give them with -proto")
+	}
+	mt, err := t.FindMessageByName(protoreflect.FullName(strings.TrimPrefix(name, ".")))
+	if err != nil {
+		return nil, fmt.Errorf("no message type %q in the -proto files", name)
+	}
+	return mt.New(), nil
+}
+
+// toJSON decodes a protobuf body as the named message type.
+func (t *protoTypes) toJSON(name string, body []byte) ([]byte, error) {
+	msg, err := t.message(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := (proto.UnmarshalOptions{Resolver: t}).Unmarshal(body, msg.Interface()); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", name, err)
+	}
+	return protojson.MarshalOptions{Resolver: t, EmitUnpopulated: true}.Marshal(msg.Interface())
+}
+
+// fromJSON encodes a JSON body as the named message type.
+func (t *protoTypes) fromJSON(name string, body []byte) ([]byte, error) {
+	msg, err := t.message(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := (protojson.UnmarshalOptions{Resolver: t}).Unmarshal(body, msg.Interface()); err != nil {
+		return nil, fmt.Errorf("encoding the body as %s: %w", name, err)
+	}
+	return proto.Marshal(msg.Interface())
+}
+
+// responseMessage reports whether a response with headers h is protobuf,
+// and if so returns its message type, "" when it's unknown. Servers often
+// name the type in a Content-Type parameter; otherwise it is the one given
+// with -proto-response.
+func responseMessage(cfg config, h http.Header) (string, bool) {
+	mediaType, params, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	if !protobufTypes[mediaType] {
+		return "", false
+	}
+	for _, p := range []string{"proto", "messagetype", "type"} {
+		if name := params[p]; name != "" {
+			return name, true
+		}
+	}
+	return cfg.protoResponse, true
+}
+
+// encodeProtoRequest returns cfg with its JSON body encoded as the
+// -proto-request message type, which is how protobuf bodies are authored
+// and edited. The request says it is protobuf unless it already has a
+// Content-Type.
+func encodeProtoRequest(cfg config) (config, error) {
+	if cfg.protoRequest == "" || cfg.body == nil {
+		return cfg, nil
+	}
+	body, err := cfg.proto.fromJSON(cfg.protoRequest, cfg.body)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.body = body
+	if cfg.header.Get("Content-Type") == "" {
+		cfg.header = cfg.header.Clone()
+		cfg.header.Set("Content-Type", "application/x-protobuf; proto="+cfg.protoRequest)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const petProto = `syntax = "proto3";
+package shop;
+import "google/protobuf/timestamp.proto";
+message Pet {
+  string name = 1;
+  int32 legs = 2;
+  google.protobuf.Timestamp born = 3;
+}
+`
+
+// petTypes compiles petProto and returns its types and the file's path.
+func petTypes(t *testing.T) (*protoTypes, string) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "pet.proto")
+	if err := os.WriteFile(file, []byte(petProto), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	types, err := loadProtoTypes([]string{file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return types, file
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	types, _ := petTypes(t)
+	wire, err := types.fromJSON("shop.Pet", []byte(`{"name":"Rex","legs":4,"born":"2020-01-02T03:04:05Z"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := types.toJSON(".shop.Pet", wire)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"name":"Rex"`, `"legs":4`, `"born":"2020-01-02T03:04:05Z"`} {
+		if !strings.Contains(strings.ReplaceAll(string(out), " ", ""), want) {
+			t.Errorf("JSON %s lacks %s", out, want)
+		}
+	}
+
+	if _, err := types.toJSON("shop.Cat", wire); err == nil || !strings.Contains(err.Error(), `no message type "shop.Cat"`) {
+		t.Errorf("unknown type: err = %v", err)
+	}
+	if _, err := types.fromJSON("shop.Pet", []byte(`{"legs":"many"}`)); err == nil {
+		t.Error("a string was encoded as an int32")
+	}
+	if _, err := (*protoTypes)(nil).toJSON("shop.Pet", wire); err == nil || !strings.Contains(err.Error(), "-proto") {
+		t.Errorf("no types: err = %v", err)
+	}
+}
+
+func TestProtoDescriptorSet(t *testing.T) {
+	types, _ := petTypes(t)
+	mt, _ := types.FindMessageByName("shop.Pet")
+	fd := mt.Descriptor().ParentFile()
+	set := &descriptorpb.FileDescriptorSet{}
+	for i := 0; i < fd.Imports().Len(); i++ {
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd.Imports().Get(i).FileDescriptor))
+	}
+	set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	b, _ := proto.Marshal(set)
+	file := filepath.Join(t.TempDir(), "pet.pb")
+	os.WriteFile(file, b, 0o644)
+
+	fromSet, err := loadProtoTypes([]string{file}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire, err := fromSet.fromJSON("shop.Pet", []byte(`{"name":"Tom"}`))
+	if err != nil || len(wire) == 0 {
+		t.Errorf("encoding with a descriptor set: %v", err)
+	}
+
+	os.WriteFile(file, []byte("not a descriptor set"), 0o644)
+	if _, err := loadProtoTypes([]string{file}, nil); err == nil {
+		t.Error("garbage was read as a descriptor set")
+	}
+}
+
+func TestProtoName(t *testing.T) {
+	name, dir := protoName("/src/protos/shop/pet.proto", []string{"/other", "/src/protos"})
+	if name != "shop/pet.proto" || dir != "" {
+		t.Errorf("under an import path = %q, %q", name, dir)
+	}
+	name, dir = protoName("/elsewhere/pet.proto", []string{"/src/protos"})
+	if name != "pet.proto" || dir != "/elsewhere" {
+		t.Errorf("outside the import paths = %q, %q", name, dir)
+	}
+}
+
+func TestResponseMessage(t *testing.T) {
+	cfg := config{protoResponse: "shop.Pet"}
+	for ct, want := range map[string]string{
+		"application/x-protobuf; proto=shop.Cat":    "shop.Cat",
+		"application/protobuf; messageType=shop.Ox": "shop.Ox",
+		"application/x-protobuf":                    "shop.Pet",
+	} {
+		if got, ok := responseMessage(cfg, http.Header{"Content-Type": {ct}}); !ok || got != want {
+			t.Errorf("responseMessage(%q) = %q, %v, want %q", ct, got, ok, want)
+		}
+	}
+	if _, ok := responseMessage(cfg, http.Header{"Content-Type": {"application/json"}}); ok {
+		t.Error("JSON counted as protobuf")
+	}
+}
+
+func TestEncodeProtoRequest(t *testing.T) {
+	types, _ := petTypes(t)
+	cfg := config{proto: types, protoRequest: "shop.Pet", header: http.Header{}, body: []byte(`{"name":"Rex"}`)}
+	got, err := encodeProtoRequest(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.header.Get("Content-Type") != "application/x-protobuf; proto=shop.Pet" || len(cfg.header) != 0 {
+		t.Errorf("Content-Type = %q, original headers %v", got.header.Get("Content-Type"), cfg.header)
+	}
+	if back, err := types.toJSON("shop.Pet", got.body); err != nil || !strings.Contains(string(back), "Rex") {
+		t.Errorf("body decodes to %s, %v", back, err)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Race mode sends defaultRacers requests at once unless told otherwise.
+const (
+	defaultRacers = 10
+	maxRacers     = 200
+)
+
+// raceOutcome is one way the racing requests were answered, and how many
+// were answered that way.
+type raceOutcome struct {
+	status int    // 0 for no answer.
+	body   string // The body, shortened, or why there was no answer.
+	count  int
+}
+
+// runRace is the palette's `race [requests]`: it readies that many copies
+// of the request, lets them all go at the same instant, and shows how they
+// were answered. An action meant to happen once, such as redeeming a
+// coupon, that succeeds more than once has a race. Every request goes into
+// the history.
+func runRace(m model, args []string) (tea.Model, tea.Cmd) {
+	n := defaultRacers
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v < 2 || v > maxRacers {
+			return m.paletteError(fmt.Errorf("%q is not a number of requests from 2 to %d", args[0], maxRacers))
+		}
+		n = v
+	}
+	if isFileTransfer(m.cfg.url) {
+		return m.paletteError(errors.New("only HTTP requests can race"))
+	}
+	cfg := m.cfg
+	m.job = fmt.Sprintf("Racing %d requests", n)
+	return m, runJob(func(func(string)) tea.Msg {
+		msgs, spread := race(cfg, n)
+		return reportMsg{title: fmt.Sprintf("Race of %d × %s %s", n, cfg.method, cfg.url), body: describeRace(msgs, spread)}
+	})
+}
+
+// race sends n copies of the request at once. Each waits at a barrier
+// until all are ready, so that they leave as close together as the
+// scheduler allows; spread is how far apart the first and last left.
+func race(cfg config, n int) (msgs []tea.Msg, spread time.Duration) {
+	msgs = make([]tea.Msg, n)
+	starts := make([]time.Time, n)
+	var ready, done sync.WaitGroup
+	barrier := make(chan struct{})
+	for i := range n {
+		ready.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			cfg := withRequestID(cfg)
+			ready.Done()
+			<-barrier
+			starts[i] = time.Now()
+			msg := send(cfg)
+			_ = recordHistory(cfg, msg, time.Since(starts[i]))
+			if res, ok := msg.(responseMsg); ok && res.stopStream != nil {
+				res.stopStream()
+			}
+			msgs[i] = msg
+		}()
+	}
+	ready.Wait()
+	close(barrier)
+	done.Wait()
+	return msgs, slices.MaxFunc(starts, time.Time.Compare).Sub(slices.MinFunc(starts, time.Time.Compare))
+}
+
+// raceOutcomes groups the answers of a race by status and body, the most
+// common first.
+func raceOutcomes(msgs []tea.Msg) []raceOutcome {
+	var outcomes []raceOutcome
+	for _, msg := range msgs {
+		o := raceOutcome{}
+		switch msg := msg.(type) {
+		case responseMsg:
+			o.status = msg.status
+			o.body = shortJSON(takeSnapshot(msg.status, response(msg).jsonBody(), nil).Body)
+		case errMsg:
+			o.body = msg.err.Error()
+		}
+		i := slices.IndexFunc(outcomes, func(p raceOutcome) bool { return p.status == o.status && p.body == o.body })
+		if i < 0 {
+			outcomes = append(outcomes, o)
+			i = len(outcomes) - 1
+		}
+		outcomes[i].count++
+	}
+	slices.SortStableFunc(outcomes, func(a, b raceOutcome) int { return cmp.Compare(b.count, a.count) })
+	return outcomes
+}
+
+// describeRace writes up how the racing requests were answered.
+func describeRace(msgs []tea.Msg, spread time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d requests left within %s of each other.\n\n", len(msgs), spread.Round(time.Microsecond))
+	succeeded := 0
+	outcomes := raceOutcomes(msgs)
+	for _, o := range outcomes {
+		what := "no answer"
+		if o.status > 0 {
+			what = strconv.Itoa(o.status)
+		}
+		fmt.Fprintf(&b, "%4d × %s  %s\n", o.count, what, o.body)
+		if o.status >= 200 && o.status <= 299 {
+			succeeded += o.count
+		}
+	}
+	b.WriteString("\n")
+	switch {
+	case len(outcomes) == 1:
+		b.WriteString("Every request was answered the same way.")
+	case succeeded > 1:
+		fmt.Fprintf(&b, "⚠ %d requests succeeded. If the action may only happen once, the server let it happen %d times: a race.", succeeded, succeeded)
+	case succeeded == 1:
+		b.WriteString("One request succeeded and the others were turned away, as an action that may only happen once should be.")
+	default:
+		b.WriteString("No request succeeded.")
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// couponServer redeems its one coupon for every request that arrives while
+// a slow check is still running, as a racy handler would.
+func couponServer() *httptest.Server {
+	var mu sync.Mutex
+	redeemed := false
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		used := redeemed
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		if used {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error": "already redeemed"}`))
+			return
+		}
+		mu.Lock()
+		redeemed = true
+		mu.Unlock()
+		w.Write([]byte(`{"redeemed": true}`))
+	}))
+}
+
+func TestRace(t *testing.T) {
+	srv := couponServer()
+	defer srv.Close()
+	msgs, spread := race(config{method: "POST", url: srv.URL}, 5)
+	if len(msgs) != 5 || spread > 40*time.Millisecond {
+		t.Fatalf("got %d answers, %s apart", len(msgs), spread)
+	}
+	outcomes := raceOutcomes(msgs)
+	if len(outcomes) != 1 || outcomes[0].status != 200 || outcomes[0].count != 5 || outcomes[0].body != `{"redeemed":true}` {
+		t.Errorf("outcomes = %+v", outcomes)
+	}
+
+	// The coupon is used now, so a second race turns everyone away.
+	msgs, _ = race(config{method: "POST", url: srv.URL}, 3)
+	if out := describeRace(msgs, 0); !strings.Contains(out, "   3 × 409  {\"error\":\"already redeemed\"}") {
+		t.Errorf("report:\n%s", out)
+	}
+}
+
+func TestDescribeRace(t *testing.T) {
+	in := []tea.Msg{
+		responseMsg{status: 200, body: []byte(`{"ok": true}`)},
+		responseMsg{status: 409, body: []byte(`{"ok": false}`)},
+		responseMsg{status: 200, body: []byte(`{"ok": true}`)},
+	}
+	out := describeRace(in, time.Millisecond)
+	for _, want := range []string{"3 requests left within 1ms", "   2 × 200  {\"ok\":true}\n   1 × 409", "⚠ 2 requests succeeded"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q:\n%s", want, out)
+		}
+	}
+	if out := describeRace(in[1:2], 0); !strings.Contains(out, "Every request was answered the same way.") {
+		t.Errorf("report:\n%s", out)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// contentRange is a parsed Content-Range header such as "bytes 0-1023/4096".
+type contentRange struct {
+	first, last int64 // Inclusive byte positions of this part, -1 for "*".
+	total       int64 // Full size of the resource, -1 when the server did not say.
+}
+
+// parseContentRange parses the value of a Content-Range response header.
+// Both the "bytes first-last/total" and the "bytes */total" (as sent with
+// 416 Range Not Satisfiable) forms are understood.
+func parseContentRange(s string) (contentRange, error) {
+	cr := contentRange{first: -1, last: -1, total: -1}
+
+	spec, ok := strings.CutPrefix(strings.TrimSpace(s), "bytes ")
+	if !ok {
+		return cr, fmt.Errorf("content-range %q: only byte ranges are supported", s)
+	}
+	span, total, ok := strings.Cut(spec, "/")
+	if !ok {
+		return cr, fmt.Errorf("content-range %q: missing total length", s)
+	}
+
+	var err error
+	if total != "*" {
+		if cr.total, err = strconv.ParseInt(total, 10, 64); err != nil {
+			return cr, fmt.Errorf("content-range %q: bad total length", s)
+		}
+	}
+	if span != "*" {
+		first, last, ok := strings.Cut(span, "-")
+		if !ok {
+			return cr, fmt.Errorf("content-range %q: bad byte span", s)
+		}
+		if cr.first, err = strconv.ParseInt(first, 10, 64); err != nil {
+			return cr, fmt.Errorf("content-range %q: bad first byte", s)
+		}
+		if cr.last, err = strconv.ParseInt(last, 10, 64); err != nil || cr.last < cr.first {
+			return cr, fmt.Errorf("content-range %q: bad last byte", s)
+		}
+	}
+	return cr, nil
+}
+
+// String renders the range for humans, e.g. "bytes 0-1023 of 4096 (1024 bytes)".
+func (c contentRange) String() string {
+	total := "unknown size"
+	if c.total >= 0 {
+		total = strconv.FormatInt(c.total, 10)
+	}
+	if c.first < 0 {
+		return "no satisfiable bytes of " + total
+	}
+	return fmt.Sprintf("bytes %d-%d of %s (%d bytes)", c.first, c.last, total, c.last-c.first+1)
+}
+
+// download describes a response body written to disk with -o.
+type download struct {
+	path      string // File the body was written to.
+	offset    int64  // Byte position we resumed from, 0 for a fresh download.
+	written   int64  // Bytes written during this run.
+	done      bool   // The server said there was nothing left to fetch.
+	restarted error  // Why a resume started over from the first byte, if it did.
+}
+
+// summary describes what happened to the file, e.g.
+// "saved 1024 bytes to out.bin (resumed at byte 3072)".
+func (d *download) summary() string {
+	if d.done {
+		return d.path + " is already complete"
+	}
+	s := fmt.Sprintf("saved %d bytes to %s", d.written, d.path)
+	switch {
+	case d.restarted != nil:
+		s += fmt.Sprintf(" (started over: %v)", d.restarted)
+	case d.offset > 0:
+		s += fmt.Sprintf(" (resumed at byte %d)", d.offset)
+	}
+	return s
+}
+
+// resumeMismatch is a 416 to a resume of a file that isn't a part of the
+// server's: it is as long or longer, but not the same length.
+type resumeMismatch struct {
+	path        string
+	have, total int64 // total is -1 when the server didn't say.
+}
+
+func (e resumeMismatch) Error() string {
+	if e.total < 0 {
+		return fmt.Sprintf("the server can't resume %s at byte %d, and didn't say how long it is", e.path, e.have)
+	}
+	return fmt.Sprintf("%s has %d bytes, but the server's has %d", e.path, e.have, e.total)
+}
+
+// A download in progress keeps the ETag, or failing that the Last-Modified
+// date, of what it is downloading beside it, in a file named after it with
+// ".resume" on the end. A resume sends it as If-Range, so that a resource
+// changed since gets downloaded whole rather than spliced onto the old
+// part. A download that finishes removes it.
+
+// resumeFile is where the validator of a download to path is kept.
+func resumeFile(path string) string {
+	return path + ".resume"
+}
+
+// resumeValidator returns the validator kept for the download to path, or
+// "" if there is none.
+func resumeValidator(path string) string {
+	b, err := os.ReadFile(resumeFile(path))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// keepValidator keeps what of h a resume can send as If-Range. A weak ETag
+// can't be, as If-Range only takes strong ones.
+func keepValidator(path string, h http.Header) {
+	v := h.Get("ETag")
+	if v == "" || strings.HasPrefix(v, "W/") {
+		v = h.Get("Last-Modified")
+	}
+	if v != "" {
+		os.WriteFile(resumeFile(path), []byte(v+"\n"), 0o644)
+	}
+}
+
+// resumeOffset returns how many bytes of cfg.output are already on disk when
+// -resume is set, which is where the ranged request should pick up from.
+func resumeOffset(cfg config) int64 {
+	if !cfg.resume || cfg.output == "" {
+		return 0
+	}
+	fi, err := os.Stat(cfg.output)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+// saveBody streams res.Body into cfg.output. When we asked to resume at
+// offset and the server honoured it with a matching 206, the new bytes are
+// appended; if it sent the whole resource instead, the file is rewritten.
+// Any other answer leaves the file alone, since it may be the partial
+// download we were asked to resume. A 416 to a resume is a resumeMismatch
+// unless the file is as long as the server says the resource is.
+func saveBody(res *http.Response, cfg config, offset int64) (*download, error) {
+	d := &download{path: cfg.output}
+
+	if offset > 0 && res.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		cr, err := parseContentRange(res.Header.Get("Content-Range"))
+		if err != nil {
+			cr.total = -1
+		}
+		if cr.total != offset {
+			return nil, resumeMismatch{path: cfg.output, have: offset, total: cr.total}
+		}
+		os.Remove(resumeFile(cfg.output))
+		d.done = true
+		return d, nil
+	}
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("server answered %s, so %s was left as it was", res.Status, cfg.output)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 && res.StatusCode == http.StatusPartialContent {
+		cr, err := parseContentRange(res.Header.Get("Content-Range"))
+		if err != nil {
+			return nil, err
+		}
+		if cr.first != offset {
+			return nil, errors.New("server resumed at a different byte than we asked for")
+		}
+		flags = os.O_WRONLY | os.O_APPEND
+		d.offset = offset
+	}
+
+	f, err := os.OpenFile(cfg.output, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	keepValidator(cfg.output, res.Header)
+	if d.written, err = io.Copy(f, res.Body); err != nil {
+		return d, err
+	}
+	os.Remove(resumeFile(cfg.output))
+	return d, nil
+}
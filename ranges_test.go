@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    contentRange
+		wantErr bool
+	}{
+		{in: "bytes 0-1023/4096", want: contentRange{0, 1023, 4096}},
+		{in: "bytes 100-199/*", want: contentRange{100, 199, -1}},
+		{in: "bytes */500", want: contentRange{-1, -1, 500}},
+		{in: " bytes 5-5/6 ", want: contentRange{5, 5, 6}},
+		{in: "items 0-1/2", wantErr: true},
+		{in: "bytes 0-1", wantErr: true},
+		{in: "bytes 9-3/10", wantErr: true},
+		{in: "bytes x-3/10", wantErr: true},
+		{in: "bytes 0-3/big", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseContentRange(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseContentRange(%q) = %+v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseContentRange(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+// answer returns a response with the given status, headers and body.
+func answer(status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// partialFile writes a partial download and returns a config resuming it.
+func partialFile(t *testing.T, content string) config {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "out.bin")
+	if err := os.WriteFile(out, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return config{output: out, resume: true}
+}
+
+func TestSaveBodyAppendsOnResume(t *testing.T) {
+	cfg := partialFile(t, "0123")
+	res := answer(http.StatusPartialContent, http.Header{"Content-Range": {"bytes 4-9/10"}}, "456789")
+
+	d, err := saveBody(res, cfg, resumeOffset(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.offset != 4 || d.written != 6 {
+		t.Errorf("download = %+v, want 6 bytes from offset 4", d)
+	}
+	if got, _ := os.ReadFile(cfg.output); string(got) != "0123456789" {
+		t.Errorf("file = %q", got)
+	}
+}
+
+func TestSaveBodyRewritesOnFullAnswer(t *testing.T) {
+	cfg := partialFile(t, "old")
+	if _, err := saveBody(answer(http.StatusOK, nil, "whole"), cfg, 3); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(cfg.output); string(got) != "whole" {
+		t.Errorf("file = %q, want whole", got)
+	}
+}
+
+func TestSaveBodyRejectsWrongOffset(t *testing.T) {
+	cfg := partialFile(t, "0123")
+	res := answer(http.StatusPartialContent, http.Header{"Content-Range": {"bytes 2-9/10"}}, "23456789")
+	if _, err := saveBody(res, cfg, 4); err == nil {
+		t.Error("saveBody accepted a 206 starting at the wrong byte")
+	}
+}
+
+func TestSaveBodyKeepsFileOnError(t *testing.T) {
+	cfg := partialFile(t, "PARTIAL-DOWNLOAD-DATA")
+	_, err := saveBody(answer(http.StatusServiceUnavailable, nil, "down"), cfg, resumeOffset(cfg))
+	if err == nil || !strings.Contains(err.Error(), "Service Unavailable") {
+		t.Errorf("saveBody error = %v, want one naming the status", err)
+	}
+	if got, _ := os.ReadFile(cfg.output); string(got) != "PARTIAL-DOWNLOAD-DATA" {
+		t.Errorf("file = %q, want it untouched", got)
+	}
+}
+
+func TestSaveBody416(t *testing.T) {
+	cfg := partialFile(t, "complete")
+	whole := http.Header{"Content-Range": {"bytes */8"}}
+	d, err := saveBody(answer(http.StatusRequestedRangeNotSatisfiable, whole, ""), cfg, resumeOffset(cfg))
+	if err != nil || !d.done {
+		t.Errorf("resumed 416 = %+v, %v, want done", d, err)
+	}
+
+	// A file longer than the server's, or one it doesn't give the length
+	// of, isn't complete.
+	for _, h := range []http.Header{{"Content-Range": {"bytes */5"}}, nil} {
+		if _, err := saveBody(answer(http.StatusRequestedRangeNotSatisfiable, h, ""), cfg, resumeOffset(cfg)); !errors.As(err, new(resumeMismatch)) {
+			t.Errorf("416 with %v: %v", h, err)
+		}
+	}
+
+	// Without a resume, a 416 just means the range was wrong.
+	if _, err := saveBody(answer(http.StatusRequestedRangeNotSatisfiable, nil, ""), cfg, 0); err == nil {
+		t.Error("a 416 without a resume counted as complete")
+	}
+}
+
+func TestResumeOffset(t *testing.T) {
+	cfg := partialFile(t, "12345")
+	if got := resumeOffset(cfg); got != 5 {
+		t.Errorf("resumeOffset = %d, want 5", got)
+	}
+	cfg.resume = false
+	if got := resumeOffset(cfg); got != 0 {
+		t.Errorf("resumeOffset without -resume = %d, want 0", got)
+	}
+	cfg = config{output: filepath.Join(t.TempDir(), "missing"), resume: true}
+	if got := resumeOffset(cfg); got != 0 {
+		t.Errorf("resumeOffset of a missing file = %d, want 0", got)
+	}
+}
+
+func TestResumeStartsOver(t *testing.T) {
+	content := "the new version"
+	var ifRange []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifRange = append(ifRange, r.Header.Get("If-Range"))
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, r, "file", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	// Longer than the server's file, so its 416 can't mean it's done.
+	cfg := partialFile(t, "the old version, which was longer")
+	cfg.method, cfg.url = "GET", srv.URL
+	os.WriteFile(resumeFile(cfg.output), []byte(`"v2"`+"\n"), 0o644)
+	res, ok := send(cfg).(responseMsg)
+	if !ok || res.saved == nil || res.saved.restarted == nil {
+		t.Fatalf("send = %+v", res)
+	}
+	if got, _ := os.ReadFile(cfg.output); string(got) != content {
+		t.Errorf("file = %q", got)
+	}
+	if len(ifRange) != 2 || ifRange[0] != `"v2"` || ifRange[1] != "" {
+		t.Errorf("If-Range sent: %q", ifRange)
+	}
+	if _, err := os.Stat(resumeFile(cfg.output)); err == nil {
+		t.Error("a finished download kept its .resume")
+	}
+
+	// A part of an older version is replaced, not added to.
+	os.WriteFile(cfg.output, []byte("the old"), 0o644)
+	os.WriteFile(resumeFile(cfg.output), []byte(`"v1"`+"\n"), 0o644)
+	if res, ok := send(cfg).(responseMsg); !ok || res.status != http.StatusOK {
+		t.Fatalf("send = %+v", res)
+	}
+	if got, _ := os.ReadFile(cfg.output); string(got) != content {
+		t.Errorf("file = %q", got)
+	}
+}
+
+func TestKeepValidator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.bin")
+	keepValidator(path, http.Header{"Etag": {`W/"weak"`}, "Last-Modified": {"Wed, 21 Oct 2015 07:28:00 GMT"}})
+	if v := resumeValidator(path); v != "Wed, 21 Oct 2015 07:28:00 GMT" {
+		t.Errorf("validator = %q", v)
+	}
+	keepValidator(path, http.Header{"Etag": {`"strong"`}})
+	if v := resumeValidator(path); v != `"strong"` {
+		t.Errorf("validator = %q", v)
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// defaultPings is how many probes ping and port send when not told.
+const defaultPings = 4
+
+// pingInterval is the pause between two probes, as with ping(8).
+const pingInterval = time.Second
+
+// probeTimeout is how long one probe waits for its answer.
+const probeTimeout = 2 * time.Second
+
+// runPing is the palette's `ping [count]`.
+func runPing(m model, args []string) (tea.Model, tea.Cmd) {
+	count, err := countArg(args, 0)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	host, _, err := hostPort(m.cfg.url)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	m.job = "Pinging " + host
+	return m, runJob(func(report func(string)) tea.Msg {
+		body, err := pingHost(host, count, pingInterval, report)
+		if err != nil {
+			return reportMsg{title: "Ping " + host, body: err.Error()}
+		}
+		return reportMsg{title: "Ping " + host, body: body}
+	})
+}
+
+// runPortCheck is the palette's `port [port] [count]`.
+func runPortCheck(m model, args []string) (tea.Model, tea.Cmd) {
+	host, port, err := hostPort(m.cfg.url)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	if len(args) > 0 {
+		if n, err := strconv.Atoi(args[0]); err != nil || n < 1 || n > 65535 {
+			return m.paletteError(fmt.Errorf("%q is not a port number", args[0]))
+		}
+		port = args[0]
+	}
+	count, err := countArg(args, 1)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	addr := net.JoinHostPort(host, port)
+	m.job = "Connecting to " + addr
+	return m, runJob(func(report func(string)) tea.Msg {
+		return reportMsg{title: "Port check " + addr, body: checkPort(addr, count, pingInterval, report)}
+	})
+}
+
+// paletteError shows why a palette command couldn't run, keeping the
+// response on screen.
+func (m model) paletteError(err error) (tea.Model, tea.Cmd) {
+	m.report, m.cursor = &reportMsg{title: "Command", body: err.Error()}, 0
+	return m, nil
+}
+
+// countArg reads the probe count from args[i], if it is there.
+func countArg(args []string, i int) (int, error) {
+	if len(args) <= i {
+		return defaultPings, nil
+	}
+	n, err := strconv.Atoi(args[i])
+	if err != nil || n < 1 || n > 100 {
+		return 0, fmt.Errorf("%q is not a count from 1 to 100", args[i])
+	}
+	return n, nil
+}
+
+// hostPort returns the host of a URL and the port it is reached on.
+func hostPort(raw string) (host, port string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Hostname() == "" {
+		return "", "", fmt.Errorf("%q has no host", raw)
+	}
+	port = u.Port()
+	if port == "" {
+		if port = defaultPort(u.Scheme); port == "" {
+			return "", "", fmt.Errorf("no default port for %s URLs; name one", u.Scheme)
+		}
+	}
+	return u.Hostname(), port, nil
+}
+
+// defaultPort is the port of a scheme, as far as this program speaks it.
+func defaultPort(scheme string) string {
+	switch scheme {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	case "ftp":
+		return "21"
+	case "sftp":
+		return "22"
+	}
+	return ""
+}
+
+// checkPort opens count TCP connections to addr, one every interval, and
+// describes how long each took and how they went overall.
+func checkPort(addr string, count int, interval time.Duration, report func(string)) string {
+	var b strings.Builder
+	var rtts []time.Duration
+	for i := range count {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		report(fmt.Sprintf("Connecting to %s (%d of %d)", addr, i+1, count))
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", addr, probeTimeout)
+		rtt := time.Since(start)
+		if err != nil {
+			fmt.Fprintf(&b, "#%d  %v\n", i+1, err)
+			continue
+		}
+		fmt.Fprintf(&b, "#%d  connected to %s in %s\n", i+1, conn.RemoteAddr(), rtt.Round(time.Microsecond))
+		conn.Close()
+		rtts = append(rtts, rtt)
+	}
+	b.WriteString(latencyStats(count, rtts))
+	return b.String()
+}
+
+// pingHost sends count ICMP echo requests to host, one every interval. It
+// uses an unprivileged ping socket where the system allows them, as Linux
+// does for groups in net.ipv4.ping_group_range, and a raw socket otherwise.
+func pingHost(host string, count int, interval time.Duration, report func(string)) (string, error) {
+	ip, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return "", err
+	}
+	v4 := ip.IP.To4() != nil
+	networks, listen, proto := []string{"udp6", "ip6:ipv6-icmp"}, "::", 58
+	var echo icmp.Type = ipv6.ICMPTypeEchoRequest
+	var reply icmp.Type = ipv6.ICMPTypeEchoReply
+	if v4 {
+		networks, listen, proto = []string{"udp4", "ip4:icmp"}, "0.0.0.0", 1
+		echo, reply = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	}
+
+	var conn *icmp.PacketConn
+	var network string
+	var errs []error
+	for _, network = range networks {
+		if conn, err = icmp.ListenPacket(network, listen); err == nil {
+			break
+		}
+		errs = append(errs, err)
+	}
+	if conn == nil {
+		return "", fmt.Errorf("ICMP ping isn't permitted here (%w); the port command checks reachability over TCP instead", errors.Join(errs...))
+	}
+	defer conn.Close()
+	var dst net.Addr = ip
+	if strings.HasPrefix(network, "udp") {
+		dst = &net.UDPAddr{IP: ip.IP, Zone: ip.Zone}
+	}
+
+	var b strings.Builder
+	var rtts []time.Duration
+	id := os.Getpid() & 0xffff // A ping socket replaces it with its own.
+	for seq := 1; seq <= count; seq++ {
+		if seq > 1 {
+			time.Sleep(interval)
+		}
+		report(fmt.Sprintf("Pinging %s (%d of %d)", ip, seq, count))
+		msg, _ := (&icmp.Message{Type: echo, Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("HTTPWizardTUI")}}).Marshal(nil)
+		start := time.Now()
+		if _, err := conn.WriteTo(msg, dst); err != nil {
+			fmt.Fprintf(&b, "seq=%d  %v\n", seq, err)
+			continue
+		}
+		rtt, err := awaitEcho(conn, proto, reply, seq, start)
+		if err != nil {
+			fmt.Fprintf(&b, "seq=%d  %v\n", seq, err)
+			continue
+		}
+		fmt.Fprintf(&b, "seq=%d  reply from %s in %s\n", seq, ip, rtt.Round(time.Microsecond))
+		rtts = append(rtts, rtt)
+	}
+	b.WriteString(latencyStats(count, rtts))
+	return b.String(), nil
+}
+
+// awaitEcho reads from conn until the echo reply numbered seq arrives, and
+// returns how long after start that was. Other packets are skipped.
+func awaitEcho(conn *icmp.PacketConn, proto int, reply icmp.Type, seq int, start time.Time) (time.Duration, error) {
+	conn.SetReadDeadline(start.Add(probeTimeout))
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return 0, fmt.Errorf("no reply within %s", probeTimeout)
+			}
+			return 0, err
+		}
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil || msg.Type != reply {
+			continue
+		}
+		if e, ok := msg.Body.(*icmp.Echo); ok && e.Seq == seq {
+			return time.Since(start), nil
+		}
+	}
+}
+
+// latencyStats sums up a run of probes like ping(8) does: how many were
+// answered, and the minimum, average, maximum and deviation of the times.
+func latencyStats(sent int, rtts []time.Duration) string {
+	loss := 100 * float64(sent-len(rtts)) / float64(sent)
+	s := fmt.Sprintf("\n%d sent, %d answered, %.0f%% lost", sent, len(rtts), loss)
+	if len(rtts) == 0 {
+		return s
+	}
+	var sum time.Duration
+	for _, d := range rtts {
+		sum += d
+	}
+	avg := sum / time.Duration(len(rtts))
+	var sq float64
+	for _, d := range rtts {
+		sq += math.Pow(float64(d-avg), 2)
+	}
+	dev := time.Duration(math.Sqrt(sq / float64(len(rtts))))
+	r := func(d time.Duration) time.Duration { return d.Round(time.Microsecond) }
+	return s + fmt.Sprintf("\nmin/avg/max/mdev = %s/%s/%s/%s", r(slices.Min(rtts)), r(avg), r(slices.Max(rtts)), r(dev))
+}
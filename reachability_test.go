@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHostPort(t *testing.T) {
+	tests := []struct{ url, host, port string }{
+		{"https://example.com/x", "example.com", "443"},
+		{"http://example.com:8080", "example.com", "8080"},
+		{"http://[::1]/", "::1", "80"},
+		{"sftp://files.example.com/a", "files.example.com", "22"},
+	}
+	for _, tt := range tests {
+		host, port, err := hostPort(tt.url)
+		if err != nil || host != tt.host || port != tt.port {
+			t.Errorf("hostPort(%q) = %q, %q, %v", tt.url, host, port, err)
+		}
+	}
+	if _, _, err := hostPort("gopher://example.com/"); err == nil {
+		t.Error("hostPort guessed a port for gopher")
+	}
+}
+
+func TestCountArg(t *testing.T) {
+	if n, err := countArg(nil, 0); n != defaultPings || err != nil {
+		t.Errorf("no count = %d, %v", n, err)
+	}
+	if n, err := countArg([]string{"443", "7"}, 1); n != 7 || err != nil {
+		t.Errorf("count 7 = %d, %v", n, err)
+	}
+	for _, bad := range []string{"0", "101", "x"} {
+		if _, err := countArg([]string{bad}, 0); err == nil {
+			t.Errorf("countArg accepted %q", bad)
+		}
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	got := latencyStats(4, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond})
+	want := "\n4 sent, 3 answered, 25% lost\nmin/avg/max/mdev = 10ms/20ms/30ms/8.165ms"
+	if got != want {
+		t.Errorf("latencyStats = %q, want %q", got, want)
+	}
+	if got := latencyStats(2, nil); got != "\n2 sent, 0 answered, 100% lost" {
+		t.Errorf("latencyStats with no answers = %q", got)
+	}
+}
+
+func TestCheckPort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	addr := ln.Addr().String()
+	body := checkPort(addr, 2, 0, func(string) {})
+	if strings.Count(body, "connected to "+addr) != 2 || !strings.Contains(body, "2 sent, 2 answered, 0% lost") {
+		t.Errorf("open port:\n%s", body)
+	}
+
+	// Once nothing listens, every attempt is refused.
+	ln.Close()
+	body = checkPort(addr, 2, 0, func(string) {})
+	if !strings.Contains(body, "refused") || !strings.Contains(body, "100% lost") {
+		t.Errorf("closed port:\n%s", body)
+	}
+}
+
+func TestPingHost(t *testing.T) {
+	body, err := pingHost("127.0.0.1", 1, 0, func(string) {})
+	if err != nil {
+		if strings.Contains(err.Error(), "isn't permitted") {
+			t.Skip(err)
+		}
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, "seq=1  reply from 127.0.0.1") {
+		t.Errorf("ping:\n%s", body)
+	}
+}
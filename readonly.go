@@ -0,0 +1,23 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// readOnlyMethods are the only methods read-only mode sends.
+var readOnlyMethods = []string{http.MethodGet, http.MethodHead}
+
+// errReadOnly is what saving anything fails with in read-only mode.
+var errReadOnly = errors.New("read-only mode: nothing is saved")
+
+// readOnlyBlocks says why read-only mode won't send the request described
+// by cfg, or returns nil if it will.
+func readOnlyBlocks(cfg config) error {
+	if cfg.readOnly && !slices.Contains(readOnlyMethods, cfg.method) {
+		return fmt.Errorf("read-only mode: %s requests are not sent, only GET and HEAD", cfg.method)
+	}
+	return nil
+}
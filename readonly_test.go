@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	sent := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { sent++ }))
+	defer srv.Close()
+
+	for _, method := range []string{"GET", "HEAD"} {
+		if _, ok := send(config{method: method, url: srv.URL, readOnly: true}).(responseMsg); !ok {
+			t.Errorf("%s wasn't sent in read-only mode", method)
+		}
+	}
+	for _, method := range []string{"POST", "PUT", "PATCH", "DELETE", "OPTIONS"} {
+		e, ok := send(config{method: method, url: srv.URL, readOnly: true}).(errMsg)
+		if !ok || !strings.Contains(e.err.Error(), "read-only mode: "+method+" requests are not sent") {
+			t.Errorf("%s in read-only mode = %+v", method, e)
+		}
+	}
+	if sent != 2 {
+		t.Errorf("the server got %d requests, want 2", sent)
+	}
+
+	if r := runScript(config{readOnly: true}, runStep{script: []string{"true"}}); r.failure != "read-only mode: commands are not run" {
+		t.Errorf("hook in read-only mode: %+v", r)
+	}
+}
+
+func TestReadOnlySLO(t *testing.T) {
+	m := model{cfg: config{method: "GET", url: "https://example.com/", sloFile: filepath.Join(t.TempDir(), "slo.json"), readOnly: true}}
+	next, _ := runSLO(m, []string{"300ms", "99.5"})
+	if r := next.(model).report; r == nil || !strings.Contains(r.body, "read-only mode") {
+		t.Errorf("report = %+v", r)
+	}
+}
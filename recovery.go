@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// A run that ends without quitting, in a crash or with the terminal gone,
+// saves no session. So every few seconds the tabs are kept in a recovery
+// file as well, with whatever was being edited in them: the line of the
+// resend prompt, or the headers or parameters in their editor. Quitting
+// removes the file; finding it on start means the last run didn't quit,
+// and its tabs and edits are opened from it rather than from the session.
+
+// autosaveEvery is how often the tabs are kept in the recovery file.
+const autosaveEvery = 3 * time.Second
+
+// savedEdit is an edit that was under way in a tab, as the recovery file
+// keeps it.
+type savedEdit struct {
+	Kind string `json:"kind"` // resend, headers or params.
+
+	// Line is the resend prompt's line, or the row of the editor that was
+	// being edited.
+	Line string `json:"line,omitempty"`
+
+	Pairs   [][2]string `json:"pairs,omitempty"`
+	Cursor  int         `json:"cursor,omitempty"`
+	Editing bool        `json:"editing,omitempty"`
+	Raw     bool        `json:"raw,omitempty"`
+	Text    string      `json:"text,omitempty"` // The raw text block, when Raw.
+}
+
+// recoveryFile is where the tabs are autosaved, beside the session file.
+func recoveryFile(sessionFile string) string {
+	return strings.TrimSuffix(sessionFile, ".json") + ".recovery.json"
+}
+
+// editOf returns the edit under way in m, or nil if there is none.
+func editOf(m model) *savedEdit {
+	switch {
+	case m.prompt != nil:
+		return &savedEdit{Kind: "resend", Line: m.prompt.Value()}
+	case m.kvEditor != nil:
+		e := m.kvEditor
+		kind := "headers"
+		if e.kind == kvParams {
+			kind = "params"
+		}
+		s := &savedEdit{Kind: kind, Pairs: e.pairs, Cursor: e.cursor, Editing: e.editing, Raw: e.raw}
+		if e.editing {
+			s.Line = e.input.Value()
+		}
+		if e.raw {
+			s.Text = e.area.Value()
+		}
+		return s
+	}
+	return nil
+}
+
+// reopen opens the edit in m again, as it was.
+func (s savedEdit) reopen(m model) model {
+	switch s.Kind {
+	case "resend":
+		m.prompt = newPrompt(m.cfg)
+		m.prompt.SetValue(s.Line)
+		m.prompt.CursorEnd()
+	case "headers", "params":
+		kind := kvHeaders
+		if s.Kind == "params" {
+			kind = kvParams
+		}
+		e := newKVEditor(kind, s.Pairs)
+		e.cursor = min(max(s.Cursor, 0), max(len(s.Pairs)-1, 0))
+		if s.Editing && len(s.Pairs) > 0 {
+			e.editing = true
+			e.input.SetValue(s.Line)
+			e.input.Focus()
+		}
+		if s.Raw {
+			e.raw = true
+			e.area.SetValue(s.Text)
+			e.area.Focus()
+		}
+		m.kvEditor = e
+	}
+	return m
+}
+
+// autosaveMsg says it is time to keep the tabs in the recovery file.
+type autosaveMsg struct{}
+
+// autosaveTick waits until the tabs are next due to be kept.
+func autosaveTick() tea.Cmd {
+	return tea.Tick(autosaveEvery, func(time.Time) tea.Msg { return autosaveMsg{} })
+}
+
+// autosave keeps the tabs in the recovery file if they have changed since
+// they last were. Failing to is no reason to stop; the next tick tries
+// again.
+func (a app) autosave() app {
+	file := a.tabs[0].cfg.sessionFile
+	if file == "" {
+		return a
+	}
+	s := a.session()
+	s.Saved = time.Time{}
+	kept, err := json.Marshal(s)
+	if err != nil || bytes.Equal(kept, a.autosaved) {
+		return a
+	}
+	s.Saved = time.Now().UTC()
+	if err := saveSession(recoveryFile(file), s); err != nil {
+		logf(logWarn, "Couldn't autosave the tabs: %v", err)
+		return a
+	}
+	a.autosaved = kept
+	return a
+}
+
+// loadLastSession reads the tabs to open on start: those of the recovery
+// file, if the last run left one, and those of the session otherwise.
+// recovered says which it was.
+func loadLastSession(sessionFile string) (s session, recovered bool, err error) {
+	s, err = loadSession(recoveryFile(sessionFile))
+	if err != nil || len(s.Tabs) > 0 {
+		return s, err == nil, err
+	}
+	s, err = loadSession(sessionFile)
+	return s, false, err
+}
+
+// recoveredNote is the report a tab whose edit was recovered opens with.
+func recoveredNote(saved time.Time) *reportMsg {
+	return &reportMsg{title: "Recovered", body: fmt.Sprintf("The program stopped without quitting; this is the edit you were making at %s. esc drops it.", saved.Local().Format(time.DateTime))}
+}
+
+// removeRecovery removes the recovery file, once the tabs are safely kept
+// in the session.
+func removeRecovery(sessionFile string) error {
+	err := os.Remove(recoveryFile(sessionFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
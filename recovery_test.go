@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAutosaveRecovers(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.json")
+	cfg := config{method: "GET", url: "https://example.com/", header: http.Header{"Accept": {"*/*"}}, sessionFile: file}
+
+	headers := model{cfg: cfg}
+	headers.kvEditor = newKVEditor(kvHeaders, headerPairs(cfg.header))
+	headers.kvEditor.raw = true
+	headers.kvEditor.area.SetValue("Accept: */*\nX-Half-Typed: yes")
+	resend := model{cfg: cfg}
+	resend.prompt = newPrompt(cfg)
+	resend.prompt.SetValue(`POST https://example.com/orders {"long": "body"}`)
+	a := app{tabs: []model{headers, resend}, active: 1}
+
+	a = a.autosave()
+	if a.autosaved == nil {
+		t.Fatal("nothing was autosaved")
+	}
+	// The run ends without quitting, so the next start finds the edits.
+	s, recovered, err := loadLastSession(file)
+	if err != nil || !recovered || len(s.Tabs) != 2 {
+		t.Fatalf("loadLastSession = %d tabs, recovered %v, %v", len(s.Tabs), recovered, err)
+	}
+	restored := restoreSession(model{cfg: cfg}, s, false, true)
+	h, r := restored.tabs[0], restored.tabs[1]
+	if h.kvEditor == nil || !h.kvEditor.raw || h.kvEditor.area.Value() != "Accept: */*\nX-Half-Typed: yes" {
+		t.Errorf("headers editor = %+v", h.kvEditor)
+	}
+	if r.prompt == nil || r.prompt.Value() != `POST https://example.com/orders {"long": "body"}` {
+		t.Errorf("resend prompt = %v", r.prompt)
+	}
+	if h.report == nil || h.report.title != "Recovered" {
+		t.Errorf("report = %+v", h.report)
+	}
+
+	// Quitting keeps the session and drops the recovery file.
+	if err := saveSession(file, a.session()); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeRecovery(file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(recoveryFile(file)); !os.IsNotExist(err) {
+		t.Errorf("the recovery file is still there: %v", err)
+	}
+	if _, recovered, _ := loadLastSession(file); recovered {
+		t.Error("a session that was quit counts as recovered")
+	}
+}
+
+func TestAutosaveUnchanged(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.json")
+	a := app{tabs: []model{{cfg: config{method: "GET", url: "https://example.com/", sessionFile: file}}}}
+	a = a.autosave()
+	os.Remove(recoveryFile(file))
+	if a.autosave(); fileExists(recoveryFile(file)) {
+		t.Error("the unchanged tabs were written again")
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// A variable's name turns up everywhere it is used: in the URLs, headers
+// and bodies of a collection's requests, in its hooks' commands, and in the
+// environments that define it. rename-var renames it in all of them at
+// once, {{old}} to {{new}}, and says how many of each it changed.
+
+// referencePattern matches the uses of the variable called name, as
+// {{name}} or, in a URL, escaped as %7B%7Bname%7D%7D.
+func referencePattern(name string) *regexp.Regexp {
+	return regexp.MustCompile(`((?i:\{\{|%7B%7B))\s*` + regexp.QuoteMeta(name) + `\s*((?i:\}\}|%7D%7D))`)
+}
+
+// renameReferences renames the uses of from in text to to, and counts them.
+func renameReferences(text, from, to string) (string, int) {
+	pattern := referencePattern(from)
+	n := len(pattern.FindAllStringIndex(text, -1))
+	return pattern.ReplaceAllString(text, "${1}"+to+"${2}"), n
+}
+
+// renameInEnvironments renames the variable from to to in envs: its
+// definitions, and its uses in the base URLs and the other variables'
+// values. It returns the environments that define it and how many uses
+// it renamed. An environment that defines both already can't be renamed.
+func renameInEnvironments(envs map[string]environment, from, to string) ([]string, int, error) {
+	var defined []string
+	uses := 0
+	for _, name := range envNames(envs) {
+		env := envs[name]
+		var n int
+		env.Base, n = renameReferences(env.Base, from, to)
+		uses += n
+		vars := map[string]string{}
+		for k, v := range env.Variables {
+			vars[k], n = renameReferences(v, from, to)
+			uses += n
+		}
+		if v, ok := vars[from]; ok {
+			if _, clash := vars[to]; clash {
+				return nil, 0, fmt.Errorf("the environment %s defines both %s and %s", name, from, to)
+			}
+			delete(vars, from)
+			vars[to] = v
+			defined = append(defined, name)
+		}
+		if env.Variables != nil {
+			env.Variables = vars
+		}
+		envs[name] = env
+	}
+	return defined, uses, nil
+}
+
+// countUses says how many uses n is.
+func countUses(n int) string {
+	if n == 1 {
+		return "1 use"
+	}
+	return fmt.Sprintf("%d uses", n)
+}
+
+// writeKeepingMode replaces the file at path with data, keeping its
+// permissions. The new file takes the old one's place whole, so a change
+// cut short leaves the old one as it was.
+func writeKeepingMode(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomicPerm(path, data, info.Mode().Perm())
+}
+
+// runRenameVar implements `rename-var [-env-file FILE] [-dry-run] OLD NEW
+// COLLECTION.json...`.
+func runRenameVar(args []string) error {
+	fs := flag.NewFlagSet("rename-var", flag.ExitOnError)
+	envFile := fs.String("env-file", defaultEnvFile(), "JSON `file` of named environments whose variables to rename too; \"\" for none")
+	dryRun := fs.Bool("dry-run", false, "only say what would be renamed, and change nothing")
+	fs.Parse(args)
+	if fs.NArg() < 3 {
+		return errors.New("usage: rename-var [-env-file FILE] [-dry-run] OLD NEW COLLECTION.json...")
+	}
+	return renameVariable(os.Stdout, fs.Arg(0), fs.Arg(1), fs.Args()[2:], *envFile, *dryRun)
+}
+
+// renameVariable renames the variable from to to in the collections at
+// files and in the environments file at envFile, if any, and says on w what
+// it changed. With dryRun, it only says.
+func renameVariable(w io.Writer, from, to string, files []string, envFile string, dryRun bool) error {
+	for _, name := range []string{from, to} {
+		if !variableName.MatchString(name) {
+			return fmt.Errorf("%q can't be a variable's name: use letters, digits and _, not starting with a digit", name)
+		}
+	}
+	if from == to {
+		return errors.New("the old and new names are the same")
+	}
+
+	// Work everything out before writing anything, so a problem with one
+	// file leaves them all as they were.
+	type change struct {
+		path    string
+		data    []byte
+		summary string
+	}
+	var changes []change
+	total := 0
+	for _, path := range files {
+		if _, err := loadCollection(path); err != nil {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		// The collection is rewritten as text, so that its layout stays as
+		// it was; braces are never escaped in JSON strings.
+		renamed, n := renameReferences(string(b), from, to)
+		if n > 0 {
+			changes = append(changes, change{path, []byte(renamed), countUses(n)})
+			total += n
+		}
+	}
+	if envFile != "" {
+		envs, err := loadEnvironments(envFile)
+		if err != nil {
+			return err
+		}
+		defined, uses, err := renameInEnvironments(envs, from, to)
+		if err != nil {
+			return err
+		}
+		if len(defined) > 0 || uses > 0 {
+			b, err := json.MarshalIndent(envs, "", "  ")
+			if err != nil {
+				return err
+			}
+			summary := countUses(uses)
+			if len(defined) > 0 {
+				summary = fmt.Sprintf("defined in %s, %s", strings.Join(defined, ", "), summary)
+			}
+			changes = append(changes, change{envFile, append(b, '\n'), summary})
+			total += len(defined) + uses
+		}
+	}
+
+	if total == 0 {
+		fmt.Fprintf(w, "Nothing uses or defines {{%s}}.\n", from)
+		return nil
+	}
+	for _, c := range changes {
+		if !dryRun {
+			if err := writeKeepingMode(c.path, c.data); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(w, "%s: %s\n", c.path, c.summary)
+	}
+	verb := "Renamed"
+	if dryRun {
+		verb = "Would rename"
+	}
+	fmt.Fprintf(w, "%s {{%s}} to {{%s}}: %d occurrences in %d files\n", verb, from, to, total, len(changes))
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenameReferences(t *testing.T) {
+	got, n := renameReferences(`{"url": "/users/{{ userId }}?x=%7B%7BuserId%7D%7D", "body": "{{userIdentity}} {{userId}}"}`, "userId", "accountId")
+	want := `{"url": "/users/{{accountId}}?x=%7B%7BaccountId%7D%7D", "body": "{{userIdentity}} {{accountId}}"}`
+	if got != want || n != 3 {
+		t.Errorf("got %d:\n%s\nwant\n%s", n, got, want)
+	}
+}
+
+func TestRenameVariable(t *testing.T) {
+	dir := t.TempDir()
+	coll := filepath.Join(dir, "pets.json")
+	layout := `{
+  "name": "Pets",
+  "requests": [
+    {"name": "Get", "url": "/pets/{{petId}}", "headers": {"X-Pet": "{{petId}}"}}
+  ],
+  "folders": [{"name": "F", "requests": [{"url": "/x", "body": "{\"id\": \"{{petId}}\"}"}]}],
+  "before": [{"run": "./seed.sh {{petId}}"}]
+}
+`
+	os.WriteFile(coll, []byte(layout), 0o640)
+	envFile := filepath.Join(dir, "environments.json")
+	os.WriteFile(envFile, []byte(`{"dev": {"base": "http://localhost/", "variables": {"petId": "7", "path": "/pets/{{petId}}"}}, "prod": {"base": "https://api.example.com/"}}`), 0o600)
+
+	var out strings.Builder
+	if err := renameVariable(&out, "petId", "animalId", []string{coll}, envFile, true); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(coll); string(b) != layout {
+		t.Error("a dry run changed the collection")
+	}
+	if !strings.HasSuffix(out.String(), "Would rename {{petId}} to {{animalId}}: 6 occurrences in 2 files\n") {
+		t.Errorf("dry run:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := renameVariable(&out, "petId", "animalId", []string{coll}, envFile, false); err != nil {
+		t.Fatal(err)
+	}
+	want := coll + ": 4 uses\n" + envFile + ": defined in dev, 1 use\nRenamed {{petId}} to {{animalId}}: 6 occurrences in 2 files\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+	b, _ := os.ReadFile(coll)
+	if string(b) != strings.ReplaceAll(layout, "{{petId}}", "{{animalId}}") {
+		t.Errorf("collection:\n%s", b)
+	}
+	if info, _ := os.Stat(coll); info.Mode().Perm() != 0o640 {
+		t.Errorf("mode = %v", info.Mode())
+	}
+	envs, err := loadEnvironments(envFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := envs["dev"].Variables; v["animalId"] != "7" || v["path"] != "/pets/{{animalId}}" || len(v) != 2 {
+		t.Errorf("dev variables = %v", v)
+	}
+	if envs["prod"].Variables != nil {
+		t.Errorf("prod variables = %v", envs["prod"].Variables)
+	}
+
+	out.Reset()
+	renameVariable(&out, "petId", "animalId", []string{coll}, envFile, false)
+	if out.String() != "Nothing uses or defines {{petId}}.\n" {
+		t.Errorf("again: %s", out.String())
+	}
+}
+
+func TestRenameVariableRefuses(t *testing.T) {
+	dir := t.TempDir()
+	coll := filepath.Join(dir, "c.json")
+	os.WriteFile(coll, []byte(`{"requests": [{"url": "/{{a}}"}]}`), 0o644)
+	envFile := filepath.Join(dir, "environments.json")
+	os.WriteFile(envFile, []byte(`{"dev": {"base": "http://localhost/", "variables": {"a": "1", "b": "2"}}}`), 0o644)
+
+	for _, c := range []struct{ from, to string }{{"a", "a"}, {"a", "1b"}, {"a", "b"}} {
+		if err := renameVariable(&strings.Builder{}, c.from, c.to, []string{coll}, envFile, false); err == nil {
+			t.Errorf("renaming %s to %s wasn't refused", c.from, c.to)
+		}
+	}
+	if b, _ := os.ReadFile(coll); string(b) != `{"requests": [{"url": "/{{a}}"}]}` {
+		t.Errorf("a refused rename changed the collection: %s", b)
+	}
+}
+
+func TestWriteKeepingMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.json")
+	os.WriteFile(path, []byte("old"), 0o640)
+	if err := writeKeepingMode(path, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if b, _ := os.ReadFile(path); err != nil || fi.Mode().Perm() != 0o640 || string(b) != "new" {
+		t.Errorf("%q with mode %v, %v", b, fi.Mode(), err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("left behind %d files", len(entries))
+	}
+}
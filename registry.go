@@ -0,0 +1,301 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Where services come and go, their addresses are known to a registry
+// rather than to DNS. An environment with "registry" sends a request to
+// NAME.service, or NAME.service.consul, to an instance of NAME that the
+// registry says is healthy, asked afresh each time:
+//
+//	{"staging": {"base": "http://orders.service/", "registry": {"consul": "http://consul.internal:8500", "select": "round-robin"}}}
+//	{"qa": {"base": "http://orders.service/", "registry": {"eureka": "http://eureka.internal:8761/eureka"}}}
+//
+// "select" is which instance: random, the default, round-robin, or pick,
+// whichever the palette's `instances` picked; one picked that way is used
+// whatever the select, while it is healthy. Consul's ACL token comes from
+// "token" or CONSUL_HTTP_TOKEN, and "tag" keeps to the instances tagged so.
+
+// registry is where an environment's services are looked up.
+type registry struct {
+	Consul string `json:"consul,omitempty"` // Consul's HTTP API, e.g. http://localhost:8500.
+	Eureka string `json:"eureka,omitempty"` // Eureka's REST API, e.g. http://localhost:8761/eureka.
+	Select string `json:"select,omitempty"` // random, round-robin or pick.
+	Tag    string `json:"tag,omitempty"`    // Consul only: the tag instances must have.
+	Token  string `json:"token,omitempty"`  // Consul only: the ACL token.
+}
+
+// registrySelects are the ways of choosing an instance.
+var registrySelects = []string{"random", "round-robin", "pick"}
+
+// serviceHost matches the hosts the registry is asked about, and the name
+// of the service.
+var serviceHost = regexp.MustCompile(`^([a-z0-9][-a-z0-9_]*)\.service(\.consul)?\.?$`)
+
+// serviceInstance is an instance of a service, healthy as of asking.
+type serviceInstance struct {
+	id   string
+	addr string // Its host and port.
+}
+
+// registryState keeps, by registry and service, the instance the next
+// round-robin request goes to and the one picked with `instances`.
+var registryState = struct {
+	sync.Mutex
+	next   map[string]int
+	picked map[string]string
+}{next: map[string]int{}, picked: map[string]string{}}
+
+// serviceName returns the service the host of target names, if any.
+func serviceName(target string) (string, bool) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", false
+	}
+	m := serviceHost.FindStringSubmatch(strings.ToLower(u.Hostname()))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// check reports what is wrong with r, if anything.
+func (r *registry) check() error {
+	switch {
+	case (r.Consul == "") == (r.Eureka == ""):
+		return errors.New("the registry needs one of consul and eureka")
+	case r.Select != "" && !slices.Contains(registrySelects, r.Select):
+		return fmt.Errorf("the registry's select %q is not one of %s", r.Select, strings.Join(registrySelects, ", "))
+	}
+	return nil
+}
+
+// key is what r's service is known by in registryState.
+func (r *registry) key(service string) string {
+	return r.Consul + r.Eureka + " " + service
+}
+
+// lookupService asks r for the healthy instances of service, with c.
+func lookupService(c *http.Client, r *registry, service string) ([]serviceInstance, error) {
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+	var instances []serviceInstance
+	var err error
+	if r.Consul != "" {
+		instances, err = askConsul(c, r, service)
+	} else {
+		instances, err = askEureka(c, r, service)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("looking up %s: %w", service, err)
+	}
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("the registry has no healthy instance of %s", service)
+	}
+	slices.SortFunc(instances, func(a, b serviceInstance) int { return strings.Compare(a.id, b.id) })
+	return instances, nil
+}
+
+// askRegistry GETs target and decodes its JSON into v.
+func askRegistry(c *http.Client, target string, header http.Header, v any) error {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	req.Header.Set("Accept", "application/json")
+	res, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return errors.New("the registry doesn't know it")
+	}
+	if res.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 1<<10))
+		return fmt.Errorf("the registry answered %s: %s", res.Status, strings.TrimSpace(string(b)))
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// askConsul lists the instances of service whose health checks pass.
+func askConsul(c *http.Client, r *registry, service string) ([]serviceInstance, error) {
+	q := url.Values{"passing": {"1"}}
+	if r.Tag != "" {
+		q.Set("tag", r.Tag)
+	}
+	header := http.Header{}
+	if token := cmp.Or(r.Token, os.Getenv("CONSUL_HTTP_TOKEN")); token != "" {
+		header.Set("X-Consul-Token", token)
+	}
+	var entries []struct {
+		Node struct {
+			Address string
+		}
+		Service struct {
+			ID      string
+			Address string
+			Port    int
+		}
+	}
+	target := strings.TrimSuffix(r.Consul, "/") + "/v1/health/service/" + url.PathEscape(service) + "?" + q.Encode()
+	if err := askRegistry(c, target, header, &entries); err != nil {
+		return nil, err
+	}
+	var out []serviceInstance
+	for _, e := range entries {
+		// An instance without an address of its own is on its node's.
+		host := cmp.Or(e.Service.Address, e.Node.Address)
+		out = append(out, serviceInstance{id: e.Service.ID, addr: net.JoinHostPort(host, strconv.Itoa(e.Service.Port))})
+	}
+	return out, nil
+}
+
+// askEureka lists the instances of service that are UP.
+func askEureka(c *http.Client, r *registry, service string) ([]serviceInstance, error) {
+	type port struct {
+		Port    int    `json:"$"`
+		Enabled string `json:"@enabled"`
+	}
+	var app struct {
+		Application struct {
+			Instance []struct {
+				InstanceID string `json:"instanceId"`
+				HostName   string `json:"hostName"`
+				IPAddr     string `json:"ipAddr"`
+				Status     string `json:"status"`
+				Port       port   `json:"port"`
+				SecurePort port   `json:"securePort"`
+			} `json:"instance"`
+		} `json:"application"`
+	}
+	target := strings.TrimSuffix(r.Eureka, "/") + "/apps/" + url.PathEscape(strings.ToUpper(service))
+	if err := askRegistry(c, target, http.Header{}, &app); err != nil {
+		return nil, err
+	}
+	var out []serviceInstance
+	for _, i := range app.Application.Instance {
+		if i.Status != "UP" {
+			continue
+		}
+		p := i.Port.Port
+		if i.Port.Enabled == "false" && i.SecurePort.Enabled == "true" {
+			p = i.SecurePort.Port
+		}
+		out = append(out, serviceInstance{id: i.InstanceID, addr: net.JoinHostPort(cmp.Or(i.IPAddr, i.HostName), strconv.Itoa(p))})
+	}
+	return out, nil
+}
+
+// chooseInstance picks the instance of service to send to, of those
+// healthy, as r's select says.
+func chooseInstance(r *registry, service string, instances []serviceInstance) (serviceInstance, error) {
+	key := r.key(service)
+	registryState.Lock()
+	defer registryState.Unlock()
+	if id, ok := registryState.picked[key]; ok {
+		if i := slices.IndexFunc(instances, func(s serviceInstance) bool { return s.id == id }); i >= 0 {
+			return instances[i], nil
+		}
+		if r.Select == "pick" {
+			return serviceInstance{}, fmt.Errorf("%s, the instance of %s picked, is no longer healthy; pick another with the palette's instances", id, service)
+		}
+	}
+	switch r.Select {
+	case "pick":
+		return serviceInstance{}, fmt.Errorf("no instance of %s is picked; pick one with the palette's instances", service)
+	case "round-robin":
+		n := registryState.next[key] % len(instances)
+		registryState.next[key] = n + 1
+		return instances[n], nil
+	}
+	return instances[rand.IntN(len(instances))], nil
+}
+
+// resolveService points cfg at an instance of the service its URL names,
+// if its environment has a registry.
+func resolveService(cfg config) (config, error) {
+	r := cfg.envs[cfg.env].Registry
+	service, ok := serviceName(cfg.url)
+	if r == nil || !ok {
+		return cfg, nil
+	}
+	instances, err := lookupService(newClient(cfg), r, service)
+	if err != nil {
+		return cfg, err
+	}
+	instance, err := chooseInstance(r, service, instances)
+	if err != nil {
+		return cfg, err
+	}
+	u, err := url.Parse(cfg.url)
+	if err != nil {
+		return cfg, err
+	}
+	u.Host = instance.addr
+	logf(logDebug, "Sent the request for %s to %s, at %s", service, instance.id, instance.addr)
+	cfg.url = u.String()
+	return cfg, nil
+}
+
+// runInstances is the palette's `instances [service]`: it lists the
+// healthy instances of the service, the request's by default, to pick the
+// one its requests go to.
+func runInstances(m model, args []string) (tea.Model, tea.Cmd) {
+	r := m.cfg.envs[m.cfg.env].Registry
+	if r == nil {
+		return m.paletteError(fmt.Errorf("the environment %q has no registry", m.cfg.env))
+	}
+	service, ok := serviceName(m.cfg.url)
+	if len(args) > 0 {
+		service, ok = strings.ToLower(args[0]), true
+	}
+	if !ok {
+		return m.paletteError(errors.New("the request isn't to a NAME.service host; name the service"))
+	}
+	title := "Instances of " + service
+	cfg := m.cfg
+	return m.openPicker(endpointPicker{
+		title:  title,
+		asking: "Asking the registry",
+		none:   "The registry has no healthy instance of " + service,
+		find: func() tea.Msg {
+			instances, err := lookupService(newClient(cfg), r, service)
+			msg := endpointsMsg{title: title, err: err}
+			for _, i := range instances {
+				msg.endpoints = append(msg.endpoints, endpoint{
+					label:  i.id + "  " + i.addr,
+					url:    func(current string) string { return current },
+					picked: func() { pickInstance(r, service, i.id) },
+				})
+			}
+			return msg
+		},
+	}, args[min(len(args), 1):])
+}
+
+// pickInstance has the requests to service go to the instance id.
+func pickInstance(r *registry, service, id string) {
+	registryState.Lock()
+	registryState.picked[r.key(service)] = id
+	registryState.Unlock()
+}
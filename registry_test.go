@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fakeRegistry serves Consul's health and Eureka's apps for the service
+// orders: Consul has two instances passing, of which one on its node's
+// address, and Eureka one UP and one DOWN.
+func fakeRegistry(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/health/service/orders":
+			if r.URL.Query().Get("passing") != "1" || r.Header.Get("X-Consul-Token") != "secret" {
+				http.Error(w, "bad query", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`[
+  {"Node": {"Address": "10.0.0.2"}, "Service": {"ID": "orders-b", "Address": "", "Port": 8081}},
+  {"Node": {"Address": "10.0.0.1"}, "Service": {"ID": "orders-a", "Address": "10.0.1.1", "Port": 8080}}
+]`))
+		case "/eureka/apps/ORDERS":
+			w.Write([]byte(`{"application": {"name": "ORDERS", "instance": [
+  {"instanceId": "orders:1", "hostName": "orders-1", "ipAddr": "10.0.2.1", "status": "UP", "port": {"$": 9000, "@enabled": "true"}, "securePort": {"$": 443, "@enabled": "false"}},
+  {"instanceId": "orders:2", "hostName": "orders-2", "ipAddr": "10.0.2.2", "status": "DOWN", "port": {"$": 9000, "@enabled": "true"}}
+]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestServiceName(t *testing.T) {
+	for target, want := range map[string]string{
+		"http://orders.service/api":             "orders",
+		"http://Orders.service.consul:8080/api": "orders",
+		"http://orders.example.com/":            "",
+		"http://service/":                       "",
+	} {
+		if got, _ := serviceName(target); got != want {
+			t.Errorf("serviceName(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestLookupService(t *testing.T) {
+	srv := fakeRegistry(t)
+	c := srv.Client()
+	consul, err := lookupService(c, &registry{Consul: srv.URL, Token: "secret"}, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(consul) != 2 || consul[0] != (serviceInstance{"orders-a", "10.0.1.1:8080"}) || consul[1] != (serviceInstance{"orders-b", "10.0.0.2:8081"}) {
+		t.Errorf("consul = %v", consul)
+	}
+	eureka, err := lookupService(c, &registry{Eureka: srv.URL + "/eureka/"}, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eureka) != 1 || eureka[0] != (serviceInstance{"orders:1", "10.0.2.1:9000"}) {
+		t.Errorf("eureka = %v", eureka)
+	}
+	if _, err := lookupService(c, &registry{Eureka: srv.URL + "/eureka"}, "billing"); err == nil || !strings.Contains(err.Error(), "doesn't know") {
+		t.Errorf("unknown service: %v", err)
+	}
+	if _, err := lookupService(c, &registry{Consul: srv.URL, Eureka: srv.URL}, "orders"); err == nil {
+		t.Error("a registry with both consul and eureka was taken")
+	}
+}
+
+func TestChooseInstance(t *testing.T) {
+	instances := []serviceInstance{{"a", "10.0.0.1:80"}, {"b", "10.0.0.2:80"}}
+	r := &registry{Consul: "http://round-robin.test", Select: "round-robin"}
+	var got []string
+	for range 3 {
+		i, err := chooseInstance(r, "orders", instances)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, i.id)
+	}
+	if strings.Join(got, "") != "aba" {
+		t.Errorf("round-robin went to %v", got)
+	}
+
+	r = &registry{Consul: "http://pick.test", Select: "pick"}
+	if _, err := chooseInstance(r, "orders", instances); err == nil {
+		t.Error("pick chose without an instance picked")
+	}
+	pickInstance(r, "orders", "b")
+	if i, err := chooseInstance(r, "orders", instances); err != nil || i.id != "b" {
+		t.Errorf("picked b, got %v, %v", i, err)
+	}
+	if _, err := chooseInstance(r, "orders", instances[:1]); err == nil || !strings.Contains(err.Error(), "no longer healthy") {
+		t.Errorf("picked b gone: %v", err)
+	}
+}
+
+func TestResolveServiceSend(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("from " + r.URL.Path))
+	}))
+	defer backend.Close()
+	addr := strings.TrimPrefix(backend.URL, "http://")
+	reg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]map[string]any{{"Service": map[string]any{"ID": "orders-1", "Address": "127.0.0.1", "Port": backend.Listener.Addr().(*net.TCPAddr).Port}}})
+	}))
+	defer reg.Close()
+
+	cfg := config{method: "GET", url: "http://orders.service/api/orders", header: http.Header{},
+		env: "staging", envs: map[string]environment{"staging": {Registry: &registry{Consul: reg.URL}}}}
+	resolved, err := resolveService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(resolved.url)
+	if u.Host != addr || u.Path != "/api/orders" {
+		t.Errorf("resolved to %s", resolved.url)
+	}
+	msg, ok := send(cfg).(responseMsg)
+	if !ok || string(msg.body) != "from /api/orders" {
+		t.Errorf("send = %#v", msg)
+	}
+}
+
+func TestInstancesPicker(t *testing.T) {
+	srv := fakeRegistry(t)
+	r := &registry{Consul: srv.URL, Token: "secret", Select: "pick"}
+	m := model{cfg: config{method: "GET", url: "http://orders.service/api", header: http.Header{},
+		env: "staging", envs: map[string]environment{"staging": {Registry: r}}}}
+	next, cmd := runInstances(m, nil)
+	next, _ = next.(model).Update(cmd())
+	next, _ = next.(model).Update(tea.KeyMsg{Type: tea.KeyDown})
+	if v := next.(model).View(); !strings.Contains(v, "> orders-b  10.0.0.2:8081") {
+		t.Errorf("view:\n%s", v)
+	}
+	next, _ = next.(model).Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if p := next.(model).prompt; p == nil || p.Value() != "GET http://orders.service/api" {
+		t.Errorf("prompt = %v", p)
+	}
+	if i, err := chooseInstance(r, "orders", []serviceInstance{{"orders-a", ""}, {"orders-b", "10.0.0.2:8081"}}); err != nil || i.id != "orders-b" {
+		t.Errorf("picked orders-b, got %v, %v", i, err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rendererTimeout bounds how long an external renderer may take.
+const rendererTimeout = 10 * time.Second
+
+// builtinRenderers are the renderers -render can name instead of a command.
+var builtinRenderers = map[string]bodyKind{
+	"json": kindJSON,
+	"html": kindHTML,
+	"xml":  kindXML,
+	"text": kindText,
+	"hex":  kindBinary,
+}
+
+// renderRule shows bodies of one media type, or of a whole family such as
+// "text/*", with a built-in renderer or an external command.
+type renderRule struct {
+	mediaType string
+	builtin   bodyKind // Set for a built-in renderer.
+	command   []string // Set for an external one, which reads the body on stdin.
+}
+
+// renderFlag collects -render rules given as "type=renderer".
+type renderFlag []renderRule
+
+// String implements flag.Value.
+func (r *renderFlag) String() string {
+	rules := make([]string, len(*r))
+	for i, rule := range *r {
+		rules[i] = rule.mediaType
+	}
+	return strings.Join(rules, ", ")
+}
+
+// Set implements flag.Value. It parses one "type=renderer" rule, where
+// renderer is a built-in name or a command line.
+func (r *renderFlag) Set(v string) error {
+	mediaType, renderer, ok := strings.Cut(v, "=")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if !ok || mediaType == "" || strings.TrimSpace(renderer) == "" {
+		return fmt.Errorf("renderer %q is not in \"type=renderer\" form", v)
+	}
+	rule := renderRule{mediaType: mediaType}
+	if kind, ok := builtinRenderers[strings.TrimSpace(renderer)]; ok {
+		rule.builtin = kind
+	} else {
+		args, err := shellSplit(renderer)
+		if err != nil {
+			return fmt.Errorf("renderer for %s: %w", mediaType, err)
+		}
+		rule.command = args
+	}
+	*r = append(*r, rule)
+	return nil
+}
+
+// builtinRendererNames lists the built-in renderers, for the flag's help.
+func builtinRendererNames() string {
+	names := make([]string, 0, len(builtinRenderers))
+	for name := range builtinRenderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// findRule returns the rule for contentType: one for its exact media type
+// first, then one for its family.
+func findRule(rules []renderRule, contentType string) (renderRule, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return renderRule{}, false
+	}
+	family, _, _ := strings.Cut(mediaType, "/")
+	for _, want := range []string{mediaType, family + "/*"} {
+		for _, r := range rules {
+			if r.mediaType == want {
+				return r, true
+			}
+		}
+	}
+	return renderRule{}, false
+}
+
+// render shows body with the rule's renderer. A failing command says why
+// in place of the body.
+func (r renderRule) render(body []byte) string {
+	if r.command == nil {
+		return renderBody(r.builtin, body)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rendererTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, r.command[0], r.command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return fmt.Sprintf("(%s failed: %v)", r.command[0], err)
+	}
+	return strings.TrimRight(string(out), "\n")
+}
+
+// customRender renders body with whatever the user configured for
+// contentType: a -render rule first, then an extension renderer.
+func customRender(cfg config, contentType string, body []byte) (string, bool) {
+	if len(body) == 0 {
+		return "", false
+	}
+	if rule, ok := findRule(cfg.renderers, contentType); ok {
+		return rule.render(body), true
+	}
+	return cfg.ext.render(contentType, body)
+}
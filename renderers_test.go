@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderFlag(t *testing.T) {
+	var r renderFlag
+	for _, v := range []string{"application/vnd.api+json=json", "Text/*=tr a-z A-Z", "image/svg+xml = xml"} {
+		if err := r.Set(v); err != nil {
+			t.Fatalf("Set(%q): %v", v, err)
+		}
+	}
+	if r[0].builtin != kindJSON || r[1].command == nil || r[1].mediaType != "text/*" || r[2].builtin != kindXML {
+		t.Errorf("rules = %+v", r)
+	}
+	if got := r.String(); got != "application/vnd.api+json, text/*, image/svg+xml" {
+		t.Errorf("String = %q", got)
+	}
+	for _, bad := range []string{"json", "=json", "text/plain=", `text/plain=sh -c 'x`} {
+		if err := r.Set(bad); err == nil {
+			t.Errorf("Set(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestFindRule(t *testing.T) {
+	rules := []renderRule{{mediaType: "text/*", builtin: kindText}, {mediaType: "text/csv", builtin: kindBinary}}
+	if r, ok := findRule(rules, "text/csv; charset=utf-8"); !ok || r.builtin != kindBinary {
+		t.Errorf("text/csv = %+v, %v, want the exact rule before the family", r, ok)
+	}
+	if r, ok := findRule(rules, "text/markdown"); !ok || r.builtin != kindText {
+		t.Errorf("text/markdown = %+v, %v, want the family rule", r, ok)
+	}
+	if _, ok := findRule(rules, "application/json"); ok {
+		t.Error("application/json matched a text rule")
+	}
+}
+
+func TestRenderRule(t *testing.T) {
+	up := renderRule{command: []string{"tr", "a-z", "A-Z"}}
+	if got := up.render([]byte("hello\n")); got != "HELLO" {
+		t.Errorf("tr = %q", got)
+	}
+	bad := renderRule{command: []string{"sh", "-c", "echo nope >&2; exit 1"}}
+	if got := bad.render([]byte("x")); !strings.HasPrefix(got, "(sh failed: exit status 1: nope)") {
+		t.Errorf("failing renderer = %q", got)
+	}
+	if got := (renderRule{builtin: kindJSON}).render([]byte(`{"a":1}`)); got != "{\n  \"a\": 1\n}" {
+		t.Errorf("built-in json = %q", got)
+	}
+}
+
+func TestCustomRender(t *testing.T) {
+	cfg := config{renderers: []renderRule{{mediaType: "text/plain", builtin: kindBinary}}}
+	if got, ok := customRender(cfg, "text/plain", []byte("A")); !ok || !strings.Contains(got, "41") {
+		t.Errorf("customRender = %q, %v, want a hex dump", got, ok)
+	}
+	if _, ok := customRender(cfg, "text/plain", nil); ok {
+		t.Error("an empty body was rendered")
+	}
+	if _, ok := customRender(cfg, "text/html", []byte("x")); ok {
+		t.Error("text/html had a renderer")
+	}
+}
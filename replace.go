@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// When an API's base path moves or a header is renamed, every saved
+// request that uses it has to change. replace finds text, or a regular
+// expression, in the URLs, headers and bodies of collections and shows
+// what it would replace it with, request by request; -apply writes the
+// changes. Only the strings it changes are rewritten, so the files keep
+// their layout.
+
+// replaceFields are the parts of a request replace looks in.
+var replaceFields = []string{"url", "headers", "body"}
+
+// jsonString is a string of a JSON document: where it is, raw, and what
+// it says.
+type jsonString struct {
+	path       []string // Keys and array indexes down to it, e.g. requests, 0, url.
+	start, end int      // Where its raw text is, quotes and all.
+	value      string
+	key        bool // It is an object's key; path ends with it.
+}
+
+// jsonFrame is an object or array the walk of a document is inside.
+type jsonFrame struct {
+	object    bool
+	key       string // The object's latest key.
+	expectKey bool
+	index     int // The array's next element.
+}
+
+// jsonStrings lists every string in the JSON document data, in order.
+func jsonStrings(data []byte) ([]jsonString, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var stack []jsonFrame
+	var out []jsonString
+	path := func() []string {
+		var p []string
+		for _, f := range stack {
+			if f.object {
+				p = append(p, f.key)
+			} else {
+				p = append(p, strconv.Itoa(f.index))
+			}
+		}
+		return p
+	}
+	done := func() {
+		if len(stack) == 0 {
+			return
+		}
+		if top := &stack[len(stack)-1]; top.object {
+			top.expectKey = true
+		} else {
+			top.index++
+		}
+	}
+	prev := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The decoder swallows the commas and colons between tokens.
+		start := prev
+		for start < len(data) && strings.IndexByte(" \t\r\n,:", data[start]) >= 0 {
+			start++
+		}
+		end := int(dec.InputOffset())
+		prev = end
+		switch tok := tok.(type) {
+		case json.Delim:
+			switch tok {
+			case '{':
+				stack = append(stack, jsonFrame{object: true, expectKey: true})
+			case '[':
+				stack = append(stack, jsonFrame{})
+			default:
+				stack = stack[:len(stack)-1]
+				done()
+			}
+		case string:
+			if len(stack) > 0 && stack[len(stack)-1].object && stack[len(stack)-1].expectKey {
+				stack[len(stack)-1].key, stack[len(stack)-1].expectKey = tok, false
+				out = append(out, jsonString{path: path(), start: start, end: end, value: tok, key: true})
+				continue
+			}
+			out = append(out, jsonString{path: path(), start: start, end: end, value: tok})
+			done()
+		default:
+			done()
+		}
+	}
+}
+
+// requestField says which request of a collection, and which of its
+// replaceFields, the string at path belongs to: the index of the folder,
+// -1 for none, and of the request in it.
+func requestField(path []string) (folderIndex, requestIndex int, field string, ok bool) {
+	folderIndex = -1
+	if len(path) >= 4 && path[0] == "folders" && path[2] == "requests" {
+		f, err := strconv.Atoi(path[1])
+		if err != nil {
+			return 0, 0, "", false
+		}
+		folderIndex, path = f, path[2:]
+	}
+	if len(path) < 3 || path[0] != "requests" {
+		return 0, 0, "", false
+	}
+	r, err := strconv.Atoi(path[1])
+	if err != nil || !slices.Contains(replaceFields, path[2]) {
+		return 0, 0, "", false
+	}
+	// A header's name and value are both one level down; the headers
+	// object itself is no string, and the URL and body no deeper.
+	if (path[2] == "headers") != (len(path) == 4) || len(path) > 4 {
+		return 0, 0, "", false
+	}
+	return folderIndex, r, path[2], true
+}
+
+// replacer replaces what was asked for in a string, and counts how often.
+type replacer struct {
+	find    string
+	with    string
+	pattern *regexp.Regexp // Set for -regex.
+}
+
+// replace returns s with what r finds replaced, and how many times.
+func (r replacer) replace(s string) (string, int) {
+	if r.pattern != nil {
+		n := len(r.pattern.FindAllStringIndex(s, -1))
+		return r.pattern.ReplaceAllString(s, r.with), n
+	}
+	return strings.ReplaceAll(s, r.find, r.with), strings.Count(s, r.find)
+}
+
+// replacement is one string a replace changes.
+type replacement struct {
+	request  string // The request's label, and its folder's name.
+	field    string // url, body, or a header's name, or "header name".
+	old, new string
+}
+
+// replaceInCollection replaces what r finds in the fields of the
+// collection data, read from path, and returns what it became, what it
+// changed, and the number of times it replaced anything.
+func replaceInCollection(path string, data []byte, r replacer, fields []string) ([]byte, []replacement, int, error) {
+	c, err := loadCollection(path)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	strs, err := jsonStrings(data)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("reading %s: %w", path, err)
+	}
+	var out bytes.Buffer
+	var changes []replacement
+	total, last := 0, 0
+	for _, s := range strs {
+		f, i, field, ok := requestField(s.path)
+		// Of the keys, only the headers' names are anything but the schema's.
+		if !ok || !slices.Contains(fields, field) || (s.key && field != "headers") {
+			continue
+		}
+		changed, n := r.replace(s.value)
+		if n == 0 || changed == s.value {
+			continue
+		}
+		req := c.Requests
+		label := ""
+		if f >= 0 {
+			req = c.Folders[f].Requests
+			label = c.Folders[f].Name + " / "
+		}
+		label += req[i].label()
+		what := field
+		if field == "headers" {
+			what = s.path[len(s.path)-1]
+			if s.key {
+				what = "header name"
+				for name := range req[i].Headers {
+					if name == changed {
+						return nil, nil, 0, fmt.Errorf("%s: %s has a header %s already", path, label, changed)
+					}
+				}
+			}
+		}
+		var raw bytes.Buffer
+		enc := json.NewEncoder(&raw)
+		enc.SetEscapeHTML(false)
+		enc.Encode(changed)
+		out.Write(data[last:s.start])
+		out.Write(bytes.TrimSuffix(raw.Bytes(), []byte("\n")))
+		last = s.end
+		changes = append(changes, replacement{label, what, s.value, changed})
+		total += n
+	}
+	out.Write(data[last:])
+	return out.Bytes(), changes, total, nil
+}
+
+// runReplace implements `replace [-regex] [-in FIELDS] [-apply] FIND
+// REPLACEMENT COLLECTION.json...`.
+func runReplace(args []string) error {
+	fs := flag.NewFlagSet("replace", flag.ExitOnError)
+	regex := fs.Bool("regex", false, "FIND is a regular expression, and REPLACEMENT may use its groups as $1 or ${name}")
+	in := fs.String("in", strings.Join(replaceFields, ","), "replace only in these `fields` of the requests, comma-separated")
+	apply := fs.Bool("apply", false, "write the changes; without it, they are only shown")
+	fs.Parse(args)
+	if fs.NArg() < 3 {
+		return errors.New("usage: replace [-regex] [-in url,headers,body] [-apply] FIND REPLACEMENT COLLECTION.json...")
+	}
+	r := replacer{find: fs.Arg(0), with: fs.Arg(1)}
+	if r.find == "" {
+		return errors.New("there is nothing to find")
+	}
+	if *regex {
+		var err error
+		if r.pattern, err = regexp.Compile(r.find); err != nil {
+			return err
+		}
+	}
+	var fields []string
+	for _, f := range strings.Split(*in, ",") {
+		if f = strings.TrimSpace(f); !slices.Contains(replaceFields, f) {
+			return fmt.Errorf("there is no field %q; there are %s", f, strings.Join(replaceFields, ", "))
+		}
+		fields = append(fields, f)
+	}
+	return replaceAcross(os.Stdout, r, fields, fs.Args()[2:], *apply)
+}
+
+// replaceAcross replaces with r in the fields of the collections at files,
+// showing each change on w, and writes them if apply says to. Nothing is
+// written unless every file could be read.
+func replaceAcross(w io.Writer, r replacer, fields, files []string, apply bool) error {
+	type change struct {
+		path string
+		data []byte
+	}
+	var writes []change
+	total, strs := 0, 0
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		replaced, changes, n, err := replaceInCollection(path, data, r, fields)
+		if err != nil {
+			return err
+		}
+		for _, c := range changes {
+			fmt.Fprintf(w, "%s: %s, %s\n  - %s\n  + %s\n", path, c.request, c.field, c.old, c.new)
+		}
+		if n > 0 {
+			writes = append(writes, change{path, replaced})
+		}
+		total += n
+		strs += len(changes)
+	}
+	if total == 0 {
+		fmt.Fprintln(w, "Nothing matches.")
+		return nil
+	}
+	if !apply {
+		fmt.Fprintf(w, "\n%d replacements in %d strings of %d files; -apply makes them\n", total, strs, len(writes))
+		return nil
+	}
+	for _, c := range writes {
+		if err := writeKeepingMode(c.path, c.data); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "\nMade %d replacements in %d strings of %d files\n", total, strs, len(writes))
+	return nil
+}
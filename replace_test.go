@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestJSONStrings(t *testing.T) {
+	data := []byte(`{"a": ["x", {"b": "y"}], "c" : 1, "d":"z!"}`)
+	strs, err := jsonStrings(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range strs {
+		got = append(got, strings.Join(s.path, ".")+"="+s.value+"="+string(data[s.start:s.end]))
+	}
+	want := []string{`a=a="a"`, `a.0=x="x"`, `a.1.b=b="b"`, `a.1.b=y="y"`, `c=c="c"`, `d=d="d"`, `d=z!="z!"`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestReplaceAcross(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "api.json")
+	layout := `{
+  "name": "API /v1",
+  "requests": [
+    {"name": "List", "url": "/v1/pets", "headers": {"X-Api-Key": "{{key}}"}, "expect": {"body_contains": "/v1/"}}
+  ],
+  "folders": [
+    {"name": "Orders", "requests": [
+      {"name": "Order", "method": "POST", "url": "/v1/orders", "body": "{\"href\": \"/v1/pets/1\"}"}
+    ]}
+  ]
+}
+`
+	os.WriteFile(path, []byte(layout), 0o644)
+
+	var out strings.Builder
+	r := replacer{pattern: regexp.MustCompile(`/v1/(\w+)`), with: "/v2/$1"}
+	if err := replaceAcross(&out, r, replaceFields, []string{path}, false); err != nil {
+		t.Fatal(err)
+	}
+	want := path + ": List, url\n  - /v1/pets\n  + /v2/pets\n" +
+		path + ": Orders / Order, url\n  - /v1/orders\n  + /v2/orders\n" +
+		path + ": Orders / Order, body\n  - {\"href\": \"/v1/pets/1\"}\n  + {\"href\": \"/v2/pets/1\"}\n" +
+		"\n3 replacements in 3 strings of 1 files; -apply makes them\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+	if b, _ := os.ReadFile(path); string(b) != layout {
+		t.Error("a preview changed the file")
+	}
+
+	out.Reset()
+	if err := replaceAcross(&out, r, replaceFields, []string{path}, true); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(path)
+	// The name and the expectation aren't requests' URLs, headers or bodies.
+	wantFile := strings.Replace(strings.Replace(strings.Replace(layout, `"/v1/pets"`, `"/v2/pets"`, 1), `"/v1/orders"`, `"/v2/orders"`, 1), `\"/v1/pets/1\"`, `\"/v2/pets/1\"`, 1)
+	if string(b) != wantFile {
+		t.Errorf("file:\n%s\nwant\n%s", b, wantFile)
+	}
+
+	// Headers by name, in the headers only.
+	out.Reset()
+	if err := replaceAcross(&out, replacer{find: "X-Api-Key", with: "Authorization"}, []string{"headers"}, []string{path}, true); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadCollection(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Requests[0].Headers["Authorization"] != "{{key}}" || len(c.Requests[0].Headers) != 1 {
+		t.Errorf("headers = %v\n%s", c.Requests[0].Headers, out.String())
+	}
+
+	out.Reset()
+	replaceAcross(&out, replacer{find: "/v9/", with: "/v10/"}, replaceFields, []string{path}, true)
+	if out.String() != "Nothing matches.\n" {
+		t.Errorf("no match: %s", out.String())
+	}
+}
+
+func TestReplaceHeaderClash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "c.json")
+	os.WriteFile(path, []byte(`{"requests": [{"url": "/", "headers": {"A": "1", "B": "2"}}]}`), 0o644)
+	if err := replaceAcross(&strings.Builder{}, replacer{find: "A", with: "B"}, []string{"headers"}, []string{path}, true); err == nil {
+		t.Error("a clashing header name wasn't refused")
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// reportFormats write a collection run's results for a CI system to read,
+// by the name -report-format knows them by.
+var reportFormats = map[string]func(w io.Writer, run collectionRun) error{
+	"junit": writeJUnit,
+	"tap":   writeTAP,
+	"json":  writeJSONReport,
+}
+
+// reportExtensions are the file extensions a report format is guessed from.
+var reportExtensions = map[string]string{".xml": "junit", ".tap": "tap", ".json": "json"}
+
+// reportWriter returns what writes a run's report to path, "-" for standard
+// output, in format, or the format path's extension suggests. It returns
+// nil when no report was asked for.
+func reportWriter(path, format string) (func(collectionRun) error, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if format == "" {
+		format = reportExtensions[strings.ToLower(filepath.Ext(path))]
+		if format == "" {
+			return nil, fmt.Errorf("can't tell the format of report %s from its name; give -report-format junit, tap or json", path)
+		}
+	}
+	write, ok := reportFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown -report-format %q; use junit, tap or json", format)
+	}
+	return func(run collectionRun) error {
+		if path == "-" {
+			return write(os.Stdout, run)
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := write(f, run); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}, nil
+}
+
+// junitSuites is the root of a JUnit XML report, in the shape Jenkins,
+// GitLab and GitHub's test reporters read.
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// seconds formats d the way JUnit reports give times.
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// writeJUnit writes run as a JUnit XML report, one test case per request.
+func writeJUnit(w io.Writer, run collectionRun) error {
+	suite := junitSuite{
+		Name:      run.name,
+		Tests:     len(run.results),
+		Failures:  run.failed(),
+		Time:      seconds(run.elapsed),
+		Timestamp: run.started.UTC().Format("2006-01-02T15:04:05"),
+	}
+	for _, r := range run.results {
+		c := junitCase{Name: r.name, Classname: run.name, Time: seconds(r.elapsed), SystemOut: r.method + " " + r.url}
+		if r.requestID != "" {
+			c.SystemOut += "\nX-Request-ID: " + r.requestID
+		}
+		for _, w := range r.warnings {
+			c.SystemOut += "\nWarning: " + w
+		}
+		if !r.passed() {
+			c.Failure = &junitFailure{Message: r.failure, Text: fmt.Sprintf("%s %s: %s", r.method, r.url, r.failure)}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeTAP writes run in the Test Anything Protocol, version 13, with a
+// YAML block of details under each failure.
+func writeTAP(w io.Writer, run collectionRun) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", len(run.results))
+	for i, r := range run.results {
+		// A # would start a TAP directive.
+		name := strings.ReplaceAll(r.name, "#", `\#`)
+		if r.passed() {
+			fmt.Fprintf(&b, "ok %d - %s\n", i+1, name)
+			for _, w := range r.warnings {
+				fmt.Fprintf(&b, "# warning: %s\n", w)
+			}
+			continue
+		}
+		fmt.Fprintf(&b, "not ok %d - %s\n  ---\n", i+1, name)
+		fmt.Fprintf(&b, "  message: %s\n  method: %s\n  url: %s\n", yamlString(r.failure), r.method, yamlString(r.url))
+		if r.status > 0 {
+			fmt.Fprintf(&b, "  status: %d\n", r.status)
+		}
+		if r.requestID != "" {
+			fmt.Fprintf(&b, "  request_id: %s\n", r.requestID)
+		}
+		if len(r.warnings) > 0 {
+			b.WriteString("  warnings:\n")
+			for _, w := range r.warnings {
+				fmt.Fprintf(&b, "    - %s\n", yamlString(w))
+			}
+		}
+		fmt.Fprintf(&b, "  duration_ms: %d\n  ...\n", r.elapsed.Milliseconds())
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// yamlString quotes s for YAML; a JSON string is a valid YAML one.
+func yamlString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// jsonReport is the shape of the JSON report.
+type jsonReport struct {
+	Name     string       `json:"name"`
+	Started  time.Time    `json:"started"`
+	Millis   int64        `json:"duration_ms"`
+	Passed   int          `json:"passed"`
+	Failed   int          `json:"failed"`
+	Requests []jsonResult `json:"requests"`
+}
+
+type jsonResult struct {
+	Name      string   `json:"name"`
+	Method    string   `json:"method"`
+	URL       string   `json:"url"`
+	Status    int      `json:"status,omitempty"`
+	Millis    int64    `json:"duration_ms"`
+	RequestID string   `json:"request_id,omitempty"`
+	Passed    bool     `json:"passed"`
+	Failure   string   `json:"failure,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// writeJSONReport writes run as one JSON object.
+func writeJSONReport(w io.Writer, run collectionRun) error {
+	rep := jsonReport{Name: run.name, Started: run.started.UTC(), Millis: run.elapsed.Milliseconds(), Failed: run.failed(), Requests: []jsonResult{}}
+	rep.Passed = len(run.results) - rep.Failed
+	for _, r := range run.results {
+		rep.Requests = append(rep.Requests, jsonResult{
+			Name: r.name, Method: r.method, URL: r.url, Status: r.status, Millis: r.elapsed.Milliseconds(),
+			RequestID: r.requestID, Passed: r.passed(), Failure: r.failure, Warnings: r.warnings,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
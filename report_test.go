@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRun is a run with one passing and one failing request.
+var testRun = collectionRun{
+	name:    "Pet store",
+	started: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC),
+	elapsed: 1500 * time.Millisecond,
+	results: []runResult{
+		{name: "List pets", method: "GET", url: "http://x/pets", status: 200, elapsed: 20 * time.Millisecond, requestID: "abc"},
+		{name: "Add # pet", method: "POST", url: "http://x/pets", status: 500, elapsed: 30 * time.Millisecond, failure: "expected status 201, got 500"},
+	},
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var b strings.Builder
+	if err := writeJUnit(&b, testRun); err != nil {
+		t.Fatal(err)
+	}
+	var got junitSuites
+	if err := xml.Unmarshal([]byte(b.String()), &got); err != nil {
+		t.Fatalf("%v:\n%s", err, b.String())
+	}
+	s := got.Suites[0]
+	if s.Name != "Pet store" || s.Tests != 2 || s.Failures != 1 || s.Time != "1.500" || s.Timestamp != "2026-05-01T12:00:00" {
+		t.Errorf("suite = %+v", s)
+	}
+	if s.Cases[0].Failure != nil || s.Cases[1].Failure == nil || s.Cases[1].Failure.Message != "expected status 201, got 500" {
+		t.Errorf("cases = %+v", s.Cases)
+	}
+	if !strings.Contains(s.Cases[0].SystemOut, "X-Request-ID: abc") {
+		t.Errorf("system-out = %q", s.Cases[0].SystemOut)
+	}
+}
+
+func TestWriteTAP(t *testing.T) {
+	var b strings.Builder
+	writeTAP(&b, testRun)
+	want := `TAP version 13
+1..2
+ok 1 - List pets
+not ok 2 - Add \# pet
+  ---
+  message: "expected status 201, got 500"
+  method: POST
+  url: "http://x/pets"
+  status: 500
+  duration_ms: 30
+  ...
+`
+	if b.String() != want {
+		t.Errorf("TAP:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestWriteJSONReport(t *testing.T) {
+	var b strings.Builder
+	writeJSONReport(&b, testRun)
+	var got jsonReport
+	if err := json.Unmarshal([]byte(b.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Passed != 1 || got.Failed != 1 || got.Millis != 1500 || len(got.Requests) != 2 || got.Requests[1].Passed || got.Requests[0].RequestID != "abc" {
+		t.Errorf("report = %+v", got)
+	}
+}
+
+func TestReportWriter(t *testing.T) {
+	if w, err := reportWriter("", ""); w != nil || err != nil {
+		t.Error("no -report still wrote one")
+	}
+	if _, err := reportWriter("out.txt", ""); err == nil {
+		t.Error("a format was guessed from .txt")
+	}
+	if _, err := reportWriter("out.xml", "yaml"); err == nil {
+		t.Error("an unknown format was taken")
+	}
+
+	path := filepath.Join(t.TempDir(), "results.tap")
+	w, err := reportWriter(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w(testRun); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(path); !strings.HasPrefix(string(b), "TAP version 13") {
+		t.Errorf(".tap wrote %q", b)
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// response is what we keep from a finished request.
+type response struct {
+	status int         // HTTP status code returned from the server.
+	final  *url.URL    // URL that answered, after following any redirects.
+	header http.Header // Response headers.
+	body   []byte      // Response body, up to maxBody bytes; empty when saved to disk.
+
+	// kind is the renderer chosen for body and rendered its output. When
+	// the Content-Type header disagrees with the content, mismatch says how.
+	kind     bodyKind
+	rendered string
+	lines    lineIndex // Where each line of rendered starts.
+	mismatch string
+	charset  *textCharset // Charset text was transcoded from, nil if it was UTF-8.
+
+	// A protobuf, MessagePack or CBOR body is decoded to JSON, kept in
+	// asJSON for the tree and table views. format says what it was decoded
+	// from, or why it couldn't be.
+	asJSON []byte
+	format string
+
+	fault    string // Code and reason of a SOAP fault, if the body is one.
+	transfer string // What an ftp:// or sftp:// transfer did, e.g. "sftp: downloaded 512 bytes in 40ms".
+
+	cont  *continueInfo // Outcome of the 100-continue handshake, nil if not used.
+	saved *download     // Where the body went when -o was given, nil otherwise.
+	cors  *corsCheck    // Browser CORS verdict when -cors-origin was given, nil otherwise.
+	notes []string      // What the -plugin middlewares had to say about the response.
+	conn  *connInfo     // Which address the response came from.
+
+	// requestID is the X-Request-ID the request went out with, if any, and
+	// echoed whether the server sent the same one back.
+	requestID string
+	echoed    bool
+
+	// violations lists how a JSON body breaks the -schema, if one was given.
+	violations []string
+
+	// offline is when the answer was kept, for one answered from the
+	// offline file rather than the network; nil for a live answer.
+	offline *time.Time
+
+	// wire is the request and answer as they went over the connection,
+	// and timeline what the transport did to send the one and get the other.
+	wire     *wireDump
+	timeline *timeline
+
+	// A newline-delimited JSON body is streamed in record by record: stream
+	// reads the first batch, and streaming stays set until the last one.
+	records    []string
+	streaming  bool
+	streamID   int64 // Which stream the records come from.
+	streamErr  error // Why the stream broke off, if it did.
+	stream     tea.Cmd
+	stopStream func() // Abandons the stream, for when nobody will read it.
+}
+
+// newRequest builds the outgoing request described by cfg. The returned
+// continueInfo is non-nil when the Expect: 100-continue handshake is in play.
+func newRequest(cfg config) (*http.Request, *continueInfo, error) {
+	var body io.Reader
+	if cfg.body != nil {
+		body = bytes.NewReader(cfg.body)
+	}
+
+	req, err := http.NewRequest(cfg.method, cfg.url, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, values := range cfg.header {
+		req.Header[key] = values
+	}
+	applyUAProfile(req.Header, cfg.uaProfile)
+
+	// A page on another origin always announces where it comes from.
+	if cfg.origin != "" {
+		req.Header.Set("Origin", cfg.origin)
+	}
+
+	// Ask for part of the resource: either what the user named, or the rest
+	// of a partial download.
+	if offset := resumeOffset(cfg); offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if v := resumeValidator(cfg.output); v != "" {
+			req.Header.Set("If-Range", v)
+		}
+	} else if cfg.byteRange != "" {
+		req.Header.Set("Range", "bytes="+strings.TrimPrefix(cfg.byteRange, "bytes="))
+	}
+
+	// Only large uploads are worth the extra round trip.
+	size := int64(len(cfg.body))
+	if cfg.expectContinue && size > 0 && size >= cfg.expectContinueMin {
+		req, info := withExpectContinue(req, bytes.NewReader(cfg.body), size)
+		return req, info, nil
+	}
+	return req, nil, nil
+}
+
+// newClient returns the HTTP client every request in the program goes
+// through, so they all share the same settings.
+func newClient(cfg config) *http.Client {
+	// Create an HTTP client with a timeout of 10 seconds, on the shared
+	// transport for the pool settings that were asked for and the
+	// environment's jump host.
+	return &http.Client{Timeout: 10 * time.Second, Transport: transportFor(transportOptionsFor(cfg))}
+}
+
+// maxBody caps how much of a response body we are willing to hold in memory.
+const maxBody = 10 << 20
+
+// fetch GETs target with c and returns the response together with its body,
+// read up to maxBody bytes. It is the building block for follow-up actions
+// that need to look inside a page rather than just at its status.
+func fetch(c *http.Client, target string) (*http.Response, []byte, error) {
+	res, err := c.Get(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxBody))
+	return res, body, err
+}
+
+// checkServer returns a command that performs the request described by cfg.
+// The command yields either a responseMsg or an errMsg (on error), and
+// records which it was in the history.
+func checkServer(cfg config) tea.Cmd {
+	return func() tea.Msg {
+		cfg := withRequestID(cfg)
+		start := time.Now()
+		msg := send(cfg)
+		_ = recordHistory(cfg, msg, time.Since(start)) // A history we can't write shouldn't cost the answer.
+		// The server may have logged a request that failed on the way back.
+		if e, ok := msg.(errMsg); ok && cfg.header.Get("X-Request-ID") != "" {
+			return errMsg{fmt.Errorf("%w (X-Request-ID %s)", e.err, cfg.header.Get("X-Request-ID"))}
+		}
+		return msg
+	}
+}
+
+// send performs the request described by cfg, and notes it in the audit log.
+func send(cfg config) (msg tea.Msg) {
+	if err := readOnlyBlocks(cfg); err != nil {
+		logf(logWarn, "Didn't send %s %s: %v", cfg.method, cfg.url, err)
+		return errMsg{err}
+	}
+
+	// Fill in any {{function}} calls to extensions, then encode a JSON
+	// body the way the server takes it. The logs, the audit and the offline
+	// file get the request as it was asked for, as the history does, so
+	// that the secrets its variables stand for stay out of them.
+	logVariables(cfg)
+	asked := cfg
+	cfg, err := expandRequest(cfg)
+	if err != nil {
+		return errMsg{err}
+	}
+	if cfg, err = encodeBody(cfg); err != nil {
+		return errMsg{err}
+	}
+
+	// Offline, the answer comes from what was kept of earlier ones.
+	if cfg.offline {
+		logf(logInfo, "Answered %s %s from the offline file, not the network", asked.method, asked.url)
+		return answerOffline(cfg, asked.url)
+	}
+	// A service the registry knows goes to one of its healthy instances.
+	if cfg, err = resolveService(cfg); err != nil {
+		return errMsg{err}
+	}
+	// Whatever else happens, the request was sent, or tried to be. A log
+	// we can't write shouldn't cost the answer.
+	defer func() {
+		switch msg := msg.(type) {
+		case responseMsg:
+			logf(logInfo, "%s %s: %d %s", asked.method, asked.url, msg.status, http.StatusText(msg.status))
+		case errMsg:
+			logf(logError, "%s %s: %v", asked.method, asked.url, msg.err)
+		}
+		if err := recordAudit(asked, msg); err != nil {
+			logf(logWarn, "Couldn't write the audit log: %v", err)
+		}
+	}()
+
+	// FTP and SFTP move files rather than answer requests, and big
+	// ones take a while, so they report their progress as a job.
+	if isFileTransfer(cfg.url) {
+		return runJob(func(report func(string)) tea.Msg { return transferFile(cfg, report) })()
+	}
+
+	// Downloads to disk take as long as they take, so they get no
+	// overall deadline.
+	c := newClient(cfg)
+	if cfg.output != "" {
+		c.Timeout = 0
+	}
+
+	req, cont, err := newRequest(cfg)
+	if err != nil {
+		return errMsg{err}
+	}
+
+	// Let the middlewares have their say, e.g. to sign the request. A
+	// new body bypasses the 100-continue bookkeeping, so drop its report.
+	mws, err := middlewares(cfg)
+	if err != nil {
+		return errMsg{err}
+	}
+	if changed, err := applyRequestMiddleware(mws, req, cfg.body); err != nil {
+		return errMsg{err}
+	} else if changed {
+		logf(logInfo, "The plugins changed the body of %s %s", asked.method, asked.url)
+		cont = nil
+	}
+
+	// Play the browser: preflight first when the request isn't simple.
+	var cors *corsCheck
+	if cfg.origin != "" {
+		cors = &corsCheck{}
+		if needsPreflight(cfg) {
+			if cors, err = sendPreflight(c, cfg); err != nil {
+				return errMsg{err}
+			}
+		}
+	}
+
+	// Perform the HTTP request. The client's deadline would cover the
+	// whole body, which a stream of records may take all day to send,
+	// so it is enforced here instead and lifted for streams.
+	var timedOut atomic.Bool
+	conn, steps := &connInfo{}, newTimeline()
+	traced := httptrace.WithClientTrace(httptrace.WithClientTrace(req.Context(), conn.trace()), steps.trace())
+	ctx, cancel := context.WithCancel(traced)
+	deadline := func() bool { return false }
+	if c.Timeout > 0 {
+		timer := time.AfterFunc(c.Timeout, func() { timedOut.Store(true); cancel() })
+		deadline = timer.Stop
+	}
+	release := func() { deadline(); cancel() }
+	direct := *c
+	direct.Timeout = 0
+	res, err := direct.Do(req.WithContext(ctx))
+	if err != nil {
+		release()
+		if timedOut.Load() {
+			err = timeoutError{c.Timeout}
+		}
+		// If an error occurs, wrap and return it as an errMsg.
+		return errMsg{err}
+	}
+
+	// Hand a stream of records over to be read as it arrives.
+	if isNDJSON(res.Header) && cfg.output == "" {
+		deadline()
+		stream, id, stop := streamRecords(res, release)
+		return responseMsg{
+			status:     res.StatusCode,
+			final:      res.Request.URL,
+			header:     res.Header,
+			kind:       kindText,
+			cont:       cont,
+			cors:       cors,
+			conn:       conn,
+			requestID:  res.Request.Header.Get("X-Request-ID"),
+			echoed:     echoesRequestID(res),
+			streaming:  true,
+			streamID:   id,
+			stream:     stream,
+			stopStream: stop,
+			wire:       &wireDump{request: dumpRequest(res.Request), response: dumpResponse(res, nil), http2: res.ProtoMajor == 2},
+			timeline:   steps,
+		}
+	}
+	defer release()
+	// It is best practice to close the response body to avoid resource leaks.
+	defer res.Body.Close()
+
+	// A browser only gets this far if the preflight passed; the actual
+	// response then has to allow the origin as well.
+	if cors != nil && len(cors.problems) == 0 {
+		cors.problems = checkAllowOrigin(res.Header, cfg, "response")
+	}
+
+	// Keep the body on disk if we were asked to, in memory otherwise.
+	var saved *download
+	var body []byte
+	if cfg.output != "" {
+		saved, err = saveBody(res, cfg, resumeOffset(cfg))
+		// The file on disk isn't part of the server's, so start over.
+		var mismatch resumeMismatch
+		if errors.As(err, &mismatch) {
+			logf(logWarn, "%v; downloading it again from the start", err)
+			steps.add("Resuming failed: %v", err)
+			if res, err = restartDownload(&direct, req.WithContext(ctx)); err == nil {
+				defer res.Body.Close()
+				if saved, err = saveBody(res, cfg, 0); saved != nil {
+					saved.restarted = mismatch
+				}
+			}
+		}
+	} else {
+		body, err = io.ReadAll(io.LimitReader(res.Body, maxBody))
+	}
+	if err != nil {
+		steps.add("Reading the body failed: %v", err)
+	} else if saved != nil {
+		steps.add("End of the body, saved to %s", cfg.output)
+	} else {
+		steps.add("End of the body, %d bytes", len(body))
+	}
+	if err != nil {
+		if timedOut.Load() {
+			err = timeoutError{c.Timeout}
+		}
+		return errMsg{err}
+	}
+
+	r := describeBody(cfg, res.Header, body)
+	r.status, r.final = res.StatusCode, res.Request.URL
+	r.cont, r.saved, r.cors, r.conn = cont, saved, cors, conn
+	r.requestID, r.echoed = res.Request.Header.Get("X-Request-ID"), echoesRequestID(res)
+	r.notes = applyResponseMiddleware(mws, res, body)
+	// The request that got the answer, after any redirects.
+	r.wire = &wireDump{request: dumpRequest(res.Request), response: dumpResponse(res, body), http2: res.ProtoMajor == 2}
+	r.timeline = steps
+	if cfg.schema != nil && cfg.output == "" {
+		// A protobuf, MessagePack or CBOR body is checked as the JSON it
+		// decodes to.
+		checked := body
+		if r.asJSON != nil {
+			checked = r.asJSON
+		}
+		r.violations = cfg.schema.validateBody(checked)
+	}
+	if cfg.keepOffline && cfg.offlineFile != "" && cfg.output == "" {
+		// Keep the answer for offline mode; failing to is no reason to
+		// lose it now.
+		if err := keepCanned(cfg.offlineFile, asked.method, asked.url, res.StatusCode, res.Header, body); err != nil {
+			logf(logWarn, "Couldn't keep the answer for offline mode: %v", err)
+		}
+	}
+
+	// Return what we learned wrapped as a responseMsg.
+	return responseMsg(r)
+}
+
+// restartDownload sends req again for the whole resource, without the
+// Range and If-Range of the resume it was.
+func restartDownload(c *http.Client, req *http.Request) (*http.Response, error) {
+	again := req.Clone(req.Context())
+	again.Header.Del("Range")
+	again.Header.Del("If-Range")
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		again.Body = body
+	}
+	return c.Do(again)
+}
+
+// describeBody returns a response holding body, served with headers h, and
+// everything the views need of it. The body is rendered up front so that
+// drawing the screen stays cheap, and text in a legacy charset is
+// transcoded to UTF-8 for display.
+func describeBody(cfg config, h http.Header, body []byte) response {
+	kind, mismatch := contentKind(h, body)
+	text, cs := body, (*textCharset)(nil)
+	if kind != kindBinary && kind != kindImage {
+		text, cs = decodeText(h.Get("Content-Type"), body)
+	}
+
+	rendered := renderBody(kind, text)
+
+	// Protobuf, MessagePack and CBOR are unreadable as they come, so
+	// show them as JSON.
+	asJSON, format := decodeBody(cfg, h, body)
+	if asJSON != nil {
+		kind, mismatch = kindJSON, ""
+		rendered = renderBody(kindJSON, asJSON)
+	}
+	var fault string
+	if kind == kindXML {
+		fault = soapFault(text)
+	}
+	if custom, ok := customRender(cfg, h.Get("Content-Type"), text); ok {
+		rendered = custom
+	}
+
+	return response{
+		header:   h,
+		body:     body,
+		kind:     kind,
+		rendered: rendered,
+		lines:    indexLines(rendered),
+		mismatch: mismatch,
+		charset:  cs,
+		asJSON:   asJSON,
+		format:   format,
+		fault:    fault,
+	}
+}
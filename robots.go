@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// robotsGroup is one block of robots.txt rules, shared by one or more user agents.
+type robotsGroup struct {
+	agents []string // User-agent lines the rules apply to.
+	rules  []string // Allow, Disallow and Crawl-delay lines, as written.
+}
+
+// robotsRules maps the rule lines we keep to their canonical spelling.
+var robotsRules = map[string]string{"allow": "Allow", "disallow": "Disallow", "crawl-delay": "Crawl-delay"}
+
+// parseRobots splits a robots.txt file into rule groups and the sitemaps it
+// advertises. Comments and unknown lines are ignored.
+func parseRobots(body []byte) (groups []robotsGroup, sitemaps []string) {
+	var cur *robotsGroup
+	for _, line := range strings.Split(string(body), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Consecutive User-agent lines share the group that follows them.
+			if cur == nil || len(cur.rules) > 0 {
+				groups = append(groups, robotsGroup{})
+				cur = &groups[len(groups)-1]
+			}
+			cur.agents = append(cur.agents, value)
+		case "allow", "disallow", "crawl-delay":
+			if cur != nil {
+				cur.rules = append(cur.rules, fmt.Sprintf("%-12s %s", robotsRules[key]+":", value))
+			}
+		case "sitemap":
+			sitemaps = append(sitemaps, value)
+		}
+	}
+	return groups, sitemaps
+}
+
+// siteRoot returns the scheme://host part of target, which is where
+// robots.txt and sitemap.xml live.
+func siteRoot(target string) (*url.URL, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}, nil
+}
+
+// fetchRobots returns a command that downloads and renders the robots.txt of
+// the host behind cfg.url. Any sitemaps it names become selectable links.
+func fetchRobots(cfg config) tea.Cmd {
+	return func() tea.Msg {
+		root, err := siteRoot(cfg.url)
+		if err != nil {
+			return errMsg{err}
+		}
+		target := root.JoinPath("robots.txt").String()
+
+		res, body, err := fetch(newClient(cfg), target)
+		if err != nil {
+			return errMsg{err}
+		}
+		if res.StatusCode != 200 {
+			return reportMsg{title: "robots.txt", body: fmt.Sprintf("%s answered %d; everything may be crawled", target, res.StatusCode)}
+		}
+
+		groups, sitemaps := parseRobots(body)
+		var b strings.Builder
+		fmt.Fprintf(&b, "%d rule groups, %d sitemaps", len(groups), len(sitemaps))
+		for _, g := range groups {
+			fmt.Fprintf(&b, "\nUser-agent: %s", strings.Join(g.agents, ", "))
+			for _, r := range g.rules {
+				b.WriteString("\n  " + r)
+			}
+		}
+		return reportMsg{title: "robots.txt", body: b.String(), links: sitemaps}
+	}
+}
+
+// sitemapDoc covers both shapes a sitemap can take: a <urlset> of pages or a
+// <sitemapindex> of further sitemaps.
+type sitemapDoc struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// sitemapLoc is a single <url> or <sitemap> entry.
+type sitemapLoc struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// parseSitemap decodes a sitemap or sitemap index, gunzipping it first when
+// it was served as a .xml.gz file.
+func parseSitemap(body []byte) (*sitemapDoc, error) {
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(io.LimitReader(zr, maxBody)); err != nil {
+			return nil, err
+		}
+	}
+	var doc sitemapDoc
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("parsing sitemap: %w", err)
+	}
+	return &doc, nil
+}
+
+// sitemapURL picks the sitemap to fetch: the current URL when it already
+// points at one (so nested sitemaps can be followed), otherwise /sitemap.xml.
+func sitemapURL(target string) (string, error) {
+	if strings.HasSuffix(target, ".xml") || strings.HasSuffix(target, ".xml.gz") {
+		return target, nil
+	}
+	root, err := siteRoot(target)
+	if err != nil {
+		return "", err
+	}
+	return root.JoinPath("sitemap.xml").String(), nil
+}
+
+// fetchSitemap returns a command that downloads the sitemap for cfg.url and
+// lists every page (or nested sitemap) in it as a selectable link.
+func fetchSitemap(cfg config) tea.Cmd {
+	return func() tea.Msg {
+		target, err := sitemapURL(cfg.url)
+		if err != nil {
+			return errMsg{err}
+		}
+		res, body, err := fetch(newClient(cfg), target)
+		if err != nil {
+			return errMsg{err}
+		}
+		if res.StatusCode != 200 {
+			return reportMsg{title: "Sitemap", body: fmt.Sprintf("%s answered %d", target, res.StatusCode)}
+		}
+		doc, err := parseSitemap(body)
+		if err != nil {
+			return errMsg{err}
+		}
+
+		var links []string
+		kind := "pages"
+		entries := doc.URLs
+		if doc.XMLName.Local == "sitemapindex" {
+			kind, entries = "sitemaps", doc.Sitemaps
+		}
+		for _, e := range entries {
+			links = append(links, strings.TrimSpace(e.Loc))
+		}
+		return reportMsg{title: "Sitemap", body: fmt.Sprintf("%s lists %d %s", target, len(links), kind), links: links}
+	}
+}
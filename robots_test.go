@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseRobots(t *testing.T) {
+	body := []byte(`# comments are ignored
+User-agent: Googlebot
+user-agent: Bingbot
+Disallow: /private # not this either
+Allow: /private/open
+
+User-agent: *
+Crawl-delay: 5
+Disallow:
+Sitemap: https://a.test/sitemap.xml
+Noise without a colon
+Host: a.test
+`)
+	groups, sitemaps := parseRobots(body)
+	want := []robotsGroup{
+		{agents: []string{"Googlebot", "Bingbot"}, rules: []string{"Disallow:    /private", "Allow:       /private/open"}},
+		{agents: []string{"*"}, rules: []string{"Crawl-delay: 5", "Disallow:    "}},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("groups = %q, want %q", groups, want)
+	}
+	if !reflect.DeepEqual(sitemaps, []string{"https://a.test/sitemap.xml"}) {
+		t.Errorf("sitemaps = %q", sitemaps)
+	}
+}
+
+func TestParseRobotsRulesBeforeAgent(t *testing.T) {
+	groups, _ := parseRobots([]byte("Disallow: /\n"))
+	if len(groups) != 0 {
+		t.Errorf("groups = %q, want none", groups)
+	}
+}
+
+func TestParseSitemap(t *testing.T) {
+	index := []byte(`<sitemapindex><sitemap><loc>https://a.test/s1.xml</loc></sitemap></sitemapindex>`)
+	doc, err := parseSitemap(index)
+	if err != nil || doc.XMLName.Local != "sitemapindex" || len(doc.Sitemaps) != 1 || doc.Sitemaps[0].Loc != "https://a.test/s1.xml" {
+		t.Errorf("index = %+v, %v", doc, err)
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(`<urlset><url><loc>https://a.test/</loc><lastmod>2024-01-01</lastmod></url></urlset>`))
+	zw.Close()
+	doc, err = parseSitemap(gz.Bytes())
+	if err != nil || len(doc.URLs) != 1 || doc.URLs[0].LastMod != "2024-01-01" {
+		t.Errorf("gzipped urlset = %+v, %v", doc, err)
+	}
+
+	if _, err := parseSitemap([]byte("<urlset>")); err == nil {
+		t.Error("a broken sitemap was accepted")
+	}
+}
+
+func TestSitemapURL(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://a.test/some/page?x=1":  "https://a.test/sitemap.xml",
+		"https://a.test/maps/s1.xml":    "https://a.test/maps/s1.xml",
+		"https://a.test/maps/s1.xml.gz": "https://a.test/maps/s1.xml.gz",
+	} {
+		if got, err := sitemapURL(in); err != nil || got != want {
+			t.Errorf("sitemapURL(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+}
+
+func TestFetchRobots(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("User-agent: *\nDisallow: /tmp\nSitemap: /sitemap.xml\n"))
+	}))
+	defer srv.Close()
+
+	msg := fetchRobots(config{url: srv.URL + "/deep/page", header: http.Header{}})()
+	r, ok := msg.(reportMsg)
+	if !ok {
+		t.Fatalf("fetchRobots = %#v, want a report", msg)
+	}
+	if want := "1 rule groups, 1 sitemaps\nUser-agent: *\n  Disallow:    /tmp"; r.body != want {
+		t.Errorf("report = %q, want %q", r.body, want)
+	}
+	if !reflect.DeepEqual(r.links, []string{"/sitemap.xml"}) {
+		t.Errorf("links = %q", r.links)
+	}
+}
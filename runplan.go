@@ -0,0 +1,485 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// runStep is one request of a collection run, or one of its hooks, with
+// what has to finish before it may go.
+type runStep struct {
+	req    collectionRequest
+	script []string // The command line of a hook that runs one.
+	hook   string   // "before" or "after" for a hook, "" for a request.
+	folder string   // "" for a request outside any folder.
+	after  []int    // Steps to wait for: the one before it in its folder, and needs.
+	needs  []int    // Steps it depends on, which have to pass for it to go.
+}
+
+// label is what the step is called in the output.
+func (s runStep) label() string {
+	name := s.req.label()
+	if s.script != nil {
+		name = "run " + strings.Join(s.script, " ")
+	}
+	if s.hook != "" {
+		return s.hook + ": " + name
+	}
+	return name
+}
+
+// planRun lists the steps of a run of c: the collection's before hooks,
+// then each folder, requests outside any folder first, with its own hooks
+// around its requests, and last the collection's after hooks. It works out
+// what each step waits for, and fails on a hook or depends_on that names no
+// request, or names several, and on requests that wait for each other.
+func planRun(c *collection) ([]runStep, error) {
+	groups := append([]folder{{Requests: c.Requests}}, c.Folders...)
+
+	// Requests are known by their ids and names.
+	ids := map[string]collectionRequest{}
+	names := map[string][]collectionRequest{}
+	for _, g := range groups {
+		for _, req := range g.Requests {
+			if req.ID != "" {
+				if _, dup := ids[req.ID]; dup {
+					return nil, fmt.Errorf("two requests have the id %q", req.ID)
+				}
+				ids[req.ID] = req
+			}
+			names[req.label()] = append(names[req.label()], req)
+		}
+	}
+	find := func(ref string) (collectionRequest, error) {
+		if req, ok := ids[ref]; ok {
+			return req, nil
+		}
+		switch found := names[ref]; len(found) {
+		case 0:
+			return collectionRequest{}, fmt.Errorf("no request is called %q", ref)
+		case 1:
+			return found[0], nil
+		}
+		return collectionRequest{}, fmt.Errorf("several requests are called %q; give them ids", ref)
+	}
+
+	// A request that is a hook runs only as the hook.
+	var steps []runStep
+	hooked := map[string]bool{}
+	hookSteps := func(kind, folder string, hooks []hook) ([]runStep, error) {
+		var out []runStep
+		for _, h := range hooks {
+			s := runStep{hook: kind, folder: folder}
+			switch {
+			case h.Request != "" && h.Run != "":
+				return nil, fmt.Errorf("a %s hook is either a request or a command to run, not both", kind)
+			case h.Run != "":
+				args, err := shellSplit(h.Run)
+				if err != nil || len(args) == 0 {
+					return nil, fmt.Errorf("%s hook %q: not a command line", kind, h.Run)
+				}
+				s.script = args
+			default:
+				req, err := find(h.Request)
+				if err != nil {
+					return nil, fmt.Errorf("%s hook: %w", kind, err)
+				}
+				s.req, hooked[req.label()+"\x00"+req.ID] = req, true
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	}
+	// chain adds ss to the steps, each after the one before it, the first
+	// after first and all of them needing needs. It returns their indexes.
+	chain := func(ss []runStep, first, needs []int) []int {
+		var added []int
+		for _, s := range ss {
+			s.after = slices.Clone(first)
+			if len(added) > 0 {
+				s.after = []int{added[len(added)-1]}
+			}
+			s.needs = slices.Clone(needs)
+			s.after = append(s.after, needs...)
+			added = append(added, len(steps))
+			steps = append(steps, s)
+		}
+		return added
+	}
+
+	before, err := hookSteps("before", "", c.Before)
+	if err != nil {
+		return nil, err
+	}
+	after, err := hookSteps("after", "", c.After)
+	if err != nil {
+		return nil, err
+	}
+	type planned struct {
+		g             folder
+		before, after []runStep
+	}
+	var plan []planned
+	for _, g := range groups {
+		p := planned{g: g}
+		if p.before, err = hookSteps("before", g.Name, g.Before); err != nil {
+			return nil, err
+		}
+		if p.after, err = hookSteps("after", g.Name, g.After); err != nil {
+			return nil, err
+		}
+		plan = append(plan, p)
+	}
+
+	setup := chain(before, nil, nil)
+	var ends []int // The last step of each folder.
+	for _, p := range plan {
+		folderSetup := chain(p.before, nil, setup)
+		var reqs []runStep
+		for _, req := range p.g.Requests {
+			if !hooked[req.label()+"\x00"+req.ID] {
+				reqs = append(reqs, runStep{req: req, folder: p.g.Name})
+			}
+		}
+		added := chain(reqs, lastOf(folderSetup), append(slices.Clone(setup), folderSetup...))
+		// Teardown runs whatever became of the rest, so it only waits.
+		last := lastOf(append(append(slices.Clone(setup), folderSetup...), added...))
+		teardown := chain(p.after, last, nil)
+		if all := append(append(folderSetup, added...), teardown...); len(all) > 0 {
+			ends = append(ends, all[len(all)-1])
+		}
+	}
+	if len(ends) == 0 {
+		ends = lastOf(setup)
+	}
+	chain(after, ends, nil)
+
+	// depends_on names requests that run as requests or as hooks.
+	index := map[string][]int{}
+	for i, s := range steps {
+		if s.script == nil {
+			index[s.req.label()] = append(index[s.req.label()], i)
+			if s.req.ID != "" {
+				index["\x00"+s.req.ID] = []int{i}
+			}
+		}
+	}
+	for i := range steps {
+		for _, dep := range steps[i].req.DependsOn {
+			found, ok := index["\x00"+dep]
+			if !ok {
+				found = index[dep]
+			}
+			switch {
+			case len(found) == 0:
+				return nil, fmt.Errorf("%s depends on %q, which no request is called", steps[i].label(), dep)
+			case len(found) > 1:
+				return nil, fmt.Errorf("%s depends on %q, which several requests are called; give them ids", steps[i].label(), dep)
+			}
+			steps[i].after = append(steps[i].after, found[0])
+			steps[i].needs = append(steps[i].needs, found[0])
+		}
+	}
+	if cycle := findCycle(steps); cycle != nil {
+		names := make([]string, len(cycle))
+		for k, i := range cycle {
+			names[k] = steps[i].label()
+		}
+		return nil, fmt.Errorf("these requests wait for each other: %s", strings.Join(names, " → "))
+	}
+	return steps, nil
+}
+
+// lastOf returns the last of steps, as a list to wait for; none if there
+// are none.
+func lastOf(steps []int) []int {
+	if len(steps) == 0 {
+		return nil
+	}
+	return steps[len(steps)-1:]
+}
+
+// findCycle returns steps that wait for each other in a circle, the first
+// repeated at the end, or nil if there are none.
+func findCycle(steps []runStep) []int {
+	const (
+		unseen = iota
+		visiting
+		finished
+	)
+	state := make([]int, len(steps))
+	var path []int
+	var visit func(i int) []int
+	visit = func(i int) []int {
+		state[i] = visiting
+		path = append(path, i)
+		for _, j := range steps[i].after {
+			switch state[j] {
+			case visiting:
+				start := slices.Index(path, j)
+				return append(slices.Clone(path[start:]), j)
+			case unseen:
+				if c := visit(j); c != nil {
+					return c
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = finished
+		return nil
+	}
+	for i := range steps {
+		if state[i] == unseen {
+			if c := visit(i); c != nil {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// runEvent says a step has started, or finished with result.
+type runEvent struct {
+	step   int
+	result *runResult // nil when it has only started.
+}
+
+// runSteps sends the steps, up to parallel at once, each once what it
+// waits for has finished, and tells onEvent how they get on. onEvent may be
+// called from several goroutines at once. It returns the results in the
+// order of the steps.
+func runSteps(base config, steps []runStep, g gates, parallel int, onEvent func(runEvent)) []runResult {
+	results := make([]runResult, len(steps))
+	run := func(i int) {
+		s := steps[i]
+		for _, j := range s.needs {
+			if !results[j].passed() {
+				results[i] = runResult{name: s.label(), folder: s.folder, method: s.req.method(), url: s.req.URL, failure: "skipped, as " + steps[j].label() + " failed"}
+				if s.script != nil {
+					results[i].method, results[i].url = "", strings.Join(s.script, " ")
+				}
+				onEvent(runEvent{i, &results[i]})
+				return
+			}
+		}
+		onEvent(runEvent{step: i})
+		var r runResult
+		if s.script != nil {
+			r = runScript(base, s)
+		} else {
+			r = sendSaved(base, s.req)
+			g.check(&r)
+			g.snapshots.check(s, &r)
+			g.contract.check(&r)
+		}
+		r.name, r.folder = s.label(), s.folder
+		results[i] = r
+		onEvent(runEvent{i, &results[i]})
+	}
+
+	// One at a time, in the order of the file as far as depends_on allows.
+	if parallel == 1 {
+		for _, i := range runOrder(steps) {
+			run(i)
+		}
+		return results
+	}
+
+	// Otherwise each step waits for its own, and only holds one of the
+	// parallel slots while it is being sent.
+	done := make([]chan struct{}, len(steps))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	slots := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := range steps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[i])
+			for _, j := range steps[i].after {
+				<-done[j]
+			}
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			run(i)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runOrder orders the steps so that each comes after what it waits for,
+// and otherwise keeps them in the order of the file.
+func runOrder(steps []runStep) []int {
+	var order []int
+	placed := make([]bool, len(steps))
+	for len(order) < len(steps) {
+		for i, s := range steps {
+			if !placed[i] && !slices.ContainsFunc(s.after, func(j int) bool { return !placed[j] }) {
+				order, placed[i] = append(order, i), true
+				break
+			}
+		}
+	}
+	return order
+}
+
+// printProgress returns an onEvent for runSteps that writes a line to w
+// for each request once it has finished.
+func printProgress(w io.Writer) func(runEvent) {
+	var mu sync.Mutex
+	return func(e runEvent) {
+		if e.result == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if f := e.result.folder; f != "" {
+			fmt.Fprintf(w, "[%s] ", f)
+		}
+		fmt.Fprintln(w, describeResult(*e.result))
+	}
+}
+
+// describeResult sums a finished request up in a line.
+func describeResult(r runResult) string {
+	mark := "✓"
+	if !r.passed() {
+		mark = "✗"
+	}
+	s := fmt.Sprintf("%s %s  %s %s", mark, r.name, r.method, r.url)
+	if r.method == "" { // A hook's command, which its name already shows.
+		s = mark + " " + r.name
+	}
+	if r.status > 0 {
+		s += fmt.Sprintf(" → %d", r.status)
+	}
+	if r.elapsed > 0 {
+		s += " in " + r.elapsed.Round(time.Millisecond).String()
+	}
+	if !r.passed() {
+		s += ": " + r.failure
+	}
+	for _, w := range r.warnings {
+		s += "\n    ⚠ " + w
+	}
+	return s
+}
+
+// isTerminal reports whether f is a terminal, where the live view can be
+// drawn, rather than a file or pipe, which get a line per request.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// runFinishedMsg says every step of a run has finished.
+type runFinishedMsg struct{}
+
+// runViewModel is the live view of a collection run: every folder with its
+// requests, and where each of them stands.
+type runViewModel struct {
+	steps   []runStep
+	started []bool
+	results []*runResult
+	spin    spinner.Model
+}
+
+// runLive runs the steps under the live view, drawn on out.
+func runLive(base config, steps []runStep, g gates, parallel int, out io.Writer) ([]runResult, error) {
+	m := runViewModel{steps: steps, started: make([]bool, len(steps)), results: make([]*runResult, len(steps)), spin: newSpinner()}
+	p := tea.NewProgram(m, tea.WithOutput(out), tea.WithInput(nil))
+	finished := make(chan []runResult, 1)
+	go func() {
+		finished <- runSteps(base, steps, g, parallel, func(e runEvent) { p.Send(e) })
+		p.Send(runFinishedMsg{})
+	}()
+	if _, err := p.Run(); err != nil {
+		return nil, err
+	}
+	select {
+	case results := <-finished:
+		return results, nil
+	default:
+		return nil, errors.New("the run was interrupted")
+	}
+}
+
+// Init starts the spinner.
+func (m runViewModel) Init() tea.Cmd { return m.spin.Tick }
+
+// Update follows the run.
+func (m runViewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case runEvent:
+		m.started = slices.Clone(m.started)
+		m.results = slices.Clone(m.results)
+		m.started[msg.step] = true
+		if msg.result != nil {
+			r := *msg.result
+			m.results[msg.step] = &r
+		}
+	case runFinishedMsg:
+		return m, tea.Quit
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spin, cmd = m.spin.Update(msg)
+		return m, cmd
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+// View draws the folders and their requests: ✓ or ✗ when finished, the
+// spinner while being sent, and otherwise what each is waiting for.
+func (m runViewModel) View() string {
+	var b strings.Builder
+	finished := 0
+	for _, r := range m.results {
+		if r != nil {
+			finished++
+		}
+	}
+	fmt.Fprintf(&b, "%d of %d requests finished\n", finished, len(m.steps))
+	folder := "\x00"
+	for i, s := range m.steps {
+		if s.folder != folder {
+			folder = s.folder
+			b.WriteString("\n" + cmp.Or(folder, "(no folder)") + "\n")
+		}
+		switch r := m.results[i]; {
+		case r != nil:
+			b.WriteString("  " + describeResult(*r) + "\n")
+		case m.started[i]:
+			b.WriteString("  " + m.spin.View() + " " + s.label() + "\n")
+		default:
+			var waiting []string
+			for _, j := range s.after {
+				if m.results[j] == nil {
+					waiting = append(waiting, m.steps[j].label())
+				}
+			}
+			line := "  · " + s.label()
+			if len(waiting) > 0 {
+				line += ", waiting for " + strings.Join(waiting, ", ")
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPlanRun(t *testing.T) {
+	c := &collection{
+		Requests: []collectionRequest{{Name: "login", URL: "/login"}},
+		Folders: []folder{
+			{Name: "pets", Requests: []collectionRequest{{ID: "add", Name: "Add", URL: "/pets"}, {Name: "List", URL: "/pets", DependsOn: []string{"login"}}}},
+			{Name: "orders", Requests: []collectionRequest{{Name: "Order", URL: "/orders", DependsOn: []string{"add"}}}},
+		},
+	}
+	steps, err := planRun(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 4 || steps[1].folder != "pets" || len(steps[1].after) != 0 {
+		t.Fatalf("steps = %+v", steps)
+	}
+	if !slices.Equal(steps[2].after, []int{1, 0}) || !slices.Equal(steps[2].needs, []int{0}) {
+		t.Errorf("List waits for %v and needs %v", steps[2].after, steps[2].needs)
+	}
+	if !slices.Equal(steps[3].after, []int{1}) {
+		t.Errorf("Order waits for %v", steps[3].after)
+	}
+
+	// A dependency on a later request changes the order of a serial run.
+	c.Requests[0].DependsOn = []string{"Order"}
+	steps, err = planRun(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order := runOrder(steps); !slices.Equal(order, []int{1, 3, 0, 2}) {
+		t.Errorf("order = %v", order)
+	}
+}
+
+func TestPlanRunErrors(t *testing.T) {
+	for want, c := range map[string]*collection{
+		"no request is called": {Requests: []collectionRequest{{URL: "/a", DependsOn: []string{"nope"}}}},
+		"several requests":     {Requests: []collectionRequest{{Name: "x", URL: "/a"}, {Name: "x", URL: "/b"}, {URL: "/c", DependsOn: []string{"x"}}}},
+		"the id":               {Requests: []collectionRequest{{ID: "x", URL: "/a"}, {ID: "x", URL: "/b"}}},
+		"a → b → a": {Folders: []folder{
+			{Requests: []collectionRequest{{Name: "a", URL: "/a", DependsOn: []string{"b"}}}},
+			{Requests: []collectionRequest{{Name: "b", URL: "/b", DependsOn: []string{"a"}}}},
+		}},
+	} {
+		if _, err := planRun(c); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error %v, want one about %q", err, want)
+		}
+	}
+}
+
+func TestRunStepsParallel(t *testing.T) {
+	var running, most atomic.Int64
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		seen = append(seen, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	c := &collection{Folders: []folder{
+		{Name: "a", Requests: []collectionRequest{{Name: "a1", URL: srv.URL + "/a1"}, {Name: "a2", URL: srv.URL + "/a2"}}},
+		{Name: "b", Requests: []collectionRequest{{Name: "b1", URL: srv.URL + "/b1"}, {Name: "b2", URL: srv.URL + "/b2", DependsOn: []string{"a2"}}}},
+		{Name: "c", Requests: []collectionRequest{{Name: "broken", URL: srv.URL + "/broken"}, {Name: "c2", URL: srv.URL + "/c2", DependsOn: []string{"broken"}}}},
+	}}
+	steps, err := planRun(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events atomic.Int64
+	results := runSteps(config{bodyFormat: "json"}, steps, gates{}, 3, func(runEvent) { events.Add(1) })
+
+	if most.Load() < 2 || most.Load() > 3 {
+		t.Errorf("%d requests ran at once, want 2 or 3", most.Load())
+	}
+	if i, j := slices.Index(seen, "/a1"), slices.Index(seen, "/a2"); i > j {
+		t.Errorf("a folder ran out of order: %v", seen)
+	}
+	if i, j := slices.Index(seen, "/a2"), slices.Index(seen, "/b2"); i > j {
+		t.Errorf("b2 went before a2, which it depends on: %v", seen)
+	}
+	if slices.Contains(seen, "/c2") || results[5].failure != "skipped, as broken failed" || results[5].folder != "c" {
+		t.Errorf("c2 should be skipped: %+v", results[5])
+	}
+	// Everything sent starts and finishes; skipped steps only finish.
+	if events.Load() != 11 {
+		t.Errorf("%d events", events.Load())
+	}
+}
+
+func TestRunView(t *testing.T) {
+	steps := []runStep{
+		{req: collectionRequest{Name: "login", URL: "/login"}},
+		{req: collectionRequest{Name: "List", URL: "/pets"}, folder: "pets", after: []int{0}},
+	}
+	m := runViewModel{steps: steps, started: make([]bool, 2), results: make([]*runResult, 2), spin: newSpinner()}
+	next, _ := m.Update(runEvent{step: 0})
+	view := next.(runViewModel).View()
+	for _, want := range []string{"0 of 2 requests finished", "(no folder)\n", "pets\n  · List, waiting for login"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view lacks %q:\n%s", want, view)
+		}
+	}
+	next, _ = next.Update(runEvent{0, &runResult{name: "login", method: "GET", url: "/login", status: 200}})
+	if view := next.(runViewModel).View(); !strings.Contains(view, "✓ login  GET /login → 200") || strings.Contains(view, "waiting") {
+		t.Errorf("view:\n%s", view)
+	}
+}
+
+func TestPlanRunHooks(t *testing.T) {
+	c := &collection{
+		Before: []hook{{Request: "login"}},
+		After:  []hook{{Run: "echo done"}},
+		Requests: []collectionRequest{
+			{Name: "login", URL: "/login"},
+			{ID: "cleanup", Name: "Delete pets", Method: "DELETE", URL: "/pets"},
+		},
+		Folders: []folder{{
+			Name:     "pets",
+			Requests: []collectionRequest{{Name: "Add", URL: "/pets"}, {Name: "List", URL: "/pets"}},
+			Before:   []hook{{Run: "./seed.sh pets"}},
+			After:    []hook{{Request: "cleanup"}},
+		}},
+	}
+	steps, err := planRun(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, s := range steps {
+		labels = append(labels, s.label())
+	}
+	want := []string{"before: login", "before: run ./seed.sh pets", "Add", "List", "after: Delete pets", "after: run echo done"}
+	if !slices.Equal(labels, want) {
+		t.Fatalf("steps = %q, want %q", labels, want)
+	}
+	if !slices.Equal(steps[1].needs, []int{0}) || !slices.Equal(steps[2].needs, []int{0, 1}) || !slices.Equal(steps[3].after, []int{2, 0, 1}) {
+		t.Errorf("requests should need the before hooks: %+v", steps[1:4])
+	}
+	if !slices.Equal(steps[4].after, []int{3}) || len(steps[4].needs) != 0 {
+		t.Errorf("folder teardown waits for %v and needs %v", steps[4].after, steps[4].needs)
+	}
+	if !slices.Equal(steps[5].after, []int{4}) || len(steps[5].needs) != 0 {
+		t.Errorf("collection teardown waits for %v and needs %v", steps[5].after, steps[5].needs)
+	}
+
+	for want, c := range map[string]*collection{
+		"before hook: no request is called": {Requests: []collectionRequest{{URL: "/a"}}, Before: []hook{{Request: "nope"}}},
+		"not both":                          {Requests: []collectionRequest{{Name: "a", URL: "/a"}}, After: []hook{{Request: "a", Run: "true"}}},
+		"not a command line":                {Requests: []collectionRequest{{URL: "/a"}}, Folders: []folder{{Before: []hook{{Run: `"unclosed`}}}}},
+	} {
+		if _, err := planRun(c); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error %v, want one about %q", err, want)
+		}
+	}
+}
+
+func TestRunStepsHooks(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	c := &collection{
+		Requests: []collectionRequest{{Name: "Delete pets", Method: "DELETE", URL: "/pets"}},
+		Folders: []folder{{
+			Name:     "pets",
+			Requests: []collectionRequest{{Name: "Add", URL: "/pets"}},
+			Before:   []hook{{Run: `sh -c "echo seeding; test \"$HTTPWIZARD_FOLDER\" = pets && echo no database >&2 && exit 3"`}},
+			After:    []hook{{Request: "Delete pets"}},
+		}},
+	}
+	steps, err := planRun(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := config{bodyFormat: "json", envs: map[string]environment{"test": {Base: srv.URL}}, env: "test"}
+	for _, parallel := range []int{1, 2} {
+		seen = nil
+		results := runSteps(base, steps, gates{}, parallel, func(runEvent) {})
+		if len(results) != 3 {
+			t.Fatalf("results = %+v", results)
+		}
+		if got := results[0].failure; got != "exit status 3: no database" {
+			t.Errorf("the failed hook says %q", got)
+		}
+		if got := results[1].failure; got != `skipped, as before: run sh -c echo seeding; test "$HTTPWIZARD_FOLDER" = pets && echo no database >&2 && exit 3 failed` {
+			t.Errorf("Add says %q", got)
+		}
+		if !results[2].passed() || !slices.Equal(seen, []string{"DELETE /pets"}) {
+			t.Errorf("the teardown should run all the same: %+v, sent %v", results[2], seen)
+		}
+	}
+	if got := describeResult(runResult{name: "before: run ./seed.sh", url: "./seed.sh", elapsed: time.Second}); got != "✓ before: run ./seed.sh in 1s" {
+		t.Errorf("describeResult = %q", got)
+	}
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// maxViolations is how many of a body's violations of its schema are shown.
+const maxViolations = 10
+
+// jsonSchema is a JSON Schema that response bodies are checked against. It
+// may be a whole file or one schema inside a bigger document, such as
+// openapi.json#/components/schemas/Pet, in which case $refs are resolved
+// against that document.
+//
+// The keywords that say what a value has to look like are checked: type,
+// enum and const; properties, required, additionalProperties and the
+// counts of an object; items, prefixItems and the counts of an array;
+// the lengths, pattern and date-time, date, email and uuid formats of a
+// string; the bounds of a number; allOf, anyOf, oneOf and not; and OpenAPI
+// 3.0's nullable. Annotations, and keywords of their own, are left alone.
+type jsonSchema struct {
+	doc    any // The document the schema is in, for $ref.
+	schema any
+}
+
+// loadSchema reads the schema ref names: a JSON file, or file#/pointer for
+// the schema at that JSON Pointer in it. A relative file is looked for in
+// dir.
+func loadSchema(ref, dir string) (*jsonSchema, error) {
+	file, pointer, _ := strings.Cut(ref, "#")
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("reading the schema %s: %w (YAML has to be converted to JSON first)", file, err)
+	}
+	s, err := resolvePointer(doc, pointer)
+	if err != nil {
+		return nil, fmt.Errorf("schema %s: %w", ref, err)
+	}
+	return &jsonSchema{doc: doc, schema: s}, nil
+}
+
+// parseSchema reads a schema written out in full.
+func parseSchema(b []byte) (*jsonSchema, error) {
+	doc, err := decodeJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonSchema{doc: doc, schema: doc}, nil
+}
+
+// decodeJSON decodes a single JSON value, keeping numbers as they are
+// written.
+func decodeJSON(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.Decode(new(any)) != io.EOF {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return v, nil
+}
+
+// resolvePointer finds the value at a JSON Pointer, such as
+// /components/schemas/Pet, in doc.
+func resolvePointer(doc any, pointer string) (any, error) {
+	if pointer == "" {
+		return doc, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%q is not a JSON Pointer, which starts with /", pointer)
+	}
+	v := doc
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch node := v.(type) {
+		case map[string]any:
+			child, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("nothing is at %s", pointer)
+			}
+			v = child
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("nothing is at %s", pointer)
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf("nothing is at %s", pointer)
+		}
+	}
+	return v, nil
+}
+
+// pointerToken escapes an object key for a JSON Pointer.
+func pointerToken(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// validateBody checks a body against s, and lists how it falls short, each
+// violation with the JSON Pointer of the value it is about, e.g.
+// "#/pets/0/name: expected a string, got a number".
+func (s *jsonSchema) validateBody(body []byte) []string {
+	v, err := decodeJSON(body)
+	if err != nil {
+		return []string{"#: the body isn't JSON: " + err.Error()}
+	}
+	var out []string
+	s.validate(&out, "#", s.schema, v, 0)
+	return out
+}
+
+// maxRefDepth bounds how many $refs deep validation goes, so that a schema
+// that refers to itself without ever reaching a value can't loop.
+const maxRefDepth = 64
+
+// validate adds to out how v, at pointer, breaks schema.
+func (s *jsonSchema) validate(out *[]string, pointer string, schema, v any, depth int) {
+	add := func(format string, args ...any) {
+		*out = append(*out, pointer+": "+fmt.Sprintf(format, args...))
+	}
+	if allowed, ok := schema.(bool); ok {
+		if !allowed {
+			add("no value is allowed here")
+		}
+		return
+	}
+	sch, ok := schema.(map[string]any)
+	if !ok {
+		return
+	}
+
+	if ref, ok := sch["$ref"].(string); ok {
+		target, err := s.resolveRef(ref)
+		switch {
+		case err != nil:
+			add("%v", err)
+		case depth >= maxRefDepth:
+			add("$ref %s goes more than %d deep", ref, maxRefDepth)
+		default:
+			s.validate(out, pointer, target, v, depth+1)
+		}
+	}
+
+	if v == nil && sch["nullable"] == true {
+		return
+	}
+	if types := schemaTypes(sch["type"]); len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool { return hasType(v, t) }) {
+		add("expected %s, got %s", strings.Join(withArticles(types), " or "), withArticle(typeOf(v)))
+		return
+	}
+	if enum, ok := sch["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return sameJSON(e, v) }) {
+		add("%s is not one of %s", shortJSON(v), shortJSON(enum))
+	}
+	if c, ok := sch["const"]; ok && !sameJSON(c, v) {
+		add("expected %s, got %s", shortJSON(c), shortJSON(v))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		s.validateObject(out, pointer, sch, v, depth)
+	case []any:
+		s.validateArray(out, pointer, sch, v, depth)
+	case string:
+		validateString(add, sch, v)
+	case json.Number:
+		validateNumber(add, sch, v)
+	}
+
+	if all, ok := sch["allOf"].([]any); ok {
+		for _, sub := range all {
+			s.validate(out, pointer, sub, v, depth)
+		}
+	}
+	if some, ok := sch["anyOf"].([]any); ok {
+		if s.matching(pointer, some, v, depth) == 0 {
+			add("matches none of the schemas anyOf allows")
+		}
+	}
+	if one, ok := sch["oneOf"].([]any); ok {
+		if n := s.matching(pointer, one, v, depth); n != 1 {
+			add("matches %d of the schemas oneOf allows, instead of exactly one", n)
+		}
+	}
+	if not, ok := sch["not"]; ok {
+		if s.matching(pointer, []any{not}, v, depth) == 1 {
+			add("matches the schema it must not")
+		}
+	}
+}
+
+// matching counts the schemas v matches.
+func (s *jsonSchema) matching(pointer string, schemas []any, v any, depth int) int {
+	n := 0
+	for _, sub := range schemas {
+		var violations []string
+		s.validate(&violations, pointer, sub, v, depth)
+		if len(violations) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// resolveRef finds the schema a $ref within the document points to.
+func (s *jsonSchema) resolveRef(ref string) (any, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("$ref %s: only references within the same document are followed", ref)
+	}
+	target, err := resolvePointer(s.doc, pointer)
+	if err != nil {
+		return nil, fmt.Errorf("$ref %s: %w", ref, err)
+	}
+	return target, nil
+}
+
+// validateObject checks the keywords about objects.
+func (s *jsonSchema) validateObject(out *[]string, pointer string, sch, v map[string]any, depth int) {
+	add := func(format string, args ...any) {
+		*out = append(*out, pointer+": "+fmt.Sprintf(format, args...))
+	}
+	if required, ok := sch["required"].([]any); ok {
+		for _, name := range required {
+			if name, ok := name.(string); ok {
+				if _, ok := v[name]; !ok {
+					add("%q is required but missing", name)
+				}
+			}
+		}
+	}
+	props, _ := sch["properties"].(map[string]any)
+	extra, hasExtra := sch["additionalProperties"]
+	for _, name := range slices.Sorted(maps.Keys(v)) {
+		child := pointer + "/" + pointerToken(name)
+		if prop, ok := props[name]; ok {
+			s.validate(out, child, prop, v[name], depth)
+			continue
+		}
+		switch {
+		case !hasExtra:
+		case extra == false:
+			*out = append(*out, child+": is not one of the properties allowed")
+		default:
+			s.validate(out, child, extra, v[name], depth)
+		}
+	}
+	if n, ok := schemaInt(sch["minProperties"]); ok && len(v) < n {
+		add("has %d properties, fewer than the %d it needs", len(v), n)
+	}
+	if n, ok := schemaInt(sch["maxProperties"]); ok && len(v) > n {
+		add("has %d properties, more than the %d allowed", len(v), n)
+	}
+}
+
+// validateArray checks the keywords about arrays.
+func (s *jsonSchema) validateArray(out *[]string, pointer string, sch map[string]any, v []any, depth int) {
+	add := func(format string, args ...any) {
+		*out = append(*out, pointer+": "+fmt.Sprintf(format, args...))
+	}
+	// Tuples are prefixItems now, and were an array of items before.
+	prefix, _ := sch["prefixItems"].([]any)
+	items := sch["items"]
+	if tuple, ok := items.([]any); ok {
+		prefix, items = tuple, sch["additionalItems"]
+	}
+	for i, el := range v {
+		child := pointer + "/" + strconv.Itoa(i)
+		switch {
+		case i < len(prefix):
+			s.validate(out, child, prefix[i], el, depth)
+		case items != nil:
+			s.validate(out, child, items, el, depth)
+		}
+	}
+	if n, ok := schemaInt(sch["minItems"]); ok && len(v) < n {
+		add("has %d items, fewer than the %d it needs", len(v), n)
+	}
+	if n, ok := schemaInt(sch["maxItems"]); ok && len(v) > n {
+		add("has %d items, more than the %d allowed", len(v), n)
+	}
+	if sch["uniqueItems"] == true {
+		for i := range v {
+			for j := i + 1; j < len(v); j++ {
+				if sameJSON(v[i], v[j]) {
+					add("items %d and %d are the same, but have to be unique", i, j)
+				}
+			}
+		}
+	}
+}
+
+// schemaFormats check the string formats APIs use most.
+var schemaFormats = map[string]func(string) bool{
+	"date-time": func(s string) bool { _, err := time.Parse(time.RFC3339, s); return err == nil },
+	"date":      func(s string) bool { _, err := time.Parse(time.DateOnly, s); return err == nil },
+	"email":     func(s string) bool { a, err := mail.ParseAddress(s); return err == nil && a.Address == s },
+	"uuid":      regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+}
+
+// schemaPatterns caches the compiled patterns of schemas.
+var schemaPatterns sync.Map
+
+// validateString checks the keywords about strings.
+func validateString(add func(string, ...any), sch map[string]any, v string) {
+	n := utf8.RuneCountInString(v)
+	if min, ok := schemaInt(sch["minLength"]); ok && n < min {
+		add("%s is %d characters long, shorter than %d", shortJSON(v), n, min)
+	}
+	if max, ok := schemaInt(sch["maxLength"]); ok && n > max {
+		add("%s is %d characters long, longer than %d", shortJSON(v), n, max)
+	}
+	if pattern, ok := sch["pattern"].(string); ok {
+		re, cached := schemaPatterns.Load(pattern)
+		if !cached {
+			compiled, err := regexp.Compile(pattern)
+			if err != nil {
+				add("the schema's pattern %q is not one Go can read: %v", pattern, err)
+				return
+			}
+			re, _ = schemaPatterns.LoadOrStore(pattern, compiled)
+		}
+		if !re.(*regexp.Regexp).MatchString(v) {
+			add("%s doesn't match the pattern %q", shortJSON(v), pattern)
+		}
+	}
+	if format, ok := sch["format"].(string); ok {
+		if valid, known := schemaFormats[format]; known && !valid(v) {
+			add("%s is not a valid %s", shortJSON(v), format)
+		}
+	}
+}
+
+// validateNumber checks the keywords about numbers.
+func validateNumber(add func(string, ...any), sch map[string]any, v json.Number) {
+	f, err := v.Float64()
+	if err != nil {
+		return
+	}
+	bound := func(key string) (float64, bool) {
+		n, ok := sch[key].(json.Number)
+		if !ok {
+			return 0, false
+		}
+		b, err := n.Float64()
+		return b, err == nil
+	}
+	// OpenAPI 3.0 and draft 4 make exclusiveMinimum a flag on minimum.
+	if min, ok := bound("minimum"); ok {
+		if sch["exclusiveMinimum"] == true && f <= min {
+			add("%s is not more than %v", v, min)
+		} else if f < min {
+			add("%s is less than the minimum %v", v, min)
+		}
+	}
+	if max, ok := bound("maximum"); ok {
+		if sch["exclusiveMaximum"] == true && f >= max {
+			add("%s is not less than %v", v, max)
+		} else if f > max {
+			add("%s is more than the maximum %v", v, max)
+		}
+	}
+	if min, ok := bound("exclusiveMinimum"); ok && f <= min {
+		add("%s is not more than %v", v, min)
+	}
+	if max, ok := bound("exclusiveMaximum"); ok && f >= max {
+		add("%s is not less than %v", v, max)
+	}
+	if m, ok := bound("multipleOf"); ok && m > 0 {
+		if q := f / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			add("%s is not a multiple of %v", v, m)
+		}
+	}
+}
+
+// schemaTypes reads a type keyword, which is one type or a list of them.
+func schemaTypes(t any) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		var types []string
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// hasType reports whether v is of the JSON Schema type t.
+func hasType(v any, t string) bool {
+	if t == "integer" {
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	}
+	return typeOf(v) == t || t == "number" && typeOf(v) == "integer"
+}
+
+// typeOf is the JSON Schema type of v: integer for whole numbers.
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case json.Number:
+		if hasType(v, "integer") {
+			return "integer"
+		}
+	}
+	return "number"
+}
+
+// withArticle puts a or an before a type, or none before null.
+func withArticle(t string) string {
+	switch {
+	case t == "null":
+		return t
+	case strings.ContainsRune("aeiou", rune(t[0])):
+		return "an " + t
+	}
+	return "a " + t
+}
+
+// withArticles puts an article before each of types.
+func withArticles(types []string) []string {
+	out := make([]string, len(types))
+	for i, t := range types {
+		out[i] = withArticle(t)
+	}
+	return out
+}
+
+// schemaInt reads a keyword whose value is a count.
+func schemaInt(v any) (int, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.Atoi(n.String())
+	return i, err == nil
+}
+
+// sameJSON reports whether a and b are the same JSON value, numbers being
+// the same if they are equal.
+func sameJSON(a, b any) bool {
+	if x, ok := a.(json.Number); ok {
+		y, ok := b.(json.Number)
+		fx, errx := x.Float64()
+		fy, erry := y.Float64()
+		return ok && errx == nil && erry == nil && fx == fy
+	}
+	// Marshalling sorts the keys of objects.
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
+
+// describeViolations lists a body's violations of its schema for the
+// screen or a failure, a few at most.
+func describeViolations(violations []string, sep string) string {
+	if len(violations) > maxViolations {
+		violations = append(violations[:maxViolations:maxViolations], fmt.Sprintf("and %d more", len(violations)-maxViolations))
+	}
+	return strings.Join(violations, sep)
+}
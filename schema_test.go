@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const petSchema = `{
+  "type": "object",
+  "required": ["id", "name"],
+  "additionalProperties": false,
+  "properties": {
+    "id": {"type": "integer", "minimum": 1},
+    "name": {"type": "string", "minLength": 1, "pattern": "^[A-Z]"},
+    "tag": {"type": "string", "nullable": true, "enum": ["dog", "cat"]},
+    "born": {"type": "string", "format": "date"},
+    "owners": {"type": "array", "maxItems": 2, "uniqueItems": true, "items": {"type": "string", "format": "email"}},
+    "price": {"type": ["number", "null"], "exclusiveMinimum": 0, "multipleOf": 0.5}
+  }
+}`
+
+func TestValidateBody(t *testing.T) {
+	s, err := parseSchema([]byte(petSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := s.validateBody([]byte(`{"id": 1, "name": "Rex", "tag": null, "born": "2020-02-29", "owners": ["a@example.com"], "price": 9.5}`)); len(v) != 0 {
+		t.Errorf("a valid pet breaks the schema: %q", v)
+	}
+	got := s.validateBody([]byte(`{"id": 1.5, "name": "rex", "tag": "fish", "born": "yesterday", "owners": ["x", "x", "y"], "price": 0, "extra/field": 1}`))
+	want := []string{
+		`#/born: "yesterday" is not a valid date`,
+		`#/extra~1field: is not one of the properties allowed`,
+		`#/id: expected an integer, got a number`,
+		`#/name: "rex" doesn't match the pattern "^[A-Z]"`,
+		`#/owners/0: "x" is not a valid email`,
+		`#/owners/1: "x" is not a valid email`,
+		`#/owners/2: "y" is not a valid email`,
+		`#/owners: has 3 items, more than the 2 allowed`,
+		`#/owners: items 0 and 1 are the same, but have to be unique`,
+		`#/price: 0 is not more than 0`,
+		`#/tag: "fish" is not one of ["dog","cat"]`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("violations:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if got := s.validateBody([]byte(`[1]`)); !slices.Equal(got, []string{"#: expected an object, got an array"}) {
+		t.Errorf("an array: %q", got)
+	}
+	if got := s.validateBody([]byte(`{"id": 2}`)); !slices.Equal(got, []string{`#: "name" is required but missing`}) {
+		t.Errorf("a missing field: %q", got)
+	}
+	if got := s.validateBody([]byte(`<html>`)); len(got) != 1 || !strings.HasPrefix(got[0], "#: the body isn't JSON") {
+		t.Errorf("not JSON: %q", got)
+	}
+}
+
+func TestValidateCombinators(t *testing.T) {
+	s, err := parseSchema([]byte(`{
+	  "oneOf": [{"type": "integer"}, {"type": "number", "maximum": 10}],
+	  "not": {"const": 3}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for body, want := range map[string]string{
+		"20":   "",
+		"2.5":  "",
+		"5":    "#: matches 2 of the schemas oneOf allows, instead of exactly one",
+		"3":    "#: matches 2 of the schemas oneOf allows, instead of exactly one; #: matches the schema it must not",
+		"12.5": "#: matches 0 of the schemas oneOf allows, instead of exactly one",
+	} {
+		if got := strings.Join(s.validateBody([]byte(body)), "; "); got != want {
+			t.Errorf("%s: %q, want %q", body, got, want)
+		}
+	}
+}
+
+func TestLoadSchemaFromOpenAPI(t *testing.T) {
+	dir := t.TempDir()
+	spec := `{"openapi": "3.0.3", "components": {"schemas": {
+	  "Pets": {"type": "array", "items": {"$ref": "#/components/schemas/Pet"}},
+	  "Pet": {"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}},
+	  "Loop": {"$ref": "#/components/schemas/Loop"}
+	}}}`
+	os.WriteFile(filepath.Join(dir, "openapi.json"), []byte(spec), 0o644)
+
+	s, err := loadSchema("openapi.json#/components/schemas/Pets", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.validateBody([]byte(`[{"name": "Rex"}, {"name": 7}, {}]`))
+	want := []string{"#/1/name: expected a string, got an integer", `#/2: "name" is required but missing`}
+	if !slices.Equal(got, want) {
+		t.Errorf("violations = %q, want %q", got, want)
+	}
+
+	loop, err := loadSchema(filepath.Join(dir, "openapi.json")+"#/components/schemas/Loop", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loop.validateBody([]byte(`1`)); len(got) != 1 || !strings.Contains(got[0], "more than 64 deep") {
+		t.Errorf("a looping $ref: %q", got)
+	}
+	if _, err := loadSchema("openapi.json#/components/schemas/Cat", dir); err == nil || !strings.Contains(err.Error(), "nothing is at /components/schemas/Cat") {
+		t.Errorf("a missing schema: %v", err)
+	}
+}
+
+func TestExpectationSchema(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "pet.json"), []byte(petSchema), 0o644)
+	path := filepath.Join(dir, "pets.json")
+	os.WriteFile(path, []byte(`{"requests": [
+	  {"url": "/a", "expect": {"schema": "pet.json"}},
+	  {"url": "/b", "expect": {"schema": {"type": "array"}}}
+	]}`), 0o644)
+	c, err := loadCollection(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := response{status: 200, body: []byte(`{"id": 0, "name": "Rex"}`)}
+	if got := c.Requests[0].Expect.check(res); got != "the body breaks its schema: #/id: 0 is less than the minimum 1" {
+		t.Errorf("check = %q", got)
+	}
+	if got := c.Requests[1].Expect.check(response{status: 200, body: []byte(`[]`)}); got != "" {
+		t.Errorf("an inline schema: %q", got)
+	}
+}
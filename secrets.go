@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A variable's value can name a secret instead of being one: an
+// environment's token may be op://Engineering/api/credential, and the
+// secret stays in 1Password. Such a reference is resolved when a request
+// that uses it is sent, by asking the manager's own command line, which
+// also does the signing in:
+//
+//	op://vault/item/field            op read, for 1Password
+//	vault://mount/path#field         vault kv get, for HashiCorp Vault
+//	aws-sm://secret-id[#key]         aws secretsmanager get-secret-value, for
+//	                                 AWS Secrets Manager; key picks one of
+//	                                 a JSON secret's values
+//
+// -secret-provider adds a scheme of one's own, or replaces one of these:
+// the command is given the reference as its last argument and prints the
+// secret.
+
+// secretTimeout bounds how long a provider may take, signing in included.
+const secretTimeout = 60 * time.Second
+
+// secretTTL is how long a resolved secret is kept, so each request doesn't
+// ask the manager again.
+const secretTTL = 5 * time.Minute
+
+// builtinSecretProviders make the command line that reads the secret ref
+// names, without its scheme, as path and, after a #, field.
+var builtinSecretProviders = map[string]func(ref, path, field string) ([]string, error){
+	"op": func(ref, _, _ string) ([]string, error) {
+		return []string{"op", "read", "--no-newline", ref}, nil
+	},
+	"vault": func(_, path, field string) ([]string, error) {
+		if field == "" {
+			return nil, fmt.Errorf("say which field of %s to read, as vault://%s#field", path, path)
+		}
+		return []string{"vault", "kv", "get", "-field=" + field, path}, nil
+	},
+	"aws-sm": func(_, path, _ string) ([]string, error) {
+		return []string{"aws", "secretsmanager", "get-secret-value", "--secret-id", path, "--query", "SecretString", "--output", "text"}, nil
+	},
+}
+
+// secretProviderFlag collects -secret-provider commands given as
+// "scheme=command", by scheme.
+type secretProviderFlag map[string][]string
+
+// String implements flag.Value.
+func (p *secretProviderFlag) String() string {
+	return strings.Join(slices.Sorted(maps.Keys(*p)), ", ")
+}
+
+// Set implements flag.Value.
+func (p *secretProviderFlag) Set(v string) error {
+	scheme, command, ok := strings.Cut(v, "=")
+	scheme = strings.ToLower(strings.TrimSpace(scheme))
+	if !ok || scheme == "" {
+		return fmt.Errorf("secret provider %q is not in \"scheme=command\" form", v)
+	}
+	args, err := shellSplit(command)
+	if err != nil || len(args) == 0 {
+		return fmt.Errorf("secret provider for %s: not a command line", scheme)
+	}
+	if *p == nil {
+		*p = secretProviderFlag{}
+	}
+	(*p)[scheme] = args
+	return nil
+}
+
+// secretScheme returns the scheme of value if it is a reference to a secret
+// that cfg has a provider for.
+func secretScheme(cfg config, value string) (string, bool) {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return "", false
+	}
+	scheme = strings.ToLower(scheme)
+	_, custom := cfg.secretProviders[scheme]
+	_, builtin := builtinSecretProviders[scheme]
+	return scheme, custom || builtin
+}
+
+// secretCommand returns the command line that reads the secret ref, of
+// scheme, for cfg.
+func secretCommand(cfg config, scheme, ref string) ([]string, error) {
+	if args, ok := cfg.secretProviders[scheme]; ok {
+		return append(slices.Clone(args), ref), nil
+	}
+	path, field, _ := strings.Cut(ref[len(scheme)+len("://"):], "#")
+	return builtinSecretProviders[scheme](ref, path, field)
+}
+
+// secretCache keeps resolved secrets for secretTTL, by reference. It lives
+// only as long as the program.
+var secretCache = struct {
+	sync.Mutex
+	values map[string]cachedSecret
+}{values: map[string]cachedSecret{}}
+
+type cachedSecret struct {
+	value   string
+	fetched time.Time
+}
+
+// readSecret resolves the secret ref, of scheme, with cfg's providers.
+func readSecret(cfg config, scheme, ref string) (string, error) {
+	secretCache.Lock()
+	c, ok := secretCache.values[ref]
+	secretCache.Unlock()
+	if ok && time.Since(c.fetched) < secretTTL {
+		return c.value, nil
+	}
+
+	args, err := secretCommand(cfg, scheme, ref)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", args[0], err)
+	}
+	value := strings.TrimRight(string(out), "\r\n")
+
+	// An AWS secret is often a JSON object of several values.
+	if _, key, ok := strings.Cut(ref, "#"); ok && scheme == "aws-sm" && cfg.secretProviders[scheme] == nil {
+		var values map[string]any
+		if err := json.Unmarshal([]byte(value), &values); err != nil {
+			return "", fmt.Errorf("%s is not a JSON object of values, so it has no %s", ref[:strings.Index(ref, "#")], key)
+		}
+		v, ok := values[key]
+		if !ok {
+			return "", fmt.Errorf("the secret has no %s", key)
+		}
+		if s, ok := v.(string); ok {
+			value = s
+		} else {
+			value = fmt.Sprint(v)
+		}
+	}
+
+	secretCache.Lock()
+	secretCache.values[ref] = cachedSecret{value, time.Now()}
+	secretCache.Unlock()
+	return value, nil
+}
+
+// resolveSecrets returns cfg with the variables its request uses whose
+// values are references to secrets resolved, for expanding it.
+func resolveSecrets(cfg config) (config, error) {
+	resolved := map[string]string{}
+	for _, name := range references(cfg) {
+		v, ok := lookupVariable(cfg, name)
+		if !ok {
+			continue
+		}
+		scheme, ok := secretScheme(cfg, v)
+		if !ok {
+			continue
+		}
+		secret, err := readSecret(cfg, scheme, v)
+		if err != nil {
+			return cfg, fmt.Errorf("{{%s}}: %w", name, err)
+		}
+		resolved[name] = secret
+	}
+	if len(resolved) == 0 {
+		return cfg, nil
+	}
+	// They take the place of the references in the most specific source,
+	// on a copy, so nothing else ever holds them.
+	captured := maps.Clone(cfg.captured)
+	if captured == nil {
+		captured = map[string]string{}
+	}
+	maps.Copy(captured, resolved)
+	cfg.captured = captured
+	return cfg, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSecretCommand(t *testing.T) {
+	cfg := config{}
+	for _, c := range []struct {
+		ref  string
+		want []string
+	}{
+		{"op://Engineering/api/credential", []string{"op", "read", "--no-newline", "op://Engineering/api/credential"}},
+		{"vault://secret/app#token", []string{"vault", "kv", "get", "-field=token", "secret/app"}},
+		{"aws-sm://arn:aws:secretsmanager:eu-west-1:1:secret:api#key", []string{"aws", "secretsmanager", "get-secret-value", "--secret-id", "arn:aws:secretsmanager:eu-west-1:1:secret:api", "--query", "SecretString", "--output", "text"}},
+	} {
+		scheme, ok := secretScheme(cfg, c.ref)
+		if !ok {
+			t.Errorf("%s is not a secret", c.ref)
+			continue
+		}
+		got, err := secretCommand(cfg, scheme, c.ref)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: %q, %v", c.ref, got, err)
+		}
+	}
+	if _, err := secretCommand(cfg, "vault", "vault://secret/app"); err == nil {
+		t.Error("a vault reference without a field was taken")
+	}
+	for _, v := range []string{"https://api.example.com/", "plain", "s3://bucket/key"} {
+		if _, ok := secretScheme(cfg, v); ok {
+			t.Errorf("%s was taken for a secret", v)
+		}
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	// A provider of one's own, which makes up a secret for the reference.
+	script := filepath.Join(t.TempDir(), "provider")
+	os.WriteFile(script, []byte("#!/bin/sh\necho \"secret of $1\"\n"), 0o755)
+	var providers secretProviderFlag
+	if err := providers.Set("test=" + script); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config{
+		method: "GET", url: "https://api.example.com/?k={{key}}",
+		header: http.Header{"Authorization": {"Bearer {{token}}"}},
+		envs:   map[string]environment{"dev": {Variables: map[string]string{"token": "test://abc", "key": "plain", "unused": "test://never"}}},
+		env:    "dev", secretProviders: providers,
+	}
+	got, err := expandRequest(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := got.header.Get("Authorization"); h != "Bearer secret of test://abc" || got.url != "https://api.example.com/?k=plain" {
+		t.Errorf("header %q, url %q", h, got.url)
+	}
+	if cfg.captured != nil || cfg.envs["dev"].Variables["token"] != "test://abc" {
+		t.Error("the secret was kept in the configuration")
+	}
+	if _, ok := secretCache.values["test://never"]; ok {
+		t.Error("a secret no request uses was read")
+	}
+
+	providers.Set("fail=false")
+	cfg.envs["dev"].Variables["token"] = "fail://x"
+	if _, err := expandRequest(cfg); err == nil || !strings.HasPrefix(err.Error(), "{{token}}: false") {
+		t.Errorf("a failing provider: %v", err)
+	}
+}
+
+func TestSecretsStayOutOfRecords(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }))
+	defer srv.Close()
+	script := filepath.Join(t.TempDir(), "provider")
+	os.WriteFile(script, []byte("#!/bin/sh\necho hunter2\n"), 0o755)
+	var providers secretProviderFlag
+	providers.Set("test=" + script)
+	dir := t.TempDir()
+	cfg := config{
+		method: "POST", url: srv.URL + "/?key={{key}}", body: []byte(`{"key": "{{key}}"}`),
+		envs: map[string]environment{"dev": {Variables: map[string]string{"key": "test://k"}}},
+		env:  "dev", secretProviders: providers,
+		auditFile: filepath.Join(dir, "audit.log"), auditBodies: true,
+		offlineFile: filepath.Join(dir, "offline.json"), keepOffline: true,
+	}
+	if _, ok := send(cfg).(responseMsg); !ok {
+		t.Fatal("send failed")
+	}
+	for _, f := range []string{cfg.auditFile, cfg.offlineFile} {
+		b, err := os.ReadFile(f)
+		if err != nil || strings.Contains(string(b), "hunter2") || !strings.Contains(string(b), "{{key}}") {
+			t.Errorf("%s holds:\n%s", filepath.Base(f), b)
+		}
+	}
+}
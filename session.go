@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The workspace outlives the program: on the way out, however that is,
+// the open tabs are kept in the session file, each with its request and
+// how it was laid out, and the next start opens them again. -fresh starts
+// with just the request on the command line.
+
+// savedTab is a tab as the session file keeps it.
+type savedTab struct {
+	Method   string            `json:"method"`
+	URL      string            `json:"url"`
+	Header   http.Header       `json:"header,omitempty"`
+	Body     []byte            `json:"body,omitempty"`
+	Env      string            `json:"env,omitempty"`
+	Captured map[string]string `json:"captured,omitempty"`
+
+	// How the tab was laid out: which panes were open, and how far down
+	// the body it was scrolled.
+	ShowBody bool   `json:"show_body,omitempty"`
+	BodyTop  int    `json:"body_top,omitempty"`
+	ShowLog  bool   `json:"show_log,omitempty"`
+	LogLevel string `json:"log_level,omitempty"`
+	Decoded  bool   `json:"decoded,omitempty"`
+
+	Edit *savedEdit `json:"edit,omitempty"` // What was being edited in it, if anything; see recovery.go.
+}
+
+// session is the open tabs, and which of them was on screen.
+type session struct {
+	Name   string     `json:"name,omitempty"` // The named session the tabs are, if any; see sessions.go.
+	Tabs   []savedTab `json:"tabs"`
+	Active int        `json:"active"`
+	Saved  time.Time  `json:"saved"`
+}
+
+// defaultSessionFile is where the session is kept unless -session-file
+// says otherwise.
+func defaultSessionFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "httpwizard", "session.json")
+}
+
+// saveTab keeps what m holds of its request and layout.
+func saveTab(m model) savedTab {
+	return savedTab{
+		Method:   m.cfg.method,
+		URL:      m.cfg.url,
+		Header:   m.cfg.header,
+		Body:     m.cfg.body,
+		Env:      m.cfg.env,
+		Captured: m.cfg.captured,
+		ShowBody: m.showBody,
+		BodyTop:  m.bodyTop,
+		ShowLog:  m.showLog,
+		LogLevel: m.logLevel.String(),
+		Decoded:  m.decoded,
+		Edit:     editOf(m),
+	}
+}
+
+// session returns the tabs open in a.
+func (a app) session() session {
+	s := session{Name: a.name, Active: a.active, Saved: time.Now().UTC()}
+	for _, t := range a.tabs {
+		s.Tabs = append(s.Tabs, saveTab(t))
+	}
+	return s
+}
+
+// saveSession writes s to path. The tabs' headers may carry credentials,
+// so only the user can read the file.
+func saveSession(path string, s session) error {
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(out, '\n'))
+}
+
+// loadSession reads the session at path. There being none is no error; it
+// is an empty session.
+func loadSession(path string) (session, error) {
+	var s session
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// restoreTab opens t again as a tab like base, the tab the command line
+// describes.
+func restoreTab(base model, t savedTab) model {
+	m := base
+	m.cfg.method, m.cfg.url, m.cfg.path = t.Method, t.URL, t.URL
+	m.cfg.header = t.Header.Clone()
+	if m.cfg.header == nil {
+		m.cfg.header = http.Header{}
+	}
+	m.cfg.body, m.cfg.env, m.cfg.captured = t.Body, t.Env, t.Captured
+	m.cfg.bundle = nil
+	m.showBody, m.showLog, m.decoded = t.ShowBody, t.ShowLog, t.Decoded
+	m.logLevel, _ = parseLogLevel(t.LogLevel)
+	m.resumeTop = t.BodyTop
+	m.confirm = nil
+	if t.Edit != nil {
+		m = t.Edit.reopen(m)
+	}
+
+	// What changes things on the server isn't sent again without asking.
+	sendIt := func(m model) (tea.Model, tea.Cmd) { return m, checkServer(m.cfg) }
+	if !slices.Contains([]string{http.MethodGet, http.MethodHead, http.MethodOptions}, m.cfg.method) {
+		host := ""
+		if u, err := url.Parse(m.cfg.url); err == nil {
+			host = u.Hostname()
+		}
+		m.confirm = holdFor(host, "The restored "+m.cfg.method+" "+m.cfg.url, sendIt)
+		m.confirm.detail = "This tab was open when the program last quit. It isn't sent again unless you say so."
+	}
+	return m
+}
+
+// restoreSession opens the tabs of s again, like first. If first was asked
+// for on the command line, it opens after them, on screen; otherwise the
+// tab that was on screen is again. A session recovered from a run that
+// didn't quit says so in the tabs whose edits it saved.
+func restoreSession(first model, s session, asked, recovered bool) app {
+	a := app{nextID: 1, name: s.Name}
+	for _, t := range s.Tabs {
+		m := restoreTab(first, t)
+		if recovered && t.Edit != nil {
+			m.report = recoveredNote(s.Saved)
+		}
+		m.id = a.nextID
+		a.nextID++
+		a.tabs = append(a.tabs, m)
+	}
+	a.active = min(max(s.Active, 0), len(a.tabs)-1)
+	if asked || len(a.tabs) == 0 {
+		first.id = a.nextID
+		a.nextID++
+		a.tabs = append(a.tabs, first)
+		a.active = len(a.tabs) - 1
+	}
+	return a
+}
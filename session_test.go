@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	get := model{cfg: config{method: "GET", url: "https://example.com/orders", header: http.Header{"Accept": {"application/json"}}, env: "dev"}, showBody: true, bodyTop: 40, logLevel: logWarn}
+	post := model{cfg: config{method: "POST", url: "https://example.com/orders", header: http.Header{}, body: []byte(`{"n": 1}`)}}
+	a := app{tabs: []model{get, post}, active: 1}
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := saveSession(path, a.session()); err != nil {
+		t.Fatal(err)
+	}
+	s, err := loadSession(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := model{cfg: config{method: "GET", url: defaultURL, header: http.Header{}, readOnly: true}}
+	restored := restoreSession(base, s, false, false)
+	if len(restored.tabs) != 2 || restored.active != 1 || restored.nextID != 3 {
+		t.Fatalf("restored %d tabs, active %d, next ID %d", len(restored.tabs), restored.active, restored.nextID)
+	}
+	g, p := restored.tabs[0], restored.tabs[1]
+	if g.cfg.url != get.cfg.url || g.cfg.header.Get("Accept") != "application/json" || g.cfg.env != "dev" || !g.showBody || g.resumeTop != 40 || g.logLevel != logWarn || !g.cfg.readOnly {
+		t.Errorf("GET tab = %+v", g)
+	}
+	if g.confirm != nil {
+		t.Error("the restored GET is held back")
+	}
+	if string(p.cfg.body) != `{"n": 1}` || p.confirm == nil || p.confirm.host != "example.com" {
+		t.Errorf("POST tab = %+v, confirm %+v", p.cfg, p.confirm)
+	}
+
+	// A request on the command line opens after the restored tabs.
+	restored = restoreSession(base, s, true, false)
+	if len(restored.tabs) != 3 || restored.active != 2 || restored.tabs[2].cfg.url != defaultURL {
+		t.Errorf("with a request asked for: %d tabs, active %d", len(restored.tabs), restored.active)
+	}
+}
+
+func TestResumeScroll(t *testing.T) {
+	m := model{resumeTop: 5}
+	next, _ := m.Update(responseMsg(describeBody(config{}, http.Header{"Content-Type": {"text/plain"}}, []byte(strings.Repeat("line\n", 100)))))
+	if m = next.(model); m.bodyTop != 5 || m.resumeTop != 0 {
+		t.Errorf("bodyTop = %d, resumeTop = %d", m.bodyTop, m.resumeTop)
+	}
+}
+
+func TestLoadMissingSession(t *testing.T) {
+	s, err := loadSession(filepath.Join(t.TempDir(), "none.json"))
+	if err != nil || len(s.Tabs) != 0 {
+		t.Errorf("loadSession = %+v, %v", s, err)
+	}
+}
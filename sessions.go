@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Named sessions are sets of tabs kept under a name, "payments debugging"
+// say, each tab with its request and environment, to switch between from
+// the palette. Switching keeps the tabs open now under the session they
+// came from, if they came from one, so going back finds them as they were.
+
+// sessionMsg asks the app to save, open, delete or list the named sessions; only
+// the app sees every tab.
+type sessionMsg struct {
+	verb string // save, open, delete or list.
+	name string
+}
+
+// runSession is the palette's `session [save | open | delete NAME]`: it
+// saves the open tabs as a named session, replaces them with those of one,
+// moves one into the trash, or lists them.
+func runSession(m model, args []string) (tea.Model, tea.Cmd) {
+	msg := sessionMsg{verb: "list"}
+	if len(args) > 0 {
+		msg = sessionMsg{verb: args[0], name: strings.Join(args[1:], " ")}
+	}
+	if (msg.verb != "list" && msg.verb != "save" && msg.verb != "open" && msg.verb != "delete") || (msg.verb != "list" && msg.name == "") {
+		return m.paletteError(errors.New("usage: session [save NAME | open NAME | delete NAME]"))
+	}
+	if m.cfg.sessionFile == "" {
+		return m.paletteError(errors.New("sessions are kept beside -session-file, which is off"))
+	}
+	return m, func() tea.Msg { return msg }
+}
+
+// sessionsDir is where the named sessions are kept, beside the session file.
+func sessionsDir(sessionFile string) string {
+	return filepath.Join(filepath.Dir(sessionFile), "sessions")
+}
+
+// namedSessionFile is the file the session called name is kept in.
+func namedSessionFile(sessionFile, name string) string {
+	return filepath.Join(sessionsDir(sessionFile), url.PathEscape(name)+".json")
+}
+
+// handleSession does what msg asks, and reports on the tab on screen.
+func (a app) handleSession(msg sessionMsg) (tea.Model, tea.Cmd) {
+	file := a.tabs[a.active].cfg.sessionFile
+	var cmd tea.Cmd
+	var report reportMsg
+	switch msg.verb {
+	case "save":
+		s := a.session()
+		s.Name = msg.name
+		if err := saveSession(namedSessionFile(file, msg.name), s); err != nil {
+			report = reportMsg{title: "Session", body: "Couldn't save it: " + err.Error()}
+			break
+		}
+		a.name = msg.name
+		report = reportMsg{title: "Session", body: fmt.Sprintf("Saved the %d tabs as %q", len(a.tabs), msg.name)}
+	case "open":
+		next, start, err := a.openSession(msg.name)
+		if err != nil {
+			report = reportMsg{title: "Session", body: err.Error()}
+			break
+		}
+		a, cmd = next, start
+		report = reportMsg{title: "Session", body: fmt.Sprintf("Opened %q, with %d tabs", msg.name, len(a.tabs))}
+	case "delete":
+		if err := trashSessionFile(file, a.tabs[a.active].cfg.trashFile, msg.name); err != nil {
+			report = reportMsg{title: "Session", body: "Couldn't delete it: " + err.Error()}
+			break
+		}
+		// The tabs stay open, but are no longer kept under the name.
+		if a.name == msg.name {
+			a.name = ""
+		}
+		report = reportMsg{title: "Session", body: fmt.Sprintf("Moved %q into the trash; trash restore puts it back", msg.name)}
+	default:
+		report = listSessions(file, a.name)
+	}
+	a.tabs = append([]model(nil), a.tabs...)
+	a.tabs[a.active].report, a.tabs[a.active].cursor = &report, 0
+	return a, cmd
+}
+
+// openSession replaces the open tabs with those of the session called name,
+// first keeping them under the session they came from, if any. It returns
+// the commands the new tabs start with.
+func (a app) openSession(name string) (app, tea.Cmd, error) {
+	current := a.tabs[a.active]
+	file := current.cfg.sessionFile
+	s, err := loadSession(namedSessionFile(file, name))
+	if err != nil {
+		return a, nil, fmt.Errorf("couldn't open %q: %w", name, err)
+	}
+	if len(s.Tabs) == 0 {
+		return a, nil, fmt.Errorf("there is no session %q; save one with session save %s", name, name)
+	}
+	if a.name != "" && a.name != name {
+		if err := saveSession(namedSessionFile(file, a.name), a.session()); err != nil {
+			return a, nil, fmt.Errorf("couldn't keep %q before switching: %w", a.name, err)
+		}
+	}
+
+	// The tabs going away stop streaming, and the new ones are numbered on
+	// from the old, so that answers still on their way to the old ones
+	// don't land in the new.
+	for _, t := range a.tabs {
+		if t.res.streaming {
+			t.res.stopStream()
+		}
+	}
+	base := model{cfg: current.cfg, spin: newSpinner()}
+	next := restoreSession(base, s, false, false)
+	var cmds []tea.Cmd
+	for i := range next.tabs {
+		next.tabs[i].id += a.nextID - 1
+		cmds = append(cmds, tagged(next.tabs[i].id, next.tabs[i].start()))
+	}
+	next.nextID += a.nextID - 1
+	next.name, next.autosaved = name, a.autosaved
+	return next, tea.Batch(cmds...), nil
+}
+
+// listSessions reports the named sessions kept beside sessionFile, marking
+// current, the one open.
+func listSessions(sessionFile, current string) reportMsg {
+	entries, err := os.ReadDir(sessionsDir(sessionFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return reportMsg{title: "Sessions", body: err.Error()}
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		escaped, ok := strings.CutSuffix(e.Name(), ".json")
+		name, err := url.PathUnescape(escaped)
+		if !ok || err != nil {
+			continue
+		}
+		s, err := loadSession(filepath.Join(sessionsDir(sessionFile), e.Name()))
+		if err != nil {
+			fmt.Fprintf(&b, "  %s: %v\n", name, err)
+			continue
+		}
+		mark := " "
+		if name == current {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "%s %s: %d tabs, saved %s\n", mark, name, len(s.Tabs), s.Saved.Local().Format(time.DateTime))
+	}
+	if b.Len() == 0 {
+		return reportMsg{title: "Sessions", body: "No sessions yet; save the open tabs as one with session save NAME."}
+	}
+	return reportMsg{title: "Sessions", body: strings.TrimRight(b.String(), "\n")}
+}
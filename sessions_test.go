@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNamedSessions(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.json")
+	tab := func(u, env string) model {
+		return model{cfg: config{method: "GET", url: u, env: env, header: http.Header{}, sessionFile: file}}
+	}
+	payments := app{tabs: []model{tab("https://pay.example.com/charges", "staging"), tab("https://pay.example.com/refunds", "staging")}, nextID: 3}
+	for i := range payments.tabs {
+		payments.tabs[i].id = i + 1
+	}
+
+	next, _ := payments.handleSession(sessionMsg{verb: "save", name: "payments debugging"})
+	payments = next.(app)
+	if payments.name != "payments debugging" || !strings.Contains(payments.tabs[0].report.body, "Saved the 2 tabs") {
+		t.Fatalf("save: name %q, report %+v", payments.name, payments.tabs[0].report)
+	}
+
+	// Another context, saved under its own name, then switched away from.
+	auth := app{tabs: []model{tab("https://auth.example.com/token", "dev")}, nextID: 9}
+	auth.tabs[0].id = 8
+	next, _ = auth.handleSession(sessionMsg{verb: "save", name: "auth"})
+	auth = next.(app)
+	auth.tabs[0].cfg.url = "https://auth.example.com/userinfo"
+
+	next, cmd := auth.handleSession(sessionMsg{verb: "open", name: "payments debugging"})
+	opened := next.(app)
+	if cmd == nil || opened.name != "payments debugging" || len(opened.tabs) != 2 || opened.tabs[1].cfg.url != "https://pay.example.com/refunds" || opened.tabs[0].cfg.env != "staging" {
+		t.Fatalf("open: %+v", opened)
+	}
+	if opened.tabs[0].id != 9 || opened.nextID != 11 {
+		t.Errorf("new tab IDs %d, %d; next %d", opened.tabs[0].id, opened.tabs[1].id, opened.nextID)
+	}
+
+	// Going back finds auth as it was left.
+	next, _ = opened.handleSession(sessionMsg{verb: "open", name: "auth"})
+	if back := next.(app); back.tabs[0].cfg.url != "https://auth.example.com/userinfo" {
+		t.Errorf("auth came back with %s", back.tabs[0].cfg.url)
+	}
+
+	next, _ = opened.handleSession(sessionMsg{verb: "list"})
+	list := next.(app).tabs[opened.active].report.body
+	if !strings.Contains(list, "  auth: 1 tabs") || !strings.Contains(list, "* payments debugging: 2 tabs") {
+		t.Errorf("list:\n%s", list)
+	}
+
+	next, _ = opened.handleSession(sessionMsg{verb: "open", name: "nope"})
+	if r := next.(app).tabs[opened.active].report.body; !strings.Contains(r, `there is no session "nope"`) {
+		t.Errorf("opening a missing session: %s", r)
+	}
+}
+
+func TestRunSessionUsage(t *testing.T) {
+	m := model{cfg: config{sessionFile: "session.json"}}
+	for _, args := range [][]string{{"save"}, {"rename", "x"}} {
+		if next, cmd := runSession(m, args); cmd != nil || next.(model).report == nil {
+			t.Errorf("session %v wasn't refused", args)
+		}
+	}
+	if _, cmd := runSession(m, []string{"open", "payments", "debugging"}); cmd == nil || cmd().(sessionMsg).name != "payments debugging" {
+		t.Error("session open with a spaced name")
+	}
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/websocket"
+)
+
+// The shared server keeps a team's environments and collections in step.
+// Everyone's program holds a websocket to it at /sync and speaks in
+// shareMessages: each says hello with its user's name, then sends a doc
+// whenever a file of its changes, and a viewing whenever it sends another
+// request. The server keeps the latest doc of each name, by when it was
+// changed, so the last writer wins, and passes every doc it keeps on to
+// the others. It also tells everyone who is connected, and what they are
+// looking at, with a presence message whenever that changes.
+//
+// The state is the team's environments, so only those with the server's
+// token get at it: the token goes in the -share URL as ?token=, and is
+// sent as a bearer token rather than in the URL. A browser can't open the
+// websocket for a page the user visits, for its Origin gives it away. And
+// whatever comes in, an environment protected here stays protected, and
+// keeps its base and route, so nobody can take the confirmation off
+// production, or point it elsewhere, from their own copy.
+
+// envDoc is the name the environments file is shared under; collections and
+// other files are shared as "file:" and their base name.
+const envDoc = "environments"
+
+// sharedDoc is one file everyone shares, as it was last written.
+type sharedDoc struct {
+	Name    string          `json:"name"`
+	Content json.RawMessage `json:"content"`
+	Updated time.Time       `json:"updated"`
+	By      string          `json:"by,omitempty"`
+}
+
+// peer is someone connected to the shared server.
+type peer struct {
+	User    string `json:"user"`
+	Viewing string `json:"viewing,omitempty"` // The request they last sent, e.g. "GET https://…".
+}
+
+// shareMessage is what goes either way over the websocket; Type says which
+// of the other fields it carries.
+type shareMessage struct {
+	Type    string     `json:"type"` // hello, doc, viewing or presence.
+	User    string     `json:"user,omitempty"`
+	Doc     *sharedDoc `json:"doc,omitempty"`
+	Viewing string     `json:"viewing,omitempty"`
+	Peers   []peer     `json:"peers,omitempty"`
+}
+
+// shareOrigin is the Origin the program dials the shared server with; a
+// browser sends its page's instead.
+const shareOrigin = "httpwizard://share"
+
+// shareServer is the shared state, and who is connected to it.
+type shareServer struct {
+	token string // What a program must send to connect.
+
+	mu    sync.Mutex
+	docs  map[string]sharedDoc
+	peers map[*websocket.Conn]*peer
+}
+
+func newShareServer(token string) *shareServer {
+	return &shareServer{token: token, docs: map[string]sharedDoc{}, peers: map[*websocket.Conn]*peer{}}
+}
+
+// runShareServer implements `share-server [-addr ADDRESS] [-token TOKEN]`.
+// Without a token, from the flag or HTTPWIZARD_SHARE_TOKEN, it makes one up.
+func runShareServer(args []string) error {
+	fs := flag.NewFlagSet("share-server", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:7070", "serve the shared state on this `address`; :7070 serves the whole network")
+	token := fs.String("token", os.Getenv("HTTPWIZARD_SHARE_TOKEN"), "the `secret` programs must connect with (default $HTTPWIZARD_SHARE_TOKEN, or a random one)")
+	fs.Parse(args)
+	if *token == "" {
+		b := make([]byte, 16)
+		rand.Read(b)
+		*token = hex.EncodeToString(b)
+	}
+	fmt.Printf("Sharing on ws://%s/sync; connect with -share 'ws://%s/sync?token=%s'\n", *addr, *addr, *token)
+	return http.ListenAndServe(*addr, newShareServer(*token))
+}
+
+// ServeHTTP takes the websockets of the programs sharing state, from those
+// with the token.
+func (s *shareServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/sync" {
+		http.NotFound(w, r)
+		return
+	}
+	got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if s.token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+		http.Error(w, "the shared server needs its token, as ?token= in the -share URL", http.StatusUnauthorized)
+		return
+	}
+	websocket.Server{Handler: s.serve, Handshake: checkShareOrigin}.ServeHTTP(w, r)
+}
+
+// checkShareOrigin turns away websockets a browser opens, which carry the
+// Origin of the page that opened them.
+func checkShareOrigin(_ *websocket.Config, r *http.Request) error {
+	if origin := r.Header.Get("Origin"); origin != shareOrigin {
+		return fmt.Errorf("websocket from %q rather than the program", origin)
+	}
+	return nil
+}
+
+// serve talks to one program until it goes away.
+func (s *shareServer) serve(ws *websocket.Conn) {
+	var hello shareMessage
+	if websocket.JSON.Receive(ws, &hello) != nil || hello.Type != "hello" {
+		return
+	}
+	p := &peer{User: hello.User}
+	s.mu.Lock()
+	s.peers[ws] = p
+	docs := slices.Collect(maps.Values(s.docs))
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.peers, ws)
+		s.mu.Unlock()
+		s.broadcast(s.presence(), nil)
+	}()
+
+	for _, d := range docs {
+		if websocket.JSON.Send(ws, shareMessage{Type: "doc", Doc: &d}) != nil {
+			return
+		}
+	}
+	s.broadcast(s.presence(), nil)
+
+	for {
+		var msg shareMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			return
+		}
+		switch {
+		case msg.Type == "doc" && msg.Doc != nil && msg.Doc.Name != "":
+			d := *msg.Doc
+			d.By = p.User
+			s.mu.Lock()
+			held, ok := s.docs[d.Name]
+			newer := !ok || d.Updated.After(held.Updated)
+			if newer {
+				s.docs[d.Name] = d
+			}
+			s.mu.Unlock()
+			if newer {
+				s.broadcast(shareMessage{Type: "doc", Doc: &d}, ws)
+			} else if held.Updated.After(d.Updated) {
+				// The sender is behind; put it right.
+				websocket.JSON.Send(ws, shareMessage{Type: "doc", Doc: &held})
+			}
+		case msg.Type == "viewing":
+			s.mu.Lock()
+			p.Viewing = msg.Viewing
+			s.mu.Unlock()
+			s.broadcast(s.presence(), nil)
+		}
+	}
+}
+
+// presence is the message saying who is connected, by name.
+func (s *shareServer) presence() shareMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	peers := make([]peer, 0, len(s.peers))
+	for _, p := range s.peers {
+		peers = append(peers, *p)
+	}
+	slices.SortFunc(peers, func(a, b peer) int { return strings.Compare(a.User, b.User) })
+	return shareMessage{Type: "presence", Peers: peers}
+}
+
+// broadcast sends msg to everyone connected but except.
+func (s *shareServer) broadcast(msg shareMessage, except *websocket.Conn) {
+	s.mu.Lock()
+	conns := make([]*websocket.Conn, 0, len(s.peers))
+	for ws := range s.peers {
+		if ws != except {
+			conns = append(conns, ws)
+		}
+	}
+	s.mu.Unlock()
+	for _, ws := range conns {
+		websocket.JSON.Send(ws, msg)
+	}
+}
+
+// shareClient is this program's connection to the shared server.
+type shareClient struct {
+	ws   *websocket.Conn
+	user string
+
+	mu    sync.Mutex
+	peers []peer // Who else is connected, as last heard.
+	err   error  // Why the connection closed, once it has.
+}
+
+// shareUser names this user on this machine for the others, e.g. ann@laptop.
+func shareUser() string {
+	host, _ := os.Hostname()
+	return auditUser() + "@" + host
+}
+
+// dialShare connects to the shared server at rawURL, a ws:// or wss:// URL,
+// and says hello as user. The URL's token query, or HTTPWIZARD_SHARE_TOKEN,
+// is sent as a bearer token, so that it isn't in the server's logs.
+func dialShare(rawURL, user string) (*shareClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	token := cmp.Or(q.Get("token"), os.Getenv("HTTPWIZARD_SHARE_TOKEN"))
+	q.Del("token")
+	u.RawQuery = q.Encode()
+	config, err := websocket.NewConfig(u.String(), shareOrigin)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		config.Header.Set("Authorization", "Bearer "+token)
+	}
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the shared server: %w", err)
+	}
+	c := &shareClient{ws: ws, user: user}
+	if err := websocket.JSON.Send(ws, shareMessage{Type: "hello", User: user}); err != nil {
+		ws.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// push shares the file at path as the doc name, as of when it last changed.
+// A file that isn't there yet has nothing to share.
+func (c *shareClient) push(name, path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !json.Valid(b) {
+		return fmt.Errorf("%s isn't JSON, so it can't be shared", path)
+	}
+	return websocket.JSON.Send(c.ws, shareMessage{Type: "doc", Doc: &sharedDoc{Name: name, Content: b, Updated: info.ModTime().UTC()}})
+}
+
+// view tells the others which request this program is looking at.
+func (c *shareClient) view(request string) error {
+	return websocket.JSON.Send(c.ws, shareMessage{Type: "viewing", Viewing: request})
+}
+
+// receive waits for the server's next message, noting who is connected.
+func (c *shareClient) receive() (shareMessage, error) {
+	var msg shareMessage
+	if err := websocket.JSON.Receive(c.ws, &msg); err != nil {
+		c.mu.Lock()
+		c.err = err
+		c.mu.Unlock()
+		return msg, err
+	}
+	if msg.Type == "presence" {
+		c.mu.Lock()
+		c.peers = slices.DeleteFunc(msg.Peers, func(p peer) bool { return p.User == c.user })
+		c.mu.Unlock()
+	}
+	return msg, nil
+}
+
+// others describes who else is connected, and what they are looking at.
+func (c *shareClient) others() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return "nobody, since the connection closed: " + c.err.Error()
+	}
+	if len(c.peers) == 0 {
+		return "nobody else"
+	}
+	var names []string
+	for _, p := range c.peers {
+		if p.Viewing != "" {
+			names = append(names, p.User+" ("+p.Viewing+")")
+		} else {
+			names = append(names, p.User)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// writeDoc writes what d holds to the file at path, with the time it was
+// changed, so that it doesn't look newer than it is when shared again.
+func writeDoc(path string, d sharedDoc) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// The content comes compacted; write it out for people to read.
+	var out bytes.Buffer
+	if err := json.Indent(&out, d.Content, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	if err := writeFileAtomic(path, out.Bytes()); err != nil {
+		return err
+	}
+	return os.Chtimes(path, d.Updated, d.Updated)
+}
+
+// shareMsg carries what the shared server said; err is why the connection
+// closed, if it did.
+type shareMsg struct {
+	msg shareMessage
+	err error
+}
+
+// listenShare waits for the shared server's next message.
+func listenShare(c *shareClient) tea.Cmd {
+	return func() tea.Msg {
+		msg, err := c.receive()
+		return shareMsg{msg, err}
+	}
+}
+
+// applyShare takes a shared change into the tab: new environments replace
+// its own, but for what keeps the protected ones safe.
+func (m model) applyShare(msg shareMessage) model {
+	if docName(msg) != envDoc {
+		return m
+	}
+	var envs map[string]environment
+	if json.Unmarshal(msg.Doc.Content, &envs) == nil {
+		m.cfg.envs = keepProtected(m.cfg.envs, envs, msg.Doc.By)
+	}
+	return m
+}
+
+// keepProtected returns the shared environments theirs, sent by by, with
+// each environment protected in mine as it was: still protected, with its
+// base, jump host and registry. Those a share tried to change are warned of.
+func keepProtected(mine, theirs map[string]environment, by string) map[string]environment {
+	if theirs == nil {
+		theirs = map[string]environment{}
+	}
+	for name, env := range mine {
+		if !env.Protected {
+			continue
+		}
+		got, ok := theirs[name]
+		if !ok || !got.Protected || got.Base != env.Base || got.SSH != env.SSH || !reflect.DeepEqual(got.Registry, env.Registry) {
+			logf(logWarn, "%s's shared environments change the protected %s; keeping its own protection, base and route", by, name)
+		}
+		got.Protected, got.Base, got.SSH, got.Registry = true, env.Base, env.SSH, env.Registry
+		theirs[name] = got
+	}
+	return theirs
+}
+
+// runShareFiles implements `share-files -share URL FILE...`: it keeps the
+// files, collections say, in step with everyone else's copies, until it is
+// stopped. A file changed here is shared within a second; one changed
+// elsewhere is written here.
+func runShareFiles(args []string) error {
+	fs := flag.NewFlagSet("share-files", flag.ExitOnError)
+	server := fs.String("share", "", "the shared server's `URL`, e.g. ws://team-box:7070/sync?token=SECRET")
+	fs.Parse(args)
+	if *server == "" || fs.NArg() == 0 {
+		return errors.New("usage: share-files -share URL FILE...")
+	}
+	c, err := dialShare(*server, shareUser())
+	if err != nil {
+		return err
+	}
+	return syncFiles(c, fs.Args(), time.Second, nil)
+}
+
+// syncFiles shares files over c, checking every interval for changes made
+// here, until the connection closes or stop is closed.
+func syncFiles(c *shareClient, files []string, interval time.Duration, stop <-chan struct{}) error {
+	byName := map[string]string{}
+	seen := map[string]time.Time{}
+	var mu sync.Mutex
+	for _, f := range files {
+		name := "file:" + filepath.Base(f)
+		byName[name] = f
+		if err := c.push(name, f); err != nil {
+			return err
+		}
+		if info, err := os.Stat(f); err == nil {
+			seen[f] = info.ModTime()
+		}
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := c.receive()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if path, ok := byName[docName(msg)]; ok {
+				if err := writeDoc(path, *msg.Doc); err != nil {
+					errs <- err
+					return
+				}
+				mu.Lock()
+				seen[path] = msg.Doc.Updated
+				mu.Unlock()
+				fmt.Printf("%s: updated by %s\n", path, msg.Doc.By)
+			}
+		}
+	}()
+
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case err := <-errs:
+			return err
+		case <-stop:
+			return c.ws.Close()
+		case <-tick.C:
+			for name, f := range byName {
+				info, err := os.Stat(f)
+				if err != nil {
+					continue
+				}
+				mu.Lock()
+				changed := !info.ModTime().Equal(seen[f])
+				seen[f] = info.ModTime()
+				mu.Unlock()
+				if changed {
+					if err := c.push(name, f); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+}
+
+// docName is the name of the doc msg carries, or "" if it carries none.
+func docName(msg shareMessage) string {
+	if msg.Type != "doc" || msg.Doc == nil {
+		return ""
+	}
+	return msg.Doc.Name
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// shareTestServer starts a shared server and returns its websocket URL,
+// token and all.
+func shareTestServer(t *testing.T) string {
+	srv := httptest.NewServer(newShareServer("s3cret"))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/sync?token=s3cret"
+}
+
+// receiveType waits for c's next message of type typ.
+func receiveType(t *testing.T, c *shareClient, typ string) shareMessage {
+	t.Helper()
+	for {
+		msg, err := c.receive()
+		if err != nil {
+			t.Fatalf("waiting for %s: %v", typ, err)
+		}
+		if msg.Type == typ {
+			return msg
+		}
+	}
+}
+
+func TestShareServer(t *testing.T) {
+	url := shareTestServer(t)
+	dir := t.TempDir()
+	envs := filepath.Join(dir, "environments.json")
+	os.WriteFile(envs, []byte(`{"dev": {"base": "http://localhost:8080/"}}`), 0o644)
+
+	ann, err := dialShare(url, "ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ann.push(envDoc, envs); err != nil {
+		t.Fatal(err)
+	}
+	receiveType(t, ann, "presence")
+
+	// Bob joins, gets the environments, and shows up for Ann.
+	bob, err := dialShare(url, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := receiveType(t, bob, "doc")
+	if msg.Doc.Name != envDoc || msg.Doc.By != "ann" {
+		t.Fatalf("bob got %+v", msg.Doc)
+	}
+	m := model{}.applyShare(msg)
+	if m.cfg.envs["dev"].Base != "http://localhost:8080/" {
+		t.Errorf("envs = %+v", m.cfg.envs)
+	}
+	receiveType(t, bob, "presence")
+	bob.view("GET https://example.com/")
+	for !strings.Contains(ann.others(), "bob (GET https://example.com/)") {
+		receiveType(t, ann, "presence")
+	}
+
+	// An older copy loses, and its sender is put right.
+	old := filepath.Join(dir, "old.json")
+	os.WriteFile(old, []byte(`{"dev": {"base": "http://stale/"}}`), 0o644)
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(old, past, past)
+	bob.push(envDoc, old)
+	if msg := receiveType(t, bob, "doc"); !strings.Contains(string(msg.Doc.Content), "localhost:8080") {
+		t.Errorf("bob was sent %s", msg.Doc.Content)
+	}
+}
+
+func TestShareServerRefuses(t *testing.T) {
+	url := shareTestServer(t)
+	if _, err := dialShare(strings.Replace(url, "s3cret", "guess", 1), "eve"); err == nil {
+		t.Error("connected with the wrong token")
+	}
+	t.Setenv("HTTPWIZARD_SHARE_TOKEN", "")
+	if _, err := dialShare(strings.TrimSuffix(url, "?token=s3cret"), "eve"); err == nil {
+		t.Error("connected without a token")
+	}
+	// A page in a browser has the token no more than its own origin.
+	config, _ := websocket.NewConfig(url, "https://evil.example/")
+	config.Header.Set("Authorization", "Bearer s3cret")
+	if _, err := websocket.DialConfig(config); err == nil {
+		t.Error("a browser's websocket got in")
+	}
+}
+
+func TestShareKeepsProtected(t *testing.T) {
+	m := model{cfg: config{envs: map[string]environment{
+		"prod": {Base: "https://api.example.com/", Protected: true},
+		"dev":  {Base: "http://localhost:8080/"},
+	}}}
+	msg := shareMessage{Type: "doc", Doc: &sharedDoc{Name: envDoc, By: "eve", Content: []byte(
+		`{"prod": {"base": "https://evil.example/", "variables": {"v": "2"}}, "dev": {"base": "http://localhost:9090/"}}`)}}
+	envs := m.applyShare(msg).cfg.envs
+	if prod := envs["prod"]; !prod.Protected || prod.Base != "https://api.example.com/" || prod.Variables["v"] != "2" {
+		t.Errorf("prod = %+v", prod)
+	}
+	if envs["dev"].Base != "http://localhost:9090/" {
+		t.Errorf("dev = %+v", envs["dev"])
+	}
+	// Leaving prod out doesn't drop it either.
+	msg.Doc.Content = []byte(`{}`)
+	if prod := m.applyShare(msg).cfg.envs["prod"]; !prod.Protected || prod.Base != "https://api.example.com/" {
+		t.Errorf("prod = %+v", prod)
+	}
+}
+
+func TestSyncFiles(t *testing.T) {
+	url := shareTestServer(t)
+	dir := t.TempDir()
+	mine, theirs := filepath.Join(dir, "mine", "api.json"), filepath.Join(dir, "theirs", "api.json")
+	os.MkdirAll(filepath.Dir(mine), 0o755)
+	os.WriteFile(mine, []byte(`{"name": "v1"}`), 0o644)
+
+	a, _ := dialShare(url, "ann")
+	b, _ := dialShare(url, "bob")
+	stop := make(chan struct{})
+	done := make(chan error, 2)
+	go func() { done <- syncFiles(a, []string{mine}, 10*time.Millisecond, stop) }()
+	go func() { done <- syncFiles(b, []string{theirs}, 10*time.Millisecond, stop) }()
+
+	// Ann's file reaches Bob, and Bob's edit reaches Ann.
+	waitFor(t, func() bool { b, _ := os.ReadFile(theirs); return strings.Contains(string(b), `"v1"`) })
+	time.Sleep(20 * time.Millisecond)
+	os.WriteFile(theirs, []byte(`{"name": "v2"}`), 0o644)
+	waitFor(t, func() bool { b, _ := os.ReadFile(mine); return strings.Contains(string(b), `"v2"`) })
+	close(stop)
+	<-done
+	<-done
+}
+
+// waitFor polls ok for up to two seconds.
+func waitFor(t *testing.T, ok func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); !ok(); {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
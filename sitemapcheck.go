@@ -0,0 +1,321 @@
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// check-sitemap is check-links for sites that say what pages they have:
+// rather than crawl, it checks every page the sitemap lists, following a
+// sitemap index to the sitemaps it names, with a pool of workers and a
+// progress bar, and tabulates the pages that didn't answer 200.
+
+// defaultSitemapWorkers is how many pages check-sitemap checks at once
+// unless told otherwise.
+const defaultSitemapWorkers = 16
+
+// maxSitemapFiles and maxSitemapPages stop a sitemap index from running
+// away: the sitemap protocol allows 50,000 pages to each sitemap file.
+const (
+	maxSitemapFiles = 100
+	maxSitemapPages = 50000
+)
+
+// sitemapBarWidth is how many cells wide the progress bar is.
+const sitemapBarWidth = 40
+
+// defaultSitemapCSV is where e writes the table unless -csv says otherwise.
+const defaultSitemapCSV = "sitemap-check.csv"
+
+// sitemapPagesMsg carries the pages the sitemap lists, each with the
+// sitemap file it was found in as its referrer.
+type sitemapPagesMsg []crawlResult
+
+// sitemapCheckedMsg carries the result of checking one page.
+type sitemapCheckedMsg crawlResult
+
+// sitemapDoneMsg says every page has been checked.
+type sitemapDoneMsg struct{}
+
+// sitemapModel is the Bubble Tea model behind the check-sitemap subcommand.
+type sitemapModel struct {
+	cfg     config
+	workers int
+	csvFile string // Where e writes the table; written on its own when -csv was given.
+	autoCSV bool
+
+	results chan crawlResult
+	total   int           // Pages the sitemap lists, once it is read.
+	checked int           // Pages checked so far.
+	broken  []crawlResult // Pages that didn't answer 200, in the current sort order.
+	done    bool
+	sortBy  int // Index into linkColumns.
+	offset  int
+	note    string // What the last export did.
+	err     error
+}
+
+// runCheckSitemap implements `check-sitemap [-workers N] [-csv file] URL`.
+func runCheckSitemap(args []string) error {
+	fs := flag.NewFlagSet("check-sitemap", flag.ExitOnError)
+	workers := fs.Int("workers", defaultSitemapWorkers, "how many pages to check at once")
+	csvFile := fs.String("csv", "", "write the pages that didn't answer 200 to this CSV `file` when done")
+	fs.Parse(args)
+	if *workers < 1 {
+		return errors.New("-workers has to be at least 1")
+	}
+
+	m := sitemapModel{cfg: config{url: defaultURL}, workers: *workers, csvFile: *csvFile, autoCSV: *csvFile != ""}
+	if fs.NArg() > 0 {
+		m.cfg.url = fs.Arg(0)
+	}
+	if m.csvFile == "" {
+		m.csvFile = defaultSitemapCSV
+	}
+	_, err := tea.NewProgram(m).Run()
+	return err
+}
+
+// Init reads the sitemap.
+func (m sitemapModel) Init() tea.Cmd {
+	return func() tea.Msg {
+		target, err := sitemapURL(m.cfg.url)
+		if err != nil {
+			return errMsg{err}
+		}
+		pages, err := sitemapPages(newClient(m.cfg), target)
+		if err != nil {
+			return errMsg{err}
+		}
+		return sitemapPagesMsg(pages)
+	}
+}
+
+// sitemapPages lists the pages of the sitemap at target, and of every
+// sitemap it names if it is an index, without duplicates.
+func sitemapPages(c *http.Client, target string) ([]crawlResult, error) {
+	var pages []crawlResult
+	seen := map[string]bool{}
+	queue, files := []string{target}, 0
+	for len(queue) > 0 && files < maxSitemapFiles && len(pages) < maxSitemapPages {
+		file := queue[0]
+		queue = queue[1:]
+		files++
+		res, body, err := fetch(c, file)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s answered %d", file, res.StatusCode)
+		}
+		doc, err := parseSitemap(body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if doc.XMLName.Local == "sitemapindex" {
+			for _, s := range doc.Sitemaps {
+				queue = append(queue, strings.TrimSpace(s.Loc))
+			}
+			continue
+		}
+		for _, u := range doc.URLs {
+			loc := strings.TrimSpace(u.Loc)
+			if loc != "" && !seen[loc] && len(pages) < maxSitemapPages {
+				seen[loc] = true
+				pages = append(pages, crawlResult{url: loc, referrer: file})
+			}
+		}
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("%s lists no pages", target)
+	}
+	return pages, nil
+}
+
+// checkPages checks pages with a pool of workers, sending each result to
+// results as it comes and closing it once all are in.
+func checkPages(c *http.Client, pages []crawlResult, workers int, results chan<- crawlResult) {
+	jobs := make(chan crawlResult)
+	var wg sync.WaitGroup
+	for range min(workers, len(pages)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				visit(c, &r, false)
+				results <- r
+			}
+		}()
+	}
+	for _, p := range pages {
+		jobs <- p
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+}
+
+// nextResult waits for the next page to be checked.
+func nextResult(results <-chan crawlResult) tea.Cmd {
+	return func() tea.Msg {
+		r, ok := <-results
+		if !ok {
+			return sitemapDoneMsg{}
+		}
+		return sitemapCheckedMsg(r)
+	}
+}
+
+// Update starts the checks once the sitemap is read, tallies their
+// results, and handles the table's keys.
+func (m sitemapModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case sitemapPagesMsg:
+		m.total = len(msg)
+		m.results = make(chan crawlResult, m.workers)
+		go checkPages(newClient(m.cfg), msg, m.workers, m.results)
+		return m, nextResult(m.results)
+
+	case sitemapCheckedMsg:
+		m.checked++
+		if r := crawlResult(msg); r.err != nil || r.status != http.StatusOK {
+			m.broken = append(m.broken, r)
+		}
+		return m, nextResult(m.results)
+
+	case sitemapDoneMsg:
+		m.done = true
+		m.sort()
+		if m.autoCSV {
+			m = m.export()
+		}
+
+	case errMsg:
+		m.err = msg.err
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "s":
+			m.sortBy = (m.sortBy + 1) % len(linkColumns)
+			m.sort()
+		case "e":
+			if m.done {
+				m = m.export()
+			}
+		case "up", "k":
+			m.offset = max(m.offset-1, 0)
+		case "down", "j":
+			m.offset = min(m.offset+1, max(len(m.broken)-listHeight, 0))
+		}
+	}
+	return m, nil
+}
+
+// sort orders the broken pages by the selected column, breaking ties by
+// URL. The referrer of a page is the sitemap that lists it.
+func (m *sitemapModel) sort() {
+	slices.SortStableFunc(m.broken, func(a, b crawlResult) int {
+		var c int
+		switch linkColumns[m.sortBy] {
+		case "status":
+			c = cmp.Compare(a.status, b.status)
+		case "referrer":
+			c = cmp.Compare(a.referrer, b.referrer)
+		}
+		return cmp.Or(c, cmp.Compare(a.url, b.url))
+	})
+}
+
+// export writes the table to the CSV file, and notes how that went.
+func (m sitemapModel) export() sitemapModel {
+	f, err := os.Create(m.csvFile)
+	if err == nil {
+		err = writeSitemapCSV(f, m.broken)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		m.note = "Couldn't write " + m.csvFile + ": " + err.Error()
+	} else {
+		m.note = fmt.Sprintf("Wrote %d rows to %s", len(m.broken), m.csvFile)
+	}
+	return m
+}
+
+// writeSitemapCSV writes broken as CSV: status, URL, the sitemap listing
+// it, and the error for pages that got no answer.
+func writeSitemapCSV(w io.Writer, broken []crawlResult) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"status", "url", "sitemap", "error"})
+	for _, r := range broken {
+		status, problem := strconv.Itoa(r.status), ""
+		if r.err != nil {
+			status, problem = "", r.err.Error()
+		}
+		cw.Write([]string{status, r.url, r.referrer, problem})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// progressBar draws done of total as a bar, e.g. "████░░░░ 50%".
+func progressBar(done, total, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = done * width / total
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + fmt.Sprintf(" %3d%%", done*100/max(total, 1))
+}
+
+// View renders the progress of the checks, then the table of pages that
+// didn't answer 200.
+func (m sitemapModel) View() string {
+	if m.err != nil {
+		return fmt.Sprintf("\nWe had some trouble: %v\n\n", m.err)
+	}
+	if m.total == 0 {
+		return fmt.Sprintf("\nReading the sitemap of %s ...\n\n", m.cfg.url)
+	}
+	var b strings.Builder
+	if !m.done {
+		fmt.Fprintf(&b, "\nChecking %d pages from the sitemap of %s, %d at a time\n\n", m.total, m.cfg.url, m.workers)
+		fmt.Fprintf(&b, "%s  %d of %d, %d not 200\n\n", progressBar(m.checked, m.total, sitemapBarWidth), m.checked, m.total, len(m.broken))
+		b.WriteString("q quit\n")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "\nChecked %d pages from the sitemap of %s: %d didn't answer 200, sorted by %s\n\n",
+		m.total, m.cfg.url, len(m.broken), linkColumns[m.sortBy])
+	if len(m.broken) == 0 {
+		b.WriteString("Every page answered 200.\n")
+	} else {
+		fmt.Fprintf(&b, "%-6s %-50s %s\n", "STATUS", "URL", "SITEMAP")
+	}
+	for _, r := range m.broken[m.offset:min(m.offset+listHeight, len(m.broken))] {
+		status := fmt.Sprint(r.status)
+		if r.err != nil {
+			status = "ERR"
+		}
+		fmt.Fprintf(&b, "%-6s %-50s %s\n", status, r.url, r.referrer)
+	}
+	if m.note != "" {
+		b.WriteString("\n" + m.note + "\n")
+	}
+	b.WriteString("\n↑/↓ scroll • s sort • e export CSV • q quit\n")
+	return b.String()
+}
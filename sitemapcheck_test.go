@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestSitemapCheck(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			w.Write([]byte(`<sitemapindex><sitemap><loc>` + srv.URL + `/a.xml</loc></sitemap><sitemap><loc>` + srv.URL + `/b.xml</loc></sitemap></sitemapindex>`))
+		case "/a.xml":
+			w.Write([]byte(`<urlset><url><loc>` + srv.URL + `/</loc></url><url><loc>` + srv.URL + `/gone</loc></url></urlset>`))
+		case "/b.xml":
+			w.Write([]byte(`<urlset><url><loc>` + srv.URL + `/</loc></url><url><loc>` + srv.URL + `/moved</loc></url></urlset>`))
+		case "/":
+			w.Write([]byte("home"))
+		case "/moved":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	pages, err := sitemapPages(srv.Client(), srv.URL+"/sitemap.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 3 || pages[2].referrer != srv.URL+"/b.xml" {
+		t.Fatalf("pages = %+v", pages)
+	}
+
+	m := sitemapModel{cfg: config{url: srv.URL}, workers: 2, csvFile: filepath.Join(t.TempDir(), "out.csv"), autoCSV: true}
+	next, cmd := m.Update(sitemapPagesMsg(pages))
+	m = next.(sitemapModel)
+	if view := m.View(); !strings.Contains(view, "░") || !strings.Contains(view, "0 of 3") {
+		t.Errorf("progress view =\n%s", view)
+	}
+	for cmd != nil {
+		next, cmd = m.Update(cmd())
+		m = next.(sitemapModel)
+	}
+	if !m.done || m.checked != 3 || len(m.broken) != 2 || m.broken[0].status != 204 {
+		t.Fatalf("model = %+v", m)
+	}
+	view := m.View()
+	if !strings.Contains(view, "Checked 3 pages") || !strings.Contains(view, "2 didn't answer 200") || !strings.Contains(view, "Wrote 2 rows") {
+		t.Errorf("view =\n%s", view)
+	}
+	out, err := os.ReadFile(m.csvFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "status,url,sitemap,error\n204," + srv.URL + "/moved," + srv.URL + "/b.xml,\n404," + srv.URL + "/gone," + srv.URL + "/a.xml,\n"
+	if string(out) != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", out, want)
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	if got := next.(sitemapModel).broken[0].url; got != srv.URL+"/gone" {
+		t.Errorf("by url, first = %s", got)
+	}
+}
+
+func TestWriteSitemapCSV(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeSitemapCSV(&b, []crawlResult{{url: "https://a.test/x", referrer: "https://a.test/s.xml", err: errors.New("refused, badly")}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != "status,url,sitemap,error\n,https://a.test/x,https://a.test/s.xml,\"refused, badly\"\n" {
+		t.Errorf("CSV = %q", got)
+	}
+	if got := progressBar(1, 4, 8); got != "██░░░░░░  25%" {
+		t.Errorf("bar = %q", got)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sloWindow is how many of a request's latest runs its SLO is judged on.
+const sloWindow = 100
+
+// sparkWidth is how many runs a sparkline shows, the latest ones.
+const sparkWidth = 60
+
+// sparkBars are the levels of a sparkline, lowest first.
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// sloTarget is the service level objective of a request: the share of its
+// runs that should be good, where good means answered without a server
+// error, and within latency if one is set.
+type sloTarget struct {
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+	Percent   float64 `json:"percent"`
+}
+
+// latency is the target's latency as a duration, 0 for none.
+func (t sloTarget) latency() time.Duration {
+	return time.Duration(t.LatencyMs * float64(time.Millisecond))
+}
+
+// good reports whether a run meets the target.
+func (t sloTarget) good(e historyEntry) bool {
+	return e.ok() && (t.LatencyMs == 0 || e.elapsed() <= t.latency())
+}
+
+// defaultSLOFile is where SLOs are kept unless -slo-file says otherwise, or
+// "" for nowhere when there is no config directory.
+func defaultSLOFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "httpwizard", "slos.json")
+}
+
+// loadSLOs reads the SLO file, a JSON object mapping requests, as
+// historyKey names them, to their targets:
+//
+//	{"GET https://api.example.com/health": {"latency_ms": 300, "percent": 99.5}}
+//
+// A missing file means no request has an SLO yet.
+func loadSLOs(path string) (map[string]sloTarget, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]sloTarget{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	slos := map[string]sloTarget{}
+	if err := json.Unmarshal(b, &slos); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return slos, nil
+}
+
+// saveSLOs writes slos to the SLO file.
+func saveSLOs(path string, slos map[string]sloTarget) error {
+	b, err := json.MarshalIndent(slos, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// parseSLO reads the palette's `slo [latency] percent` arguments, e.g.
+// "300ms 99.5", or just "99.9" for availability alone.
+func parseSLO(args []string) (sloTarget, error) {
+	var t sloTarget
+	if len(args) == 2 {
+		d, err := time.ParseDuration(args[0])
+		if err != nil || d <= 0 {
+			return t, fmt.Errorf("%q is not a latency such as 300ms", args[0])
+		}
+		t.LatencyMs = float64(d) / float64(time.Millisecond)
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return t, errors.New("usage: slo [latency] percent, e.g. slo 300ms 99.5")
+	}
+	p, err := strconv.ParseFloat(strings.TrimSuffix(args[0], "%"), 64)
+	if err != nil || p <= 0 || p > 100 {
+		return t, fmt.Errorf("%q is not a percentage above 0 and up to 100", args[0])
+	}
+	t.Percent = p
+	return t, nil
+}
+
+// runSLO is the palette's `slo [[latency] percent]`: with arguments it sets
+// the current request's SLO, and either way it reports how the request's
+// history measures up.
+func runSLO(m model, args []string) (tea.Model, tea.Cmd) {
+	key := historyKey(m.cfg.method, m.cfg.url)
+	slos, err := loadSLOs(m.cfg.sloFile)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	if len(args) > 0 && m.cfg.readOnly {
+		return m.paletteError(errReadOnly)
+	}
+	if len(args) > 0 && m.cfg.sloFile == "" {
+		return m.paletteError(errors.New("there is no -slo-file to keep the SLO in"))
+	}
+	if len(args) > 0 {
+		target, err := parseSLO(args)
+		if err != nil {
+			return m.paletteError(err)
+		}
+		slos[key] = target
+		if err := saveSLOs(m.cfg.sloFile, slos); err != nil {
+			return m.paletteError(err)
+		}
+	}
+	target, ok := slos[key]
+	if !ok {
+		return m.paletteError(fmt.Errorf("%s has no SLO yet; set one with slo [latency] percent, e.g. slo 300ms 99.5", key))
+	}
+	if m.cfg.historyFile == "" {
+		return m.paletteError(errors.New("the history is switched off, so there is nothing to judge the SLO on"))
+	}
+	runs, err := historyOf(m.cfg.historyFile, key)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	m.report, m.cursor = &reportMsg{title: "SLO " + key, body: sloReport(runs, target)}, 0
+	return m, nil
+}
+
+// sloReport judges the latest sloWindow runs against target: the share of
+// good runs, the error budget that leaves, and sparklines of the latency
+// and of which runs were good.
+func sloReport(runs []historyEntry, target sloTarget) string {
+	goal := fmt.Sprintf("%g%% of runs answered", target.Percent)
+	if target.LatencyMs > 0 {
+		goal += " within " + target.latency().String()
+	}
+	if len(runs) == 0 {
+		return "Target: " + goal + "\nNo runs of this request in the history yet."
+	}
+	runs = runs[max(len(runs)-sloWindow, 0):]
+
+	good, available := 0, 0
+	for _, r := range runs {
+		if target.good(r) {
+			good++
+		}
+		if r.ok() {
+			available++
+		}
+	}
+	share := 100 * float64(good) / float64(len(runs))
+	verdict := "✓ met"
+	if share < target.Percent {
+		verdict = "✗ missed"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Target:       %s\n", goal)
+	fmt.Fprintf(&b, "Compliance:   %.1f%% of the last %d runs, %s\n", share, len(runs), verdict)
+	fmt.Fprintf(&b, "Availability: %.1f%%\n", 100*float64(available)/float64(len(runs)))
+	allowed := int(math.Floor(float64(len(runs)) * (100 - target.Percent) / 100))
+	fmt.Fprintf(&b, "Error budget: %d of %d bad runs allowed used", len(runs)-good, allowed)
+	if len(runs)-good > allowed {
+		b.WriteString(", exhausted")
+	}
+
+	shown := runs[max(len(runs)-sparkWidth, 0):]
+	latencies := make([]float64, len(shown))
+	marks := make([]rune, len(shown))
+	for i, r := range shown {
+		latencies[i] = r.Millis
+		marks[i] = '✓'
+		if !target.good(r) {
+			marks[i] = '✗'
+		}
+	}
+	fmt.Fprintf(&b, "\n\nLatency  %s\nGood     %s", sparkline(latencies), string(marks))
+	fmt.Fprintf(&b, "\n         %s to %s", shown[0].Time.Local().Format(time.DateTime), shown[len(shown)-1].Time.Local().Format(time.DateTime))
+	return b.String()
+}
+
+// sparkline draws values as a row of bars, scaled from the smallest to the
+// largest of them.
+func sparkline(values []float64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	bars := make([]rune, len(values))
+	for i, v := range values {
+		level := 0
+		if hi > lo {
+			level = int((v - lo) / (hi - lo) * float64(len(sparkBars)-1))
+		}
+		bars[i] = sparkBars[level]
+	}
+	return string(bars)
+}
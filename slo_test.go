@@ -0,0 +1,86 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSLO(t *testing.T) {
+	got, err := parseSLO([]string{"300ms", "99.5%"})
+	if err != nil || got != (sloTarget{LatencyMs: 300, Percent: 99.5}) {
+		t.Errorf("parseSLO(300ms 99.5%%) = %+v, %v", got, err)
+	}
+	if got, err := parseSLO([]string{"99.9"}); err != nil || got != (sloTarget{Percent: 99.9}) {
+		t.Errorf("parseSLO(99.9) = %+v, %v", got, err)
+	}
+	for _, args := range [][]string{{"fast", "99"}, {"300ms", "101"}, {"0"}, {"1s", "2s", "3"}} {
+		if _, err := parseSLO(args); err == nil {
+			t.Errorf("parseSLO(%q) took it", args)
+		}
+	}
+}
+
+func TestSLOFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "slos.json")
+	slos, err := loadSLOs(path)
+	if err != nil || len(slos) != 0 {
+		t.Fatalf("missing file = %v, %v", slos, err)
+	}
+	slos["GET http://x"] = sloTarget{LatencyMs: 250, Percent: 99}
+	if err := saveSLOs(path, slos); err != nil {
+		t.Fatal(err)
+	}
+	back, err := loadSLOs(path)
+	if err != nil || back["GET http://x"] != slos["GET http://x"] {
+		t.Errorf("loaded %v, %v", back, err)
+	}
+}
+
+func TestSLOReport(t *testing.T) {
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	var runs []historyEntry
+	for i := range 10 {
+		e := historyEntry{Time: start.Add(time.Duration(i) * time.Minute), Status: 200, Millis: 100}
+		switch i {
+		case 3:
+			e.Millis = 900 // Too slow.
+		case 7:
+			e.Status = 503
+		}
+		runs = append(runs, e)
+	}
+
+	body := sloReport(runs, sloTarget{LatencyMs: 300, Percent: 90})
+	for _, want := range []string{
+		"90% of runs answered within 300ms",
+		"Compliance:   80.0% of the last 10 runs, ✗ missed",
+		"Availability: 90.0%",
+		"Error budget: 2 of 1 bad runs allowed used, exhausted",
+		"Good     ✓✓✓✗✓✓✓✗✓✓",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("report lacks %q:\n%s", want, body)
+		}
+	}
+
+	if body := sloReport(runs, sloTarget{Percent: 80}); !strings.Contains(body, "90.0% of the last 10 runs, ✓ met") {
+		t.Errorf("availability-only report:\n%s", body)
+	}
+	if body := sloReport(nil, sloTarget{Percent: 99}); !strings.Contains(body, "No runs") {
+		t.Errorf("empty report:\n%s", body)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]float64{1, 5, 8}); got != "▁▅█" {
+		t.Errorf("sparkline = %q", got)
+	}
+	if got := sparkline([]float64{3, 3}); got != "▁▁" {
+		t.Errorf("flat sparkline = %q", got)
+	}
+	if sparkline(nil) != "" {
+		t.Error("empty sparkline isn't empty")
+	}
+}
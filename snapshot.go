@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxSnapshotChanges is how many of the ways a response differs from its
+// snapshot a failure lists.
+const maxSnapshotChanges = 5
+
+// ignoredValue stands in for the value of an ignored field in a snapshot.
+const ignoredValue = "(ignored)"
+
+// snapshot is the answer a request got when its snapshot was taken. A JSON
+// body is kept as JSON, and compared field by field; any other as a string.
+type snapshot struct {
+	Status int `json:"status"`
+	Body   any `json:"body"`
+}
+
+// snapshots are the snapshots of a collection's requests, kept in one file,
+// which a run with -snapshots compares each answer with. A request without
+// one has its answer taken as its snapshot; with -update-snapshots, every
+// request has.
+//
+// Fields that change from one answer to the next, such as ids and
+// timestamps, are left out of the comparison with snapshot_ignore, in the
+// collection for every request or in a request for its own:
+//
+//	"snapshot_ignore": ["id", "$.meta.generated_at", "$.items[*].created"]
+//
+// A bare name ignores that field wherever it is; a path starting with $,
+// written the way the JSON tree shows it, ignores the field there, with [*]
+// for any element of an array and .* for any member of an object.
+type snapshots struct {
+	mu      sync.Mutex
+	path    string
+	update  bool
+	ignore  []string
+	saved   map[string]snapshot
+	taken   int // How many snapshots this run took.
+	changed bool
+}
+
+// loadSnapshots reads the snapshot file at path, if there is one yet.
+// ignore is the collection's snapshot_ignore.
+func loadSnapshots(path string, update bool, ignore []string) (*snapshots, error) {
+	s := &snapshots{path: path, update: update, ignore: ignore, saved: map[string]snapshot{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&s.saved); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// snapshotKey is what the snapshot of a step is kept under: the request's
+// id or, failing that, its folder and name.
+func snapshotKey(step runStep) string {
+	if step.req.ID != "" {
+		return step.req.ID
+	}
+	if step.folder != "" {
+		return step.folder + "/" + step.req.label()
+	}
+	return step.req.label()
+}
+
+// check compares the answer r got with the step's snapshot, and fails r if
+// they differ. Hooks, requests that failed already and requests that got
+// no answer are left alone. On a nil *snapshots it does nothing, so that
+// callers needn't check whether snapshots were asked for.
+func (s *snapshots) check(step runStep, r *runResult) {
+	if s == nil || step.hook != "" || !r.passed() || r.status == 0 {
+		return
+	}
+	got := takeSnapshot(r.status, r.body, append(slices.Clone(s.ignore), step.req.SnapshotIgnore...))
+	key := snapshotKey(step)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	want, ok := s.saved[key]
+	if ok && !s.update {
+		// What was saved is compared as it would be taken now, so that a
+		// field ignored since is ignored in it too.
+		want.Body = blankIgnored(want.Body, "$", "", append(slices.Clone(s.ignore), step.req.SnapshotIgnore...))
+		if changes := diffSnapshots(want, got); len(changes) > 0 {
+			if len(changes) > maxSnapshotChanges {
+				changes = append(changes[:maxSnapshotChanges], fmt.Sprintf("and %d more", len(changes)-maxSnapshotChanges))
+			}
+			r.failure = "differs from its snapshot: " + strings.Join(changes, "; ")
+		}
+		return
+	}
+	s.saved[key] = got
+	s.taken++
+	s.changed = true
+}
+
+// save writes the snapshots back, if the run took any.
+func (s *snapshots) save() error {
+	if s == nil || !s.changed {
+		return nil
+	}
+	b, err := json.MarshalIndent(s.saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, append(b, '\n'))
+}
+
+// takeSnapshot is the snapshot of an answer, with the fields ignore names
+// blanked out.
+func takeSnapshot(status int, body []byte, ignore []string) snapshot {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.Decode(new(any)) != io.EOF {
+		return snapshot{Status: status, Body: string(body)}
+	}
+	return snapshot{Status: status, Body: blankIgnored(v, "$", "", ignore)}
+}
+
+// blankIgnored replaces the fields of v that ignore names with ignoredValue.
+// path is where v sits in the document, key what its parent calls it.
+func blankIgnored(v any, path, key string, ignore []string) any {
+	if path != "$" && ignores(ignore, path, key) {
+		return ignoredValue
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, child := range v {
+			out[k] = blankIgnored(child, childPath(path, k), k, ignore)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, child := range v {
+			out[i] = blankIgnored(child, path+"["+strconv.Itoa(i)+"]", "", ignore)
+		}
+		return out
+	}
+	return v
+}
+
+// ignores reports whether one of patterns names the field at path, which
+// its object calls key ("" for an array element).
+func ignores(patterns []string, path, key string) bool {
+	for _, p := range patterns {
+		if !strings.HasPrefix(p, "$") {
+			if key != "" && key == p {
+				return true
+			}
+			continue
+		}
+		if ignoreRe(p).MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreRes caches the compiled snapshot_ignore paths.
+var ignoreRes sync.Map
+
+// ignoreRe compiles a snapshot_ignore path, with its wildcards, into a
+// regular expression.
+func ignoreRe(pattern string) *regexp.Regexp {
+	if re, ok := ignoreRes.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\[\*\]`, `\[\d+\]`)
+	expr = strings.ReplaceAll(expr, `\.\*`, `(\.[^.\[]+|\["(?:[^"\\]|\\.)*"\])`)
+	re := regexp.MustCompile("^" + expr + "$")
+	ignoreRes.Store(pattern, re)
+	return re
+}
+
+// diffSnapshots lists the ways got differs from want, e.g.
+// `status 200 → 500` or `$.name: "Rex" → "Max"`.
+func diffSnapshots(want, got snapshot) []string {
+	var changes []string
+	if want.Status != got.Status {
+		changes = append(changes, fmt.Sprintf("status %d → %d", want.Status, got.Status))
+	}
+	return diffJSON(changes, "$", want.Body, got.Body)
+}
+
+// diffJSON adds to changes the ways b differs from a, both found at path.
+// Object members are compared in the order of their keys.
+func diffJSON(changes []string, path string, a, b any) []string {
+	switch a := a.(type) {
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			for _, k := range slices.Sorted(maps.Keys(a)) {
+				if _, ok := b[k]; !ok {
+					changes = append(changes, childPath(path, k)+" removed")
+					continue
+				}
+				changes = diffJSON(changes, childPath(path, k), a[k], b[k])
+			}
+			for _, k := range slices.Sorted(maps.Keys(b)) {
+				if _, ok := a[k]; !ok {
+					changes = append(changes, childPath(path, k)+" added: "+shortJSON(b[k]))
+				}
+			}
+			return changes
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			for i := range max(len(a), len(b)) {
+				p := path + "[" + strconv.Itoa(i) + "]"
+				switch {
+				case i >= len(b):
+					changes = append(changes, p+" removed")
+				case i >= len(a):
+					changes = append(changes, p+" added: "+shortJSON(b[i]))
+				default:
+					changes = diffJSON(changes, p, a[i], b[i])
+				}
+			}
+			return changes
+		}
+	}
+	if shortJSON(a) != shortJSON(b) {
+		changes = append(changes, fmt.Sprintf("%s: %s → %s", path, shortJSON(a), shortJSON(b)))
+	}
+	return changes
+}
+
+// shortJSON writes v as compact JSON, cut short if it is long.
+func shortJSON(v any) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	s := strings.TrimSuffix(b.String(), "\n")
+	if r := []rune(s); len(r) > 40 {
+		s = string(r[:39]) + "…"
+	}
+	return s
+}
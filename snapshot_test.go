@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTakeSnapshot(t *testing.T) {
+	body := `{"id": 7, "name": "Rex", "meta": {"at": "now"}, "tags": [{"id": 1, "created": "x"}], "odd key": {"created": 1}}`
+	got := takeSnapshot(200, []byte(body), []string{"id", "$.tags[*].created", `$["odd key"].*`})
+	pet := got.Body.(map[string]any)
+	if pet["id"] != ignoredValue || pet["name"] != "Rex" || pet["meta"].(map[string]any)["at"] != "now" {
+		t.Errorf("pet = %v", pet)
+	}
+	if tag := pet["tags"].([]any)[0].(map[string]any); tag["id"] != ignoredValue || tag["created"] != ignoredValue {
+		t.Errorf("tag = %v", tag)
+	}
+	if odd := pet["odd key"].(map[string]any); odd["created"] != ignoredValue {
+		t.Errorf("odd key = %v", odd)
+	}
+	if got := takeSnapshot(200, []byte("plain text"), nil); got.Body != "plain text" {
+		t.Errorf("a body that isn't JSON is kept as a string: %v", got.Body)
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	want := takeSnapshot(200, []byte(`{"name": "Rex", "age": 3, "tags": ["a", "b"], "gone": true}`), nil)
+	got := takeSnapshot(201, []byte(`{"name": "Max", "age": "3", "tags": ["a"], "new": {"x": 1}}`), nil)
+	changes := diffSnapshots(want, got)
+	wantChanges := []string{
+		"status 200 → 201",
+		`$.age: 3 → "3"`,
+		"$.gone removed",
+		`$.name: "Rex" → "Max"`,
+		"$.tags[1] removed",
+		`$.new added: {"x":1}`,
+	}
+	if !slices.Equal(changes, wantChanges) {
+		t.Errorf("changes = %q, want %q", changes, wantChanges)
+	}
+	if changes := diffSnapshots(want, want); len(changes) != 0 {
+		t.Errorf("a snapshot differs from itself: %q", changes)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	name, id := "Rex", "1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": ` + id + `, "name": "` + name + `"}`))
+	}))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "pets.snapshots.json")
+	c := &collection{
+		Requests:       []collectionRequest{{Name: "Get Rex", URL: srv.URL}},
+		Folders:        []folder{{Name: "f", Requests: []collectionRequest{{Name: "Get Rex", URL: srv.URL}}}},
+		SnapshotIgnore: []string{"id"},
+	}
+	steps, err := planRun(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(update bool) []runResult {
+		s, err := loadSnapshots(path, update, c.SnapshotIgnore)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := runSteps(config{bodyFormat: "json"}, steps, gates{snapshots: s}, 1, func(runEvent) {})
+		if err := s.save(); err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	// The first run takes the snapshots, under the folder and name.
+	if results := run(false); results[0].failure != "" || results[1].failure != "" {
+		t.Fatalf("results = %+v", results)
+	}
+	if b, _ := os.ReadFile(path); !strings.Contains(string(b), `"f/Get Rex"`) || !strings.Contains(string(b), `"(ignored)"`) {
+		t.Errorf("snapshot file:\n%s", b)
+	}
+
+	// An ignored field may change; others may not.
+	id = "2"
+	if results := run(false); results[0].failure != "" {
+		t.Errorf("an ignored field failed the run: %+v", results[0])
+	}
+	name = "Max"
+	if results := run(false); results[0].failure != `differs from its snapshot: $.name: "Rex" → "Max"` {
+		t.Errorf("failure = %q", results[0].failure)
+	}
+	run(true)
+	if results := run(false); results[0].failure != "" {
+		t.Errorf("-update-snapshots should take the new answer: %+v", results[0])
+	}
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// The envelope namespaces of the two SOAP versions.
+const (
+	soap11Envelope = "http://schemas.xmlsoap.org/soap/envelope/"
+	soap12Envelope = "http://www.w3.org/2003/05/soap-envelope"
+)
+
+// The WSDL namespaces of SOAP 1.1 and 1.2 bindings.
+const (
+	soap11Binding = "http://schemas.xmlsoap.org/wsdl/soap/"
+	soap12Binding = "http://schemas.xmlsoap.org/wsdl/soap12/"
+)
+
+// isEnvelope reports whether body is a complete SOAP envelope already,
+// rather than just what goes inside its Body.
+func isEnvelope(body []byte) bool {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local == "Envelope"
+		}
+	}
+}
+
+// envelope wraps body, and header if there is one, in a SOAP envelope of
+// the given version.
+func envelope(version string, header, body []byte) []byte {
+	ns := soap11Envelope
+	if version == "1.2" {
+		ns = soap12Envelope
+	}
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, "<soap:Envelope xmlns:soap=%q>\n", ns)
+	if len(bytes.TrimSpace(header)) > 0 {
+		fmt.Fprintf(&b, "  <soap:Header>\n    %s\n  </soap:Header>\n", bytes.TrimSpace(header))
+	}
+	fmt.Fprintf(&b, "  <soap:Body>\n    %s\n  </soap:Body>\n", bytes.TrimSpace(body))
+	b.WriteString("</soap:Envelope>\n")
+	return b.Bytes()
+}
+
+// wrapSOAP returns cfg with its body in an envelope, unless it is one
+// already, and the headers SOAP wants: for 1.1 a SOAPAction header, for
+// 1.2 the action as a parameter of the Content-Type. Headers given with
+// -H are left alone.
+func wrapSOAP(cfg config) config {
+	if !isEnvelope(cfg.body) {
+		cfg.body = envelope(cfg.soapVersion, cfg.soapHeader, cfg.body)
+	}
+	cfg.header = cfg.header.Clone()
+	if cfg.soapVersion == "1.2" {
+		if cfg.header.Get("Content-Type") == "" {
+			ct := "application/soap+xml; charset=utf-8"
+			if cfg.soapAction != "" {
+				ct += fmt.Sprintf("; action=%q", cfg.soapAction)
+			}
+			cfg.header.Set("Content-Type", ct)
+		}
+		return cfg
+	}
+	if cfg.header.Get("Content-Type") == "" {
+		cfg.header.Set("Content-Type", "text/xml; charset=utf-8")
+	}
+	// SOAP 1.1 wants the header even when there is no action, as "".
+	if cfg.header.Values("SOAPAction") == nil {
+		cfg.header.Set("SOAPAction", fmt.Sprintf("%q", cfg.soapAction))
+	}
+	return cfg
+}
+
+// soapFault returns the code and reason of the fault in a SOAP response
+// body, or "" if it isn't one.
+func soapFault(body []byte) string {
+	var env struct {
+		Body struct {
+			Fault *struct {
+				// SOAP 1.1.
+				Code   string `xml:"faultcode"`
+				String string `xml:"faultstring"`
+				// SOAP 1.2.
+				Code12   string `xml:"Code>Value"`
+				Reason12 string `xml:"Reason>Text"`
+			} `xml:"Fault"`
+		} `xml:"Body"`
+	}
+	if !isEnvelope(body) || xml.Unmarshal(body, &env) != nil || env.Body.Fault == nil {
+		return ""
+	}
+	f := env.Body.Fault
+	code, reason := cmp.Or(f.Code, f.Code12), cmp.Or(f.String, f.Reason12)
+	return strings.TrimSpace(code + ": " + reason)
+}
+
+// The parts of a WSDL 1.1 document the operation skeletons are made from.
+// Names that refer to other parts are QNames, "prefix:name"; they are
+// looked up by their local name, which is unambiguous in the WSDLs one
+// meets in practice. Imported WSDLs and schemas are not followed.
+type (
+	wsdlDefinitions struct {
+		TargetNamespace string         `xml:"targetNamespace,attr"`
+		Schemas         []xsdSchema    `xml:"types>schema"`
+		Messages        []wsdlMessage  `xml:"message"`
+		PortTypes       []wsdlPortType `xml:"portType"`
+		Bindings        []wsdlBinding  `xml:"binding"`
+		Services        []wsdlService  `xml:"service"`
+	}
+	xsdSchema struct {
+		TargetNamespace    string           `xml:"targetNamespace,attr"`
+		ElementFormDefault string           `xml:"elementFormDefault,attr"`
+		Elements           []xsdElement     `xml:"element"`
+		ComplexTypes       []xsdComplexType `xml:"complexType"`
+	}
+	xsdElement struct {
+		Name        string          `xml:"name,attr"`
+		Type        string          `xml:"type,attr"`
+		Ref         string          `xml:"ref,attr"`
+		MaxOccurs   string          `xml:"maxOccurs,attr"`
+		ComplexType *xsdComplexType `xml:"complexType"`
+	}
+	xsdComplexType struct {
+		Name     string       `xml:"name,attr"`
+		Sequence []xsdElement `xml:"sequence>element"`
+		All      []xsdElement `xml:"all>element"`
+		Choice   []xsdElement `xml:"choice>element"`
+		Extends  *struct {
+			Base     string       `xml:"base,attr"`
+			Sequence []xsdElement `xml:"sequence>element"`
+		} `xml:"complexContent>extension"`
+	}
+	wsdlMessage struct {
+		Name  string `xml:"name,attr"`
+		Parts []struct {
+			Name    string `xml:"name,attr"`
+			Element string `xml:"element,attr"`
+			Type    string `xml:"type,attr"`
+		} `xml:"part"`
+	}
+	wsdlPortType struct {
+		Name       string `xml:"name,attr"`
+		Operations []struct {
+			Name  string `xml:"name,attr"`
+			Doc   string `xml:"documentation"`
+			Input struct {
+				Message string `xml:"message,attr"`
+			} `xml:"input"`
+		} `xml:"operation"`
+	}
+	wsdlBinding struct {
+		Name string `xml:"name,attr"`
+		Type string `xml:"type,attr"`
+		SOAP struct {
+			XMLName xml.Name
+			Style   string `xml:"style,attr"`
+		} `xml:"binding"`
+		Operations []struct {
+			Name string `xml:"name,attr"`
+			SOAP struct {
+				Action string `xml:"soapAction,attr"`
+				Style  string `xml:"style,attr"`
+			} `xml:"operation"`
+		} `xml:"operation"`
+	}
+	wsdlService struct {
+		Ports []struct {
+			Binding   string `xml:"binding,attr"`
+			Addresses []struct {
+				Location string `xml:"location,attr"`
+			} `xml:"address"`
+		} `xml:"port"`
+	}
+)
+
+// soapOperation is what it takes to call one operation of a web service.
+type soapOperation struct {
+	name     string
+	version  string // "1.1" or "1.2".
+	action   string // SOAPAction, may be empty.
+	endpoint string
+	doc      string
+	body     string // A skeleton of what goes in the envelope's Body.
+}
+
+// localName returns the name part of a QName.
+func localName(qname string) string {
+	if i := strings.LastIndex(qname, ":"); i >= 0 {
+		return qname[i+1:]
+	}
+	return qname
+}
+
+// loadWSDL reads a WSDL from a URL or a file and lists its operations.
+func loadWSDL(source string) ([]soapOperation, error) {
+	var doc []byte
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		res, body, err := fetch(newClient(config{}), source)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode >= 400 {
+			return nil, fmt.Errorf("fetching %s: %s", source, res.Status)
+		}
+		doc = body
+	} else {
+		var err error
+		if doc, err = os.ReadFile(source); err != nil {
+			return nil, err
+		}
+	}
+
+	var defs wsdlDefinitions
+	if err := xml.Unmarshal(doc, &defs); err != nil {
+		return nil, fmt.Errorf("reading the WSDL: %w", err)
+	}
+	ops := defs.operations()
+	if len(ops) == 0 {
+		return nil, errors.New("the WSDL has no SOAP operations")
+	}
+	return ops, nil
+}
+
+// operations lists every operation of every SOAP port in defs.
+func (defs wsdlDefinitions) operations() []soapOperation {
+	var ops []soapOperation
+	for _, service := range defs.Services {
+		for _, port := range service.Ports {
+			binding, ok := defs.binding(localName(port.Binding))
+			var version string
+			switch binding.SOAP.XMLName.Space {
+			case soap11Binding:
+				version = "1.1"
+			case soap12Binding:
+				version = "1.2"
+			}
+			if !ok || version == "" {
+				continue // An HTTP binding, say.
+			}
+			endpoint := ""
+			if len(port.Addresses) > 0 {
+				endpoint = port.Addresses[0].Location
+			}
+			for _, bop := range binding.Operations {
+				op := soapOperation{name: bop.Name, version: version, action: bop.SOAP.Action, endpoint: endpoint}
+				rpc := cmp.Or(bop.SOAP.Style, binding.SOAP.Style) == "rpc"
+				op.doc, op.body = defs.skeleton(localName(binding.Type), bop.Name, rpc)
+				ops = append(ops, op)
+			}
+		}
+	}
+	return ops
+}
+
+// binding returns the binding called name.
+func (defs wsdlDefinitions) binding(name string) (wsdlBinding, bool) {
+	for _, b := range defs.Bindings {
+		if b.Name == name {
+			return b, true
+		}
+	}
+	return wsdlBinding{}, false
+}
+
+// skeleton returns the documentation of an operation of portType and a
+// skeleton of its input, with a placeholder for every value. A document
+// style operation sends the elements of its message parts; an rpc style
+// one wraps its parts in an element named after the operation.
+func (defs wsdlDefinitions) skeleton(portType, operation string, rpc bool) (doc, body string) {
+	var message string
+	for _, pt := range defs.PortTypes {
+		if pt.Name != portType {
+			continue
+		}
+		for _, op := range pt.Operations {
+			if op.Name == operation {
+				doc, message = strings.TrimSpace(op.Doc), localName(op.Input.Message)
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, m := range defs.Messages {
+		if m.Name != message {
+			continue
+		}
+		if rpc {
+			fmt.Fprintf(&b, "<ns:%s xmlns:ns=%q>\n", operation, defs.TargetNamespace)
+			for _, part := range m.Parts {
+				fmt.Fprintf(&b, "  <%s>%s</%s>\n", part.Name, placeholder(part.Type), part.Name)
+			}
+			fmt.Fprintf(&b, "</ns:%s>", operation)
+			continue
+		}
+		for _, part := range m.Parts {
+			if el, schema, ok := defs.element(localName(part.Element)); ok {
+				defs.writeElement(&b, el, schema, 0, true)
+			}
+		}
+	}
+	return doc, strings.TrimSpace(b.String())
+}
+
+// element returns the top-level schema element called name.
+func (defs wsdlDefinitions) element(name string) (xsdElement, xsdSchema, bool) {
+	for _, s := range defs.Schemas {
+		for _, el := range s.Elements {
+			if el.Name == name {
+				return el, s, true
+			}
+		}
+	}
+	return xsdElement{}, xsdSchema{}, false
+}
+
+// complexType returns the named complex type.
+func (defs wsdlDefinitions) complexType(name string) (*xsdComplexType, bool) {
+	for _, s := range defs.Schemas {
+		for i, t := range s.ComplexTypes {
+			if t.Name == name {
+				return &s.ComplexTypes[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// maxSkeletonDepth stops the skeleton of a recursive type from going on forever.
+const maxSkeletonDepth = 8
+
+// writeElement writes a skeleton of el at the given depth. Children are in
+// the schema's namespace when it qualifies them, and unqualified otherwise.
+func (defs wsdlDefinitions) writeElement(b *strings.Builder, el xsdElement, schema xsdSchema, depth int, top bool) {
+	if el.Ref != "" {
+		if ref, s, ok := defs.element(localName(el.Ref)); ok {
+			defs.writeElement(b, ref, s, depth, true)
+		}
+		return
+	}
+	indent := strings.Repeat("  ", depth)
+	name, attrs := el.Name, ""
+	if top || schema.ElementFormDefault == "qualified" {
+		name = "ns:" + el.Name
+	}
+	if top {
+		attrs = fmt.Sprintf(" xmlns:ns=%q", schema.TargetNamespace)
+	}
+	if el.MaxOccurs != "" && el.MaxOccurs != "1" {
+		fmt.Fprintf(b, "%s<!-- repeatable -->\n", indent)
+	}
+
+	t := el.ComplexType
+	if t == nil && el.Type != "" {
+		t, _ = defs.complexType(localName(el.Type))
+	}
+	if t == nil || depth >= maxSkeletonDepth {
+		fmt.Fprintf(b, "%s<%s%s>%s</%s>\n", indent, name, attrs, placeholder(el.Type), name)
+		return
+	}
+	fmt.Fprintf(b, "%s<%s%s>\n", indent, name, attrs)
+	for _, child := range defs.children(t, 0) {
+		defs.writeElement(b, child, schema, depth+1, false)
+	}
+	fmt.Fprintf(b, "%s</%s>\n", indent, name)
+}
+
+// children returns the elements of t, those of the type it extends first.
+func (defs wsdlDefinitions) children(t *xsdComplexType, depth int) []xsdElement {
+	var out []xsdElement
+	if t.Extends != nil {
+		if base, ok := defs.complexType(localName(t.Extends.Base)); ok && depth < maxSkeletonDepth {
+			out = append(out, defs.children(base, depth+1)...)
+		}
+		out = append(out, t.Extends.Sequence...)
+	}
+	out = append(out, t.Sequence...)
+	out = append(out, t.All...)
+	return append(out, t.Choice...)
+}
+
+// placeholder returns a stand-in value for a schema type.
+func placeholder(xsdType string) string {
+	switch localName(xsdType) {
+	case "int", "integer", "long", "short", "byte", "decimal", "unsignedInt", "unsignedLong", "unsignedShort", "nonNegativeInteger", "positiveInteger":
+		return "0"
+	case "float", "double":
+		return "0.0"
+	case "boolean":
+		return "false"
+	case "date":
+		return "2006-01-02"
+	case "dateTime":
+		return "2006-01-02T15:04:05Z"
+	}
+	return "?"
+}
+
+// findOperation returns the operation called name, preferring the given
+// SOAP version when the service offers both.
+func findOperation(ops []soapOperation, name, version string) (soapOperation, error) {
+	var found []soapOperation
+	for _, op := range ops {
+		if op.name == name {
+			found = append(found, op)
+		}
+	}
+	for _, op := range found {
+		if version == "" || op.version == version {
+			return op, nil
+		}
+	}
+	if len(found) > 0 {
+		return found[0], nil
+	}
+	names := make([]string, 0, len(ops))
+	for _, op := range ops {
+		if !slices.Contains(names, op.name) {
+			names = append(names, op.name)
+		}
+	}
+	return soapOperation{}, fmt.Errorf("no operation %q; the WSDL has %s", name, strings.Join(names, ", "))
+}
+
+// runWSDL implements `wsdl URL-or-file`: it lists the operations of a web
+// service with a skeleton request for each, ready to fill in.
+func runWSDL(args []string) error {
+	fs := flag.NewFlagSet("wsdl", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: wsdl URL-or-file")
+	}
+	ops, err := loadWSDL(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	printOperations(os.Stdout, fs.Arg(0), ops)
+	return nil
+}
+
+// printOperations writes a description of each operation to w.
+func printOperations(w io.Writer, source string, ops []soapOperation) {
+	for _, op := range ops {
+		fmt.Fprintf(w, "%s (SOAP %s)\n", op.name, op.version)
+		if op.doc != "" {
+			fmt.Fprintf(w, "  %s\n", op.doc)
+		}
+		fmt.Fprintf(w, "  endpoint:   %s\n", op.endpoint)
+		if op.action != "" {
+			fmt.Fprintf(w, "  SOAPAction: %s\n", op.action)
+		}
+		fmt.Fprintf(w, "  send with:  %s -wsdl %s -soap-operation %s -soap %s\n", os.Args[0], source, op.name, op.version)
+		if op.body != "" {
+			fmt.Fprintf(w, "  body:\n    %s\n", strings.ReplaceAll(op.body, "\n", "\n    "))
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsEnvelope(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{`<?xml version="1.0"?><!-- hi --><soap:Envelope xmlns:soap="x"><soap:Body/></soap:Envelope>`, true},
+		{`<Envelope/>`, true},
+		{`<GetUser><id>1</id></GetUser>`, false},
+		{`not xml`, false},
+		{``, false},
+	}
+	for _, tt := range tests {
+		if got := isEnvelope([]byte(tt.body)); got != tt.want {
+			t.Errorf("isEnvelope(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	env := envelope("1.2", []byte(" <auth/> "), []byte("<GetUser/>"))
+	if !isEnvelope(env) {
+		t.Fatalf("envelope is not an envelope:\n%s", env)
+	}
+	var parsed struct {
+		XMLName xml.Name
+		Header  struct {
+			Inner string `xml:",innerxml"`
+		} `xml:"Header"`
+		Body struct {
+			Inner string `xml:",innerxml"`
+		} `xml:"Body"`
+	}
+	if err := xml.Unmarshal(env, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.XMLName.Space != soap12Envelope {
+		t.Errorf("namespace = %q, want SOAP 1.2", parsed.XMLName.Space)
+	}
+	if strings.TrimSpace(parsed.Header.Inner) != "<auth/>" || strings.TrimSpace(parsed.Body.Inner) != "<GetUser/>" {
+		t.Errorf("header = %q, body = %q", parsed.Header.Inner, parsed.Body.Inner)
+	}
+
+	// No header, no Header element; any other version is 1.1.
+	env = envelope("1.1", nil, []byte("<X/>"))
+	if bytes.Contains(env, []byte("Header")) || !bytes.Contains(env, []byte(soap11Envelope)) {
+		t.Errorf("1.1 envelope without a header:\n%s", env)
+	}
+}
+
+func TestWrapSOAP11(t *testing.T) {
+	cfg := config{header: http.Header{}, soapVersion: "1.1", soapAction: "urn:GetUser", body: []byte("<GetUser/>")}
+	got := wrapSOAP(cfg)
+	if !isEnvelope(got.body) {
+		t.Errorf("body was not wrapped:\n%s", got.body)
+	}
+	if ct := got.header.Get("Content-Type"); ct != "text/xml; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if a := got.header.Get("SOAPAction"); a != `"urn:GetUser"` {
+		t.Errorf("SOAPAction = %q", a)
+	}
+	if len(cfg.header) != 0 {
+		t.Errorf("wrapSOAP changed the caller's headers: %v", cfg.header)
+	}
+
+	// Without an action the header is still sent, empty; an envelope is
+	// sent as it is.
+	cfg = config{header: http.Header{}, soapVersion: "1.1", body: envelope("1.1", nil, []byte("<A/>"))}
+	got = wrapSOAP(cfg)
+	if a := got.header.Values("SOAPAction"); len(a) != 1 || a[0] != `""` {
+		t.Errorf("SOAPAction = %q, want \"\"", a)
+	}
+	if !bytes.Equal(got.body, cfg.body) {
+		t.Errorf("an envelope was wrapped again:\n%s", got.body)
+	}
+}
+
+func TestWrapSOAP12(t *testing.T) {
+	cfg := config{header: http.Header{}, soapVersion: "1.2", soapAction: "urn:GetUser", body: []byte("<GetUser/>")}
+	got := wrapSOAP(cfg)
+	if ct := got.header.Get("Content-Type"); ct != `application/soap+xml; charset=utf-8; action="urn:GetUser"` {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if got.header.Values("SOAPAction") != nil {
+		t.Errorf("SOAP 1.2 sent a SOAPAction header: %q", got.header.Values("SOAPAction"))
+	}
+
+	// A Content-Type given with -H wins.
+	cfg.header.Set("Content-Type", "application/soap+xml")
+	if ct := wrapSOAP(cfg).header.Get("Content-Type"); ct != "application/soap+xml" {
+		t.Errorf("Content-Type = %q, want the one from -H", ct)
+	}
+}
+
+func TestSOAPFault(t *testing.T) {
+	tests := []struct {
+		name, body, want string
+	}{
+		{
+			name: "1.1",
+			body: `<s:Envelope xmlns:s="` + soap11Envelope + `"><s:Body><s:Fault>
+				<faultcode>s:Client</faultcode><faultstring>Bad id</faultstring>
+			</s:Fault></s:Body></s:Envelope>`,
+			want: "s:Client: Bad id",
+		},
+		{
+			name: "1.2",
+			body: `<env:Envelope xmlns:env="` + soap12Envelope + `"><env:Body><env:Fault>
+				<env:Code><env:Value>env:Sender</env:Value></env:Code>
+				<env:Reason><env:Text xml:lang="en">No such user</env:Text></env:Reason>
+			</env:Fault></env:Body></env:Envelope>`,
+			want: "env:Sender: No such user",
+		},
+		{
+			name: "answer",
+			body: `<s:Envelope xmlns:s="` + soap11Envelope + `"><s:Body><GetUserResponse/></s:Body></s:Envelope>`,
+		},
+		{name: "not an envelope", body: `<Fault><faultcode>x</faultcode></Fault>`},
+		{name: "not xml", body: `{"fault": true}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := soapFault([]byte(tt.body)); got != tt.want {
+				t.Errorf("soapFault = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// testWSDL describes a service with a document style operation over SOAP
+// 1.1 and 1.2, an rpc style one, and an HTTP binding that isn't SOAP.
+const testWSDL = `<?xml version="1.0"?>
+<definitions xmlns="http://schemas.xmlsoap.org/wsdl/"
+    xmlns:soap="http://schemas.xmlsoap.org/wsdl/soap/"
+    xmlns:soap12="http://schemas.xmlsoap.org/wsdl/soap12/"
+    xmlns:http="http://schemas.xmlsoap.org/wsdl/http/"
+    xmlns:xs="http://www.w3.org/2001/XMLSchema"
+    xmlns:tns="urn:users" targetNamespace="urn:users">
+  <types>
+    <xs:schema targetNamespace="urn:users" elementFormDefault="qualified">
+      <xs:element name="GetUser">
+        <xs:complexType>
+          <xs:sequence>
+            <xs:element name="id" type="xs:int"/>
+            <xs:element name="filter" type="tns:Filter"/>
+            <xs:element name="tag" type="xs:string" maxOccurs="unbounded"/>
+          </xs:sequence>
+        </xs:complexType>
+      </xs:element>
+      <xs:complexType name="Base">
+        <xs:sequence><xs:element name="active" type="xs:boolean"/></xs:sequence>
+      </xs:complexType>
+      <xs:complexType name="Filter">
+        <xs:complexContent>
+          <xs:extension base="tns:Base">
+            <xs:sequence><xs:element name="since" type="xs:date"/></xs:sequence>
+          </xs:extension>
+        </xs:complexContent>
+      </xs:complexType>
+    </xs:schema>
+  </types>
+  <message name="GetUserIn"><part name="parameters" element="tns:GetUser"/></message>
+  <message name="AddIn">
+    <part name="a" type="xs:int"/>
+    <part name="b" type="xs:double"/>
+  </message>
+  <portType name="Users">
+    <operation name="GetUser">
+      <documentation>Looks a user up.</documentation>
+      <input message="tns:GetUserIn"/>
+    </operation>
+    <operation name="Add"><input message="tns:AddIn"/></operation>
+  </portType>
+  <binding name="Users11" type="tns:Users">
+    <soap:binding style="document" transport="http://schemas.xmlsoap.org/soap/http"/>
+    <operation name="GetUser"><soap:operation soapAction="urn:users/GetUser"/></operation>
+    <operation name="Add"><soap:operation soapAction="urn:users/Add" style="rpc"/></operation>
+  </binding>
+  <binding name="Users12" type="tns:Users">
+    <soap12:binding style="document" transport="http://schemas.xmlsoap.org/soap/http"/>
+    <operation name="GetUser"><soap12:operation soapAction="urn:users/GetUser"/></operation>
+  </binding>
+  <binding name="UsersHTTP" type="tns:Users">
+    <http:binding verb="GET"/>
+    <operation name="GetUser"/>
+  </binding>
+  <service name="UserService">
+    <port name="P11" binding="tns:Users11"><soap:address location="http://example.com/soap11"/></port>
+    <port name="P12" binding="tns:Users12"><soap12:address location="http://example.com/soap12"/></port>
+    <port name="PHTTP" binding="tns:UsersHTTP"><http:address location="http://example.com/http"/></port>
+  </service>
+</definitions>
+`
+
+// writeWSDL writes testWSDL to a file and returns its path.
+func writeWSDL(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "users.wsdl")
+	if err := os.WriteFile(path, []byte(testWSDL), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadWSDL(t *testing.T) {
+	ops, err := loadWSDL(writeWSDL(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, op := range ops {
+		got = append(got, op.name+" "+op.version+" "+op.endpoint)
+	}
+	want := []string{
+		"GetUser 1.1 http://example.com/soap11",
+		"Add 1.1 http://example.com/soap11",
+		"GetUser 1.2 http://example.com/soap12",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("operations:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	get := ops[0]
+	if get.action != "urn:users/GetUser" || get.doc != "Looks a user up." {
+		t.Errorf("GetUser action = %q, doc = %q", get.action, get.doc)
+	}
+	wantBody := `<ns:GetUser xmlns:ns="urn:users">
+  <ns:id>0</ns:id>
+  <ns:filter>
+    <ns:active>false</ns:active>
+    <ns:since>2006-01-02</ns:since>
+  </ns:filter>
+  <!-- repeatable -->
+  <ns:tag>?</ns:tag>
+</ns:GetUser>`
+	if get.body != wantBody {
+		t.Errorf("GetUser skeleton:\n%s\nwant:\n%s", get.body, wantBody)
+	}
+
+	wantRPC := `<ns:Add xmlns:ns="urn:users">
+  <a>0</a>
+  <b>0.0</b>
+</ns:Add>`
+	if ops[1].body != wantRPC {
+		t.Errorf("Add skeleton:\n%s\nwant:\n%s", ops[1].body, wantRPC)
+	}
+}
+
+func TestLoadWSDLErrors(t *testing.T) {
+	dir := t.TempDir()
+	noSOAP := filepath.Join(dir, "empty.wsdl")
+	os.WriteFile(noSOAP, []byte(`<definitions xmlns="http://schemas.xmlsoap.org/wsdl/"/>`), 0o644)
+	if _, err := loadWSDL(noSOAP); err == nil || !strings.Contains(err.Error(), "no SOAP operations") {
+		t.Errorf("loadWSDL of a WSDL without SOAP ports: %v", err)
+	}
+
+	broken := filepath.Join(dir, "broken.wsdl")
+	os.WriteFile(broken, []byte(`<definitions>`), 0o644)
+	if _, err := loadWSDL(broken); err == nil || !strings.Contains(err.Error(), "reading the WSDL") {
+		t.Errorf("loadWSDL of broken XML: %v", err)
+	}
+
+	if _, err := loadWSDL(filepath.Join(dir, "missing.wsdl")); err == nil {
+		t.Error("loadWSDL of a missing file succeeded")
+	}
+}
+
+func TestFindOperation(t *testing.T) {
+	ops := []soapOperation{
+		{name: "GetUser", version: "1.1"},
+		{name: "Add", version: "1.1"},
+		{name: "GetUser", version: "1.2"},
+	}
+	if op, err := findOperation(ops, "GetUser", "1.2"); err != nil || op.version != "1.2" {
+		t.Errorf("GetUser 1.2 = %+v, %v", op, err)
+	}
+	if op, err := findOperation(ops, "GetUser", ""); err != nil || op.version != "1.1" {
+		t.Errorf("GetUser = %+v, %v, want the first", op, err)
+	}
+	// Asking for a version the operation lacks falls back to the other.
+	if op, err := findOperation(ops, "Add", "1.2"); err != nil || op.version != "1.1" {
+		t.Errorf("Add 1.2 = %+v, %v, want 1.1", op, err)
+	}
+	_, err := findOperation(ops, "Delete", "")
+	if err == nil || !strings.Contains(err.Error(), `no operation "Delete"; the WSDL has GetUser, Add`) {
+		t.Errorf("findOperation of a missing operation: %v", err)
+	}
+}
+
+func TestPrintOperations(t *testing.T) {
+	var b bytes.Buffer
+	printOperations(&b, "users.wsdl", []soapOperation{{
+		name: "Add", version: "1.1", action: "urn:Add", endpoint: "http://example.com/",
+		doc: "Adds.", body: "<Add>\n  <a>0</a>\n</Add>",
+	}})
+	out := b.String()
+	for _, want := range []string{
+		"Add (SOAP 1.1)\n  Adds.\n",
+		"  endpoint:   http://example.com/\n",
+		"  SOAPAction: urn:Add\n",
+		" -wsdl users.wsdl -soap-operation Add -soap 1.1\n",
+		"  body:\n    <Add>\n      <a>0</a>\n    </Add>\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Some services can only be reached from a bastion host. An environment
+// with "ssh" sends its requests through an SSH connection to one, which
+// opens each TCP connection on the far side, as ssh -J would:
+//
+//	{"internal": {"base": "http://billing.internal:8080/", "ssh": "deploy@bastion.example.com"}}
+//
+// The connection to the bastion is made once and shared by every request,
+// and made again if it drops. It authenticates as dialSSH does.
+
+// sshTunnels holds the connections to the jump hosts, by how they were
+// given.
+var sshTunnels = struct {
+	sync.Mutex
+	byHost map[string]*ssh.Client
+}{byHost: map[string]*ssh.Client{}}
+
+// parseJumpHost reads an environment's "ssh": [user@]host[:port].
+func parseJumpHost(jump string) (*url.URL, error) {
+	u, err := url.Parse("ssh://" + jump)
+	if err != nil || u.Hostname() == "" || u.Path != "" || u.RawQuery != "" {
+		return nil, fmt.Errorf("the jump host %q is not [user@]host[:port]", jump)
+	}
+	return u, nil
+}
+
+// sshClient returns the connection to the jump host jump, connecting if
+// there is none.
+func sshClient(jump string) (*ssh.Client, error) {
+	sshTunnels.Lock()
+	defer sshTunnels.Unlock()
+	if c, ok := sshTunnels.byHost[jump]; ok {
+		return c, nil
+	}
+	u, err := parseJumpHost(jump)
+	if err != nil {
+		return nil, err
+	}
+	c, err := dialSSH(u)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the jump host %s: %w", u.Host, err)
+	}
+	sshTunnels.byHost[jump] = c
+	// Once it drops, the next request connects afresh.
+	go func() {
+		c.Wait()
+		sshTunnels.Lock()
+		if sshTunnels.byHost[jump] == c {
+			delete(sshTunnels.byHost, jump)
+		}
+		sshTunnels.Unlock()
+	}()
+	return c, nil
+}
+
+// sshDialer dials through the jump host jump: the address is resolved and
+// connected to from there. The jump host picks the address family of what
+// it resolves, so -4 or -6, family, only goes for an address given as an
+// IP of that family; anything else is refused rather than sent either way.
+func sshDialer(jump, family string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if family != "" {
+			host, _, _ := net.SplitHostPort(addr)
+			if ip := net.ParseIP(host); ip == nil || ipFamily(ip) != "IPv"+family {
+				return nil, fmt.Errorf("-%s can't be kept to through the jump host %s, which looks %s up itself", family, jump, host)
+			}
+		}
+		c, err := sshClient(jump)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := c.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("through %s: %w", jump, err)
+		}
+		return conn, nil
+	}
+}
+
+// transportOptionsFor is cfg's connection settings, with the jump host of
+// its environment.
+func transportOptionsFor(cfg config) transportOptions {
+	opts := cfg.transport
+	opts.sshJump = cfg.envs[cfg.env].SSH
+	return opts
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// startJumpHost runs an SSH server on localhost that lets anyone in and
+// forwards their direct-tcpip channels, and lists its key in a
+// known_hosts under a new home directory. It counts the connections to it.
+func startJumpHost(t *testing.T) (addr string, logins *atomic.Int32) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &ssh.ServerConfig{NoClientAuth: true}
+	conf.AddHostKey(signer)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	logins = new(atomic.Int32)
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			logins.Add(1)
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(nc, conf)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nch := range chans {
+					if nch.ChannelType() != "direct-tcpip" {
+						nch.Reject(ssh.UnknownChannelType, "no")
+						continue
+					}
+					// The target's host and port lead the extra data.
+					data := nch.ExtraData()
+					n := binary.BigEndian.Uint32(data)
+					host, port := string(data[4:4+n]), binary.BigEndian.Uint32(data[4+n:])
+					target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+					if err != nil {
+						nch.Reject(ssh.ConnectionFailed, err.Error())
+						continue
+					}
+					ch, chReqs, _ := nch.Accept()
+					go ssh.DiscardRequests(chReqs)
+					go func() { io.Copy(ch, target); ch.CloseWrite() }()
+					go func() { io.Copy(target, ch); target.Close() }()
+				}
+			}()
+		}
+	}()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+	os.Mkdir(filepath.Join(home, ".ssh"), 0o700)
+	line := knownhosts.Line([]string{knownhosts.Normalize(ln.Addr().String())}, signer.PublicKey())
+	os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), []byte(line+"\n"), 0o600)
+	return ln.Addr().String(), logins
+}
+
+func TestSSHTunnel(t *testing.T) {
+	jump, logins := startJumpHost(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "inside")
+	}))
+	defer srv.Close()
+
+	cfg := config{method: "GET", url: srv.URL, header: http.Header{},
+		envs: map[string]environment{"internal": {Base: srv.URL, SSH: "deploy@" + jump}}, env: "internal"}
+	for range 2 {
+		client := newClient(cfg)
+		res, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		client.CloseIdleConnections()
+		if string(b) != "inside" {
+			t.Errorf("got %q", b)
+		}
+	}
+	if n := logins.Load(); n != 1 {
+		t.Errorf("%d logins to the jump host; they should share one", n)
+	}
+
+	// -4 holds for an IPv4 address; -6 can't, nor for a name.
+	cfg.transport.family = "4"
+	if res, err := newClient(cfg).Get(srv.URL); err != nil {
+		t.Errorf("-4 to %s: %v", srv.URL, err)
+	} else {
+		res.Body.Close()
+	}
+	cfg.transport.family = "6"
+	if _, err := newClient(cfg).Get(srv.URL); err == nil || !strings.Contains(err.Error(), "-6 can't be kept to") {
+		t.Errorf("-6 to %s: %v", srv.URL, err)
+	}
+	cfg.transport.family = ""
+
+	// A host that isn't known is refused.
+	os.WriteFile(filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts"), nil, 0o600)
+	cfg.envs["internal"] = environment{SSH: "someone@" + jump}
+	if _, err := newClient(cfg).Get(srv.URL); err == nil || !strings.Contains(err.Error(), "is not in ~/.ssh/known_hosts") {
+		t.Errorf("an unknown jump host: %v", err)
+	}
+}
+
+func TestParseJumpHost(t *testing.T) {
+	u, err := parseJumpHost("deploy@bastion.example.com:2222")
+	if err != nil || u.User.Username() != "deploy" || u.Host != "bastion.example.com:2222" {
+		t.Errorf("%v, %v", u, err)
+	}
+	for _, bad := range []string{"", "bastion/x", "@"} {
+		if _, err := parseJumpHost(bad); err == nil {
+			t.Errorf("%q was taken", bad)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// statusRef is an offline reference entry for one HTTP status code.
+type statusRef struct {
+	code    int    // The status code itself.
+	spec    string // Where it is defined, e.g. "RFC 9110 §15.5.5".
+	meaning string // What the server is telling us.
+	causes  string // Why it usually happens and what to do about it.
+}
+
+// statusRefs is the reference pane's content, in numeric order.
+var statusRefs = []statusRef{
+	{100, "RFC 9110 §15.2.1", "The request headers were accepted; send the body.", "Only seen with Expect: 100-continue. Nothing to fix."},
+	{101, "RFC 9110 §15.2.2", "The server is switching to the protocol named in Upgrade.", "Normal for WebSocket and h2c upgrades."},
+	{102, "RFC 2518 §10.1", "The server accepted a WebDAV request and is still working on it.", "Long-running WebDAV operation; wait for the final status."},
+	{103, "RFC 8297 §2", "Preliminary headers, usually Link preloads, ahead of the real response.", "Informational only; the final response follows."},
+	{200, "RFC 9110 §15.3.1", "The request succeeded.", "All is well."},
+	{201, "RFC 9110 §15.3.2", "A new resource was created.", "Check the Location header for its URL."},
+	{202, "RFC 9110 §15.3.3", "The request was accepted for processing that hasn't finished.", "Poll the status URL the API gives you, if any."},
+	{203, "RFC 9110 §15.3.4", "Success, but a proxy changed the payload.", "A transforming proxy sits in the path; bypass it to see the origin's answer."},
+	{204, "RFC 9110 §15.3.5", "Success with no body.", "Expected for DELETE and many PUTs; don't try to parse a body."},
+	{205, "RFC 9110 §15.3.6", "Success; the client should reset its form or view.", "Rare outside form-driven UIs."},
+	{206, "RFC 9110 §15.3.7", "Only the requested byte ranges are in the body.", "An answer to a Range request; read Content-Range for the span."},
+	{207, "RFC 4918 §11.1", "Several statuses for several WebDAV resources are in the body.", "Parse the XML multistatus body for per-resource results."},
+	{208, "RFC 5842 §7.1", "WebDAV members already listed earlier in this response.", "Avoids repeating bindings; nothing to fix."},
+	{226, "RFC 3229 §10.4.1", "The body is a delta-encoded instance manipulation.", "Only with A-IM requests; rare in practice."},
+	{300, "RFC 9110 §15.4.1", "Several representations are available.", "Pick one from the body or Link headers."},
+	{301, "RFC 9110 §15.4.2", "The resource moved permanently.", "Update links and bookmarks to the Location URL."},
+	{302, "RFC 9110 §15.4.3", "The resource is temporarily somewhere else.", "Follow Location; clients may switch POST to GET, use 307 if that matters."},
+	{303, "RFC 9110 §15.4.4", "See the Location URL, fetching it with GET.", "Common after form POSTs (post/redirect/get)."},
+	{304, "RFC 9110 §15.4.5", "Not modified since the validator you sent.", "Your cached copy is current; this is the answer to a conditional GET."},
+	{305, "RFC 9110 §15.4.6", "Use the proxy in Location (deprecated).", "Ignored by modern clients for security reasons."},
+	{307, "RFC 9110 §15.4.8", "Temporarily elsewhere; repeat the same method and body there.", "Follow Location without changing the method."},
+	{308, "RFC 9110 §15.4.9", "Permanently elsewhere; repeat the same method and body there.", "Update the URL; unlike 301 the method is kept."},
+	{400, "RFC 9110 §15.5.1", "The server couldn't understand the request.", "Malformed JSON, bad query parameters or an invalid header; check the error body."},
+	{401, "RFC 9110 §15.5.2", "Authentication is missing or wrong.", "Send or refresh credentials; WWW-Authenticate says which scheme is expected."},
+	{402, "RFC 9110 §15.5.3", "Payment required (reserved).", "Used ad hoc by some APIs for billing problems."},
+	{403, "RFC 9110 §15.5.4", "The server understood but refuses to do it.", "Your credentials lack permission, or a WAF or IP rule blocked you."},
+	{404, "RFC 9110 §15.5.5", "Nothing was found at this URL.", "Typo in the path, wrong base URL or API version, or the resource was deleted."},
+	{405, "RFC 9110 §15.5.6", "The method is not allowed on this resource.", "Check the Allow header for the methods it does support."},
+	{406, "RFC 9110 §15.5.7", "No representation matches your Accept headers.", "Loosen Accept, Accept-Language or Accept-Encoding."},
+	{407, "RFC 9110 §15.5.8", "A proxy wants you to authenticate.", "Configure proxy credentials; see Proxy-Authenticate."},
+	{408, "RFC 9110 §15.5.9", "The server gave up waiting for the request.", "A slow or stalled upload; retry, and check the client's network."},
+	{409, "RFC 9110 §15.5.10", "The request conflicts with the resource's current state.", "Concurrent edit or duplicate create; refetch and retry."},
+	{410, "RFC 9110 §15.5.11", "The resource is gone for good.", "Remove references to it; it won't come back."},
+	{411, "RFC 9110 §15.5.12", "A Content-Length header is required.", "Send the body with a known length instead of chunked."},
+	{412, "RFC 9110 §15.5.13", "A precondition such as If-Match failed.", "Someone changed the resource; refetch its ETag and retry."},
+	{413, "RFC 9110 §15.5.14", "The body is larger than the server accepts.", "Shrink the upload, or raise the server's or proxy's body limit."},
+	{414, "RFC 9110 §15.5.15", "The URL is longer than the server accepts.", "Move query data into a POST body."},
+	{415, "RFC 9110 §15.5.16", "The body's media type isn't supported.", "Set the right Content-Type (and Content-Encoding)."},
+	{416, "RFC 9110 §15.5.17", "The requested byte range can't be served.", "The range is past the end; Content-Range gives the real size."},
+	{417, "RFC 9110 §15.5.18", "The Expect header can't be met.", "Drop Expect: 100-continue for this server."},
+	{418, "RFC 9110 §15.5.19", "I'm a teapot (reserved, from an April Fools' RFC).", "Sometimes used jokingly to refuse bots."},
+	{421, "RFC 9110 §15.5.20", "The request reached a server that can't answer for this host.", "Connection reuse across hosts with a shared certificate; retry on a new connection."},
+	{422, "RFC 9110 §15.5.21", "The body is well-formed but semantically invalid.", "Validation errors; the body usually lists the offending fields."},
+	{423, "RFC 4918 §11.3", "The WebDAV resource is locked.", "Wait for or release the lock."},
+	{424, "RFC 4918 §11.4", "Failed because a request it depended on failed.", "Fix the earlier failure in the same WebDAV batch."},
+	{425, "RFC 8470 §5.2", "The server won't risk processing a replayable early-data request.", "Retry after the TLS handshake completes."},
+	{426, "RFC 9110 §15.5.22", "Switch to the protocol named in Upgrade.", "Often means use HTTPS or a newer HTTP version."},
+	{428, "RFC 6585 §3", "The server requires a conditional request.", "Send If-Match with the resource's ETag to avoid lost updates."},
+	{429, "RFC 6585 §4", "Too many requests in a given time.", "Back off; Retry-After says how long."},
+	{431, "RFC 6585 §5", "Request headers are too large.", "Usually oversized cookies or tokens; trim them."},
+	{451, "RFC 7725 §3", "Unavailable for legal reasons.", "Blocked by a legal demand, often by region."},
+	{500, "RFC 9110 §15.6.1", "The server hit an unexpected error.", "A server-side bug or crash; check the server logs."},
+	{501, "RFC 9110 §15.6.2", "The server doesn't support this functionality.", "Unknown method or feature; try a different approach."},
+	{502, "RFC 9110 §15.6.3", "A gateway got an invalid answer from upstream.", "The upstream app is down or crashing; check the proxy's and app's logs."},
+	{503, "RFC 9110 §15.6.4", "The server is temporarily unable to handle the request.", "Overload or maintenance; honour Retry-After."},
+	{504, "RFC 9110 §15.6.5", "A gateway timed out waiting for upstream.", "The upstream is slow or unreachable; check timeouts along the chain."},
+	{505, "RFC 9110 §15.6.6", "The HTTP version isn't supported.", "Try a different protocol version."},
+	{506, "RFC 2295 §8.1", "Content negotiation is misconfigured on the server.", "A server configuration bug."},
+	{507, "RFC 4918 §11.5", "The server is out of storage.", "Free space on the server or reduce the upload."},
+	{508, "RFC 5842 §7.2", "The server found an infinite loop.", "WebDAV binding loop; fix the resource structure."},
+	{510, "RFC 2774 §7", "Further extensions are required.", "Obsolete; rarely seen."},
+	{511, "RFC 6585 §6", "Network authentication is required.", "A captive portal is intercepting traffic; log in to the network."},
+}
+
+// refIndex returns the position of code in statusRefs, or of the nearest
+// code below it for unlisted codes, so the pane always opens near it.
+func refIndex(code int) int {
+	i := 0
+	for j, r := range statusRefs {
+		if r.code <= code {
+			i = j
+		}
+	}
+	return i
+}
+
+// statusMeaning returns the one-line meaning of code, or "" if it isn't listed.
+func statusMeaning(code int) string {
+	for _, r := range statusRefs {
+		if r.code == code {
+			return r.meaning
+		}
+	}
+	return ""
+}
+
+// updateRef handles keys while the status code reference is open.
+func (m model) updateRef(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "i", "esc":
+		m.showRef = false
+	case "up", "k":
+		m.refCursor = max(m.refCursor-1, 0)
+	case "down", "j":
+		m.refCursor = min(m.refCursor+1, len(statusRefs)-1)
+	}
+	return m, nil
+}
+
+// viewRef renders the status code reference: a scrolling list of codes and
+// the details of the selected one.
+func (m model) viewRef() string {
+	items := make([]string, len(statusRefs))
+	for i, r := range statusRefs {
+		items[i] = fmt.Sprintf("%d %s", r.code, http.StatusText(r.code))
+		if r.code == m.res.status {
+			items[i] += "  ← this response"
+		}
+	}
+
+	r := statusRefs[m.refCursor]
+	var b strings.Builder
+	b.WriteString("\nStatus code reference\n")
+	b.WriteString(renderList(items, m.refCursor))
+	fmt.Fprintf(&b, "\n\n%d %s (%s)\n%s\n%s\n", r.code, http.StatusText(r.code), r.spec, r.meaning, r.causes)
+	b.WriteString("\n↑/↓ select • i close • q quit\n")
+	return b.String()
+}
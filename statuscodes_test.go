@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStatusRefsSorted(t *testing.T) {
+	for i := 1; i < len(statusRefs); i++ {
+		if statusRefs[i-1].code >= statusRefs[i].code {
+			t.Errorf("%d comes before %d", statusRefs[i-1].code, statusRefs[i].code)
+		}
+	}
+	for _, r := range statusRefs {
+		if http.StatusText(r.code) == "" || r.spec == "" || r.meaning == "" {
+			t.Errorf("%d is missing a name, spec or meaning", r.code)
+		}
+	}
+}
+
+func TestRefIndex(t *testing.T) {
+	if got := statusRefs[refIndex(404)].code; got != 404 {
+		t.Errorf("refIndex(404) is at %d", got)
+	}
+	// Unlisted codes open at the nearest code below them.
+	if got := statusRefs[refIndex(499)].code; got > 499 || got < 400 {
+		t.Errorf("refIndex(499) is at %d", got)
+	}
+	if refIndex(0) != 0 {
+		t.Errorf("refIndex(0) = %d, want 0", refIndex(0))
+	}
+}
+
+func TestStatusMeaning(t *testing.T) {
+	if got := statusMeaning(511); !strings.Contains(got, "Network authentication") {
+		t.Errorf("statusMeaning(511) = %q", got)
+	}
+	if got := statusMeaning(299); got != "" {
+		t.Errorf("statusMeaning(299) = %q, want nothing", got)
+	}
+}
+
+func TestViewRef(t *testing.T) {
+	m := model{res: response{status: 404}, refCursor: refIndex(404)}
+	view := m.viewRef()
+	if !strings.Contains(view, "404 Not Found  ← this response") || !strings.Contains(view, "RFC 9110") {
+		t.Errorf("view =\n%s", view)
+	}
+}
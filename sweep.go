@@ -0,0 +1,224 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sweepWorkers is how many requests of a sweep are in flight at once.
+const sweepWorkers = 10
+
+// maxSweepValues caps how many values one sweep may try.
+const maxSweepValues = 10000
+
+// runSweep is the palette's `sweep [field=]values [path]`: it sends the
+// request once per value, with field set to it, and shows a table of the
+// statuses and times, and with a path such as $.data.state, what each
+// answer had there. The field is a query parameter or a JSON body field,
+// as fuzz takes them; left out, it is the ID at the end of the URL's path.
+// The values are a range, from..to or from..to..step, or a list separated
+// by commas.
+func runSweep(m model, args []string) (tea.Model, tea.Cmd) {
+	if len(args) == 0 || len(args) > 2 {
+		return m.paletteError(errors.New("usage: sweep [field=]values [path], e.g. sweep 1..20 $.status or sweep q=a,b,c"))
+	}
+	if isFileTransfer(m.cfg.url) {
+		return m.paletteError(errors.New("only HTTP requests can be swept"))
+	}
+	field, spec, ok := strings.Cut(args[0], "=")
+	if !ok {
+		field, spec = "", args[0]
+	}
+	values, err := parseSweep(spec)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	var path string
+	if len(args) == 2 {
+		path = args[1]
+		if !strings.HasPrefix(path, "$") {
+			path = "$." + path
+		}
+	}
+	if _, err := swept(m.cfg, field, values[0]); err != nil {
+		return m.paletteError(err)
+	}
+	cfg := m.cfg
+	m.job = fmt.Sprintf("Sweeping %d values", len(values))
+	return m, runJob(func(report func(string)) tea.Msg {
+		return tableMsg{sweep(cfg, field, values, path, report)}
+	})
+}
+
+// parseSweep reads the values of a sweep: from..to, from..to..step or a
+// comma-separated list. Zero padding of a range's start is kept, so that
+// 001..010 sweeps 001, 002 and so on.
+func parseSweep(spec string) ([]string, error) {
+	if parts := strings.Split(spec, ".."); len(parts) == 2 || len(parts) == 3 {
+		from, err1 := strconv.Atoi(parts[0])
+		to, err2 := strconv.Atoi(parts[1])
+		step, err3 := 1, error(nil)
+		if len(parts) == 3 {
+			step, err3 = strconv.Atoi(parts[2])
+		}
+		if err := errors.Join(err1, err2, err3); err != nil || step < 1 {
+			return nil, fmt.Errorf("%q is not a range such as 1..20 or 0..100..10", spec)
+		}
+		width := 0
+		if strings.HasPrefix(parts[0], "0") {
+			width = len(parts[0])
+		}
+		if from > to {
+			step = -step
+		}
+		var values []string
+		for n := from; (step > 0 && n <= to) || (step < 0 && n >= to); n += step {
+			if len(values) == maxSweepValues {
+				return nil, fmt.Errorf("%q has more than %d values", spec, maxSweepValues)
+			}
+			values = append(values, fmt.Sprintf("%0*d", width, n))
+		}
+		return values, nil
+	}
+	values := strings.Split(spec, ",")
+	if len(values) > maxSweepValues {
+		return nil, fmt.Errorf("%q has more than %d values", spec, maxSweepValues)
+	}
+	return values, nil
+}
+
+// swept returns cfg with value in field, or in the path's ID if field is "".
+// A value that is a number goes into a JSON body as one.
+func swept(cfg config, field, value string) (config, error) {
+	if field != "" {
+		var v any = value
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			v = json.Number(value)
+		}
+		return fuzzed(cfg, field, fuzzPayload{value: v})
+	}
+	target, ok := withID(cfg.url, value)
+	if !ok {
+		return cfg, errors.New("the URL's path has no ID to sweep; name a field, e.g. sweep page=1..5")
+	}
+	cfg.url = target
+	return cfg, nil
+}
+
+// withID returns raw with the last number in its path, the one bumpID
+// bumps, replaced by id.
+func withID(raw, id string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	loc := lastNumber.FindStringSubmatchIndex(u.Path)
+	if loc == nil {
+		return "", false
+	}
+	u.Path = u.Path[:loc[0]] + id + u.Path[loc[2]:]
+	u.RawPath = ""
+	return u.String(), true
+}
+
+// sweep sends the request once per value and tabulates the answers.
+func sweep(cfg config, field string, values []string, path string, report func(string)) *tableView {
+	rows := make([][]string, len(values))
+	var next, done atomic.Int64
+	var wg sync.WaitGroup
+	for range min(sweepWorkers, len(values)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < len(values); i = int(next.Add(1) - 1) {
+				rows[i] = sweepRow(cfg, field, values[i], path)
+				report(fmt.Sprintf("%d of %d sent", done.Add(1), len(values)))
+			}
+		}()
+	}
+	wg.Wait()
+
+	columns := []string{cmp.Or(field, "id"), "status", "ms"}
+	if path != "" {
+		columns = append(columns, path)
+	}
+	columns = append(columns, "error")
+	t := makeTable(columns, rows)
+	t.title = fmt.Sprintf("Sweep of %s over %d values", columns[0], len(values))
+	return t
+}
+
+// sweepRow sends the request with one value and makes a row of the table
+// of what came back.
+func sweepRow(cfg config, field, value, path string) []string {
+	cfg, _ = swept(cfg, field, value)
+	cfg = withRequestID(cfg)
+	start := time.Now()
+	msg := send(cfg)
+	elapsed := time.Since(start)
+	_ = recordHistory(cfg, msg, elapsed)
+	ms := strconv.FormatFloat(float64(elapsed.Microseconds())/1000, 'f', 1, 64)
+
+	row := []string{value, "", ms}
+	var found, problem string
+	switch msg := msg.(type) {
+	case responseMsg:
+		if msg.stopStream != nil {
+			msg.stopStream()
+		}
+		row[1] = strconv.Itoa(msg.status)
+		if path != "" {
+			found, problem = jsonAt(response(msg).jsonBody(), path)
+		}
+	case errMsg:
+		problem = msg.err.Error()
+	}
+	if path != "" {
+		row = append(row, found)
+	}
+	return append(row, problem)
+}
+
+// jsonAt returns what body holds at path, written the way the JSON tree
+// writes paths: a scalar as its JSON text, without the quotes of a string,
+// and a container by its size. It says why there is nothing to show when
+// the body isn't JSON or lacks the path.
+func jsonAt(body []byte, path string) (value, problem string) {
+	root, err := parseJSONTree(body)
+	if err != nil {
+		return "", "the body isn't JSON"
+	}
+	n := root
+	for n.path != path {
+		var next *jsonNode
+		for _, c := range n.children {
+			if rest, ok := strings.CutPrefix(path, c.path); ok && (rest == "" || rest[0] == '.' || rest[0] == '[') {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return "", "no " + path
+		}
+		n = next
+	}
+	switch {
+	case n.array || n.object:
+		return n.size(), ""
+	case strings.HasPrefix(n.value, `"`):
+		var s string
+		json.Unmarshal([]byte(n.value), &s)
+		return s, ""
+	}
+	return n.value, ""
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseSweep(t *testing.T) {
+	for spec, want := range map[string][]string{
+		"1..3":     {"1", "2", "3"},
+		"3..1":     {"3", "2", "1"},
+		"0..10..5": {"0", "5", "10"},
+		"008..011": {"008", "009", "010", "011"},
+		"a,b,c":    {"a", "b", "c"},
+		"only":     {"only"},
+	} {
+		if got, err := parseSweep(spec); err != nil || !slices.Equal(got, want) {
+			t.Errorf("%s: %q, %v", spec, got, err)
+		}
+	}
+	for _, spec := range []string{"1..x", "1..5..0", "0..20000"} {
+		if _, err := parseSweep(spec); err == nil {
+			t.Errorf("%s parsed", spec)
+		}
+	}
+}
+
+func TestSwept(t *testing.T) {
+	cfg := config{url: "https://example.com/users/42/orders?page=1", body: []byte(`{"n": 1}`)}
+	if got, err := swept(cfg, "", "007"); err != nil || got.url != "https://example.com/users/007/orders?page=1" {
+		t.Errorf("the path's ID: %q, %v", got.url, err)
+	}
+	if got, err := swept(cfg, "page", "3"); err != nil || got.url != "https://example.com/users/42/orders?page=3" {
+		t.Errorf("a query parameter: %q, %v", got.url, err)
+	}
+	if got, err := swept(cfg, "$.n", "3"); err != nil || string(got.body) != `{"n":3}` {
+		t.Errorf("a body field: %s, %v", got.body, err)
+	}
+	if _, err := swept(config{url: "https://example.com/users"}, "", "1"); err == nil {
+		t.Error("swept a path without an ID")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch id := strings.TrimPrefix(r.URL.Path, "/orders/"); id {
+		case "1", "2":
+			w.Write([]byte(`{"order": {"state": "shipped", "items": [1, 2]}, "id": ` + id + `}`))
+		case "3":
+			w.Write([]byte(`{"order": {}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	cfg := config{method: "GET", url: srv.URL + "/orders/1", bodyFormat: "json"}
+	table := sweep(cfg, "", []string{"1", "2", "3", "4"}, "$.order.state", func(string) {})
+	if !slices.Equal(table.columns, []string{"id", "status", "ms", "$.order.state", "error"}) || table.title != "Sweep of id over 4 values" {
+		t.Fatalf("table = %q, %q", table.title, table.columns)
+	}
+	var got [][]string
+	for _, row := range table.rows {
+		got = append(got, []string{row[0], row[1], row[3], row[4]})
+	}
+	want := [][]string{
+		{"1", "200", "shipped", ""},
+		{"2", "200", "shipped", ""},
+		{"3", "200", "", "no $.order.state"},
+		{"4", "404", "", "the body isn't JSON"},
+	}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("rows = %q", got)
+	}
+	if v, _ := jsonAt([]byte(`{"a": {"items": [1, 2]}, "ab": 3}`), "$.a.items"); v != "2 items" {
+		t.Errorf("a container: %q", v)
+	}
+	if v, _ := jsonAt([]byte(`{"a": {"items": [1, 2]}, "ab": 3}`), "$.ab"); v != "3" {
+		t.Errorf("a sibling with a longer name: %q", v)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+)
+
+// tableHeight is how many rows of a table are visible at once.
+const tableHeight = 20
+
+// maxColumnWidth keeps one long cell from pushing every other column off screen.
+const maxColumnWidth = 30
+
+// tableView shows tabular data as aligned columns that can be sorted.
+type tableView struct {
+	title   string // What the table is of; "" for the response body.
+	columns []string
+	rows    [][]string // Every row has one cell per column.
+	widths  []int      // Display width of each column.
+	column  int        // Selected column.
+	sortBy  int        // Column the rows are sorted by, -1 for document order.
+	desc    bool       // Sort descending.
+	cursor  int        // Selected row.
+	offset  int        // First visible row.
+}
+
+// tabular reports whether a response looks like it can be shown as a
+// table: CSV or TSV, or a JSON array, which parseTable checks is of flat
+// objects. It is cheap enough to call on every redraw.
+func tabular(res response) bool {
+	mediaType, _, _ := mime.ParseMediaType(res.header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv", "application/csv", "text/tab-separated-values":
+		return true
+	}
+	return res.kind == kindJSON && bytes.HasPrefix(bytes.TrimSpace(res.jsonBody()), []byte("["))
+}
+
+// parseTable reads body into columns and rows.
+func parseTable(contentType string, kind bodyKind, body []byte) ([]string, [][]string, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "text/csv" || mediaType == "application/csv":
+		return parseDelimited(body, ',')
+	case mediaType == "text/tab-separated-values":
+		return parseDelimited(body, '\t')
+	case kind == kindJSON:
+		return parseJSONTable(body)
+	}
+	return nil, nil, errors.New("not a table")
+}
+
+// parseDelimited reads CSV or TSV whose first record holds the column names.
+func parseDelimited(body []byte, comma rune) ([]string, [][]string, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.Comma = comma
+	r.LazyQuotes = true
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, errors.New("no header row")
+	}
+	columns := records[0]
+
+	// Pad or widen ragged rows so every row has a cell per column.
+	rows := records[1:]
+	for _, row := range rows {
+		for len(columns) < len(row) {
+			columns = append(columns, fmt.Sprintf("column %d", len(columns)+1))
+		}
+	}
+	for i, row := range rows {
+		for len(row) < len(columns) {
+			row = append(row, "")
+		}
+		rows[i] = row
+	}
+	return columns, rows, nil
+}
+
+// parseJSONTable reads a JSON array of objects whose values are all
+// scalars. Columns appear in the order their keys are first seen.
+func parseJSONTable(body []byte) ([]string, [][]string, error) {
+	root, err := parseJSONTree(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !root.array || len(root.children) == 0 {
+		return nil, nil, errors.New("not an array of objects")
+	}
+
+	var columns []string
+	index := map[string]int{}
+	for _, obj := range root.children {
+		if !obj.object {
+			return nil, nil, errors.New("not an array of objects")
+		}
+		for _, field := range obj.children {
+			if field.array || field.object {
+				return nil, nil, errors.New("the objects are not flat")
+			}
+			if _, ok := index[field.key]; !ok {
+				index[field.key] = len(columns)
+				columns = append(columns, field.key)
+			}
+		}
+	}
+
+	if len(columns) == 0 {
+		return nil, nil, errors.New("no columns")
+	}
+
+	rows := make([][]string, len(root.children))
+	for i, obj := range root.children {
+		row := make([]string, len(columns))
+		for _, field := range obj.children {
+			// Strings are shown without their quotes.
+			value := field.value
+			if s, err := strconv.Unquote(value); err == nil {
+				value = s
+			}
+			row[index[field.key]] = value
+		}
+		rows[i] = row
+	}
+	return columns, rows, nil
+}
+
+// newTableView builds a table view of body.
+func newTableView(contentType string, kind bodyKind, body []byte) (*tableView, error) {
+	columns, rows, err := parseTable(contentType, kind, body)
+	if err != nil {
+		return nil, err
+	}
+	return makeTable(columns, rows), nil
+}
+
+// makeTable builds a table view of rows, each with one cell per column.
+func makeTable(columns []string, rows [][]string) *tableView {
+	t := &tableView{columns: columns, rows: rows, sortBy: -1}
+	t.widths = make([]int, len(columns))
+	for i, c := range columns {
+		t.widths[i] = runewidth.StringWidth(c) + 1 // Room for the selection mark.
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			t.widths[i] = max(t.widths[i], runewidth.StringWidth(cell))
+		}
+	}
+	for i := range t.widths {
+		t.widths[i] = min(max(t.widths[i], 2), maxColumnWidth)
+	}
+	return t
+}
+
+// compareCells orders two cells numerically when both are numbers, and
+// as text otherwise.
+func compareCells(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return cmp.Compare(x, y)
+	}
+	return cmp.Compare(a, b)
+}
+
+// sortRows sorts by the selected column, ascending first and then
+// descending when sorted by it already.
+func (t *tableView) sortRows() {
+	if len(t.columns) == 0 {
+		return
+	}
+	if t.sortBy == t.column {
+		t.desc = !t.desc
+	} else {
+		t.sortBy, t.desc = t.column, false
+	}
+	// Sort a copy so that earlier models keep their own order.
+	rows := slices.Clone(t.rows)
+	slices.SortStableFunc(rows, func(a, b []string) int {
+		c := compareCells(a[t.sortBy], b[t.sortBy])
+		if t.desc {
+			return -c
+		}
+		return c
+	})
+	t.rows = rows
+}
+
+// updateTable handles keys while the table view is open.
+func (m model) updateTable(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// Shallow-copy the table so the previous model keeps its own state.
+	copied := *m.table
+	t := &copied
+	m.table = t
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "T", "q":
+		m.table = nil
+		return m, nil
+	case "up", "k":
+		t.cursor = max(t.cursor-1, 0)
+	case "down", "j":
+		t.cursor = min(t.cursor+1, max(len(t.rows)-1, 0))
+	case "pgup":
+		t.cursor = max(t.cursor-tableHeight, 0)
+	case "pgdown":
+		t.cursor = min(t.cursor+tableHeight, max(len(t.rows)-1, 0))
+	case "g", "home":
+		t.cursor = 0
+	case "G", "end":
+		t.cursor = max(len(t.rows)-1, 0)
+	case "left", "h":
+		t.column = max(t.column-1, 0)
+	case "right", "l":
+		t.column = max(min(t.column+1, len(t.columns)-1), 0)
+	case "s":
+		t.sortRows()
+	}
+
+	// Scroll so the cursor stays in view.
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+tableHeight {
+		t.offset = t.cursor - tableHeight + 1
+	}
+	return m, nil
+}
+
+// cell pads or cuts s to width, right-aligning numbers.
+func cell(s string, width int) string {
+	s = runewidth.Truncate(strings.ReplaceAll(s, "\n", " "), width, "…")
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return runewidth.FillLeft(s, width)
+	}
+	return runewidth.FillRight(s, width)
+}
+
+// viewTable renders the columns and the visible window of rows. Columns
+// start from the selected one when they don't all fit the terminal.
+func (m model) viewTable() string {
+	t := m.table
+	first := 0
+	if m.width > 0 {
+		// Scroll columns left until the selected one fits.
+		for first < t.column {
+			used := 2
+			for i := first; i <= t.column; i++ {
+				used += t.widths[i] + 3
+			}
+			if used <= m.width {
+				break
+			}
+			first++
+		}
+	}
+
+	line := func(cells []string, mark string, header bool) string {
+		parts := make([]string, 0, len(cells)-first)
+		for i := first; i < len(cells); i++ {
+			c := cell(cells[i], t.widths[i])
+			if header && i == t.column {
+				c = runewidth.FillRight(runewidth.Truncate(cells[i], t.widths[i]-1, "…")+"*", t.widths[i])
+			}
+			parts = append(parts, c)
+		}
+		s := mark + strings.Join(parts, " │ ")
+		if m.width > 0 {
+			s = runewidth.Truncate(s, m.width, "…")
+		}
+		return s + "\n"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n%s\n\n", cmp.Or(t.title, "Table of "+m.cfg.url))
+	b.WriteString(line(t.columns, "  ", true))
+	for i := t.offset; i < min(t.offset+tableHeight, len(t.rows)); i++ {
+		mark := "  "
+		if i == t.cursor {
+			mark = "> "
+		}
+		b.WriteString(line(t.rows[i], mark, false))
+	}
+
+	order := "document order"
+	if t.sortBy >= 0 {
+		dir := "ascending"
+		if t.desc {
+			dir = "descending"
+		}
+		order = fmt.Sprintf("sorted by %s, %s", t.columns[t.sortBy], dir)
+	}
+	fmt.Fprintf(&b, "\n%d rows, %d columns, %s", len(t.rows), len(t.columns), order)
+	if len(t.rows) > 0 {
+		fmt.Fprintf(&b, " (row %d)", t.cursor+1)
+	}
+	b.WriteString("\n\n↑/↓ select row • ←/→ select column (*) • s sort by column • esc close\n")
+	return b.String()
+}
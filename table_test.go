@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestParseTable(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		kind        bodyKind
+		body        string
+		columns     []string
+		rows        [][]string
+		wantErr     bool
+	}{
+		{
+			name: "csv", contentType: "text/csv", body: "a,b\n1,2\n3,4\n",
+			columns: []string{"a", "b"}, rows: [][]string{{"1", "2"}, {"3", "4"}},
+		},
+		{
+			name: "ragged csv", contentType: "text/csv", body: "a\n1,2\n\n3\n",
+			columns: []string{"a", "column 2"}, rows: [][]string{{"1", "2"}, {"3", ""}},
+		},
+		{
+			name: "tsv", contentType: "text/tab-separated-values", body: "x\ty\n\"q\"\tz\n",
+			columns: []string{"x", "y"}, rows: [][]string{{"q", "z"}},
+		},
+		{
+			name: "json objects", contentType: "application/json", kind: kindJSON,
+			body:    `[{"id":1,"name":"ann"},{"name":"bob","ok":true}]`,
+			columns: []string{"id", "name", "ok"}, rows: [][]string{{"1", "ann", ""}, {"", "bob", "true"}},
+		},
+		{name: "empty objects", kind: kindJSON, body: `[{},{}]`, wantErr: true},
+		{name: "nested", kind: kindJSON, body: `[{"a":{"b":1}}]`, wantErr: true},
+		{name: "not objects", kind: kindJSON, body: `[1,2]`, wantErr: true},
+		{name: "empty array", kind: kindJSON, body: `[]`, wantErr: true},
+		{name: "html", contentType: "text/html", kind: kindHTML, body: "<p>", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, rows, err := parseTable(tt.contentType, tt.kind, []byte(tt.body))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseTable = %q, %q, want an error", columns, rows)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(columns, tt.columns) || !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("parseTable = %q, %q, want %q, %q", columns, rows, tt.columns, tt.rows)
+			}
+		})
+	}
+}
+
+func TestCompareCells(t *testing.T) {
+	if compareCells("9", "10") >= 0 {
+		t.Error("numbers should compare numerically: 9 < 10")
+	}
+	if compareCells("b", "a") <= 0 {
+		t.Error("text should compare as text: b > a")
+	}
+	if compareCells("10", "abc") >= 0 {
+		t.Error("mixed cells should compare as text: 10 < abc")
+	}
+}
+
+func TestTableSort(t *testing.T) {
+	table, err := newTableView("text/csv", kindText, []byte("n,s\n10,b\n9,a\n100,c\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := model{table: table}
+	key := func(k string) {
+		next, _ := m.updateTable(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		m = next.(model)
+	}
+
+	key("s")
+	if got := firstColumn(m.table); !reflect.DeepEqual(got, []string{"9", "10", "100"}) {
+		t.Errorf("ascending = %q", got)
+	}
+	key("s")
+	if got := firstColumn(m.table); !reflect.DeepEqual(got, []string{"100", "10", "9"}) {
+		t.Errorf("descending = %q", got)
+	}
+	// The earlier model keeps its own order.
+	if got := firstColumn(table); !reflect.DeepEqual(got, []string{"10", "9", "100"}) {
+		t.Errorf("original = %q", got)
+	}
+}
+
+func TestTableKeysWithoutColumns(t *testing.T) {
+	m := model{table: &tableView{sortBy: -1}}
+	for _, k := range []string{"l", "h", "s"} {
+		next, _ := m.updateTable(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		m = next.(model)
+		if m.table.column != 0 {
+			t.Errorf("after %s, column = %d, want 0", k, m.table.column)
+		}
+	}
+}
+
+// firstColumn returns the cells of a table's first column, in row order.
+func firstColumn(t *tableView) []string {
+	cells := make([]string, len(t.rows))
+	for i, row := range t.rows {
+		cells[i] = row[0]
+	}
+	return cells
+}
+
+func TestTableFailureKeepsResponse(t *testing.T) {
+	res := response{status: 200, kind: kindJSON, header: http.Header{"Content-Type": {"application/json"}}, body: []byte(`[{},{}]`)}
+	m := runKey(t, model{res: res}, "T")
+	if m.err != nil || m.table != nil {
+		t.Fatalf("err = %v, table = %v, want neither", m.err, m.table)
+	}
+	if m.report == nil || !strings.Contains(m.report.body, "no columns") {
+		t.Errorf("report = %+v", m.report)
+	}
+	if m.res.status != 200 {
+		t.Errorf("status = %d", m.res.status)
+	}
+}
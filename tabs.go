@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// app is the top-level Bubble Tea model: a row of tabs, each one a model
+// with its own request and response, of which one is shown at a time.
+type app struct {
+	tabs   []model // Open tabs, in display order.
+	active int     // Index of the tab on screen.
+	nextID int     // ID to give the next tab that is opened.
+
+	autosaved []byte // The tabs as last kept in the recovery file.
+	name      string // The named session the tabs are, "" if none.
+}
+
+// tabMsg routes a message produced by a tab's command back to that tab, even
+// if the user has switched to another tab in the meantime.
+type tabMsg struct {
+	id  int
+	msg tea.Msg
+}
+
+// newApp starts the program with a single tab.
+func newApp(first model) app {
+	first.id = 1
+	return app{tabs: []model{first}, nextID: 2}
+}
+
+// tagged wraps cmd so that its message is delivered to tab id.
+func tagged(id int, cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		return tabMsg{id: id, msg: cmd()}
+	}
+}
+
+// Init starts every tab's request. Only the first listens to the shared
+// server, for all of them.
+func (a app) Init() tea.Cmd {
+	cmds := []tea.Cmd{tagged(a.tabs[0].id, a.tabs[0].Init())}
+	for _, t := range a.tabs[1:] {
+		cmds = append(cmds, tagged(t.id, t.start()))
+	}
+	if a.tabs[0].cfg.sessionFile != "" {
+		cmds = append(cmds, autosaveTick())
+	}
+	return tea.Batch(cmds...)
+}
+
+// Update handles the tab keys itself and hands everything else to a tab.
+func (a app) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case autosaveMsg:
+		return a.autosave(), autosaveTick()
+
+	case tabMsg:
+		// Unpack what a tab's command produced and deliver it there.
+		switch inner := msg.msg.(type) {
+		case tea.QuitMsg:
+			return a, tea.Quit
+		case tea.BatchMsg:
+			cmds := make([]tea.Cmd, len(inner))
+			for i, c := range inner {
+				cmds[i] = tagged(msg.id, c)
+			}
+			return a, tea.Batch(cmds...)
+		case shareMsg:
+			return a.applyShare(msg.id, inner)
+		case sessionMsg:
+			return a.handleSession(inner)
+		}
+		for i, t := range a.tabs {
+			if t.id == msg.id {
+				return a.updateTab(i, msg.msg)
+			}
+		}
+		// The tab was closed while its request was in flight, so nobody is
+		// left to read the rest of its stream.
+		if records, ok := msg.msg.(recordsMsg); ok {
+			records.stop()
+		}
+		return a, nil
+
+	// Every tab draws at the terminal's size, not just the one on screen.
+	case tea.WindowSizeMsg:
+		for i := range a.tabs {
+			next, _ := a.updateTab(i, msg)
+			a = next.(app)
+		}
+		return a, nil
+
+	case tea.KeyMsg:
+		// Panels and prompts inside a tab get every key.
+		if a.tabs[a.active].modal() {
+			break
+		}
+		switch msg.String() {
+		// Copy the current tab, request and response alike, into a new one.
+		case "D":
+			dup := a.tabs[a.active]
+			dup.id = a.nextID
+			dup.cfg.header = dup.cfg.header.Clone()
+			// The rest of a stream keeps going to the original tab, so the
+			// copy keeps the records that have arrived so far.
+			if dup.res.streaming {
+				dup.res.streaming = false
+				dup.res.streamErr = fmt.Errorf("tab copied after %d records", len(dup.res.records))
+				dup.res.records = append([]string(nil), dup.res.records...)
+				dup.res = dup.res.finishRecords()
+				if dup.records != nil {
+					v := *dup.records
+					v.follow = false
+					dup.records = &v
+				}
+			}
+			a.nextID++
+			a.tabs = append(a.tabs[:a.active+1], append([]model{dup}, a.tabs[a.active+1:]...)...)
+			a.active++
+			// The copy's spinner needs a tick loop of its own.
+			return a, tagged(dup.id, dup.spin.Tick)
+
+		// Move between tabs.
+		case "tab":
+			a.active = (a.active + 1) % len(a.tabs)
+			return a, nil
+		case "shift+tab":
+			a.active = (a.active + len(a.tabs) - 1) % len(a.tabs)
+			return a, nil
+
+		// Close the current tab, unless it is the last one.
+		case "w":
+			if len(a.tabs) > 1 {
+				if closed := a.tabs[a.active].res; closed.streaming {
+					closed.stopStream()
+				}
+				a.tabs = append(a.tabs[:a.active], a.tabs[a.active+1:]...)
+				a.active = min(a.active, len(a.tabs)-1)
+			}
+			return a, nil
+		}
+	}
+	return a.updateTab(a.active, msg)
+}
+
+// applyShare takes a change from the shared server into every tab, and keeps
+// the environments file in step, then listens for the next one on tab id.
+// Once the connection closes, there is nothing more to listen for.
+func (a app) applyShare(id int, msg shareMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil || len(a.tabs) == 0 {
+		return a, nil
+	}
+	a.tabs = append([]model(nil), a.tabs...)
+	for i := range a.tabs {
+		a.tabs[i] = a.tabs[i].applyShare(msg.msg)
+	}
+	c := a.tabs[0].cfg.share
+	if name := docName(msg.msg); name != "" {
+		logf(logInfo, "%s changed the shared %s", msg.msg.Doc.By, name)
+	}
+	if docName(msg.msg) == envDoc && a.tabs[0].cfg.envFile != "" {
+		// Write what was kept, protections and all, not what was sent.
+		d := *msg.msg.Doc
+		d.Content, _ = json.Marshal(a.tabs[0].cfg.envs)
+		if err := writeDoc(a.tabs[0].cfg.envFile, d); err != nil {
+			logf(logWarn, "Couldn't write the shared environments to %s: %v", a.tabs[0].cfg.envFile, err)
+		}
+	}
+	return a, tagged(id, listenShare(c))
+}
+
+// updateTab passes msg to tab i and tags whatever command comes back.
+func (a app) updateTab(i int, msg tea.Msg) (tea.Model, tea.Cmd) {
+	// Copy the slice so that earlier app values keep their own tabs.
+	a.tabs = append([]model(nil), a.tabs...)
+	next, cmd := a.tabs[i].Update(msg)
+	a.tabs[i] = next.(model)
+	return a, tagged(a.tabs[i].id, cmd)
+}
+
+// View shows a tab bar when more than one tab is open, then the active tab.
+// A named session's name leads the bar.
+func (a app) View() string {
+	if len(a.tabs) == 1 && a.name == "" {
+		return a.tabs[0].View()
+	}
+	session := ""
+	if a.name != "" {
+		session = a.name + ": "
+	}
+	names := make([]string, len(a.tabs))
+	for i, t := range a.tabs {
+		names[i] = fmt.Sprintf(" %d %s %s ", i+1, t.cfg.method, t.cfg.url)
+		if i == a.active {
+			names[i] = "[" + names[i] + "]"
+		}
+	}
+	return "\n" + session + strings.Join(names, "│") + "\n" + a.tabs[a.active].View() +
+		"tab/shift+tab switch tab • w close tab\n"
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// streamingTab returns a tab whose response is still streaming, and
+// reports through stopped whether its stream was abandoned.
+func streamingTab(stopped *bool) model {
+	m := model{cfg: config{method: http.MethodGet, url: "http://example.com/", header: http.Header{}}}
+	m.res = response{
+		status:     http.StatusOK,
+		records:    []string{`{"n":1}`},
+		streaming:  true,
+		streamID:   7,
+		stopStream: func() { *stopped = true },
+	}
+	return m
+}
+
+func press(a app, k string) app {
+	next, _ := a.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+	return next.(app)
+}
+
+func TestCloseTabStopsStream(t *testing.T) {
+	var stopped bool
+	a := newApp(streamingTab(&stopped))
+	a = press(a, "D")
+	a.active = 0
+	a = press(a, "w")
+	if !stopped {
+		t.Error("closing a streaming tab left its stream running")
+	}
+	if len(a.tabs) != 1 {
+		t.Errorf("%d tabs open, want 1", len(a.tabs))
+	}
+}
+
+func TestDuplicateFinishesRecords(t *testing.T) {
+	var stopped bool
+	a := press(newApp(streamingTab(&stopped)), "D")
+	dup := a.tabs[1].res
+	if dup.streaming || dup.streamErr == nil {
+		t.Errorf("copy: streaming = %v, err = %v, want it finished with a note", dup.streaming, dup.streamErr)
+	}
+	if string(dup.body) != `{"n":1}` {
+		t.Errorf("copy body = %q", dup.body)
+	}
+	if !a.tabs[0].res.streaming || stopped {
+		t.Error("the original tab should keep streaming")
+	}
+}
+
+func TestRecordsForClosedTab(t *testing.T) {
+	var stopped bool
+	a := newApp(model{})
+	a.Update(tabMsg{id: 99, msg: recordsMsg{stream: 1, stop: func() { stopped = true }}})
+	if !stopped {
+		t.Error("records for a closed tab did not stop the stream")
+	}
+}
+
+func TestTabSwitching(t *testing.T) {
+	first := model{cfg: config{method: http.MethodGet, url: "https://a.test/", header: http.Header{"X": {"1"}}}}
+	a := press(newApp(first), "D")
+	if len(a.tabs) != 2 || a.active != 1 || a.tabs[1].id == a.tabs[0].id {
+		t.Fatalf("after D: %d tabs, active %d", len(a.tabs), a.active)
+	}
+	// The copy's headers are its own.
+	a.tabs[1].cfg.header.Set("X", "2")
+	if a.tabs[0].cfg.header.Get("X") != "1" {
+		t.Error("editing the copy's headers changed the original")
+	}
+
+	next, _ := a.Update(tea.KeyMsg{Type: tea.KeyTab})
+	a = next.(app)
+	if a.active != 0 {
+		t.Errorf("after tab, active = %d, want 0", a.active)
+	}
+	next, _ = a.Update(tea.KeyMsg{Type: tea.KeyShiftTab})
+	a = next.(app)
+	if a.active != 1 {
+		t.Errorf("after shift+tab, active = %d, want 1", a.active)
+	}
+	if view := a.View(); !strings.Contains(view, "[ 2 GET https://a.test/ ]") {
+		t.Errorf("tab bar =\n%s", view)
+	}
+
+	// The last tab can't be closed.
+	a = press(press(a, "w"), "w")
+	if len(a.tabs) != 1 {
+		t.Errorf("%d tabs left, want 1", len(a.tabs))
+	}
+}
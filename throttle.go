@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// throttle caps how fast a connection moves data, in bytes a second each
+// way, to show what a transfer takes on a slow network. Zero is no cap.
+type throttle struct {
+	down, up int64
+}
+
+// throttlePresets are the networks -throttle knows by name, at their
+// typical speeds.
+var throttlePresets = map[string]throttle{
+	"gprs":     {down: 50_000 / 8, up: 20_000 / 8},
+	"edge":     {down: 250_000 / 8, up: 50_000 / 8},
+	"3g":       {down: 750_000 / 8, up: 250_000 / 8},
+	"slow-dsl": {down: 2_000_000 / 8, up: 256_000 / 8},
+	"dsl":      {down: 8_000_000 / 8, up: 1_000_000 / 8},
+	"4g":       {down: 9_000_000 / 8, up: 9_000_000 / 8},
+}
+
+// throttlePresetNames lists the presets for the flag's help text.
+func throttlePresetNames() string {
+	names := make([]string, 0, len(throttlePresets))
+	for name := range throttlePresets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+// parseThrottle reads a -throttle value: a preset's name, or the speeds in
+// kbit/s as DOWN or DOWN/UP, e.g. 1000/500.
+func parseThrottle(v string) (throttle, error) {
+	if v == "" {
+		return throttle{}, nil
+	}
+	if t, ok := throttlePresets[strings.ToLower(v)]; ok {
+		return t, nil
+	}
+	downText, upText, both := strings.Cut(v, "/")
+	down, err := strconv.ParseInt(downText, 10, 64)
+	up := down
+	if err == nil && both {
+		up, err = strconv.ParseInt(upText, 10, 64)
+	}
+	if err != nil || down <= 0 || up <= 0 {
+		return throttle{}, fmt.Errorf("-throttle %q is neither a network (%s) nor kbit/s as DOWN or DOWN/UP", v, throttlePresetNames())
+	}
+	return throttle{down: down * 1000 / 8, up: up * 1000 / 8}, nil
+}
+
+// String describes the caps, e.g. "750 kbit/s down, 250 kbit/s up".
+func (t throttle) String() string {
+	if t == (throttle{}) {
+		return "none"
+	}
+	return fmt.Sprintf("%s down, %s up", bitRate(t.down), bitRate(t.up))
+}
+
+// bitRate writes bytes a second as kbit/s or Mbit/s.
+func bitRate(bytes int64) string {
+	bits := bytes * 8
+	if bits >= 1_000_000 {
+		return strconv.FormatFloat(float64(bits)/1_000_000, 'f', -1, 64) + " Mbit/s"
+	}
+	return strconv.FormatFloat(float64(bits)/1000, 'f', -1, 64) + " kbit/s"
+}
+
+// throttledDialer wraps dial so that its connections move data no faster
+// than t allows.
+func throttledDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error), t throttle) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &throttledConn{Conn: c, read: pacer{rate: t.down}, write: pacer{rate: t.up}}, nil
+	}
+}
+
+// throttledConn is a connection that paces its reads and writes.
+type throttledConn struct {
+	net.Conn
+	read, write pacer
+}
+
+// Read reads no more than a moment's worth of data, then waits for as long
+// as that data would take at the cap.
+func (c *throttledConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p[:min(len(p), c.read.chunk())])
+	c.read.wait(n)
+	return n, err
+}
+
+// Write writes p a moment's worth at a time, at the cap.
+func (c *throttledConn) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		n, err := c.Conn.Write(p[written:min(len(p), written+c.write.chunk())])
+		written += n
+		c.write.wait(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// pacer keeps one direction of a connection to its rate, by owing time for
+// every byte that passes and sleeping the debt off.
+type pacer struct {
+	mu   sync.Mutex
+	rate int64     // Bytes a second; 0 for no cap.
+	next time.Time // When the bytes so far would have passed at the rate.
+}
+
+// chunk is how many bytes to move at once: a twentieth of a second's worth,
+// so that the pace is smooth.
+func (p *pacer) chunk() int {
+	if p.rate == 0 {
+		return 1 << 30
+	}
+	return int(max(1, p.rate/20))
+}
+
+// wait sleeps for as long as n more bytes take at the rate.
+func (p *pacer) wait(n int) {
+	if p.rate == 0 || n == 0 {
+		return
+	}
+	p.mu.Lock()
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	p.next = p.next.Add(time.Duration(int64(n) * int64(time.Second) / p.rate))
+	until := p.next
+	p.mu.Unlock()
+	time.Sleep(time.Until(until))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseThrottle(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want throttle
+	}{
+		{"", throttle{}},
+		{"3G", throttle{down: 93750, up: 31250}},
+		{"800", throttle{down: 100_000, up: 100_000}},
+		{"800/80", throttle{down: 100_000, up: 10_000}},
+	} {
+		if got, err := parseThrottle(tt.in); err != nil || got != tt.want {
+			t.Errorf("parseThrottle(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"dialup", "0", "10/x", "-5"} {
+		if _, err := parseThrottle(bad); err == nil {
+			t.Errorf("parseThrottle(%q) took it", bad)
+		}
+	}
+	if got := throttlePresets["slow-dsl"].String(); got != "2 Mbit/s down, 256 kbit/s up" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestThrottledTransfer(t *testing.T) {
+	body := strings.Repeat("x", 20_000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	// 100 kB/s down moves the 20 kB body in about 200ms.
+	c := newClient(config{transport: transportOptions{throttle: throttle{down: 100_000, up: 100_000}}})
+	start := time.Now()
+	_, got, err := fetch(c, srv.URL)
+	if err != nil || string(got) != body {
+		t.Fatalf("fetch: %d bytes, %v", len(got), err)
+	}
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("took %s, want about 200ms", elapsed)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeline is what the transport did for a request, step by step, from
+// looking up the host to the last byte of the answer: why a request was
+// slow is usually plain from what it was waiting on.
+type timeline struct {
+	mu     sync.Mutex
+	start  time.Time
+	events []traceEvent
+}
+
+// traceEvent is one step of a timeline, at how long after the start.
+type traceEvent struct {
+	at   time.Duration
+	what string
+}
+
+// newTimeline starts a timeline now.
+func newTimeline() *timeline {
+	return &timeline{start: time.Now()}
+}
+
+// add notes that what happened just now.
+func (t *timeline) add(format string, args ...any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, traceEvent{time.Since(t.start), fmt.Sprintf(format, args...)})
+}
+
+// trace returns the hooks that fill in t.
+func (t *timeline) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(hostPort string) { t.add("Getting a connection to %s", hostPort) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			switch {
+			case info.Reused && info.WasIdle:
+				t.add("Reusing the connection to %s, idle for %s", remoteAddr(info.Conn), info.IdleTime.Round(time.Millisecond))
+			case info.Reused:
+				t.add("Reusing the connection to %s", remoteAddr(info.Conn))
+			default:
+				t.add("Got a new connection to %s", remoteAddr(info.Conn))
+			}
+		},
+		DNSStart: func(info httptrace.DNSStartInfo) { t.add("Looking up %s", info.Host) },
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if info.Err != nil {
+				t.add("DNS failed: %v", info.Err)
+				return
+			}
+			addrs := make([]string, len(info.Addrs))
+			for i, a := range info.Addrs {
+				addrs[i] = a.String()
+			}
+			t.add("DNS answered %s", strings.Join(addrs, ", "))
+		},
+		ConnectStart: func(network, addr string) { t.add("Connecting to %s over %s", addr, network) },
+		ConnectDone: func(network, addr string, err error) {
+			if err != nil {
+				t.add("Couldn't connect to %s: %v", addr, err)
+				return
+			}
+			t.add("Connected to %s", addr)
+		},
+		TLSHandshakeStart: func() { t.add("TLS handshake") },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err != nil {
+				t.add("TLS handshake failed: %v", err)
+				return
+			}
+			t.add("TLS handshake done: %s", describeTLS(state))
+		},
+		WroteHeaders:    func() { t.add("Wrote the headers") },
+		Wait100Continue: func() { t.add("Waiting for 100 Continue") },
+		Got100Continue:  func() { t.add("Got 100 Continue") },
+		Got1xxResponse: func(code int, _ textproto.MIMEHeader) error {
+			if code != 100 {
+				t.add("Got an interim %d", code)
+			}
+			return nil
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err != nil {
+				t.add("Couldn't write the request: %v", info.Err)
+				return
+			}
+			t.add("Wrote the request")
+		},
+		GotFirstResponseByte: func() { t.add("First byte of the answer") },
+	}
+}
+
+// describeTLS sums up a finished handshake, e.g. "TLS 1.3,
+// TLS_AES_128_GCM_SHA256, ALPN h2, certificate for example.com".
+func describeTLS(state tls.ConnectionState) string {
+	parts := []string{tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite)}
+	if state.NegotiatedProtocol != "" {
+		parts = append(parts, "ALPN "+state.NegotiatedProtocol)
+	}
+	if state.DidResume {
+		parts = append(parts, "resumed")
+	}
+	if len(state.PeerCertificates) > 0 {
+		parts = append(parts, "certificate for "+state.PeerCertificates[0].Subject.CommonName)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// report lists the timeline, each step with when it happened and how long
+// after the one before.
+func (t *timeline) report() reportMsg {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var b strings.Builder
+	var last time.Duration
+	for _, e := range t.events {
+		fmt.Fprintf(&b, "%9s  %9s  %s\n", roundEvent(e.at), "+"+roundEvent(e.at-last), e.what)
+		last = e.at
+	}
+	return reportMsg{title: "Timeline", body: strings.TrimRight(b.String(), "\n")}
+}
+
+// roundEvent rounds the time of an event to something worth reading.
+func roundEvent(d time.Duration) string {
+	return d.Round(100 * time.Microsecond).String()
+}
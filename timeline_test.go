@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTimeline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("hello")) }))
+	defer srv.Close()
+
+	res, ok := send(config{method: "GET", url: srv.URL, header: http.Header{}}).(responseMsg)
+	if !ok || res.timeline == nil {
+		t.Fatalf("send = %#v", res)
+	}
+	addr := strings.TrimPrefix(srv.URL, "http://")
+	report := res.timeline.report().body
+	lines := strings.Split(report, "\n")
+	want := []string{
+		"Getting a connection to " + addr,
+		"Connecting to " + addr + " over tcp",
+		"Connected to " + addr,
+		"Got a new connection to " + addr,
+		"Wrote the headers",
+		"Wrote the request",
+		"First byte of the answer",
+		"End of the body, 5 bytes",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("timeline:\n%s", report)
+	}
+	for i, w := range want {
+		if !strings.HasSuffix(lines[i], "  "+w) || !strings.Contains(lines[i], "+") {
+			t.Errorf("step %d = %q, want %q", i, lines[i], w)
+		}
+	}
+}
+
+func TestDescribeTLS(t *testing.T) {
+	state := tls.ConnectionState{
+		Version:            tls.VersionTLS13,
+		CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+		NegotiatedProtocol: "h2",
+		PeerCertificates:   []*x509.Certificate{{Subject: pkix.Name{CommonName: "example.com"}}},
+	}
+	if got := describeTLS(state); got != "TLS 1.3, TLS_AES_128_GCM_SHA256, ALPN h2, certificate for example.com" {
+		t.Errorf("describeTLS = %q", got)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"cmp"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/crypto/ocsp"
+)
+
+// tlsVersions are the protocol versions a scan tries, oldest first. SSL 3.0
+// is left out; Go can't speak it, and neither should anyone else.
+var tlsVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+// tlsScanTimeout is how long one handshake of a scan may take.
+const tlsScanTimeout = 5 * time.Second
+
+// certExpiryWarning is how close to its expiry a certificate gets a warning.
+const certExpiryWarning = 30 * 24 * time.Hour
+
+// tlsScan is what a scan found out about a server's TLS.
+type tlsScan struct {
+	versions []uint16 // Protocol versions the server accepted.
+	suites   []uint16 // TLS 1.0-1.2 cipher suites it accepted.
+	suite13  uint16   // The TLS 1.3 suite it chose, if it speaks 1.3.
+
+	chain     []*x509.Certificate // As the server sent it, leaf first.
+	verifyErr error               // Why the chain didn't verify, nil if it did.
+	ocsp      string              // What the stapled OCSP response says, "" if none was stapled.
+}
+
+// runTLSScan is the palette's `tls [port]`.
+func runTLSScan(m model, args []string) (tea.Model, tea.Cmd) {
+	host, port, err := hostPort(m.cfg.url)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	if len(args) > 0 {
+		port = args[0]
+	}
+	addr := net.JoinHostPort(host, port)
+	m.job = "Scanning the TLS of " + addr
+	return m, runJob(func(report func(string)) tea.Msg {
+		scan, err := scanTLS(addr, host, report)
+		if err != nil {
+			return reportMsg{title: "TLS scan " + addr, body: err.Error()}
+		}
+		return reportMsg{title: "TLS scan " + addr, body: scan.describe(time.Now())}
+	})
+}
+
+// scanTLS shakes hands with addr to check the certificate chain against
+// serverName, the way a browser would, and read any stapled OCSP response;
+// then once per protocol version and cipher suite, to learn which it accepts.
+func scanTLS(addr, serverName string, report func(string)) (*tlsScan, error) {
+	var scan tlsScan
+	handshake := func(cfg *tls.Config) (tls.ConnectionState, error) {
+		cfg.ServerName = serverName
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: tlsScanTimeout}, "tcp", addr, cfg)
+		if err != nil {
+			return tls.ConnectionState{}, err
+		}
+		defer conn.Close()
+		return conn.ConnectionState(), nil
+	}
+
+	// The chain is verified by hand, so that a bad certificate still lets
+	// the rest of the scan go ahead. If there is no handshake at all, there
+	// is nothing more to learn.
+	report("Checking the certificate chain")
+	state, err := handshake(&tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, fmt.Errorf("no TLS handshake with %s: %w", addr, err)
+	}
+	scan.chain = state.PeerCertificates
+	scan.verifyErr = verifyChain(state.PeerCertificates, serverName)
+	if len(state.OCSPResponse) > 0 {
+		scan.ocsp = describeOCSP(state.OCSPResponse, state.PeerCertificates)
+	}
+
+	for _, v := range tlsVersions {
+		report("Trying " + tls.VersionName(v))
+		state, err := handshake(&tls.Config{InsecureSkipVerify: true, MinVersion: v, MaxVersion: v})
+		if err != nil {
+			continue
+		}
+		scan.versions = append(scan.versions, v)
+		if v == tls.VersionTLS13 {
+			scan.suite13 = state.CipherSuite
+		}
+	}
+
+	// Go only lets us choose the suites of TLS 1.2 and older.
+	var suites []*tls.CipherSuite
+	suites = append(suites, tls.CipherSuites()...)
+	suites = append(suites, tls.InsecureCipherSuites()...)
+	for _, s := range suites {
+		var versions []uint16
+		for _, v := range s.SupportedVersions {
+			if v < tls.VersionTLS13 && slices.Contains(scan.versions, v) {
+				versions = append(versions, v)
+			}
+		}
+		if len(versions) == 0 {
+			continue
+		}
+		report("Trying " + s.Name)
+		cfg := &tls.Config{InsecureSkipVerify: true, MinVersion: slices.Min(versions), MaxVersion: slices.Max(versions), CipherSuites: []uint16{s.ID}}
+		if _, err := handshake(cfg); err == nil {
+			scan.suites = append(scan.suites, s.ID)
+		}
+	}
+	return &scan, nil
+}
+
+// verifyChain checks chain the way a browser would, against the system's
+// roots, for serverName.
+func verifyChain(chain []*x509.Certificate, serverName string) error {
+	if len(chain) == 0 {
+		return fmt.Errorf("the server sent no certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{DNSName: serverName, Intermediates: intermediates})
+	return err
+}
+
+// describeOCSP reads a stapled OCSP response for the leaf of chain.
+func describeOCSP(raw []byte, chain []*x509.Certificate) string {
+	var issuer *x509.Certificate
+	if len(chain) > 1 {
+		issuer = chain[1]
+	}
+	res, err := ocsp.ParseResponseForCert(raw, chain[0], issuer)
+	if err != nil {
+		return "stapled, but unreadable: " + err.Error()
+	}
+	status := map[int]string{ocsp.Good: "good", ocsp.Revoked: "REVOKED", ocsp.Unknown: "unknown"}[res.Status]
+	return fmt.Sprintf("stapled, %s, next update %s", status, res.NextUpdate.Format(time.DateOnly))
+}
+
+// weakSuite reports whether a suite is one Go calls insecure, or one
+// without forward secrecy.
+func weakSuite(id uint16) bool {
+	for _, s := range tls.InsecureCipherSuites() {
+		if s.ID == id {
+			return true
+		}
+	}
+	return strings.HasPrefix(tls.CipherSuiteName(id), "TLS_RSA_")
+}
+
+// grade sums the scan up the way SSL Labs does, if more roughly:
+//
+//	F  the certificate doesn't verify
+//	C  TLS 1.0 or 1.1 is still accepted
+//	B  an insecure suite, or one without forward secrecy, is accepted
+//	A  none of the above
+//	A+ and there is TLS 1.3 and a stapled OCSP response
+//
+// It returns the grade and the reasons it isn't better.
+func (s *tlsScan) grade() (string, []string) {
+	var reasons []string
+	if s.verifyErr != nil {
+		return "F", []string{"the certificate doesn't verify: " + s.verifyErr.Error()}
+	}
+	letter := "A"
+	var old []string
+	for _, v := range s.versions {
+		if v < tls.VersionTLS12 {
+			old = append(old, tls.VersionName(v))
+		}
+	}
+	if len(old) > 0 {
+		letter = "C"
+		reasons = append(reasons, "accepts "+strings.Join(old, " and ")+", which browsers have dropped")
+	}
+	var weak []string
+	for _, id := range s.suites {
+		if weakSuite(id) {
+			weak = append(weak, tls.CipherSuiteName(id))
+		}
+	}
+	if len(weak) > 0 {
+		if letter == "A" {
+			letter = "B"
+		}
+		reasons = append(reasons, "accepts weak suites: "+strings.Join(weak, ", "))
+	}
+	if letter != "A" {
+		return letter, reasons
+	}
+	if !slices.Contains(s.versions, tls.VersionTLS13) {
+		reasons = append(reasons, "no TLS 1.3")
+	}
+	if s.ocsp == "" {
+		reasons = append(reasons, "no stapled OCSP response")
+	}
+	if len(reasons) == 0 {
+		return "A+", nil
+	}
+	return "A", reasons
+}
+
+// describe writes the scan up, with the grade first.
+func (s *tlsScan) describe(now time.Time) string {
+	var b strings.Builder
+	letter, reasons := s.grade()
+	fmt.Fprintf(&b, "Grade: %s", letter)
+	for _, r := range reasons {
+		b.WriteString("\n  - " + r)
+	}
+
+	names := make([]string, len(s.versions))
+	for i, v := range s.versions {
+		names[i] = tls.VersionName(v)
+	}
+	fmt.Fprintf(&b, "\n\nProtocols: %s", cmp.Or(strings.Join(names, ", "), "none that Go speaks"))
+	if s.suite13 != 0 {
+		fmt.Fprintf(&b, "\nTLS 1.3 suite: %s", tls.CipherSuiteName(s.suite13))
+	}
+	b.WriteString("\nSuites (TLS 1.2 and older):")
+	if len(s.suites) == 0 {
+		b.WriteString(" none")
+	}
+	for _, id := range s.suites {
+		mark := "  "
+		if weakSuite(id) {
+			mark = "✗ "
+		}
+		b.WriteString("\n  " + mark + tls.CipherSuiteName(id))
+	}
+
+	b.WriteString("\n\nCertificate chain:")
+	for i, c := range s.chain {
+		fmt.Fprintf(&b, "\n  %d %s\n    issued by %s, valid until %s", i, c.Subject.CommonName, c.Issuer.CommonName, c.NotAfter.Format(time.DateOnly))
+		if left := c.NotAfter.Sub(now); left < 0 {
+			b.WriteString(" (EXPIRED)")
+		} else if left < certExpiryWarning {
+			fmt.Fprintf(&b, " (expires in %d days)", int(left.Hours()/24))
+		}
+	}
+	if s.verifyErr != nil {
+		b.WriteString("\n  ✗ " + s.verifyErr.Error())
+	} else {
+		b.WriteString("\n  ✓ verifies against the system's roots")
+	}
+	fmt.Fprintf(&b, "\nOCSP: %s", cmp.Or(s.ocsp, "not stapled"))
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanTLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_128_GCM_SHA256},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	scan, err := scanTLS(srv.Listener.Addr().String(), "example.com", func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(scan.versions, []uint16{tls.VersionTLS12, tls.VersionTLS13}) {
+		t.Errorf("versions = %v", scan.versions)
+	}
+	if !slices.Equal(scan.suites, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_128_GCM_SHA256}) {
+		t.Errorf("suites = %v", scan.suites)
+	}
+	if scan.suite13 == 0 || len(scan.chain) == 0 {
+		t.Errorf("suite13 = %x, chain of %d", scan.suite13, len(scan.chain))
+	}
+	// httptest's certificate is self-signed.
+	if letter, _ := scan.grade(); letter != "F" || scan.verifyErr == nil {
+		t.Errorf("grade = %s, verifyErr = %v", letter, scan.verifyErr)
+	}
+}
+
+func TestScanTLSWithoutTLS(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	if _, err := scanTLS(srv.Listener.Addr().String(), "127.0.0.1", func(string) {}); err == nil || !strings.Contains(err.Error(), "no TLS handshake") {
+		t.Errorf("scan of plain HTTP: %v", err)
+	}
+}
+
+func TestTLSGrade(t *testing.T) {
+	strong := tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+	tests := []struct {
+		name   string
+		scan   tlsScan
+		letter string
+		reason string
+	}{
+		{"bad certificate", tlsScan{verifyErr: errors.New("x509: expired")}, "F", "doesn't verify"},
+		{"old protocols", tlsScan{versions: []uint16{tls.VersionTLS10, tls.VersionTLS12}, suites: []uint16{strong}}, "C", "accepts TLS 1.0"},
+		{"no forward secrecy", tlsScan{versions: []uint16{tls.VersionTLS12}, suites: []uint16{tls.TLS_RSA_WITH_AES_128_GCM_SHA256}}, "B", "weak suites: TLS_RSA_WITH_AES_128_GCM_SHA256"},
+		{"insecure suite", tlsScan{versions: []uint16{tls.VersionTLS12}, suites: []uint16{tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA}}, "B", "RC4"},
+		{"good", tlsScan{versions: []uint16{tls.VersionTLS12}, suites: []uint16{strong}}, "A", "no TLS 1.3"},
+		{"best", tlsScan{versions: []uint16{tls.VersionTLS12, tls.VersionTLS13}, suites: []uint16{strong}, ocsp: "stapled, good"}, "A+", ""},
+	}
+	for _, tt := range tests {
+		letter, reasons := tt.scan.grade()
+		if letter != tt.letter || !strings.Contains(strings.Join(reasons, "\n"), tt.reason) {
+			t.Errorf("%s: grade = %s %q, want %s with %q", tt.name, letter, reasons, tt.letter, tt.reason)
+		}
+	}
+}
+
+func TestTLSDescribeExpiry(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	cert := srv.Certificate()
+	scan := tlsScan{chain: []*x509.Certificate{cert}}
+
+	if out := scan.describe(cert.NotAfter.Add(time.Hour)); !strings.Contains(out, "(EXPIRED)") {
+		t.Errorf("expired certificate:\n%s", out)
+	}
+	if out := scan.describe(cert.NotAfter.Add(-10 * 24 * time.Hour)); !strings.Contains(out, "(expires in 10 days)") {
+		t.Errorf("expiring certificate:\n%s", out)
+	}
+	out := scan.describe(cert.NotBefore)
+	for _, want := range []string{"Grade: A", "Protocols: none that Go speaks", "OCSP: not stapled", "✓ verifies"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("describe lacks %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// traceMaxHops is how far a trace goes unless told otherwise.
+const traceMaxHops = 30
+
+// tracePort is the first UDP port probed, as by traceroute(8); each probe
+// goes to the next one, so that the answers can be told apart.
+const tracePort = 33434
+
+// traceProbes is how many probes are sent with each TTL.
+const traceProbes = 3
+
+// traceWait is how long to wait for the answer to one probe.
+const traceWait = time.Second
+
+// timeoutsBeforeTrace is how many timeouts in a row make the error screen
+// suggest a trace.
+const timeoutsBeforeTrace = 2
+
+// timeoutError says that a request got no complete answer in time.
+type timeoutError struct{ after time.Duration }
+
+func (e timeoutError) Error() string { return fmt.Sprintf("no complete answer within %s", e.after) }
+
+// Timeout marks the error as a timeout, like the net package's.
+func (e timeoutError) Timeout() bool { return true }
+
+// isTimeout reports whether err is a request or network timeout.
+func isTimeout(err error) bool {
+	var t interface{ Timeout() bool }
+	return errors.As(err, &t) && t.Timeout()
+}
+
+// traceHop is what came back for the probes with one TTL.
+type traceHop struct {
+	ttl     int
+	from    net.IP          // Who answered; nil if nobody did.
+	rtts    []time.Duration // One per answered probe.
+	arrived bool            // The answer came from the target itself.
+}
+
+// runTrace is the palette's `trace [max-hops]`.
+func runTrace(m model, args []string) (tea.Model, tea.Cmd) {
+	hops := traceMaxHops
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > 64 {
+			return m.paletteError(fmt.Errorf("%q is not a hop count from 1 to 64", args[0]))
+		}
+		hops = n
+	}
+	host, _, err := hostPort(m.cfg.url)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	m.job = "Tracing the path to " + host
+	return m, runJob(func(report func(string)) tea.Msg {
+		path, err := traceroute(host, hops, report)
+		if err != nil {
+			return reportMsg{title: "Trace to " + host, body: err.Error()}
+		}
+		return reportMsg{title: "Trace to " + host, body: formatTrace(path)}
+	})
+}
+
+// traceroute finds the routers on the way to host, the way traceroute(8)
+// does: UDP probes are sent with growing TTLs, and each router that drops
+// one for having run out of hops says so over ICMP. The target itself
+// answers that the port is closed. Reading ICMP takes a raw socket, which
+// needs privileges; without them traceroute says so.
+func traceroute(host string, maxHops int, report func(string)) ([]traceHop, error) {
+	ip, err := net.ResolveIPAddr("ip", host)
+	if err != nil {
+		return nil, err
+	}
+	v4 := ip.IP.To4() != nil
+	icmpNet, udpNet, proto := "ip6:ipv6-icmp", "udp6", 58
+	if v4 {
+		icmpNet, udpNet, proto = "ip4:icmp", "udp4", 1
+	}
+
+	listener, err := icmp.ListenPacket(icmpNet, "")
+	if err != nil {
+		return nil, fmt.Errorf("tracing needs a raw ICMP socket, which isn't permitted here (%w); try the port command, or run traceroute -T as root", err)
+	}
+	defer listener.Close()
+	sender, err := net.ListenPacket(udpNet, "")
+	if err != nil {
+		return nil, err
+	}
+	defer sender.Close()
+	setTTL := ipv6.NewPacketConn(sender).SetHopLimit
+	if v4 {
+		setTTL = ipv4.NewPacketConn(sender).SetTTL
+	}
+
+	var path []traceHop
+	port := tracePort
+	for ttl := 1; ttl <= maxHops; ttl++ {
+		report(fmt.Sprintf("Tracing the path to %s: hop %d", ip, ttl))
+		hop := traceHop{ttl: ttl}
+		if err := setTTL(ttl); err != nil {
+			return path, err
+		}
+		for range traceProbes {
+			port++
+			start := time.Now()
+			if _, err := sender.WriteTo([]byte("HTTPWizardTUI"), &net.UDPAddr{IP: ip.IP, Port: port, Zone: ip.Zone}); err != nil {
+				return path, err
+			}
+			from, arrived, ok := awaitHop(listener, proto, port, start.Add(traceWait))
+			if !ok {
+				continue
+			}
+			hop.from, hop.arrived = from, hop.arrived || arrived
+			hop.rtts = append(hop.rtts, time.Since(start))
+		}
+		path = append(path, hop)
+		if hop.arrived {
+			break
+		}
+	}
+	return path, nil
+}
+
+// awaitHop reads ICMP until the answer to the probe sent to port arrives,
+// or deadline passes. It returns who answered, and whether that was the
+// target saying the port is unreachable rather than a router on the way.
+func awaitHop(conn *icmp.PacketConn, proto, port int, deadline time.Time) (net.IP, bool, bool) {
+	conn.SetReadDeadline(deadline)
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			return nil, false, false
+		}
+		msg, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+		var data []byte
+		var arrived bool
+		switch body := msg.Body.(type) {
+		case *icmp.TimeExceeded:
+			data = body.Data
+		case *icmp.DstUnreach:
+			data, arrived = body.Data, true
+		default:
+			continue
+		}
+		if probePort(data, proto == 1) != port {
+			continue // The answer to some other probe, or someone else's.
+		}
+		from, _ := peer.(*net.IPAddr)
+		if from == nil {
+			return nil, arrived, true
+		}
+		return from.IP, arrived, true
+	}
+}
+
+// probePort returns the UDP destination port of the packet an ICMP error
+// quotes: its IP header, then at least the first 8 bytes of its payload.
+func probePort(quoted []byte, v4 bool) int {
+	offset := 40 // IPv6 headers are of fixed size.
+	if v4 {
+		if len(quoted) == 0 {
+			return -1
+		}
+		offset = int(quoted[0]&0x0f) * 4
+	}
+	if len(quoted) < offset+4 {
+		return -1
+	}
+	return int(quoted[offset+2])<<8 | int(quoted[offset+3])
+}
+
+// formatTrace lays a path out one hop per line, ending with where it stopped.
+func formatTrace(path []traceHop) string {
+	var b strings.Builder
+	for _, hop := range path {
+		fmt.Fprintf(&b, "%2d  ", hop.ttl)
+		if hop.from == nil {
+			b.WriteString("*\n")
+			continue
+		}
+		b.WriteString(hop.from.String())
+		if names, err := net.LookupAddr(hop.from.String()); err == nil && len(names) > 0 {
+			b.WriteString(" (" + strings.TrimSuffix(names[0], ".") + ")")
+		}
+		for _, rtt := range hop.rtts {
+			b.WriteString("  " + rtt.Round(time.Microsecond).String())
+		}
+		for range traceProbes - len(hop.rtts) {
+			b.WriteString("  *")
+		}
+		b.WriteString("\n")
+	}
+	switch {
+	case len(path) == 0:
+	case path[len(path)-1].arrived:
+		fmt.Fprintf(&b, "\nReached the host in %d hops.", len(path))
+	default:
+		last := 0
+		for _, hop := range path {
+			if hop.from != nil {
+				last = hop.ttl
+			}
+		}
+		if last == 0 {
+			b.WriteString("\nNothing answered; the first router already drops the probes, or ICMP is filtered.")
+		} else {
+			fmt.Fprintf(&b, "\nThe path goes dark after hop %d; packets are dropped there or just beyond.", last)
+		}
+	}
+	return strings.TrimPrefix(b.String(), "\n")
+}
+
+// traceHint is what the error screen says about a timeout that keeps
+// happening, or "" while it hasn't.
+func traceHint(timeouts int) string {
+	if timeouts < timeoutsBeforeTrace {
+		return ""
+	}
+	return fmt.Sprintf("It has timed out %d times in a row; : trace shows where on the way the packets stop.", timeouts)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbePort(t *testing.T) {
+	// An IPv4 header with options (24 bytes), then a UDP header to 33440.
+	v4 := make([]byte, 32)
+	v4[0] = 0x46
+	v4[26], v4[27] = 0x82, 0xa0
+	if got := probePort(v4, true); got != 33440 {
+		t.Errorf("IPv4 port = %d", got)
+	}
+	v6 := make([]byte, 48)
+	v6[42], v6[43] = 0x82, 0x9b
+	if got := probePort(v6, false); got != 33435 {
+		t.Errorf("IPv6 port = %d", got)
+	}
+	if got := probePort(v4[:25], true); got != -1 {
+		t.Errorf("cut-off quote = %d, want -1", got)
+	}
+}
+
+func TestFormatTrace(t *testing.T) {
+	ms := time.Millisecond
+	out := formatTrace([]traceHop{
+		{ttl: 1, from: net.IPv4(192, 0, 2, 1), rtts: []time.Duration{ms, 2 * ms}},
+		{ttl: 2},
+		{ttl: 3, from: net.IPv4(192, 0, 2, 9), rtts: []time.Duration{3 * ms, 3 * ms, 4 * ms}, arrived: true},
+	})
+	for _, want := range []string{" 1  192.0.2.1", "  1ms  2ms  *\n", " 2  *\n", "  3ms  3ms  4ms\n", "Reached the host in 3 hops."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("trace lacks %q:\n%s", want, out)
+		}
+	}
+
+	out = formatTrace([]traceHop{{ttl: 1, from: net.IPv4(192, 0, 2, 1), rtts: []time.Duration{ms}}, {ttl: 2}, {ttl: 3}})
+	if !strings.Contains(out, "goes dark after hop 1") {
+		t.Errorf("a trace that stops:\n%s", out)
+	}
+	if out := formatTrace([]traceHop{{ttl: 1}}); !strings.Contains(out, "Nothing answered") {
+		t.Errorf("a trace without answers:\n%s", out)
+	}
+}
+
+func TestIsTimeout(t *testing.T) {
+	if !isTimeout(timeoutError{time.Second}) || !isTimeout(fmt.Errorf("get: %w", context.DeadlineExceeded)) {
+		t.Error("timeouts weren't recognised")
+	}
+	if isTimeout(errors.New("connection refused")) {
+		t.Error("a refusal counted as a timeout")
+	}
+}
+
+func TestTimeoutsSuggestTrace(t *testing.T) {
+	m := model{}
+	for range timeoutsBeforeTrace {
+		next, _ := m.Update(errMsg{timeoutError{10 * time.Second}})
+		m = next.(model)
+	}
+	if !strings.Contains(m.View(), "timed out 2 times in a row; : trace") {
+		t.Errorf("view:\n%s", m.View())
+	}
+	next, _ := m.Update(errMsg{errors.New("connection refused")})
+	if m = next.(model); m.timeouts != 0 || strings.Contains(m.View(), ": trace") {
+		t.Errorf("another error kept the count at %d", m.timeouts)
+	}
+}
+
+func TestTracerouteLoopback(t *testing.T) {
+	path, err := traceroute("127.0.0.1", 3, func(string) {})
+	if err != nil {
+		if strings.Contains(err.Error(), "isn't permitted") {
+			t.Skip(err)
+		}
+		t.Fatal(err)
+	}
+	if len(path) != 1 || !path[0].arrived || !path[0].from.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("path = %+v, want the host at hop 1", path)
+	}
+}
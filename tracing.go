@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// traceparentRe matches a W3C traceparent header, capturing the trace ID
+// and the parent span ID.
+var traceparentRe = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+
+// traceIDHeaders are the response headers servers and proxies report a
+// trace ID in, besides the W3C traceresponse: Zipkin's B3, AWS X-Ray's and
+// the common X-Trace-Id.
+var traceIDHeaders = []string{"X-Trace-Id", "X-B3-TraceId", "X-Amzn-Trace-Id"}
+
+// otlpTimeout bounds how long exporting a span may take.
+const otlpTimeout = 5 * time.Second
+
+// tracing starts a W3C trace for each request: it sends a traceparent
+// header naming a new trace and a span for the request, and afterwards
+// notes the trace ID, any the server sent back, and where to look the
+// trace up. With an OTLP endpoint, it also exports the request's span, so
+// that the trace starts at the client.
+type tracing struct {
+	endpoint string // OTLP/HTTP collector, e.g. http://localhost:4318; "" to export nothing.
+	link     string // URL of a trace in the tracing UI, with {trace} for its ID; "" for none.
+	start    time.Time
+}
+
+// newTracing is the traceparent middleware for cfg.
+func newTracing(cfg config) middleware {
+	return &tracing{endpoint: cfg.otlpEndpoint, link: cfg.traceLink}
+}
+
+func (t *tracing) name() string { return "traceparent" }
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (t *tracing) onRequest(req *http.Request, body []byte) ([]byte, error) {
+	// A traceparent given with -H names the trace to join; the request is
+	// a new span in it all the same.
+	trace, flags := randomHex(16), "01"
+	if given := req.Header.Get("Traceparent"); traceparentRe.MatchString(given) {
+		trace, flags = given[3:35], given[53:]
+	}
+	req.Header.Set("Traceparent", "00-"+trace+"-"+randomHex(8)+"-"+flags)
+	t.start = time.Now()
+	return body, nil
+}
+
+func (t *tracing) onResponse(res *http.Response, body []byte) ([]string, error) {
+	m := traceparentRe.FindStringSubmatch(res.Request.Header.Get("Traceparent"))
+	if m == nil {
+		return nil, fmt.Errorf("a later plugin replaced the traceparent header")
+	}
+	trace, span := m[1], m[2]
+	note := "trace " + trace
+	if t.link != "" {
+		note += " " + strings.ReplaceAll(t.link, "{trace}", trace)
+	}
+	notes := []string{note}
+	if back := returnedTraceID(res.Header); back != "" && back != trace {
+		notes = append(notes, "the server reports trace "+back)
+	}
+	if t.endpoint != "" {
+		if err := exportSpan(t.endpoint, trace, span, t.start, time.Now(), res); err != nil {
+			return append(notes, "exporting the span failed: "+err.Error()), nil
+		}
+		notes = append(notes, "span exported to "+t.endpoint)
+	}
+	return notes, nil
+}
+
+// returnedTraceID is the trace ID a response reports, if any.
+func returnedTraceID(h http.Header) string {
+	if m := traceparentRe.FindStringSubmatch(h.Get("Traceresponse")); m != nil {
+		return m[1]
+	}
+	for _, name := range traceIDHeaders {
+		if v := h.Get(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// exportSpan sends a request's client span to an OTLP/HTTP collector, in
+// the protocol's JSON encoding.
+func exportSpan(endpoint, trace, span string, start, end time.Time, res *http.Response) error {
+	attr := func(key string, value map[string]any) map[string]any {
+		return map[string]any{"key": key, "value": value}
+	}
+	str := func(s string) map[string]any { return map[string]any{"stringValue": s} }
+	// Only answered requests get here, so only a server error fails the span.
+	status := map[string]any{"code": 1} // Ok.
+	if res.StatusCode >= 500 {
+		status = map[string]any{"code": 2, "message": res.Status}
+	}
+	payload := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": []any{attr("service.name", str("httpwizard"))}},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "httpwizard"},
+				"spans": []any{map[string]any{
+					"traceId":           trace,
+					"spanId":            span,
+					"name":              res.Request.Method,
+					"kind":              3, // Client.
+					"startTimeUnixNano": strconv.FormatInt(start.UnixNano(), 10),
+					"endTimeUnixNano":   strconv.FormatInt(end.UnixNano(), 10),
+					"attributes": []any{
+						attr("http.request.method", str(res.Request.Method)),
+						attr("url.full", str(res.Request.URL.String())),
+						attr("http.response.status_code", map[string]any{"intValue": strconv.Itoa(res.StatusCode)}),
+					},
+					"status": status,
+				}},
+			}},
+		}},
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	c := &http.Client{Timeout: otlpTimeout}
+	got, err := c.Post(strings.TrimSuffix(endpoint, "/")+"/v1/traces", "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	got.Body.Close()
+	if got.StatusCode/100 != 2 {
+		return fmt.Errorf("the collector answered %s", got.Status)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTracing(t *testing.T) {
+	var exported map[string]any
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("export went to %s as %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &exported)
+	}))
+	defer collector.Close()
+
+	mws, err := middlewares(config{otlpEndpoint: collector.URL, traceLink: "http://jaeger/trace/{trace}"})
+	if err != nil || len(mws) != 1 || mws[0].name() != "traceparent" {
+		t.Fatalf("-otlp-endpoint brought %v, %v", mws, err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "https://a.test/", nil)
+	if _, err := applyRequestMiddleware(mws, req, nil); err != nil {
+		t.Fatal(err)
+	}
+	m := traceparentRe.FindStringSubmatch(req.Header.Get("Traceparent"))
+	if m == nil {
+		t.Fatalf("traceparent = %q", req.Header.Get("Traceparent"))
+	}
+	trace, span := m[1], m[2]
+
+	res := &http.Response{Request: req, StatusCode: 502, Status: "502 Bad Gateway", Header: http.Header{"X-B3-Traceid": {"80f198ee56343ba8"}}}
+	notes := applyResponseMiddleware(mws, res, nil)
+	want := []string{
+		"traceparent: trace " + trace + " http://jaeger/trace/" + trace,
+		"traceparent: the server reports trace 80f198ee56343ba8",
+		"traceparent: span exported to " + collector.URL,
+	}
+	if strings.Join(notes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("notes = %q", notes)
+	}
+
+	got := exported["resourceSpans"].([]any)[0].(map[string]any)["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	if got["traceId"] != trace || got["spanId"] != span || got["kind"] != 3.0 {
+		t.Errorf("exported span = %v", got)
+	}
+	if status := got["status"].(map[string]any); status["code"] != 2.0 {
+		t.Errorf("a 502 exported status %v", status)
+	}
+}
+
+func TestTracingJoinsGivenTrace(t *testing.T) {
+	given := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00"
+	req, _ := http.NewRequest(http.MethodGet, "https://a.test/", nil)
+	req.Header.Set("Traceparent", given)
+	mws := []middleware{newTracing(config{})}
+	applyRequestMiddleware(mws, req, nil)
+	sent := req.Header.Get("Traceparent")
+	if !strings.HasPrefix(sent, "00-0af7651916cd43dd8448eb211c80319c-") || !strings.HasSuffix(sent, "-00") || sent == given {
+		t.Errorf("traceparent = %q; want a new span in the given trace", sent)
+	}
+
+	res := &http.Response{Request: req, Header: http.Header{"Traceresponse": {"00-0af7651916cd43dd8448eb211c80319c-00f067aa0ba902b7-01"}}}
+	if notes := applyResponseMiddleware(mws, res, nil); len(notes) != 1 {
+		t.Errorf("the server echoing our own trace is no news: %q", notes)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultIdleTimeout is how long net/http's default transport keeps an
+// idle connection around; we use it when -idle-timeout isn't given.
+const defaultIdleTimeout = 90 * time.Second
+
+// transportOptions are the connection pool settings given on the command
+// line. The zero value behaves like net/http's default transport.
+type transportOptions struct {
+	maxIdlePerHost int           // Idle connections kept per host; 0 means net/http's default.
+	idleTimeout    time.Duration // How long an idle connection is kept; 0 means defaultIdleTimeout.
+	noCompression  bool          // Don't ask for gzip, so bodies arrive as the server sent them.
+	noHTTP2        bool          // Stick to HTTP/1.1 even where the server offers HTTP/2.
+	family         string        // Connect over IPv4 only for "4", IPv6 only for "6", either for "".
+	chaos          chaosOptions  // Delays and failures to inflict on requests.
+	throttle       throttle      // Caps on how fast connections move data.
+	portForward    bool          // Reach the cluster's services and pods through kubectl port-forward.
+	kubeContext    string        // The kubectl context of the cluster; "" for the current one.
+	sshJump        string        // The environment's SSH jump host to connect through; "" for none.
+	keyLog         string        // The file the TLS session keys are appended to; "" for none.
+}
+
+// poolStats counts how the pool served the requests sent through it.
+type poolStats struct {
+	requests atomic.Int64 // Requests that got a connection.
+	reused   atomic.Int64 // ... of which on a connection that was already open.
+	idle     atomic.Int64 // ... of which on one that was idle in the pool.
+	waited   atomic.Int64 // Total time spent getting a connection, in nanoseconds.
+}
+
+// pooledTransport is a transport shared by every client with the same
+// options, so connections really are pooled between requests.
+type pooledTransport struct {
+	*http.Transport
+	stats poolStats
+	chaos chaosOptions
+}
+
+// transports holds the one pooledTransport per set of options.
+var transports struct {
+	sync.Mutex
+	byOpts map[transportOptions]*pooledTransport
+}
+
+// transportFor returns the shared transport for opts, making it on first use.
+func transportFor(opts transportOptions) *pooledTransport {
+	transports.Lock()
+	defer transports.Unlock()
+	if t, ok := transports.byOpts[opts]; ok {
+		return t
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.MaxIdleConnsPerHost = opts.maxIdlePerHost
+	tr.IdleConnTimeout = opts.idleTimeout
+	if tr.IdleConnTimeout == 0 {
+		tr.IdleConnTimeout = defaultIdleTimeout
+	}
+	tr.DisableCompression = opts.noCompression
+	if opts.family != "" {
+		tr.DialContext = familyDialer(opts.family)
+	}
+	if opts.sshJump != "" {
+		tr.DialContext = sshDialer(opts.sshJump, opts.family)
+	}
+	if opts.portForward {
+		tr.DialContext = portForwardDialer(tr.DialContext, opts.kubeContext)
+	}
+	if opts.throttle != (throttle{}) {
+		tr.DialContext = throttledDialer(tr.DialContext, opts.throttle)
+	}
+	if opts.keyLog != "" {
+		tr.TLSClientConfig = &tls.Config{KeyLogWriter: keyLogWriter(opts.keyLog)}
+	}
+	tr.ForceAttemptHTTP2 = !opts.noHTTP2
+	if opts.noHTTP2 {
+		// A non-nil, empty map is how net/http is told not to upgrade.
+		tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	t := &pooledTransport{Transport: tr, chaos: opts.chaos}
+	if transports.byOpts == nil {
+		transports.byOpts = map[transportOptions]*pooledTransport{}
+	}
+	transports.byOpts[opts] = t
+	return t
+}
+
+// RoundTrip sends req, noting how its connection was obtained, after any
+// chaos asked for.
+func (t *pooledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.chaos.enabled() {
+		if err := t.chaos.inflict(req, rand.IntN(100)); err != nil {
+			return nil, err
+		}
+	}
+	start := time.Now()
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.stats.requests.Add(1)
+			t.stats.waited.Add(int64(time.Since(start)))
+			if info.Reused {
+				t.stats.reused.Add(1)
+			}
+			if info.WasIdle {
+				t.stats.idle.Add(1)
+			}
+		},
+	}
+	return t.Transport.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// diagnostics describes the effective pool settings and how the pool has
+// been used so far.
+func diagnostics(cfg config) reportMsg {
+	t := transportFor(transportOptionsFor(cfg))
+	perHost := t.MaxIdleConnsPerHost
+	if perHost == 0 {
+		perHost = http.DefaultMaxIdleConnsPerHost
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "MaxIdleConns:        %d\n", t.MaxIdleConns)
+	fmt.Fprintf(&b, "MaxIdleConnsPerHost: %d\n", perHost)
+	fmt.Fprintf(&b, "IdleConnTimeout:     %s\n", t.IdleConnTimeout)
+	fmt.Fprintf(&b, "DisableCompression:  %t\n", t.DisableCompression)
+	fmt.Fprintf(&b, "ForceAttemptHTTP2:   %t\n", t.ForceAttemptHTTP2)
+	fmt.Fprintf(&b, "Address family:      %s\n", familyName(cfg.transport.family))
+	fmt.Fprintf(&b, "Chaos:               %s\n", t.chaos)
+	fmt.Fprintf(&b, "Throttle:            %s\n", cfg.transport.throttle)
+	if jump := cfg.envs[cfg.env].SSH; jump != "" {
+		fmt.Fprintf(&b, "SSH jump host:       %s\n", jump)
+	}
+	if cfg.transport.keyLog != "" {
+		fmt.Fprintf(&b, "TLS key log:         %s (%d sessions)\n", cfg.transport.keyLog, keyLogWriter(cfg.transport.keyLog).logged())
+	}
+
+	n := t.stats.requests.Load()
+	fmt.Fprintf(&b, "\nConnections handed out: %d", n)
+	if n > 0 {
+		reused := t.stats.reused.Load()
+		fmt.Fprintf(&b, "\n  reused:  %d (%d straight from the idle pool)", reused, t.stats.idle.Load())
+		fmt.Fprintf(&b, "\n  opened:  %d", n-reused)
+		fmt.Fprintf(&b, "\n  average wait for a connection: %s", (time.Duration(t.stats.waited.Load()) / time.Duration(n)).Round(time.Microsecond))
+	}
+	return reportMsg{title: "Connection pool", body: b.String()}
+}
+
+// familyName describes an address family setting for the diagnostics.
+func familyName(family string) string {
+	if family == "" {
+		return "IPv4 or IPv6 (Happy Eyeballs)"
+	}
+	return "IPv" + family + " only"
+}
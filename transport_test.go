@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransportFor(t *testing.T) {
+	opts := transportOptions{maxIdlePerHost: 7, idleTimeout: time.Minute, noCompression: true, noHTTP2: true}
+	tr := transportFor(opts)
+	if tr != transportFor(opts) {
+		t.Error("the same options gave two transports")
+	}
+	if tr == transportFor(transportOptions{}) {
+		t.Error("different options shared a transport")
+	}
+	if tr.MaxIdleConnsPerHost != 7 || tr.IdleConnTimeout != time.Minute || !tr.DisableCompression ||
+		tr.ForceAttemptHTTP2 || tr.TLSNextProto == nil {
+		t.Errorf("transport = %+v", tr.Transport)
+	}
+	if got := transportFor(transportOptions{}).IdleConnTimeout; got != defaultIdleTimeout {
+		t.Errorf("default idle timeout = %s", got)
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	// Options of its own, so other tests' requests don't count.
+	cfg := config{transport: transportOptions{maxIdlePerHost: 3, idleTimeout: 42 * time.Second}}
+	c := newClient(cfg)
+	for range 3 {
+		res, err := c.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}
+
+	body := diagnostics(cfg).body
+	for _, want := range []string{
+		"MaxIdleConnsPerHost: 3",
+		"IdleConnTimeout:     42s",
+		"Connections handed out: 3",
+		"reused:  2 (2 straight from the idle pool)",
+		"opened:  1",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("diagnostics lack %q:\n%s", want, body)
+		}
+	}
+}
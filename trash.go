@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Unstarring a request or deleting a named session shouldn't be the end
+// of it if it was a slip. Either moves what it removes into the trash,
+// which `trash` lists; `trash restore` puts back the latest, or the one
+// numbered, as an undo, and `trash purge` empties it for good.
+
+// Kinds of what the trash holds.
+const (
+	trashFavorite = "favorite"
+	trashSession  = "session"
+)
+
+// trashItem is something deleted, as the trash file keeps it.
+type trashItem struct {
+	Kind     string    `json:"kind"`
+	Name     string    `json:"name"` // The request, as historyKey names it, or the session's name.
+	Deleted  time.Time `json:"deleted"`
+	Favorite *savedTab `json:"favorite,omitempty"`
+	Session  *session  `json:"session,omitempty"`
+}
+
+// defaultTrashFile is where the trash is kept unless -trash-file says
+// otherwise, or "" for none without a config directory.
+func defaultTrashFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "httpwizard", "trash.json")
+}
+
+// loadTrash reads the trash file, oldest deletion first. A missing file is
+// an empty trash.
+func loadTrash(path string) ([]trashItem, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var items []trashItem
+	if err := json.Unmarshal(b, &items); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return items, nil
+}
+
+// saveTrash writes items to the trash file. What is in it may carry
+// credentials, as what it came from did, so only the user can read it.
+func saveTrash(path string, items []trashItem) error {
+	b, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(b, '\n'))
+}
+
+// moveToTrash adds it to the trash file at path, if there is one.
+func moveToTrash(path string, it trashItem) error {
+	if path == "" {
+		return nil
+	}
+	items, err := loadTrash(path)
+	if err != nil {
+		return err
+	}
+	return saveTrash(path, append(items, it))
+}
+
+// trashSessionFile moves the named session called name, kept beside
+// sessionFile, into the trash file at trashFile.
+func trashSessionFile(sessionFile, trashFile, name string) error {
+	file := namedSessionFile(sessionFile, name)
+	if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("there is no session %q", name)
+	}
+	s, err := loadSession(file)
+	if err != nil {
+		return err
+	}
+	if err := moveToTrash(trashFile, trashItem{Kind: trashSession, Name: name, Deleted: time.Now().UTC(), Session: &s}); err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+// runTrash is the palette's `trash [restore [N] | purge [age]]`: it lists
+// the trash, latest first, puts back the latest deletion or the one
+// numbered N, or empties the trash, of everything or of what was deleted
+// longer than age ago, e.g. 30d.
+func runTrash(m model, args []string) (tea.Model, tea.Cmd) {
+	path := m.cfg.trashFile
+	if path == "" {
+		return m.paletteError(errors.New("there is no trash; -trash-file is off"))
+	}
+	items, err := loadTrash(path)
+	if err != nil {
+		return m.paletteError(err)
+	}
+	verb := ""
+	if len(args) > 0 {
+		verb = args[0]
+	}
+	switch verb {
+	case "":
+		m.report, m.cursor = &reportMsg{title: "Trash", body: listTrash(items, time.Now())}, 0
+	case "restore":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 || n > len(items) {
+				return m.paletteError(fmt.Errorf("there is no item %s in the trash; trash lists them", args[1]))
+			}
+		}
+		if len(items) == 0 {
+			return m.paletteError(errors.New("the trash is empty"))
+		}
+		i := len(items) - n
+		if err := restoreItem(m.cfg, items[i]); err != nil {
+			return m.paletteError(err)
+		}
+		it := items[i]
+		if err := saveTrash(path, append(items[:i:i], items[i+1:]...)); err != nil {
+			return m.paletteError(err)
+		}
+		m.report, m.cursor = &reportMsg{title: "Trash", body: fmt.Sprintf("Restored the %s %s", it.Kind, it.Name)}, 0
+	case "purge":
+		var cutoff time.Time
+		if len(args) > 1 {
+			age, err := parseAge(args[1])
+			if err != nil {
+				return m.paletteError(fmt.Errorf("%q is not an age such as 30d or 12h", args[1]))
+			}
+			cutoff = time.Now().Add(-age)
+		}
+		var kept []trashItem
+		for _, it := range items {
+			if !cutoff.IsZero() && it.Deleted.After(cutoff) {
+				kept = append(kept, it)
+			}
+		}
+		if err := saveTrash(path, kept); err != nil {
+			return m.paletteError(err)
+		}
+		m.report, m.cursor = &reportMsg{title: "Trash", body: fmt.Sprintf("Purged %d for good; %d left in the trash", len(items)-len(kept), len(kept))}, 0
+	default:
+		return m.paletteError(errors.New("usage: trash [restore [N] | purge [age]]"))
+	}
+	return m, nil
+}
+
+// listTrash sets out items, latest first and numbered as trash restore
+// takes them, as of now.
+func listTrash(items []trashItem, now time.Time) string {
+	if len(items) == 0 {
+		return "The trash is empty. Unstarring a request or deleting a session puts it here."
+	}
+	var b strings.Builder
+	for n := 1; n <= len(items); n++ {
+		it := items[len(items)-n]
+		fmt.Fprintf(&b, "%3d  %-8s  %s, deleted %s ago\n", n, it.Kind, it.Name, now.Sub(it.Deleted).Round(time.Minute))
+	}
+	b.WriteString("\ntrash restore [N] puts one back • trash purge [age] deletes for good")
+	return b.String()
+}
+
+// restoreItem puts it back where it was deleted from, as cfg says where
+// that is, unless something has taken its place since.
+func restoreItem(cfg config, it trashItem) error {
+	switch {
+	case it.Kind == trashFavorite && it.Favorite != nil:
+		if cfg.favoritesFile == "" {
+			return errors.New("there is no favorites file to restore the request to")
+		}
+		favs, err := loadFavorites(cfg.favoritesFile)
+		if err != nil {
+			return err
+		}
+		for _, t := range favs {
+			if historyKey(t.Method, t.URL) == it.Name {
+				return fmt.Errorf("%s is starred again already", it.Name)
+			}
+		}
+		return saveFavorites(cfg.favoritesFile, append(favs, *it.Favorite))
+	case it.Kind == trashSession && it.Session != nil:
+		if cfg.sessionFile == "" {
+			return errors.New("sessions are kept beside -session-file, which is off")
+		}
+		file := namedSessionFile(cfg.sessionFile, it.Name)
+		if _, err := os.Stat(file); err == nil {
+			return fmt.Errorf("there is a session called %q again already", it.Name)
+		}
+		return saveSession(file, *it.Session)
+	}
+	return fmt.Errorf("the trash holds a %s this program can't restore", it.Kind)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestTrashFavorite(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config{method: "GET", url: "https://api.example.com/users", header: http.Header{"Authorization": {"Bearer x"}},
+		favoritesFile: filepath.Join(dir, "favorites.json"), trashFile: filepath.Join(dir, "trash.json")}
+	m := model{cfg: cfg}
+	star := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("*")}
+	next, _ := m.Update(star)
+	next, _ = next.(model).Update(star)
+	if r := next.(model).report; !strings.Contains(r.body, "trash restore") {
+		t.Errorf("unstarred: %+v", r)
+	}
+
+	next, _ = runTrash(next.(model), nil)
+	if body := next.(model).report.body; !strings.Contains(body, "  1  favorite  GET https://api.example.com/users, deleted 0s ago") {
+		t.Errorf("trash:\n%s", body)
+	}
+	next, _ = runTrash(next.(model), []string{"restore"})
+	if body := next.(model).report.body; body != "Restored the favorite GET https://api.example.com/users" {
+		t.Errorf("restore: %s", body)
+	}
+	favs, _ := loadFavorites(cfg.favoritesFile)
+	if len(favs) != 1 || favs[0].Header.Get("Authorization") != "Bearer x" {
+		t.Errorf("favorites = %+v", favs)
+	}
+	if items, _ := loadTrash(cfg.trashFile); len(items) != 0 {
+		t.Errorf("trash = %+v", items)
+	}
+	next, _ = runTrash(next.(model), []string{"restore"})
+	if body := next.(model).report.body; body != "the trash is empty" {
+		t.Errorf("restore from an empty trash: %s", body)
+	}
+}
+
+func TestTrashSession(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config{method: "GET", url: "https://pay.example.com/charges", header: http.Header{},
+		sessionFile: filepath.Join(dir, "session.json"), trashFile: filepath.Join(dir, "trash.json")}
+	a := app{tabs: []model{{id: 1, cfg: cfg}}, nextID: 2}
+	next, _ := a.handleSession(sessionMsg{verb: "save", name: "payments"})
+	next, _ = next.(app).handleSession(sessionMsg{verb: "delete", name: "payments"})
+	a = next.(app)
+	if a.name != "" || !strings.Contains(a.tabs[0].report.body, "into the trash") {
+		t.Fatalf("deleted: name %q, %+v", a.name, a.tabs[0].report)
+	}
+	if _, err := os.Stat(namedSessionFile(cfg.sessionFile, "payments")); !os.IsNotExist(err) {
+		t.Errorf("the session is still there: %v", err)
+	}
+	next, _ = a.handleSession(sessionMsg{verb: "delete", name: "payments"})
+	if body := next.(app).tabs[0].report.body; !strings.Contains(body, `no session "payments"`) {
+		t.Errorf("deleting it again: %s", body)
+	}
+
+	// Something saved under the name since keeps its place.
+	saved, _ := a.handleSession(sessionMsg{verb: "save", name: "payments"})
+	m, _ := runTrash(saved.(app).tabs[0], []string{"restore", "1"})
+	if body := m.(model).report.body; !strings.Contains(body, "again already") {
+		t.Errorf("restore over a new session: %s", body)
+	}
+	os.Remove(namedSessionFile(cfg.sessionFile, "payments"))
+	m, _ = runTrash(a.tabs[0], []string{"restore", "1"})
+	if body := m.(model).report.body; body != "Restored the session payments" {
+		t.Errorf("restore: %s", body)
+	}
+	if s, _ := loadSession(namedSessionFile(cfg.sessionFile, "payments")); len(s.Tabs) != 1 || s.Tabs[0].URL != cfg.url {
+		t.Errorf("restored session = %+v", s)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trash.json")
+	now := time.Now().UTC()
+	fav := &savedTab{Method: "GET", URL: "https://x.example.com/"}
+	if err := saveTrash(path, []trashItem{
+		{Kind: trashFavorite, Name: "GET old", Deleted: now.Add(-40 * 24 * time.Hour), Favorite: fav},
+		{Kind: trashFavorite, Name: "GET new", Deleted: now.Add(-time.Hour), Favorite: fav},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	m := model{cfg: config{trashFile: path}}
+	next, _ := runTrash(m, []string{"purge", "30d"})
+	if body := next.(model).report.body; body != "Purged 1 for good; 1 left in the trash" {
+		t.Errorf("purge 30d: %s", body)
+	}
+	if items, _ := loadTrash(path); len(items) != 1 || items[0].Name != "GET new" {
+		t.Errorf("trash = %+v", items)
+	}
+	runTrash(m, []string{"purge"})
+	if items, _ := loadTrash(path); len(items) != 0 {
+		t.Errorf("trash = %+v", items)
+	}
+	for _, args := range [][]string{{"purge", "soon"}, {"restore", "9"}, {"empty"}} {
+		if next, _ := runTrash(m, args); next.(model).report.title != "Command" {
+			t.Errorf("trash %v wasn't refused", args)
+		}
+	}
+}
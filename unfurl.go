@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/html"
+)
+
+// Chat apps and search engines show a link as a card: a title, a line of
+// description and an image, taken from the page's <head>. Unfurling a URL
+// fetches the page and builds the card the way they would, from the
+// OpenGraph and Twitter tags and, failing those, the plain HTML ones, and
+// says what is missing for it to look right.
+
+// Past these lengths, search engines and chat apps cut the title and the
+// description short.
+const (
+	maxTitleLength       = 60
+	maxDescriptionLength = 160
+)
+
+// pageMeta is what a page's <head> says about it.
+type pageMeta struct {
+	title       string
+	description string
+	canonical   string
+	icon        string
+	og          [][2]string // og:* properties in page order, e.g. {"og:title", "Home"}.
+	twitter     [][2]string // twitter:* names in page order.
+}
+
+// get returns the value of key, an og: or twitter: tag, or "".
+func (p pageMeta) get(key string) string {
+	tags := p.og
+	if strings.HasPrefix(key, "twitter:") {
+		tags = p.twitter
+	}
+	for _, t := range tags {
+		if t[0] == key {
+			return t[1]
+		}
+	}
+	return ""
+}
+
+// runUnfurl is the palette's `unfurl [url]`: it fetches the page, the
+// request's own by default, and shows the card a link to it unfurls into.
+func runUnfurl(m model, args []string) (tea.Model, tea.Cmd) {
+	target := m.cfg.url
+	if len(args) > 0 {
+		target = args[0]
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return m.paletteError(fmt.Errorf("%q is not an http:// or https:// URL", target))
+	}
+	c := newClient(m.cfg)
+	m.job = "Unfurling " + target
+	return m, runJob(func(func(string)) tea.Msg {
+		meta, final, err := fetchMeta(c, target)
+		if err != nil {
+			return reportMsg{title: "Unfurl " + target, body: err.Error()}
+		}
+		r := reportMsg{title: "Unfurl " + final.String(), body: meta.card(final)}
+		for _, link := range []string{meta.canonical, meta.get("og:url"), meta.get("og:image")} {
+			if link != "" {
+				r.links = append(r.links, link)
+			}
+		}
+		return r
+	})
+}
+
+// fetchMeta GETs the page at target and reads its <head>, returning the
+// URL that answered after any redirects.
+func fetchMeta(c *http.Client, target string) (pageMeta, *url.URL, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return pageMeta{}, nil, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	res, err := c.Do(req)
+	if err != nil {
+		return pageMeta{}, nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return pageMeta{}, nil, fmt.Errorf("the page answered %d %s, and unfurlers give up on anything but 200", res.StatusCode, http.StatusText(res.StatusCode))
+	}
+	if !isHTML(res.Header) {
+		return pageMeta{}, nil, errors.New("the URL is not an HTML page, so it has no tags to unfurl; links to it show as bare URLs")
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxBody))
+	if err != nil {
+		return pageMeta{}, nil, err
+	}
+	return parseMeta(body, res.Request.URL), res.Request.URL, nil
+}
+
+// parseMeta reads the tags in the <head> of an HTML page fetched from base,
+// resolving the URLs among them.
+func parseMeta(body []byte, base *url.URL) pageMeta {
+	var p pageMeta
+	resolve := func(s string) string {
+		if ref, err := base.Parse(strings.TrimSpace(s)); err == nil {
+			return ref.String()
+		}
+		return s
+	}
+	z := html.NewTokenizer(bytes.NewReader(body))
+	inTitle := false
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return p
+		case html.TextToken:
+			if inTitle && p.title == "" {
+				p.title = strings.Join(strings.Fields(string(z.Text())), " ")
+			}
+			continue
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return p
+			}
+			continue
+		case html.StartTagToken, html.SelfClosingTagToken:
+		default:
+			continue
+		}
+		name, _ := z.TagName()
+		attrs := map[string]string{}
+		for {
+			key, val, more := z.TagAttr()
+			attrs[string(key)] = string(val)
+			if !more {
+				break
+			}
+		}
+		switch string(name) {
+		case "title":
+			inTitle = tt == html.StartTagToken
+		case "body":
+			return p
+		case "link":
+			for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+				switch {
+				case rel == "canonical" && p.canonical == "":
+					p.canonical = resolve(attrs["href"])
+				case rel == "icon" && p.icon == "":
+					p.icon = resolve(attrs["href"])
+				}
+			}
+		case "meta":
+			// OpenGraph uses property=, Twitter name=, and pages mix them up.
+			key := strings.ToLower(attrs["property"])
+			if key == "" {
+				key = strings.ToLower(attrs["name"])
+			}
+			content := strings.TrimSpace(attrs["content"])
+			switch {
+			case key == "description" && p.description == "":
+				p.description = content
+			case strings.HasPrefix(key, "og:"):
+				if strings.HasSuffix(key, ":image") || key == "og:url" {
+					content = resolve(content)
+				}
+				p.og = append(p.og, [2]string{key, content})
+			case strings.HasPrefix(key, "twitter:"):
+				if key == "twitter:image" {
+					content = resolve(content)
+				}
+				p.twitter = append(p.twitter, [2]string{key, content})
+			}
+		}
+	}
+}
+
+// card lays out the card a link to the page at u unfurls into, the tags it
+// came from, and what is missing or too long.
+func (p pageMeta) card(u *url.URL) string {
+	// Unfurlers prefer OpenGraph and fall back to the plain tags.
+	first := func(values ...string) string {
+		for _, v := range values {
+			if v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	title := first(p.get("og:title"), p.get("twitter:title"), p.title)
+	description := first(p.get("og:description"), p.get("twitter:description"), p.description)
+	image := first(p.get("og:image"), p.get("twitter:image"))
+	site := first(p.get("og:site_name"), u.Hostname())
+
+	var b strings.Builder
+	b.WriteString("┌ " + site + "\n")
+	b.WriteString("│ " + first(title, "(no title)") + "\n")
+	if description != "" {
+		b.WriteString("│ " + description + "\n")
+	}
+	if image != "" {
+		b.WriteString("│ [image] " + image + "\n")
+	}
+	b.WriteString("└ " + first(p.canonical, p.get("og:url"), u.String()) + "\n")
+
+	b.WriteString("\n")
+	row := func(label, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%-13s %s\n", label+":", value)
+		}
+	}
+	row("Title", p.title)
+	row("Description", p.description)
+	row("Canonical", p.canonical)
+	row("Icon", p.icon)
+	for _, t := range append(append([][2]string(nil), p.og...), p.twitter...) {
+		fmt.Fprintf(&b, "%-13s %s\n", t[0], t[1])
+	}
+
+	var problems []string
+	for _, key := range []string{"og:title", "og:description", "og:image"} {
+		if p.get(key) == "" {
+			problems = append(problems, "no "+key+"; unfurlers fall back to what they can find, or show a bare link")
+		}
+	}
+	if p.get("twitter:card") == "" {
+		problems = append(problems, "no twitter:card, so X shows a small summary card at best")
+	}
+	if n := utf8.RuneCountInString(title); n > maxTitleLength {
+		problems = append(problems, fmt.Sprintf("the title is %d characters; past %d it is cut short", n, maxTitleLength))
+	}
+	if n := utf8.RuneCountInString(description); n > maxDescriptionLength {
+		problems = append(problems, fmt.Sprintf("the description is %d characters; past %d it is cut short", n, maxDescriptionLength))
+	}
+	if p.canonical != "" && p.canonical != u.String() {
+		problems = append(problems, "the canonical URL is another page; search engines index that one instead")
+	}
+	if strings.HasPrefix(image, "http://") {
+		problems = append(problems, "the image is served over plain HTTP, which many unfurlers won't load")
+	}
+	if len(problems) == 0 {
+		return b.String() + "\n✓ The card has all it needs"
+	}
+	b.WriteString("\n")
+	for _, problem := range problems {
+		b.WriteString("⚠ " + problem + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const unfurlPage = `<!doctype html><html><head>
+<title>  Widgets
+  for sale </title>
+<meta name="description" content="The best widgets.">
+<link rel="canonical" href="/widgets">
+<link rel="shortcut icon" href="/favicon.ico">
+<meta property="og:title" content="Widgets">
+<meta property="og:image" content="/img/card.png">
+<meta name="twitter:card" content="summary_large_image">
+</head><body><meta property="og:description" content="not in the head"></body></html>`
+
+func TestUnfurl(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/widgets":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(unfurlPage))
+		case "/data":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	meta, final, err := fetchMeta(srv.Client(), srv.URL+"/widgets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.title != "Widgets for sale" || meta.canonical != srv.URL+"/widgets" || meta.icon != srv.URL+"/favicon.ico" || meta.get("og:description") != "" {
+		t.Errorf("meta = %+v", meta)
+	}
+	out := meta.card(final)
+	for _, want := range []string{
+		"┌ 127.0.0.1\n│ Widgets\n│ The best widgets.\n│ [image] " + srv.URL + "/img/card.png\n└ " + srv.URL + "/widgets\n",
+		"Title:        Widgets for sale\n",
+		"og:image      " + srv.URL + "/img/card.png\n",
+		"twitter:card  summary_large_image\n",
+		"⚠ no og:description",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("card lacks %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "canonical URL is another page") || strings.Contains(out, "no twitter:card") {
+		t.Errorf("card has problems it shouldn't:\n%s", out)
+	}
+
+	if _, _, err := fetchMeta(srv.Client(), srv.URL+"/data"); err == nil || !strings.Contains(err.Error(), "not an HTML page") {
+		t.Errorf("JSON: err = %v", err)
+	}
+	if _, _, err := fetchMeta(srv.Client(), srv.URL+"/gone"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("404: err = %v", err)
+	}
+}
+
+func TestCardProblems(t *testing.T) {
+	u, _ := url.Parse("https://example.com/a")
+	p := pageMeta{title: strings.Repeat("t", 70), canonical: "https://example.com/b", og: [][2]string{{"og:image", "http://example.com/i.png"}}}
+	out := p.card(u)
+	for _, want := range []string{"the title is 70 characters", "the canonical URL is another page", "the image is served over plain HTTP", "no twitter:card"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("card lacks %q:\n%s", want, out)
+		}
+	}
+}
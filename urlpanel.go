@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// urlParts is a URL taken apart into the pieces the inspector edits. Values
+// are kept decoded for reading and are escaped again by String.
+type urlParts struct {
+	scheme, user, host, port string
+	segments                 []string        // Path segments, without slashes.
+	slash                    bool            // The path ends in a slash after its last segment.
+	params                   [][2]string     // Query parameters, in their original order.
+	bare                     map[string]bool // Parameters written without "=", like ?flag.
+	fragment                 string
+}
+
+// splitURL takes raw apart. Query parameters keep their order and
+// duplicates, which url.Values would lose, and the path keeps its empty
+// segments and trailing slash, so that String gives back the same URL.
+func splitURL(raw string) (urlParts, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return urlParts{}, err
+	}
+	p := urlParts{
+		scheme:   u.Scheme,
+		user:     u.User.String(),
+		port:     u.Port(),
+		fragment: u.Fragment,
+	}
+	p.host, _, _ = idnHost(raw)
+	if p.host == "" {
+		p.host = u.Hostname()
+	}
+	// Split the escaped path, so an encoded slash stays inside its segment.
+	if path := strings.TrimPrefix(u.EscapedPath(), "/"); path != "" {
+		if strings.HasSuffix(path, "/") {
+			p.slash = true
+			path = strings.TrimSuffix(path, "/")
+		}
+		for _, seg := range strings.Split(path, "/") {
+			if s, err := url.PathUnescape(seg); err == nil {
+				seg = s
+			}
+			p.segments = append(p.segments, seg)
+		}
+	}
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, hasValue := strings.Cut(pair, "=")
+		k, err1 := url.QueryUnescape(key)
+		v, err2 := url.QueryUnescape(value)
+		if err1 != nil || err2 != nil {
+			k, v = key, value
+		}
+		p.params = append(p.params, [2]string{k, v})
+		p.setBare(k, !hasValue)
+	}
+	return p, nil
+}
+
+// setBare records whether the parameter key is written without "=".
+func (p *urlParts) setBare(key string, bare bool) {
+	if bare {
+		if p.bare == nil {
+			p.bare = map[string]bool{}
+		}
+		p.bare[key] = true
+	} else {
+		delete(p.bare, key)
+	}
+}
+
+// String puts the parts back together as a URL ready to be sent.
+func (p urlParts) String() string {
+	var b strings.Builder
+	b.WriteString(p.scheme + "://")
+	if p.user != "" {
+		b.WriteString(p.user + "@")
+	}
+	switch {
+	case p.port != "":
+		b.WriteString(net.JoinHostPort(p.host, p.port))
+	case strings.Contains(p.host, ":"):
+		b.WriteString("[" + p.host + "]") // An IPv6 address.
+	default:
+		b.WriteString(p.host)
+	}
+	for _, seg := range p.segments {
+		b.WriteString("/" + url.PathEscape(seg))
+	}
+	if len(p.segments) == 0 || p.slash {
+		b.WriteString("/")
+	}
+	for i, kv := range p.params {
+		sep := "&"
+		if i == 0 {
+			sep = "?"
+		}
+		b.WriteString(sep + url.QueryEscape(kv[0]))
+		if kv[1] != "" || !p.bare[kv[0]] {
+			b.WriteString("=" + url.QueryEscape(kv[1]))
+		}
+	}
+	if p.fragment != "" {
+		b.WriteString("#" + (&url.URL{Fragment: p.fragment}).EscapedFragment())
+	}
+	s, err := normalizeURL(b.String())
+	if err != nil {
+		return b.String()
+	}
+	return s
+}
+
+// field is one row of the inspector: a label, the value shown, and how to
+// store an edited value back into the parts.
+type field struct {
+	label string
+	value string
+	set   func(p *urlParts, v string)
+}
+
+// fields lists the inspector rows for p, starting with the raw URL itself.
+func (p urlParts) fields() []field {
+	fs := []field{
+		{"url", p.String(), nil},
+		{"scheme", p.scheme, func(p *urlParts, v string) { p.scheme = v }},
+		{"user", p.user, func(p *urlParts, v string) { p.user = v }},
+		{"host", p.host, func(p *urlParts, v string) { p.host = v }},
+		{"port", p.port, func(p *urlParts, v string) { p.port = v }},
+	}
+	for i, seg := range p.segments {
+		fs = append(fs, field{fmt.Sprintf("path %d", i+1), seg, func(p *urlParts, v string) { p.segments[i] = v }})
+	}
+	for i, kv := range p.params {
+		shown := kv[0] + "=" + kv[1]
+		if kv[1] == "" && p.bare[kv[0]] {
+			shown = kv[0]
+		}
+		fs = append(fs, field{"param", shown, func(p *urlParts, v string) {
+			key, value, hasValue := strings.Cut(v, "=")
+			p.params[i] = [2]string{key, value}
+			p.setBare(key, !hasValue)
+		}})
+	}
+	return append(fs, field{"fragment", p.fragment, func(p *urlParts, v string) { p.fragment = v }})
+}
+
+// urlPanel is the state of the URL inspector while it is open.
+type urlPanel struct {
+	parts   urlParts        // The URL being edited.
+	cursor  int             // Selected row.
+	editing bool            // The selected row is being edited in input.
+	input   textinput.Model // Line editor for the selected row.
+	err     error           // Why the last edit could not be applied.
+}
+
+// newURLPanel opens the inspector on raw.
+func newURLPanel(raw string) (*urlPanel, error) {
+	parts, err := splitURL(raw)
+	if err != nil {
+		return nil, err
+	}
+	in := textinput.New()
+	in.Cursor.SetMode(cursor.CursorStatic)
+	return &urlPanel{parts: parts, input: in}, nil
+}
+
+// updateURLPanel handles keys while the URL inspector is open.
+func (m model) updateURLPanel(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// Shallow-copy the panel so the previous model keeps its own state.
+	copied := *m.urlPanel
+	p := &copied
+	m.urlPanel = p
+	fields := p.parts.fields()
+
+	if p.editing {
+		switch msg.String() {
+		case "enter":
+			p.editing, p.err = false, nil
+			if p.cursor == 0 {
+				// A new raw URL replaces every part at once.
+				parts, err := splitURL(p.input.Value())
+				if err != nil {
+					p.err = err
+					return m, nil
+				}
+				p.parts = parts
+			} else {
+				p.parts = p.parts.clone()
+				fields[p.cursor].set(&p.parts, p.input.Value())
+			}
+		case "esc":
+			p.editing = false
+		default:
+			var cmd tea.Cmd
+			p.input, cmd = p.input.Update(msg)
+			return m, cmd
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.urlPanel = nil
+	case "up", "k":
+		p.cursor = max(p.cursor-1, 0)
+	case "down", "j":
+		p.cursor = min(p.cursor+1, len(fields)-1)
+	case "enter":
+		p.editing = true
+		p.input.SetValue(fields[p.cursor].value)
+		p.input.CursorEnd()
+		p.input.Focus()
+	case "+":
+		p.parts = p.parts.clone()
+		p.parts.params = append(p.parts.params, [2]string{"key", "value"})
+		p.cursor = len(p.parts.fields()) - 2
+	case "-":
+		p.parts = p.parts.remove(fields[p.cursor].label, p.cursor)
+		p.cursor = min(p.cursor, len(p.parts.fields())-1)
+	case "s":
+		// Send the edited URL, closing the inspector.
+		target := p.parts.String()
+		m.urlPanel = nil
+		return m.open(target)
+	}
+	return m, nil
+}
+
+// clone copies p deeply enough that edits don't leak into earlier models.
+func (p urlParts) clone() urlParts {
+	p.segments = append([]string(nil), p.segments...)
+	p.params = append([][2]string(nil), p.params...)
+	p.bare = maps.Clone(p.bare)
+	return p
+}
+
+// remove drops the path segment or query parameter shown at row i, whose
+// label is label. Other rows can't be removed, only emptied.
+func (p urlParts) remove(label string, i int) urlParts {
+	p = p.clone()
+	first := 5 // Rows before the first path segment: url, scheme, user, host, port.
+	switch {
+	case strings.HasPrefix(label, "path "):
+		i -= first
+		p.segments = append(p.segments[:i], p.segments[i+1:]...)
+	case label == "param":
+		i -= first + len(p.segments)
+		p.params = append(p.params[:i], p.params[i+1:]...)
+	}
+	return p
+}
+
+// viewURLPanel renders the URL inspector.
+func (m model) viewURLPanel() string {
+	p := m.urlPanel
+	var b strings.Builder
+	b.WriteString("\nURL inspector\n")
+	for i, f := range p.parts.fields() {
+		mark := "  "
+		if i == p.cursor {
+			mark = "> "
+		}
+		value := f.value
+		if i == p.cursor && p.editing {
+			value = p.input.View()
+		}
+		fmt.Fprintf(&b, "\n%s%-9s %s", mark, f.label, value)
+	}
+	if p.err != nil {
+		fmt.Fprintf(&b, "\n\n%v", p.err)
+	}
+	if p.editing {
+		b.WriteString("\n\nenter apply • esc cancel\n")
+	} else {
+		b.WriteString("\n\n↑/↓ select • enter edit • + add param • - remove • s send • esc close\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestURLPartsRoundTrip(t *testing.T) {
+	for _, raw := range []string{
+		"https://example.com/",
+		"https://example.com/a/b/",
+		"https://example.com/a//b",
+		"https://example.com//",
+		"https://example.com/a%2Fb/c",
+		"http://[::1]:8080/x",
+		"http://[2001:db8::1]/",
+		"https://example.com/?flag&a=1&a=2&empty=",
+		"https://user:pw@example.com:8443/p?q=a+b#sec%20two",
+		"https://example.com/#a/b?c",
+	} {
+		p, err := splitURL(raw)
+		if err != nil {
+			t.Errorf("splitURL(%q): %v", raw, err)
+			continue
+		}
+		if got := p.String(); got != raw {
+			t.Errorf("splitURL(%q).String() = %q", raw, got)
+		}
+	}
+}
+
+func TestSplitURL(t *testing.T) {
+	p, err := splitURL("https://example.com/a%2Fb/c/?x=1&flag#top")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a/b", "c"}; !reflect.DeepEqual(p.segments, want) || !p.slash {
+		t.Errorf("segments = %q, slash = %v, want %q with a slash", p.segments, p.slash, want)
+	}
+	if want := [][2]string{{"x", "1"}, {"flag", ""}}; !reflect.DeepEqual(p.params, want) {
+		t.Errorf("params = %q, want %q", p.params, want)
+	}
+	if !p.bare["flag"] || p.bare["x"] {
+		t.Errorf("bare = %v, want only flag", p.bare)
+	}
+	if p.fragment != "top" {
+		t.Errorf("fragment = %q", p.fragment)
+	}
+}
+
+func TestURLPartsEdits(t *testing.T) {
+	p, err := splitURL("https://example.com/?flag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := p.fields()
+	last := fields[len(fields)-2] // The param row, just before the fragment.
+	if last.value != "flag" {
+		t.Errorf("param row = %q, want flag", last.value)
+	}
+
+	// Giving the parameter a value, even an empty one, writes the "=".
+	edited := p.clone()
+	last.set(&edited, "flag=")
+	if got := edited.String(); got != "https://example.com/?flag=" {
+		t.Errorf("after edit = %q", got)
+	}
+	if got := p.String(); got != "https://example.com/?flag" {
+		t.Errorf("original changed to %q", got)
+	}
+
+	edited.port, edited.host = "9000", "::1"
+	edited.fragment = "a b"
+	if got := edited.String(); got != "https://[::1]:9000/?flag=#a%20b" {
+		t.Errorf("after edits = %q", got)
+	}
+}
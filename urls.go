@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// hostProfile converts Unicode hosts for lookup, but without STD3's rule
+// against underscores, which container and service names are full of.
+var hostProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false), idna.BidiRule())
+
+// normalizeURL makes raw safe to put on the wire: a Unicode host is
+// converted to its ASCII (punycode) form and any non-ASCII characters or
+// spaces in the path, query or fragment are percent-encoded. Escapes that
+// are already present are left as they are, and so are ASCII hosts.
+func normalizeURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	if host := u.Hostname(); host != "" && net.ParseIP(host) == nil && !isASCII(host) {
+		ascii, err := hostProfile.ToASCII(host)
+		if err != nil {
+			return "", fmt.Errorf("host %q: %w", host, err)
+		}
+		if port := u.Port(); port != "" {
+			ascii = net.JoinHostPort(ascii, port)
+		}
+		u.Host = ascii
+	}
+
+	// url.URL escapes Path itself, but RawQuery and RawFragment are sent
+	// verbatim, so any Unicode in them has to be encoded by hand.
+	u.RawQuery = escapeNonASCII(u.RawQuery)
+	if u.RawFragment != "" {
+		u.RawFragment = escapeNonASCII(u.RawFragment)
+	}
+	return u.String(), nil
+}
+
+// isASCII reports whether s is all ASCII.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeNonASCII percent-encodes every byte of s that is not printable
+// ASCII, plus spaces, and leaves everything else (including existing
+// %XX escapes and reserved characters like & and =) untouched.
+func escapeNonASCII(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c >= 0x7f {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// displayURL renders raw for reading: a punycode host is shown in Unicode
+// and percent-escapes in the path and query are decoded. The result is for
+// humans only and may not be a valid URL.
+func displayURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	host := u.Host
+	if h := u.Hostname(); h != "" && net.ParseIP(h) == nil {
+		if uni, err := idna.Display.ToUnicode(h); err == nil {
+			host = uni
+			if port := u.Port(); port != "" {
+				host = net.JoinHostPort(uni, port)
+			}
+		}
+	}
+
+	s := u.Scheme + "://" + host + u.Path
+	if u.RawQuery != "" {
+		query, err := url.QueryUnescape(u.RawQuery)
+		if err != nil {
+			query = u.RawQuery
+		}
+		s += "?" + query
+	}
+	if u.Fragment != "" {
+		s += "#" + u.Fragment
+	}
+	return s
+}
+
+// idnHost returns the Unicode and ASCII forms of raw's host when it is an
+// internationalized domain name, and ok=false for plain ASCII hosts.
+func idnHost(raw string) (unicode, ascii string, ok bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", false
+	}
+	ascii = u.Hostname()
+	unicode, err = idna.Display.ToUnicode(ascii)
+	if err != nil || unicode == ascii {
+		return "", "", false
+	}
+	return unicode, ascii, true
+}
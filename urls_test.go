@@ -0,0 +1,40 @@
+package main
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"https://bücher.example/straße?q=café#über", "https://xn--bcher-kva.example/stra%C3%9Fe?q=caf%C3%A9#%C3%BCber"},
+		{"https://example.com/a b?x=1 2", "https://example.com/a%20b?x=1%202"},
+		{"https://example.com/already%20done?a=%C3%A9&b=c", "https://example.com/already%20done?a=%C3%A9&b=c"},
+		{"http://日本.example:8080/", "http://xn--wgv71a.example:8080/"},
+		{"http://[::1]:8080/x", "http://[::1]:8080/x"},
+		{"http://my_service:8080/health", "http://my_service:8080/health"},
+		{"http://api_v2.bücher.example/", "http://api_v2.xn--bcher-kva.example/"},
+	}
+	for _, tt := range tests {
+		if got, err := normalizeURL(tt.in); err != nil || got != tt.want {
+			t.Errorf("normalizeURL(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := normalizeURL("http://a\x00b/"); err == nil {
+		t.Error("a URL with a control character was accepted")
+	}
+}
+
+func TestDisplayURL(t *testing.T) {
+	got := displayURL("https://xn--bcher-kva.example:8443/stra%C3%9Fe?q=caf%C3%A9#top")
+	if want := "https://bücher.example:8443/straße?q=café#top"; got != want {
+		t.Errorf("displayURL = %q, want %q", got, want)
+	}
+}
+
+func TestIDNHost(t *testing.T) {
+	uni, ascii, ok := idnHost("https://xn--bcher-kva.example/")
+	if !ok || uni != "bücher.example" || ascii != "xn--bcher-kva.example" {
+		t.Errorf("idnHost = %q, %q, %v", uni, ascii, ok)
+	}
+	if _, _, ok := idnHost("https://example.com/"); ok {
+		t.Error("an ASCII host counted as an IDN")
+	}
+}
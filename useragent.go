@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Servers answer browsers, phones and crawlers differently: a mobile page,
+// a prerendered one for Googlebot, a challenge for a bot. -ua, or the
+// palette's `ua`, sends the request with the headers a browser or crawler
+// sends, together, so the server sees a consistent one rather than Go's
+// User-Agent under a browser's Accept. A header given with -H wins.
+//
+// Only the headers are alike. The TLS and HTTP/2 handshakes are still Go's,
+// so a bot check that looks at those isn't fooled, and Accept-Encoding is
+// left to Go, which decompresses only gzip.
+
+// uaHeader is a header of a profile.
+type uaHeader struct{ name, value string }
+
+// uaProfile is the headers a client sends, in the order it sends them.
+type uaProfile struct {
+	about  string
+	header []uaHeader
+}
+
+// Headers the Chromium browsers send on a navigation, after their hints.
+var chromiumNavigation = []uaHeader{
+	{"Upgrade-Insecure-Requests", "1"},
+	{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"},
+	{"Sec-Fetch-Site", "none"},
+	{"Sec-Fetch-Mode", "navigate"},
+	{"Sec-Fetch-User", "?1"},
+	{"Sec-Fetch-Dest", "document"},
+	{"Accept-Language", "en-US,en;q=0.9"},
+}
+
+// Headers Safari sends on a navigation.
+var safariNavigation = []uaHeader{
+	{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+	{"Sec-Fetch-Site", "none"},
+	{"Sec-Fetch-Mode", "navigate"},
+	{"Sec-Fetch-Dest", "document"},
+	{"Accept-Language", "en-US,en;q=0.9"},
+}
+
+// crawlerAccept is what the crawlers ask for.
+var crawlerAccept = uaHeader{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"}
+
+// uaProfiles are the clients -ua and `ua` can pass for.
+var uaProfiles = map[string]uaProfile{
+	"chrome": {"Chrome on Windows", slices.Concat([]uaHeader{
+		{"Sec-CH-UA", `"Google Chrome";v="131", "Chromium";v="131", "Not_A Brand";v="24"`},
+		{"Sec-CH-UA-Mobile", "?0"},
+		{"Sec-CH-UA-Platform", `"Windows"`},
+		{"User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"},
+	}, chromiumNavigation)},
+	"chrome-android": {"Chrome on an Android phone", slices.Concat([]uaHeader{
+		{"Sec-CH-UA", `"Google Chrome";v="131", "Chromium";v="131", "Not_A Brand";v="24"`},
+		{"Sec-CH-UA-Mobile", "?1"},
+		{"Sec-CH-UA-Platform", `"Android"`},
+		{"User-Agent", "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Mobile Safari/537.36"},
+	}, chromiumNavigation)},
+	"firefox": {"Firefox on Windows", []uaHeader{
+		{"User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:133.0) Gecko/20100101 Firefox/133.0"},
+		{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+		{"Accept-Language", "en-US,en;q=0.5"},
+		{"Upgrade-Insecure-Requests", "1"},
+		{"Sec-Fetch-Dest", "document"},
+		{"Sec-Fetch-Mode", "navigate"},
+		{"Sec-Fetch-Site", "none"},
+		{"Sec-Fetch-User", "?1"},
+	}},
+	"safari": {"Safari on a Mac", slices.Concat([]uaHeader{
+		{"User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.1 Safari/605.1.15"},
+	}, safariNavigation)},
+	"safari-ios": {"Safari on an iPhone", slices.Concat([]uaHeader{
+		{"User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 18_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.1 Mobile/15E148 Safari/604.1"},
+	}, safariNavigation)},
+	"googlebot": {"Google's desktop crawler", []uaHeader{
+		{"User-Agent", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"},
+		crawlerAccept,
+		{"From", "googlebot(at)googlebot.com"},
+	}},
+	"googlebot-mobile": {"Google's smartphone crawler", []uaHeader{
+		{"User-Agent", "Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.6778.69 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"},
+		crawlerAccept,
+		{"From", "googlebot(at)googlebot.com"},
+	}},
+	"bingbot": {"Bing's crawler", []uaHeader{
+		{"User-Agent", "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)"},
+		crawlerAccept,
+	}},
+	"curl": {"curl", []uaHeader{
+		{"User-Agent", "curl/8.11.0"},
+		{"Accept", "*/*"},
+	}},
+}
+
+// uaProfileNames lists the profiles for -ua's help and errors.
+func uaProfileNames() string {
+	return strings.Join(slices.Sorted(maps.Keys(uaProfiles)), ", ")
+}
+
+// checkUAProfile reports whether name is a profile, or "" for none.
+func checkUAProfile(name string) error {
+	if _, ok := uaProfiles[name]; name != "" && !ok {
+		return fmt.Errorf("no user agent profile %q; use one of %s", name, uaProfileNames())
+	}
+	return nil
+}
+
+// applyUAProfile adds the headers of the profile name to h, but for those
+// h already has.
+func applyUAProfile(h http.Header, name string) {
+	for _, hd := range uaProfiles[name].header {
+		if h.Get(hd.name) == "" {
+			h.Set(hd.name, hd.value)
+		}
+	}
+}
+
+// runUA is the palette's `ua [profile | off]`: it sends the request again as
+// the client profile names, or as itself, or lists the profiles.
+func runUA(m model, args []string) (tea.Model, tea.Cmd) {
+	if len(args) == 0 {
+		var b strings.Builder
+		for _, name := range slices.Sorted(maps.Keys(uaProfiles)) {
+			mark := "  "
+			if name == m.cfg.uaProfile {
+				mark = "> "
+			}
+			fmt.Fprintf(&b, "%s%-17s %s\n", mark, name, uaProfiles[name].about)
+		}
+		if m.cfg.uaProfile == "" {
+			b.WriteString("\nRequests go with Go's own headers; ua NAME sends them as one of these.\n")
+		}
+		m.report, m.cursor = &reportMsg{title: "User agent profiles", body: b.String()}, 0
+		return m, nil
+	}
+	name := strings.ToLower(args[0])
+	if name == "off" {
+		name = ""
+	}
+	if err := checkUAProfile(name); err != nil {
+		return m.paletteError(err)
+	}
+	cfg := m.cfg
+	cfg.uaProfile = name
+	return m.resend(cfg)
+}
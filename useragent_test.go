@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUAProfiles(t *testing.T) {
+	for name, p := range uaProfiles {
+		seen := map[string]bool{}
+		for _, h := range p.header {
+			if key := http.CanonicalHeaderKey(h.name); seen[key] {
+				t.Errorf("%s sends %s twice", name, h.name)
+			} else {
+				seen[key] = true
+			}
+		}
+		if !seen["User-Agent"] {
+			t.Errorf("%s has no User-Agent", name)
+		}
+	}
+	if err := checkUAProfile("netscape"); err == nil || !strings.Contains(err.Error(), "googlebot") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestUAProfileSend(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.Header }))
+	defer srv.Close()
+	cfg := config{method: "GET", url: srv.URL, header: http.Header{"Accept-Language": {"de-DE"}}, uaProfile: "chrome-android"}
+	if _, ok := send(cfg).(responseMsg); !ok {
+		t.Fatal("send failed")
+	}
+	if ua := got.Get("User-Agent"); !strings.Contains(ua, "Android") || !strings.Contains(ua, "Mobile") {
+		t.Errorf("User-Agent = %q", ua)
+	}
+	if got.Get("Sec-CH-UA-Mobile") != "?1" || got.Get("Sec-Fetch-Mode") != "navigate" {
+		t.Errorf("headers = %v", got)
+	}
+	// -H wins over the profile.
+	if got.Get("Accept-Language") != "de-DE" {
+		t.Errorf("Accept-Language = %q", got.Get("Accept-Language"))
+	}
+	// Go still asks for gzip, which it undoes.
+	if got.Get("Accept-Encoding") != "gzip" {
+		t.Errorf("Accept-Encoding = %q", got.Get("Accept-Encoding"))
+	}
+}
+
+func TestRunUA(t *testing.T) {
+	m := model{cfg: config{method: "GET", url: "http://localhost/", header: http.Header{}, uaProfile: "googlebot"}}
+	next, _ := runUA(m, nil)
+	if body := next.(model).report.body; !strings.Contains(body, "> googlebot ") || !strings.Contains(body, "  safari-ios ") {
+		t.Errorf("report:\n%s", body)
+	}
+	next, cmd := runUA(m, []string{"Safari"})
+	if next.(model).cfg.uaProfile != "safari" || cmd == nil {
+		t.Errorf("ua Safari: profile %q", next.(model).cfg.uaProfile)
+	}
+	next, _ = runUA(m, []string{"off"})
+	if next.(model).cfg.uaProfile != "" {
+		t.Error("ua off kept the profile")
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http/httputil"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Variables are named values a request uses as {{name}}, in its URL, header
+// values and body. They come from three places, each overriding the last:
+// -var on the command line, the active environment's "variables", and
+// values captured from answers with the palette's capture. A {{name}} that
+// is none of them is left for the extension functions.
+
+// Where a variable's value came from, from least to most specific.
+const (
+	fromGlobal   = "global"
+	fromEnv      = "env"
+	fromCaptured = "captured"
+)
+
+// variableRef matches {{name}}, a variable use without arguments, and
+// variableName the names variables can have.
+var (
+	variableRef  = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// variable is the value a name has in scope.
+type variable struct {
+	name, value string
+	source      string   // fromGlobal, fromEnv or fromCaptured.
+	hides       []string // The sources of the values it overrides.
+}
+
+// variableSource is one place variables come from.
+type variableSource struct {
+	name string
+	vars map[string]string
+}
+
+// variableSources lists the sources of variables for cfg, least specific first.
+func variableSources(cfg config) []variableSource {
+	return []variableSource{
+		{fromGlobal, cfg.vars},
+		{fromEnv, cfg.envs[cfg.env].Variables},
+		{fromCaptured, cfg.captured},
+	}
+}
+
+// variablesInScope lists every variable cfg's request can use, by name,
+// with the value that wins.
+func variablesInScope(cfg config) []variable {
+	byName := map[string]*variable{}
+	for _, src := range variableSources(cfg) {
+		for name, value := range src.vars {
+			v, ok := byName[name]
+			if !ok {
+				byName[name] = &variable{name: name, value: value, source: src.name}
+				continue
+			}
+			v.hides = append(v.hides, v.source)
+			v.value, v.source = value, src.name
+		}
+	}
+	vars := make([]variable, 0, len(byName))
+	for _, name := range slices.Sorted(maps.Keys(byName)) {
+		vars = append(vars, *byName[name])
+	}
+	return vars
+}
+
+// lookupVariable returns the value name has for cfg's request.
+func lookupVariable(cfg config, name string) (string, bool) {
+	sources := variableSources(cfg)
+	for i := len(sources) - 1; i >= 0; i-- {
+		if v, ok := sources[i].vars[name]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// hasVariables reports whether cfg has any variables to fill in.
+func hasVariables(cfg config) bool {
+	return slices.ContainsFunc(variableSources(cfg), func(src variableSource) bool { return len(src.vars) > 0 })
+}
+
+// expandVariables fills the variables of cfg into s.
+func expandVariables(cfg config, s string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	return variableRef.ReplaceAllStringFunc(s, func(match string) string {
+		if v, ok := lookupVariable(cfg, variableRef.FindStringSubmatch(match)[1]); ok {
+			return v
+		}
+		return match
+	})
+}
+
+// maskSecret hides a secret's value, keeping only its length in runes.
+func maskSecret(value string) string {
+	return fmt.Sprintf("•••••• (%d characters)", utf8.RuneCountInString(value))
+}
+
+// runVariables is the palette's `vars`: it lists every variable in scope,
+// where its value came from and what it is, secrets masked, and shows the
+// request as it will go out, variables and functions filled in.
+func runVariables(m model, _ []string) (tea.Model, tea.Cmd) {
+	var b strings.Builder
+	vars := variablesInScope(m.cfg)
+	if len(vars) == 0 {
+		b.WriteString("No variables are in scope; give them with -var name=value, in the environment's \"variables\", or with capture.\n")
+	}
+	width := 0
+	for _, v := range vars {
+		width = max(width, len(v.name))
+	}
+	for _, v := range vars {
+		value := v.value
+		if isSecret(v.name) {
+			value = maskSecret(value)
+		}
+		fmt.Fprintf(&b, "%-*s  %-8s  %s", width, v.name, v.source, value)
+		if len(v.hides) > 0 {
+			fmt.Fprintf(&b, "  (overrides %s)", strings.Join(v.hides, ", "))
+		}
+		b.WriteString("\n")
+	}
+	if unknown := unknownReferences(m.cfg); len(unknown) > 0 {
+		fmt.Fprintf(&b, "\n⚠ Not defined: %s\n", strings.Join(unknown, ", "))
+	}
+
+	b.WriteString("\nAs it will be sent:\n")
+	wire, err := resolvedRequest(m.cfg)
+	if err != nil {
+		b.WriteString(err.Error())
+	} else {
+		b.WriteString(wire)
+	}
+	m.report, m.cursor = &reportMsg{title: "Variables", body: strings.TrimRight(b.String(), "\n")}, 0
+	return m, nil
+}
+
+// references lists the names the request uses as {{name}}, each once.
+func references(cfg config) []string {
+	text := cfg.url + "\n" + string(cfg.body)
+	for _, values := range cfg.header {
+		text += "\n" + strings.Join(values, "\n")
+	}
+	var names []string
+	for _, match := range variableRef.FindAllStringSubmatch(escapedCall.ReplaceAllString(text, "{{$1}}"), -1) {
+		if !slices.Contains(names, match[1]) {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// unknownReferences lists the {{name}} uses in the request that are neither
+// variables nor extension functions.
+func unknownReferences(cfg config) []string {
+	return slices.DeleteFunc(references(cfg), func(name string) bool {
+		if _, ok := lookupVariable(cfg, name); ok {
+			return true
+		}
+		if cfg.ext != nil {
+			if _, ok := cfg.ext.functions[name]; ok {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// logVariables notes in the application log which variables the request
+// described by cfg has filled in, and from where.
+func logVariables(cfg config) {
+	used := references(cfg)
+	for _, v := range variablesInScope(cfg) {
+		if !slices.Contains(used, v.name) {
+			continue
+		}
+		value := v.value
+		if isSecret(v.name) {
+			value = maskSecret(value)
+		}
+		logf(logDebug, "Filled in {{%s}} as %s, from %s", v.name, value, v.source)
+	}
+	for _, name := range unknownReferences(cfg) {
+		logf(logWarn, "{{%s}} is not defined, so it was sent as it is", name)
+	}
+}
+
+// resolvedRequest writes out the request cfg describes as it goes on the
+// wire, with the headers Go adds itself, and secret header values masked.
+func resolvedRequest(cfg config) (string, error) {
+	cfg, err := expandRequest(cfg)
+	if err == nil {
+		cfg, err = encodeBody(cfg)
+	}
+	if err != nil {
+		return "", err
+	}
+	req, _, err := newRequest(cfg)
+	if err != nil {
+		return "", err
+	}
+	dump, err := httputil.DumpRequestOut(req, true)
+	if err != nil {
+		return "", err
+	}
+	head, body, _ := strings.Cut(string(dump), "\r\n\r\n")
+	lines := strings.Split(head, "\r\n")
+	for i, line := range lines[1:] {
+		if name, value, ok := strings.Cut(line, ": "); ok && isSecret(name) {
+			lines[i+1] = name + ": " + maskSecret(value)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n\n" + body, nil
+}
+
+// runCapture is the palette's `capture name [$.path | header]`: it keeps a
+// value of the answer, from its JSON body or a header, as a variable for
+// the requests that follow. Without a source, it is the body's $.name.
+func runCapture(m model, args []string) (tea.Model, tea.Cmd) {
+	if len(args) == 0 || len(args) > 2 || !variableName.MatchString(args[0]) {
+		return m.paletteError(errors.New("usage: capture name [$.path | header], e.g. capture token $.access_token"))
+	}
+	if m.res.status == 0 {
+		return m.paletteError(errors.New("there is no answer to capture from yet"))
+	}
+	name, from := args[0], "$."+args[0]
+	if len(args) == 2 {
+		from = args[1]
+	}
+	var value string
+	if strings.HasPrefix(from, "$") {
+		v, problem := jsonAt(m.res.jsonBody(), from)
+		if problem != "" {
+			return m.paletteError(errors.New(problem))
+		}
+		value = v
+	} else if value = m.res.header.Get(from); value == "" {
+		return m.paletteError(fmt.Errorf("the answer has no %s header", from))
+	}
+	captured := maps.Clone(m.cfg.captured)
+	if captured == nil {
+		captured = map[string]string{}
+	}
+	captured[name] = value
+	m.cfg.captured = captured
+	shown := value
+	if isSecret(name) {
+		shown = maskSecret(value)
+	}
+	m.report, m.cursor = &reportMsg{title: "Capture", body: fmt.Sprintf("{{%s}} is now %s, from %s", name, shown, from)}, 0
+	return m, nil
+}
+
+// varFlag collects -var name=value flags.
+type varFlag map[string]string
+
+// String implements flag.Value.
+func (v varFlag) String() string {
+	var parts []string
+	for _, name := range slices.Sorted(maps.Keys(v)) {
+		parts = append(parts, name+"="+v[name])
+	}
+	return strings.Join(parts, " ")
+}
+
+// Set implements flag.Value. It parses one name=value pair.
+func (v varFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || !variableName.MatchString(name) {
+		return fmt.Errorf("variable %q is not in name=value form", s)
+	}
+	v[name] = value
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestVariablesInScope(t *testing.T) {
+	cfg := config{
+		vars:     map[string]string{"host": "global.example.com", "user": "ann"},
+		env:      "dev",
+		envs:     map[string]environment{"dev": {Variables: map[string]string{"host": "dev.example.com", "api_token": "s3cret"}}},
+		captured: map[string]string{"user": "bob"},
+	}
+	got := variablesInScope(cfg)
+	want := []variable{
+		{name: "api_token", value: "s3cret", source: fromEnv},
+		{name: "host", value: "dev.example.com", source: fromEnv, hides: []string{fromGlobal}},
+		{name: "user", value: "bob", source: fromCaptured, hides: []string{fromGlobal}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("variablesInScope = %+v", got)
+	}
+	for i := range want {
+		if got[i].name != want[i].name || got[i].value != want[i].value || got[i].source != want[i].source || strings.Join(got[i].hides, ",") != strings.Join(want[i].hides, ",") {
+			t.Errorf("variable %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestExpandVariables(t *testing.T) {
+	cfg := config{
+		method: "POST",
+		url:    normalizeOrDie(t, "https://example.com/{{version}}/users/{{ id }}?q={{missing}}"),
+		header: http.Header{"Authorization": {"Bearer {{api_token}}"}},
+		body:   []byte(`{"name": "{{user}}"}`),
+		vars:   map[string]string{"version": "v2", "id": "7", "api_token": "s3cret", "user": "ann"},
+	}
+	got, err := expandRequest(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got.url, "https://example.com/v2/users/7?q=") {
+		t.Errorf("url = %s", got.url)
+	}
+	if got.header.Get("Authorization") != "Bearer s3cret" || string(got.body) != `{"name": "ann"}` {
+		t.Errorf("header = %v, body = %s", got.header, got.body)
+	}
+	if unknown := unknownReferences(cfg); strings.Join(unknown, ",") != "missing" {
+		t.Errorf("unknownReferences = %v", unknown)
+	}
+
+	wire, err := resolvedRequest(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"POST /v2/users/7?q=", "Host: example.com", "Authorization: •••••• (13 characters)", "User-Agent: Go-http-client", `{"name": "ann"}`} {
+		if !strings.Contains(wire, want) {
+			t.Errorf("wire lacks %q:\n%s", want, wire)
+		}
+	}
+}
+
+// normalizeOrDie normalizes raw as the command line would.
+func normalizeOrDie(t *testing.T, raw string) string {
+	u, err := normalizeURL(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestCapture(t *testing.T) {
+	m := model{cfg: config{method: "GET", url: "https://example.com/"}}
+	m.res = describeBody(m.cfg, http.Header{"Content-Type": {"application/json"}, "X-Next": {"page2"}}, []byte(`{"access_token": "abc", "user": {"id": 7}}`))
+	m.res.status = 200
+	for _, args := range [][]string{{"access_token"}, {"uid", "$.user.id"}, {"next", "X-Next"}} {
+		next, _ := runCapture(m, args)
+		m = next.(model)
+	}
+	if c := m.cfg.captured; c["access_token"] != "abc" || c["uid"] != "7" || c["next"] != "page2" {
+		t.Errorf("captured = %v", c)
+	}
+	if next, _ := runCapture(m, []string{"x", "$.nope"}); !strings.Contains(next.(model).report.body, "no $.nope") {
+		t.Errorf("report = %+v", next.(model).report)
+	}
+}
+
+func TestVarFlag(t *testing.T) {
+	v := varFlag{}
+	for _, s := range []string{"host=example.com", "empty=", "q=a=b"} {
+		if err := v.Set(s); err != nil {
+			t.Errorf("Set(%q): %v", s, err)
+		}
+	}
+	if got := v.String(); got != "empty= host=example.com q=a=b" {
+		t.Errorf("String() = %q", got)
+	}
+	for _, s := range []string{"novalue", "bad-name=1", "=1"} {
+		if v.Set(s) == nil {
+			t.Errorf("Set(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultWatchInterval is how often watch mode sends the request unless
+// told otherwise.
+const defaultWatchInterval = 2 * time.Second
+
+// Load mode sends defaultLoadRequests requests, defaultLoadWorkers at a
+// time, unless told otherwise.
+const (
+	defaultLoadRequests = 100
+	defaultLoadWorkers  = 10
+	maxLoadRequests     = 100000
+	maxLoadWorkers      = 500
+)
+
+// histBounds are the upper bounds of the latency histogram's buckets; a
+// last bucket takes everything slower.
+var histBounds = []time.Duration{
+	10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second,
+}
+
+// histWidth is how wide the longest bar of the histogram is drawn.
+const histWidth = 40
+
+// sample is what came of one request of a watch or load run.
+type sample struct {
+	at      time.Time
+	elapsed time.Duration
+	status  int   // 0 when the request failed.
+	err     error // Why it failed.
+}
+
+// toSample reads the message a request returned as a sample of it. Streamed
+// bodies are stopped; a run times the response, not the stream.
+func toSample(msg tea.Msg, start time.Time, elapsed time.Duration) sample {
+	s := sample{at: start, elapsed: elapsed}
+	switch msg := msg.(type) {
+	case responseMsg:
+		if msg.stopStream != nil {
+			msg.stopStream()
+		}
+		s.status = msg.status
+	case errMsg:
+		s.err = msg.err
+	default:
+		s.err = errors.New("not an HTTP request")
+	}
+	return s
+}
+
+// latencyRun is the state of watch or load mode: the requests sent so far,
+// shown as a live histogram and sparkline of their latency.
+type latencyRun struct {
+	id      int64  // Which run the samples in flight belong to.
+	title   string // What is being run, e.g. "Watching GET https://… every 2s".
+	total   int    // How many requests a load run sends; 0 to watch until stopped.
+	samples []sample
+	done    bool
+	stop    context.CancelFunc
+	note    string // What the last export did.
+}
+
+// sampleMsg delivers a sample of run; next waits for the one after.
+type sampleMsg struct {
+	run    int64
+	sample sample
+	next   tea.Cmd
+}
+
+// loadDoneMsg says every request of a load run has been answered.
+type loadDoneMsg struct{ run int64 }
+
+// lastRun numbers the watch and load runs, so that the samples of a
+// stopped one can be told apart from those of the next.
+var lastRun atomic.Int64
+
+// runWatch is the palette's `watch [interval]`: it sends the request again
+// and again until stopped.
+func runWatch(m model, args []string) (tea.Model, tea.Cmd) {
+	interval := defaultWatchInterval
+	if len(args) > 0 {
+		d, err := time.ParseDuration(args[0])
+		if err != nil || d < 100*time.Millisecond {
+			return m.paletteError(fmt.Errorf("%q is not an interval of 100ms or more, such as 5s", args[0]))
+		}
+		interval = d
+	}
+	if isFileTransfer(m.cfg.url) {
+		return m.paletteError(errors.New("only HTTP requests can be watched"))
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	run := &latencyRun{id: lastRun.Add(1), title: fmt.Sprintf("Watching %s %s every %s", m.cfg.method, m.cfg.url, interval), stop: stop}
+	m.latency = run
+	return m, watchNext(ctx, m.cfg, run.id, interval, 0)
+}
+
+// watchNext returns a command that waits out what is left of the interval,
+// given the last request took last, and sends the request once more. Each
+// one goes into the history, like any other request.
+func watchNext(ctx context.Context, cfg config, run int64, interval, last time.Duration) tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval - min(last, interval)):
+		}
+		cfg := withRequestID(cfg)
+		start := time.Now()
+		msg := send(cfg)
+		elapsed := time.Since(start)
+		_ = recordHistory(cfg, msg, elapsed)
+		s := toSample(msg, start, elapsed)
+		_ = cfg.metrics.observe(cfg.method, cfg.url, s)
+		return sampleMsg{run: run, sample: s, next: watchNext(ctx, cfg, run, interval, elapsed)}
+	}
+}
+
+// runLoad is the palette's `load [requests] [concurrency]`: it sends the
+// request that many times, that many at once. Load runs stay out of the
+// history, which they would swamp.
+func runLoad(m model, args []string) (tea.Model, tea.Cmd) {
+	total, workers := defaultLoadRequests, defaultLoadWorkers
+	for i, limit := range []int{maxLoadRequests, maxLoadWorkers} {
+		if len(args) <= i {
+			break
+		}
+		n, err := strconv.Atoi(args[i])
+		if err != nil || n < 1 || n > limit {
+			return m.paletteError(fmt.Errorf("%q is not a number from 1 to %d", args[i], limit))
+		}
+		if i == 0 {
+			total = n
+		} else {
+			workers = n
+		}
+	}
+	if isFileTransfer(m.cfg.url) {
+		return m.paletteError(errors.New("only HTTP requests can be load tested"))
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	run := &latencyRun{id: lastRun.Add(1), title: fmt.Sprintf("Load test of %s %s: %d requests, %d at a time", m.cfg.method, m.cfg.url, total, workers), total: total, stop: stop}
+	m.latency = run
+	return m, startLoad(ctx, m.cfg, run.id, total, workers)
+}
+
+// startLoad starts workers that share total requests between them, and
+// returns a command that delivers their samples one at a time.
+func startLoad(ctx context.Context, cfg config, run int64, total, workers int) tea.Cmd {
+	// Room for every sample, so that workers never wait on a view that
+	// has been closed.
+	samples := make(chan sample, total)
+	var left atomic.Int64
+	left.Store(int64(total))
+	var wg sync.WaitGroup
+	for range min(workers, total) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil && left.Add(-1) >= 0 {
+				start := time.Now()
+				msg := send(withRequestID(cfg))
+				s := toSample(msg, start, time.Since(start))
+				_ = cfg.metrics.observe(cfg.method, cfg.url, s)
+				samples <- s
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(samples)
+	}()
+	return waitForSample(samples, run)
+}
+
+// waitForSample returns a command that delivers the next sample of a load
+// run, or says the run is done.
+func waitForSample(samples chan sample, run int64) tea.Cmd {
+	return func() tea.Msg {
+		s, ok := <-samples
+		if !ok {
+			return loadDoneMsg{run}
+		}
+		return sampleMsg{run: run, sample: s, next: waitForSample(samples, run)}
+	}
+}
+
+// updateLatency handles the keys of watch and load mode: esc stops the run
+// and closes it, s stops it and keeps the numbers on screen, and x and X
+// export its samples as CSV and JSON; see export.go.
+func (m model) updateLatency(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		m.latency.stop()
+		return m, tea.Quit
+	case "esc", "q":
+		m.latency.stop()
+		m.latency = nil
+	case "s":
+		m.latency.stop()
+		run := *m.latency
+		run.done = true
+		m.latency = &run
+	case "x", "X":
+		format := "csv"
+		if msg.String() == "X" {
+			format = "json"
+		}
+		run := *m.latency
+		if name, err := exportRun(&run, "", format, time.Now()); err != nil {
+			run.note = "Couldn't export the run: " + err.Error()
+		} else {
+			run.note = fmt.Sprintf("Wrote %d answers to %s", len(run.samples), name)
+		}
+		m.latency = &run
+	}
+	return m, nil
+}
+
+// addSample adds a sample to the run it belongs to, and waits for the
+// next; a sample of a closed run is dropped, and so is the rest of it.
+func (m model) addSample(msg sampleMsg) (tea.Model, tea.Cmd) {
+	if m.latency == nil || m.latency.id != msg.run || m.latency.done {
+		return m, nil
+	}
+	run := *m.latency
+	run.samples = append(run.samples, msg.sample)
+	m.latency = &run
+	return m, msg.next
+}
+
+// viewLatency shows a watch or load run as it happens.
+func (m model) viewLatency() string {
+	run := m.latency
+	var b strings.Builder
+	b.WriteString("\n" + run.title + "\n")
+	switch {
+	case run.total > 0:
+		fmt.Fprintf(&b, "%d of %d answered", len(run.samples), run.total)
+	default:
+		fmt.Fprintf(&b, "%d requests", len(run.samples))
+	}
+	if !run.done {
+		b.WriteString(" " + m.spin.View())
+	} else {
+		b.WriteString(", stopped")
+	}
+	b.WriteString("\n\n")
+	if len(run.samples) == 0 {
+		b.WriteString("Waiting for the first answer…\n")
+	} else {
+		b.WriteString(describeSamples(run.samples))
+	}
+	if run.note != "" {
+		b.WriteString("\n" + run.note + "\n")
+	}
+	if run.done {
+		return b.String() + "\nx export CSV • X export JSON • esc close\n"
+	}
+	return b.String() + "\ns stop • x export CSV • X export JSON • esc stop and close\n"
+}
+
+// describeSamples writes up a run's samples: the statuses, the latency
+// percentiles, a sparkline of the latest latencies and a histogram of all
+// of them.
+func describeSamples(samples []sample) string {
+	var b strings.Builder
+	b.WriteString("Statuses: " + sampleStatuses(samples) + "\n")
+
+	var elapsed []time.Duration
+	for _, s := range samples {
+		if s.err == nil {
+			elapsed = append(elapsed, s.elapsed)
+		}
+	}
+	if len(elapsed) > 0 {
+		sorted := slices.Sorted(slices.Values(elapsed))
+		r := func(d time.Duration) time.Duration { return d.Round(time.Microsecond) }
+		fmt.Fprintf(&b, "p50 %s  p90 %s  p99 %s  max %s\n", r(percentile(sorted, 50)), r(percentile(sorted, 90)), r(percentile(sorted, 99)), r(sorted[len(sorted)-1]))
+
+		latest := elapsed[max(len(elapsed)-sparkWidth, 0):]
+		ms := make([]float64, len(latest))
+		for i, d := range latest {
+			ms[i] = float64(d) / float64(time.Millisecond)
+		}
+		fmt.Fprintf(&b, "\nLatest %d: %s\n\n", len(latest), sparkline(ms))
+		b.WriteString(histogram(elapsed))
+	}
+	return b.String()
+}
+
+// sampleStatuses counts the samples by status the way statusCounts does for
+// a crawl, with the last error, e.g. "200×57, 503×2, ERR×1 (connection refused)".
+func sampleStatuses(samples []sample) string {
+	counts := map[int]int{}
+	var lastErr error
+	for _, s := range samples {
+		counts[s.status]++
+		if s.err != nil {
+			lastErr = s.err
+		}
+	}
+	var parts []string
+	for _, status := range slices.Sorted(maps.Keys(counts)) {
+		if status == 0 {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%d×%d", status, counts[status]))
+	}
+	if counts[0] > 0 {
+		parts = append(parts, fmt.Sprintf("ERR×%d (%v)", counts[0], lastErr))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// percentile returns the p-th percentile of sorted, by nearest rank.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(float64(len(sorted))*p/100+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// histogram draws how latencies spread over histBounds, one bar per
+// bucket, from the fastest bucket in use to the slowest.
+func histogram(latencies []time.Duration) string {
+	counts := make([]int, len(histBounds)+1)
+	for _, d := range latencies {
+		i, _ := slices.BinarySearch(histBounds, d)
+		counts[i]++
+	}
+	first, last := len(counts), 0
+	for i, n := range counts {
+		if n > 0 {
+			first, last = min(first, i), i
+		}
+	}
+	most := slices.Max(counts)
+
+	var b strings.Builder
+	for i := first; i <= last; i++ {
+		label := "> " + histBounds[len(histBounds)-1].String()
+		if i < len(histBounds) {
+			label = "≤ " + histBounds[i].String()
+		}
+		bar := strings.Repeat("█", (counts[i]*histWidth+most-1)/most)
+		fmt.Fprintf(&b, "%8s %-*s %d\n", label, histWidth, bar, counts[i])
+	}
+	return b.String()
+}
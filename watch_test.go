@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestHistogram(t *testing.T) {
+	ms := time.Millisecond
+	got := histogram([]time.Duration{20 * ms, 22 * ms, 24 * ms, 40 * ms, 900 * ms})
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	// From the ≤ 25ms bucket to the ≤ 1s one; the empty buckets between
+	// them stay, so the shape of the spread shows.
+	if len(lines) != 6 {
+		t.Fatalf("histogram has %d lines:\n%s", len(lines), got)
+	}
+	if !strings.HasPrefix(lines[0], "  ≤ 25ms "+strings.Repeat("█", histWidth)) || !strings.HasSuffix(lines[0], " 3") {
+		t.Errorf("first bar: %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "≤ 50ms") || strings.Count(lines[1], "█") != 14 {
+		t.Errorf("second bar: %q", lines[1])
+	}
+	if strings.Contains(lines[2], "█") {
+		t.Errorf("an empty bucket has a bar: %q", lines[2])
+	}
+	if over := histogram([]time.Duration{time.Minute}); !strings.Contains(over, "> 5s") {
+		t.Errorf("slow bucket: %q", over)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{50: 50 * time.Millisecond, 99: 99 * time.Millisecond, 100: 100 * time.Millisecond, 0: time.Millisecond} {
+		if got := percentile(sorted, p); got != want {
+			t.Errorf("p%g = %s, want %s", p, got, want)
+		}
+	}
+	if got := percentile(sorted[:1], 99); got != time.Millisecond {
+		t.Errorf("p99 of one = %s", got)
+	}
+}
+
+func TestSampleStatuses(t *testing.T) {
+	samples := []sample{{status: 503}, {status: 200}, {err: errors.New("refused")}, {status: 200}}
+	if got := sampleStatuses(samples); got != "200×2, 503×1, ERR×1 (refused)" {
+		t.Errorf("sampleStatuses = %q", got)
+	}
+	if s := toSample(errMsg{errors.New("boom")}, time.Now(), time.Second); s.err == nil || s.status != 0 {
+		t.Errorf("sample of an error = %+v", s)
+	}
+	if s := toSample(reportMsg{}, time.Now(), time.Second); s.err == nil {
+		t.Errorf("sample of something else = %+v", s)
+	}
+}
+
+func TestLoadMode(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1)%5 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	m := model{cfg: config{method: http.MethodGet, url: srv.URL, header: http.Header{}}}
+	next, cmd := runLoad(m, []string{"20", "4"})
+	m = next.(model)
+	if m.latency == nil || cmd == nil {
+		t.Fatal("load didn't start")
+	}
+	for cmd != nil {
+		next, cmd = m.Update(cmd())
+		m = next.(model)
+	}
+	if !m.latency.done || len(m.latency.samples) != 20 || hits.Load() != 20 {
+		t.Fatalf("done %v with %d samples after %d hits", m.latency.done, len(m.latency.samples), hits.Load())
+	}
+	view := m.viewLatency()
+	for _, want := range []string{"20 of 20 answered, stopped", "200×16, 503×4", "p50 ", "Latest 20: ", "█"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view lacks %q:\n%s", want, view)
+		}
+	}
+
+	if next, _ := runLoad(m, []string{"0"}); next.(model).report == nil {
+		t.Error("a load of 0 requests was taken")
+	}
+}
+
+func TestWatchMode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer srv.Close()
+
+	m := model{cfg: config{method: http.MethodGet, url: srv.URL, header: http.Header{}}}
+	next, cmd := runWatch(m, []string{"100ms"})
+	m = next.(model)
+	for range 3 {
+		next, cmd = m.Update(cmd())
+		m = next.(model)
+	}
+	if len(m.latency.samples) != 3 || m.latency.samples[2].status != 200 {
+		t.Fatalf("samples = %+v", m.latency.samples)
+	}
+
+	// Once closed, the answer still in flight is dropped, and the watch ends.
+	first := m.latency.id
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = next.(model)
+	if m.latency != nil {
+		t.Fatal("esc left watch mode open")
+	}
+	if next, cmd := m.Update(sampleMsg{run: first, next: cmd}); next.(model).latency != nil || cmd != nil {
+		t.Error("a sample of a closed watch was taken")
+	}
+
+	if next, _ := runWatch(m, []string{"1ms"}); next.(model).report == nil {
+		t.Error("an interval of 1ms was taken")
+	}
+}
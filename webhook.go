@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Testing a webhook means having somewhere for it to go. `listen` takes
+// whatever is sent to it and prints each request as it comes, headers and
+// body, answering them all alike. A webhook from GitHub or Stripe can't
+// reach a laptop, so -tunnel opens one to it with ngrok, cloudflared or
+// localtunnel, and prints the public URL to give the sender:
+//
+//	httpwizard listen -addr :9000 -tunnel ngrok
+//
+// The tunnel closes with the listener.
+
+// tunnelStart bounds how long a tunnel may take to say where it is.
+var tunnelStart = 30 * time.Second
+
+// tunnelProgram is how a tunnel is opened to a local port, and what its
+// public URL looks like in what it prints.
+type tunnelProgram struct {
+	command string
+	args    func(port string) []string
+	url     *regexp.Regexp
+}
+
+// tunnelPrograms are what -tunnel can open.
+var tunnelPrograms = map[string]tunnelProgram{
+	"ngrok": {
+		command: "ngrok",
+		args:    func(port string) []string { return []string{"http", port, "--log", "stdout", "--log-format", "json"} },
+		url:     regexp.MustCompile(`"url":"(https://[^"]+)"`),
+	},
+	"cloudflared": {
+		command: "cloudflared",
+		args: func(port string) []string {
+			return []string{"tunnel", "--no-autoupdate", "--url", "http://localhost:" + port}
+		},
+		url: regexp.MustCompile(`(https://[-a-z0-9]+\.trycloudflare\.com)`),
+	},
+	"localtunnel": {
+		command: "lt",
+		args:    func(port string) []string { return []string{"--port", port} },
+		url:     regexp.MustCompile(`your url is: (https://\S+)`),
+	},
+}
+
+// runListen implements `listen [-addr ADDRESS] [-status CODE] [-tunnel PROGRAM]`.
+func runListen(args []string) error {
+	fs := flag.NewFlagSet("listen", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "listen on this `address`")
+	status := fs.Int("status", http.StatusOK, "answer every request with this status `code`")
+	tunnel := fs.String("tunnel", "", "open a public tunnel to the listener with `program`: "+strings.Join(slices.Sorted(maps.Keys(tunnelPrograms)), ", "))
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: listen [-addr ADDRESS] [-status CODE] [-tunnel PROGRAM]")
+	}
+	if http.StatusText(*status) == "" {
+		return fmt.Errorf("-status %d is not an HTTP status", *status)
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	fmt.Printf("Listening on http://%s/\n", ln.Addr())
+	if *tunnel != "" {
+		_, port, _ := net.SplitHostPort(ln.Addr().String())
+		t, err := openTunnel(*tunnel, port)
+		if err != nil {
+			return err
+		}
+		defer t.close()
+		fmt.Printf("Public URL: %s\n", t.url)
+	}
+	fmt.Println()
+	return http.Serve(ln, webhookHandler(os.Stdout, *status))
+}
+
+// webhookHandler writes each request to out as it comes, and answers it
+// with status.
+func webhookHandler(out io.Writer, status int) http.Handler {
+	var mu sync.Mutex
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+		from := r.RemoteAddr
+		// Through a tunnel, the sender is whoever the tunnel says.
+		if f := r.Header.Get("X-Forwarded-For"); f != "" {
+			from, _, _ = strings.Cut(f, ",")
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s %s %s from %s\n", time.Now().Format(time.TimeOnly), r.Method, r.URL.RequestURI(), strings.TrimSpace(from))
+		for _, name := range slices.Sorted(maps.Keys(r.Header)) {
+			for _, v := range r.Header[name] {
+				fmt.Fprintf(&b, "%s: %s\n", name, v)
+			}
+		}
+		if len(body) > 0 {
+			var indented bytes.Buffer
+			if json.Indent(&indented, body, "", "  ") == nil {
+				body = indented.Bytes()
+			}
+			b.WriteString("\n" + strings.TrimRight(string(body), "\n") + "\n")
+		}
+		if err != nil {
+			fmt.Fprintf(&b, "(the body was cut short: %v)\n", err)
+		}
+		mu.Lock()
+		fmt.Fprintln(out, b.String())
+		mu.Unlock()
+		w.WriteHeader(status)
+	})
+}
+
+// tunnel is a running tunnel program and its public URL.
+type tunnel struct {
+	cmd  *exec.Cmd
+	url  string
+	done chan struct{}
+}
+
+// openTunnel starts the tunnel program name to the local port, and waits
+// until it says where it is.
+func openTunnel(name, port string) (*tunnel, error) {
+	p, ok := tunnelPrograms[name]
+	if !ok {
+		return nil, fmt.Errorf("-tunnel %q: use one of %s", name, strings.Join(slices.Sorted(maps.Keys(tunnelPrograms)), ", "))
+	}
+	cmd := exec.Command(p.command, p.args(port)...)
+	// Some write their URL to standard output, some to standard error.
+	r, w := io.Pipe()
+	cmd.Stdout, cmd.Stderr = w, w
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("opening a tunnel: %w", err)
+	}
+	t := &tunnel{cmd: cmd, done: make(chan struct{})}
+
+	found := make(chan string, 1)
+	var said []string // What it printed before the URL, to tell why if there is none.
+	scanned := make(chan struct{})
+	go func() {
+		defer close(scanned)
+		lines := bufio.NewScanner(r)
+		for lines.Scan() {
+			if m := p.url.FindStringSubmatch(lines.Text()); m != nil && len(found) == 0 {
+				found <- m[1]
+			} else if len(said) < 10 && len(found) == 0 {
+				said = append(said, lines.Text())
+			}
+		}
+		io.Copy(io.Discard, r)
+	}()
+	go func() {
+		cmd.Wait()
+		w.Close()
+		close(t.done)
+	}()
+
+	fail := func(err error) (*tunnel, error) {
+		t.close()
+		<-scanned
+		if msg := strings.TrimSpace(strings.Join(said, "\n")); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("opening a tunnel with %s: %w", p.command, err)
+	}
+	select {
+	case t.url = <-found:
+		return t, nil
+	case <-t.done:
+		return fail(errors.New("it stopped"))
+	case <-time.After(tunnelStart):
+		return fail(fmt.Errorf("it gave no public URL within %s", tunnelStart))
+	}
+}
+
+// close stops the tunnel.
+func (t *tunnel) close() {
+	t.cmd.Process.Kill()
+	<-t.done
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	var out strings.Builder
+	srv := httptest.NewServer(webhookHandler(&out, http.StatusAccepted))
+	defer srv.Close()
+	req, _ := http.NewRequest("POST", srv.URL+"/hooks/github?x=1", strings.NewReader(`{"action":"opened","number":7}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", "140.82.115.1, 10.0.0.1")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		t.Errorf("status = %d", res.StatusCode)
+	}
+	got := out.String()
+	for _, want := range []string{
+		" POST /hooks/github?x=1 from 140.82.115.1\n",
+		"Content-Type: application/json\n",
+		"\n{\n  \"action\": \"opened\",\n  \"number\": 7\n}\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+// fakeTunnel puts a program called name on PATH that runs script.
+func fakeTunnel(t *testing.T, name, script string) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestOpenTunnel(t *testing.T) {
+	fakeTunnel(t, "ngrok", `echo '{"lvl":"info","msg":"starting web service","addr":"127.0.0.1:4040"}'
+echo '{"lvl":"info","msg":"started tunnel","obj":"tunnels","addr":"http://localhost:'"$2"'","url":"https://ab12.ngrok-free.app"}'
+exec sleep 30
+`)
+	tun, err := openTunnel("ngrok", "9000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tun.url != "https://ab12.ngrok-free.app" {
+		t.Errorf("url = %q", tun.url)
+	}
+	start := time.Now()
+	tun.close()
+	if time.Since(start) > 5*time.Second {
+		t.Error("closing the tunnel took", time.Since(start))
+	}
+
+	fakeTunnel(t, "cloudflared", "echo 'failed to request quick Tunnel: 429 Too Many Requests' >&2\nexit 1\n")
+	if _, err := openTunnel("cloudflared", "9000"); err == nil || !strings.Contains(err.Error(), "429 Too Many Requests") {
+		t.Errorf("err = %v", err)
+	}
+	if _, err := openTunnel("serveo", "9000"); err == nil {
+		t.Error("an unknown tunnel program was taken")
+	}
+}
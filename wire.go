@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+)
+
+// wireDump is a request and its answer written out as they went over the
+// connection, the way curl -v shows them: what the signature of a signed
+// request is computed over is easier to check against these than against
+// the headers given on the command line, as Go adds Host, User-Agent,
+// Content-Length and Accept-Encoding on its own.
+type wireDump struct {
+	request  string
+	response string
+	http2    bool // HTTP/2 frames headers rather than writing them out.
+}
+
+// maxWireBody caps how much of a body the dump shows.
+const maxWireBody = 64 << 10
+
+// dumpRequest writes out req as the transport sends it, body included. The
+// body req will be sent with is left for the transport to read.
+func dumpRequest(req *http.Request) string {
+	// Writing the request out goes through a transport of its own, which
+	// mustn't set off the request's trace hooks.
+	r, withBody := req.Clone(context.Background()), false
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			r.Body, withBody = body, true
+		}
+	}
+	if !withBody {
+		r.Body, r.ContentLength = nil, 0
+	}
+	out, err := httputil.DumpRequestOut(r, withBody)
+	if err != nil {
+		return "Couldn't write the request out: " + err.Error()
+	}
+	head, body, _ := bytes.Cut(out, []byte("\r\n\r\n"))
+	return string(head) + "\r\n\r\n" + wireBody(body)
+}
+
+// dumpResponse writes out res as it came, with body, the part of its body
+// that was read. The transport takes some headers out of res.Header to act
+// on them, so they are put back as they were sent.
+func dumpResponse(res *http.Response, body []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\r\n", res.Proto, res.Status)
+	h := res.Header.Clone()
+	if len(res.TransferEncoding) > 0 {
+		h.Set("Transfer-Encoding", strings.Join(res.TransferEncoding, ", "))
+	}
+	h.Write(&b)
+	if res.Uncompressed {
+		// The transport asked for gzip itself, and took the encoding off.
+		b.WriteString("(sent gzipped; shown as decompressed)\r\n")
+	}
+	b.WriteString("\r\n")
+	b.WriteString(wireBody(body))
+	return b.String()
+}
+
+// wireBody shows a body as it is, if it is text, or says how much binary
+// there is otherwise.
+func wireBody(body []byte) string {
+	switch kind := kindOf(sniff(body)); {
+	case len(body) == 0:
+		return ""
+	case kind == kindBinary || kind == kindImage:
+		return fmt.Sprintf("(%d bytes of binary)", len(body))
+	case len(body) > maxWireBody:
+		return fmt.Sprintf("%s\n… %d more bytes", body[:maxWireBody], len(body)-maxWireBody)
+	}
+	return string(body)
+}
+
+// report shows the dump, marking where the request ends and the answer
+// starts, as curl -v does.
+func (w *wireDump) report() reportMsg {
+	var b strings.Builder
+	if w.http2 {
+		b.WriteString("Sent over HTTP/2, which frames and compresses these headers rather than writing them out.\n\n")
+	}
+	for _, line := range strings.Split(strings.TrimRight(w.request, "\r\n"), "\n") {
+		b.WriteString("> " + strings.TrimSuffix(line, "\r") + "\n")
+	}
+	b.WriteString("\n")
+	for _, line := range strings.Split(strings.TrimRight(w.response, "\r\n"), "\n") {
+		b.WriteString("< " + strings.TrimSuffix(line, "\r") + "\n")
+	}
+	return reportMsg{title: "Wire", body: strings.TrimRight(b.String(), "\n")}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWireDump(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(`{"ok": true}`))
+		zw.Close()
+	}))
+	defer srv.Close()
+
+	cfg := config{method: "POST", url: srv.URL + "/orders?id=7", header: http.Header{"X-Signature": {"abc"}}, body: []byte(`{"n": 1}`)}
+	res, ok := send(cfg).(responseMsg)
+	if !ok || res.wire == nil {
+		t.Fatalf("send = %#v", res)
+	}
+	got := res.wire.report().body
+	for _, want := range []string{
+		"> POST /orders?id=7 HTTP/1.1",
+		"> Host: " + strings.TrimPrefix(srv.URL, "http://"),
+		"> User-Agent: Go-http-client/1.1",
+		"> Content-Length: 8",
+		"> X-Signature: abc",
+		"> Accept-Encoding: gzip",
+		`> {"n": 1}`,
+		"< HTTP/1.1 200 OK",
+		"< Content-Type: application/json",
+		"< (sent gzipped; shown as decompressed)",
+		`< {"ok": true}`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("wire lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestWireBody(t *testing.T) {
+	if got := wireBody([]byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0}); got != "(9 bytes of binary)" {
+		t.Errorf("binary body = %q", got)
+	}
+	long := bytes.Repeat([]byte("a"), maxWireBody+5)
+	if got := wireBody(long); !strings.HasSuffix(got, "\n… 5 more bytes") {
+		t.Errorf("long body ends %q", got[len(got)-20:])
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// progressMsg reports how far a background job has got. next waits for
+// the job's following message, which is either more progress or its result.
+type progressMsg struct {
+	status string
+	next   tea.Cmd
+}
+
+// progressEvery is the least time between two progress reports of a job.
+// Anything the job reports in between is folded into the next one, so a
+// fast job can't flood the Update loop with messages.
+const progressEvery = 100 * time.Millisecond
+
+// runJob returns a command that runs work in the background. work calls
+// report to say how it is doing, and returns the message that is its result.
+func runJob(work func(report func(status string)) tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		// The channel holds one pending report; while it is full, newer
+		// reports are dropped.
+		updates := make(chan tea.Msg, 1)
+		go func() {
+			var mu sync.Mutex // work may report from several goroutines.
+			var last time.Time
+			report := func(status string) {
+				mu.Lock()
+				defer mu.Unlock()
+				if time.Since(last) < progressEvery {
+					return
+				}
+				last = time.Now()
+				select {
+				case updates <- progressMsg{status: status}:
+				default:
+				}
+			}
+			updates <- work(report)
+		}()
+		return waitForJob(updates)()
+	}
+}
+
+// waitForJob returns a command that delivers the next message from a job.
+func waitForJob(updates chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg := <-updates
+		if p, ok := msg.(progressMsg); ok {
+			p.next = waitForJob(updates)
+			return p
+		}
+		return msg
+	}
+}
+
+// newSpinner makes the spinner shown while a request or job is running.
+func newSpinner() spinner.Model {
+	return spinner.New(spinner.WithSpinner(spinner.MiniDot))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestRunJob(t *testing.T) {
+	release := make(chan struct{})
+	cmd := runJob(func(report func(string)) tea.Msg {
+		report("half way")
+		report("dropped, too soon after the first")
+		<-release
+		return reportMsg{title: "Job", body: "done"}
+	})
+
+	msg := cmd()
+	p, ok := msg.(progressMsg)
+	if !ok || p.status != "half way" || p.next == nil {
+		t.Fatalf("first message = %#v, want progress", msg)
+	}
+	close(release)
+	msg = p.next()
+	if r, ok := msg.(reportMsg); !ok || r.body != "done" {
+		t.Errorf("result = %#v, want the report", msg)
+	}
+}
+
+func TestRunJobThrottles(t *testing.T) {
+	var sent int
+	cmd := runJob(func(report func(string)) tea.Msg {
+		start := time.Now()
+		for time.Since(start) < 3*progressEvery {
+			report("working")
+		}
+		return reportMsg{}
+	})
+	for msg := cmd(); ; {
+		p, ok := msg.(progressMsg)
+		if !ok {
+			break
+		}
+		sent++
+		msg = p.next()
+	}
+	if sent == 0 || sent > 4 {
+		t.Errorf("%d progress messages in %s, want a few", sent, 3*progressEvery)
+	}
+}
+
+func TestJobShownWhileRunning(t *testing.T) {
+	m := model{job: "Building the table", spin: newSpinner()}
+	m.res.status = 200
+	next, _ := m.Update(progressMsg{status: "Crawling: 3 pages checked"})
+	m = next.(model)
+	if m.job != "Crawling: 3 pages checked" {
+		t.Errorf("job = %q", m.job)
+	}
+	if view := m.View(); !strings.Contains(view, "Crawling: 3 pages checked") {
+		t.Errorf("view lacks the job:\n%s", view)
+	}
+}
+
+func TestOneCrawlAtATime(t *testing.T) {
+	m := model{job: "Crawling", spin: newSpinner()}
+	m.res.status = 200
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")}); cmd != nil {
+		t.Error("c started a second crawl")
+	}
+}